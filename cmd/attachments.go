@@ -0,0 +1,113 @@
+// Package cmd provides the CLI commands for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/linkalls/gmn/internal/compact"
+	"github.com/linkalls/gmn/internal/confirmation"
+	"github.com/linkalls/gmn/internal/input"
+	"github.com/linkalls/gmn/internal/tools"
+	"github.com/linkalls/gmn/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+// errAttachmentsCancelled is returned when the user cancels trimming
+var errAttachmentsCancelled = errors.New("cancelled: attached files exceed the token budget")
+
+// fitAttachments keeps the attached files within budget tokens (0 means no
+// limit). When they are over it, the user picks files to drop or to replace
+// with a summary written by summaryModel; sending them anyway is also an
+// option. Without a terminal to ask on, it fails instead.
+func fitAttachments(ctx context.Context, attachments []input.Attachment, budget int, summaryModel string, connect tui.Connector) ([]input.Attachment, error) {
+	for budget > 0 && input.TotalTokens(attachments) > budget {
+		if !term.IsTerminal(os.Stdin.Fd()) || !term.IsTerminal(os.Stdout.Fd()) {
+			return nil, input.CheckBudget(attachments, budget)
+		}
+
+		files := make([]confirmation.TrimFile, len(attachments))
+		for i, a := range attachments {
+			files[i] = confirmation.TrimFile{Path: a.Path, Tokens: a.Tokens()}
+		}
+		actions, ok, err := confirmation.PromptTrim(files, budget)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errAttachmentsCancelled
+		}
+
+		var kept []input.Attachment
+		changed := false
+		for i, a := range attachments {
+			switch actions[i] {
+			case confirmation.TrimDrop:
+				changed = true
+				continue
+			case confirmation.TrimSummarize:
+				changed = true
+				summary, err := summarizeAttachment(ctx, connect, summaryModel, a)
+				if err != nil {
+					return nil, err
+				}
+				a = input.Attachment{Path: a.Path, Content: summary, Summary: true}
+			}
+			kept = append(kept, a)
+		}
+		attachments = kept
+		if !changed {
+			// Sent as is at the user's request
+			break
+		}
+	}
+	return attachments, nil
+}
+
+// summarizeAttachment replaces an attached file with a model-written summary
+func summarizeAttachment(ctx context.Context, connect tui.Connector, summaryModel string, a input.Attachment) (string, error) {
+	conn, err := connect()
+	if err != nil {
+		return "", err
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	spin := newSpinner(fmt.Sprintf("Summarizing %s...", a.Path))
+	spin.Start()
+	summary, err := compact.SummarizeFile(reqCtx, conn.Client, conn.ProjectID, summaryModel, a.Path, a.Content)
+	spin.Stop()
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render(
+		fmt.Sprintf("✓ Summarized %s (~%d → ~%d tokens)", a.Path, a.Tokens(), (len(summary)+3)/4)))
+	return summary, nil
+}
+
+// completeFiles completes --file with the paths below the working
+// directory, leaving out those the file tools skip (.gitignore and
+// .gmnignore, .git and node_modules)
+func completeFiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	matches := input.CompletePath(toComplete, cwd, tools.NewIgnoreFilter(cwd).Ignored)
+	directive := cobra.ShellCompDirectiveNoFileComp
+	for _, m := range matches {
+		if strings.HasSuffix(m, "/") {
+			directive |= cobra.ShellCompDirectiveNoSpace // Keep going into the directory
+			break
+		}
+	}
+	return matches, directive
+}
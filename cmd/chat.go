@@ -2,33 +2,58 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
 	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/auth"
 	"github.com/linkalls/gmn/internal/cli"
+	"github.com/linkalls/gmn/internal/commands"
+	"github.com/linkalls/gmn/internal/compact"
+	"github.com/linkalls/gmn/internal/config"
 	"github.com/linkalls/gmn/internal/confirmation"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/guard"
+	"github.com/linkalls/gmn/internal/history"
 	"github.com/linkalls/gmn/internal/input"
+	"github.com/linkalls/gmn/internal/locale"
+	"github.com/linkalls/gmn/internal/mcp"
+	"github.com/linkalls/gmn/internal/notify"
 	"github.com/linkalls/gmn/internal/output"
 	"github.com/linkalls/gmn/internal/session"
+	"github.com/linkalls/gmn/internal/shellhook"
+	"github.com/linkalls/gmn/internal/telemetry"
+	"github.com/linkalls/gmn/internal/termcolor"
+	"github.com/linkalls/gmn/internal/textwidth"
+	"github.com/linkalls/gmn/internal/timing"
+	"github.com/linkalls/gmn/internal/title"
 	"github.com/linkalls/gmn/internal/tools"
 	"github.com/linkalls/gmn/internal/tui"
+	"github.com/linkalls/gmn/pkg/agent"
 	"github.com/spf13/cobra"
 )
 
 var (
-	yoloMode      bool   // Skip all confirmations
-	chatPrompt    string // Initial prompt from -p flag (chat-specific)
-	shellPath     string // Custom shell path
-	resumeSession string // Session ID to resume
-	useTUI        bool   // Use full TUI mode
+	yoloMode      bool                       // Skip all confirmations
+	chatPrompt    string                     // Initial prompt from -p flag (chat-specific)
+	shellPath     string                     // Custom shell path
+	resumeSession string                     // Session ID to resume
+	useTUI        bool                       // Use full TUI mode
+	enabledTools  []string                   // Only offer these tools to the model (--tools)
+	failureNotes  bool                       // Tell the model about tools that keep failing (settings)
+	promptGuard   guard.Limits               // Size and cost above which requests are confirmed (settings)
+	temperature   float64                    // Sampling temperature (settings)
+	notifications config.NotificationsConfig // Notify when a long turn finishes (settings)
 	sessionTokens struct {
 		input  int
 		output int
@@ -55,7 +80,14 @@ func newSpinner(message string) *spinner {
 	}
 }
 
+// Start shows the spinner on stderr. On dumb terminals, and when stderr is
+// not a terminal, the message is printed once instead.
 func (s *spinner) Start() {
+	if termcolor.Dumb() || !term.IsTerminal(os.Stderr.Fd()) {
+		fmt.Fprintln(os.Stderr, s.message)
+		close(s.done)
+		return
+	}
 	go func() {
 		ticker := time.NewTicker(80 * time.Millisecond)
 		defer ticker.Stop()
@@ -108,68 +140,112 @@ func DefaultShell() string {
 var chatCmd = &cobra.Command{
 	Use:   "chat",
 	Short: "Start an interactive chat session",
-	RunE:  runChat,
+	Example: `  gmn chat
+  gmn chat -m gemini-3-pro-preview
+  gmn chat -p "Explain this codebase"
+  gmn chat -r last
+  gmn chat --tui=false --shell /bin/zsh`,
+	RunE: runChat,
 }
 
 // TUI styles
 var (
-	// Modern accent colors
-	accentPurple = lipgloss.Color("#7C3AED")
-	accentGreen  = lipgloss.Color("#10B981")
-	accentBlue   = lipgloss.Color("#3B82F6")
-	mutedGray    = lipgloss.Color("#6B7280")
-	dimGray      = lipgloss.Color("#9CA3AF")
-	borderColor  = lipgloss.Color("#374151")
+	// Modern accent colors, with fallbacks for 16-color terminals
+	accentPurple lipgloss.CompleteColor
+	accentGreen  lipgloss.CompleteColor
+	accentBlue   lipgloss.CompleteColor
+	accentRed    lipgloss.CompleteColor
+	accentAmber  lipgloss.CompleteColor
+	mutedGray    lipgloss.CompleteColor
+	dimGray      lipgloss.CompleteColor
+	borderColor  lipgloss.CompleteColor
+	surfaceGray  lipgloss.CompleteColor
+
+	// Header styles
+	logoStyle       lipgloss.Style
+	modelBadgeStyle lipgloss.Style
+	infoBadgeStyle  lipgloss.Style
+	headerBoxStyle  lipgloss.Style
+	promptStyle     lipgloss.Style
+
+	// Tool styles
+	toolCallStyle   lipgloss.Style
+	toolNameStyle   lipgloss.Style
+	toolResultStyle lipgloss.Style
+	toolBoxStyle    lipgloss.Style
+
+	// Stats styles
+	statsBoxStyle lipgloss.Style
+)
+
+// The line-mode palette is rebuilt whenever the theme changes
+func init() {
+	termcolor.OnTheme(setChatStyles)
+}
+
+// setChatStyles builds the colors and styles of the line-mode chat for the
+// current theme
+func setChatStyles() {
+	// Modern accent colors, with fallbacks for 16-color terminals
+	accentPurple = termcolor.Color("#7C3AED", termcolor.Magenta)
+	accentGreen = termcolor.Color("#10B981", termcolor.Green)
+	accentBlue = termcolor.Color("#3B82F6", termcolor.Blue)
+	accentRed = termcolor.Color("#EF4444", termcolor.Red)
+	accentAmber = termcolor.Color("#F59E0B", termcolor.Yellow)
+	mutedGray = termcolor.Color("#6B7280", termcolor.BrightBlack)
+	dimGray = termcolor.Color("#9CA3AF", termcolor.White)
+	borderColor = termcolor.Border("#374151", termcolor.BrightBlack)
+	surfaceGray = termcolor.Background("#1F2937", termcolor.Black)
 
 	// Header styles
 	logoStyle = lipgloss.NewStyle().
-			Foreground(accentPurple).
-			Bold(true)
+		Foreground(accentPurple).
+		Bold(true)
 
 	modelBadgeStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFFFFF")).
-			Background(accentPurple).
-			Padding(0, 1).
-			Bold(true)
+		Foreground(termcolor.On("#FFFFFF", accentPurple)).
+		Background(accentPurple).
+		Padding(0, 1).
+		Bold(true)
 
 	infoBadgeStyle = lipgloss.NewStyle().
-			Foreground(dimGray).
-			Background(lipgloss.Color("#1F2937")).
-			Padding(0, 1)
+		Foreground(dimGray).
+		Background(surfaceGray).
+		Padding(0, 1)
 
 	headerBoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(borderColor).
-			Padding(0, 2).
-			MarginBottom(1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(borderColor).
+		Padding(0, 2).
+		MarginBottom(1)
 
 	promptStyle = lipgloss.NewStyle().
-			Foreground(accentGreen).
-			Bold(true)
+		Foreground(accentGreen).
+		Bold(true)
 
 	// Tool styles
 	toolCallStyle = lipgloss.NewStyle().
-			Foreground(accentPurple).
-			Bold(true)
+		Foreground(accentPurple).
+		Bold(true)
 	toolNameStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#F59E0B")).
-			Bold(true)
+		Foreground(accentAmber).
+		Bold(true)
 	toolResultStyle = lipgloss.NewStyle().
-			Foreground(accentGreen)
+		Foreground(accentGreen)
 	toolBoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("#374151")).
-			Padding(0, 1).
-			MarginTop(1).
-			MarginBottom(1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(borderColor).
+		Padding(0, 1).
+		MarginTop(1).
+		MarginBottom(1)
 
 	// Stats styles
 	statsBoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(borderColor).
-			Padding(0, 2).
-			MarginTop(1)
-)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(borderColor).
+		Padding(0, 2).
+		MarginTop(1)
+}
 
 func init() {
 	rootCmd.AddCommand(chatCmd)
@@ -183,16 +259,19 @@ func init() {
 	chatCmd.Flags().StringVar(&shellPath, "shell", "", "Shell to use for commands (default: auto-detect)")
 	chatCmd.Flags().StringVarP(&resumeSession, "resume", "r", "", "Resume a previous session (ID, name, or 'last')")
 	chatCmd.Flags().BoolVar(&useTUI, "tui", true, "Use full TUI mode (default: true)")
+	chatCmd.Flags().StringSliceVar(&enabledTools, "tools", nil, "Only offer these tools to the model (comma-separated names or globs, e.g. read_file,git_*)")
 
 	chatCmd.RegisterFlagCompletionFunc("model", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return AvailableModels, cobra.ShellCompDirectiveNoFileComp
 	})
+	chatCmd.RegisterFlagCompletionFunc("file", completeFiles)
+	chatCmd.RegisterFlagCompletionFunc("resume", completeSessions)
 }
 
 // displayHeader shows a rich header with model info
 func displayHeader(modelName string, yolo bool) {
 	// Logo and version
-	logo := logoStyle.Render("✨ gmn")
+	logo := logoStyle.Render(glyphs.Label(glyphs.Current().Logo, "gmn"))
 	versionBadge := lipgloss.NewStyle().
 		Foreground(dimGray).
 		Render("Gemini CLI")
@@ -206,16 +285,16 @@ func displayHeader(modelName string, yolo bool) {
 
 	if yolo {
 		yoloBadge := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFFFFF")).
-			Background(lipgloss.Color("#EF4444")).
+			Foreground(termcolor.On("#FFFFFF", accentRed)).
+			Background(accentRed).
 			Padding(0, 1).
 			Bold(true).
-			Render("⚡ YOLO")
+			Render(glyphs.Label(glyphs.Current().Yolo, "YOLO"))
 		badges = append(badges, yoloBadge)
 	}
 
 	cwd, _ := os.Getwd()
-	cwdBadge := infoBadgeStyle.Render("📁 " + cwd)
+	cwdBadge := infoBadgeStyle.Render(glyphs.Label(glyphs.Current().Folder, cwd))
 
 	// Build header
 	header := fmt.Sprintf("%s %s  %s\n%s", logo, versionBadge, strings.Join(badges, " "), cwdBadge)
@@ -257,19 +336,20 @@ func displayStats(inputTokens, outputTokens int, duration time.Duration) {
 	totalCost := inputCost + outputCost
 
 	// Format stats
+	loc := locale.Current()
 	stats := fmt.Sprintf(
-		"%s\n\n  %s %s tokens\n  %s %s tokens\n  %s %s tokens\n  %s %s\n  %s ~$%.6f",
-		headerStyle.Render("📊 Session Stats"),
+		"%s\n\n  %s %s tokens\n  %s %s tokens\n  %s %s tokens\n  %s %s\n  %s ~%s",
+		headerStyle.Render(glyphs.Label(glyphs.Current().Stats, "Session Stats")),
 		labelStyle.Render("Input:   "),
-		tokenStyle.Render(fmt.Sprintf("%d", inputTokens)),
+		tokenStyle.Render(loc.Int(inputTokens)),
 		labelStyle.Render("Output:  "),
-		tokenStyle.Render(fmt.Sprintf("%d", outputTokens)),
+		tokenStyle.Render(loc.Int(outputTokens)),
 		labelStyle.Render("Total:   "),
-		tokenStyle.Render(fmt.Sprintf("%d", totalTokens)),
+		tokenStyle.Render(loc.Int(totalTokens)),
 		labelStyle.Render("Duration:"),
 		tokenStyle.Render(duration.Round(time.Second).String()),
 		labelStyle.Render("Est Cost:"),
-		totalCost,
+		loc.Cost(totalCost, 6),
 	)
 
 	fmt.Fprintln(os.Stderr)
@@ -316,8 +396,8 @@ func displayConversationHistory(history []api.Content) {
 					lines := strings.Split(text, "\n")
 					if len(lines) > 5 {
 						text = strings.Join(lines[:5], "\n") + "\n..."
-					} else if len(text) > 500 {
-						text = text[:500] + "..."
+					} else {
+						text = textwidth.Truncate(text, 500)
 					}
 					fmt.Fprintln(os.Stderr, userStyle.Render("❯ ")+text)
 				}
@@ -331,8 +411,8 @@ func displayConversationHistory(history []api.Content) {
 					lines := strings.Split(text, "\n")
 					if len(lines) > 10 {
 						text = strings.Join(lines[:10], "\n") + "\n..."
-					} else if len(text) > 1000 {
-						text = text[:1000] + "..."
+					} else {
+						text = textwidth.Truncate(text, 1000)
 					}
 					fmt.Fprintln(os.Stderr, modelStyle.Render(text))
 				}
@@ -354,11 +434,17 @@ func runChat(cmd *cobra.Command, args []string) error {
 		confirmation.YoloMode = true
 	}
 
+	// The TUI needs an interactive terminal; pipes, logs and TERM=dumb get
+	// the line-based chat
+	if useTUI && termcolor.Dumb() {
+		useTUI = false
+		fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("Not an interactive terminal, using the line-based chat (--tui=false)"))
+	}
+
 	// Set shell path for tools
 	if shellPath == "" {
 		shellPath = DefaultShell()
 	}
-	tools.SetShellPath(shellPath)
 
 	// For chat, we don't want a short timeout context for the whole session.
 	// We'll use a background context for setup, and per-request timeout.
@@ -370,21 +456,29 @@ func runChat(cmd *cobra.Command, args []string) error {
 		initialPrompt = strings.Join(args, " ")
 	}
 
-	// Setup client (reusing logic from root.go)
-	apiClient, projectID, userTier, err := setupClient(ctx)
+	// Credentials are read now so a missing login fails fast; the network
+	// setup runs while the prompt is already on screen
+	authMgr, creds, err := loadCredentials()
 	if err != nil {
 		return err
 	}
+	connect := connectInBackground(ctx, authMgr, creds, cmd.Flags().Changed("model"))
 
-	// Apply tier-based default model if user didn't specify
-	effectiveModel := getEffectiveModel(model, userTier, cmd.Flags().Changed("model"))
+	// Apply tier-based default model if user didn't specify (the cached tier
+	// is used until the connection reports the current one)
+	cachedState, _ := config.LoadCachedState()
+	effectiveModel := getEffectiveModel(model, cachedState.UserTier, cmd.Flags().Changed("model"))
 
 	// Initialize tool registry with current working directory
 	cwd, err := os.Getwd()
 	if err != nil {
 		cwd = "."
 	}
-	toolRegistry := tools.NewRegistry(cwd)
+	// The agent takes the public registry; gmn configures the one behind it
+	agentTools := agent.NewRegistry(cwd)
+	toolRegistry := tools.RegistryOf(agentTools)
+	defer toolRegistry.Jobs().KillAll() // Background commands end with the chat
+	toolRegistry.Settings().SetShellPath(shellPath)
 
 	// Initialize session manager
 	sessionMgr, err := session.NewManager()
@@ -394,28 +488,141 @@ func runChat(cmd *cobra.Command, args []string) error {
 		sessionMgr = nil
 	}
 
+	// Load settings (defaults apply if they are unreadable)
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+	if askProjectTrust(cfg) {
+		// Trusted now, so the project's settings apply in full
+		if trusted, err := config.Load(); err == nil {
+			cfg = trusted
+		}
+	}
+	for _, problem := range errorLines(toolRegistry.ApplySettings(cfg)) {
+		fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("⚠ "+problem))
+	}
+
+	// MCP servers run for the whole chat; sampling requests are confirmed in
+	// the terminal, or in the TUI's dialog once it runs
+	mcpOpts := mcp.Options{Roots: workspaceRoots()}
+	tuiConfirmer := &tui.Confirmer{}
+	if useTUI {
+		mcpOpts.Sampler = geminiSampler(tuiConfirmer.Confirm)
+	} else {
+		mcpOpts.Sampler = terminalSampler()
+	}
+	mcpConns, err := connectMCPServers(ctx, cfg, toolRegistry, mcpOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("⚠ mcpServers: "+err.Error()))
+	}
+	defer func() {
+		for _, conn := range mcpConns {
+			conn.Close()
+		}
+	}()
+	if err := toolRegistry.SetDisabled(cfg.Tools.Disabled); err != nil {
+		fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("⚠ tools.disabled: "+err.Error()))
+	}
+	if err := toolRegistry.Restrict(enabledTools); err != nil {
+		return fmt.Errorf("--tools: %w", err)
+	}
+
+	// Attached files (-f) are trimmed to the token budget before the chat starts
+	attachments, err := input.LoadAttachments(files)
+	if err != nil {
+		return err
+	}
+	attachments, err = fitAttachments(ctx, attachments, cfg.Attachments.Budget(), cfg.Compaction.Model, connect)
+	if err != nil {
+		return err
+	}
+
 	// Use TUI mode if enabled (default)
 	if useTUI {
 		tuiConfig := tui.Config{
 			Model:           effectiveModel,
 			YoloMode:        yoloMode,
 			Cwd:             cwd,
-			ProjectID:       projectID,
+			Connect:         connect,
 			Timeout:         timeout,
 			AvailableModels: AvailableModels,
-			InitialPrompt:   initialPrompt,
+			InitialPrompt:   input.WithAttachments(initialPrompt, attachments),
 			ResumeSession:   resumeSession,
+			Compaction:      compact.FromConfig(cfg.Compaction),
+			AllowedTools:    cfg.Tools.Allowed,
+			AutoTitle:       cfg.Sessions.AutoTitle,
+			FailureNotes:    cfg.Tools.FailureNotes,
+			AutoSave:        cfg.Sessions.AutoSaveEvery(),
+			Debug:           debug,
+			PromptGuard:     guard.FromConfig(cfg.PromptGuard),
+			AttachBudget:    cfg.Attachments.Budget(),
+			Temperature:     cfg.Model.GenerationTemperature(),
+			Layout:          cfg.UI.Layout,
+			Notifications:   cfg.Notifications,
+			History:         openHistory(cfg),
+			Confirmer:       tuiConfirmer,
+			Fallback:        GetFallbackModels,
 		}
-		return tui.Run(tuiConfig, apiClient, sessionMgr, toolRegistry)
+		return tui.Run(tuiConfig, sessionMgr, agentTools)
 	}
 
 	// Legacy REPL mode (--tui=false)
-	return runLegacyREPL(cmd, apiClient, projectID, effectiveModel, initialPrompt, cwd, toolRegistry, sessionMgr, startTime)
+	return runLegacyREPL(cmd, connect, effectiveModel, initialPrompt, attachments, cwd, agentTools, sessionMgr, cfg, startTime)
+}
+
+// openHistory opens the prompt history of the current project, shared by
+// the TUI and the line-based chat. It returns nil, which disables the
+// history, if the history file cannot be read.
+func openHistory(cfg *config.Config) *history.History {
+	file, err := config.HistoryFile(cfg)
+	if err != nil {
+		if debug {
+			fmt.Fprintf(os.Stderr, "Prompt history disabled: %v\n", err)
+		}
+		return nil
+	}
+	project, err := config.ProjectDir()
+	if err != nil {
+		project = ""
+	}
+	h, err := history.Open(file, project)
+	if err != nil {
+		if debug {
+			fmt.Fprintf(os.Stderr, "Prompt history disabled: %v\n", err)
+		}
+		return nil
+	}
+	return h
+}
+
+// connectInBackground starts connecting the API client (token refresh and
+// Code Assist discovery) and returns a connector that waits for the result
+func connectInBackground(ctx context.Context, authMgr *auth.Manager, creds *auth.Credentials, modelSpecified bool) tui.Connector {
+	var (
+		conn tui.Connection
+		err  error
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var userTier string
+		conn.Client, conn.ProjectID, userTier, err = connectClient(ctx, authMgr, creds)
+		conn.TierModel = tierDefaultModel(userTier)
+		if !modelSpecified {
+			conn.Model = getEffectiveModel(model, userTier, false)
+		}
+	}()
+	return func() (tui.Connection, error) {
+		<-done
+		return conn, err
+	}
 }
 
 // runLegacyREPL runs the legacy liner-based REPL
-func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effectiveModel, initialPrompt, cwd string, toolRegistry *tools.Registry, sessionMgr *session.Manager, startTime time.Time) error {
+func runLegacyREPL(cmd *cobra.Command, connect tui.Connector, effectiveModel, initialPrompt string, attachments []input.Attachment, cwd string, agentTools *agent.Registry, sessionMgr *session.Manager, cfg *config.Config, startTime time.Time) error {
 	ctx := context.Background()
+	toolRegistry := tools.RegistryOf(agentTools)
 
 	// Setup signal handler for Ctrl+C
 	sigChan := make(chan os.Signal, 1)
@@ -424,20 +631,74 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 		<-sigChan
 		fmt.Fprintln(os.Stderr) // New line after ^C
 		displayStats(sessionTokens.input, sessionTokens.output, time.Since(sessionStartTime))
+		toolRegistry.Jobs().KillAll()
+		telemetry.Shutdown()
 		os.Exit(0)
 	}()
 	defer signal.Stop(sigChan)
 
 	// Display rich header
 	displayHeader(effectiveModel, yoloMode)
+	defaultModel := effectiveModel // Not chosen by the user or a resumed session
 
 	// Initialize allow list for session
 	allowList := confirmation.NewAllowList()
+	allowList.SetConfigured(cfg.Tools.Allowed)
+	compactOpts := compact.FromConfig(cfg.Compaction)
+	attachBudget := cfg.Attachments.Budget()
+	autoTitle := cfg.Sessions.AutoTitle
+	failureNotes = cfg.Tools.FailureNotes
+	temperature = cfg.Model.GenerationTemperature()
+	promptGuard = guard.FromConfig(cfg.PromptGuard)
+	notifications = cfg.Notifications
+
+	// Settings changes (tool allowlist, compaction) apply without a restart
+	applyConfig := func(newCfg *config.Config) error {
+		allowList.SetConfigured(newCfg.Tools.Allowed)
+		compactOpts = compact.FromConfig(newCfg.Compaction)
+		attachBudget = newCfg.Attachments.Budget()
+		autoTitle = newCfg.Sessions.AutoTitle
+		failureNotes = newCfg.Tools.FailureNotes
+		temperature = newCfg.Model.GenerationTemperature()
+		promptGuard = guard.FromConfig(newCfg.PromptGuard)
+		notifications = newCfg.Notifications
+		return errors.Join(
+			toolRegistry.ApplySettings(newCfg),
+			toolRegistry.SetDisabled(newCfg.Tools.Disabled),
+		)
+	}
+	// Custom slash commands are re-read with the settings
+	customCommands, err := commands.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("⚠ commands: "+err.Error()))
+	}
+	reloadConfig := func() error {
+		newCfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		var cmdErr error
+		customCommands, cmdErr = commands.Load()
+		return errors.Join(applyConfig(newCfg), cmdErr)
+	}
+	watcher, _ := config.NewWatcher()
 
 	// Prepare history
 	var history []api.Content
 	var currentSession *session.Session
 
+	// Context queued by commands like /run --inject, sent with the next prompt
+	var pendingContext []string
+	var lastCommand *tools.UserCommand // The last !command, for /last-cmd
+	withPendingContext := func(text string) string {
+		if len(pendingContext) == 0 {
+			return text
+		}
+		text = strings.Join(pendingContext, "\n\n") + "\n\n" + text
+		pendingContext = nil
+		return text
+	}
+
 	// Check if resuming a session
 	if resumeSession != "" && sessionMgr != nil {
 		var loadErr error
@@ -447,25 +708,10 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 			currentSession, loadErr = sessionMgr.Load(resumeSession)
 		}
 		if loadErr != nil {
-			fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(lipgloss.Color("#EF4444")).Render("✗ Failed to load session: "+loadErr.Error()))
+			fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ Failed to load session: "+loadErr.Error()))
 		} else {
 			// Restore history from session
-			for _, msg := range currentSession.Messages {
-				var content api.Content
-				if roleStr, ok := msg["role"].(string); ok {
-					content.Role = roleStr
-				}
-				if partsRaw, ok := msg["parts"].([]interface{}); ok {
-					for _, p := range partsRaw {
-						if partMap, ok := p.(map[string]interface{}); ok {
-							if text, ok := partMap["text"].(string); ok {
-								content.Parts = append(content.Parts, api.Part{Text: text})
-							}
-						}
-					}
-				}
-				history = append(history, content)
-			}
+			history = append(history, session.ToHistory(currentSession.Messages)...)
 			sessionTokens.input = currentSession.Tokens.Input
 			sessionTokens.output = currentSession.Tokens.Output
 			effectiveModel = currentSession.Model
@@ -487,32 +733,72 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 	}
 
 	// Prepare initial input (files + prompt)
-	inputText, err := input.PrepareInput(initialPrompt, files)
+	inputText, err := input.CombineInput(initialPrompt, attachments)
 	if err != nil {
 		return err
 	}
 
-	// Create formatter (force text format for chat for now)
+	// Create formatter (force text format for chat for now), rendering
+	// responses as markdown on color terminals
 	formatter, err := output.NewFormatter("text", os.Stdout, os.Stderr)
 	if err != nil {
 		return err
 	}
+	formatter = newMarkdownFormatter(formatter, os.Stdout)
+
+	// The client connects in the background and the first request waits for
+	// it. The tier's default model applies unless the user picked one.
+	connected := func() (tui.Connection, error) {
+		conn, err := connect()
+		if err == nil && conn.Model != "" && effectiveModel == defaultModel {
+			effectiveModel, defaultModel = conn.Model, conn.Model
+		}
+		return conn, err
+	}
+
+	// Titles are generated in the background and applied on the next save
+	type generatedTitle struct{ sessionID, title string }
+	titles := make(chan generatedTitle, 1)
+	titledID, titlePending := "", false
+	requestTitle := func() {
+		if !autoTitle || sessionMgr == nil || currentSession == nil ||
+			currentSession.Name != "" || titledID == currentSession.ID || len(history) == 0 {
+			return
+		}
+		titledID, titlePending = currentSession.ID, true
+		id, snapshot := currentSession.ID, append([]api.Content(nil), history...)
+		go func() {
+			reqCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			var t string
+			conn, err := connect()
+			if err == nil {
+				t, err = title.Generate(reqCtx, conn.Client, conn.ProjectID, snapshot)
+			}
+			if err != nil && debug {
+				fmt.Fprintf(os.Stderr, "Title generation failed: %v\n", err)
+			}
+			titles <- generatedTitle{id, t} // Empty on failure
+		}()
+	}
+	applyTitle := func(t generatedTitle) {
+		titlePending = false
+		// Skip if the user switched sessions or named this one meanwhile
+		if t.title != "" && currentSession != nil && currentSession.ID == t.sessionID && currentSession.Name == "" {
+			currentSession.Name = sessionMgr.UniqueName(t.title)
+		}
+	}
 
 	// Auto-save function
 	autoSave := func() {
 		if sessionMgr != nil && currentSession != nil {
-			// Convert history to session format
-			currentSession.Messages = make([]map[string]interface{}, len(history))
-			for i, h := range history {
-				parts := make([]map[string]interface{}, len(h.Parts))
-				for j, p := range h.Parts {
-					parts[j] = map[string]interface{}{"text": p.Text}
-				}
-				currentSession.Messages[i] = map[string]interface{}{
-					"role":  h.Role,
-					"parts": parts,
-				}
+			select {
+			case t := <-titles:
+				applyTitle(t)
+			default:
 			}
+			// Convert history to session format
+			currentSession.Messages = session.FromHistory(history)
 			currentSession.Tokens.Input = sessionTokens.input
 			currentSession.Tokens.Output = sessionTokens.output
 			currentSession.Model = effectiveModel
@@ -520,6 +806,33 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 		}
 	}
 
+	// checkPromptGuard asks before a turn whose first request is over the
+	// prompt guard limits, compacting the history first if the user picks
+	// that. Later requests of the turn are checked in processWithToolLoop.
+	checkPromptGuard := func(text string) error {
+		next := append(append([]api.Content(nil), history...), api.Content{Role: "user", Parts: []api.Part{{Text: text}}})
+		b := guard.Estimate(next, toolRegistry.SystemInstruction(failureNotes), toolRegistry.GetTools())
+		if !promptGuard.Exceeded(effectiveModel, b) {
+			return nil
+		}
+		choice, err := confirmation.PromptLargeRequest(confirmation.LargeRequest{
+			Model:      effectiveModel,
+			Breakdown:  b,
+			Limits:     promptGuard,
+			CanCompact: len(history) > 0,
+		})
+		if err != nil {
+			return err
+		}
+		switch choice {
+		case confirmation.LargeCompact:
+			return compactHistory(ctx, connect, compactOpts, &history)
+		case confirmation.LargeCancel:
+			return errRequestNotSent
+		}
+		return nil
+	}
+
 	// If there is initial input, process it first
 	if inputText != "" {
 		userStyle := lipgloss.NewStyle().Foreground(accentBlue)
@@ -529,26 +842,90 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 		}
 		fmt.Fprintln(os.Stderr)
 
-		err := processWithToolLoop(ctx, apiClient, projectID, effectiveModel, inputText, &history, formatter, toolRegistry, allowList)
+		err := checkPromptGuard(inputText)
+		if err == nil {
+			var conn tui.Connection
+			if conn, err = connected(); err == nil {
+				err = processWithToolLoop(ctx, conn.Client, conn.ProjectID, effectiveModel, inputText, &history, formatter, agentTools, allowList)
+			}
+		}
 		if err != nil {
 			formatter.WriteError(err)
 		}
 		autoSave() // Auto-save after each interaction
+		requestTitle()
+	}
+
+	// waitForTitle gives a pending title a moment to arrive before exiting
+	waitForTitle := func() {
+		if !titlePending {
+			return
+		}
+		select {
+		case t := <-titles:
+			applyTitle(t)
+		case <-time.After(3 * time.Second):
+		}
 	}
 
 	// Start REPL
+	// sendInput sends a prompt, typed or from a custom command
+	sendInput := func(line string) {
+		if watcher != nil && watcher.Changed() {
+			if err := reloadConfig(); err != nil {
+				fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ Failed to reload settings: "+err.Error()))
+			} else {
+				fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("↻ Settings reloaded"))
+			}
+		}
+		if compactOpts.ShouldCompact(history) {
+			if err := compactHistory(ctx, connect, compactOpts, &history); err != nil {
+				fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ Auto-compaction failed: "+err.Error()))
+			}
+		}
+		// Files mentioned as @path are attached
+		mentioned, err := input.LoadMentions(line, cwd)
+		if err == nil {
+			mentioned, err = fitAttachments(ctx, mentioned, attachBudget, compactOpts.Model, connect)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+			return
+		}
+		for _, file := range mentioned {
+			fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("  "+glyphs.Label(glyphs.Current().FileIcon(file.Path), file.Path)))
+		}
+		line = withPendingContext(input.WithAttachments(line, mentioned))
+		err = checkPromptGuard(line)
+		if err == nil {
+			var conn tui.Connection
+			if conn, err = connected(); err == nil {
+				err = processWithToolLoop(ctx, conn.Client, conn.ProjectID, effectiveModel, line, &history, formatter, agentTools, allowList)
+			}
+		}
+		if err != nil {
+			formatter.WriteError(err)
+		}
+		autoSave() // Auto-save after each interaction
+		requestTitle()
+	}
+
 	replConfig := cli.REPLConfig{
 		Prompt:          "❯ ",
 		AvailableModels: AvailableModels,
 		ToolNames:       toolRegistry.GetToolNames(),
+		History:         openHistory(cfg),
+		PickHistory:     tui.PickPrompt,
+		CommandNames:    func() []string { return customCommands.Names() },
 		OnCommand: func(line string) (handled bool, exit bool) {
 			switch strings.ToLower(strings.TrimSpace(line)) {
 			case "/exit", "/quit", "/q":
+				waitForTitle()
 				autoSave() // Save before exit
 				displayStats(sessionTokens.input, sessionTokens.output, time.Since(startTime))
 				return true, true // handled and exit
 			case "/help", "/h":
-				showHelp()
+				showHelp(customCommands)
 				return true, false
 			case "/clear":
 				history = nil
@@ -557,15 +934,25 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 			case "/stats":
 				displayStats(sessionTokens.input, sessionTokens.output, time.Since(startTime))
 				return true, false
+			case "/reload-config":
+				if err := reloadConfig(); err != nil {
+					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+					return true, false
+				}
+				if watcher != nil {
+					watcher.Changed() // Already applied
+				}
+				fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render("✓ Settings reloaded"))
+				return true, false
 			case "/sessions":
 				// List all sessions
 				if sessionMgr == nil {
-					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(lipgloss.Color("#EF4444")).Render("✗ Session management not available"))
+					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ Session management not available"))
 					return true, false
 				}
 				sessions, err := sessionMgr.List()
 				if err != nil {
-					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(lipgloss.Color("#EF4444")).Render("✗ "+err.Error()))
+					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
 					return true, false
 				}
 				if len(sessions) == 0 {
@@ -588,11 +975,33 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 					}
 					fmt.Fprintf(os.Stderr, "  %s %s%s\n",
 						lipgloss.NewStyle().Foreground(accentPurple).Render(name),
-						lipgloss.NewStyle().Foreground(dimGray).Render(fmt.Sprintf("[%d msgs, %s]", len(s.Messages), s.UpdatedAt.Format("01/02 15:04"))),
+						lipgloss.NewStyle().Foreground(dimGray).Render(fmt.Sprintf("[%d msgs, %s]", s.MessageCount(), locale.Current().Relative(s.UpdatedAt, time.Now()))),
 						lipgloss.NewStyle().Foreground(accentGreen).Render(current))
 				}
 				return true, false
 			default:
+				// !command runs in the shell; !?command also attaches the output
+				if command, ok := strings.CutPrefix(line, "!"); ok {
+					command, attach := strings.CutPrefix(command, "?")
+					command = strings.TrimSpace(command)
+					if command == "" {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("Usage: !<command>, or !?<command> to attach the output to your next message"))
+						return true, false
+					}
+					result, err := runUserCommand(ctx, toolRegistry, command, cwd, sigChan)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+						return true, false
+					}
+					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render(result.Summary()))
+					lastCommand = result
+					if attach {
+						pendingContext = append(pendingContext, result.ContextText())
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render("✓ Output will be attached to your next message"))
+					}
+					return true, false
+				}
+
 				// Check for /model command
 				if line == "/model" || strings.HasPrefix(strings.ToLower(line), "/model ") {
 					parts := strings.Fields(line)
@@ -619,7 +1028,7 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 							displayHeader(effectiveModel, yoloMode)
 							fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render("✓ Model switched to "+newModel))
 						} else {
-							fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(lipgloss.Color("#EF4444")).Render("✗ Invalid model: "+newModel))
+							fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ Invalid model: "+newModel))
 							fmt.Fprintf(os.Stderr, "Available models: %s\n", strings.Join(AvailableModels, ", "))
 						}
 					} else {
@@ -631,7 +1040,7 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 				// Check for /save command
 				if line == "/save" || strings.HasPrefix(strings.ToLower(line), "/save ") {
 					if sessionMgr == nil || currentSession == nil {
-						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(lipgloss.Color("#EF4444")).Render("✗ Session management not available"))
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ Session management not available"))
 						return true, false
 					}
 					parts := strings.Fields(line)
@@ -647,10 +1056,184 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 					return true, false
 				}
 
+				// Check for /run command
+				if line == "/run" || strings.HasPrefix(strings.ToLower(line), "/run ") {
+					inv, err := tools.ParseInvocation(strings.TrimSpace(line[len("/run"):]))
+					if err != nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("Usage: /run [--inject] <tool> key=value..."))
+						return true, false
+					}
+					tool, ok := toolRegistry.Get(inv.Name)
+					if !ok {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ Unknown tool: "+inv.Name))
+						fmt.Fprintf(os.Stderr, "Available tools: %s\n", strings.Join(toolRegistry.GetToolNames(), ", "))
+						return true, false
+					}
+					displayToolCall(&api.FunctionCall{Name: inv.Name, Args: inv.Args})
+					result := agent.Execute(agentTools, tool, inv.Args)
+					displayToolResult(tool, result)
+					fmt.Fprintln(os.Stderr, tools.FormatResult(result))
+					if inv.Inject {
+						pendingContext = append(pendingContext, inv.ContextText(result))
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render("✓ Result will be attached to your next message"))
+					}
+					return true, false
+				}
+
+				// Check for /last-cmd command
+				if strings.ToLower(line) == "/last-cmd" {
+					text, err := captureLastCommand(lastCommand)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+						return true, false
+					}
+					pendingContext = append(pendingContext, text)
+					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render("✓ Last command will be attached to your next message"))
+					return true, false
+				}
+
+				// Check for /memory command
+				if line == "/memory" || strings.HasPrefix(strings.ToLower(line), "/memory ") {
+					text, err := toolRegistry.Memory().RunCommand(line[len("/memory"):])
+					if err != nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+						return true, false
+					}
+					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render("✓ "+text))
+					return true, false
+				}
+
+				// Check for /jobs command
+				if line == "/jobs" || strings.HasPrefix(strings.ToLower(line), "/jobs ") {
+					text, err := toolRegistry.Jobs().RunCommand(line[len("/jobs"):])
+					if err != nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+						return true, false
+					}
+					fmt.Fprintln(os.Stderr, text)
+					return true, false
+				}
+
+				// Check for /rewind command
+				if line == "/rewind" || strings.HasPrefix(strings.ToLower(line), "/rewind ") {
+					parts := strings.Fields(line)
+					n := 1
+					if len(parts) == 2 {
+						var err error
+						if n, err = strconv.Atoi(parts[1]); err != nil {
+							n = 0
+						}
+					}
+					if len(parts) > 2 || n <= 0 {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("Usage: /rewind [N]"))
+						return true, false
+					}
+					rewound, err := compact.Rewind(history, n)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+						return true, false
+					}
+					history = rewound
+					autoSave()
+					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render(fmt.Sprintf("✓ Rewound %d turn(s)", n)))
+					return true, false
+				}
+
+				// Check for /branch command
+				if line == "/branch" || strings.HasPrefix(strings.ToLower(line), "/branch ") {
+					if sessionMgr == nil || currentSession == nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ Session management not available"))
+						return true, false
+					}
+					parts := strings.Fields(line)
+					name := ""
+					if len(parts) == 2 {
+						name = parts[1]
+					}
+					autoSave()
+					branch, err := sessionMgr.Branch(currentSession, name)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+						return true, false
+					}
+					parentID := currentSession.ID
+					currentSession = branch
+					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render("✓ Branched "+branch.ID+" from "+parentID))
+					return true, false
+				}
+
+				// Check for /compact command
+				if line == "/compact" || strings.HasPrefix(strings.ToLower(line), "/compact ") {
+					parts := strings.Fields(line)
+					switch {
+					case len(parts) == 1:
+						if err := compactHistory(ctx, connect, compactOpts, &history); err != nil {
+							fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+							return true, false
+						}
+						autoSave()
+					case len(parts) == 3 && parts[1] == "auto" && (parts[2] == "on" || parts[2] == "off"):
+						compactOpts.Auto = parts[2] == "on"
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render("✓ Auto-compaction "+parts[2]))
+					default:
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("Usage: /compact [auto on|off]"))
+					}
+					return true, false
+				}
+
+				// Check for /summary command
+				if line == "/summary" || strings.HasPrefix(strings.ToLower(line), "/summary ") {
+					parts := strings.Fields(line)
+					if len(parts) > 2 || len(parts) == 2 && parts[1] != "save" {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("Usage: /summary [save]"))
+						return true, false
+					}
+					if err := recapSession(ctx, connect, compactOpts.Model, history, cwd, len(parts) == 2); err != nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+					}
+					return true, false
+				}
+
+				// Check for /search command
+				if line == "/search" || strings.HasPrefix(strings.ToLower(line), "/search ") {
+					if sessionMgr == nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ Session management not available"))
+						return true, false
+					}
+					query, useRegex := session.ParseSearchArgs(line[len("/search"):])
+					if query == "" {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("Usage: /search [--regex] <query>"))
+						return true, false
+					}
+					results, err := sessionMgr.Search(query, useRegex, 20)
+					if err != nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+						return true, false
+					}
+					if len(results) == 0 {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("No matches"))
+						return true, false
+					}
+					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentBlue).Bold(true).Render(fmt.Sprintf("🔍 %d match(es)", len(results))))
+					for _, r := range results {
+						name := r.SessionID
+						if r.Name != "" {
+							name = r.Name + " (" + r.SessionID + ")"
+						}
+						fmt.Fprintf(os.Stderr, "  %s %s\n    %s\n",
+							lipgloss.NewStyle().Foreground(accentPurple).Render(name),
+							lipgloss.NewStyle().Foreground(dimGray).Render(fmt.Sprintf("[%s, %s]", r.Role, locale.Current().ShortDateTime(r.UpdatedAt))),
+							r.Snippet)
+					}
+					fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("Use /load <id> to open a session"))
+					return true, false
+				}
+
 				// Check for /load command
 				if strings.HasPrefix(strings.ToLower(line), "/load ") {
 					if sessionMgr == nil {
-						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(lipgloss.Color("#EF4444")).Render("✗ Session management not available"))
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ Session management not available"))
 						return true, false
 					}
 					parts := strings.Fields(line)
@@ -660,27 +1243,11 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 					}
 					loadedSession, err := sessionMgr.Load(parts[1])
 					if err != nil {
-						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(lipgloss.Color("#EF4444")).Render("✗ "+err.Error()))
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
 						return true, false
 					}
 					// Restore session
-					history = nil
-					for _, msg := range loadedSession.Messages {
-						var content api.Content
-						if roleStr, ok := msg["role"].(string); ok {
-							content.Role = roleStr
-						}
-						if partsRaw, ok := msg["parts"].([]interface{}); ok {
-							for _, p := range partsRaw {
-								if partMap, ok := p.(map[string]interface{}); ok {
-									if text, ok := partMap["text"].(string); ok {
-										content.Parts = append(content.Parts, api.Part{Text: text})
-									}
-								}
-							}
-						}
-						history = append(history, content)
-					}
+					history = session.ToHistory(loadedSession.Messages)
 					currentSession = loadedSession
 					sessionTokens.input = loadedSession.Tokens.Input
 					sessionTokens.output = loadedSession.Tokens.Output
@@ -692,17 +1259,29 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 					return true, false
 				}
 
+				// Custom commands from .gmn/commands; built-in commands win
+				if custom, args, ok := customCommands.Match(line); ok {
+					prompt, files, err := custom.Expand(args)
+					if err == nil {
+						files, err = fitAttachments(ctx, files, attachBudget, compactOpts.Model, connect)
+					}
+					if err != nil {
+						fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentRed).Render("✗ "+err.Error()))
+						return true, false
+					}
+					if len(files) > 0 {
+						pendingContext = append(pendingContext, strings.TrimSpace(input.FormatAttachments(files)))
+					}
+					sendInput(prompt)
+					return true, false
+				}
+
 				return false, false
 			}
 		},
-		OnInput: func(line string) {
-			err := processWithToolLoop(ctx, apiClient, projectID, effectiveModel, line, &history, formatter, toolRegistry, allowList)
-			if err != nil {
-				formatter.WriteError(err)
-			}
-			autoSave() // Auto-save after each interaction
-		},
+		OnInput: sendInput,
 		OnExit: func() {
+			waitForTitle()
 			autoSave() // Save on exit
 			displayStats(sessionTokens.input, sessionTokens.output, time.Since(startTime))
 		},
@@ -711,8 +1290,69 @@ func runLegacyREPL(cmd *cobra.Command, apiClient *api.Client, projectID, effecti
 	return cli.StartREPL(replConfig)
 }
 
+// errRequestNotSent is returned when the user cancels a request over the
+// prompt guard limits
+var errRequestNotSent = errors.New("request not sent; use /compact or /rewind to shrink the conversation")
+
+// compactHistory replaces older turns of history with a summary
+func compactHistory(ctx context.Context, connect tui.Connector, opts compact.Options, history *[]api.Content) error {
+	conn, err := connect()
+	if err != nil {
+		return err
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	spin := newSpinner("Compacting conversation...")
+	spin.Start()
+	compacted, result, err := compact.Compact(reqCtx, conn.Client, conn.ProjectID, opts, *history)
+	spin.Stop()
+	if err != nil {
+		return err
+	}
+
+	*history = compacted
+	sessionTokens.input += result.Usage.PromptTokenCount
+	sessionTokens.output += result.Usage.CandidatesTokenCount
+	fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render(
+		fmt.Sprintf("✓ Compacted %d messages (~%d → ~%d tokens)", result.Summarized, result.TokensBefore, result.TokensAfter)))
+	return nil
+}
+
+// recapSession prints a short recap of the session and, if save is set,
+// appends it to NOTES.md in dir
+func recapSession(ctx context.Context, connect tui.Connector, model string, history []api.Content, dir string, save bool) error {
+	conn, err := connect()
+	if err != nil {
+		return err
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	spin := newSpinner("Summarizing session...")
+	spin.Start()
+	recap, usage, err := compact.Recap(reqCtx, conn.Client, conn.ProjectID, model, history)
+	spin.Stop()
+	if err != nil {
+		return err
+	}
+
+	sessionTokens.input += usage.PromptTokenCount
+	sessionTokens.output += usage.CandidatesTokenCount
+	fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentBlue).Bold(true).Render("📝 Session recap"))
+	fmt.Fprintln(os.Stderr, recap)
+	if save {
+		path, err := compact.SaveRecap(dir, recap)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(accentGreen).Render("✓ Saved to "+path))
+	}
+	return nil
+}
+
 // showHelp displays available commands
-func showHelp() {
+func showHelp(custom commands.Set) {
 	helpStyle := lipgloss.NewStyle().Foreground(dimGray)
 	cmdStyle := lipgloss.NewStyle().Foreground(accentPurple).Bold(true)
 	sectionStyle := lipgloss.NewStyle().Foreground(accentBlue).Bold(true)
@@ -726,6 +1366,15 @@ func showHelp() {
 	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/clear       "), helpStyle.Render("Clear conversation history"))
 	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/stats       "), helpStyle.Render("Show token usage stats"))
 	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/model       "), helpStyle.Render("Show/switch model (e.g., /model gemini-2.5-flash)"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/reload-config"), helpStyle.Render("Re-read settings.json (also automatic when it changes)"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/compact     "), helpStyle.Render("Summarize older turns to free context (/compact auto on|off)"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/summary     "), helpStyle.Render("Recap decisions, files and TODOs (/summary save appends it to NOTES.md)"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/run <tool>  "), helpStyle.Render("Run a tool directly (--inject to attach the result)"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/last-cmd    "), helpStyle.Render("Attach your last !command and its output, or the command and exit code gmn shell-init recorded"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/memory      "), helpStyle.Render("Show, add (--project for GEMINI.md) or refresh saved memory"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/jobs        "), helpStyle.Render("List background shell jobs (/jobs kill <id> to stop one)"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/history [q] "), helpStyle.Render("Fuzzy-search past prompts; the one picked is put back to edit (Ctrl+R searches as you type)"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("!<command>   "), helpStyle.Render("Run a shell command (!?<command> attaches its output)"))
 	fmt.Fprintln(os.Stderr)
 
 	// Sessions section
@@ -733,11 +1382,27 @@ func showHelp() {
 	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/sessions    "), helpStyle.Render("List saved sessions"))
 	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/save [name] "), helpStyle.Render("Save current session (optional name)"))
 	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/load <id>   "), helpStyle.Render("Load a saved session"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/branch [nm] "), helpStyle.Render("Fork the conversation into a new session"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/rewind [N]  "), helpStyle.Render("Drop the last N turns (default 1)"))
+	fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render("/search <q>  "), helpStyle.Render("Search all sessions (--regex for patterns)"))
 	fmt.Fprintln(os.Stderr)
 
+	// Custom commands section
+	if len(custom) > 0 {
+		fmt.Fprintln(os.Stderr, sectionStyle.Render("🧩 Custom Commands"))
+		for _, c := range custom.Sorted() {
+			description := c.Description
+			if description == "" {
+				description = c.Path
+			}
+			fmt.Fprintf(os.Stderr, "  %s  %s\n", cmdStyle.Render(fmt.Sprintf("%-13s", "/"+c.Name)), helpStyle.Render(description))
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
 	// Tools section
 	fmt.Fprintln(os.Stderr, sectionStyle.Render("🔧 Available Tools"))
-	toolStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#F59E0B")).Bold(true)
+	toolStyle := lipgloss.NewStyle().Foreground(accentAmber).Bold(true)
 	fmt.Fprintf(os.Stderr, "  %s  %s\n", toolStyle.Render("read_file        "), helpStyle.Render("Read file contents"))
 	fmt.Fprintf(os.Stderr, "  %s  %s\n", toolStyle.Render("write_file       "), helpStyle.Render("Write to file (requires confirmation)"))
 	fmt.Fprintf(os.Stderr, "  %s  %s\n", toolStyle.Render("edit_file        "), helpStyle.Render("Edit file (requires confirmation)"))
@@ -759,36 +1424,40 @@ func showHelp() {
 	fmt.Fprintln(os.Stderr)
 }
 
-// generateStreamWithFallback attempts to stream with fallback models on retryable errors
-func generateStreamWithFallback(
-	ctx context.Context,
-	client *api.Client,
-	req *api.GenerateRequest,
-	modelName string,
-) (<-chan api.StreamEvent, string, error) {
-	fallbackModels := GetFallbackModels(modelName)
-
-	for attempt, fallback := range fallbackModels {
-		if attempt > 0 {
-			req.Model = fallback
-			if debug {
-				fmt.Fprintf(os.Stderr, "Falling back to model: %s\n", fallback)
-			}
-		}
+// captureLastCommand returns the last shell command formatted as prompt
+// context: the last !command of the session with its output, or else the
+// command the shell hook recorded, without output. Neither is run again.
+func captureLastCommand(last *tools.UserCommand) (string, error) {
+	if last != nil {
+		return last.ContextText(), nil
+	}
+	recorded, err := shellhook.LoadLast()
+	if err != nil {
+		return "", err
+	}
+	return recorded.ContextText(), nil
+}
 
-		stream, err := client.GenerateStream(ctx, req)
-		if err != nil {
-			if isRetryableError(err) && attempt < len(fallbackModels)-1 {
-				if debug {
-					fmt.Fprintf(os.Stderr, "Model %s failed: %v, trying fallback...\n", req.Model, err)
-				}
-				continue
-			}
-			return nil, req.Model, err
+// runUserCommand runs a !command in the terminal. While it runs, Ctrl+C
+// stops the command instead of the chat, whose handler listens on sigChan.
+func runUserCommand(ctx context.Context, registry *tools.Registry, command, cwd string, sigChan chan os.Signal) (*tools.UserCommand, error) {
+	signal.Stop(sigChan)
+	defer signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	cmdCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-cmdCtx.Done():
 		}
-		return stream, req.Model, nil
-	}
-	return nil, modelName, fmt.Errorf("all fallback models failed")
+	}()
+
+	return registry.RunUserCommand(cmdCtx, command, cwd, os.Stdout)
 }
 
 // processWithToolLoop handles a chat request with automatic tool execution
@@ -800,221 +1469,140 @@ func processWithToolLoop(
 	text string,
 	history *[]api.Content,
 	formatter output.Formatter,
-	toolRegistry *tools.Registry,
+	agentTools *agent.Registry,
 	allowList *confirmation.AllowList,
 ) error {
-	const maxIterations = 10
-
-	// Add user message to history
-	*history = append(*history, api.Content{
-		Role:  "user",
-		Parts: []api.Part{{Text: text}},
-	})
-
-	// Helper to revert on failure
-	historyLenBefore := len(*history)
-	success := false
+	// Time the turn; --debug shows where the time went
+	turn := timing.Start()
 	defer func() {
-		if !success {
-			// Revert all changes to history
-			*history = (*history)[:historyLenBefore-1]
+		turn.StreamEnded()
+		turn.Finish()
+		b := turn.Breakdown()
+		telemetry.RecordTurn(b)
+		if debug {
+			fmt.Fprintln(os.Stderr, lipgloss.NewStyle().Foreground(dimGray).Render("  "+b.String()))
 		}
-	}()
-
-	for i := 0; i < maxIterations; i++ {
-		// Generate user prompt ID
-		userPromptID := fmt.Sprintf("gmn-chat-%d-%d", time.Now().UnixNano(), i)
-
-		// Build request with tools
-		req := &api.GenerateRequest{
-			Model:        modelName,
-			Project:      projectID,
-			UserPromptID: userPromptID,
-			Request: api.InnerRequest{
-				Contents: *history,
-				Config: api.GenerationConfig{
-					Temperature:     1.0,
-					TopP:            0.95,
-					MaxOutputTokens: 8192,
-				},
-				Tools: toolRegistry.GetTools(),
-			},
+		// The REPL cannot tell whether the terminal has focus, so every
+		// long turn is notified about
+		if notifications.Enabled && b.Total >= notifications.After() {
+			_ = notify.Send(notifications.Method, "gmn", "Response ready")
 		}
+	}()
 
-		// Create a context with timeout for this request
-		reqCtx, cancel := context.WithTimeout(ctx, timeout)
-
-		// Start spinner while waiting for response
-		spin := newSpinner("Thinking...")
-		spin.Start()
-
-		// Stream response with fallback
-		stream, usedModel, err := generateStreamWithFallback(reqCtx, client, req, modelName)
-		if err != nil {
+	// The spinner runs from each request until its first event
+	var spin *spinner
+	stopSpinner := func() {
+		if spin != nil {
 			spin.Stop()
-			cancel()
-			return err
-		}
-
-		// Update model name if fallback was used
-		if usedModel != modelName {
-			modelName = usedModel
+			spin = nil
 		}
-
-		var fullResponse strings.Builder
-		var pendingToolCallParts []*api.Part // Store full Parts with thought_signature for Gemini 3 Pro
-		spinnerStopped := false
-
-		for event := range stream {
-			// Stop spinner on first content
-			if !spinnerStopped {
-				spin.Stop()
-				spinnerStopped = true
-			}
-
-			if event.Type == "error" {
-				cancel()
-				return fmt.Errorf(event.Error)
-			}
-
-			// Track token usage
-			if event.Type == "done" && event.Usage != nil {
-				sessionTokens.input += event.Usage.PromptTokenCount
-				sessionTokens.output += event.Usage.CandidatesTokenCount
+	}
+	defer stopSpinner()
+
+	guardApproved := false // The user chose to send a request over the prompt guard limits
+	a, err := agent.New(agent.Options{
+		Client:       client,
+		ProjectID:    projectID,
+		Model:        modelName,
+		Fallback:     GetFallbackModels(modelName),
+		Tools:        agentTools,
+		AllowList:    allowList,
+		Timeout:      timeout,
+		FailureNotes: failureNotes,
+		Temperature:  temperature,
+		Confirm: func(ctx context.Context, req agent.ConfirmRequest) (agent.Decision, error) {
+			outcome, err := promptToolConfirmation(req.Tool, req.Call.Args)
+			switch {
+			case err != nil:
+				return agent.Deny, err
+			case outcome == confirmation.OutcomeProceedAlways:
+				return agent.AllowAlways, nil
+			case outcome == confirmation.OutcomeCancel:
+				return agent.Deny, nil
 			}
-
-			// Handle tool calls
-			if event.Type == "tool_call" && event.ToolCall != nil {
-				// Use ToolCallPart if available (contains thought_signature), otherwise create Part
-				if event.ToolCallPart != nil {
-					pendingToolCallParts = append(pendingToolCallParts, event.ToolCallPart)
-				} else {
-					pendingToolCallParts = append(pendingToolCallParts, &api.Part{FunctionCall: event.ToolCall})
+			return agent.Allow, nil
+		},
+		BeforeRequest: func(ctx context.Context, req *agent.Request, iteration int) error {
+			// Requests after tool calls can grow past the prompt guard limits
+			if iteration > 0 && !guardApproved {
+				b := guard.Estimate(req.Request.Contents, req.Request.SystemInstruction, req.Request.Tools)
+				if promptGuard.Exceeded(req.Model, b) {
+					choice, err := confirmation.PromptLargeRequest(confirmation.LargeRequest{
+						Model:     req.Model,
+						Breakdown: b,
+						Limits:    promptGuard,
+					})
+					if err != nil {
+						return err
+					}
+					if choice != confirmation.LargeSend {
+						return errRequestNotSent
+					}
+					guardApproved = true
 				}
-				// Display tool call notification (OpenCode style)
-				displayToolCall(event.ToolCall)
-				continue
 			}
+			spin = newSpinner("Thinking...")
+			spin.Start()
+			turn.RequestStarted()
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	a.SetHistory(*history)
 
-			// Stream text content
-			if err := formatter.WriteStreamEvent(&event); err != nil {
-				cancel()
-				return err
-			}
-
-			if event.Text != "" {
-				fullResponse.WriteString(event.Text)
-			}
-		}
-
-		// Ensure spinner is stopped
-		if !spinnerStopped {
-			spin.Stop()
+	// Text held back for rendering is shown before tool calls and errors
+	flush := func() {
+		if f, ok := formatter.(interface{ Flush() error }); ok {
+			_ = f.Flush()
 		}
+	}
+	defer flush()
 
-		cancel()
-
-		// If no tool calls, we're done
-		if len(pendingToolCallParts) == 0 {
-			// Add model response to history
-			*history = append(*history, api.Content{
-				Role:  "model",
-				Parts: []api.Part{{Text: fullResponse.String()}},
-			})
-			success = true
-			return nil
+	_, err = a.Send(ctx, text, func(ev agent.Event) {
+		if ev.Type != agent.EventToolResult {
+			turn.FirstEvent()
+			stopSpinner()
 		}
 
-		// Execute tool calls
-		for _, fcPart := range pendingToolCallParts {
-			fc := fcPart.FunctionCall
-			// Generate a response ID (use FunctionCall ID if present, otherwise generate one)
-			responseID := fc.ID
-			if responseID == "" {
-				responseID = fmt.Sprintf("%s-%d", fc.Name, time.Now().UnixNano())
-			}
-
-			tool, ok := toolRegistry.Get(fc.Name)
-			if !ok {
-				// Unknown tool - add error response (preserve thought_signature)
-				*history = append(*history,
-					api.Content{
-						Role:  "model",
-						Parts: []api.Part{*fcPart}, // Use full Part with thought_signature
-					},
-					api.Content{
-						Role: "user",
-						Parts: []api.Part{{FunctionResp: &api.FunctionResp{
-							ID:       responseID,
-							Name:     fc.Name,
-							Response: map[string]interface{}{"error": "unknown tool: " + fc.Name},
-						}}},
-					},
-				)
-				continue
+		renderStart := time.Now()
+		switch ev.Type {
+		case agent.EventStart:
+			if debug && ev.Model != modelName {
+				fmt.Fprintf(os.Stderr, "Falling back to model: %s\n", ev.Model)
 			}
-
-			// Check if confirmation is required
-			if tool.RequiresConfirmation() && !allowList.IsAllowed(fc.Name) {
-				outcome, err := promptToolConfirmation(tool, fc.Args)
-				if err != nil {
-					return fmt.Errorf("confirmation error: %w", err)
-				}
-
-				switch outcome {
-				case confirmation.OutcomeCancel:
-					// User cancelled - add cancelled response (preserve thought_signature)
-					*history = append(*history,
-						api.Content{
-							Role:  "model",
-							Parts: []api.Part{*fcPart}, // Use full Part with thought_signature
-						},
-						api.Content{
-							Role: "user",
-							Parts: []api.Part{{FunctionResp: &api.FunctionResp{
-								ID:       responseID,
-								Name:     fc.Name,
-								Response: map[string]interface{}{"error": "operation cancelled by user"},
-							}}},
-						},
-					)
-					continue
-
-				case confirmation.OutcomeProceedAlways:
-					allowList.Allow(fc.Name)
-				}
+			_ = formatter.WriteStreamEvent(&api.StreamEvent{Type: "start", Model: ev.Model})
+		case agent.EventText:
+			_ = formatter.WriteStreamEvent(&api.StreamEvent{Type: "content", Text: ev.Text})
+		case agent.EventToolCall:
+			flush()
+			displayToolCall(ev.Call)
+		case agent.EventDone:
+			// Track token usage
+			if ev.Usage != nil {
+				sessionTokens.input += ev.Usage.PromptTokenCount
+				sessionTokens.output += ev.Usage.CandidatesTokenCount
 			}
-
-			// Execute the tool
-			result, err := tool.Execute(fc.Args)
-			if err != nil {
-				result = map[string]interface{}{"error": err.Error()}
+			turn.StreamEnded()
+			_ = formatter.WriteStreamEvent(&api.StreamEvent{Type: "done", Usage: ev.Usage})
+		case agent.EventToolResult:
+			turn.AddTools(ev.Duration, 1)
+			if ev.Tool != nil {
+				displayToolResult(ev.Tool, ev.Result)
 			}
-
-			// Display result (OpenCode style)
-			displayToolResult(tool, result)
-
-			// Add tool call and response to history (preserve thought_signature for Gemini 3 Pro)
-			*history = append(*history,
-				api.Content{
-					Role:  "model",
-					Parts: []api.Part{*fcPart}, // Use full Part with thought_signature
-				},
-				api.Content{
-					Role: "user",
-					Parts: []api.Part{{FunctionResp: &api.FunctionResp{
-						ID:       responseID,
-						Name:     fc.Name,
-						Response: result,
-					}}},
-				},
-			)
+			_ = formatter.WriteStreamEvent(&api.StreamEvent{
+				Type:       "tool_result",
+				ToolResult: &api.ToolResult{Name: ev.Call.Name, Result: ev.Result},
+			})
 		}
-
-		// Continue the loop to get the model's response after tool execution
+		turn.AddRender(time.Since(renderStart))
+	})
+	if err != nil {
+		return err
 	}
-
-	return fmt.Errorf("max tool iterations (%d) reached", maxIterations)
+	*history = a.History()
+	return nil
 }
 
 // promptToolConfirmation shows a confirmation prompt for a tool
@@ -1041,8 +1629,23 @@ func promptToolConfirmation(tool tools.BuiltinTool, args map[string]interface{})
 		details.Command = cmd
 	}
 
+	// Get working directory, environment and stdin if available (for shell)
+	if dir, ok := args["cwd"].(string); ok {
+		details.Dir = dir
+	}
+	details.Env, _ = tools.EnvArg(args)
+	if stdin, ok := args["stdin"].(string); ok {
+		details.Stdin = stdin
+	}
+
 	// For edit confirmations, try to get diff content
-	if tool.ConfirmationType() == "edit" {
+	if previewer, ok := tool.(interface {
+		Preview(map[string]interface{}) (string, error)
+	}); ok {
+		if diff, err := previewer.Preview(args); err == nil {
+			details.Diff = diff
+		}
+	} else if tool.ConfirmationType() == "edit" {
 		if getter, ok := tool.(interface {
 			GetOriginalContent(map[string]interface{}) (string, error)
 			GetNewContent(map[string]interface{}) (string, error)
@@ -1070,17 +1673,9 @@ func displayToolCall(fc *api.FunctionCall) {
 	} else if url, ok := fc.Args["url"].(string); ok {
 		argsPreview = url
 	} else if cmd, ok := fc.Args["command"].(string); ok {
-		if len(cmd) > 40 {
-			argsPreview = cmd[:37] + "..."
-		} else {
-			argsPreview = cmd
-		}
+		argsPreview = textwidth.Truncate(cmd, 40)
 	} else if query, ok := fc.Args["query"].(string); ok {
-		if len(query) > 40 {
-			argsPreview = query[:37] + "..."
-		} else {
-			argsPreview = query
-		}
+		argsPreview = textwidth.Truncate(query, 40)
 	}
 
 	header := toolCallStyle.Render("⚡ TOOL")
@@ -1102,31 +1697,45 @@ func displayToolResult(tool tools.BuiltinTool, result map[string]interface{}) {
 
 	// Check for error
 	if errMsg, hasErr := result["error"].(string); hasErr {
-		errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#EF4444")).Bold(true)
+		errorStyle := lipgloss.NewStyle().Foreground(accentRed).Bold(true)
 		fmt.Fprintf(os.Stderr, "   %s %s\n\n", errorStyle.Render("✗"), dimStyle.Render(errMsg))
 		return
 	}
 
-	// Success with brief info
-	var info string
-	if count, ok := result["count"].(int); ok {
-		info = fmt.Sprintf("(%d items)", count)
-	} else if msg, ok := result["message"].(string); ok {
-		if len(msg) > 50 {
-			info = msg[:47] + "..."
-		} else {
-			info = msg
-		}
-	}
+	// Success with the summary the tool provides
+	info := tools.Summary(result)
+	info = textwidth.Truncate(info, 50)
 
 	if info != "" {
-		fmt.Fprintf(os.Stderr, "   %s %s %s\n\n",
+		fmt.Fprintf(os.Stderr, "   %s %s %s\n",
 			successStyle.Render("✓"),
 			tool.DisplayName(),
 			dimStyle.Render(info))
 	} else {
-		fmt.Fprintf(os.Stderr, "   %s %s\n\n",
+		fmt.Fprintf(os.Stderr, "   %s %s\n",
 			successStyle.Render("✓"),
 			tool.DisplayName())
 	}
+
+	// Print the task list so long plans can be followed
+	if todos, ok := tool.(*tools.WriteTodosTool); ok {
+		displayTodos(todos.Todos())
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
+// displayTodos prints the write_todos task list as a checklist
+func displayTodos(todos []tools.Todo) {
+	for _, todo := range todos {
+		style := lipgloss.NewStyle().Foreground(dimGray)
+		switch todo.Status {
+		case tools.TodoCompleted:
+			style = lipgloss.NewStyle().Foreground(accentGreen)
+		case tools.TodoInProgress:
+			style = lipgloss.NewStyle().Foreground(accentPurple).Bold(true)
+		case tools.TodoCancelled:
+			style = lipgloss.NewStyle().Foreground(mutedGray).Strikethrough(true)
+		}
+		fmt.Fprintf(os.Stderr, "     %s %s\n", style.Render(tools.TodoMark(todo.Status)), style.Render(todo.Description))
+	}
 }
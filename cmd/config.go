@@ -0,0 +1,548 @@
+// Config command for gmn
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/auth"
+	"github.com/linkalls/gmn/internal/config"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/locale"
+	"github.com/linkalls/gmn/internal/mcp"
+	"github.com/linkalls/gmn/internal/termcolor"
+	"github.com/linkalls/gmn/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage settings and profiles",
+	// Only selects the profile: these commands must work even when the
+	// active profile is unknown, to fix it
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		config.SetProfile(profileName)
+		applyNoColor()
+	},
+}
+
+var configUseCmd = &cobra.Command{
+	Use:   "use [profile]",
+	Short: "Choose the settings profile (lists profiles without an argument)",
+	Long: `Choose the profile applied over the settings from now on. Profiles are
+defined under "profiles" in settings.json and can set anything settings can,
+such as the model, the account, tool policy and MCP servers:
+
+  "profiles": {
+    "work": {
+      "model": { "name": "gemini-2.5-pro" },
+      "security": { "auth": { "credentialsFile": "~/.gemini/oauth_creds.work.json", "project": "acme-ai" } }
+    }
+  }
+
+--profile and $GMN_PROFILE override the choice for a single run.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigUse,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Show the effective value of a setting",
+	Long: `Show the effective value of a setting: the settings files merged in
+precedence order, with the active profile applied. Keys follow the JSON names
+in settings.json, joined with dots, such as ui.theme or mcpServers.github.url.`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runConfigGet,
+	SilenceUsage: true,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting in a settings file",
+	Long: `Change a setting in the user or project settings file. Strings need no
+quotes and lists of strings may be comma-separated; other values are JSON:
+
+  gmn config set ui.theme light
+  gmn config set tools.disabled "web_fetch,google_web_search"
+  gmn config set mcp.toolCacheTTL 3600
+  gmn config set mcpServers.docs '{"url": "https://example.com/mcp"}'
+
+The value is checked before the file is written.`,
+	Args:         cobra.ExactArgs(2),
+	RunE:         runConfigSet,
+	SilenceUsage: true,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the effective settings",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigList,
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Show the settings files in precedence order",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigPath,
+}
+
+var configDoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check settings, credentials, MCP servers and directories",
+	Long: `Check that the settings load and are valid, that credentials are saved,
+that MCP server definitions can be started, and that the sessions, cache and
+history directories are writable. Nothing is sent over the network.`,
+	Args:         cobra.NoArgs,
+	RunE:         runConfigDoctor,
+	SilenceUsage: true,
+}
+
+var configTrustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Trust the current project's settings to start MCP servers and allow tools",
+	Long: `Trust the settings of the current project (its .gmn and .gemini
+directories), so that the MCP servers they define are started and the tool
+settings that let tools do more without asking (tools.allowed,
+tools.shell.allowedCommands and env, tools.sandbox and others) apply.
+Settings that come with a repository can run any command, so they are left
+out until the project is trusted; gmn chat asks once per project.
+
+Project settings cannot mark MCP servers as trusted: their tools still ask
+before running.`,
+	Args:         cobra.NoArgs,
+	RunE:         runConfigTrust,
+	SilenceUsage: true,
+}
+
+var (
+	configUseClear    bool
+	configScope       string
+	configListJSON    bool
+	configTrustRevoke bool
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configUseCmd, configGetCmd, configSetCmd, configListCmd, configPathCmd, configDoctorCmd, configTrustCmd)
+	configUseCmd.Flags().BoolVar(&configUseClear, "clear", false, "Stop using a profile")
+	for _, c := range []*cobra.Command{configSetCmd, configPathCmd} {
+		c.Flags().StringVarP(&configScope, "scope", "s", "", "Settings file: user (~/.gemini) or project (the project's .gmn or .gemini)")
+	}
+	configListCmd.Flags().BoolVar(&configListJSON, "json", false, "Print the settings as JSON")
+	configTrustCmd.Flags().BoolVar(&configTrustRevoke, "revoke", false, "Stop trusting the project")
+}
+
+func runConfigUse(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadBase()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if len(args) == 0 && !configUseClear {
+		names := cfg.ProfileNames()
+		if len(names) == 0 {
+			fmt.Println("No profiles defined.")
+			fmt.Println(`Add them under "profiles" in settings.json (see 'gmn config use --help')`)
+			return nil
+		}
+		active := config.ActiveProfile(cfg)
+		for _, name := range names {
+			marker := "  "
+			if name == active {
+				marker = "* "
+			}
+			fmt.Println(marker + name)
+		}
+		return nil
+	}
+
+	var name string
+	if !configUseClear {
+		name = args[0]
+		if err := config.CheckProfile(cfg, name); err != nil {
+			return err
+		}
+	}
+
+	path, err := config.SettingsPath(config.ScopeUser)
+	if err != nil {
+		return err
+	}
+	err = config.UpdateSettings(path, func(settings *config.Object) error {
+		if name == "" {
+			settings.Delete("profile")
+			return nil
+		}
+		return settings.SetValue("profile", name)
+	})
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		fmt.Printf("Stopped using a profile (%s)\n", path)
+	} else {
+		fmt.Printf("Using profile '%s' (%s)\n", name, path)
+	}
+	if env := os.Getenv(config.EnvProfile); env != "" && env != name {
+		fmt.Fprintf(os.Stderr, "Note: $%s=%s overrides this choice in this shell\n", config.EnvProfile, env)
+	}
+	return nil
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	value, err := config.GetKey(cfg, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(formatSetting(value, true))
+	return nil
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	key := args[0]
+	value, err := config.ParseValue(key, args[1])
+	if err != nil {
+		return err
+	}
+	scope := configScope
+	if scope == "" {
+		scope = config.ScopeUser
+	}
+	path, err := config.SettingsPath(scope)
+	if err != nil {
+		return err
+	}
+
+	// Refuse values the settings would reject when loaded, such as an
+	// unknown theme or an invalid MCP server
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	before := settingsProblems(cfg)
+	if err := json.Unmarshal(nestSetting(key, value), cfg); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	for _, problem := range settingsProblems(cfg) {
+		if !slices.Contains(before, problem) {
+			return errors.New(problem)
+		}
+	}
+
+	err = config.UpdateSettings(path, func(settings *config.Object) error {
+		return config.SetKey(settings, key, value)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Set %s = %s in %s\n", key, formatSetting(value, false), path)
+
+	// Other settings files or the profile may still override this one
+	if cfg, err := config.Load(); err == nil {
+		if effective, err := config.GetKey(cfg, key); err == nil && !jsonEqual(effective, value) {
+			fmt.Printf("Note: the effective value is %s; see 'gmn config path' for the files that override it\n", formatSetting(effective, false))
+		}
+	}
+	return nil
+}
+
+func runConfigList(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if configListJSON {
+		data, err := json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	settings, err := config.Flatten(cfg)
+	if err != nil {
+		return err
+	}
+	for _, setting := range settings {
+		fmt.Printf("%s = %s\n", setting[0], setting[1])
+	}
+	return nil
+}
+
+func runConfigPath(cmd *cobra.Command, args []string) error {
+	if configScope != "" {
+		path, err := config.SettingsPath(configScope)
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+	}
+
+	geminiPath, err := config.GeminiDir()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Settings files, later ones override earlier ones:")
+	for i, path := range config.SettingsPaths(geminiPath) {
+		scope := config.ScopeProject
+		if i == 0 {
+			scope = config.ScopeUser
+		}
+		state := ""
+		if _, err := os.Stat(path); err != nil {
+			state = " (not found)"
+		}
+		fmt.Printf("  %-8s %s%s\n", scope, path, state)
+	}
+	if cfg, err := config.LoadBase(); err == nil {
+		if name := config.ActiveProfile(cfg); name != "" {
+			fmt.Printf("Profile: %s\n", name)
+		}
+	}
+	return nil
+}
+
+func runConfigTrust(cmd *cobra.Command, args []string) error {
+	dir, err := config.ProjectDir()
+	if err != nil {
+		return err
+	}
+	if err := config.SetProjectTrust(dir, !configTrustRevoke); err != nil {
+		return err
+	}
+	if configTrustRevoke {
+		fmt.Printf("No longer trusting the settings of %s\n", dir)
+		return nil
+	}
+	fmt.Printf("Trusting the settings of %s\n", dir)
+	if cfg, err := config.Load(); err == nil {
+		for _, name := range cfg.ProjectServers() {
+			fmt.Printf("  MCP server %s: %s\n", name, mcpServerTarget(cfg.MCPServers[name]))
+		}
+	}
+	return nil
+}
+
+func runConfigDoctor(cmd *cobra.Command, args []string) error {
+	okStyle := lipgloss.NewStyle().Foreground(accentGreen)
+	failStyle := lipgloss.NewStyle().Foreground(accentRed)
+	problems := 0
+	check := func(err error, format string, a ...interface{}) {
+		msg := fmt.Sprintf(format, a...)
+		if err != nil {
+			problems++
+			fmt.Println(failStyle.Render("  ✗ "+msg+": ") + err.Error())
+			return
+		}
+		fmt.Println(okStyle.Render("  ✓ ") + msg)
+	}
+
+	fmt.Println("Settings")
+	cfg, err := config.Load()
+	if errors.Is(err, config.ErrUnknownProfile) {
+		// Check the settings without the profile
+		check(err, "profile")
+		if cfg, err = config.LoadBase(); err == nil {
+			cfg.Profile = ""
+		}
+	}
+	if err != nil {
+		check(err, "settings")
+		cfg = config.DefaultConfig()
+	} else {
+		check(nil, "settings loaded")
+		if cfg.Profile != "" {
+			check(nil, "profile %s", cfg.Profile)
+		}
+	}
+	for _, problem := range settingsProblems(cfg) {
+		key, msg, _ := strings.Cut(problem, ": ")
+		check(errors.New(msg), "%s", key)
+	}
+
+	fmt.Println("Credentials")
+	if authMgr, err := auth.NewManager(); err != nil {
+		check(err, "credentials")
+	} else if creds, err := authMgr.LoadCredentials(); err != nil {
+		check(err, "credentials")
+	} else if creds.IsExpired() && creds.RefreshToken == "" {
+		check(errors.New("expired and cannot be refreshed; run 'gmn' to log in again"), "credentials")
+	} else {
+		check(nil, "credentials found")
+	}
+
+	fmt.Println("MCP servers")
+	names := make([]string, 0, len(cfg.MCPServers))
+	for name := range cfg.MCPServers {
+		if cfg.MCPServerEnabled(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		fmt.Println("  none enabled")
+	}
+	for _, name := range names {
+		// Invalid definitions are reported with the settings
+		server := cfg.MCPServers[name]
+		if mcp.ValidateServer(server) != nil {
+			continue
+		}
+		if err := mcp.CheckCommand(server); err != nil {
+			check(err, "%s", name)
+			continue
+		}
+		if mcp.UsesOAuth(name, server) {
+			if status := oauthStatus(name, server.URL); status != "authorized" {
+				check(errors.New(status), "%s", name)
+				continue
+			}
+		}
+		check(nil, "%s", name)
+	}
+
+	fmt.Println("Directories")
+	dirs := []struct {
+		name string
+		path func(*config.Config) (string, error)
+	}{
+		{"sessions", config.SessionsDir},
+		{"cache", config.CacheDir},
+		{"history", func(cfg *config.Config) (string, error) {
+			file, err := config.HistoryFile(cfg)
+			return filepath.Dir(file), err
+		}},
+	}
+	for _, dir := range dirs {
+		path, err := dir.path(cfg)
+		if err == nil {
+			err = checkWritable(path)
+		}
+		check(err, "%s %s", dir.name, path)
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s) found", problems)
+	}
+	fmt.Println("No problems found")
+	return nil
+}
+
+// settingsProblems lists the settings in cfg that gmn would warn about or
+// reject when loading them, as "key: reason"
+func settingsProblems(cfg *config.Config) []string {
+	var problems []string
+	add := func(key string, err error) {
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	add("ui.colorMode", termcolor.Apply(cfg.UI.ColorMode))
+	add("ui.theme", termcolor.ValidateTheme(cfg.UI.Theme))
+	add("ui.glyphs", glyphs.Apply(cfg.UI.Glyphs))
+	add("ui.locale", locale.Apply(cfg.UI.Locale))
+
+	cwd, _ := os.Getwd()
+	registry := tools.NewRegistry(cwd)
+	problems = append(problems, errorLines(registry.ApplySettings(cfg))...)
+	add("tools.disabled", registry.SetDisabled(cfg.Tools.Disabled))
+
+	names := make([]string, 0, len(cfg.MCPServers))
+	for name := range cfg.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add("mcpServers."+name, mcp.ValidateServer(cfg.MCPServers[name]))
+	}
+	return problems
+}
+
+// errorLines returns the messages of the errors joined in err, such as
+// the problems tools.Registry.ApplySettings reports
+func errorLines(err error) []string {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []string{err.Error()}
+	}
+	var lines []string
+	for _, err := range joined.Unwrap() {
+		lines = append(lines, err.Error())
+	}
+	return lines
+}
+
+// checkWritable creates dir if needed and checks that files can be
+// created in it
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".gmn-doctor-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// nestSetting wraps value in the objects named by a dotted key
+func nestSetting(key string, value json.RawMessage) []byte {
+	parts := strings.Split(key, ".")
+	data := []byte(value)
+	for i := len(parts) - 1; i >= 0; i-- {
+		name, _ := json.Marshal(parts[i])
+		data = []byte(fmt.Sprintf("{%s:%s}", name, data))
+	}
+	return data
+}
+
+// formatSetting formats a setting's JSON value for display: strings bare,
+// and objects indented if indent is set
+func formatSetting(value json.RawMessage, indent bool) string {
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	if indent && bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")) {
+		var buf bytes.Buffer
+		if json.Indent(&buf, value, "", "  ") == nil {
+			return buf.String()
+		}
+	}
+	var buf bytes.Buffer
+	if json.Compact(&buf, value) == nil {
+		return buf.String()
+	}
+	return string(value)
+}
+
+// jsonEqual reports whether two JSON values are equal
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,114 @@
+// Examples command for gmn
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// recipe is a task-oriented usage example
+type recipe struct {
+	Topic    string
+	Title    string
+	Commands []string
+}
+
+var recipes = []recipe{
+	{
+		Topic: "review",
+		Title: "Code review",
+		Commands: []string{
+			`gmn "Review this code for bugs and readability" -f main.go`,
+			`git diff | gmn "Review this diff. Point out bugs, missing tests and risky changes"`,
+			`gmn chat -p "Review the error handling in this package" -f internal/api/client.go`,
+		},
+	},
+	{
+		Topic: "commit",
+		Title: "Commit messages",
+		Commands: []string{
+			`git diff --staged | gmn "Write a concise commit message (subject + body) for this diff"`,
+			`git commit -m "$(git diff --staged | gmn 'One-line commit subject for this diff, no quotes')"`,
+		},
+	},
+	{
+		Topic: "pipe",
+		Title: "Pipes and scripting",
+		Commands: []string{
+			`cat error.log | gmn "What is the root cause of this error?"`,
+			`curl -s https://example.com/api | gmn "Summarize this JSON" -o json | jq -r .response`,
+			`gmn "List 5 project name ideas" -o stream-json`,
+			`ls -la | gmn "Which of these files look like build artifacts?"`,
+		},
+	},
+	{
+		Topic: "chat",
+		Title: "Interactive sessions",
+		Commands: []string{
+			`gmn chat -r last                       # resume where you left off`,
+			`gmn chat -m gemini-3-pro-preview --yolo # no confirmations (careful!)`,
+			`gmn sessions list --json | jq -r '.[].id'`,
+			`gmn sessions prune --older-than 30d`,
+		},
+	},
+	{
+		Topic: "debug",
+		Title: "Debugging a failed command",
+		Commands: []string{
+			`eval "$(gmn shell-init bash)"   # once, in ~/.bashrc`,
+			`make test                        # fails...`,
+			`gmn chat                         # then type /last-cmd and ask why`,
+		},
+	},
+}
+
+var examplesCmd = &cobra.Command{
+	Use:   "examples [topic]",
+	Short: "Show task-oriented usage recipes",
+	Long:  "Show task-oriented usage recipes. Topics: " + recipeTopics() + ".",
+	Args:  cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var topics []string
+		for _, r := range recipes {
+			topics = append(topics, r.Topic)
+		}
+		return topics, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: runExamples,
+}
+
+func init() {
+	rootCmd.AddCommand(examplesCmd)
+}
+
+func recipeTopics() string {
+	var topics []string
+	for _, r := range recipes {
+		topics = append(topics, r.Topic)
+	}
+	return strings.Join(topics, ", ")
+}
+
+func runExamples(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+	found := false
+	for _, r := range recipes {
+		if len(args) > 0 && r.Topic != args[0] {
+			continue
+		}
+		found = true
+		fmt.Fprintf(out, "# %s (%s)\n", r.Title, r.Topic)
+		for _, c := range r.Commands {
+			fmt.Fprintf(out, "  %s\n", c)
+		}
+		fmt.Fprintln(out)
+	}
+	if !found {
+		return fmt.Errorf("unknown topic: %s (available: %s)", args[0], recipeTopics())
+	}
+	return nil
+}
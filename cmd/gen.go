@@ -0,0 +1,64 @@
+// Documentation generation command for gmn
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+var genDocsDir string
+
+var genCmd = &cobra.Command{
+	Use:    "gen",
+	Short:  "Generate build artifacts",
+	Hidden: true,
+}
+
+var genDocsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate man pages and markdown reference from the command tree",
+	Args:  cobra.NoArgs,
+	RunE:  runGenDocs,
+}
+
+func init() {
+	rootCmd.AddCommand(genCmd)
+	genCmd.AddCommand(genDocsCmd)
+
+	genDocsCmd.Flags().StringVar(&genDocsDir, "dir", "build/docs", "Output directory")
+}
+
+func runGenDocs(cmd *cobra.Command, args []string) error {
+	manDir := filepath.Join(genDocsDir, "man")
+	mdDir := filepath.Join(genDocsDir, "md")
+	for _, dir := range []string{manDir, mdDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	// Keep the generated files reproducible between builds
+	rootCmd.DisableAutoGenTag = true
+
+	header := &doc.GenManHeader{
+		Title:   "GMN",
+		Section: "1",
+		Source:  "gmn " + version,
+		Manual:  "gmn manual",
+	}
+	if err := doc.GenManTree(rootCmd, header, manDir); err != nil {
+		return fmt.Errorf("failed to generate man pages: %w", err)
+	}
+	if err := doc.GenMarkdownTree(rootCmd, mdDir); err != nil {
+		return fmt.Errorf("failed to generate markdown: %w", err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Generated man pages in %s and markdown in %s\n", manDir, mdDir)
+	return nil
+}
@@ -0,0 +1,202 @@
+// Package cmd provides the CLI commands for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/fence"
+	"github.com/linkalls/gmn/internal/output"
+	"github.com/linkalls/gmn/internal/textwidth"
+	"github.com/linkalls/gmn/internal/tui"
+	"github.com/muesli/termenv"
+)
+
+// markdownFormatter renders the streamed response of the line-based chat
+// as markdown a block at a time. Each paragraph, list or code block is
+// shown as it streams in and replaced with its rendering once it is
+// complete; a block taller than the screen is held back until then, since
+// text scrolled off the screen cannot be replaced.
+type markdownFormatter struct {
+	output.Formatter // Writes everything but the response text
+	out              *os.File
+	renderer         *tui.MarkdownRenderer
+	pending          string // Text of the blocks not rendered yet
+	shown            string // The part of pending shown as written
+	held             bool   // pending no longer fits on the screen
+	blocks           int    // Code blocks rendered in this response
+}
+
+// newMarkdownFormatter wraps the text formatter of the line-based chat so
+// that responses are rendered as markdown. Without a color terminal on out
+// the formatter is returned as it is.
+func newMarkdownFormatter(f output.Formatter, out *os.File) output.Formatter {
+	if !term.IsTerminal(out.Fd()) || lipgloss.ColorProfile() == termenv.Ascii {
+		return f
+	}
+	return &markdownFormatter{
+		Formatter: f,
+		out:       out,
+		renderer:  tui.NewMarkdownRenderer(80),
+	}
+}
+
+// WriteStreamEvent shows response text as it streams in and renders each
+// block once it is complete
+func (m *markdownFormatter) WriteStreamEvent(event *api.StreamEvent) error {
+	switch {
+	case event.Type == "start":
+		m.blocks = 0
+	case event.Type == "done":
+		return m.Flush()
+	case event.Text != "":
+		return m.write(event.Text)
+	}
+	return m.Formatter.WriteStreamEvent(event)
+}
+
+// Flush renders the text not rendered yet, for the end of a response or
+// before tool calls are shown
+func (m *markdownFormatter) Flush() error {
+	text := m.pending
+	m.erase()
+	m.pending, m.held = "", false
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	_, err := fmt.Fprint(m.out, m.render(text)+"\n")
+	return err
+}
+
+// write adds streamed text, rendering the blocks it completes
+func (m *markdownFormatter) write(text string) error {
+	m.pending += text
+	for {
+		block, ok := completeBlock(m.pending)
+		if !ok {
+			break
+		}
+		m.erase()
+		m.pending, m.held = m.pending[len(block):], false
+		if strings.TrimSpace(block) != "" {
+			if _, err := fmt.Fprint(m.out, m.render(block)+"\n\n"); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Show the rest as written while it fits on the screen
+	more := m.pending[len(m.shown):]
+	if m.held || more == "" {
+		return nil
+	}
+	_, height := m.size()
+	if rowsUp(m.shown+more, m.width())+1 >= height {
+		m.held = true
+		return nil
+	}
+	m.shown += more
+	_, err := fmt.Fprint(m.out, expandTabs(more))
+	return err
+}
+
+// render renders a block at the terminal's width, numbering its code
+// blocks after those already rendered
+func (m *markdownFormatter) render(block string) string {
+	block = strings.Trim(block, "\n")
+	m.renderer.SetWidth(m.width())
+	out := m.renderer.RenderFrom(block, m.blocks+1)
+	m.blocks += len(fence.Blocks(block))
+	return out
+}
+
+// erase removes the text shown as written from the screen
+func (m *markdownFormatter) erase() {
+	if m.shown == "" {
+		return
+	}
+	seq := "\r"
+	if up := rowsUp(m.shown, m.width()); up > 0 {
+		seq += fmt.Sprintf("\x1b[%dA", up)
+	}
+	fmt.Fprint(m.out, seq+"\x1b[J")
+	m.shown = ""
+}
+
+// size returns the terminal's size, 80x24 if it cannot be told
+func (m *markdownFormatter) size() (int, int) {
+	width, height, err := term.GetSize(m.out.Fd())
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// width returns the terminal's width
+func (m *markdownFormatter) width() int {
+	width, _ := m.size()
+	return width
+}
+
+// completeBlock returns the first complete markdown block of text with
+// the blank lines after it: a paragraph, list or table ended by a blank
+// line or a code fence, or a closed code block. ok is false while the
+// first block is still streaming.
+func completeBlock(text string) (block string, ok bool) {
+	pos := 0
+	ticks, content := "", false // ticks opened the fence being read
+	for {
+		end := strings.IndexByte(text[pos:], '\n')
+		if end < 0 {
+			return "", false
+		}
+		line := text[pos : pos+end]
+		next := pos + end + 1
+		_, open, _, isFence := fence.OpenFence(line)
+		switch {
+		case ticks != "":
+			if fence.CloseFence(line, ticks) {
+				return text[:next], true
+			}
+		case isFence:
+			if content {
+				return text[:pos], true
+			}
+			ticks = open
+		case strings.TrimSpace(line) == "":
+			if content {
+				return text[:next], true
+			}
+		}
+		if strings.TrimSpace(line) != "" {
+			content = true
+		}
+		pos = next
+	}
+}
+
+// rowsUp returns how many rows above the cursor text starts once written
+// at the start of a row of a terminal width columns wide
+func rowsUp(text string, width int) int {
+	lines := strings.Split(expandTabs(text), "\n")
+	up := 0
+	for i, line := range lines {
+		rows := max(1, (textwidth.Width(line)+width-1)/width)
+		if i == len(lines)-1 {
+			rows-- // The cursor is on the last row
+		}
+		up += rows
+	}
+	return up
+}
+
+// expandTabs replaces tabs, whose width depends on the column, with spaces
+func expandTabs(text string) string {
+	return strings.ReplaceAll(text, "\t", "    ")
+}
@@ -6,13 +6,23 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/spf13/cobra"
+	"github.com/charmbracelet/x/term"
+	"github.com/linkalls/gmn/internal/api"
 	"github.com/linkalls/gmn/internal/config"
+	"github.com/linkalls/gmn/internal/confirmation"
 	"github.com/linkalls/gmn/internal/mcp"
+	"github.com/linkalls/gmn/internal/tools"
+	"github.com/spf13/cobra"
 )
 
 var mcpCmd = &cobra.Command{
@@ -33,10 +43,109 @@ var mcpCallCmd = &cobra.Command{
 	RunE:  runMCPCall,
 }
 
+var mcpAddCmd = &cobra.Command{
+	Use:   "add <name> <command|url> [args...]",
+	Short: "Add an MCP server to settings",
+	Long: `Add an MCP server to settings.json.
+
+For stdio servers, everything after the name is the command line; put it
+after -- if it has flags of its own:
+
+  gmn mcp add fs -- npx -y @modelcontextprotocol/server-filesystem .
+  gmn mcp add --transport http docs https://example.com/mcp -H "Authorization: Bearer $TOKEN"`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runMCPAdd,
+}
+
+var mcpRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove an MCP server from settings",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMCPRemove,
+}
+
+var mcpEnableCmd = &cobra.Command{
+	Use:   "enable <name>",
+	Short: "Enable a disabled MCP server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setMCPServerEnabled(args[0], true)
+	},
+}
+
+var mcpDisableCmd = &cobra.Command{
+	Use:   "disable <name>",
+	Short: "Disable an MCP server without removing it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setMCPServerEnabled(args[0], false)
+	},
+}
+
+var mcpTestCmd = &cobra.Command{
+	Use:   "test <name>",
+	Short: "Start an MCP server, list its tools and report latency",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMCPTest,
+}
+
+var mcpAuthCmd = &cobra.Command{
+	Use:   "auth <name>",
+	Short: "Authorize gmn with an MCP server that uses OAuth",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMCPAuth,
+}
+
+var mcpLogoutCmd = &cobra.Command{
+	Use:   "logout <name>",
+	Short: "Delete the stored OAuth token of an MCP server",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMCPLogout,
+}
+
+var (
+	mcpScope        string
+	mcpTransport    string
+	mcpEnv          []string
+	mcpHeaders      []string
+	mcpTimeout      int
+	mcpTrust        bool
+	mcpIncludeTools []string
+	mcpExcludeTools []string
+	mcpForce        bool
+	mcpOAuth        bool
+	mcpClientID     string
+	mcpScopes       []string
+	mcpRefresh      bool
+)
+
 func init() {
 	rootCmd.AddCommand(mcpCmd)
 	mcpCmd.AddCommand(mcpListCmd)
 	mcpCmd.AddCommand(mcpCallCmd)
+	mcpCmd.AddCommand(mcpAddCmd)
+	mcpCmd.AddCommand(mcpRemoveCmd)
+	mcpCmd.AddCommand(mcpEnableCmd)
+	mcpCmd.AddCommand(mcpDisableCmd)
+	mcpCmd.AddCommand(mcpTestCmd)
+	mcpCmd.AddCommand(mcpAuthCmd)
+	mcpCmd.AddCommand(mcpLogoutCmd)
+
+	for _, c := range []*cobra.Command{mcpAddCmd, mcpRemoveCmd, mcpEnableCmd, mcpDisableCmd} {
+		c.Flags().StringVarP(&mcpScope, "scope", "s", config.ScopeUser, "Settings file to edit: user (~/.gemini) or project (the project's .gmn or .gemini)")
+	}
+	mcpListCmd.Flags().BoolVar(&mcpRefresh, "refresh", false, "Start every server instead of showing cached tools")
+	mcpAddCmd.Flags().StringVarP(&mcpTransport, "transport", "t", "", "Transport: stdio, sse or http (default: http for URLs, otherwise stdio)")
+	mcpAddCmd.Flags().StringArrayVarP(&mcpEnv, "env", "e", nil, "Environment variable for the server (KEY=VALUE, repeatable)")
+	mcpAddCmd.Flags().StringArrayVarP(&mcpHeaders, "header", "H", nil, "HTTP header (\"Name: value\", repeatable)")
+	mcpAddCmd.Flags().IntVar(&mcpTimeout, "timeout", 0, "Request timeout in milliseconds")
+	mcpAddCmd.Flags().BoolVar(&mcpTrust, "trust", false, "Run the server's tools without confirmation")
+	mcpAddCmd.Flags().StringSliceVar(&mcpIncludeTools, "include-tools", nil, "Only expose these tools (comma-separated)")
+	mcpAddCmd.Flags().StringSliceVar(&mcpExcludeTools, "exclude-tools", nil, "Hide these tools (comma-separated)")
+	mcpAddCmd.Flags().BoolVarP(&mcpForce, "force", "f", false, "Replace an existing server with the same name")
+	mcpAddCmd.Flags().BoolVar(&mcpOAuth, "oauth", false, "Authorize with OAuth (run 'gmn mcp auth <name>' afterwards)")
+	mcpAddCmd.Flags().StringVar(&mcpClientID, "client-id", "", "OAuth client ID (default: register dynamically)")
+	mcpAddCmd.Flags().StringSliceVar(&mcpScopes, "scopes", nil, "OAuth scopes (comma-separated)")
 }
 
 func runMCPList(cmd *cobra.Command, args []string) error {
@@ -47,47 +156,104 @@ func runMCPList(cmd *cobra.Command, args []string) error {
 
 	if len(cfg.MCPServers) == 0 {
 		fmt.Println("No MCP servers configured.")
-		fmt.Println("Add servers to ~/.gemini/settings.json under 'mcpServers'")
+		fmt.Println("Add one with 'gmn mcp add <name> <command>'")
 		return nil
 	}
 
 	ctx := context.Background()
+	cache := mcpToolCache(cfg)
+	trusted := projectTrusted()
 
-	for name, serverCfg := range cfg.MCPServers {
-		fmt.Printf("=== %s ===\n", name)
+	names := make([]string, 0, len(cfg.MCPServers))
+	for name := range cfg.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-		if serverCfg.Command == "" {
-			fmt.Printf("  (HTTP/SSE transport - not yet supported)\n\n")
-			continue
-		}
+	// Servers are started in parallel and printed in order
+	outputs := make([]strings.Builder, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listMCPServer(ctx, &outputs[i], cfg, name, cache, trusted)
+		}()
+	}
+	wg.Wait()
+	for i := range outputs {
+		fmt.Print(outputs[i].String())
+	}
+
+	return nil
+}
 
-		client, err := mcp.NewClient(serverCfg.Command, serverCfg.Args, serverCfg.Env)
+// listMCPServer writes a server's entry of gmn mcp list to out, starting
+// the server unless its tools are cached or it comes from the settings of
+// a project that is not trusted
+func listMCPServer(ctx context.Context, out *strings.Builder, cfg *config.Config, name string, cache *mcp.ToolCache, trusted bool) {
+	serverCfg := cfg.MCPServers[name]
+	fmt.Fprintf(out, "=== %s ===\n", name)
+
+	if !cfg.MCPServerEnabled(name) {
+		fmt.Fprintf(out, "  (disabled - run 'gmn mcp enable %s' to use it)\n\n", name)
+		return
+	}
+
+	if serverCfg.Project && !trusted {
+		fmt.Fprintf(out, "  (from the project settings, which are not trusted - run 'gmn config trust' to use it)\n\n")
+		return
+	}
+
+	if mcp.UsesOAuth(name, serverCfg) {
+		fmt.Fprintf(out, "  OAuth: %s\n", oauthStatus(name, serverCfg.URL))
+	}
+
+	var info *mcp.CachedServer
+	if cached, ok := cache.Lookup(name, serverCfg); ok && !mcpRefresh {
+		info = cached
+	} else {
+		client, err := mcp.NewClientFromConfig(name, serverCfg)
 		if err != nil {
-			fmt.Printf("  Error: %v\n\n", err)
-			continue
+			fmt.Fprintf(out, "  Error: %v\n\n", err)
+			return
 		}
+		client.Roots = workspaceRoots()
 
 		if err := client.Initialize(ctx); err != nil {
-			fmt.Printf("  Error initializing: %v\n\n", err)
+			fmt.Fprintf(out, "  Error initializing: %v\n\n", err)
 			client.Close()
-			continue
-		}
-
-		fmt.Printf("  Server: %s %s\n", client.ServerName, client.ServerVersion)
-		fmt.Printf("  Tools:\n")
-		for _, tool := range client.Tools {
-			fmt.Printf("    - %s", tool.Name)
-			if tool.Description != "" {
-				fmt.Printf(": %s", tool.Description)
-			}
-			fmt.Println()
+			return
 		}
-		fmt.Println()
-
 		client.Close()
+		if err := cache.Store(name, serverCfg, client); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not cache the tools of MCP server '%s': %v\n", name, err)
+		}
+		info = &mcp.CachedServer{ServerName: client.ServerName, ServerVersion: client.ServerVersion, Tools: client.Tools}
 	}
 
-	return nil
+	fmt.Fprintf(out, "  Server: %s %s", info.ServerName, info.ServerVersion)
+	if !info.Updated.IsZero() {
+		fmt.Fprintf(out, " (cached %s ago; --refresh to start it)", time.Since(info.Updated).Round(time.Second))
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "  Tools:\n")
+	for _, tool := range info.Tools {
+		fmt.Fprintf(out, "    - %s", tool.Name)
+		switch {
+		case !tools.MCPToolEnabled(serverCfg, tool.Name):
+			fmt.Fprint(out, " [excluded]")
+		case tools.MCPToolPolicy(serverCfg, tool.Name) == tools.MCPPolicyTrusted:
+			fmt.Fprint(out, " [trusted]")
+		case tools.MCPToolPolicy(serverCfg, tool.Name) == tools.MCPPolicyConfirm:
+			fmt.Fprint(out, " [always confirm]")
+		}
+		if tool.Description != "" {
+			fmt.Fprintf(out, ": %s", tool.Description)
+		}
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintln(out)
 }
 
 func runMCPCall(cmd *cobra.Command, args []string) error {
@@ -95,18 +261,9 @@ func runMCPCall(cmd *cobra.Command, args []string) error {
 	toolName := args[1]
 
 	// Parse tool arguments (key=value pairs)
-	toolArgs := make(map[string]interface{})
-	for _, arg := range args[2:] {
-		parts := strings.SplitN(arg, "=", 2)
-		if len(parts) == 2 {
-			// Try to parse as JSON first
-			var val interface{}
-			if err := json.Unmarshal([]byte(parts[1]), &val); err != nil {
-				// Fall back to string
-				val = parts[1]
-			}
-			toolArgs[parts[0]] = val
-		}
+	toolArgs, err := tools.ParseKeyValueArgs(args[2:])
+	if err != nil {
+		return err
 	}
 
 	cfg, err := config.Load()
@@ -119,17 +276,15 @@ func runMCPCall(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("MCP server '%s' not found in config", serverName)
 	}
 
-	if serverCfg.Command == "" {
-		return fmt.Errorf("HTTP/SSE transport not yet supported")
-	}
-
 	ctx := context.Background()
 
-	client, err := mcp.NewClient(serverCfg.Command, serverCfg.Args, serverCfg.Env)
+	client, err := mcp.NewClientFromConfig(serverName, serverCfg)
 	if err != nil {
 		return fmt.Errorf("failed to start MCP server: %w", err)
 	}
 	defer client.Close()
+	client.Roots = workspaceRoots()
+	client.Sampler = terminalSampler()
 
 	if err := client.Initialize(ctx); err != nil {
 		return fmt.Errorf("failed to initialize MCP: %w", err)
@@ -147,3 +302,499 @@ func runMCPCall(cmd *cobra.Command, args []string) error {
 	fmt.Println(result)
 	return nil
 }
+
+func runMCPAdd(cmd *cobra.Command, args []string) error {
+	name, target := args[0], args[1]
+
+	transport := mcpTransport
+	if transport == "" {
+		transport = "stdio"
+		if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+			transport = "http"
+		}
+	}
+
+	server := config.MCPServerConfig{
+		Timeout:      mcpTimeout,
+		Trust:        mcpTrust,
+		IncludeTools: mcpIncludeTools,
+		ExcludeTools: mcpExcludeTools,
+	}
+	switch transport {
+	case "stdio":
+		server.Command = target
+		server.Args = args[2:]
+		env, err := parseMCPPairs(mcpEnv, "=", "--env")
+		if err != nil {
+			return err
+		}
+		server.Env = env
+		if len(mcpHeaders) > 0 || mcpOAuth || mcpClientID != "" || len(mcpScopes) > 0 {
+			return fmt.Errorf("--header and OAuth flags only apply to sse and http servers")
+		}
+	case "sse", "http":
+		if len(args) > 2 {
+			return fmt.Errorf("%s servers take a URL, not a command line", transport)
+		}
+		if len(mcpEnv) > 0 {
+			return fmt.Errorf("--env only applies to stdio servers")
+		}
+		server.URL = target
+		server.Type = transport
+		headers, err := parseMCPPairs(mcpHeaders, ":", "--header")
+		if err != nil {
+			return err
+		}
+		server.Headers = headers
+		if mcpOAuth || mcpClientID != "" || len(mcpScopes) > 0 {
+			server.OAuth = &config.MCPOAuthConfig{
+				Enabled:  true,
+				ClientID: mcpClientID,
+				Scopes:   mcpScopes,
+			}
+		}
+	default:
+		return fmt.Errorf("unknown transport %q (use stdio, sse or http)", transport)
+	}
+
+	path, err := editMCPSettings(func(servers, _ *config.Object) error {
+		if _, exists := servers.Get(name); exists && !mcpForce {
+			return fmt.Errorf("MCP server '%s' already exists (use --force to replace it)", name)
+		}
+		return servers.SetValue(name, server)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added MCP server '%s' to %s\n", name, path)
+	return nil
+}
+
+func runMCPRemove(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	path, err := editMCPSettings(func(servers, mcpSection *config.Object) error {
+		if _, exists := servers.Get(name); !exists {
+			return fmt.Errorf("MCP server '%s' not found in %s settings", name, mcpScope)
+		}
+		servers.Delete(name)
+		return updateStringList(mcpSection, "excluded", func(list []string) []string {
+			return slices.DeleteFunc(list, func(s string) bool { return s == name })
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed MCP server '%s' from %s\n", name, path)
+	return nil
+}
+
+// setMCPServerEnabled enables or disables a server through the mcp.excluded
+// list, which the official Gemini CLI uses as well
+func setMCPServerEnabled(name string, enabled bool) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if _, ok := cfg.MCPServers[name]; !ok {
+		return fmt.Errorf("MCP server '%s' not found in config", name)
+	}
+
+	path, err := editMCPSettings(func(_, mcpSection *config.Object) error {
+		if err := updateStringList(mcpSection, "excluded", func(list []string) []string {
+			list = slices.DeleteFunc(list, func(s string) bool { return s == name })
+			if !enabled {
+				list = append(list, name)
+			}
+			return list
+		}); err != nil {
+			return err
+		}
+		// An allow list in this file would still hide the server
+		return updateStringList(mcpSection, "allowed", func(list []string) []string {
+			if enabled && len(list) > 0 && !slices.Contains(list, name) {
+				list = append(list, name)
+			}
+			return list
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	state := "Disabled"
+	if enabled {
+		state = "Enabled"
+	}
+	fmt.Printf("%s MCP server '%s' in %s\n", state, name, path)
+
+	// Settings from the other scope may still override this one
+	if cfg, err := config.Load(); err == nil && cfg.MCPServerEnabled(name) != enabled {
+		other := config.ScopeProject
+		if mcpScope == config.ScopeProject {
+			other = config.ScopeUser
+		}
+		fmt.Printf("Note: the %s settings override this; run again with --scope %s\n", other, other)
+	}
+	return nil
+}
+
+func runMCPTest(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	serverCfg, ok := cfg.MCPServers[name]
+	if !ok {
+		return fmt.Errorf("MCP server '%s' not found in config", name)
+	}
+	if !cfg.MCPServerEnabled(name) {
+		fmt.Printf("Note: '%s' is disabled and is not used in chat\n", name)
+	}
+	testTimeout := 30 * time.Second
+	if serverCfg.Timeout > 0 {
+		testTimeout = time.Duration(serverCfg.Timeout) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	start := time.Now()
+	client, err := mcp.NewClientFromConfig(name, serverCfg)
+	if err != nil {
+		return fmt.Errorf("failed to start MCP server: %w", err)
+	}
+	defer client.Close()
+	started := time.Since(start)
+	client.Roots = workspaceRoots()
+
+	start = time.Now()
+	if err := client.Initialize(ctx); err != nil {
+		return fmt.Errorf("failed to initialize MCP: %w", err)
+	}
+	initialized := time.Since(start)
+
+	// A second tools/list measures the round trip of a warm server
+	start = time.Now()
+	if _, err := client.ListTools(ctx); err != nil {
+		return err
+	}
+	listed := time.Since(start)
+
+	fmt.Printf("Server:      %s %s\n", client.ServerName, client.ServerVersion)
+	fmt.Printf("Start:       %s\n", formatLatency(started))
+	fmt.Printf("Initialize:  %s (handshake and tools/list)\n", formatLatency(initialized))
+	fmt.Printf("Round trip:  %s (tools/list)\n", formatLatency(listed))
+	fmt.Printf("Tools (%d):\n", len(client.Tools))
+	for _, tool := range client.Tools {
+		fmt.Printf("  - %s", tool.Name)
+		if tool.Description != "" {
+			fmt.Printf(": %s", strings.SplitN(tool.Description, "\n", 2)[0])
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func runMCPAuth(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	serverCfg, ok := cfg.MCPServers[name]
+	if !ok {
+		return fmt.Errorf("MCP server '%s' not found in config", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	creds, err := mcp.Authorize(ctx, name, serverCfg, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Authorized '%s'", name)
+	if creds.Token.ExpiresAt > 0 {
+		fmt.Printf(" (token expires %s", time.UnixMilli(creds.Token.ExpiresAt).Format("2006-01-02 15:04"))
+		if creds.Token.RefreshToken != "" {
+			fmt.Print(", refreshed automatically")
+		}
+		fmt.Print(")")
+	}
+	fmt.Println()
+	return nil
+}
+
+func runMCPLogout(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	deleted, err := mcp.DeleteOAuthCredentials(name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		fmt.Printf("No OAuth token stored for '%s'\n", name)
+		return nil
+	}
+	fmt.Printf("Deleted the OAuth token of '%s'\n", name)
+	return nil
+}
+
+// oauthStatus describes the stored OAuth token of a server at serverURL
+func oauthStatus(name, serverURL string) string {
+	creds, err := mcp.LoadOAuthCredentials(name, serverURL)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("error: %v", err)
+	case creds == nil:
+		return fmt.Sprintf("not authorized (run 'gmn mcp auth %s')", name)
+	case creds.Token.Expired() && creds.Token.RefreshToken == "":
+		return fmt.Sprintf("token expired (run 'gmn mcp auth %s')", name)
+	}
+	return "authorized"
+}
+
+// workspaceRoots advertises the working directory to MCP servers
+func workspaceRoots() []mcp.Root {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	return mcp.WorkspaceRoots(cwd)
+}
+
+// terminalSampler answers sampling requests from MCP servers with Gemini
+// after confirming each one in the terminal
+func terminalSampler() mcp.SamplingHandler {
+	return geminiSampler(confirmation.PromptConfirmation)
+}
+
+// geminiSampler answers sampling requests from MCP servers with Gemini
+// after confirming each one with confirm
+func geminiSampler(confirm func(confirmation.Details) (confirmation.Outcome, error)) mcp.SamplingHandler {
+	newClient := func(ctx context.Context) (*api.Client, string, error) {
+		client, projectID, _, err := setupClient(ctx)
+		return client, projectID, err
+	}
+	ask := func(server string, req *mcp.SamplingRequest) bool {
+		outcome, err := confirm(confirmation.Details{
+			Type:     confirmation.TypeMCP,
+			Title:    fmt.Sprintf("Allow MCP server '%s' to query Gemini?", server),
+			ToolName: "sampling/createMessage",
+			Args:     mcp.SamplingPreview(req),
+		})
+		return err == nil && outcome != confirmation.OutcomeCancel
+	}
+	return mcp.GeminiSampler(newClient, ModelFreeDefault, ask)
+}
+
+// askProjectTrust asks once per project, in the terminal, whether the
+// project's settings may start the MCP servers they define and change
+// what the tools do without asking, showing what they set. The answer is
+// remembered (see 'gmn config trust'). It reports whether the user just
+// trusted the project, in which case the settings must be loaded again.
+func askProjectTrust(cfg *config.Config) bool {
+	names := cfg.ProjectServers()
+	if len(names) == 0 && len(cfg.Untrusted) == 0 {
+		return false
+	}
+	dir, err := config.ProjectDir()
+	if err != nil {
+		return false
+	}
+	if _, decided := config.ProjectTrust(dir); decided {
+		return false
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		warnUntrusted(cfg)
+		return false
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(os.Stderr, "The project settings in %s define MCP servers:\n", dir)
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", name, mcpServerTarget(cfg.MCPServers[name]))
+		}
+	}
+	if len(cfg.Untrusted) > 0 {
+		fmt.Fprintf(os.Stderr, "The project settings in %s change what tools may do without asking:\n", dir)
+		for _, key := range cfg.Untrusted {
+			fmt.Fprintf(os.Stderr, "  %s\n", key)
+		}
+	}
+	trusted, err := confirmation.AskYesNo("Trust this project and apply its settings?")
+	if err != nil {
+		return false
+	}
+	if err := config.SetProjectTrust(dir, trusted); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return false
+	}
+	return trusted
+}
+
+// warnUntrusted tells that the settings of an untrusted project that widen
+// what the tools may do were left out, for commands that cannot ask
+func warnUntrusted(cfg *config.Config) {
+	if len(cfg.Untrusted) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring %s from the settings of an untrusted project (trust it with 'gmn config trust')\n", strings.Join(cfg.Untrusted, ", "))
+	}
+}
+
+// projectTrusted reports whether the user trusts the current project's
+// settings
+func projectTrusted() bool {
+	dir, err := config.ProjectDir()
+	if err != nil {
+		return false
+	}
+	trusted, _ := config.ProjectTrust(dir)
+	return trusted
+}
+
+// mcpServerTarget describes what a server runs or where it connects
+func mcpServerTarget(server config.MCPServerConfig) string {
+	if server.Command == "" {
+		return server.URL
+	}
+	return strings.Join(append([]string{server.Command}, server.Args...), " ")
+}
+
+// connectMCPServers connects to the enabled MCP servers in parallel and
+// registers their tools (as server__tool), returning the connections to
+// close when the chat ends. Servers whose tools are cached start on their
+// first call; the others are started now. Servers that fail to start, and
+// those from the settings of a project the user does not trust, are
+// skipped and reported in the error.
+func connectMCPServers(ctx context.Context, cfg *config.Config, registry *tools.Registry, opts mcp.Options) ([]*mcp.Conn, error) {
+	opts.Cache = mcpToolCache(cfg)
+	trusted := projectTrusted()
+	var names, untrusted []string
+	for name, server := range cfg.MCPServers {
+		switch {
+		case !cfg.MCPServerEnabled(name):
+		case server.Project && !trusted:
+			untrusted = append(untrusted, name)
+		default:
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(untrusted)
+
+	conns := make([]*mcp.Conn, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conns[i], errs[i] = mcp.Open(ctx, name, cfg.MCPServers[name], opts)
+		}()
+	}
+	wg.Wait()
+
+	var connected []*mcp.Conn
+	for i, conn := range conns {
+		if conn == nil {
+			continue
+		}
+		if _, err := registry.RegisterMCP(names[i], cfg.MCPServers[names[i]], conn, conn.Tools()); err != nil {
+			errs = append(errs, err)
+		}
+		connected = append(connected, conn)
+	}
+	if len(untrusted) > 0 {
+		errs = append(errs, fmt.Errorf("not started from the project settings, which are not trusted (gmn config trust): %s", strings.Join(untrusted, ", ")))
+	}
+	return connected, errors.Join(errs...)
+}
+
+// mcpToolCache returns the cache of servers' tools, or nil if it is
+// disabled or there is no cache directory
+func mcpToolCache(cfg *config.Config) *mcp.ToolCache {
+	ttl := cfg.MCP.CacheTTL()
+	if ttl == 0 {
+		return nil
+	}
+	dir, err := config.CacheDir(cfg)
+	if err != nil {
+		return nil
+	}
+	return mcp.NewToolCache(filepath.Join(dir, mcp.ToolCacheFile), ttl)
+}
+
+// editMCPSettings applies fn to the mcpServers and mcp objects of the
+// settings file selected by --scope, returning the file's path
+func editMCPSettings(fn func(servers, mcpSection *config.Object) error) (string, error) {
+	path, err := config.SettingsPath(mcpScope)
+	if err != nil {
+		return "", err
+	}
+	err = config.UpdateSettings(path, func(settings *config.Object) error {
+		servers, err := settings.Child("mcpServers")
+		if err != nil {
+			return err
+		}
+		mcpSection, err := settings.Child("mcp")
+		if err != nil {
+			return err
+		}
+		if err := fn(servers, mcpSection); err != nil {
+			return err
+		}
+		if err := settings.SetChild("mcpServers", servers); err != nil {
+			return err
+		}
+		return settings.SetChild("mcp", mcpSection)
+	})
+	return path, err
+}
+
+// updateStringList rewrites a string list field of obj, removing it when
+// it ends up empty
+func updateStringList(obj *config.Object, key string, fn func([]string) []string) error {
+	var list []string
+	if raw, ok := obj.Get(key); ok {
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return fmt.Errorf("mcp.%s: %w", key, err)
+		}
+	}
+	list = fn(list)
+	if len(list) == 0 {
+		obj.Delete(key)
+		return nil
+	}
+	return obj.SetValue(key, list)
+}
+
+// parseMCPPairs parses KEY<sep>VALUE flag values into a map
+func parseMCPPairs(values []string, sep, flag string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	pairs := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, sep)
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid %s value %q (expected KEY%sVALUE)", flag, v, sep)
+		}
+		if sep == ":" { // Header values may follow "Name: "
+			value = strings.TrimSpace(value)
+		}
+		pairs[key] = value
+	}
+	return pairs, nil
+}
+
+// formatLatency rounds a duration for display
+func formatLatency(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
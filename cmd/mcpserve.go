@@ -0,0 +1,210 @@
+// MCP server command for gmn
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/config"
+	"github.com/linkalls/gmn/internal/confirmation"
+	"github.com/linkalls/gmn/internal/mcp"
+	"github.com/linkalls/gmn/internal/tools"
+	"github.com/linkalls/gmn/pkg/agent"
+	"github.com/spf13/cobra"
+)
+
+// generateToolName is the tool that asks Gemini for a completion
+const generateToolName = "gemini_generate"
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Offer gmn's tools and Gemini to other agents as a stdio MCP server",
+	Long: `Run gmn as an MCP server on stdin and stdout, so other agents and editors
+can use gmn's built-in tools and ask Gemini through the gemini_generate tool.
+
+There is nobody to confirm tool calls, so tools that would ask in a chat are
+refused unless tools.allowed or tools.shell.allowedCommands allows them, or
+--yolo is given. Blocked commands stay blocked.
+
+  {"mcpServers": {"gmn": {"command": "gmn", "args": ["mcp", "serve"]}}}`,
+	Args: cobra.NoArgs,
+	RunE: runMCPServe,
+}
+
+var (
+	mcpServeYolo  bool
+	mcpServeTools []string
+	mcpServeModel string
+)
+
+func init() {
+	mcpCmd.AddCommand(mcpServeCmd)
+	mcpServeCmd.Flags().BoolVar(&mcpServeYolo, "yolo", false, "Run every tool call without asking (dangerous!)")
+	mcpServeCmd.Flags().StringSliceVar(&mcpServeTools, "tools", nil, "Only offer these tools (comma-separated names or globs, e.g. read_file,search_*)")
+	mcpServeCmd.Flags().StringVarP(&mcpServeModel, "model", "m", "", "Default model of gemini_generate (default determined by tier)")
+	mcpServeCmd.Flags().StringVar(&shellPath, "shell", "", "Shell to use for commands (default: auto-detect)")
+}
+
+func runMCPServe(cmd *cobra.Command, args []string) error {
+	// stdout carries the protocol, so warnings go to stderr
+	warn := func(setting string, err error) {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", setting, err)
+	}
+
+	if shellPath == "" {
+		shellPath = DefaultShell()
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+	// The agent takes the public registry; gmn configures the one behind it
+	agentTools := agent.NewRegistry(cwd)
+	registry := tools.RegistryOf(agentTools)
+	defer registry.Jobs().KillAll()
+	registry.Settings().SetShellPath(shellPath)
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+	warnUntrusted(cfg)
+	for _, problem := range errorLines(registry.ApplySettings(cfg)) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", problem)
+	}
+	if err := registry.SetDisabled(cfg.Tools.Disabled); err != nil {
+		warn("tools.disabled", err)
+	}
+	if err := registry.Restrict(mcpServeTools); err != nil {
+		return fmt.Errorf("--tools: %w", err)
+	}
+
+	allowList := confirmation.NewAllowList()
+	allowList.SetConfigured(cfg.Tools.Allowed)
+
+	var offered []mcp.Tool
+	for _, t := range registry.GetAll() {
+		offered = append(offered, mcp.Tool{Name: t.Name(), Description: t.Description(), InputSchema: t.Parameters()})
+	}
+	generate := newGenerateTool(cmd.Flags().Changed("model"))
+	offered = append(offered, mcp.Tool{
+		Name:        generateToolName,
+		Description: "Ask Gemini for a response to a prompt. Returns the generated text.",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{` +
+			`"prompt":{"type":"string","description":"The prompt to send"},` +
+			`"system":{"type":"string","description":"Optional system instruction"},` +
+			`"model":{"type":"string","description":"Gemini model to use (defaults to gmn's model)"}},` +
+			`"required":["prompt"]}`),
+	})
+
+	server := &mcp.Server{
+		Name:    "gmn",
+		Version: version,
+		Tools:   offered,
+		Call: func(ctx context.Context, name string, args map[string]interface{}) (string, bool) {
+			if name == generateToolName {
+				text, err := generate(ctx, args)
+				if err != nil {
+					return err.Error(), true
+				}
+				return text, false
+			}
+
+			tool, ok := registry.Get(name)
+			if !ok {
+				return "unknown tool: " + name, true
+			}
+			if args == nil {
+				args = map[string]interface{}{}
+			}
+			if msg := serveRefusal(tool, args, allowList); msg != "" {
+				return msg, true
+			}
+			result := agent.ExecuteContext(ctx, agentTools, tool, args)
+			_, failed := result["error"]
+			return tools.FormatResult(result), failed
+		},
+	}
+	return server.Serve(cmd.Context(), os.Stdin, os.Stdout)
+}
+
+// serveRefusal returns why a tool call cannot run without a confirmation,
+// or "" if it can. It follows the chat's rules with the user's answer
+// taken to be no.
+func serveRefusal(tool tools.BuiltinTool, args map[string]interface{}, allowList *confirmation.AllowList) string {
+	decision, reason := tools.CheckCall(tool, args)
+	switch {
+	case decision == tools.DecisionBlock:
+		return tools.BlockedError(reason)
+	case mcpServeYolo || decision == tools.DecisionAllow || !tool.RequiresConfirmation():
+		return ""
+	case !tools.AlwaysConfirm(tool) && allowList.IsAllowed(tool.Name()):
+		return ""
+	}
+	return fmt.Sprintf("%s needs confirmation, which gmn mcp serve cannot ask for; "+
+		"allow it with tools.allowed or tools.shell.allowedCommands in settings.json, or run gmn mcp serve --yolo", tool.Name())
+}
+
+// newGenerateTool returns the handler of gemini_generate. It connects on
+// the first call, so clients that never use it need no login.
+func newGenerateTool(userSpecified bool) func(ctx context.Context, args map[string]interface{}) (string, error) {
+	var mu sync.Mutex
+	var client *api.Client
+	var projectID, defaultModel string
+
+	return func(ctx context.Context, args map[string]interface{}) (string, error) {
+		prompt, _ := args["prompt"].(string)
+		if strings.TrimSpace(prompt) == "" {
+			return "", fmt.Errorf("prompt is required")
+		}
+
+		mu.Lock()
+		if client == nil {
+			c, p, tier, err := setupClient(ctx)
+			if err != nil {
+				mu.Unlock()
+				return "", err
+			}
+			client, projectID = c, p
+			defaultModel = getEffectiveModel(mcpServeModel, tier, userSpecified)
+		}
+		mu.Unlock()
+
+		useModel := defaultModel
+		if m, _ := args["model"].(string); m != "" {
+			useModel = m
+		}
+		genReq := &api.GenerateRequest{
+			Model:        useModel,
+			Project:      projectID,
+			UserPromptID: fmt.Sprintf("gmn-mcp-%d", time.Now().UnixNano()),
+			Request: api.InnerRequest{
+				Contents: []api.Content{{Role: "user", Parts: []api.Part{{Text: prompt}}}},
+			},
+		}
+		if system, _ := args["system"].(string); system != "" {
+			genReq.Request.SystemInstruction = &api.Content{Role: "user", Parts: []api.Part{{Text: system}}}
+		}
+
+		resp, err := client.Generate(ctx, genReq)
+		if err != nil {
+			return "", fmt.Errorf("generation failed: %w", err)
+		}
+		var text strings.Builder
+		if len(resp.Response.Candidates) > 0 {
+			for _, p := range resp.Response.Candidates[0].Content.Parts {
+				text.WriteString(p.Text)
+			}
+		}
+		return text.String(), nil
+	}
+}
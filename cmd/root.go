@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -15,8 +16,14 @@ import (
 	"github.com/linkalls/gmn/internal/api"
 	"github.com/linkalls/gmn/internal/auth"
 	"github.com/linkalls/gmn/internal/config"
+	"github.com/linkalls/gmn/internal/glyphs"
 	"github.com/linkalls/gmn/internal/input"
+	"github.com/linkalls/gmn/internal/locale"
 	"github.com/linkalls/gmn/internal/output"
+	"github.com/linkalls/gmn/internal/telemetry"
+	"github.com/linkalls/gmn/internal/termcolor"
+	"github.com/linkalls/gmn/internal/tui"
+	"github.com/linkalls/gmn/pkg/agent"
 	"github.com/spf13/cobra"
 )
 
@@ -51,6 +58,8 @@ var (
 	files        []string
 	timeout      time.Duration
 	debug        bool
+	profileName  string
+	noColor      bool
 )
 
 var rootCmd = &cobra.Command{
@@ -68,6 +77,8 @@ Examples:
 	RunE:    run,
 	Version: version,
 	Args:    cobra.MaximumNArgs(1),
+
+	PersistentPreRunE: applySettings,
 }
 
 func init() {
@@ -77,18 +88,61 @@ func init() {
 	rootCmd.Flags().StringArrayVarP(&files, "file", "f", nil, "Files to include in context")
 	rootCmd.Flags().DurationVarP(&timeout, "timeout", "t", 5*time.Minute, "API timeout")
 	rootCmd.Flags().BoolVar(&debug, "debug", false, "Enable debug output")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colors (also set by NO_COLOR or a non-terminal stdout)")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Settings profile to use (default: $GMN_PROFILE or the one chosen with 'gmn config use')")
 
 	rootCmd.RegisterFlagCompletionFunc("model", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return AvailableModels, cobra.ShellCompDirectiveNoFileComp
 	})
-
+	rootCmd.RegisterFlagCompletionFunc("file", completeFiles)
 }
 
 // Execute runs the root command
 func Execute() error {
+	defer telemetry.Shutdown()
 	return rootCmd.Execute()
 }
 
+// applySettings selects the profile and applies the settings every command
+// shares. An unknown profile stops the command, since it would otherwise
+// run with the wrong account or tools.
+func applySettings(cmd *cobra.Command, args []string) error {
+	config.SetProfile(profileName)
+	cfg, err := config.Load()
+	if errors.Is(err, config.ErrUnknownProfile) {
+		return err
+	}
+	// The legacy Windows console garbles anything but ASCII
+	asciiOnly := glyphs.LegacyConsole()
+	// Telemetry is opt-in; Init is a no-op unless telemetry.enabled is set
+	if err == nil {
+		telemetry.Init(cfg.Telemetry, version)
+		if err := termcolor.Apply(cfg.UI.ColorMode); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: ui.colorMode:", err)
+		}
+		if err := termcolor.SetTheme(cfg.UI.Theme, cfg.UI.MinContrast); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: ui.theme:", err)
+		}
+		if err := glyphs.Apply(cfg.UI.Glyphs); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: ui.glyphs:", err)
+		}
+		asciiOnly = asciiOnly || cfg.UI.ASCIIOnly
+		if err := locale.Apply(cfg.UI.Locale); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: ui.locale:", err)
+		}
+	}
+	glyphs.SetASCIIOnly(asciiOnly)
+	applyNoColor()
+	return nil
+}
+
+// applyNoColor turns colors off for --no-color, over any ui.colorMode
+func applyNoColor() {
+	if noColor {
+		termcolor.Apply(termcolor.ModeNone)
+	}
+}
+
 // SetVersion sets the version string
 func SetVersion(v string) {
 	version = v
@@ -117,8 +171,37 @@ func run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Prepare input
-	inputText, err := input.PrepareInput(prompt, files)
+	// Prepare input, trimming attached files to the token budget. The client
+	// is only set up here if a file has to be summarized.
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+	attachments, err := input.LoadAttachments(files)
+	if err != nil {
+		formatter.WriteError(err)
+		return err
+	}
+	var (
+		apiClient *api.Client
+		projectID string
+		userTier  string
+	)
+	connect := func() (tui.Connection, error) {
+		if apiClient == nil {
+			var err error
+			if apiClient, projectID, userTier, err = setupClient(ctx); err != nil {
+				return tui.Connection{}, err
+			}
+		}
+		return tui.Connection{Client: apiClient, ProjectID: projectID}, nil
+	}
+	attachments, err = fitAttachments(ctx, attachments, cfg.Attachments.Budget(), cfg.Compaction.Model, connect)
+	if err != nil {
+		formatter.WriteError(err)
+		return err
+	}
+	inputText, err := input.CombineInput(prompt, attachments)
 	if err != nil {
 		formatter.WriteError(err)
 		return err
@@ -130,8 +213,7 @@ func run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	apiClient, projectID, userTier, err := setupClient(ctx)
-	if err != nil {
+	if _, err := connect(); err != nil {
 		formatter.WriteError(err)
 		return err
 	}
@@ -153,12 +235,15 @@ func run(cmd *cobra.Command, args []string) error {
 				Parts: []api.Part{{Text: inputText}},
 			}},
 			Config: api.GenerationConfig{
-				Temperature:     1.0,
+				Temperature:     cfg.Model.GenerationTemperature(),
 				TopP:            0.95,
 				MaxOutputTokens: 8192,
 			},
 		},
 	}
+	if cfg.General.SystemPrompt != "" {
+		req.Request.SystemInstruction = &api.Content{Role: "user", Parts: []api.Part{{Text: cfg.General.SystemPrompt}}}
+	}
 
 	// Execute based on output format
 	switch outputFormat {
@@ -198,7 +283,7 @@ func runStreamingWithFallback(ctx context.Context, client *api.Client, req *api.
 		stream, err := client.GenerateStream(ctx, req)
 		if err != nil {
 			// Check if this is a retryable error (429, 503, model not available)
-			if isRetryableError(err) && attempt < len(fallbackModels)-1 {
+			if agent.IsRetryable(err) && attempt < len(fallbackModels)-1 {
 				if debug {
 					fmt.Fprintf(os.Stderr, "Model %s failed: %v, trying fallback...\n", currentModel, err)
 				}
@@ -212,15 +297,16 @@ func runStreamingWithFallback(ctx context.Context, client *api.Client, req *api.
 		for event := range stream {
 			if event.Type == "error" {
 				// Check if this is a retryable error
-				if isRetryableStreamError(event.Error) && attempt < len(fallbackModels)-1 {
+				if (isRetryableStreamError(event.Error) || errors.Is(event.Err, api.ErrStalled)) && attempt < len(fallbackModels)-1 {
 					hasError = true
 					if debug {
 						fmt.Fprintf(os.Stderr, "Model %s stream error: %s, trying fallback...\n", currentModel, event.Error)
 					}
 					break
 				}
-				formatter.WriteError(fmt.Errorf(event.Error))
-				return fmt.Errorf(event.Error)
+				err := errors.New(event.Error)
+				formatter.WriteError(err)
+				return err
 			}
 			if err := formatter.WriteStreamEvent(&event); err != nil {
 				return err
@@ -235,19 +321,6 @@ func runStreamingWithFallback(ctx context.Context, client *api.Client, req *api.
 	return fmt.Errorf("all fallback models failed")
 }
 
-// isRetryableError checks if the error is retryable (rate limit, service unavailable, model not found, etc.)
-func isRetryableError(err error) bool {
-	errStr := err.Error()
-	return strings.Contains(errStr, "429") ||
-		strings.Contains(errStr, "404") ||
-		strings.Contains(errStr, "503") ||
-		strings.Contains(errStr, "RESOURCE_EXHAUSTED") ||
-		strings.Contains(errStr, "UNAVAILABLE") ||
-		strings.Contains(errStr, "NOT_FOUND") ||
-		strings.Contains(errStr, "model not found") ||
-		strings.Contains(errStr, "Model not found")
-}
-
 // isRetryableStreamError checks if the stream error is retryable
 func isRetryableStreamError(errStr string) bool {
 	return strings.Contains(errStr, "429") ||
@@ -260,27 +333,37 @@ func isRetryableStreamError(errStr string) bool {
 		strings.Contains(errStr, "Model not found")
 }
 
-// getEffectiveModel returns the model to use based on tier and user preference
+// getEffectiveModel returns the model to use: the one the user specified,
+// then model.name from settings, then the default for the tier
 func getEffectiveModel(specifiedModel string, userTier string, userSpecified bool) string {
 	// If user explicitly specified a model, use it
 	if userSpecified {
 		return specifiedModel
 	}
 
-	// Apply tier-based default
-	switch userTier {
-	case "standard-tier":
+	// Then the model from settings, which projects can override
+	if cfg, err := config.Load(); err == nil && cfg.Model.Name != "" {
 		if debug {
-			fmt.Fprintf(os.Stderr, "Using tier-based default model: %s (tier: %s)\n", ModelStandardDefault, userTier)
+			fmt.Fprintf(os.Stderr, "Using model from settings: %s\n", cfg.Model.Name)
 		}
+		return cfg.Model.Name
+	}
+
+	// Apply tier-based default
+	def := tierDefaultModel(userTier)
+	if debug {
+		fmt.Fprintf(os.Stderr, "Using tier-based default model: %s (tier: %s)\n", def, userTier)
+	}
+	return def
+}
+
+// tierDefaultModel returns the default model of a tier
+func tierDefaultModel(userTier string) string {
+	if userTier == "standard-tier" {
 		return ModelStandardDefault
-	default:
-		// Free tier or unknown tier uses flash model
-		if debug {
-			fmt.Fprintf(os.Stderr, "Using default model: %s (tier: %s)\n", ModelFreeDefault, userTier)
-		}
-		return ModelFreeDefault
 	}
+	// Free tier or unknown tier uses flash model
+	return ModelFreeDefault
 }
 
 // GetFallbackModels returns the fallback model list, starting from the specified model
@@ -302,24 +385,40 @@ func GetFallbackModels(currentModel string) []string {
 }
 
 func setupClient(ctx context.Context) (*api.Client, string, string, error) {
+	authMgr, creds, err := loadCredentials()
+	if err != nil {
+		return nil, "", "", err
+	}
+	return connectClient(ctx, authMgr, creds)
+}
+
+// loadCredentials reads the saved credentials. It does not touch the
+// network, so commands can fail fast when the user is not logged in.
+func loadCredentials() (*auth.Manager, *auth.Credentials, error) {
 	// Load config
 	cfg, err := config.Load()
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to load config: %w", err)
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
 	}
 	_ = cfg // Will be used for MCP
 
 	// Load credentials
 	authMgr, err := auth.NewManager()
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to initialize auth: %w", err)
+		return nil, nil, fmt.Errorf("failed to initialize auth: %w", err)
 	}
 
 	creds, err := authMgr.LoadCredentials()
 	if err != nil {
-		return nil, "", "", err
+		return nil, nil, err
 	}
+	return authMgr, creds, nil
+}
 
+// connectClient refreshes expired credentials and creates the API client,
+// loading the project ID and tier from Code Assist unless they are cached
+func connectClient(ctx context.Context, authMgr *auth.Manager, creds *auth.Credentials) (*api.Client, string, string, error) {
+	var err error
 	// Refresh if expired
 	if creds.IsExpired() {
 		if debug {
@@ -334,6 +433,11 @@ func setupClient(ctx context.Context) (*api.Client, string, string, error) {
 	// Create API client
 	httpClient := authMgr.HTTPClient(creds)
 	apiClient := api.NewClient(httpClient)
+	var configuredProject string
+	if cfg, err := config.Load(); err == nil {
+		apiClient.SetStallTimeout(cfg.General.StallTimeout())
+		configuredProject = cfg.Security.Auth.Project
+	}
 
 	// Try to load cached project ID first
 	cachedState, _ := config.LoadCachedState()
@@ -371,5 +475,10 @@ func setupClient(ctx context.Context) (*api.Client, string, string, error) {
 		fmt.Fprintf(os.Stderr, "Using cached Tier: %s\n", userTier)
 	}
 
+	// A configured project replaces the one Code Assist assigned
+	if configuredProject != "" {
+		projectID = configuredProject
+	}
+
 	return apiClient, projectID, userTier, nil
 }
@@ -0,0 +1,417 @@
+// Headless agent command for gmn
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/config"
+	"github.com/linkalls/gmn/internal/confirmation"
+	"github.com/linkalls/gmn/internal/guard"
+	"github.com/linkalls/gmn/internal/input"
+	"github.com/linkalls/gmn/internal/mcp"
+	"github.com/linkalls/gmn/internal/tools"
+	"github.com/linkalls/gmn/internal/tui"
+	"github.com/linkalls/gmn/pkg/agent"
+	"github.com/spf13/cobra"
+)
+
+// defaultRunMaxTurns is how many requests gmn run sends at most when
+// neither --max-turns nor the policy says
+const defaultRunMaxTurns = 25
+
+// Statuses of the result event of gmn run
+const (
+	runSuccess  = "success"
+	runError    = "error"
+	runMaxTurns = "max_turns"
+	runMaxCost  = "max_cost"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <task>",
+	Short: "Run the agent on a task without a terminal, for CI and cron jobs",
+	Long: `Run the full tool loop on a task without the TUI or any questions, and
+write what happens to stdout as JSON lines: one event per model request,
+text chunk, tool call and tool result, then a result event with the status,
+the answer, the tokens used and the estimated cost.
+
+There is nobody to confirm tool calls, so only tools that need no
+confirmation run unless the policy file (--policy), tools.allowed or
+tools.shell.allowedCommands allows more; other calls are refused and the
+model is told so. Blocked commands stay blocked.
+
+  {
+    "readOnly": false,
+    "tools": ["read_file", "glob", "search_file_content", "edit_file", "shell"],
+    "allowedTools": ["edit_file"],
+    "allowedCommands": ["go test*", "go vet*"],
+    "blockedCommands": ["git push*"],
+    "maxTurns": 20,
+    "maxCost": 0.50
+  }
+
+The run stops after --max-turns model requests or before a request that
+would take the estimated cost over --max-cost (USD), and exits non-zero
+unless the model finished.`,
+	Example: `  gmn run "Fix the failing test in ./internal/api"
+  gmn run --policy .gmn/ci-policy.json --max-cost 0.25 "Update the changelog"
+  git diff | gmn run --policy review.json "Review this diff" | jq -r 'select(.type=="result").answer'`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runAgent,
+}
+
+var (
+	runPolicyFile string
+	runMaxTurnsN  int
+	runMaxCostUSD float64
+)
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().StringVar(&runPolicyFile, "policy", "", "Policy file of the tools and commands that may run (JSON)")
+	runCmd.Flags().IntVar(&runMaxTurnsN, "max-turns", 0, fmt.Sprintf("Most model requests to send (default: the policy's, or %d)", defaultRunMaxTurns))
+	runCmd.Flags().Float64Var(&runMaxCostUSD, "max-cost", 0, "Most estimated cost in USD to spend (default: the policy's, or no limit)")
+	runCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use (default determined by tier)")
+	runCmd.Flags().StringArrayVarP(&files, "file", "f", nil, "Files to include in context")
+	runCmd.Flags().DurationVarP(&timeout, "timeout", "t", 5*time.Minute, "API timeout, per request")
+	runCmd.Flags().StringVar(&shellPath, "shell", "", "Shell to use for commands (default: auto-detect)")
+
+	runCmd.RegisterFlagCompletionFunc("model", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return AvailableModels, cobra.ShellCompDirectiveNoFileComp
+	})
+	runCmd.RegisterFlagCompletionFunc("file", completeFiles)
+}
+
+// runPolicy says what gmn run may do without anyone to ask
+type runPolicy struct {
+	ReadOnly        bool     `json:"readOnly,omitempty"`        // Offer only the tools that change nothing
+	Tools           []string `json:"tools,omitempty"`           // Tools offered (names or globs); all if empty
+	AllowedTools    []string `json:"allowedTools,omitempty"`    // Tools that run without confirmation (names or globs)
+	AllowedCommands []string `json:"allowedCommands,omitempty"` // Shell commands that run without confirmation
+	BlockedCommands []string `json:"blockedCommands,omitempty"` // Shell commands that are refused
+	MaxTurns        int      `json:"maxTurns,omitempty"`
+	MaxCost         float64  `json:"maxCost,omitempty"` // USD
+}
+
+// loadRunPolicy reads a policy file; no file is the empty policy, which
+// runs only the tools that need no confirmation
+func loadRunPolicy(file string) (runPolicy, error) {
+	var policy runPolicy
+	if file == "" {
+		return policy, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return policy, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields() // A misspelled key would silently allow less
+	if err := dec.Decode(&policy); err != nil {
+		return policy, fmt.Errorf("%s: %w", file, err)
+	}
+	return policy, nil
+}
+
+// allows reports whether the policy lets a tool run without confirmation
+func (p runPolicy) allows(name string) bool {
+	for _, pattern := range p.AllowedTools {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runEvent is a line of the event stream gmn run writes to stdout
+type runEvent struct {
+	Type         string                 `json:"type"`
+	Turn         int                    `json:"turn,omitempty"` // Model requests sent so far
+	Model        string                 `json:"model,omitempty"`
+	Text         string                 `json:"text,omitempty"`
+	Tool         string                 `json:"tool,omitempty"`
+	ID           string                 `json:"id,omitempty"`
+	Args         map[string]interface{} `json:"args,omitempty"`
+	Result       map[string]interface{} `json:"result,omitempty"`
+	Denied       bool                   `json:"denied,omitempty"` // Refused for want of a confirmation
+	DurationMs   int64                  `json:"durationMs,omitempty"`
+	Usage        *api.UsageMetadata     `json:"usage,omitempty"`
+	Status       string                 `json:"status,omitempty"`
+	Answer       string                 `json:"answer,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	InputTokens  int                    `json:"inputTokens,omitempty"`
+	OutputTokens int                    `json:"outputTokens,omitempty"`
+	Cost         float64                `json:"cost,omitempty"` // Estimated USD spent so far
+}
+
+// errCostBudget stops a run before a request that would go over --max-cost
+var errCostBudget = errors.New("cost budget reached")
+
+func runAgent(cmd *cobra.Command, args []string) error {
+	start := time.Now()
+	// stdout carries the events, so warnings go to stderr
+	warn := func(setting string, err error) {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", setting, err)
+	}
+
+	policy, err := loadRunPolicy(runPolicyFile)
+	if err != nil {
+		return fmt.Errorf("--policy: %w", err)
+	}
+	maxTurns := policy.MaxTurns
+	if cmd.Flags().Changed("max-turns") || maxTurns <= 0 {
+		maxTurns = runMaxTurnsN
+	}
+	if maxTurns <= 0 {
+		maxTurns = defaultRunMaxTurns
+	}
+	maxCost := policy.MaxCost
+	if cmd.Flags().Changed("max-cost") {
+		maxCost = runMaxCostUSD
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		select {
+		case <-sigCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if shellPath == "" {
+		shellPath = DefaultShell()
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+	// The agent takes the public registry; gmn configures the one behind it
+	agentTools := agent.NewRegistry(cwd)
+	registry := tools.RegistryOf(agentTools)
+	defer registry.Jobs().KillAll()
+	registry.Settings().SetShellPath(shellPath)
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = config.DefaultConfig()
+	}
+	warnUntrusted(cfg)
+	// The policy file's command lists add to the settings'
+	cfg.Tools.Shell.AllowedCommands = append(slices.Clone(cfg.Tools.Shell.AllowedCommands), policy.AllowedCommands...)
+	cfg.Tools.Shell.BlockedCommands = append(slices.Clone(cfg.Tools.Shell.BlockedCommands), policy.BlockedCommands...)
+	for _, problem := range errorLines(registry.ApplySettings(cfg)) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", problem)
+	}
+
+	// MCP servers cannot sample: there is nobody to confirm it
+	mcpConns, err := connectMCPServers(ctx, cfg, registry, mcp.Options{Roots: workspaceRoots()})
+	if err != nil {
+		warn("mcpServers", err)
+	}
+	defer func() {
+		for _, conn := range mcpConns {
+			conn.Close()
+		}
+	}()
+	if err := registry.SetDisabled(cfg.Tools.Disabled); err != nil {
+		warn("tools.disabled", err)
+	}
+	if err := restrictRunTools(registry, policy); err != nil {
+		return fmt.Errorf("--policy: %w", err)
+	}
+
+	allowList := confirmation.NewAllowList()
+	allowList.SetConfigured(cfg.Tools.Allowed)
+
+	// The task, with piped input and attached files
+	attachments, err := input.LoadAttachments(files)
+	if err != nil {
+		return err
+	}
+	var (
+		client    *api.Client
+		projectID string
+		userTier  string
+	)
+	connect := func() (tui.Connection, error) {
+		if client == nil {
+			var err error
+			if client, projectID, userTier, err = setupClient(ctx); err != nil {
+				return tui.Connection{}, err
+			}
+		}
+		return tui.Connection{Client: client, ProjectID: projectID}, nil
+	}
+	attachments, err = fitAttachments(ctx, attachments, cfg.Attachments.Budget(), cfg.Compaction.Model, connect)
+	if err != nil {
+		return err
+	}
+	task, err := input.CombineInput(args[0], attachments)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(task) == "" {
+		return fmt.Errorf("no task given")
+	}
+	if _, err := connect(); err != nil {
+		return err
+	}
+	modelName := getEffectiveModel(model, userTier, cmd.Flags().Changed("model"))
+
+	events := newRunEvents(os.Stdout)
+	denied := make(map[*api.FunctionCall]bool)
+	current := modelName
+	a, err := agent.New(agent.Options{
+		Client:        client,
+		ProjectID:     projectID,
+		Model:         modelName,
+		Fallback:      GetFallbackModels(modelName),
+		Tools:         agentTools,
+		AllowList:     allowList,
+		MaxIterations: maxTurns,
+		Timeout:       timeout,
+		FailureNotes:  cfg.Tools.FailureNotes,
+		Temperature:   cfg.Model.GenerationTemperature(),
+		Confirm: func(ctx context.Context, req agent.ConfirmRequest) (agent.Decision, error) {
+			if policy.allows(req.Tool.Name()) {
+				return agent.Allow, nil
+			}
+			denied[req.Call] = true
+			return agent.Deny, nil
+		},
+		BeforeRequest: func(ctx context.Context, req *agent.Request, iteration int) error {
+			if maxCost <= 0 {
+				return nil
+			}
+			tokens := guard.Estimate(req.Request.Contents, req.Request.SystemInstruction, req.Request.Tools).Total()
+			if events.cost+guard.InputCost(req.Model, tokens) > maxCost {
+				return errCostBudget
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	answer, err := a.Send(ctx, task, func(ev agent.Event) {
+		switch ev.Type {
+		case agent.EventStart:
+			current = ev.Model
+			events.turn++
+			events.write(runEvent{Type: "start", Model: ev.Model})
+		case agent.EventText:
+			events.write(runEvent{Type: "text", Text: ev.Text})
+		case agent.EventToolCall:
+			events.write(runEvent{Type: "tool_call", Tool: ev.Call.Name, ID: ev.Call.ID, Args: ev.Call.Args})
+		case agent.EventToolResult:
+			events.write(runEvent{
+				Type:       "tool_result",
+				Tool:       ev.Call.Name,
+				ID:         ev.Call.ID,
+				Result:     ev.Result,
+				Denied:     denied[ev.Call],
+				DurationMs: ev.Duration.Milliseconds(),
+			})
+		case agent.EventDone:
+			if ev.Usage != nil {
+				events.input += ev.Usage.PromptTokenCount
+				events.output += ev.Usage.CandidatesTokenCount
+				events.cost += guard.Cost(current, ev.Usage.PromptTokenCount, ev.Usage.CandidatesTokenCount)
+			}
+			events.write(runEvent{Type: "usage", Model: current, Usage: ev.Usage, Cost: events.cost})
+		}
+	})
+
+	result := runEvent{
+		Type:         "result",
+		Status:       runSuccess,
+		Answer:       answer,
+		InputTokens:  events.input,
+		OutputTokens: events.output,
+		Cost:         events.cost,
+		DurationMs:   time.Since(start).Milliseconds(),
+	}
+	switch {
+	case errors.Is(err, agent.ErrMaxIterations):
+		result.Status = runMaxTurns
+	case errors.Is(err, errCostBudget):
+		result.Status = runMaxCost
+	case err != nil:
+		result.Status = runError
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	events.write(result)
+	if events.err != nil {
+		return events.err
+	}
+	return err
+}
+
+// restrictRunTools offers the tools the policy names, and of them only the
+// read-only ones if it says so
+func restrictRunTools(registry *tools.Registry, policy runPolicy) error {
+	if err := registry.Restrict(policy.Tools); err != nil {
+		return err
+	}
+	if !policy.ReadOnly {
+		return nil
+	}
+	var names []string
+	for _, t := range registry.GetAll() {
+		if tools.ReadOnly(t) {
+			names = append(names, t.Name())
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no read-only tools to offer")
+	}
+	return registry.Restrict(names)
+}
+
+// runEvents writes the event stream and keeps the totals the events report
+type runEvents struct {
+	enc    *json.Encoder
+	err    error // First write error; the run goes on to its end
+	turn   int
+	input  int
+	output int
+	cost   float64
+}
+
+// newRunEvents writes events to w, one JSON object per line
+func newRunEvents(w io.Writer) *runEvents {
+	return &runEvents{enc: json.NewEncoder(w)}
+}
+
+// write sends an event, numbered with the current turn
+func (e *runEvents) write(ev runEvent) {
+	ev.Turn = e.turn
+	if err := e.enc.Encode(ev); err != nil && e.err == nil {
+		e.err = err
+	}
+}
@@ -0,0 +1,674 @@
+// Sessions command for gmn
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linkalls/gmn/internal/guard"
+	"github.com/linkalls/gmn/internal/locale"
+	"github.com/linkalls/gmn/internal/session"
+	"github.com/linkalls/gmn/internal/textwidth"
+	"github.com/linkalls/gmn/internal/tools"
+	"github.com/mattn/go-runewidth"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionsJSON      bool   // Machine-readable output
+	sessionsOlderThan string // prune: minimum age
+	sessionsKeep      int    // prune: always keep the N newest
+	sessionsDryRun    bool   // prune: only report
+	sessionsShowAll   bool   // show: include tool call turns
+	sessionsRegex     bool   // search: treat query as a regular expression
+	sessionsLimit     int    // search: maximum number of matches
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage saved chat sessions",
+	Example: `  gmn sessions list
+  gmn sessions show last-week --json
+  gmn sessions rename 20250101-120000 refactor-notes
+  gmn sessions compare fix-flash fix-pro
+  gmn sessions prune --older-than 30d --keep 20`,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved sessions (newest first)",
+	Args:  cobra.NoArgs,
+	RunE:  runSessionsList,
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <id-or-name>",
+	Short: "Print a saved session",
+	Args:  cobra.ExactArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeSessions(cmd, args, toComplete)
+	},
+	RunE: runSessionsShow,
+}
+
+var sessionsDeleteCmd = &cobra.Command{
+	Use:               "delete <id-or-name>...",
+	Short:             "Delete saved sessions",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeSessions,
+	RunE:              runSessionsDelete,
+}
+
+var sessionsRenameCmd = &cobra.Command{
+	Use:   "rename <id-or-name> <new-name>",
+	Short: "Rename a saved session",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runSessionsRename,
+}
+
+var sessionsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete old sessions",
+	Long: `Delete sessions that have not been updated recently.
+
+Examples:
+  gmn sessions prune --older-than 30d
+  gmn sessions prune --keep 50
+  gmn sessions prune --older-than 2w --keep 10 --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: runSessionsPrune,
+}
+
+var sessionsSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search message content across saved sessions",
+	Long: `Search message content across all saved sessions.
+
+Plain queries match case-insensitively. Use --regex for Go regular expressions.
+
+Examples:
+  gmn sessions search dockerfile
+  gmn sessions search --regex 'FROM (golang|alpine)'
+  gmn sessions search "retry logic" --json | jq -r '.[].session_id'`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSessionsSearch,
+}
+
+var sessionsImportCmd = &cobra.Command{
+	Use:   "import <file>...",
+	Short: "Import transcripts from gemini-cli or Claude Code",
+	Long: `Import conversation transcripts from other tools as gmn sessions.
+
+Supported formats (detected automatically):
+  - gemini-cli checkpoints saved with /chat save (~/.gemini/tmp/<hash>/checkpoint-*.json)
+  - gemini-cli chat recordings (~/.gemini/tmp/<hash>/chats/session-*.json)
+  - Claude Code transcripts (~/.claude/projects/<project>/*.jsonl)
+
+Only text turns are imported; tool calls and tool results are skipped.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSessionsImport,
+}
+
+var sessionsCompareCmd = &cobra.Command{
+	Use:   "compare <a> <b>",
+	Short: "Show how two sessions diverged",
+	Long: `Compare two saved sessions side by side, e.g. the same task attempted
+with different models: prompts, tool usage, files modified and token/cost
+totals. Prompts are listed in pairs up to where they first differ.
+
+Tool calls are only recorded in sessions saved by this version of gmn or later.
+
+Examples:
+  gmn sessions compare fix-flash fix-pro
+  gmn sessions compare 20250101-120000 20250101-130000 --json`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSessionsCompare,
+}
+
+func init() {
+	rootCmd.AddCommand(sessionsCmd)
+	sessionsCmd.AddCommand(sessionsListCmd)
+	sessionsCmd.AddCommand(sessionsShowCmd)
+	sessionsCmd.AddCommand(sessionsDeleteCmd)
+	sessionsCmd.AddCommand(sessionsRenameCmd)
+	sessionsCmd.AddCommand(sessionsPruneCmd)
+	sessionsCmd.AddCommand(sessionsSearchCmd)
+	sessionsCmd.AddCommand(sessionsImportCmd)
+	sessionsCmd.AddCommand(sessionsCompareCmd)
+
+	sessionsCmd.PersistentFlags().BoolVar(&sessionsJSON, "json", false, "Output machine-readable JSON")
+	sessionsShowCmd.Flags().BoolVar(&sessionsShowAll, "all", false, "Include tool call turns")
+	sessionsSearchCmd.Flags().BoolVarP(&sessionsRegex, "regex", "e", false, "Treat the query as a regular expression")
+	sessionsSearchCmd.Flags().IntVarP(&sessionsLimit, "limit", "n", 50, "Maximum number of matches (0 for all)")
+	sessionsPruneCmd.Flags().StringVar(&sessionsOlderThan, "older-than", "", "Delete sessions not updated within this age (e.g. 30d, 2w, 12h)")
+	sessionsPruneCmd.Flags().IntVar(&sessionsKeep, "keep", 0, "Always keep the N most recent sessions")
+	sessionsPruneCmd.Flags().BoolVar(&sessionsDryRun, "dry-run", false, "Show what would be deleted without deleting")
+}
+
+// sessionSummary is the JSON representation of a session in listings
+type sessionSummary struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name,omitempty"`
+	ParentID  string             `json:"parent_id,omitempty"`
+	Model     string             `json:"model"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Messages  int                `json:"messages"`
+	Tokens    session.TokenUsage `json:"tokens"`
+}
+
+func summarizeSession(s *session.Session) sessionSummary {
+	return sessionSummary{
+		ID:        s.ID,
+		Name:      s.Name,
+		ParentID:  s.ParentID,
+		Model:     s.Model,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Messages:  s.MessageCount(),
+		Tokens:    s.Tokens,
+	}
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// completeSessions completes a session argument with the IDs of the saved
+// sessions, described by their names
+func completeSessions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	sessions, err := sessionMgr.List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var ids []string
+	for _, s := range sessions {
+		if !strings.HasPrefix(s.ID, toComplete) {
+			continue
+		}
+		if s.Name != "" {
+			ids = append(ids, s.ID+"\t"+s.Name)
+		} else {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+func runSessionsList(cmd *cobra.Command, args []string) error {
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		return err
+	}
+	sessions, err := sessionMgr.List()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if sessionsJSON {
+		summaries := make([]sessionSummary, 0, len(sessions))
+		for _, s := range sessions {
+			summaries = append(summaries, summarizeSession(s))
+		}
+		return writeJSON(out, summaries)
+	}
+
+	if len(sessions) == 0 {
+		fmt.Fprintln(out, "No saved sessions")
+		return nil
+	}
+	for _, s := range sessions {
+		name := s.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(out, "%-20s %s %-24s %4d msgs  %s\n",
+			s.ID, textwidth.Pad(name, 24), s.Model, s.MessageCount(), s.UpdatedAt.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+func runSessionsShow(cmd *cobra.Command, args []string) error {
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		return err
+	}
+	s, err := sessionMgr.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if sessionsJSON {
+		return writeJSON(out, s)
+	}
+
+	fmt.Fprintf(out, "Session: %s\n", s.ID)
+	if s.Name != "" {
+		fmt.Fprintf(out, "Name:    %s\n", s.Name)
+	}
+	if s.ParentID != "" {
+		fmt.Fprintf(out, "Branch:  from %s\n", s.ParentID)
+	}
+	fmt.Fprintf(out, "Model:   %s\n", s.Model)
+	fmt.Fprintf(out, "Updated: %s\n", s.UpdatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(out, "Tokens:  %d in / %d out\n", s.Tokens.Input, s.Tokens.Output)
+
+	for _, msg := range s.Messages {
+		role, _ := msg["role"].(string)
+		text := session.MessageText(msg)
+		if text == "" && !sessionsShowAll {
+			continue
+		}
+		if text == "" {
+			text = "(tool call)"
+		}
+		fmt.Fprintf(out, "\n[%s]\n%s\n", role, text)
+	}
+	return nil
+}
+
+func runSessionsDelete(cmd *cobra.Command, args []string) error {
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		return err
+	}
+
+	var deleted []string
+	for _, idOrName := range args {
+		s, err := sessionMgr.Load(idOrName)
+		if err != nil {
+			return err
+		}
+		if err := sessionMgr.Delete(s.ID); err != nil {
+			return err
+		}
+		deleted = append(deleted, s.ID)
+	}
+
+	if sessionsJSON {
+		return writeJSON(cmd.OutOrStdout(), map[string]interface{}{"deleted": deleted})
+	}
+	for _, id := range deleted {
+		fmt.Fprintf(cmd.OutOrStdout(), "✓ Deleted %s\n", id)
+	}
+	return nil
+}
+
+func runSessionsRename(cmd *cobra.Command, args []string) error {
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		return err
+	}
+	if err := sessionMgr.Rename(args[0], args[1]); err != nil {
+		return err
+	}
+	s, err := sessionMgr.Load(args[1])
+	if err != nil {
+		return err
+	}
+
+	if sessionsJSON {
+		return writeJSON(cmd.OutOrStdout(), summarizeSession(s))
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "✓ Renamed %s to %s\n", s.ID, s.Name)
+	return nil
+}
+
+func runSessionsPrune(cmd *cobra.Command, args []string) error {
+	if sessionsOlderThan == "" && sessionsKeep == 0 {
+		return fmt.Errorf("specify --older-than and/or --keep")
+	}
+
+	var olderThan time.Duration
+	if sessionsOlderThan != "" {
+		var err error
+		olderThan, err = parseAge(sessionsOlderThan)
+		if err != nil {
+			return err
+		}
+		// 0d would match every session, the current one included
+		if olderThan <= 0 {
+			return fmt.Errorf("--older-than must be more than zero (got %s)", sessionsOlderThan)
+		}
+	}
+	if sessionsKeep < 0 {
+		return fmt.Errorf("--keep must not be negative")
+	}
+
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		return err
+	}
+	pruned, err := sessionMgr.Prune(olderThan, sessionsKeep, sessionsDryRun)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if sessionsJSON {
+		summaries := make([]sessionSummary, 0, len(pruned))
+		for _, s := range pruned {
+			summaries = append(summaries, summarizeSession(s))
+		}
+		return writeJSON(out, map[string]interface{}{
+			"dry_run": sessionsDryRun,
+			"pruned":  summaries,
+		})
+	}
+
+	verb := "Deleted"
+	if sessionsDryRun {
+		verb = "Would delete"
+	}
+	for _, s := range pruned {
+		fmt.Fprintf(out, "%s %s (updated %s)\n", verb, s.ID, s.UpdatedAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Fprintf(out, "%s %d session(s)\n", verb, len(pruned))
+	return nil
+}
+
+func runSessionsSearch(cmd *cobra.Command, args []string) error {
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		return err
+	}
+	results, err := sessionMgr.Search(args[0], sessionsRegex, sessionsLimit)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if sessionsJSON {
+		if results == nil {
+			results = []session.SearchResult{}
+		}
+		return writeJSON(out, results)
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(out, "No matches")
+		return nil
+	}
+	for _, r := range results {
+		id := r.SessionID
+		if r.Name != "" {
+			id += " (" + r.Name + ")"
+		}
+		fmt.Fprintf(out, "%s  %s  [%s #%d]\n    %s\n",
+			id, r.UpdatedAt.Format("2006-01-02 15:04"), r.Role, r.Message, r.Snippet)
+	}
+	return nil
+}
+
+// parseAge parses a duration that may use d (days) or w (weeks) units
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age: %s", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age: %s (use e.g. 30d, 2w, 12h)", s)
+	}
+	return d, nil
+}
+
+func runSessionsImport(cmd *cobra.Command, args []string) error {
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, path := range args {
+		s, format, err := sessionMgr.Import(path, ModelFreeDefault)
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "✗ %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "✓ Imported %s (%s, %d messages) as %s\n", path, format, len(s.Messages), s.ID)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d transcripts failed to import", failed, len(args))
+	}
+	return nil
+}
+
+// compareColumn is the width of each side of the compare report
+const compareColumn = 38
+
+// compareSide is one session of a comparison
+type compareSide struct {
+	sessionSummary
+	Prompts       []string       `json:"prompts"`
+	ToolCalls     map[string]int `json:"tool_calls"`
+	FilesModified []string       `json:"files_modified"`
+	Cost          float64        `json:"cost_usd"` // Estimated; 0 for unknown models
+}
+
+// sessionComparison is the result of gmn sessions compare
+type sessionComparison struct {
+	A          compareSide `json:"a"`
+	B          compareSide `json:"b"`
+	DivergedAt int         `json:"diverged_at"` // 1-based index of the first differing prompt, 0 if none differ
+	Files      struct {
+		Both  []string `json:"both"`
+		OnlyA []string `json:"only_a"`
+		OnlyB []string `json:"only_b"`
+	} `json:"files"`
+}
+
+func runSessionsCompare(cmd *cobra.Command, args []string) error {
+	sessionMgr, err := session.NewManager()
+	if err != nil {
+		return err
+	}
+	a, err := sessionMgr.Load(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := sessionMgr.Load(args[1])
+	if err != nil {
+		return err
+	}
+
+	cwd, _ := os.Getwd()
+	c := compareSessions(a, b, tools.NewRegistry(cwd))
+
+	out := cmd.OutOrStdout()
+	if sessionsJSON {
+		return writeJSON(out, c)
+	}
+	printComparison(out, c)
+	return nil
+}
+
+// compareSessions collects the differences between two sessions; registry
+// tells which tools modify files
+func compareSessions(a, b *session.Session, registry *tools.Registry) sessionComparison {
+	var c sessionComparison
+	c.A = summarizeActivity(a, registry)
+	c.B = summarizeActivity(b, registry)
+
+	for i := 0; i < len(c.A.Prompts) || i < len(c.B.Prompts); i++ {
+		if i >= len(c.A.Prompts) || i >= len(c.B.Prompts) || c.A.Prompts[i] != c.B.Prompts[i] {
+			c.DivergedAt = i + 1
+			break
+		}
+	}
+
+	inB := make(map[string]bool, len(c.B.FilesModified))
+	for _, f := range c.B.FilesModified {
+		inB[f] = true
+	}
+	inA := make(map[string]bool, len(c.A.FilesModified))
+	for _, f := range c.A.FilesModified {
+		inA[f] = true
+		if inB[f] {
+			c.Files.Both = append(c.Files.Both, f)
+		} else {
+			c.Files.OnlyA = append(c.Files.OnlyA, f)
+		}
+	}
+	for _, f := range c.B.FilesModified {
+		if !inA[f] {
+			c.Files.OnlyB = append(c.Files.OnlyB, f)
+		}
+	}
+	return c
+}
+
+// summarizeActivity counts the tool calls of a session and the files its
+// edit tools touched
+func summarizeActivity(s *session.Session, registry *tools.Registry) compareSide {
+	activity := s.Activity()
+	side := compareSide{
+		sessionSummary: summarizeSession(s),
+		Prompts:        activity.Prompts,
+		ToolCalls:      make(map[string]int),
+		FilesModified:  []string{},
+		Cost:           guard.Cost(s.Model, s.Tokens.Input, s.Tokens.Output),
+	}
+	if side.Prompts == nil {
+		side.Prompts = []string{}
+	}
+
+	seen := make(map[string]bool)
+	for _, call := range activity.ToolCalls {
+		side.ToolCalls[call.Name]++
+		tool, ok := registry.Get(call.Name)
+		if !ok || tool.ConfirmationType() != "edit" {
+			continue
+		}
+		path, _ := call.Args["path"].(string)
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		side.FilesModified = append(side.FilesModified, path)
+	}
+	sort.Strings(side.FilesModified)
+	return side
+}
+
+// printComparison renders a comparison as a side-by-side report
+func printComparison(w io.Writer, c sessionComparison) {
+	row := func(label, a, b string) {
+		fmt.Fprintf(w, "%-10s %s  %s\n", label, compareCell(a), strings.TrimRight(compareCell(b), " "))
+	}
+	name := func(s compareSide) string {
+		if s.Name == "" {
+			return "-"
+		}
+		return s.Name
+	}
+	tokens := func(s compareSide) string {
+		return fmt.Sprintf("%s in / %s out", guard.FormatTokens(s.Tokens.Input), guard.FormatTokens(s.Tokens.Output))
+	}
+	cost := func(s compareSide) string {
+		if s.Cost == 0 {
+			return "-"
+		}
+		return "~" + locale.Current().Cost(s.Cost, 4)
+	}
+
+	row("", "A", "B")
+	row("Session", c.A.ID, c.B.ID)
+	row("Name", name(c.A), name(c.B))
+	row("Model", c.A.Model, c.B.Model)
+	row("Messages", strconv.Itoa(c.A.Messages), strconv.Itoa(c.B.Messages))
+	row("Prompts", strconv.Itoa(len(c.A.Prompts)), strconv.Itoa(len(c.B.Prompts)))
+	row("Tokens", tokens(c.A), tokens(c.B))
+	row("Cost", cost(c.A), cost(c.B))
+
+	fmt.Fprintln(w, "\nTool calls")
+	var names []string
+	for n := range c.A.ToolCalls {
+		names = append(names, n)
+	}
+	for n := range c.B.ToolCalls {
+		if _, ok := c.A.ToolCalls[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		fmt.Fprintln(w, "  (none recorded)")
+	}
+	var totalA, totalB int
+	for _, n := range names {
+		totalA += c.A.ToolCalls[n]
+		totalB += c.B.ToolCalls[n]
+		fmt.Fprintf(w, "  %-24s %6d %6d\n", n, c.A.ToolCalls[n], c.B.ToolCalls[n])
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(w, "  %-24s %6d %6d\n", "total", totalA, totalB)
+	}
+
+	fmt.Fprintln(w, "\nFiles modified")
+	if len(c.Files.Both)+len(c.Files.OnlyA)+len(c.Files.OnlyB) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, f := range c.Files.Both {
+		fmt.Fprintf(w, "  both    %s\n", f)
+	}
+	for _, f := range c.Files.OnlyA {
+		fmt.Fprintf(w, "  only A  %s\n", f)
+	}
+	for _, f := range c.Files.OnlyB {
+		fmt.Fprintf(w, "  only B  %s\n", f)
+	}
+
+	if c.DivergedAt == 0 {
+		fmt.Fprintln(w, "\nPrompts (identical)")
+	} else {
+		fmt.Fprintf(w, "\nPrompts (diverge at #%d)\n", c.DivergedAt)
+	}
+	for i := 0; i < len(c.A.Prompts) || i < len(c.B.Prompts); i++ {
+		var a, b string
+		if i < len(c.A.Prompts) {
+			a = c.A.Prompts[i]
+		}
+		if i < len(c.B.Prompts) {
+			b = c.B.Prompts[i]
+		}
+		mark := " "
+		if i+1 == c.DivergedAt {
+			mark = "≠"
+		}
+		row(fmt.Sprintf("%s #%d", mark, i+1), a, b)
+	}
+}
+
+// compareCell fits text on one line of a report column
+func compareCell(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	text = runewidth.Truncate(text, compareColumn, "…")
+	return runewidth.FillRight(text, compareColumn)
+}
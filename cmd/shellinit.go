@@ -0,0 +1,39 @@
+// Shell integration command for gmn
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/linkalls/gmn/internal/shellhook"
+	"github.com/spf13/cobra"
+)
+
+var shellInitCmd = &cobra.Command{
+	Use:   "shell-init <bash|zsh|fish>",
+	Short: "Print shell integration hooks used by /last-cmd",
+	Long: `Print a hook script that records your most recent shell command,
+its exit code and working directory so /last-cmd can attach it to a chat prompt.
+The output is not recorded; run a command as !command in the chat to attach
+its output.
+
+Add one of these to your shell startup file:
+  bash:  eval "$(gmn shell-init bash)"     # ~/.bashrc
+  zsh:   eval "$(gmn shell-init zsh)"      # ~/.zshrc
+  fish:  gmn shell-init fish | source      # ~/.config/fish/config.fish`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		script, err := shellhook.Script(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Print(script)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(shellInitCmd)
+}
@@ -0,0 +1,94 @@
+// Upgrade command for gmn
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/linkalls/gmn/internal/update"
+	"github.com/spf13/cobra"
+)
+
+var (
+	upgradeCheck   bool   // Only report whether an update is available
+	upgradeVersion string // Install a specific version instead of the latest
+	upgradeForce   bool   // Reinstall even if up to date
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Update gmn to the latest release",
+	Long: `Download the latest gmn release from GitHub, verify it against the
+release's SHA-256 checksums and replace the running binary.
+
+With --check nothing is installed; the command exits with status 1 when a
+newer release is available, which makes it usable in CI.
+
+If gmn was installed with a package manager (Homebrew, go install, ...),
+upgrade with that instead.`,
+	Example: `  gmn upgrade
+  gmn upgrade --check
+  gmn upgrade --version v0.3.0`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE:         runUpgrade,
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+
+	upgradeCmd.Flags().BoolVar(&upgradeCheck, "check", false, "Only check for a newer release (exit 1 if one is available)")
+	upgradeCmd.Flags().StringVar(&upgradeVersion, "version", "", "Install a specific release (e.g. v0.3.0)")
+	upgradeCmd.Flags().BoolVar(&upgradeForce, "force", false, "Reinstall even if already up to date")
+}
+
+func runUpgrade(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	out := cmd.OutOrStdout()
+	rel, err := update.FetchRelease(ctx, upgradeVersion)
+	if err != nil {
+		return err
+	}
+
+	newer := update.IsNewer(version, rel.Version())
+	if upgradeCheck {
+		if newer {
+			fmt.Fprintf(out, "Update available: %s → %s (%s)\n", version, rel.Version(), rel.HTMLURL)
+			return fmt.Errorf("gmn %s is out of date", version)
+		}
+		fmt.Fprintf(out, "gmn %s is up to date\n", version)
+		return nil
+	}
+
+	if !newer && upgradeVersion == "" && !upgradeForce {
+		fmt.Fprintf(out, "gmn %s is already the latest version\n", version)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	fmt.Fprintf(out, "Downloading gmn %s...\n", rel.Version())
+	binary, err := update.Download(ctx, rel)
+	if err != nil {
+		return err
+	}
+	if err := update.Replace(exe, binary); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "✓ Upgraded %s from %s to %s\n", exe, version, rel.Version())
+	return nil
+}
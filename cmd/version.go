@@ -0,0 +1,96 @@
+// Version command for gmn
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	rdebug "runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	commit    = "" // Set via SetBuildInfo (ldflags)
+	buildDate = ""
+)
+
+var versionJSON bool
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show version and build information",
+	Args:  cobra.NoArgs,
+	RunE:  runVersion,
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Output machine-readable JSON")
+}
+
+// SetBuildInfo sets the commit and build date embedded at build time
+func SetBuildInfo(c, date string) {
+	commit = c
+	buildDate = date
+}
+
+// BuildInfo describes how the running binary was built
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// currentBuildInfo returns build information, falling back to the VCS
+// metadata Go embeds for `go install` builds when ldflags were not set.
+func currentBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if bi, ok := rdebug.ReadBuildInfo(); ok {
+		if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			}
+		}
+	}
+	return info
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	info := currentBuildInfo()
+	out := cmd.OutOrStdout()
+	if versionJSON {
+		return writeJSON(out, info)
+	}
+
+	fmt.Fprintf(out, "gmn %s\n", info.Version)
+	if info.Commit != "" {
+		fmt.Fprintf(out, "  commit:   %s\n", info.Commit)
+	}
+	if info.Date != "" {
+		fmt.Fprintf(out, "  built:    %s\n", info.Date)
+	}
+	fmt.Fprintf(out, "  go:       %s\n", info.GoVersion)
+	fmt.Fprintf(out, "  platform: %s\n", info.Platform)
+	return nil
+}
@@ -12,6 +12,9 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/linkalls/gmn/internal/telemetry"
 )
 
 const (
@@ -22,15 +25,17 @@ const (
 
 // Client is a Gemini API client
 type Client struct {
-	httpClient *http.Client
-	baseURL    string
+	httpClient   *http.Client
+	baseURL      string
+	stallTimeout time.Duration // Abort streams that send nothing for this long
 }
 
 // NewClient creates a new API client
 func NewClient(httpClient *http.Client) *Client {
 	return &Client{
-		httpClient: httpClient,
-		baseURL:    baseURL,
+		httpClient:   httpClient,
+		baseURL:      baseURL,
+		stallTimeout: DefaultStallTimeout,
 	}
 }
 
@@ -44,9 +49,10 @@ type GenerateRequest struct {
 
 // InnerRequest is the inner request structure for Code Assist API
 type InnerRequest struct {
-	Contents []Content        `json:"contents"`
-	Config   GenerationConfig `json:"generationConfig,omitempty"`
-	Tools    []Tool           `json:"tools,omitempty"`
+	Contents          []Content        `json:"contents"`
+	SystemInstruction *Content         `json:"systemInstruction,omitempty"`
+	Config            GenerationConfig `json:"generationConfig,omitempty"`
+	Tools             []Tool           `json:"tools,omitempty"`
 }
 
 // Content represents a message content
@@ -60,9 +66,19 @@ type Part struct {
 	Text             string        `json:"text,omitempty"`
 	FunctionCall     *FunctionCall `json:"functionCall,omitempty"`
 	FunctionResp     *FunctionResp `json:"functionResponse,omitempty"`
+	InlineData       *Blob         `json:"inlineData,omitempty"`
 	ThoughtSignature string        `json:"thoughtSignature,omitempty"` // Required for Gemini 3 Pro function calling
 }
 
+// Blob is inline binary data, such as an image, sent to the model
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // Base64-encoded
+}
+
+// ImageTokens roughly estimates what an inline image costs in tokens
+const ImageTokens = 1290
+
 // FunctionCall represents a tool call
 type FunctionCall struct {
 	ID   string                 `json:"id,omitempty"`
@@ -123,7 +139,16 @@ type UsageMetadata struct {
 }
 
 // Generate sends a non-streaming generate request
-func (c *Client) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+func (c *Client) Generate(ctx context.Context, req *GenerateRequest) (result *GenerateResponse, err error) {
+	start := time.Now()
+	defer func() {
+		var usage UsageMetadata
+		if result != nil {
+			usage = result.Response.UsageMetadata
+		}
+		telemetry.RecordRequest("generate", req.Model, start, usage.PromptTokenCount, usage.CandidatesTokenCount, err)
+	}()
+
 	endpoint := fmt.Sprintf("%s/%s:generateContent", c.baseURL, apiVersion)
 
 	body, err := json.Marshal(req)
@@ -148,12 +173,12 @@ func (c *Client) Generate(ctx context.Context, req *GenerateRequest) (*GenerateR
 		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
 	}
 
-	var result GenerateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	var decoded GenerateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return &result, nil
+	return &decoded, nil
 }
 
 // StreamEvent represents a streaming event
@@ -166,6 +191,7 @@ type StreamEvent struct {
 	ToolResult   *ToolResult    `json:"tool_result,omitempty"`
 	Usage        *UsageMetadata `json:"usage,omitempty"`
 	Error        string         `json:"error,omitempty"`
+	Err          error          `json:"-"` // The error behind Error; ErrStalled for stalled streams
 }
 
 // ToolResult represents a tool execution result
@@ -243,6 +269,7 @@ func (c *Client) LoadCodeAssist(ctx context.Context) (*LoadCodeAssistResponse, e
 
 // GenerateStream sends a streaming generate request
 func (c *Client) GenerateStream(ctx context.Context, req *GenerateRequest) (<-chan StreamEvent, error) {
+	start := time.Now()
 	endpoint := fmt.Sprintf("%s/%s:streamGenerateContent?alt=sse", c.baseURL, apiVersion)
 
 	body, err := json.Marshal(req)
@@ -250,8 +277,10 @@ func (c *Client) GenerateStream(ctx context.Context, req *GenerateRequest) (<-ch
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
@@ -259,32 +288,47 @@ func (c *Client) GenerateStream(ctx context.Context, req *GenerateRequest) (<-ch
 
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		cancel()
+		err = fmt.Errorf("failed to send request: %w", err)
+		telemetry.RecordRequest("stream", req.Model, start, 0, 0, err)
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+		cancel()
+		err := fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(bodyBytes))
+		telemetry.RecordRequest("stream", req.Model, start, 0, 0, err)
+		return nil, err
 	}
 
 	events := make(chan StreamEvent)
 
 	go func() {
 		defer close(events)
+		defer cancel()
 		defer resp.Body.Close()
 
 		// Send start event
 		events <- StreamEvent{Type: "start", Model: req.Model}
 
-		reader := bufio.NewReader(resp.Body)
+		var body io.Reader = resp.Body
+		if c.stallTimeout > 0 {
+			watchdog := newStallReader(resp.Body, c.stallTimeout, cancel)
+			defer watchdog.stop()
+			body = watchdog
+		}
+		reader := bufio.NewReader(body)
 		var usage *UsageMetadata
+		var streamErr error
 
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
 				if err != io.EOF {
-					events <- StreamEvent{Type: "error", Error: err.Error()}
+					streamErr = err
+					events <- StreamEvent{Type: "error", Error: err.Error(), Err: err}
 				}
 				break
 			}
@@ -324,6 +368,12 @@ func (c *Client) GenerateStream(ctx context.Context, req *GenerateRequest) (<-ch
 			}
 		}
 
+		if usage != nil {
+			telemetry.RecordRequest("stream", req.Model, start, usage.PromptTokenCount, usage.CandidatesTokenCount, streamErr)
+		} else {
+			telemetry.RecordRequest("stream", req.Model, start, 0, 0, streamErr)
+		}
+
 		// Send done event
 		events <- StreamEvent{Type: "done", Usage: usage}
 	}()
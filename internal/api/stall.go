@@ -0,0 +1,62 @@
+// Package api provides a client for the Gemini API.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStallTimeout is how long a stream may go without sending any data
+// before it is aborted
+const DefaultStallTimeout = 60 * time.Second
+
+// ErrStalled is reported when a stream stops sending data. The request can
+// be retried or sent to another model.
+var ErrStalled = errors.New("stalled stream")
+
+// SetStallTimeout sets how long a stream may go without data before it is
+// aborted with ErrStalled; 0 waits until the request times out
+func (c *Client) SetStallTimeout(d time.Duration) {
+	c.stallTimeout = d
+}
+
+// stallReader aborts a response body that sends no data for a while.
+// Keep-alive comments count as data, so a server sending heartbeats is
+// never considered stalled.
+type stallReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+// newStallReader watches r, calling abort if no data arrives for timeout
+func newStallReader(r io.Reader, timeout time.Duration, abort func()) *stallReader {
+	s := &stallReader{r: r, timeout: timeout}
+	s.timer = time.AfterFunc(timeout, func() {
+		s.stalled.Store(true)
+		abort()
+	})
+	return s
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 && !s.stalled.Load() {
+		s.timer.Reset(s.timeout)
+	}
+	if err != nil && s.stalled.Load() {
+		err = fmt.Errorf("%w: no data received for %s", ErrStalled, s.timeout)
+	}
+	return n, err
+}
+
+// stop disarms the watchdog once the stream is finished
+func (s *stallReader) stop() {
+	s.timer.Stop()
+}
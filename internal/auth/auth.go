@@ -51,6 +51,7 @@ func (c *Credentials) IsExpired() bool {
 // Manager handles OAuth authentication
 type Manager struct {
 	geminiDir string
+	credsFile string // security.auth.credentialsFile, if set
 }
 
 // NewManager creates a new auth manager
@@ -59,11 +60,22 @@ func NewManager() (*Manager, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Manager{geminiDir: geminiDir}, nil
+	m := &Manager{geminiDir: geminiDir}
+	if cfg, err := config.Load(); err == nil {
+		if m.credsFile, err = config.CredentialsFile(cfg); err != nil {
+			return nil, fmt.Errorf("security.auth.credentialsFile: %w", err)
+		}
+	}
+	return m, nil
 }
 
 // LoadCredentials loads OAuth credentials from file or keychain
 func (m *Manager) LoadCredentials() (*Credentials, error) {
+	// A configured file selects the account, so the keychain is skipped
+	if m.credsFile != "" {
+		return m.loadFromFile()
+	}
+
 	// Try keychain first (macOS only)
 	creds, err := m.loadFromKeychain()
 	if err == nil && creds != nil {
@@ -74,11 +86,18 @@ func (m *Manager) LoadCredentials() (*Credentials, error) {
 	return m.loadFromFile()
 }
 
-// loadFromFile reads credentials from oauth_creds.json
+// loadFromFile reads credentials from oauth_creds.json or the configured
+// credentials file
 func (m *Manager) loadFromFile() (*Credentials, error) {
 	path := filepath.Join(m.geminiDir, oauthFile)
+	if m.credsFile != "" {
+		path = m.credsFile
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
+		if os.IsNotExist(err) && m.credsFile != "" {
+			return nil, fmt.Errorf("credentials not found at %s (security.auth.credentialsFile)", path)
+		}
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("credentials not found: run 'gemini' to authenticate first")
 		}
@@ -6,8 +6,11 @@ package cli
 import (
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
+	"github.com/linkalls/gmn/internal/history"
+	"github.com/linkalls/gmn/internal/input"
 	"github.com/linkalls/gmn/internal/tools"
 	"github.com/peterh/liner"
 )
@@ -17,6 +20,9 @@ type REPLConfig struct {
 	Prompt          string
 	AvailableModels []string
 	ToolNames       []string
+	History         *history.History                            // Prompt history (nil disables history)
+	PickHistory     func([]string, string) (string, error)      // Search the history for /history (optional)
+	CommandNames    func() []string                             // Custom slash commands to complete (optional)
 	OnCommand       func(line string) (handled bool, exit bool) // Return handled=true if command, exit=true to quit
 	OnInput         func(line string)                           // Handle regular input
 	OnExit          func()                                      // Called on exit
@@ -51,9 +57,37 @@ func StartREPL(config REPLConfig) error {
 			return matches
 		}
 
+		// If starting with /run, complete tool names
+		if len(words) == 2 && words[0] == "/run" && !strings.HasSuffix(line, " ") {
+			var matches []string
+			for _, name := range config.ToolNames {
+				if strings.HasPrefix(name, lastWord) {
+					matches = append(matches, "/run "+name)
+				}
+			}
+			return matches
+		}
+
+		// @ completes the paths of files to attach
+		if strings.HasPrefix(lastWord, "@") && !strings.HasSuffix(line, " ") {
+			head := line[:len(line)-len(lastWord)]
+			var matches []string
+			for _, path := range input.CompleteMention(lastWord, "") {
+				matches = append(matches, head+path)
+			}
+			return matches
+		}
+
 		// If starting with /, complete commands
 		if strings.HasPrefix(lastWord, "/") {
-			commands := []string{"/help", "/exit", "/quit", "/clear", "/stats", "/model", "/sessions", "/save", "/load"}
+			commands := []string{"/help", "/exit", "/quit", "/clear", "/stats", "/model", "/compact", "/summary", "/reload-config", "/sessions", "/save", "/load", "/branch", "/rewind", "/search", "/run", "/last-cmd", "/memory", "/jobs", "/history"}
+			if config.CommandNames != nil {
+				for _, name := range config.CommandNames() {
+					if !slices.Contains(commands, name) {
+						commands = append(commands, name)
+					}
+				}
+			}
 			var matches []string
 			for _, cmd := range commands {
 				if strings.HasPrefix(cmd, lastWord) {
@@ -67,14 +101,15 @@ func StartREPL(config REPLConfig) error {
 		return nil
 	})
 
-	// Load history
-	if f, err := os.Open(".gmn_history"); err == nil {
-		line.ReadHistory(f)
-		f.Close()
+	// Load history; Ctrl+R searches it
+	for _, prompt := range singleLinePrompts(config.History) {
+		line.AppendHistory(prompt)
 	}
 
+	next := "" // Prompt picked with /history, to edit before sending
 	for {
-		input, err := line.Prompt(config.Prompt)
+		input, err := line.PromptWithSuggestion(config.Prompt, next, -1)
+		next = ""
 		if err != nil {
 			if err == liner.ErrPromptAborted {
 				// Ctrl+C pressed - show message and exit gracefully
@@ -96,6 +131,19 @@ func StartREPL(config REPLConfig) error {
 			continue
 		}
 
+		// /history searches past prompts; the one picked is the next input
+		if query, ok := historyQuery(line); ok && config.History != nil && config.PickHistory != nil {
+			picked, err := config.PickHistory(singleLinePrompts(config.History), query)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "History search failed:", err)
+			}
+			next = picked
+			continue
+		}
+		if config.History != nil {
+			config.History.Add(line)
+		}
+
 		// Check if it's a command
 		if config.OnCommand != nil {
 			handled, exit := config.OnCommand(line)
@@ -113,12 +161,6 @@ func StartREPL(config REPLConfig) error {
 		}
 	}
 
-	// Save history
-	if f, err := os.Create(".gmn_history"); err == nil {
-		line.WriteHistory(f)
-		f.Close()
-	}
-
 	if config.OnExit != nil {
 		config.OnExit()
 	}
@@ -130,3 +172,29 @@ func StartREPL(config REPLConfig) error {
 func GetToolNamesFromRegistry(registry *tools.Registry) []string {
 	return registry.GetToolNames()
 }
+
+// historyQuery reports whether line is the /history command, and returns
+// the search it starts with
+func historyQuery(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(line, "/history")
+	if !ok || (rest != "" && rest[0] != ' ') {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// singleLinePrompts returns the prompts of h, oldest first, leaving out
+// those of several lines typed in the TUI, which cannot be edited on one
+// line
+func singleLinePrompts(h *history.History) []string {
+	if h == nil {
+		return nil
+	}
+	var prompts []string
+	for _, prompt := range h.Prompts() {
+		if !strings.Contains(prompt, "\n") {
+			prompts = append(prompts, prompt)
+		}
+	}
+	return prompts
+}
@@ -0,0 +1,225 @@
+// Package clipboard reads and writes the system clipboard through the
+// platform's clipboard tools (pbpaste, PowerShell, wl-paste, xclip or xsel),
+// falling back to the terminal's OSC 52 escape sequence for writes over SSH.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+)
+
+// ErrEmpty is returned when the clipboard holds no text or image
+var ErrEmpty = errors.New("the clipboard is empty")
+
+// ErrUnavailable is returned when no clipboard tool is installed
+var ErrUnavailable = errors.New("no clipboard tool found (install wl-clipboard, xclip or xsel)")
+
+// Content is what the clipboard holds: text, or a PNG image
+type Content struct {
+	Text  string
+	Image []byte
+}
+
+// Read returns the clipboard content, preferring an image over text since
+// copied images often come with a text fallback such as the file name
+func Read() (*Content, error) {
+	if img, err := readImage(); err == nil && len(img) > 0 {
+		return &Content{Image: img}, nil
+	}
+	text, err := readText()
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, ErrEmpty
+	}
+	return &Content{Text: text}, nil
+}
+
+// readText returns the clipboard text
+func readText() (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return output("pbpaste")
+	case "windows":
+		text, err := output("powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw")
+		return strings.ReplaceAll(text, "\r\n", "\n"), err
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-paste"); err == nil {
+			return output("wl-paste", "--no-newline")
+		}
+	}
+	if _, err := exec.LookPath("xclip"); err == nil {
+		return output("xclip", "-selection", "clipboard", "-o")
+	}
+	if _, err := exec.LookPath("xsel"); err == nil {
+		return output("xsel", "--clipboard", "--output")
+	}
+	return "", ErrUnavailable
+}
+
+// readImage returns the clipboard image as PNG, or nothing if the
+// clipboard holds no image
+func readImage() ([]byte, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		// AppleScript prints the data as «data PNGf89504E47...»
+		out, err := output("osascript", "-e", "the clipboard as «class PNGf»")
+		if err != nil {
+			return nil, err
+		}
+		data := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(out), "«data PNGf"), "»")
+		return hex.DecodeString(data)
+	case "windows":
+		out, err := output("powershell", "-NoProfile", "-Command",
+			"Add-Type -AssemblyName System.Windows.Forms; "+
+				"$img = [System.Windows.Forms.Clipboard]::GetImage(); "+
+				"if ($img) { $ms = New-Object System.IO.MemoryStream; "+
+				"$img.Save($ms, [System.Drawing.Imaging.ImageFormat]::Png); "+
+				"[Convert]::ToBase64String($ms.ToArray()) }")
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(out))
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-paste"); err == nil {
+			types, err := output("wl-paste", "--list-types")
+			if err != nil || !hasLine(types, "image/png") {
+				return nil, err
+			}
+			return outputBytes("wl-paste", "--no-newline", "--type", "image/png")
+		}
+	}
+	if _, err := exec.LookPath("xclip"); err == nil {
+		targets, err := output("xclip", "-selection", "clipboard", "-t", "TARGETS", "-o")
+		if err != nil || !hasLine(targets, "image/png") {
+			return nil, err
+		}
+		return outputBytes("xclip", "-selection", "clipboard", "-t", "image/png", "-o")
+	}
+	return nil, nil
+}
+
+// Write puts text on the clipboard. Over SSH, or when no clipboard tool is
+// installed, the text is sent to the terminal with OSC 52 instead, which
+// most terminals (and tmux with set-clipboard on) copy to the clipboard of
+// the machine the user sits at.
+func Write(text string) error {
+	if isSSH() {
+		return writeTerminal(text)
+	}
+	err := writeText(text)
+	if errors.Is(err, ErrUnavailable) {
+		return writeTerminal(text)
+	}
+	return err
+}
+
+// writeText puts text on the clipboard with the platform's clipboard tool
+func writeText(text string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return input(text, "pbcopy")
+	case "windows":
+		return input(text, "powershell", "-NoProfile", "-Command", "[Console]::In.ReadToEnd() | Set-Clipboard")
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-copy"); err == nil {
+			return input(text, "wl-copy")
+		}
+	}
+	if _, err := exec.LookPath("xclip"); err == nil {
+		return input(text, "xclip", "-selection", "clipboard", "-in")
+	}
+	if _, err := exec.LookPath("xsel"); err == nil {
+		return input(text, "xsel", "--clipboard", "--input")
+	}
+	return ErrUnavailable
+}
+
+// isSSH reports whether gmn runs in an SSH session, where the clipboard
+// tools would copy to the remote machine's clipboard
+func isSSH() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+// writeTerminal sends text to the terminal as an OSC 52 sequence, wrapped
+// for tmux and screen so they pass it on
+func writeTerminal(text string) error {
+	var w io.Writer = os.Stderr
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		defer tty.Close()
+		w = tty
+	}
+	seq := osc52.New(text)
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		seq = seq.Screen()
+	}
+	_, err := seq.WriteTo(w)
+	return err
+}
+
+// hasLine reports whether text has a line equal to s
+func hasLine(text, s string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == s {
+			return true
+		}
+	}
+	return false
+}
+
+func output(name string, args ...string) (string, error) {
+	out, err := outputBytes(name, args...)
+	return string(out), err
+}
+
+// outputBytes runs a clipboard tool and returns its output. Errors carry
+// what the tool printed on stderr.
+func outputBytes(name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, ErrUnavailable
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(name + ": " + msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// input runs a clipboard tool with text on its standard input. The output
+// is not captured: xclip and xsel keep running in the background to own the
+// selection, and would hold the pipes open.
+func input(text, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return ErrUnavailable
+		}
+		return errors.New(name + ": " + err.Error())
+	}
+	return nil
+}
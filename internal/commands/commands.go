@@ -0,0 +1,172 @@
+// Package commands loads custom slash commands: reusable prompts defined in
+// markdown or TOML files under .gmn/commands, .gemini/commands and
+// ~/.gemini/commands.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/linkalls/gmn/internal/config"
+	"github.com/linkalls/gmn/internal/input"
+)
+
+// Dir is the directory under each settings directory that holds commands
+const Dir = "commands"
+
+// ArgsPlaceholder is replaced by the text typed after the command
+const ArgsPlaceholder = "{{args}}"
+
+// Command is a custom slash command
+type Command struct {
+	Name        string   // Without the slash; subdirectories add "dir:" prefixes
+	Description string   // Shown in /help
+	Prompt      string   // Sent to the model, with {{args}} replaced
+	Files       []string // Files or globs attached to the prompt
+	Path        string   // File the command is defined in
+	Root        string   // Directory Files are relative to ("" for the working directory)
+}
+
+// Set holds the custom commands by name
+type Set map[string]*Command
+
+// Load reads the custom commands of the user and the current project.
+// Project commands override user commands with the same name. Files that
+// cannot be read or parsed are reported in the error; the other commands
+// are still returned.
+func Load() (Set, error) {
+	geminiPath, err := config.GeminiDir()
+	if err != nil {
+		return Set{}, err
+	}
+	set := Set{}
+	var errs []error
+	for i, dir := range config.ConfigDirs(geminiPath) {
+		root := ""
+		if i > 0 {
+			// Project commands attach files relative to the project
+			root = filepath.Dir(dir)
+		}
+		errs = append(errs, set.loadDir(filepath.Join(dir, Dir), root))
+	}
+	return set, errors.Join(errs...)
+}
+
+// loadDir adds the commands defined in dir and its subdirectories
+func (s Set) loadDir(dir, root string) error {
+	var errs []error
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.IsDir() || (ext != ".md" && ext != ".toml") {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, strings.TrimSuffix(path, filepath.Ext(path)))
+		name := strings.ToLower(strings.ReplaceAll(filepath.ToSlash(rel), "/", ":"))
+		if strings.ContainsAny(name, " \t") {
+			errs = append(errs, fmt.Errorf("%s: command names cannot contain spaces", path))
+			return nil
+		}
+		cmd, err := parseFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			return nil
+		}
+		cmd.Name, cmd.Path, cmd.Root = name, path, root
+		s[name] = cmd
+		return nil
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// parseFile reads a command from a markdown or TOML file
+func parseFile(path string) (*Command, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return parseTOML(text)
+	}
+	return parseMarkdown(text)
+}
+
+// Names returns the command names with their slash, sorted
+func (s Set) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, "/"+name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sorted returns the commands sorted by name
+func (s Set) Sorted() []*Command {
+	cmds := make([]*Command, 0, len(s))
+	for _, cmd := range s {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// Match returns the command a "/name args" line invokes and its arguments
+func (s Set) Match(line string) (*Command, string, bool) {
+	if !strings.HasPrefix(line, "/") {
+		return nil, "", false
+	}
+	name, args, _ := strings.Cut(strings.TrimSpace(line[1:]), " ")
+	cmd, ok := s[strings.ToLower(name)]
+	return cmd, strings.TrimSpace(args), ok
+}
+
+// Expand returns the prompt for args and reads the attached files. Without
+// an {{args}} placeholder, arguments are appended to the prompt.
+func (c *Command) Expand(args string) (string, []input.Attachment, error) {
+	prompt := c.Prompt
+	if strings.Contains(prompt, ArgsPlaceholder) {
+		prompt = strings.ReplaceAll(prompt, ArgsPlaceholder, args)
+	} else if args != "" {
+		prompt += "\n\n" + args
+	}
+
+	var paths []string
+	for _, pattern := range c.Files {
+		if c.Root != "" && !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(c.Root, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", nil, fmt.Errorf("/%s: %w", c.Name, err)
+		}
+		if len(matches) == 0 {
+			return "", nil, fmt.Errorf("/%s: no file matches %s", c.Name, pattern)
+		}
+		paths = append(paths, matches...)
+	}
+	attachments, err := input.LoadAttachments(paths)
+	if err != nil {
+		return "", nil, fmt.Errorf("/%s: %w", c.Name, err)
+	}
+	// Name project files as the command does
+	for i := range attachments {
+		if rel, err := filepath.Rel(c.Root, attachments[i].Path); c.Root != "" && err == nil && !strings.HasPrefix(rel, "..") {
+			attachments[i].Path = rel
+		}
+	}
+	return prompt, attachments, nil
+}
@@ -0,0 +1,344 @@
+// Package commands loads custom slash commands: reusable prompts defined in
+// markdown or TOML files under .gmn/commands, .gemini/commands and
+// ~/.gemini/commands.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseMarkdown reads a markdown command: the body is the prompt, and an
+// optional front matter block sets the description and attached files:
+//
+//	---
+//	description: Review the staged changes
+//	files: [CONTRIBUTING.md, docs/style/*.md]
+//	---
+//	Review the staged diff against our guidelines. Focus on {{args}}.
+func parseMarkdown(text string) (*Command, error) {
+	cmd := &Command{}
+	body := text
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		header, after, found := strings.Cut(rest, "\n---")
+		if !found {
+			return nil, errors.New("front matter is not closed with ---")
+		}
+		body = strings.TrimPrefix(after, "\n")
+		if err := parseFrontMatter(header, cmd); err != nil {
+			return nil, err
+		}
+	}
+	cmd.Prompt = strings.TrimSpace(body)
+	if cmd.Prompt == "" {
+		return nil, errors.New("the prompt is empty")
+	}
+	return cmd, nil
+}
+
+// parseFrontMatter reads "key: value" lines. Lists are written inline in
+// brackets, comma-separated, or as "- item" lines under the key.
+func parseFrontMatter(header string, cmd *Command) error {
+	var listKey string
+	for i, line := range strings.Split(header, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && listKey != "" {
+			cmd.Files = append(cmd.Files, unquoteYAML(strings.TrimSpace(item)))
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return fmt.Errorf("front matter line %d: expected key: value", i+1)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		listKey = ""
+		switch key {
+		case "description":
+			cmd.Description = unquoteYAML(value)
+		case "files":
+			if value == "" {
+				listKey = key
+				continue
+			}
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+			for _, item := range strings.Split(value, ",") {
+				if item = unquoteYAML(strings.TrimSpace(item)); item != "" {
+					cmd.Files = append(cmd.Files, item)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// unquoteYAML removes the quotes around a YAML scalar
+func unquoteYAML(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		if s[0] == '"' {
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseTOML reads a TOML command in the official Gemini CLI's format:
+//
+//	description = "Review the staged changes"
+//	files = ["CONTRIBUTING.md"]
+//	prompt = """
+//	Review the staged diff. Focus on {{args}}.
+//	"""
+//
+// Only the string and string array values commands use are supported.
+func parseTOML(text string) (*Command, error) {
+	p := &tomlParser{text: text, line: 1}
+	cmd := &Command{}
+	for {
+		p.skipSpace(true)
+		if p.done() {
+			break
+		}
+		if p.peek() == '[' {
+			return nil, p.errorf("tables are not supported")
+		}
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		if !p.consume("=") {
+			return nil, p.errorf("expected = after %s", key)
+		}
+		p.skipSpace(false)
+		switch key {
+		case "description":
+			cmd.Description, err = p.str()
+		case "prompt":
+			cmd.Prompt, err = p.str()
+		case "files":
+			cmd.Files, err = p.array()
+		default:
+			// Keys commands do not use
+			switch p.peek() {
+			case '"', '\'':
+				_, err = p.str()
+			case '[':
+				_, err = p.array()
+			default:
+				p.skipLine()
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		if !p.done() && p.peek() != '\n' {
+			return nil, p.errorf("unexpected text after %s", key)
+		}
+	}
+	cmd.Prompt = strings.TrimSpace(cmd.Prompt)
+	if cmd.Prompt == "" {
+		return nil, errors.New("prompt is missing")
+	}
+	return cmd, nil
+}
+
+type tomlParser struct {
+	text string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) done() bool { return p.pos >= len(p.text) }
+func (p *tomlParser) peek() byte { return p.text[p.pos] }
+
+func (p *tomlParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, a...))
+}
+
+// consume skips s if the text continues with it
+func (p *tomlParser) consume(s string) bool {
+	if !strings.HasPrefix(p.text[p.pos:], s) {
+		return false
+	}
+	p.line += strings.Count(s, "\n")
+	p.pos += len(s)
+	return true
+}
+
+// skipSpace skips blanks and comments, and newlines if newlines is set
+func (p *tomlParser) skipSpace(newlines bool) {
+	for !p.done() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newlines:
+			p.pos++
+			p.line++
+		case c == '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+// skipLine skips to the end of the line
+func (p *tomlParser) skipLine() {
+	if i := strings.IndexByte(p.text[p.pos:], '\n'); i >= 0 {
+		p.pos += i
+	} else {
+		p.pos = len(p.text)
+	}
+}
+
+func (p *tomlParser) key() (string, error) {
+	if !p.done() && (p.peek() == '"' || p.peek() == '\'') {
+		return p.str()
+	}
+	start := p.pos
+	for !p.done() {
+		c := p.peek()
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a key")
+	}
+	return p.text[start:p.pos], nil
+}
+
+// str reads a basic, literal or multi-line string
+func (p *tomlParser) str() (string, error) {
+	for _, quote := range []string{`"""`, `'''`} {
+		if !p.consume(quote) {
+			continue
+		}
+		end := strings.Index(p.text[p.pos:], quote)
+		if end < 0 {
+			return "", p.errorf("unterminated %s string", quote)
+		}
+		// Quotes right before the closing delimiter belong to the string
+		for strings.HasPrefix(p.text[p.pos+end+1:], quote) {
+			end++
+		}
+		s := p.text[p.pos : p.pos+end]
+		p.consume(s + quote)
+		s = strings.TrimPrefix(s, "\n")
+		if quote == `'''` {
+			return s, nil
+		}
+		return p.unescape(s)
+	}
+
+	if p.done() || (p.peek() != '"' && p.peek() != '\'') {
+		return "", p.errorf("expected a string")
+	}
+	quote := p.peek()
+	p.pos++
+	start := p.pos
+	for ; !p.done() && p.peek() != quote; p.pos++ {
+		if p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		if p.peek() == '\\' && quote == '"' {
+			p.pos++
+		}
+	}
+	if p.done() {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.text[start:p.pos]
+	p.pos++
+	if quote == '\'' {
+		return s, nil
+	}
+	return p.unescape(s)
+}
+
+// unescape processes the escapes of a basic string
+func (p *tomlParser) unescape(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", p.errorf("string ends with a backslash")
+		}
+		switch c := s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+n >= len(s) {
+				return "", p.errorf("invalid \\%c escape", c)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", p.errorf("invalid \\%c escape", c)
+			}
+			b.WriteRune(rune(r))
+			i += n
+		case ' ', '\t', '\n':
+			// A backslash at the end of a line joins it with the next
+			// non-blank text
+			rest := strings.TrimLeft(s[i:], " \t\r\n")
+			i = len(s) - len(rest) - 1
+		default:
+			return "", p.errorf("invalid escape \\%c", c)
+		}
+	}
+	return b.String(), nil
+}
+
+// array reads an array of strings
+func (p *tomlParser) array() ([]string, error) {
+	if !p.consume("[") {
+		return nil, p.errorf("expected an array")
+	}
+	var items []string
+	for {
+		p.skipSpace(true)
+		if p.consume("]") {
+			return items, nil
+		}
+		item, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		p.skipSpace(true)
+		if !p.consume(",") {
+			p.skipSpace(true)
+			if !p.consume("]") {
+				return nil, p.errorf("expected , or ] in array")
+			}
+			return items, nil
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Package compact shortens conversation history: it summarizes older turns
+// so long chats stay within the model's context window, and rewinds turns.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package compact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/config"
+)
+
+// Defaults used when the settings do not override them
+const (
+	DefaultThreshold = 700000 // ~70% of the 1M token Gemini context window
+	DefaultKeepTurns = 4
+	DefaultModel     = "gemini-2.5-flash"
+)
+
+// SummaryPrefix marks the user turn that carries a compaction summary
+const SummaryPrefix = "[Summary of earlier conversation]"
+
+// maxToolOutput caps how much of each tool response goes into the summary prompt
+const maxToolOutput = 2000
+
+const summaryPrompt = `Summarize the conversation below so it can replace the original turns in a
+continuing chat. Keep everything needed to carry on the work:
+- the user's goals and constraints
+- decisions made and their reasons
+- files, commands, and identifiers that were referenced or changed
+- open questions and remaining tasks
+
+Be concise and use bullet points. Do not add commentary.
+
+Conversation:
+`
+
+// Options configures compaction
+type Options struct {
+	Auto      bool   // Compact automatically when Threshold is exceeded
+	Threshold int    // Estimated history size in tokens that triggers auto compaction
+	KeepTurns int    // Number of recent user turns kept verbatim
+	Model     string // Model used to write the summary
+}
+
+// FromConfig converts the compaction settings into Options
+func FromConfig(c config.CompactionConfig) Options {
+	return Options{
+		Auto:      c.Auto,
+		Threshold: c.ThresholdTokens,
+		KeepTurns: c.KeepTurns,
+		Model:     c.Model,
+	}
+}
+
+// withDefaults fills unset fields with defaults
+func (o Options) withDefaults() Options {
+	if o.Threshold <= 0 {
+		o.Threshold = DefaultThreshold
+	}
+	if o.KeepTurns <= 0 {
+		o.KeepTurns = DefaultKeepTurns
+	}
+	if o.Model == "" {
+		o.Model = DefaultModel
+	}
+	return o
+}
+
+// ShouldCompact reports whether auto compaction should run for history
+func (o Options) ShouldCompact(history []api.Content) bool {
+	return o.Auto && EstimateTokens(history) > o.withDefaults().Threshold
+}
+
+// Result describes a completed compaction
+type Result struct {
+	Summarized   int // Number of Contents replaced by the summary
+	TokensBefore int // Estimated tokens before compaction
+	TokensAfter  int // Estimated tokens after compaction
+	Usage        *api.UsageMetadata
+}
+
+// EstimateTokens roughly estimates the token count of history (~4 bytes per token)
+func EstimateTokens(history []api.Content) int {
+	n := 0
+	for _, c := range history {
+		for _, p := range c.Parts {
+			n += len(p.Text)
+			if p.FunctionCall != nil {
+				data, _ := json.Marshal(p.FunctionCall.Args)
+				n += len(p.FunctionCall.Name) + len(data)
+			}
+			if p.FunctionResp != nil {
+				data, _ := json.Marshal(p.FunctionResp.Response)
+				n += len(p.FunctionResp.Name) + len(data)
+			}
+			if p.InlineData != nil {
+				n += api.ImageTokens * 4
+			}
+		}
+	}
+	return n / 4
+}
+
+// Split divides history into the turns to summarize and the most recent
+// keepTurns user turns. The split always lands on a user text turn so a
+// function call is never separated from its response.
+func Split(history []api.Content, keepTurns int) (older, recent []api.Content) {
+	turns := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if !isUserText(history[i]) {
+			continue
+		}
+		turns++
+		if turns == keepTurns {
+			return history[:i], history[i:]
+		}
+	}
+	return nil, history
+}
+
+// Rewind drops the last n user turns (and the replies and tool calls that
+// followed them) from history.
+func Rewind(history []api.Content, n int) ([]api.Content, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("number of turns must be positive")
+	}
+	if turns := Turns(history); turns < n {
+		return nil, fmt.Errorf("only %d turn(s) to rewind", turns)
+	}
+	older, _ := Split(history, n)
+	return older, nil
+}
+
+// Turns counts the user turns in history
+func Turns(history []api.Content) int {
+	n := 0
+	for _, c := range history {
+		if isUserText(c) {
+			n++
+		}
+	}
+	return n
+}
+
+// isUserText reports whether c is a user message (not a function response)
+func isUserText(c api.Content) bool {
+	if c.Role != "user" {
+		return false
+	}
+	for _, p := range c.Parts {
+		if p.FunctionResp != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Compact replaces older turns in history with a model-written summary.
+// The returned history starts with the summary followed by the recent turns:
+// the last KeepTurns, or as many as leave something to summarize.
+func Compact(ctx context.Context, client *api.Client, projectID string, opts Options, history []api.Content) ([]api.Content, *Result, error) {
+	opts = opts.withDefaults()
+
+	older, recent := Split(history, opts.KeepTurns)
+	// A few very large turns: keep fewer of them rather than failing on
+	// every turn until there are enough
+	for keep := opts.KeepTurns - 1; len(older) == 0 && keep > 0; keep-- {
+		older, recent = Split(history, keep)
+	}
+	if len(older) == 0 {
+		return nil, nil, fmt.Errorf("not enough history to compact: the conversation is a single turn of ~%d tokens", EstimateTokens(history))
+	}
+
+	req := &api.GenerateRequest{
+		Model:        opts.Model,
+		Project:      projectID,
+		UserPromptID: fmt.Sprintf("gmn-compact-%d", time.Now().UnixNano()),
+		Request: api.InnerRequest{
+			Contents: []api.Content{{
+				Role:  "user",
+				Parts: []api.Part{{Text: summaryPrompt + Transcript(older)}},
+			}},
+			Config: api.GenerationConfig{
+				Temperature:     0.2,
+				MaxOutputTokens: 8192,
+			},
+		},
+	}
+
+	resp, err := client.Generate(ctx, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to summarize history: %w", err)
+	}
+
+	var summary strings.Builder
+	if len(resp.Response.Candidates) > 0 {
+		for _, p := range resp.Response.Candidates[0].Content.Parts {
+			summary.WriteString(p.Text)
+		}
+	}
+	if strings.TrimSpace(summary.String()) == "" {
+		return nil, nil, fmt.Errorf("failed to summarize history: empty response")
+	}
+
+	compacted := append([]api.Content{
+		{Role: "user", Parts: []api.Part{{Text: SummaryPrefix + "\n" + strings.TrimSpace(summary.String())}}},
+		{Role: "model", Parts: []api.Part{{Text: "Understood. I'll continue from this summary."}}},
+	}, recent...)
+
+	return compacted, &Result{
+		Summarized:   len(older),
+		TokensBefore: EstimateTokens(history),
+		TokensAfter:  EstimateTokens(compacted),
+		Usage:        &resp.Response.UsageMetadata,
+	}, nil
+}
+
+// Transcript renders history as plain text for the summary prompt
+func Transcript(history []api.Content) string {
+	var b strings.Builder
+	for _, c := range history {
+		for _, p := range c.Parts {
+			switch {
+			case p.Text != "":
+				fmt.Fprintf(&b, "%s: %s\n\n", c.Role, p.Text)
+			case p.FunctionCall != nil:
+				args, _ := json.Marshal(p.FunctionCall.Args)
+				fmt.Fprintf(&b, "%s called tool %s %s\n\n", c.Role, p.FunctionCall.Name, args)
+			case p.FunctionResp != nil:
+				data, _ := json.Marshal(p.FunctionResp.Response)
+				out := string(data)
+				if len(out) > maxToolOutput {
+					out = out[:maxToolOutput] + "...(truncated)"
+				}
+				fmt.Fprintf(&b, "tool %s returned %s\n\n", p.FunctionResp.Name, out)
+			case p.InlineData != nil:
+				fmt.Fprintf(&b, "%s attached an image (%s)\n\n", c.Role, p.InlineData.MimeType)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+// Package compact shortens conversation history: it summarizes older turns
+// so long chats stay within the model's context window, and rewinds turns.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package compact
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linkalls/gmn/internal/api"
+)
+
+const fileSummaryPrompt = `Summarize the file below so the summary can stand in for the whole file as
+context for a later question. Keep:
+- what the file is for and how it is structured
+- names and signatures of its types, functions, settings, or sections
+- important values, constraints, and notes
+
+Be concise and use bullet points. Do not add commentary.
+
+File: %s
+
+`
+
+// SummarizeFile writes a summary of an attached file that is too large to
+// send whole. An empty model uses DefaultModel.
+func SummarizeFile(ctx context.Context, client *api.Client, projectID, model, path, content string) (string, error) {
+	if model == "" {
+		model = DefaultModel
+	}
+
+	req := &api.GenerateRequest{
+		Model:        model,
+		Project:      projectID,
+		UserPromptID: fmt.Sprintf("gmn-summarize-%d", time.Now().UnixNano()),
+		Request: api.InnerRequest{
+			Contents: []api.Content{{
+				Role:  "user",
+				Parts: []api.Part{{Text: fmt.Sprintf(fileSummaryPrompt, path) + content}},
+			}},
+			Config: api.GenerationConfig{
+				Temperature:     0.2,
+				MaxOutputTokens: 4096,
+			},
+		},
+	}
+
+	resp, err := client.Generate(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize %s: %w", path, err)
+	}
+
+	var summary strings.Builder
+	if len(resp.Response.Candidates) > 0 {
+		for _, p := range resp.Response.Candidates[0].Content.Parts {
+			summary.WriteString(p.Text)
+		}
+	}
+	if strings.TrimSpace(summary.String()) == "" {
+		return "", fmt.Errorf("failed to summarize %s: empty response", path)
+	}
+	return strings.TrimSpace(summary.String()), nil
+}
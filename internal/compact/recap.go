@@ -0,0 +1,106 @@
+// Package compact shortens conversation history: it summarizes older turns
+// so long chats stay within the model's context window, and rewinds turns.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package compact
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/linkalls/gmn/internal/api"
+)
+
+// NotesFile is the workspace file /summary save appends recaps to
+const NotesFile = "NOTES.md"
+
+// maxRecapTranscript caps the conversation sent for a recap; the most recent
+// part is kept
+const maxRecapTranscript = 400000
+
+const recapPrompt = `Write a short recap of the conversation below for someone picking the work
+back up. Use these sections, as markdown bullet points, and skip a section
+if there is nothing for it:
+
+**Decisions** — what was decided and why
+**Files touched** — files that were read, created or changed
+**Open TODOs** — what is left to do or still unclear
+
+Keep it under 200 words. Do not add commentary.
+
+Conversation:
+`
+
+// Recap asks a cheap model for a short recap of history (decisions, files
+// touched, open TODOs). Unlike Compact it leaves history unchanged. An
+// empty model uses DefaultModel.
+func Recap(ctx context.Context, client *api.Client, projectID, model string, history []api.Content) (string, *api.UsageMetadata, error) {
+	if Turns(history) == 0 {
+		return "", nil, fmt.Errorf("nothing to summarize yet")
+	}
+	if model == "" {
+		model = DefaultModel
+	}
+
+	transcript := Transcript(history)
+	if len(transcript) > maxRecapTranscript {
+		transcript = "...(earlier conversation omitted)\n\n" + transcript[len(transcript)-maxRecapTranscript:]
+	}
+
+	req := &api.GenerateRequest{
+		Model:        model,
+		Project:      projectID,
+		UserPromptID: fmt.Sprintf("gmn-recap-%d", time.Now().UnixNano()),
+		Request: api.InnerRequest{
+			Contents: []api.Content{{
+				Role:  "user",
+				Parts: []api.Part{{Text: recapPrompt + transcript}},
+			}},
+			Config: api.GenerationConfig{
+				Temperature:     0.2,
+				MaxOutputTokens: 2048,
+			},
+		},
+	}
+
+	resp, err := client.Generate(ctx, req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to summarize session: %w", err)
+	}
+
+	var recap strings.Builder
+	if len(resp.Response.Candidates) > 0 {
+		for _, p := range resp.Response.Candidates[0].Content.Parts {
+			recap.WriteString(p.Text)
+		}
+	}
+	if strings.TrimSpace(recap.String()) == "" {
+		return "", nil, fmt.Errorf("failed to summarize session: empty response")
+	}
+	return strings.TrimSpace(recap.String()), &resp.Response.UsageMetadata, nil
+}
+
+// SaveRecap appends a recap to NOTES.md in dir under a dated heading and
+// returns the file's path
+func SaveRecap(dir, recap string) (string, error) {
+	path := filepath.Join(dir, NotesFile)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to save recap: %w", err)
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "## Session recap (%s)\n\n%s\n", time.Now().Format("2006-01-02 15:04"), recap)
+	if _, err := f.WriteString(b.String()); err != nil {
+		return "", fmt.Errorf("failed to save recap: %w", err)
+	}
+	return path, nil
+}
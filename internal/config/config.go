@@ -7,21 +7,46 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
 )
 
 const (
 	geminiDir    = ".gemini"
+	gmnDir       = ".gmn" // Project settings read only by gmn
 	settingsFile = "settings.json"
 )
 
 // Config is the main configuration structure
 type Config struct {
-	Security   SecurityConfig             `json:"security"`
-	MCPServers map[string]MCPServerConfig `json:"mcpServers"`
-	General    GeneralConfig              `json:"general"`
-	Output     OutputConfig               `json:"output"`
+	Security      SecurityConfig             `json:"security"`
+	Model         ModelConfig                `json:"model"`
+	MCPServers    map[string]MCPServerConfig `json:"mcpServers"`
+	MCP           MCPConfig                  `json:"mcp"`
+	General       GeneralConfig              `json:"general"`
+	Output        OutputConfig               `json:"output"`
+	Compaction    CompactionConfig           `json:"compaction"`
+	Attachments   AttachmentsConfig          `json:"attachments"`
+	PromptGuard   PromptGuardConfig          `json:"promptGuard"`
+	Tools         ToolsConfig                `json:"tools"`
+	Sessions      SessionsConfig             `json:"sessions"`
+	Paths         PathsConfig                `json:"paths"`
+	Telemetry     TelemetryConfig            `json:"telemetry"`
+	UI            UIConfig                   `json:"ui"`
+	Notifications NotificationsConfig        `json:"notifications"`
+
+	// Profile is the active profile; Profiles are named sets of settings
+	// applied over all the others (see SetProfile)
+	Profile  string                     `json:"profile,omitempty"`
+	Profiles map[string]json.RawMessage `json:"profiles,omitempty"`
+
+	// Untrusted lists the keys of the settings an untrusted project set to
+	// let the tools do more without asking, which were left out
+	Untrusted []string `json:"-"`
 }
 
 // SecurityConfig holds security-related settings
@@ -32,6 +57,12 @@ type SecurityConfig struct {
 // AuthConfig holds authentication settings
 type AuthConfig struct {
 	SelectedType string `json:"selectedType"`
+	// CredentialsFile is an OAuth credentials file to use instead of
+	// ~/.gemini/oauth_creds.json, e.g. one per account
+	CredentialsFile string `json:"credentialsFile,omitempty"`
+	// Project is the Google Cloud project to bill instead of the one Code
+	// Assist assigns
+	Project string `json:"project,omitempty"`
 }
 
 // MCPServerConfig holds MCP server configuration
@@ -46,17 +77,117 @@ type MCPServerConfig struct {
 	URL     string            `json:"url,omitempty"`
 	Type    string            `json:"type,omitempty"` // "sse" | "http"
 	Headers map[string]string `json:"headers,omitempty"`
+	OAuth   *MCPOAuthConfig   `json:"oauth,omitempty"`
 
 	// Common
 	Timeout      int      `json:"timeout,omitempty"`
-	Trust        bool     `json:"trust,omitempty"`
+	Trust        bool     `json:"trust,omitempty"`        // Run all tools without confirmation
+	TrustedTools []string `json:"trustedTools,omitempty"` // Tools that run without confirmation
+	ConfirmTools []string `json:"confirmTools,omitempty"` // Tools that always ask, even if trusted or allowed ("*" for all)
 	IncludeTools []string `json:"includeTools,omitempty"`
 	ExcludeTools []string `json:"excludeTools,omitempty"`
+
+	// Project is set on servers defined or changed by project settings,
+	// which are only started in trusted projects (see ProjectTrust)
+	Project bool `json:"-"`
+}
+
+// MCPOAuthConfig configures OAuth for a remote MCP server. Endpoints that
+// are not set are discovered from the server, and without a client ID gmn
+// registers itself dynamically.
+type MCPOAuthConfig struct {
+	Enabled          bool     `json:"enabled,omitempty"`
+	ClientID         string   `json:"clientId,omitempty"`
+	ClientSecret     string   `json:"clientSecret,omitempty"`
+	AuthorizationURL string   `json:"authorizationUrl,omitempty"`
+	TokenURL         string   `json:"tokenUrl,omitempty"`
+	Scopes           []string `json:"scopes,omitempty"`
+	RedirectURI      string   `json:"redirectUri,omitempty"` // Default http://127.0.0.1:<random port>/oauth/callback
+}
+
+// MCPConfig selects which configured MCP servers are used. The format is
+// shared with the official Gemini CLI.
+type MCPConfig struct {
+	Allowed      []string `json:"allowed,omitempty"`      // If set, only these servers are used
+	Excluded     []string `json:"excluded,omitempty"`     // Disabled servers
+	ToolCacheTTL int      `json:"toolCacheTTL,omitempty"` // Seconds servers' tools are cached (0 = 24h, negative = off)
+}
+
+// defaultToolCacheTTL is how long the tools of MCP servers are cached
+const defaultToolCacheTTL = 24 * time.Hour
+
+// CacheTTL returns how long servers' tools are cached, or 0 if caching is
+// disabled
+func (m MCPConfig) CacheTTL() time.Duration {
+	switch {
+	case m.ToolCacheTTL < 0:
+		return 0
+	case m.ToolCacheTTL == 0:
+		return defaultToolCacheTTL
+	}
+	return time.Duration(m.ToolCacheTTL) * time.Second
+}
+
+// MCPServerEnabled reports whether the named MCP server is enabled
+func (c *Config) MCPServerEnabled(name string) bool {
+	if len(c.MCP.Allowed) > 0 && !slices.Contains(c.MCP.Allowed, name) {
+		return false
+	}
+	return !slices.Contains(c.MCP.Excluded, name)
+}
+
+// ModelConfig holds model settings
+type ModelConfig struct {
+	Name        string  `json:"name,omitempty"`        // Default model; --model overrides it
+	Temperature float64 `json:"temperature,omitempty"` // Chat and prompt temperature (0 = 1.0)
+}
+
+// defaultTemperature is the sampling temperature of chats and prompts
+const defaultTemperature = 1.0
+
+// GenerationTemperature returns the temperature for chats and prompts
+func (m ModelConfig) GenerationTemperature() float64 {
+	if m.Temperature <= 0 {
+		return defaultTemperature
+	}
+	return m.Temperature
+}
+
+// UnmarshalJSON also accepts a plain model name ("model": "gemini-2.5-pro")
+func (m *ModelConfig) UnmarshalJSON(data []byte) error {
+	var name string
+	if json.Unmarshal(data, &name) == nil {
+		m.Name = name
+		return nil
+	}
+	type plain ModelConfig
+	return json.Unmarshal(data, (*plain)(m))
 }
 
 // GeneralConfig holds general settings
 type GeneralConfig struct {
 	PreviewFeatures bool `json:"previewFeatures"`
+	// SystemPrompt is added to the system instruction of every chat, e.g.
+	// a project's conventions
+	SystemPrompt string `json:"systemPrompt,omitempty"`
+	// StreamStallTimeout is how many seconds a response may go without
+	// sending data before it is aborted and retried. 0 uses the default of
+	// 60 seconds; negative waits for the request timeout.
+	StreamStallTimeout int `json:"streamStallTimeout,omitempty"`
+}
+
+// defaultStreamStallTimeout is the default stalled stream timeout
+const defaultStreamStallTimeout = 60 * time.Second
+
+// StallTimeout returns the stalled stream timeout, or 0 if disabled
+func (g GeneralConfig) StallTimeout() time.Duration {
+	switch {
+	case g.StreamStallTimeout < 0:
+		return 0
+	case g.StreamStallTimeout == 0:
+		return defaultStreamStallTimeout
+	}
+	return time.Duration(g.StreamStallTimeout) * time.Second
 }
 
 // OutputConfig holds output settings
@@ -64,6 +195,188 @@ type OutputConfig struct {
 	Format string `json:"format"`
 }
 
+// CompactionConfig holds chat history compaction settings
+type CompactionConfig struct {
+	Auto            bool   `json:"auto"`
+	ThresholdTokens int    `json:"thresholdTokens,omitempty"`
+	KeepTurns       int    `json:"keepTurns,omitempty"`
+	Model           string `json:"model,omitempty"`
+}
+
+// AttachmentsConfig holds settings for files attached with -f
+type AttachmentsConfig struct {
+	// MaxTokens is the estimated size of the attached files above which
+	// gmn asks which files to drop or summarize. 0 uses the default of
+	// 100000; negative disables the limit.
+	MaxTokens int `json:"maxTokens,omitempty"`
+}
+
+// defaultAttachmentTokens is the default attachment token budget
+const defaultAttachmentTokens = 100000
+
+// Budget returns the attachment token budget, or 0 if there is no limit
+func (a AttachmentsConfig) Budget() int {
+	switch {
+	case a.MaxTokens < 0:
+		return 0
+	case a.MaxTokens == 0:
+		return defaultAttachmentTokens
+	}
+	return a.MaxTokens
+}
+
+// PromptGuardConfig holds the limits above which a chat request is
+// confirmed before it is sent. 0 uses the default (400000 tokens, $1.00);
+// negative disables the limit.
+type PromptGuardConfig struct {
+	MaxTokens int     `json:"maxTokens,omitempty"`
+	MaxCost   float64 `json:"maxCost,omitempty"` // Estimated input cost in USD
+}
+
+// ToolsConfig holds tool settings
+type ToolsConfig struct {
+	Allowed      []string           `json:"allowed,omitempty"`      // Tools that run without confirmation
+	Disabled     []string           `json:"disabled,omitempty"`     // Tools not offered to the model (names or globs)
+	FailureNotes bool               `json:"failureNotes"`           // Tell the model which tools keep failing (off by default)
+	MaxReadBytes int64              `json:"maxReadBytes,omitempty"` // Largest file read_file reads (default 10 MB)
+	Shell        ShellConfig        `json:"shell"`
+	Sandbox      SandboxConfig      `json:"sandbox"`
+	Web          WebConfig          `json:"web"`
+	Custom       []CustomToolConfig `json:"custom,omitempty"` // Tools backed by local programs
+}
+
+// CustomToolConfig declares a tool that runs a local program. The call's
+// arguments are passed to it as a JSON object on stdin, and its stdout is
+// returned to the model.
+type CustomToolConfig struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"` // JSON schema of the arguments
+	Command     string          `json:"command"`              // Program, relative paths are resolved in the workspace
+	// Args are passed to the program; {{param}} is replaced with the value
+	// of an argument
+	Args     []string          `json:"args,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Timeout  int               `json:"timeout,omitempty"`  // Seconds (default 60)
+	ReadOnly bool              `json:"readOnly,omitempty"` // Run without confirmation (not from untrusted project settings)
+}
+
+// WebConfig restricts the hosts web_fetch and web_search may contact.
+// Entries are domains, which also match their subdomains ("example.com"),
+// subdomains only ("*.example.com"), IP addresses or CIDR ranges.
+type WebConfig struct {
+	AllowedDomains []string `json:"allowedDomains,omitempty"` // If set, only these hosts are contacted
+	BlockedDomains []string `json:"blockedDomains,omitempty"` // Never contacted
+	// BlockPrivateNetworks refuses loopback and private addresses
+	BlockPrivateNetworks bool `json:"blockPrivateNetworks,omitempty"`
+	// AllowLinkLocal permits link-local addresses and cloud metadata
+	// endpoints (169.254.169.254), which are blocked by default
+	AllowLinkLocal bool `json:"allowLinkLocal,omitempty"`
+}
+
+// SandboxConfig confines the filesystem tools to the workspace
+type SandboxConfig struct {
+	Enabled     bool     `json:"enabled"`               // Refuse paths outside the workspace
+	AllowedDirs []string `json:"allowedDirs,omitempty"` // Directories allowed besides the workspace
+}
+
+// ShellConfig holds defaults for the shell tool. The model can override
+// the directory and add variables per call.
+type ShellConfig struct {
+	Dir string            `json:"dir,omitempty"` // Working directory, relative to the workspace
+	Env map[string]string `json:"env,omitempty"` // Extra environment variables
+	// AllowedCommands run without confirmation and BlockedCommands are
+	// refused even in YOLO mode. Entries are globs or /regular expressions/.
+	AllowedCommands []string `json:"allowedCommands,omitempty"`
+	BlockedCommands []string `json:"blockedCommands,omitempty"`
+}
+
+// SessionsConfig holds session storage settings
+type SessionsConfig struct {
+	Backend   string `json:"backend,omitempty"` // "json" (default) or "sqlite"
+	Encrypt   bool   `json:"encrypt,omitempty"` // Encrypt session files at rest
+	AutoTitle bool   `json:"autoTitle"`         // Name new sessions after the first exchange
+	// AutoSaveInterval is how often (seconds) a chat is saved in addition to
+	// after every turn. 0 uses the default of 30 seconds; negative disables it.
+	AutoSaveInterval int `json:"autoSaveInterval,omitempty"`
+}
+
+// defaultAutoSaveInterval is the periodic session save interval
+const defaultAutoSaveInterval = 30 * time.Second
+
+// AutoSaveEvery returns the periodic save interval, or 0 if disabled
+func (s SessionsConfig) AutoSaveEvery() time.Duration {
+	switch {
+	case s.AutoSaveInterval < 0:
+		return 0
+	case s.AutoSaveInterval == 0:
+		return defaultAutoSaveInterval
+	}
+	return time.Duration(s.AutoSaveInterval) * time.Second
+}
+
+// TelemetryConfig holds opt-in OpenTelemetry export settings
+type TelemetryConfig struct {
+	Enabled  bool              `json:"enabled"`
+	Endpoint string            `json:"endpoint,omitempty"` // OTLP/HTTP base URL (default $OTEL_EXPORTER_OTLP_ENDPOINT or http://localhost:4318)
+	Headers  map[string]string `json:"headers,omitempty"`  // Extra headers, e.g. for collector auth
+}
+
+// NotificationsConfig holds the notifications sent when a long response
+// finishes while the terminal is in the background
+type NotificationsConfig struct {
+	Enabled bool `json:"enabled"`
+	// AfterSeconds is how long a turn has to take to be notified about
+	// (default 30)
+	AfterSeconds int `json:"afterSeconds,omitempty"`
+	// Method is "auto" (default), "terminal" (OSC 9), "desktop" or "bell"
+	Method string `json:"method,omitempty"`
+}
+
+// defaultNotifyAfter is how long a turn has to take to be notified about
+const defaultNotifyAfter = 30 * time.Second
+
+// After returns how long a turn has to take to be notified about
+func (n NotificationsConfig) After() time.Duration {
+	if n.AfterSeconds <= 0 {
+		return defaultNotifyAfter
+	}
+	return time.Duration(n.AfterSeconds) * time.Second
+}
+
+// UIConfig holds terminal display settings
+type UIConfig struct {
+	// ColorMode overrides color detection: "auto" (default), "truecolor",
+	// "256", "16" or "none"
+	ColorMode string `json:"colorMode,omitempty"`
+	// Glyphs selects the icon set: "emoji" (default), "nerdfont" or "ascii"
+	Glyphs string `json:"glyphs,omitempty"`
+	// ASCIIOnly draws the UI in ASCII alone, for consoles that garble other
+	// characters; it is on by default in the legacy Windows console
+	ASCIIOnly bool `json:"asciiOnly,omitempty"`
+	// Locale sets how dates, numbers and costs are formatted, e.g. "de-DE";
+	// by default it is taken from LC_ALL, LC_TIME or LANG
+	Locale string `json:"locale,omitempty"`
+	// Theme selects the color theme: "default" or "high-contrast"
+	Theme string `json:"theme,omitempty"`
+	// MinContrast is the least contrast ratio between text and background
+	// colors (1 to 21; default 4.5, or 7 in the high-contrast theme).
+	// Colors below it are lightened; a negative value keeps them as they are.
+	MinContrast float64 `json:"minContrast,omitempty"`
+	// Layout is the TUI panel layout, saved when it is changed in the TUI
+	Layout LayoutConfig `json:"layout,omitempty"`
+}
+
+// LayoutConfig holds the TUI panel layout
+type LayoutConfig struct {
+	HideSidebar  bool `json:"hideSidebar,omitempty"`
+	HideContext  bool `json:"hideContext,omitempty"`
+	SidebarWidth int  `json:"sidebarWidth,omitempty"` // Columns; 0 uses 28
+	ContextWidth int  `json:"contextWidth,omitempty"` // Columns; 0 uses 30
+	// Zen hides everything but the chat and the input
+	Zen bool `json:"zen,omitempty"`
+}
+
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -79,6 +392,17 @@ func DefaultConfig() *Config {
 		Output: OutputConfig{
 			Format: "text",
 		},
+		Compaction: CompactionConfig{
+			Auto: true,
+		},
+		Tools: ToolsConfig{
+			Sandbox: SandboxConfig{
+				Enabled: true,
+			},
+		},
+		Sessions: SessionsConfig{
+			AutoTitle: true,
+		},
 	}
 }
 
@@ -91,33 +415,103 @@ func GeminiDir() (string, error) {
 	return filepath.Join(home, geminiDir), nil
 }
 
-// Load loads the configuration from ~/.gemini/settings.json
+// Load loads the configuration from ~/.gemini/settings.json and the
+// project settings, with the active profile applied. MCP servers that come
+// from project settings are marked as such and cannot be trusted, and
+// their custom tools are not read-only unless the project is trusted.
 func Load() (*Config, error) {
+	cfg, err := LoadBase()
+	if err != nil {
+		return nil, err
+	}
+	if err := applyProfile(cfg); err != nil {
+		return nil, err
+	}
 	geminiPath, err := GeminiDir()
 	if err != nil {
 		return nil, err
 	}
+	markProjectSettings(cfg, filepath.Join(geminiPath, settingsFile))
+	return cfg, nil
+}
 
-	cfg := DefaultConfig()
-
-	// Load global settings
-	globalPath := filepath.Join(geminiPath, settingsFile)
-	if err := loadFile(globalPath, cfg); err != nil && !os.IsNotExist(err) {
+// LoadBase loads the settings files without applying a profile
+func LoadBase() (*Config, error) {
+	geminiPath, err := GeminiDir()
+	if err != nil {
 		return nil, err
 	}
 
-	// Load project settings (optional, overrides global)
-	cwd, err := os.Getwd()
-	if err == nil {
-		projectPath := filepath.Join(cwd, geminiDir, settingsFile)
-		if err := loadFile(projectPath, cfg); err != nil && !os.IsNotExist(err) {
-			return nil, err
+	cfg := DefaultConfig()
+
+	// Load global settings, then project settings (optional, overrides global)
+	for _, path := range SettingsPaths(geminiPath) {
+		if err := loadFile(path, cfg); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: %w", path, err)
 		}
 	}
 
 	return cfg, nil
 }
 
+// SettingsPaths returns the settings files Load reads, in override order:
+// the user settings, then the project's .gemini/settings.json and
+// .gmn/settings.json. Each file overrides the settings it sets; lists are
+// replaced and mcpServers entries are replaced by name.
+func SettingsPaths(geminiPath string) []string {
+	var paths []string
+	for _, dir := range ConfigDirs(geminiPath) {
+		paths = append(paths, filepath.Join(dir, settingsFile))
+	}
+	return paths
+}
+
+// ConfigDirs returns the directories gmn reads settings and custom
+// commands from, in override order: ~/.gemini, then the project's .gemini
+// and .gmn
+func ConfigDirs(geminiPath string) []string {
+	dirs := []string{geminiPath}
+	if dir, err := ProjectDir(); err == nil && filepath.Join(dir, geminiDir) != geminiPath {
+		dirs = append(dirs, filepath.Join(dir, geminiDir), filepath.Join(dir, gmnDir))
+	}
+	return dirs
+}
+
+// projectMarkers are the files and directories under .gmn or .gemini that
+// make a directory a project
+var projectMarkers = []string{settingsFile, "commands"}
+
+// ProjectDir returns the directory whose project settings apply: the
+// nearest one from the working directory up to the repository root that
+// has settings or custom commands in .gmn or .gemini, or else the working
+// directory. The home directory is not searched, since its .gemini holds
+// the user settings.
+func ProjectDir() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	home, _ := os.UserHomeDir()
+	for dir := cwd; dir != home; {
+		for _, name := range []string{gmnDir, geminiDir} {
+			for _, marker := range projectMarkers {
+				if _, err := os.Stat(filepath.Join(dir, name, marker)); err == nil {
+					return dir, nil
+				}
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break // The repository root
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return cwd, nil
+}
+
 func loadFile(path string, cfg *Config) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -132,14 +526,27 @@ type CachedState struct {
 	UserTier  string `json:"userTier,omitempty"`
 }
 
+// cachedStatePath returns gmn_state.json, or gmn_state.<profile>.json when
+// a profile is active, since profiles may use other accounts
+func cachedStatePath() (string, error) {
+	geminiPath, err := GeminiDir()
+	if err != nil {
+		return "", err
+	}
+	name := "gmn_state.json"
+	if cfg, err := Load(); err == nil && cfg.Profile != "" {
+		name = "gmn_state." + url.PathEscape(cfg.Profile) + ".json"
+	}
+	return filepath.Join(geminiPath, name), nil
+}
+
 // LoadCachedState loads the cached state from gmn_state.json
 func LoadCachedState() (*CachedState, error) {
-	geminiPath, err := GeminiDir()
+	path, err := cachedStatePath()
 	if err != nil {
 		return nil, err
 	}
 
-	path := filepath.Join(geminiPath, "gmn_state.json")
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -158,12 +565,11 @@ func LoadCachedState() (*CachedState, error) {
 
 // SaveCachedState saves the cached state to gmn_state.json
 func SaveCachedState(state *CachedState) error {
-	geminiPath, err := GeminiDir()
+	path, err := cachedStatePath()
 	if err != nil {
 		return err
 	}
 
-	path := filepath.Join(geminiPath, "gmn_state.json")
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
 		return err
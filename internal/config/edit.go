@@ -0,0 +1,228 @@
+// Package config provides configuration loading for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Settings file scopes for commands that edit settings
+const (
+	ScopeUser    = "user"    // ~/.gemini/settings.json
+	ScopeProject = "project" // The project's .gmn/settings.json or .gemini/settings.json
+)
+
+// SettingsPath returns the settings file for scope
+func SettingsPath(scope string) (string, error) {
+	switch scope {
+	case ScopeUser, "":
+		geminiPath, err := GeminiDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(geminiPath, settingsFile), nil
+	case ScopeProject:
+		// The project's .gmn/settings.json if it has one, which takes
+		// precedence, or else its .gemini/settings.json
+		dir, err := ProjectDir()
+		if err != nil {
+			return "", err
+		}
+		path := filepath.Join(dir, gmnDir, settingsFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		return filepath.Join(dir, geminiDir, settingsFile), nil
+	}
+	return "", fmt.Errorf("unknown scope %q (use %s or %s)", scope, ScopeUser, ScopeProject)
+}
+
+// Object is a JSON object that keeps its key order and the raw values of
+// fields gmn does not model, so edited settings files keep everything the
+// official Gemini CLI (or the user) put there.
+type Object struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+// NewObject returns an empty Object
+func NewObject() *Object {
+	return &Object{values: make(map[string]json.RawMessage)}
+}
+
+// ParseObject parses a JSON object. Empty input yields an empty Object.
+func ParseObject(data []byte) (*Object, error) {
+	o := NewObject()
+	if len(bytes.TrimSpace(data)) == 0 {
+		return o, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		o.Set(key, value)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Keys returns the keys in order
+func (o *Object) Keys() []string {
+	return append([]string(nil), o.keys...)
+}
+
+// Get returns the raw value of key
+func (o *Object) Get(key string) (json.RawMessage, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// Set sets key to a raw value, appending new keys at the end
+func (o *Object) Set(key string, value json.RawMessage) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// SetValue sets key to the JSON encoding of v
+func (o *Object) SetValue(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	o.Set(key, data)
+	return nil
+}
+
+// Delete removes key
+func (o *Object) Delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Child returns the object stored at key, or an empty one
+func (o *Object) Child(key string) (*Object, error) {
+	raw, ok := o.values[key]
+	if !ok || string(raw) == "null" {
+		return NewObject(), nil
+	}
+	child, err := ParseObject(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return child, nil
+}
+
+// SetChild stores child at key, or removes key if child is empty
+func (o *Object) SetChild(key string, child *Object) error {
+	if len(child.keys) == 0 {
+		o.Delete(key)
+		return nil
+	}
+	data, err := child.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	o.Set(key, data)
+	return nil
+}
+
+// MarshalJSON encodes the object with its keys in order
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(o.values[k])
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// UpdateSettings loads the settings file at path (if it exists), lets fn
+// modify it and writes it back atomically, keeping unknown fields and key
+// order. The previous file is kept as settings.json.bak.
+func UpdateSettings(path string, fn func(settings *Object) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	settings, err := ParseObject(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := fn(settings); err != nil {
+		return err
+	}
+
+	compact, err := settings.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if data != nil {
+		if err := os.WriteFile(path+".bak", data, 0600); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".settings-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,178 @@
+// Package config provides configuration loading for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Settings are addressed by dotted keys such as "ui.theme" or
+// "mcpServers.github.url", following their JSON names.
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// KeyType returns the Go type of the setting at key, or an error if gmn has
+// no such setting. Keys inside maps (mcpServers, env, profiles) may use any
+// name, and keys inside a profile are anything a profile can set.
+func KeyType(key string) (reflect.Type, error) {
+	parts := strings.Split(key, ".")
+	t := reflect.TypeOf(Config{})
+	for i, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("invalid setting %q", key)
+		}
+		switch {
+		case t == rawMessageType && isProfileKey(parts[:i]):
+			// A profile: the rest is a setting
+			return KeyType(strings.Join(parts[i:], "."))
+		case t.Kind() == reflect.Map:
+			t = t.Elem()
+		case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct:
+			t = t.Elem()
+			fallthrough
+		case t.Kind() == reflect.Struct:
+			field, ok := jsonField(t, part)
+			if !ok {
+				return nil, fmt.Errorf("unknown setting %q", key)
+			}
+			t = field.Type
+		default:
+			return nil, fmt.Errorf("unknown setting %q (%s is not an object)", key, strings.Join(parts[:i], "."))
+		}
+	}
+	return t, nil
+}
+
+// isProfileKey reports whether parts address a profile ("profiles.<name>")
+func isProfileKey(parts []string) bool {
+	return len(parts) == 2 && parts[0] == "profiles"
+}
+
+// jsonField returns the field of a struct type with the given JSON name
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == name && field.IsExported() {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// ParseValue turns a command-line value into JSON for the setting at key:
+// strings need no quotes, lists of strings may be comma-separated, and
+// everything else is JSON
+func ParseValue(key, value string) (json.RawMessage, error) {
+	t, err := KeyType(key)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case t.Kind() == reflect.String:
+		if !json.Valid([]byte(value)) || !strings.HasPrefix(value, `"`) {
+			return json.Marshal(value)
+		}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "["):
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return json.Marshal(list)
+	}
+
+	if !json.Valid([]byte(value)) {
+		return nil, fmt.Errorf("%s: %q is not valid JSON", key, value)
+	}
+	// Check the type now, so the error names the setting
+	v := reflect.New(t)
+	if err := json.Unmarshal([]byte(value), v.Interface()); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return json.RawMessage(value), nil
+}
+
+// SetKey sets the setting at key in a settings file, creating the objects
+// on the way, or removes it if value is nil
+func SetKey(settings *Object, key string, value json.RawMessage) error {
+	name, rest, nested := strings.Cut(key, ".")
+	if !nested {
+		if value == nil {
+			settings.Delete(name)
+			return nil
+		}
+		settings.Set(name, value)
+		return nil
+	}
+	child, err := settings.Child(name)
+	if err != nil {
+		return err
+	}
+	if err := SetKey(child, rest, value); err != nil {
+		return err
+	}
+	return settings.SetChild(name, child)
+}
+
+// GetKey returns the value of the setting at key in cfg as JSON
+func GetKey(cfg *Config, key string) (json.RawMessage, error) {
+	if _, err := KeyType(key); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	value := json.RawMessage(data)
+	for _, part := range strings.Split(key, ".") {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(value, &obj); err != nil {
+			return json.RawMessage("null"), nil
+		}
+		var ok bool
+		if value, ok = obj[part]; !ok {
+			return json.RawMessage("null"), nil
+		}
+	}
+	return value, nil
+}
+
+// Flatten lists the settings in cfg that have a value as dotted keys and
+// their JSON values, sorted by key. Lists are shown whole.
+func Flatten(cfg *Config) ([][2]string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var out [][2]string
+	var walk func(prefix string, value json.RawMessage)
+	walk = func(prefix string, value json.RawMessage) {
+		var obj map[string]json.RawMessage
+		if bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")) && json.Unmarshal(value, &obj) == nil {
+			for k, v := range obj {
+				key := k
+				if prefix != "" {
+					key = prefix + "." + k
+				}
+				walk(key, v)
+			}
+			return
+		}
+		switch string(value) {
+		case "null", `""`, "[]":
+			return
+		}
+		out = append(out, [2]string{prefix, string(value)})
+	}
+	walk("", data)
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+	return out, nil
+}
@@ -0,0 +1,146 @@
+// Package config provides configuration loading for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Environment variables that override the storage locations
+const (
+	EnvSessionsDir = "GMN_SESSIONS_DIR"
+	EnvCacheDir    = "GMN_CACHE_DIR"
+	EnvHistoryFile = "GMN_HISTORY_FILE"
+)
+
+const legacyDir = ".gmn"
+
+// PathsConfig overrides where gmn stores its files. Relative paths are
+// resolved against the working directory, so sessions can live inside a
+// project; a leading ~ expands to the home directory.
+type PathsConfig struct {
+	SessionsDir string `json:"sessionsDir,omitempty"`
+	CacheDir    string `json:"cacheDir,omitempty"`
+	HistoryFile string `json:"historyFile,omitempty"`
+}
+
+// SessionsDir returns the directory sessions are saved in:
+// $GMN_SESSIONS_DIR, then paths.sessionsDir, then <data dir>/sessions
+func SessionsDir(cfg *Config) (string, error) {
+	return resolvePath(EnvSessionsDir, cfg.Paths.SessionsDir, func() (string, error) {
+		dir, err := DataDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "sessions"), nil
+	})
+}
+
+// CacheDir returns the directory for disposable cached data:
+// $GMN_CACHE_DIR, then paths.cacheDir, then $XDG_CACHE_HOME/gmn on Linux
+func CacheDir(cfg *Config) (string, error) {
+	return resolvePath(EnvCacheDir, cfg.Paths.CacheDir, func() (string, error) {
+		if dir, ok := legacyHome(); ok {
+			return filepath.Join(dir, "cache"), nil
+		}
+		return xdgDir("XDG_CACHE_HOME", ".cache")
+	})
+}
+
+// HistoryFile returns the prompt history file:
+// $GMN_HISTORY_FILE, then paths.historyFile, then <state dir>/history
+func HistoryFile(cfg *Config) (string, error) {
+	return resolvePath(EnvHistoryFile, cfg.Paths.HistoryFile, func() (string, error) {
+		dir, err := StateDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "history"), nil
+	})
+}
+
+// DataDir returns the base directory for persistent data. This is ~/.gmn
+// when it already exists or outside Linux, and $XDG_DATA_HOME/gmn otherwise.
+func DataDir() (string, error) {
+	if dir, ok := legacyHome(); ok {
+		return dir, nil
+	}
+	return xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// StateDir returns the base directory for state such as history. This is
+// ~/.gmn when it already exists or outside Linux, and $XDG_STATE_HOME/gmn
+// otherwise.
+func StateDir() (string, error) {
+	if dir, ok := legacyHome(); ok {
+		return dir, nil
+	}
+	return xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// legacyHome returns ~/.gmn if it should be used: it already exists (so
+// existing installs keep their data) or the platform does not use XDG
+func legacyHome() (string, bool) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	dir := filepath.Join(home, legacyDir)
+	if runtime.GOOS != "linux" {
+		return dir, true
+	}
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir, true
+	}
+	return "", false
+}
+
+// xdgDir returns $<env>/gmn, or ~/<fallback>/gmn when the variable is unset.
+// Relative values are ignored as required by the XDG base directory spec.
+func xdgDir(env, fallback string) (string, error) {
+	if dir := os.Getenv(env); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, "gmn"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, fallback, "gmn"), nil
+}
+
+// resolvePath returns the environment override, then the configured path,
+// then the default
+func resolvePath(env, configured string, def func() (string, error)) (string, error) {
+	if v := os.Getenv(env); v != "" {
+		return expandPath(v)
+	}
+	if configured != "" {
+		return expandPath(configured)
+	}
+	return def()
+}
+
+// CredentialsFile returns security.auth.credentialsFile with ~ expanded, or
+// "" if it is not set
+func CredentialsFile(cfg *Config) (string, error) {
+	if cfg.Security.Auth.CredentialsFile == "" {
+		return "", nil
+	}
+	return expandPath(cfg.Security.Auth.CredentialsFile)
+}
+
+// expandPath expands a leading ~ and makes path absolute
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Abs(path)
+}
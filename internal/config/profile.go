@@ -0,0 +1,90 @@
+// Package config provides configuration loading for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvProfile selects the settings profile, overriding the profile setting
+const EnvProfile = "GMN_PROFILE"
+
+// ErrUnknownProfile is returned by Load when the selected profile is not
+// defined in any settings file
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// selectedProfile is the profile chosen with --profile
+var selectedProfile string
+
+// SetProfile selects the profile Load applies, overriding $GMN_PROFILE and
+// the profile setting. An empty name leaves the choice to them.
+func SetProfile(name string) {
+	selectedProfile = name
+}
+
+// ActiveProfile returns the name of the profile Load applies over cfg, as
+// loaded by LoadBase, or "" if none is selected
+func ActiveProfile(cfg *Config) string {
+	if selectedProfile != "" {
+		return selectedProfile
+	}
+	if name := os.Getenv(EnvProfile); name != "" {
+		return name
+	}
+	return cfg.Profile
+}
+
+// applyProfile applies the active profile over the merged settings and
+// records its name in cfg.Profile
+func applyProfile(cfg *Config) error {
+	name := ActiveProfile(cfg)
+	if name == "" {
+		return nil
+	}
+	if err := CheckProfile(cfg, name); err != nil {
+		return err
+	}
+	raw := cfg.Profiles[name]
+
+	profiles := cfg.Profiles
+	if err := json.Unmarshal(raw, cfg); err != nil {
+		return fmt.Errorf("profiles.%s: %w", name, err)
+	}
+	// Profiles cannot select or define other profiles
+	cfg.Profiles = profiles
+	cfg.Profile = name
+	return nil
+}
+
+// CheckProfile returns an ErrUnknownProfile error if cfg does not define
+// the named profile
+func CheckProfile(cfg *Config, name string) error {
+	if _, ok := cfg.Profiles[name]; !ok {
+		return fmt.Errorf("%w %q (defined: %s)", ErrUnknownProfile, name, profileList(cfg))
+	}
+	return nil
+}
+
+// ProfileNames returns the names of the defined profiles, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profileList describes the defined profiles for an error message
+func profileList(cfg *Config) string {
+	if len(cfg.Profiles) == 0 {
+		return "none"
+	}
+	return strings.Join(cfg.ProfileNames(), ", ")
+}
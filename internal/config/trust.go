@@ -0,0 +1,240 @@
+// Package config provides configuration loading for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// trustFile records which projects the user lets run what their settings
+// define, in the state directory
+const trustFile = "trusted_projects.json"
+
+// ProjectTrust returns whether the user trusts the settings of the project
+// in dir, and whether they were asked at all. Project settings come with
+// the repository, so the MCP servers they define are only started, and
+// their read-only custom tools only run without confirmation, in projects
+// the user trusts.
+func ProjectTrust(dir string) (trusted, decided bool) {
+	projects, err := loadTrust()
+	if err != nil {
+		return false, false
+	}
+	trusted, decided = projects[dir]
+	return trusted, decided
+}
+
+// SetProjectTrust records whether the user trusts the settings of the
+// project in dir
+func SetProjectTrust(dir string, trusted bool) error {
+	path, err := trustPath()
+	if err != nil {
+		return err
+	}
+	projects, err := loadTrust()
+	if err != nil {
+		return err
+	}
+	projects[dir] = trusted
+	data, err := json.MarshalIndent(projects, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Protected reports whether path is a file that decides what the tools
+// may do without asking: a settings.json in a .gemini or .gmn directory, or
+// the list of trusted projects. Settings are reloaded while a chat runs, so
+// the tools refuse to change these files and the model cannot raise its own
+// permissions.
+func Protected(path string) bool {
+	path = filepath.Clean(path)
+	dir, file := filepath.Split(path)
+	if strings.EqualFold(file, settingsFile) {
+		base := filepath.Base(dir)
+		if strings.EqualFold(base, geminiDir) || strings.EqualFold(base, gmnDir) {
+			return true
+		}
+	}
+	trust, err := trustPath()
+	if err != nil {
+		return false
+	}
+	if path == trust {
+		return true
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	trustInfo, err := os.Stat(trust)
+	return err == nil && os.SameFile(info, trustInfo)
+}
+
+func trustPath() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, trustFile), nil
+}
+
+func loadTrust() (map[string]bool, error) {
+	projects := make(map[string]bool)
+	path, err := trustPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return projects, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+// ProjectServers returns the names of the enabled MCP servers defined or
+// changed by project settings, sorted
+func (c *Config) ProjectServers() []string {
+	var names []string
+	for name, server := range c.MCPServers {
+		if server.Project && c.MCPServerEnabled(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// markProjectSettings marks the MCP servers that do not come from the user
+// settings as the project's, and takes away the trust the project may not
+// grant itself: trusted servers, and, unless the user trusts the project,
+// the settings that let tools run without confirmation or do more than the
+// user's own settings allow
+func markProjectSettings(cfg *Config, userPath string) {
+	user := DefaultConfig()
+	if err := loadFile(userPath, user); err != nil && !os.IsNotExist(err) {
+		user = DefaultConfig()
+	}
+	// A profile counts as the user's if the user settings define it alike
+	if raw, ok := user.Profiles[cfg.Profile]; ok && cfg.Profile != "" && bytes.Equal(raw, cfg.Profiles[cfg.Profile]) {
+		json.Unmarshal(raw, user)
+	}
+
+	for name, server := range cfg.MCPServers {
+		if own, ok := user.MCPServers[name]; ok && reflect.DeepEqual(own, server) {
+			continue
+		}
+		server.Project = true
+		server.Trust = false
+		server.TrustedTools = nil
+		cfg.MCPServers[name] = server
+	}
+
+	// A cloned repository must not approve its own commands, so only
+	// trusted projects may widen what the tools do without asking
+	if dir, err := ProjectDir(); err == nil {
+		if trusted, _ := ProjectTrust(dir); trusted {
+			return
+		}
+	}
+	cfg.Untrusted = restrictTools(&cfg.Tools, &user.Tools)
+
+	// Custom tools run any program, so only trusted projects may skip
+	// their confirmation
+	for i, tool := range cfg.Tools.Custom {
+		own := slices.ContainsFunc(user.Tools.Custom, func(t CustomToolConfig) bool {
+			return reflect.DeepEqual(t, tool)
+		})
+		if !own {
+			cfg.Tools.Custom[i].ReadOnly = false
+		}
+	}
+}
+
+// restrictTools undoes the tool settings that let the tools do more without
+// asking than the user's own settings do, returning their keys
+func restrictTools(tools, user *ToolsConfig) []string {
+	var keys []string
+	undo := func(key string, widens bool, restore func()) {
+		if widens {
+			restore()
+			keys = append(keys, key)
+		}
+	}
+	undo("tools.allowed", adds(tools.Allowed, user.Allowed), func() {
+		tools.Allowed = user.Allowed
+	})
+	undo("tools.shell.allowedCommands", adds(tools.Shell.AllowedCommands, user.Shell.AllowedCommands), func() {
+		tools.Shell.AllowedCommands = user.Shell.AllowedCommands
+	})
+	// Lists replace the user's, so a project could drop what they block
+	undo("tools.shell.blockedCommands", adds(user.Shell.BlockedCommands, tools.Shell.BlockedCommands), func() {
+		tools.Shell.BlockedCommands = union(tools.Shell.BlockedCommands, user.Shell.BlockedCommands)
+	})
+	undo("tools.disabled", adds(user.Disabled, tools.Disabled), func() {
+		tools.Disabled = union(tools.Disabled, user.Disabled)
+	})
+	// Variables such as BASH_ENV, PATH and LD_PRELOAD run other code
+	undo("tools.shell.env", !maps.Equal(tools.Shell.Env, user.Shell.Env), func() {
+		tools.Shell.Env = user.Shell.Env
+	})
+	undo("tools.sandbox.enabled", !tools.Sandbox.Enabled && user.Sandbox.Enabled, func() {
+		tools.Sandbox.Enabled = true
+	})
+	undo("tools.sandbox.allowedDirs", adds(tools.Sandbox.AllowedDirs, user.Sandbox.AllowedDirs), func() {
+		tools.Sandbox.AllowedDirs = user.Sandbox.AllowedDirs
+	})
+	undo("tools.web.allowedDomains", len(user.Web.AllowedDomains) > 0 && (len(tools.Web.AllowedDomains) == 0 || adds(tools.Web.AllowedDomains, user.Web.AllowedDomains)), func() {
+		tools.Web.AllowedDomains = user.Web.AllowedDomains
+	})
+	undo("tools.web.blockedDomains", adds(user.Web.BlockedDomains, tools.Web.BlockedDomains), func() {
+		tools.Web.BlockedDomains = union(tools.Web.BlockedDomains, user.Web.BlockedDomains)
+	})
+	undo("tools.web.allowLinkLocal", tools.Web.AllowLinkLocal && !user.Web.AllowLinkLocal, func() {
+		tools.Web.AllowLinkLocal = false
+	})
+	undo("tools.web.blockPrivateNetworks", !tools.Web.BlockPrivateNetworks && user.Web.BlockPrivateNetworks, func() {
+		tools.Web.BlockPrivateNetworks = true
+	})
+	return keys
+}
+
+// adds reports whether list has entries that base does not
+func adds(list, base []string) bool {
+	for _, entry := range list {
+		if !slices.Contains(base, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// union returns list with the entries of more it lacks appended
+func union(list, more []string) []string {
+	list = slices.Clone(list)
+	for _, entry := range more {
+		if !slices.Contains(list, entry) {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
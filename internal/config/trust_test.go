@@ -0,0 +1,79 @@
+// Package config provides configuration loading for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestRestrictTools(t *testing.T) {
+	user := ToolsConfig{
+		Allowed:  []string{"read_file"},
+		Disabled: []string{"web_fetch"},
+		Shell:    ShellConfig{AllowedCommands: []string{"go test*"}, BlockedCommands: []string{"npm publish*"}},
+		Sandbox:  SandboxConfig{Enabled: true},
+		Web:      WebConfig{BlockedDomains: []string{"internal.example.com"}, BlockPrivateNetworks: true},
+	}
+	tests := []struct {
+		name    string
+		project func(*ToolsConfig)
+		keys    []string
+		want    func(*ToolsConfig) // The user's settings with what the project may change
+	}{
+		{"same as the user's", func(*ToolsConfig) {}, nil, func(*ToolsConfig) {}},
+		{"narrower", func(p *ToolsConfig) {
+			p.Allowed = nil
+			p.Shell.AllowedCommands = []string{}
+			p.Shell.BlockedCommands = []string{"npm publish*", "rm *"}
+			p.Web.AllowedDomains = []string{"example.com"}
+		}, nil, func(w *ToolsConfig) {
+			w.Allowed = nil
+			w.Shell.AllowedCommands = []string{}
+			w.Shell.BlockedCommands = []string{"npm publish*", "rm *"}
+			w.Web.AllowedDomains = []string{"example.com"}
+		}},
+		{"allowed tools", func(p *ToolsConfig) { p.Allowed = []string{"read_file", "shell"} }, []string{"tools.allowed"}, func(*ToolsConfig) {}},
+		{"allowed commands", func(p *ToolsConfig) { p.Shell.AllowedCommands = []string{"*"} }, []string{"tools.shell.allowedCommands"}, func(*ToolsConfig) {}},
+		{"shell env", func(p *ToolsConfig) { p.Shell.Env = map[string]string{"BASH_ENV": "./x.sh"} }, []string{"tools.shell.env"}, func(*ToolsConfig) {}},
+		{"sandbox off", func(p *ToolsConfig) { p.Sandbox.Enabled = false }, []string{"tools.sandbox.enabled"}, func(*ToolsConfig) {}},
+		{"sandbox dirs", func(p *ToolsConfig) { p.Sandbox.AllowedDirs = []string{"/"} }, []string{"tools.sandbox.allowedDirs"}, func(*ToolsConfig) {}},
+		{"link-local", func(p *ToolsConfig) { p.Web.AllowLinkLocal = true }, []string{"tools.web.allowLinkLocal"}, func(*ToolsConfig) {}},
+		{"private networks", func(p *ToolsConfig) { p.Web.BlockPrivateNetworks = false }, []string{"tools.web.blockPrivateNetworks"}, func(*ToolsConfig) {}},
+		{"dropped blocked command", func(p *ToolsConfig) { p.Shell.BlockedCommands = []string{"rm *"} }, []string{"tools.shell.blockedCommands"}, func(w *ToolsConfig) {
+			w.Shell.BlockedCommands = []string{"rm *", "npm publish*"}
+		}},
+		{"dropped disabled tool", func(p *ToolsConfig) { p.Disabled = nil }, []string{"tools.disabled"}, func(*ToolsConfig) {}},
+		{"dropped blocked domain", func(p *ToolsConfig) { p.Web.BlockedDomains = nil }, []string{"tools.web.blockedDomains"}, func(*ToolsConfig) {}},
+		{"several", func(p *ToolsConfig) {
+			p.Allowed = []string{"*"}
+			p.Sandbox.Enabled = false
+		}, []string{"tools.allowed", "tools.sandbox.enabled"}, func(*ToolsConfig) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project, want, own := clone(user), clone(user), clone(user)
+			tt.project(&project)
+			tt.want(&want)
+			keys := restrictTools(&project, &own)
+			if !slices.Equal(keys, tt.keys) {
+				t.Errorf("keys = %q, want %q", keys, tt.keys)
+			}
+			if !reflect.DeepEqual(project, want) {
+				t.Errorf("settings = %+v, want %+v", project, want)
+			}
+		})
+	}
+}
+
+// clone copies the lists of tools, so tests can change them
+func clone(tools ToolsConfig) ToolsConfig {
+	tools.Allowed = slices.Clone(tools.Allowed)
+	tools.Disabled = slices.Clone(tools.Disabled)
+	tools.Shell.AllowedCommands = slices.Clone(tools.Shell.AllowedCommands)
+	tools.Shell.BlockedCommands = slices.Clone(tools.Shell.BlockedCommands)
+	tools.Web.BlockedDomains = slices.Clone(tools.Web.BlockedDomains)
+	return tools
+}
@@ -0,0 +1,47 @@
+// Package config provides configuration loading for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// Watcher detects changes to the settings files by polling their
+// modification times, so running sessions can reload them.
+type Watcher struct {
+	paths  []string
+	mtimes map[string]time.Time
+}
+
+// NewWatcher creates a watcher for the settings files read by Load
+func NewWatcher() (*Watcher, error) {
+	geminiPath, err := GeminiDir()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		paths:  SettingsPaths(geminiPath),
+		mtimes: make(map[string]time.Time),
+	}
+	w.Changed() // Record the initial state
+	return w, nil
+}
+
+// Changed reports whether any settings file was created, modified or
+// removed since the last call.
+func (w *Watcher) Changed() bool {
+	changed := false
+	for _, path := range w.paths {
+		var mtime time.Time
+		if info, err := os.Stat(path); err == nil {
+			mtime = info.ModTime()
+		}
+		if !mtime.Equal(w.mtimes[path]) {
+			w.mtimes[path] = mtime
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,223 @@
+// Package confirmation provides TUI-based confirmation prompts for destructive operations.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package confirmation
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/guard"
+	"github.com/linkalls/gmn/internal/locale"
+	"github.com/linkalls/gmn/internal/termcolor"
+)
+
+// LargeChoice is what to do with a request over the prompt guard limits
+type LargeChoice int
+
+const (
+	LargeSend    LargeChoice = iota // Send the request anyway
+	LargeCompact                    // Compact the history, then send
+	LargeCancel                     // Do not send the request
+)
+
+// LargeRequest describes a request over the prompt guard limits
+type LargeRequest struct {
+	Model      string
+	Breakdown  guard.Breakdown
+	Limits     guard.Limits
+	CanCompact bool // Compaction is offered (not in the middle of a turn)
+}
+
+// largeModel is the bubbletea model for the large request prompt
+type largeModel struct {
+	req      LargeRequest
+	choices  []LargeChoice
+	selected int
+	choice   LargeChoice
+	simple   bool // Answered with a line prompt, so no buttons
+}
+
+func (m largeModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m largeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "y", "Y":
+		m.choice = LargeSend
+		return m, tea.Quit
+	case "c", "C":
+		if m.req.CanCompact {
+			m.choice = LargeCompact
+			return m, tea.Quit
+		}
+	case "n", "N", "q", "esc", "ctrl+c":
+		m.choice = LargeCancel
+		return m, tea.Quit
+	case "enter":
+		m.choice = m.choices[m.selected]
+		return m, tea.Quit
+	case "tab", "right", "l":
+		m.selected = (m.selected + 1) % len(m.choices)
+	case "shift+tab", "left", "h":
+		m.selected = (m.selected + len(m.choices) - 1) % len(m.choices)
+	}
+	return m, nil
+}
+
+func (m largeModel) View() string {
+	var b strings.Builder
+
+	header := lipgloss.NewStyle().Foreground(warningColor).Bold(true).
+		Render(glyphs.Label(glyphs.Current().Warning, "Large request"))
+	b.WriteString(header)
+	b.WriteString("\n\n")
+	b.WriteString(largeFigures(m.req))
+
+	if m.simple {
+		return strings.TrimRight(b.String(), "\n")
+	}
+
+	b.WriteString("\n")
+	labels := map[LargeChoice]string{
+		LargeSend:    " [Y] Send anyway ",
+		LargeCompact: " [C] Compact first ",
+		LargeCancel:  " [N] Cancel ",
+	}
+	for i, c := range m.choices {
+		style := ocButtonStyle
+		if i == m.selected {
+			style = ocButtonActiveStyle
+		}
+		b.WriteString(style.Render(labels[c]))
+		b.WriteString(" ")
+	}
+	b.WriteString("\n")
+
+	help := "y/n • ←/→ select • enter confirm • esc cancel"
+	if m.req.CanCompact {
+		help = "y/c/n • ←/→ select • enter confirm • esc cancel"
+	} else {
+		help += " • /compact or /rewind after cancelling to shrink the conversation"
+	}
+	b.WriteString(ocHelpStyle.Render(help))
+
+	return ocContainerStyle.Render(b.String())
+}
+
+// largeFigures shows the size of a large request, the limits and what
+// takes up the room
+func largeFigures(req LargeRequest) string {
+	var b strings.Builder
+	bd := req.Breakdown
+	cost := guard.InputCost(req.Model, bd.Total())
+	total := fmt.Sprintf("~%s tokens", guard.FormatTokens(bd.Total()))
+	if cost > 0 {
+		total += fmt.Sprintf(" (~%s input on %s)", locale.Current().Cost(cost, 2), req.Model)
+	}
+	b.WriteString(ocLabelStyle.Render("Total"))
+	b.WriteString(lipgloss.NewStyle().Foreground(dangerColor).Bold(true).Render(total))
+	b.WriteString("\n")
+
+	var limits []string
+	if req.Limits.MaxTokens > 0 {
+		limits = append(limits, guard.FormatTokens(req.Limits.MaxTokens)+" tokens")
+	}
+	if req.Limits.MaxCost > 0 {
+		limits = append(limits, locale.Current().Cost(req.Limits.MaxCost, 2))
+	}
+	b.WriteString(ocLabelStyle.Render("Limit"))
+	b.WriteString(ocValueStyle.Render(strings.Join(limits, " or ")))
+	b.WriteString("\n\n")
+
+	rows := []struct {
+		label  string
+		tokens int
+	}{
+		{"History", bd.History},
+		{"Files", bd.Files},
+		{"Tools", bd.ToolResults},
+		{"System", bd.System},
+	}
+	for _, r := range rows {
+		share := 0
+		if bd.Total() > 0 {
+			share = r.tokens * 100 / bd.Total()
+		}
+		bar := lipgloss.NewStyle().Foreground(accentColor).Render(strings.Repeat("█", share/5)) +
+			lipgloss.NewStyle().Foreground(borderColor).Render(strings.Repeat("░", 20-share/5))
+		b.WriteString(ocLabelStyle.Render(r.label))
+		b.WriteString(bar)
+		b.WriteString(ocValueStyle.Render(fmt.Sprintf(" %8s  %3d%%", guard.FormatTokens(r.tokens), share)))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// LargeRequestSummary describes a request over the prompt guard limits:
+// its size, the limits and what takes up the room. Interfaces with their
+// own dialog, like the TUI, show it with their buttons.
+func LargeRequestSummary(req LargeRequest) string {
+	return strings.TrimRight(largeFigures(req), "\n")
+}
+
+// PromptLargeRequest asks what to do with a request over the prompt guard
+// limits. If YoloMode is enabled, the request is sent.
+func PromptLargeRequest(req LargeRequest) (LargeChoice, error) {
+	if YoloMode {
+		return LargeSend, nil
+	}
+
+	m := largeModel{req: req, choice: LargeCancel}
+	if req.CanCompact {
+		m.choices = []LargeChoice{LargeCompact, LargeSend, LargeCancel}
+	} else {
+		m.choices = []LargeChoice{LargeSend, LargeCancel}
+	}
+
+	if termcolor.Dumb() {
+		return promptLargeSimple(m)
+	}
+
+	finalModel, err := tea.NewProgram(m).Run()
+	if err != nil {
+		return LargeCancel, err
+	}
+	return finalModel.(largeModel).choice, nil
+}
+
+// promptLargeSimple asks about a large request with a line prompt, for
+// dumb terminals. Without input (EOF) the request is cancelled.
+func promptLargeSimple(m largeModel) (LargeChoice, error) {
+	m.simple = true
+	fmt.Fprintln(os.Stderr, m.View())
+	question := "Send anyway? [y]es, [n]o: "
+	if m.req.CanCompact {
+		question = "Send anyway? [y]es, [c]ompact first, [n]o: "
+	}
+	for {
+		answer, err := askLine(question)
+		if err != nil {
+			return LargeCancel, err
+		}
+		switch answer {
+		case "y", "yes":
+			return LargeSend, nil
+		case "c", "compact":
+			if m.req.CanCompact {
+				return LargeCompact, nil
+			}
+		case "n", "no", "":
+			return LargeCancel, nil
+		}
+	}
+}
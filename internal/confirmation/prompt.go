@@ -7,10 +7,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/termcolor"
+	"github.com/linkalls/gmn/internal/textwidth"
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
@@ -45,30 +49,54 @@ type Details struct {
 	FilePath        string
 	OriginalContent string
 	NewContent      string
+	Diff            string // Precomputed unified diff, e.g. for multi-file edits
 	Command         string
+	Dir             string   // Working directory of a shell command
+	Env             []string // Extra environment variables (KEY=VALUE)
+	Stdin           string   // Input passed to a shell command
 	URL             string
 	Args            map[string]interface{}
 }
 
-// AllowList tracks tools that have been allowed for the session
+// AllowList tracks tools that have been allowed for the session. It is
+// safe for concurrent use: tools are checked while settings are reloaded.
 type AllowList struct {
-	allowedTools map[string]bool
+	mu              sync.RWMutex
+	allowedTools    map[string]bool
+	configuredTools map[string]bool // From settings (tools.allowed), replaced on reload
 }
 
 // NewAllowList creates a new allow list
 func NewAllowList() *AllowList {
 	return &AllowList{
-		allowedTools: make(map[string]bool),
+		allowedTools:    make(map[string]bool),
+		configuredTools: make(map[string]bool),
 	}
 }
 
 // IsAllowed checks if a tool is in the allow list
 func (a *AllowList) IsAllowed(toolName string) bool {
-	return a.allowedTools[toolName]
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.allowedTools[toolName] || a.configuredTools[toolName]
+}
+
+// SetConfigured replaces the tools allowed by settings. Tools approved
+// during the session with "always allow" are kept.
+func (a *AllowList) SetConfigured(toolNames []string) {
+	configured := make(map[string]bool, len(toolNames))
+	for _, name := range toolNames {
+		configured[name] = true
+	}
+	a.mu.Lock()
+	a.configuredTools = configured
+	a.mu.Unlock()
 }
 
 // Allow adds a tool to the allow list
 func (a *AllowList) Allow(toolName string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.allowedTools[toolName] = true
 }
 
@@ -77,84 +105,124 @@ func (a *AllowList) Allow(toolName string) {
 // =============================================================================
 
 var (
-	// Colors
-	accentColor  = lipgloss.Color("#7C3AED") // Purple
-	successColor = lipgloss.Color("#10B981") // Green
-	dangerColor  = lipgloss.Color("#EF4444") // Red
-	warningColor = lipgloss.Color("#F59E0B") // Orange
-	mutedColor   = lipgloss.Color("#6B7280") // Gray
-	surfaceColor = lipgloss.Color("#1F2937") // Dark surface
-	borderColor  = lipgloss.Color("#374151") // Border
-	textColor    = lipgloss.Color("#F9FAFB") // Light text
-	dimTextColor = lipgloss.Color("#9CA3AF") // Dim text
+	// Colors, with fallbacks for 16-color terminals
+	accentColor  lipgloss.CompleteColor // Purple
+	successColor lipgloss.CompleteColor // Green
+	dangerColor  lipgloss.CompleteColor // Red
+	warningColor lipgloss.CompleteColor // Orange
+	mutedColor   lipgloss.CompleteColor // Gray
+	surfaceColor lipgloss.CompleteColor // Dark surface
+	borderColor  lipgloss.CompleteColor // Border
+	textColor    lipgloss.CompleteColor // Light text
+	dimTextColor lipgloss.CompleteColor // Dim text
+	infoColor    lipgloss.CompleteColor // Blue
+	commandColor lipgloss.CompleteColor // Light amber
+
+	// OpenCode styles
+	ocContainerStyle    lipgloss.Style
+	ocHeaderStyle       lipgloss.Style
+	ocTitleStyle        lipgloss.Style
+	ocLabelStyle        lipgloss.Style
+	ocValueStyle        lipgloss.Style
+	ocDiffBoxStyle      lipgloss.Style
+	ocDiffHeaderStyle   lipgloss.Style
+	ocAddedStyle        lipgloss.Style
+	ocRemovedStyle      lipgloss.Style
+	ocContextStyle      lipgloss.Style
+	ocButtonStyle       lipgloss.Style
+	ocButtonActiveStyle lipgloss.Style
+	ocHelpStyle         lipgloss.Style
+	ocStatusBarStyle    lipgloss.Style
+)
+
+// The prompt palette is rebuilt whenever the theme changes
+func init() {
+	termcolor.OnTheme(setStyles)
+}
+
+// setStyles builds the colors and styles of the prompts for the current
+// theme
+func setStyles() {
+	// Colors, with fallbacks for 16-color terminals
+	accentColor = termcolor.Color("#7C3AED", termcolor.Magenta)
+	successColor = termcolor.Color("#10B981", termcolor.Green)
+	dangerColor = termcolor.Color("#EF4444", termcolor.Red)
+	warningColor = termcolor.Color("#F59E0B", termcolor.Yellow)
+	mutedColor = termcolor.Color("#6B7280", termcolor.BrightBlack)
+	surfaceColor = termcolor.Background("#1F2937", termcolor.Black)
+	borderColor = termcolor.Border("#374151", termcolor.BrightBlack)
+	textColor = termcolor.Color("#F9FAFB", termcolor.BrightWhite)
+	dimTextColor = termcolor.Color("#9CA3AF", termcolor.White)
+	infoColor = termcolor.Color("#3B82F6", termcolor.Blue)
+	commandColor = termcolor.Color("#FCD34D", termcolor.BrightYellow)
 
 	// OpenCode styles
 	ocContainerStyle = lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(borderColor).
-				Padding(1, 2).
-				MarginTop(1).
-				MarginBottom(1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(borderColor).
+		Padding(1, 2).
+		MarginTop(1).
+		MarginBottom(1)
 
 	ocHeaderStyle = lipgloss.NewStyle().
-			Foreground(accentColor).
-			Bold(true).
-			MarginBottom(1)
+		Foreground(accentColor).
+		Bold(true).
+		MarginBottom(1)
 
 	ocTitleStyle = lipgloss.NewStyle().
-			Foreground(textColor).
-			Bold(true)
+		Foreground(textColor).
+		Bold(true)
 
 	ocLabelStyle = lipgloss.NewStyle().
-			Foreground(dimTextColor).
-			Width(10)
+		Foreground(dimTextColor).
+		Width(10)
 
 	ocValueStyle = lipgloss.NewStyle().
-			Foreground(textColor)
+		Foreground(textColor)
 
 	ocDiffBoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(borderColor).
-			Padding(0, 1).
-			MarginTop(1).
-			MarginBottom(1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(borderColor).
+		Padding(0, 1).
+		MarginTop(1).
+		MarginBottom(1)
 
 	ocDiffHeaderStyle = lipgloss.NewStyle().
-				Foreground(dimTextColor).
-				Bold(true).
-				MarginBottom(1)
+		Foreground(dimTextColor).
+		Bold(true).
+		MarginBottom(1)
 
 	ocAddedStyle = lipgloss.NewStyle().
-			Foreground(successColor)
+		Foreground(successColor)
 
 	ocRemovedStyle = lipgloss.NewStyle().
-			Foreground(dangerColor)
+		Foreground(dangerColor)
 
 	ocContextStyle = lipgloss.NewStyle().
-			Foreground(dimTextColor)
+		Foreground(dimTextColor)
 
 	ocButtonStyle = lipgloss.NewStyle().
-			Foreground(textColor).
-			Background(surfaceColor).
-			Padding(0, 2).
-			MarginRight(1)
+		Foreground(textColor).
+		Background(surfaceColor).
+		Padding(0, 2).
+		MarginRight(1)
 
 	ocButtonActiveStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#FFFFFF")).
-				Background(accentColor).
-				Bold(true).
-				Padding(0, 2).
-				MarginRight(1)
+		Foreground(termcolor.On("#FFFFFF", accentColor)).
+		Background(accentColor).
+		Bold(true).
+		Padding(0, 2).
+		MarginRight(1)
 
 	ocHelpStyle = lipgloss.NewStyle().
-			Foreground(dimTextColor).
-			MarginTop(1)
+		Foreground(dimTextColor).
+		MarginTop(1)
 
 	ocStatusBarStyle = lipgloss.NewStyle().
-				Foreground(dimTextColor).
-				Background(surfaceColor).
-				Padding(0, 1)
-)
+		Foreground(dimTextColor).
+		Background(surfaceColor).
+		Padding(0, 1)
+}
 
 // =============================================================================
 // Model
@@ -182,7 +250,10 @@ func initialModel(details Details) model {
 	}
 
 	// Generate diff for edit confirmations
-	if details.Type == TypeEdit && details.OriginalContent != "" && details.NewContent != "" {
+	if details.Diff != "" {
+		m.diff = styleUnifiedDiff(details.Diff)
+		m.hasDiff = true
+	} else if details.Type == TypeEdit && details.OriginalContent != "" && details.NewContent != "" {
 		m.diff = generateDiffOpenCode(details.OriginalContent, details.NewContent)
 		m.hasDiff = true
 	}
@@ -270,22 +341,23 @@ func (m model) viewOpenCode() string {
 
 	// Determine icon and color based on type
 	var icon string
-	var headerColor lipgloss.Color
+	var headerColor lipgloss.TerminalColor
+	g := glyphs.Current()
 	switch m.details.Type {
 	case TypeEdit:
-		icon = "📝"
+		icon = g.Edit
 		headerColor = accentColor
 	case TypeShell:
-		icon = "💻"
+		icon = g.Shell
 		headerColor = warningColor
 	case TypeFetch:
-		icon = "🌐"
-		headerColor = lipgloss.Color("#3B82F6") // Blue
+		icon = g.URL
+		headerColor = infoColor
 	case TypeExec:
-		icon = "⚡"
+		icon = g.Exec
 		headerColor = warningColor
 	default:
-		icon = "🔐"
+		icon = g.Lock
 		headerColor = accentColor
 	}
 
@@ -295,7 +367,7 @@ func (m model) viewOpenCode() string {
 		Bold(true).
 		MarginBottom(1)
 
-	header := headerStyle.Render(fmt.Sprintf("%s %s", icon, m.details.Title))
+	header := headerStyle.Render(glyphs.Label(icon, m.details.Title))
 	b.WriteString(header)
 	b.WriteString("\n\n")
 
@@ -314,7 +386,7 @@ func (m model) viewOpenCode() string {
 
 	if m.details.URL != "" {
 		b.WriteString(ocLabelStyle.Render("URL"))
-		urlStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#3B82F6")).Underline(true)
+		urlStyle := lipgloss.NewStyle().Foreground(infoColor).Underline(true)
 		b.WriteString(urlStyle.Render(m.details.URL))
 		b.WriteString("\n")
 	}
@@ -322,15 +394,37 @@ func (m model) viewOpenCode() string {
 	if m.details.Command != "" {
 		b.WriteString(ocLabelStyle.Render("Command"))
 		cmdStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FCD34D")).
-			Background(lipgloss.Color("#1F2937")).
+			Foreground(commandColor).
+			Background(surfaceColor).
 			Padding(0, 1)
 		b.WriteString(cmdStyle.Render(m.details.Command))
 		b.WriteString("\n")
 	}
 
+	if m.details.Dir != "" {
+		b.WriteString(ocLabelStyle.Render("Directory"))
+		b.WriteString(ocValueStyle.Render(m.details.Dir))
+		b.WriteString("\n")
+	}
+
+	for i, kv := range m.details.Env {
+		label := ""
+		if i == 0 {
+			label = "Env"
+		}
+		b.WriteString(ocLabelStyle.Render(label))
+		b.WriteString(ocValueStyle.Render(kv))
+		b.WriteString("\n")
+	}
+
+	if m.details.Stdin != "" {
+		b.WriteString(ocLabelStyle.Render("Stdin"))
+		b.WriteString(ocValueStyle.Render(stdinPreview(m.details.Stdin)))
+		b.WriteString("\n")
+	}
+
 	// Show args for shell/fetch if available
-	if m.details.Type == TypeShell || m.details.Type == TypeFetch || m.details.Type == TypeMCP {
+	if m.details.Type == TypeShell || m.details.Type == TypeFetch || m.details.Type == TypeMCP || m.details.Type == TypeExec {
 		if len(m.details.Args) > 0 {
 			b.WriteString("\n")
 			argsJSON, _ := json.MarshalIndent(m.details.Args, "", "  ")
@@ -345,8 +439,8 @@ func (m model) viewOpenCode() string {
 		}
 	}
 
-	// Diff viewport for edit confirmations
-	if m.details.Type == TypeEdit && m.ready && m.hasDiff {
+	// Diff viewport for edits and commits
+	if m.ready && m.hasDiff {
 		b.WriteString("\n")
 		diffHeader := ocDiffHeaderStyle.Render("─── Changes ───")
 		b.WriteString(diffHeader)
@@ -385,6 +479,16 @@ func (m model) viewOpenCode() string {
 	return ocContainerStyle.Render(b.String())
 }
 
+// stdinPreview shortens stdin content to its first line and size
+func stdinPreview(stdin string) string {
+	first, _, multiline := strings.Cut(stdin, "\n")
+	first = textwidth.Truncate(first, 60)
+	if multiline {
+		first += fmt.Sprintf(" (%d lines, %d bytes)", strings.Count(strings.TrimSuffix(stdin, "\n"), "\n")+1, len(stdin))
+	}
+	return first
+}
+
 // generateDiffOpenCode creates a styled diff for OpenCode theme
 func generateDiffOpenCode(original, new string) string {
 	dmp := diffmatchpatch.New()
@@ -423,19 +527,41 @@ func generateDiffOpenCode(original, new string) string {
 	return b.String()
 }
 
-// PromptConfirmation shows an interactive confirmation prompt using TUI
+// styleUnifiedDiff colors a precomputed unified diff
+func styleUnifiedDiff(diff string) string {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = ocDiffHeaderStyle.UnsetMarginBottom().Render(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = ocAddedStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = ocRemovedStyle.Render(line)
+		default:
+			lines[i] = ocContextStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PromptConfirmation shows an interactive confirmation prompt using TUI,
+// or a line prompt (PromptConfirmationSimple) on dumb terminals
 // If YoloMode is enabled, it automatically approves all operations
 func PromptConfirmation(details Details) (Outcome, error) {
 	// YOLO mode - skip all confirmations
 	if YoloMode {
 		return OutcomeProceedOnce, nil
 	}
+	if termcolor.Dumb() {
+		return PromptConfirmationSimple(details)
+	}
 
 	m := initialModel(details)
 
 	// Use alt screen only for diff views to avoid flickering for simple prompts
 	var opts []tea.ProgramOption
-	if details.Type == TypeEdit && details.OriginalContent != "" && details.NewContent != "" {
+	if details.Diff != "" || details.Type == TypeEdit && details.OriginalContent != "" && details.NewContent != "" {
 		opts = append(opts, tea.WithAltScreen())
 	}
 
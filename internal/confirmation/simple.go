@@ -0,0 +1,112 @@
+// Package confirmation provides TUI-based confirmation prompts for destructive operations.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package confirmation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// PromptConfirmationSimple asks for confirmation with a line prompt on
+// stderr instead of a full-screen program, for dumb terminals, pipes and
+// screen readers. Without input (EOF) the operation is cancelled. If
+// YoloMode is enabled, it automatically approves all operations.
+func PromptConfirmationSimple(details Details) (Outcome, error) {
+	if YoloMode {
+		return OutcomeProceedOnce, nil
+	}
+
+	fmt.Fprintln(os.Stderr, simpleDetails(details))
+	for {
+		answer, err := askLine("Proceed? [y]es, [n]o, [a]lways: ")
+		if err != nil {
+			return OutcomeCancel, err
+		}
+		switch answer {
+		case "y", "yes":
+			return OutcomeProceedOnce, nil
+		case "a", "always":
+			return OutcomeProceedAlways, nil
+		case "n", "no", "":
+			return OutcomeCancel, nil
+		}
+	}
+}
+
+// simpleDetails describes a confirmation in plain lines
+func simpleDetails(details Details) string {
+	var b strings.Builder
+	b.WriteString(details.Title + "\n")
+	row := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "  %-10s %s\n", label+":", value)
+		}
+	}
+	row("Tool", details.ToolName)
+	row("File", details.FilePath)
+	row("URL", details.URL)
+	row("Command", details.Command)
+	row("Directory", details.Dir)
+	for _, kv := range details.Env {
+		row("Env", kv)
+	}
+	if details.Stdin != "" {
+		row("Stdin", stdinPreview(details.Stdin))
+	}
+
+	switch details.Type {
+	case TypeShell, TypeFetch, TypeMCP, TypeExec:
+		if len(details.Args) > 0 {
+			args, _ := json.MarshalIndent(details.Args, "  ", "  ")
+			b.WriteString("  " + string(args) + "\n")
+		}
+	}
+
+	if m := initialModel(details); m.hasDiff {
+		b.WriteString("--- Changes ---\n")
+		b.WriteString(strings.TrimRight(m.diff, "\n") + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// askLine prints prompt on stderr and reads an answer from stdin, trimmed
+// and in lower case. Stdin is read a byte at a time so that input meant
+// for the chat after the answer stays unread. EOF answers "".
+func askLine(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(os.Stderr)
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(string(line))), nil
+}
+
+// AskYesNo asks question with a line prompt on stderr. Anything but yes,
+// including no input, is no. Unlike the other prompts, YoloMode does not
+// answer it.
+func AskYesNo(question string) (bool, error) {
+	answer, err := askLine(question + " [y/N] ")
+	if err != nil {
+		return false, err
+	}
+	return answer == "y" || answer == "yes", nil
+}
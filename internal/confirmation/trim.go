@@ -0,0 +1,200 @@
+// Package confirmation provides TUI-based confirmation prompts for destructive operations.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package confirmation
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/glyphs"
+)
+
+// TrimAction is what happens to an attached file that is over budget
+type TrimAction int
+
+const (
+	TrimKeep      TrimAction = iota // Send the file as is
+	TrimDrop                        // Leave the file out
+	TrimSummarize                   // Send a summary of the file instead
+)
+
+// SummaryTokens is the expected size of a file summary, used to estimate
+// the total before the summaries are written
+const SummaryTokens = 1000
+
+// TrimFile is an attached file offered for trimming
+type TrimFile struct {
+	Path   string
+	Tokens int
+}
+
+// estimate returns the expected size of the file after the action
+func (f TrimFile) estimate(action TrimAction) int {
+	switch action {
+	case TrimDrop:
+		return 0
+	case TrimSummarize:
+		return min(f.Tokens, SummaryTokens)
+	}
+	return f.Tokens
+}
+
+// AutoTrim marks the largest files for summarizing until the estimated
+// total fits in budget
+func AutoTrim(files []TrimFile, budget int) []TrimAction {
+	actions := make([]TrimAction, len(files))
+	total := 0
+	order := make([]int, len(files))
+	for i, f := range files {
+		total += f.Tokens
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return files[order[a]].Tokens > files[order[b]].Tokens
+	})
+	for _, i := range order {
+		if total <= budget {
+			break
+		}
+		if files[i].Tokens <= SummaryTokens {
+			continue
+		}
+		actions[i] = TrimSummarize
+		total -= files[i].Tokens - files[i].estimate(TrimSummarize)
+	}
+	return actions
+}
+
+// trimModel is the bubbletea model for the trimming prompt
+type trimModel struct {
+	files   []TrimFile
+	actions []TrimAction
+	budget  int
+	cursor  int
+	ok      bool
+}
+
+func (m trimModel) total() int {
+	total := 0
+	for i, f := range m.files {
+		total += f.estimate(m.actions[i])
+	}
+	return total
+}
+
+func (m trimModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m trimModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.files)-1 {
+			m.cursor++
+		}
+	case "d", "x":
+		m.actions[m.cursor] = toggle(m.actions[m.cursor], TrimDrop)
+	case "s":
+		m.actions[m.cursor] = toggle(m.actions[m.cursor], TrimSummarize)
+	case "a":
+		m.actions = AutoTrim(m.files, m.budget)
+	case "r":
+		m.actions = make([]TrimAction, len(m.files))
+	case "enter":
+		m.ok = true
+		return m, tea.Quit
+	case "q", "esc", "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// toggle switches between an action and keeping the file
+func toggle(current, action TrimAction) TrimAction {
+	if current == action {
+		return TrimKeep
+	}
+	return action
+}
+
+func (m trimModel) View() string {
+	var b strings.Builder
+
+	header := lipgloss.NewStyle().Foreground(warningColor).Bold(true).
+		Render(glyphs.Label(glyphs.Current().Warning, "Attached files exceed the token budget"))
+	b.WriteString(header)
+	b.WriteString("\n\n")
+
+	total := m.total()
+	totalStyle := lipgloss.NewStyle().Foreground(successColor)
+	if total > m.budget {
+		totalStyle = lipgloss.NewStyle().Foreground(dangerColor)
+	}
+	b.WriteString(ocLabelStyle.Render("Total"))
+	b.WriteString(totalStyle.Render(fmt.Sprintf("~%s tokens", formatTokens(total))))
+	b.WriteString(ocValueStyle.Render(fmt.Sprintf(" / budget %s", formatTokens(m.budget))))
+	b.WriteString("\n\n")
+
+	for i, f := range m.files {
+		cursor := "  "
+		nameStyle := ocValueStyle
+		if i == m.cursor {
+			cursor = lipgloss.NewStyle().Foreground(accentColor).Render("> ")
+			nameStyle = ocTitleStyle
+		}
+		var state string
+		switch m.actions[i] {
+		case TrimDrop:
+			state = lipgloss.NewStyle().Foreground(dangerColor).Render("drop     ")
+			nameStyle = nameStyle.Strikethrough(true).Foreground(mutedColor)
+		case TrimSummarize:
+			state = lipgloss.NewStyle().Foreground(infoColor).Render("summarize")
+		default:
+			state = lipgloss.NewStyle().Foreground(dimTextColor).Render("keep     ")
+		}
+		size := lipgloss.NewStyle().Foreground(dimTextColor).Width(10).Align(lipgloss.Right).
+			Render(formatTokens(f.Tokens))
+		b.WriteString(fmt.Sprintf("%s%s  %s  %s\n", cursor, state, size, nameStyle.Render(filepath.ToSlash(f.Path))))
+	}
+
+	b.WriteString(ocHelpStyle.Render("↑/↓ move • d drop • s summarize • a auto-summarize largest • r reset • enter send • esc cancel"))
+
+	return ocContainerStyle.Render(b.String())
+}
+
+// formatTokens renders a token count compactly (e.g. 12.3k)
+func formatTokens(n int) string {
+	if n >= 1000 {
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// PromptTrim lets the user drop or summarize attached files whose total
+// exceeds budget tokens. ok is false if the user cancelled.
+func PromptTrim(files []TrimFile, budget int) (actions []TrimAction, ok bool, err error) {
+	m := trimModel{
+		files:   files,
+		actions: make([]TrimAction, len(files)),
+		budget:  budget,
+	}
+	finalModel, err := tea.NewProgram(m).Run()
+	if err != nil {
+		return nil, false, err
+	}
+	final := finalModel.(trimModel)
+	return final.actions, final.ok, nil
+}
@@ -0,0 +1,346 @@
+// Package fence infers the language of markdown code blocks that the model
+// left untagged, from file names mentioned just before the block or from
+// the code itself, so they can still be highlighted and saved with the
+// right extension.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package fence
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// extensions maps file extensions to code fence languages. A language's
+// first extension is the one Extension returns, so it does not depend on
+// map order.
+var extensions = []struct {
+	ext, lang string
+}{
+	{".go", "go"},
+	{".py", "python"},
+	{".js", "javascript"},
+	{".mjs", "javascript"},
+	{".cjs", "javascript"},
+	{".jsx", "jsx"},
+	{".ts", "typescript"},
+	{".tsx", "tsx"},
+	{".rs", "rust"},
+	{".java", "java"},
+	{".kt", "kotlin"},
+	{".c", "c"},
+	{".h", "c"},
+	{".cpp", "cpp"},
+	{".cc", "cpp"},
+	{".hpp", "cpp"},
+	{".cs", "csharp"},
+	{".rb", "ruby"},
+	{".php", "php"},
+	{".swift", "swift"},
+	{".sh", "bash"},
+	{".bash", "bash"},
+	{".zsh", "zsh"},
+	{".ps1", "powershell"},
+	{".json", "json"},
+	{".yaml", "yaml"},
+	{".yml", "yaml"},
+	{".toml", "toml"},
+	{".xml", "xml"},
+	{".html", "html"},
+	{".css", "css"},
+	{".scss", "scss"},
+	{".sql", "sql"},
+	{".md", "markdown"},
+	{".lua", "lua"},
+	{".diff", "diff"},
+	{".patch", "diff"},
+}
+
+// fileNames maps well-known file names without extension to languages
+var fileNames = map[string]string{
+	"Dockerfile":  "dockerfile",
+	"Makefile":    "makefile",
+	"Gemfile":     "ruby",
+	"Jenkinsfile": "groovy",
+}
+
+// FromPath returns the language for a file name, or "" if it is unknown
+func FromPath(path string) string {
+	base := filepath.Base(path)
+	if lang, ok := fileNames[base]; ok {
+		return lang
+	}
+	ext := strings.ToLower(filepath.Ext(base))
+	for _, e := range extensions {
+		if e.ext == ext {
+			return e.lang
+		}
+	}
+	return ""
+}
+
+// aliases maps other names of languages to those in extensions
+var aliases = map[string]string{
+	"js":    "javascript",
+	"ts":    "typescript",
+	"sh":    "bash",
+	"shell": "bash",
+}
+
+// Extension returns a file extension (with the dot) for a language, or ""
+func Extension(lang string) string {
+	lang = strings.ToLower(lang)
+	if alias, ok := aliases[lang]; ok {
+		lang = alias
+	}
+	for _, e := range extensions {
+		if e.lang == lang {
+			return e.ext
+		}
+	}
+	return ""
+}
+
+// mentionedPath matches a file name with an extension or a well-known
+// extensionless name, e.g. "`cmd/main.go`" or "Dockerfile"
+var mentionedPath = regexp.MustCompile(`[\w./-]*\w\.[A-Za-z0-9]+\b|\b(?:Dockerfile|Makefile|Gemfile|Jenkinsfile)\b`)
+
+// FromContext returns the language of the last known file name mentioned in
+// text (usually the lines before a code block), or ""
+func FromContext(text string) string {
+	matches := mentionedPath.FindAllString(text, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		if lang := FromPath(matches[i]); lang != "" {
+			return lang
+		}
+	}
+	return ""
+}
+
+// signal is a pattern that suggests a language, with its weight
+type signal struct {
+	lang   string
+	re     *regexp.Regexp
+	weight int
+}
+
+var signals = []signal{
+	{"go", regexp.MustCompile(`(?m)^package \w+\s*$`), 5},
+	{"go", regexp.MustCompile(`(?m)^func (\(\w+ \*?\w+\) )?\w+\(`), 3},
+	{"go", regexp.MustCompile(`\w+ := `), 2},
+	{"go", regexp.MustCompile(`\bif err != nil\b|\bfmt\.\w+\(`), 3},
+
+	{"python", regexp.MustCompile(`(?m)^\s*def \w+\(.*\)\s*(->\s*[\w\[\], .]+)?:\s*$`), 4},
+	{"python", regexp.MustCompile(`(?m)^(from [\w.]+ )?import [\w., ]+$`), 2},
+	{"python", regexp.MustCompile(`(?m)^\s*(elif|except\b.*|class \w+(\(.*\))?|if .+|for .+ in .+|with .+):\s*$`), 2},
+	{"python", regexp.MustCompile(`\bprint\(|\bself\.\w+|__name__ == "__main__"`), 2},
+
+	{"rust", regexp.MustCompile(`(?m)^\s*(pub )?fn \w+(<.*>)?\(`), 4},
+	{"rust", regexp.MustCompile(`\blet mut \w+|\bimpl\b.*\{|(?m)^use \w+(::\w+)+`), 3},
+	{"rust", regexp.MustCompile(`\w+!\(|&str\b|\bOption<|\bResult<`), 2},
+
+	{"typescript", regexp.MustCompile(`(?m)^\s*(export )?(interface|type) \w+.*[={]`), 4},
+	{"typescript", regexp.MustCompile(`\w\??: (string|number|boolean|any|void|unknown)\b`), 3},
+
+	{"javascript", regexp.MustCompile(`\b(const|let|var) \w+ = `), 2},
+	{"javascript", regexp.MustCompile(`=> |\bconsole\.\w+\(|\brequire\(|\bdocument\.\w+`), 2},
+	{"javascript", regexp.MustCompile(`(?m)^\s*(export )?(async )?function\*? \w+\(`), 3},
+	{"javascript", regexp.MustCompile(`(?m)^import .+ from ['"]`), 2},
+
+	{"java", regexp.MustCompile(`\bpublic (static )?(final )?(class|void|interface)\b|System\.out\.print`), 5},
+	{"csharp", regexp.MustCompile(`(?m)^using System|\bnamespace \w+(\.\w+)*\s*\{?$|Console\.Write`), 5},
+	{"c", regexp.MustCompile(`(?m)^#include [<"]|\bprintf\(|\bint main\(`), 4},
+	{"cpp", regexp.MustCompile(`\bstd::|#include <(iostream|vector|string|memory)>|\btemplate ?<`), 6},
+	{"ruby", regexp.MustCompile(`(?m)^\s*(def \w+[?!]?(\(.*\))?|end|puts .+|require ['"].+['"])\s*$`), 2},
+	{"php", regexp.MustCompile(`<\?php|\$\w+->\w+`), 6},
+
+	{"bash", regexp.MustCompile(`(?m)^\s*(\$ )?(sudo |npm |npx |yarn |pnpm |go (build|run|test|get|install|mod) |pip3? |git |cd |ls\b|echo |export |mkdir |rm |cp |mv |curl |wget |brew |apt(-get)? |docker |kubectl |make\b|chmod |cat )`), 3},
+	{"bash", regexp.MustCompile(`(?m)^\s*(if \[|fi$|then$|done$|for \w+ in .*; do)|\$\{?\w+\}?`), 2},
+
+	{"sql", regexp.MustCompile(`(?i)\b(select .+ from|insert into|create (table|index)|update \w+ set|delete from|alter table)\b`), 6},
+	{"html", regexp.MustCompile(`(?i)<!doctype html|<(html|head|body|div|span|script|p)\b[^>]*>`), 5},
+	{"xml", regexp.MustCompile(`^<\?xml`), 8},
+	{"css", regexp.MustCompile(`(?m)^[.#]?[\w-]+(\s*[,>]?\s*[.#:]?[\w-]+)*\s*\{\s*$|^\s+[a-z-]+: [^;]+;$`), 3},
+	{"dockerfile", regexp.MustCompile(`(?m)^FROM \S+`), 4},
+	{"dockerfile", regexp.MustCompile(`(?m)^(RUN|COPY|WORKDIR|CMD|ENTRYPOINT|EXPOSE|ENV) `), 2},
+	{"diff", regexp.MustCompile(`(?m)^(diff --git |@@ -\d+(,\d+)? \+\d+(,\d+)? @@|--- a/|\+\+\+ b/)`), 6},
+	{"yaml", regexp.MustCompile(`(?m)^[\w-]+:( .+)?$`), 1},
+	{"yaml", regexp.MustCompile(`(?m)^\s+- [\w"']`), 1},
+	{"toml", regexp.MustCompile(`(?m)^\[[\w.-]+\]\s*$`), 3},
+	{"toml", regexp.MustCompile(`(?m)^[\w-]+ = `), 1},
+}
+
+// minScore is the score a language needs before the code is tagged with it
+const minScore = 3
+
+// Detect guesses the language of a code block from its content, returning
+// "" if it cannot tell
+func Detect(code string) string {
+	trimmed := strings.TrimSpace(code)
+	if trimmed == "" {
+		return ""
+	}
+
+	// Unambiguous cases first
+	if strings.HasPrefix(trimmed, "#!") {
+		first, _, _ := strings.Cut(trimmed, "\n")
+		switch {
+		case strings.Contains(first, "python"):
+			return "python"
+		case strings.Contains(first, "node"):
+			return "javascript"
+		case strings.Contains(first, "sh"):
+			return "bash"
+		}
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	scores := map[string]int{}
+	best, bestScore := "", 0
+	for _, s := range signals {
+		if n := len(s.re.FindAllStringIndex(code, 4)); n > 0 {
+			scores[s.lang] += s.weight * n
+		}
+	}
+	// TypeScript is JavaScript with types; JavaScript signals count for it
+	if scores["typescript"] > 0 {
+		scores["typescript"] += scores["javascript"]
+	}
+	// C++ code also matches the C signals
+	if scores["cpp"] > 0 {
+		scores["cpp"] += scores["c"]
+	}
+	for _, s := range signals {
+		// Iterate in declaration order so ties are stable
+		if score := scores[s.lang]; score > bestScore {
+			best, bestScore = s.lang, score
+		}
+	}
+	if bestScore < minScore {
+		return ""
+	}
+	return best
+}
+
+// Language returns the language for an untagged code block: the file named
+// in context (the text before the block) if there is one, otherwise a guess
+// from the code
+func Language(code, context string) string {
+	if lang := FromContext(lastLines(context, 2)); lang != "" {
+		return lang
+	}
+	return Detect(code)
+}
+
+// lastLines returns the last n non-empty lines of text
+func lastLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	var kept []string
+	for i := len(lines) - 1; i >= 0 && len(kept) < n; i-- {
+		if strings.TrimSpace(lines[i]) != "" {
+			kept = append([]string{lines[i]}, kept...)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// OpenFence reports whether line opens a fenced code block: three or more
+// backticks and an optional info string. Any indentation is allowed, so
+// fences nested in list items count too. It returns the indentation, the
+// backticks and the info string.
+func OpenFence(line string) (indent, ticks, info string, ok bool) {
+	rest := strings.TrimLeft(line, " \t")
+	n := len(rest) - len(strings.TrimLeft(rest, "`"))
+	info = strings.TrimSpace(rest[n:])
+	// A backtick in the info string makes it inline code, e.g. ```x```
+	if n < 3 || strings.Contains(info, "`") {
+		return "", "", "", false
+	}
+	return line[:len(line)-len(rest)], rest[:n], info, true
+}
+
+// CloseFence reports whether line closes a code block opened with ticks:
+// at least as many backticks and nothing else
+func CloseFence(line, ticks string) bool {
+	rest := strings.TrimSpace(line)
+	return len(rest) >= len(ticks) && strings.Trim(rest, "`") == ""
+}
+
+// closing returns the line of the fence closing the block opened at line
+// start, or len(lines) if it is unclosed
+func closing(lines []string, start int, ticks string) int {
+	end := start + 1
+	for end < len(lines) && !CloseFence(lines[end], ticks) {
+		end++
+	}
+	return end
+}
+
+// dedent removes the indentation of the opening fence from the lines of
+// its code, as far as they have it
+func dedent(lines []string, indent string) string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		n := 0
+		for n < len(indent) && n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+			n++
+		}
+		out[i] = line[n:]
+	}
+	return strings.Join(out, "\n")
+}
+
+// Tag adds inferred languages to the untagged code fences of markdown
+func Tag(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	for i := 0; i < len(lines); i++ {
+		indent, ticks, info, ok := OpenFence(lines[i])
+		if !ok {
+			continue
+		}
+		end := closing(lines, i, ticks)
+		if info == "" {
+			code := dedent(lines[i+1:min(end, len(lines))], indent)
+			if lang := Language(code, strings.Join(lines[:i], "\n")); lang != "" {
+				lines[i] = indent + ticks + lang
+			}
+		}
+		i = end
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Block is a fenced code block of markdown
+type Block struct {
+	Lang   string // Tagged or inferred language, "" if unknown
+	Code   string // Without the fence's indentation
+	Indent string // Indentation of the opening fence
+	Start  int    // Line of the opening fence
+	End    int    // Line of the closing fence, the line count if unclosed
+}
+
+// Blocks returns the fenced code blocks of markdown in order, with
+// languages inferred for untagged blocks. An unclosed block at the end
+// counts as a block.
+func Blocks(markdown string) []Block {
+	var blocks []Block
+	lines := strings.Split(markdown, "\n")
+	for i := 0; i < len(lines); i++ {
+		indent, ticks, lang, ok := OpenFence(lines[i])
+		if !ok {
+			continue
+		}
+		end := closing(lines, i, ticks)
+		code := dedent(lines[i+1:min(end, len(lines))], indent)
+		if lang == "" {
+			lang = Language(code, strings.Join(lines[:i], "\n"))
+		}
+		blocks = append(blocks, Block{Lang: lang, Code: code, Indent: indent, Start: i, End: end})
+		i = end
+	}
+	return blocks
+}
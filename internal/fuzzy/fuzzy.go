@@ -0,0 +1,53 @@
+// Package fuzzy matches typed queries against text the way fzf does: the
+// letters of the query have to appear in the text in order, and matches
+// where they follow each other or start words rank higher.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Match reports whether the letters of query appear in text in order,
+// ignoring case, and scores the match: letters that follow each other or
+// start words count more. An empty query matches everything with score 0.
+func Match(query, text string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, true
+	}
+	score, n := 0, 0
+	prevMatched := false
+	prev := ' '
+	for _, r := range strings.ToLower(text) {
+		if n < len(q) && r == q[n] {
+			score++
+			if prevMatched {
+				score += 4
+			}
+			if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+				score += 3
+			}
+			n++
+			prevMatched = true
+		} else {
+			prevMatched = false
+		}
+		prev = r
+	}
+	if n < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+// Score is Match with shorter texts winning ties
+func Score(query, text string) (int, bool) {
+	score, ok := Match(query, text)
+	if !ok {
+		return 0, false
+	}
+	return score*100 - len(text), true
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+// Legacy console stub for non-Windows platforms
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package glyphs
+
+// LegacyConsole reports whether gmn runs in the classic Windows console;
+// terminals elsewhere show Unicode
+func LegacyConsole() bool {
+	return false
+}
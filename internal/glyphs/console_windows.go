@@ -0,0 +1,32 @@
+//go:build windows
+
+// Detection of the legacy Windows console
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package glyphs
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// cpUTF8 is the UTF-8 code page
+const cpUTF8 = 65001
+
+// LegacyConsole reports whether gmn runs in the classic Windows console
+// with a code page other than UTF-8, where box drawing, arrows and emoji
+// come out as mojibake. Windows Terminal, VS Code, ConEmu and mintty show
+// them, and say so in the environment.
+func LegacyConsole() bool {
+	for _, env := range []string{"WT_SESSION", "TERM_PROGRAM", "TERM"} {
+		if os.Getenv(env) != "" {
+			return false
+		}
+	}
+	if os.Getenv("ConEmuANSI") == "ON" {
+		return false
+	}
+	cp, err := windows.GetConsoleOutputCP()
+	return err == nil && cp != cpUTF8
+}
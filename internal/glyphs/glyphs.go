@@ -0,0 +1,231 @@
+// Package glyphs provides the icons used across the UI in emoji, Nerd Font
+// and plain ASCII variants, for fonts that lack emoji or render them at the
+// wrong width.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package glyphs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Values of the ui.glyphs setting
+const (
+	ModeEmoji    = "emoji"
+	ModeNerdFont = "nerdfont"
+	ModeASCII    = "ascii"
+)
+
+// Set is a set of UI icons. An empty icon means the label stands alone.
+type Set struct {
+	// Header and sidebar
+	Logo     string
+	Model    string
+	Yolo     string
+	Folder   string
+	Sessions string
+
+	// Context panel
+	Context   string
+	Tasks     string
+	Jobs      string
+	Activity  string
+	API       string
+	Tool      string
+	File      string
+	Search    string
+	Shell     string
+	Thinking  string
+	URL       string
+	Clipboard string
+
+	// Confirmations and previews
+	Edit     string
+	Exec     string
+	Lock     string
+	Warning  string
+	Question string
+	Upload   string
+	Image    string
+	Stats    string
+
+	// FileIcons maps lowercase file extensions to icons (default File)
+	FileIcons map[string]string
+}
+
+// Emoji is the default set
+var Emoji = Set{
+	Logo:      "✨",
+	Model:     "🤖",
+	Yolo:      "⚡",
+	Folder:    "📁",
+	Sessions:  "📋",
+	Context:   "📂",
+	Tasks:     "📋",
+	Jobs:      "🔧",
+	Activity:  "⚡",
+	API:       "🔮",
+	Tool:      "⚙️",
+	File:      "📄",
+	Search:    "🔍",
+	Shell:     "💻",
+	Thinking:  "💭",
+	URL:       "🌐",
+	Clipboard: "📋",
+	Edit:      "📝",
+	Exec:      "⚡",
+	Lock:      "🔐",
+	Warning:   "⚠️",
+	Question:  "❓",
+	Upload:    "📤",
+	Image:     "🖼",
+	Stats:     "📊",
+	FileIcons: map[string]string{
+		".go": "🔵", ".py": "🐍", ".js": "🟨", ".ts": "🟨", ".rs": "🦀", ".md": "📝",
+		".json": "📋", ".yaml": "📋", ".yml": "📋", ".html": "🌐", ".css": "🌐",
+		".sh": "💻", ".bash": "💻",
+	},
+}
+
+// NerdFont uses Font Awesome and Devicons glyphs from a patched Nerd Font,
+// which are single width
+var NerdFont = Set{
+	Logo:      "\uf0d0", // nf-fa-magic
+	Model:     "\uf2db", // nf-fa-microchip
+	Yolo:      "\uf0e7", // nf-fa-bolt
+	Folder:    "\uf07b", // nf-fa-folder
+	Sessions:  "\uf03a", // nf-fa-list
+	Context:   "\uf07c", // nf-fa-folder_open
+	Tasks:     "\uf0ae", // nf-fa-tasks
+	Jobs:      "\uf0ad", // nf-fa-wrench
+	Activity:  "\uf0e7", // nf-fa-bolt
+	API:       "\uf0c2", // nf-fa-cloud
+	Tool:      "\uf013", // nf-fa-cog
+	File:      "\uf15b", // nf-fa-file
+	Search:    "\uf002", // nf-fa-search
+	Shell:     "\uf120", // nf-fa-terminal
+	Thinking:  "\uf0eb", // nf-fa-lightbulb_o
+	URL:       "\uf0ac", // nf-fa-globe
+	Clipboard: "\uf0ea", // nf-fa-clipboard
+	Edit:      "\uf040", // nf-fa-pencil
+	Exec:      "\uf0e7", // nf-fa-bolt
+	Lock:      "\uf023", // nf-fa-lock
+	Warning:   "\uf071", // nf-fa-exclamation_triangle
+	Question:  "\uf059", // nf-fa-question_circle
+	Upload:    "\uf093", // nf-fa-upload
+	Image:     "\uf03e", // nf-fa-picture_o
+	Stats:     "\uf080", // nf-fa-bar_chart
+	FileIcons: map[string]string{
+		".go": "\ue626", ".py": "\ue73c", ".js": "\ue74e", ".ts": "\ue628", ".rs": "\ue7a8",
+		".md": "\ue73e", ".json": "\ue60b", ".yaml": "\ue60b", ".yml": "\ue60b",
+		".html": "\ue736", ".css": "\ue749", ".sh": "\ue795", ".bash": "\ue795",
+	},
+}
+
+// ASCII works with any font. Labels stand alone; activity types get short
+// markers so they can still be told apart.
+var ASCII = Set{
+	API:      "@",
+	Tool:     "*",
+	File:     "#",
+	Search:   "/",
+	Shell:    "$",
+	Thinking: "~",
+	Yolo:     "!",
+	Warning:  "!",
+	Question: "?",
+}
+
+var current = Emoji
+
+// asciiOnly is set when the terminal cannot show anything but ASCII
+var asciiOnly bool
+
+// Current returns the set in use
+func Current() Set {
+	return current
+}
+
+// Apply selects the set for a ui.glyphs value ("" means emoji)
+func Apply(mode string) error {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case ModeEmoji, "":
+		current = Emoji
+	case ModeNerdFont, "nerd":
+		current = NerdFont
+	case ModeASCII:
+		current = ASCII
+	default:
+		return fmt.Errorf("unknown glyph set %q (use %s, %s or %s)", mode, ModeEmoji, ModeNerdFont, ModeASCII)
+	}
+	return nil
+}
+
+// Label prefixes text with icon, or returns text alone if icon is empty
+func Label(icon, text string) string {
+	if icon == "" {
+		return text
+	}
+	return icon + " " + text
+}
+
+// FileIcon returns the icon for a file based on its extension
+func (s Set) FileIcon(path string) string {
+	if icon, ok := s.FileIcons[strings.ToLower(filepath.Ext(path))]; ok {
+		return icon
+	}
+	return s.File
+}
+
+// SetASCIIOnly limits the UI to ASCII, for terminals that show other
+// characters as mojibake: the icons are the ASCII set whatever ui.glyphs
+// says, and Text replaces box drawing, arrows and marks
+func SetASCIIOnly(on bool) {
+	asciiOnly = on
+	if on {
+		current = ASCII
+	}
+}
+
+// ASCIIOnly reports whether the UI is limited to ASCII
+func ASCIIOnly() bool {
+	return asciiOnly
+}
+
+// asciiRunes are the ASCII stand-ins for the symbols the UI draws with,
+// each as wide as the symbol so layouts keep their shape
+var asciiRunes = map[rune]rune{
+	'─': '-', '━': '-', '═': '-', '│': '|', '┃': '|', '║': '|',
+	'▁': '_', '▂': '.', '▃': ':', '▄': '-', '▅': '=', '▆': '+', '▇': '*', '█': '#',
+	'▓': '#', '▒': '%', '░': '.', '▏': '|', '▌': '|',
+	'←': '<', '→': '>', '↑': '^', '↓': 'v', '↳': '>', '▸': '>', '▶': '>', '❯': '>', '›': '>',
+	'✓': '+', '✔': '+', '✗': 'x', '✘': 'x', '●': '*', '○': 'o', '•': '*', '·': '.',
+	'◐': '|', '◓': '/', '◑': '-', '◒': '\\',
+	'…': '.', '—': '-', '–': '-', '“': '"', '”': '"', '‘': '\'', '’': '\'',
+}
+
+// Text returns s as the terminal can show it: unchanged, or in ASCII-only
+// mode with the symbols of the UI replaced by ASCII. Braille spinner frames
+// become a turning line, and other box drawing a corner.
+func Text(s string) string {
+	if !asciiOnly {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x80 {
+			return r
+		}
+		if a, ok := asciiRunes[r]; ok {
+			return a
+		}
+		switch {
+		case r >= 0x2800 && r <= 0x28ff: // Braille
+			return rune("|/-\\"[r%4])
+		case r >= 0x2500 && r <= 0x257f: // Box drawing
+			return '+'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,217 @@
+// Package guard estimates the size and cost of a chat request before it is
+// sent, so unusually large requests can be confirmed first.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package guard
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/config"
+)
+
+// Defaults used when the settings do not override them
+const (
+	DefaultMaxTokens = 400000
+	DefaultMaxCost   = 1.00 // USD
+)
+
+// Limits are the request size and cost above which the user is asked
+// before sending. Zero means no limit.
+type Limits struct {
+	MaxTokens int
+	MaxCost   float64
+}
+
+// FromConfig converts the promptGuard settings into Limits: unset values
+// use the defaults and negative ones turn the limit off
+func FromConfig(c config.PromptGuardConfig) Limits {
+	l := Limits{MaxTokens: c.MaxTokens, MaxCost: c.MaxCost}
+	switch {
+	case l.MaxTokens < 0:
+		l.MaxTokens = 0
+	case l.MaxTokens == 0:
+		l.MaxTokens = DefaultMaxTokens
+	}
+	switch {
+	case l.MaxCost < 0:
+		l.MaxCost = 0
+	case l.MaxCost == 0:
+		l.MaxCost = DefaultMaxCost
+	}
+	return l
+}
+
+// Exceeded reports whether a request of b to model is over the limits
+func (l Limits) Exceeded(model string, b Breakdown) bool {
+	total := b.Total()
+	if l.MaxTokens > 0 && total > l.MaxTokens {
+		return true
+	}
+	return l.MaxCost > 0 && InputCost(model, total) > l.MaxCost
+}
+
+// Breakdown is the estimated size of a request in tokens, by source
+type Breakdown struct {
+	History     int // Conversation text and tool calls
+	Files       int // Files attached to messages
+	ToolResults int // Tool output sent back to the model
+	System      int // System instruction and tool declarations
+}
+
+// Total returns the estimated size of the whole request
+func (b Breakdown) Total() int {
+	return b.History + b.Files + b.ToolResults + b.System
+}
+
+// attachmentHeader starts a file attached to a message ("=== path ===")
+var attachmentHeader = regexp.MustCompile(`(?m)^=== .+ ===$`)
+
+// Estimate roughly estimates the size of a request (~4 bytes per token)
+func Estimate(history []api.Content, system *api.Content, tools []api.Tool) Breakdown {
+	var b Breakdown
+	for _, c := range history {
+		for _, p := range c.Parts {
+			if p.Text != "" {
+				files := attachedBytes(p.Text)
+				b.Files += files
+				b.History += len(p.Text) - files
+			}
+			if p.FunctionCall != nil {
+				data, _ := json.Marshal(p.FunctionCall.Args)
+				b.History += len(p.FunctionCall.Name) + len(data)
+			}
+			if p.FunctionResp != nil {
+				data, _ := json.Marshal(p.FunctionResp.Response)
+				b.ToolResults += len(p.FunctionResp.Name) + len(data)
+			}
+			if p.InlineData != nil {
+				b.Files += api.ImageTokens * 4
+			}
+		}
+	}
+	if system != nil {
+		for _, p := range system.Parts {
+			b.System += len(p.Text)
+		}
+	}
+	if len(tools) > 0 {
+		data, _ := json.Marshal(tools)
+		b.System += len(data)
+	}
+	b.History /= 4
+	b.Files /= 4
+	b.ToolResults /= 4
+	b.System /= 4
+	return b
+}
+
+// attachedBytes returns how much of a message consists of attached files:
+// everything from the first "=== path ===" header to the blank lines that
+// separate the files from the prompt
+func attachedBytes(text string) int {
+	loc := attachmentHeader.FindAllStringIndex(text, -1)
+	if len(loc) == 0 {
+		return 0
+	}
+	start := loc[0][0]
+	last := loc[len(loc)-1][1]
+	end := len(text)
+	if i := strings.LastIndex(text[last:], "\n\n\n\n"); i >= 0 {
+		end = last + i
+	}
+	return end - start
+}
+
+// price is the price of a model family in USD per million tokens; above
+// longContext input tokens the long input price applies. window is the
+// family's input token limit.
+type price struct {
+	prefix      string
+	short, long float64
+	longContext int
+	output      float64
+	window      int
+}
+
+// prices are rough list prices, most specific prefix first
+var prices = []price{
+	{"gemini-3-pro", 2.00, 4.00, 200000, 12.00, 1048576},
+	{"gemini-2.5-pro", 1.25, 2.50, 200000, 10.00, 1048576},
+	{"gemini-3-flash", 0.50, 0.50, 0, 3.00, 1048576},
+	{"gemini-2.5-flash-lite", 0.10, 0.10, 0, 0.40, 1048576},
+	{"gemini-2.5-flash", 0.30, 0.30, 0, 2.50, 1048576},
+}
+
+// ModelInfo describes a model family for pickers
+type ModelInfo struct {
+	InputPrice  float64 // USD per million input tokens, short context
+	OutputPrice float64 // USD per million output tokens
+	Window      int     // Input token limit
+}
+
+// Info returns what is known about a model, or false for an unknown one
+func Info(model string) (ModelInfo, bool) {
+	p, ok := lookupPrice(model)
+	if !ok {
+		return ModelInfo{}, false
+	}
+	return ModelInfo{InputPrice: p.short, OutputPrice: p.output, Window: p.window}, true
+}
+
+// lookupPrice returns the prices of a model
+func lookupPrice(model string) (price, bool) {
+	for _, p := range prices {
+		if strings.HasPrefix(model, p.prefix) {
+			return p, true
+		}
+	}
+	return price{}, false
+}
+
+// InputCost estimates the input cost of a request in USD, or 0 for an
+// unknown model
+func InputCost(model string, tokens int) float64 {
+	p, ok := lookupPrice(model)
+	if !ok {
+		return 0
+	}
+	perMillion := p.short
+	if p.longContext > 0 && tokens > p.longContext {
+		perMillion = p.long
+	}
+	return float64(tokens) * perMillion / 1e6
+}
+
+// Cost estimates the cost in USD of token totals spread over many requests,
+// at short-context prices, or 0 for an unknown model
+func Cost(model string, input, output int) float64 {
+	p, ok := lookupPrice(model)
+	if !ok {
+		return 0
+	}
+	return (float64(input)*p.short + float64(output)*p.output) / 1e6
+}
+
+// String describes the breakdown, e.g. "~412k tokens: history 120k, files
+// 250k, tool results 40k, system 2k"
+func (b Breakdown) String() string {
+	return fmt.Sprintf("~%s tokens: history %s, files %s, tool results %s, system %s",
+		FormatTokens(b.Total()), FormatTokens(b.History), FormatTokens(b.Files),
+		FormatTokens(b.ToolResults), FormatTokens(b.System))
+}
+
+// FormatTokens renders a token count compactly (e.g. 12.3k)
+func FormatTokens(n int) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	}
+	return fmt.Sprintf("%d", n)
+}
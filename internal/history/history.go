@@ -0,0 +1,172 @@
+// Package history keeps the prompts typed in gmn chat, in the line-based
+// chat and the TUI alike, in one file namespaced by project so each
+// project recalls its own prompts.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkalls/gmn/internal/fuzzy"
+)
+
+// maxEntries is how many prompts the file keeps across all projects; older
+// ones are dropped when it is opened
+const maxEntries = 5000
+
+// Entry is a prompt in the history file, stored as a line of JSON
+type Entry struct {
+	Project string    `json:"project,omitempty"` // Directory of the project; empty in all projects
+	Prompt  string    `json:"prompt"`
+	Time    time.Time `json:"time,omitempty"`
+}
+
+// History is the prompt history of a project
+type History struct {
+	mu      sync.Mutex
+	path    string
+	project string
+	prompts []string // Prompts of the project, oldest first
+}
+
+// Open reads the history of project from the file at path. A missing file
+// is an empty history. Lines that are not JSON, as written by earlier
+// versions, are prompts of every project.
+func Open(path, project string) (*History, error) {
+	h := &History{path: path, project: project}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var e Entry
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &e) != nil {
+			e = Entry{Prompt: line}
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.Project == "" || e.Project == project {
+			h.prompts = append(h.prompts, e.Prompt)
+		}
+	}
+	if len(entries) > maxEntries {
+		// Best effort: a history that cannot be trimmed still works
+		_ = h.rewrite(entries[len(entries)-maxEntries:])
+	}
+	return h, nil
+}
+
+// Prompts returns the project's prompts, oldest first
+func (h *History) Prompts() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.prompts...)
+}
+
+// Add records a prompt, unless it repeats the previous one
+func (h *History) Add(prompt string) error {
+	prompt = strings.TrimSpace(prompt)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if prompt == "" || (len(h.prompts) > 0 && h.prompts[len(h.prompts)-1] == prompt) {
+		return nil
+	}
+	h.prompts = append(h.prompts, prompt)
+
+	line, err := json.Marshal(Entry{Project: h.project, Prompt: prompt, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Filter returns the prompts that match query as fzf would, best match
+// first and newer before older among equal matches, without repeats.
+// prompts are oldest first, as Prompts returns them.
+func Filter(prompts []string, query string) []string {
+	type scored struct {
+		prompt string
+		score  int
+	}
+	var found []scored
+	seen := make(map[string]bool)
+	for i := len(prompts) - 1; i >= 0; i-- {
+		p := prompts[i]
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if score, ok := fuzzy.Match(query, p); ok {
+			found = append(found, scored{p, score})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score > found[j].score })
+	matches := make([]string, len(found))
+	for i, f := range found {
+		matches[i] = f.prompt
+	}
+	return matches
+}
+
+// rewrite replaces the file with entries, through a temporary file so a
+// failure leaves it as it was
+func (h *History) rewrite(entries []Entry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), ".history-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), h.path)
+}
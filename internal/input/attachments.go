@@ -0,0 +1,82 @@
+// Package input provides input handling for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package input
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Attachment is a file attached to a prompt with -f
+type Attachment struct {
+	Path    string
+	Content string
+	Summary bool // Content is a model-written summary of the file
+}
+
+// Tokens roughly estimates the size of the attachment in tokens (~4 bytes
+// per token)
+func (a Attachment) Tokens() int {
+	return (len(a.Content) + 3) / 4
+}
+
+// LoadAttachments reads the attached files
+func LoadAttachments(paths []string) ([]Attachment, error) {
+	attachments := make([]Attachment, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		attachments = append(attachments, Attachment{Path: path, Content: string(content)})
+	}
+	return attachments, nil
+}
+
+// TotalTokens estimates the size of all attachments in tokens
+func TotalTokens(attachments []Attachment) int {
+	total := 0
+	for _, a := range attachments {
+		total += a.Tokens()
+	}
+	return total
+}
+
+// CheckBudget fails if the attachments are over budget tokens (0 means no
+// limit)
+func CheckBudget(attachments []Attachment, budget int) error {
+	if total := TotalTokens(attachments); budget > 0 && total > budget {
+		return fmt.Errorf("attached files are ~%d tokens, over the budget of %d; attach fewer files or raise attachments.maxTokens in settings", total, budget)
+	}
+	return nil
+}
+
+// FormatAttachments renders attachments as prompt text, each under a
+// "=== path ===" header
+func FormatAttachments(attachments []Attachment) string {
+	var builder strings.Builder
+	for _, a := range attachments {
+		if a.Summary {
+			builder.WriteString(fmt.Sprintf("=== %s (summary) ===\n", a.Path))
+		} else {
+			builder.WriteString(fmt.Sprintf("=== %s ===\n", a.Path))
+		}
+		builder.WriteString(a.Content)
+		builder.WriteString("\n\n")
+	}
+	return builder.String()
+}
+
+// WithAttachments puts the attached files before the prompt
+func WithAttachments(prompt string, attachments []Attachment) string {
+	var parts []string
+	if files := FormatAttachments(attachments); files != "" {
+		parts = append(parts, files)
+	}
+	if prompt != "" {
+		parts = append(parts, prompt)
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,37 @@
+// Package input provides input handling for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package input
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckBudget(t *testing.T) {
+	file := func(bytes int) Attachment {
+		return Attachment{Path: "f", Content: strings.Repeat("x", bytes)}
+	}
+	tests := []struct {
+		name        string
+		attachments []Attachment
+		budget      int
+		over        bool
+	}{
+		{"none", nil, 10, false},
+		{"no limit", []Attachment{file(1 << 20)}, 0, false},
+		{"under", []Attachment{file(36)}, 10, false},
+		{"exactly at budget", []Attachment{file(40)}, 10, false},
+		{"one byte over", []Attachment{file(41)}, 10, true},
+		{"over together", []Attachment{file(24), file(24)}, 10, true},
+		{"rounded up", []Attachment{file(1), file(1)}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckBudget(tt.attachments, tt.budget)
+			if (err != nil) != tt.over {
+				t.Errorf("CheckBudget(~%d tokens, %d) = %v, want over %v", TotalTokens(tt.attachments), tt.budget, err, tt.over)
+			}
+		})
+	}
+}
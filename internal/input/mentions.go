@@ -0,0 +1,159 @@
+// Package input provides input handling for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package input
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Mentions returns the paths mentioned as @path in text: words starting
+// with @, with "\ " for spaces in the path. Trailing punctuation is not part
+// of the path.
+func Mentions(text string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(text); i++ {
+		if text[i] != '@' || (i > 0 && !isSpace(text[i-1])) {
+			continue
+		}
+		var path strings.Builder
+		j := i + 1
+		for ; j < len(text) && !isSpace(text[j]); j++ {
+			if text[j] == '\\' && j+1 < len(text) && text[j+1] == ' ' {
+				j++
+			}
+			path.WriteByte(text[j])
+		}
+		i = j
+		p := strings.TrimRight(path.String(), ",.;:!?)]}'\"")
+		if p != "" && !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// maxMentionBytes caps the size of a file attached with @path, so a
+// mention of a huge log or dump cannot fill memory before the token budget
+// is checked
+const maxMentionBytes = 1 << 20
+
+// LoadMentions reads the files mentioned as @path in text, relative to
+// dir. Mentions that are not text files, such as @someone, directories and
+// binary files, are left alone; files over 1 MiB are an error.
+func LoadMentions(text, dir string) ([]Attachment, error) {
+	var attachments []Attachment
+	for _, mention := range Mentions(text) {
+		path := resolveMention(mention, dir)
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if info.Size() > maxMentionBytes {
+			return nil, fmt.Errorf("@%s: file is too large to attach (%d bytes, limit %d)", mention, info.Size(), maxMentionBytes)
+		}
+		content, err := readLimited(path, maxMentionBytes)
+		if err != nil {
+			return nil, fmt.Errorf("@%s: %w", mention, err)
+		}
+		if bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+			continue
+		}
+		attachments = append(attachments, Attachment{Path: mention, Content: string(content)})
+	}
+	return attachments, nil
+}
+
+// readLimited reads a file that may not grow past limit bytes while it is
+// read
+func readLimited(path string, limit int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("file is too large to attach (limit %d bytes)", limit)
+	}
+	return content, nil
+}
+
+// resolveMention returns the file a mention refers to
+func resolveMention(mention, dir string) string {
+	if rest, ok := strings.CutPrefix(mention, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(mention) {
+		return mention
+	}
+	return filepath.Join(dir, mention)
+}
+
+// maxMentionCompletions caps the paths offered for one completion
+const maxMentionCompletions = 50
+
+// CompleteMention returns the completions of a partial @path word, relative
+// to dir: matching files, and directories with a trailing slash. Hidden
+// entries are only offered once the name starts with a dot.
+func CompleteMention(word, dir string) []string {
+	partial, ok := strings.CutPrefix(word, "@")
+	if !ok {
+		return nil
+	}
+	matches := CompletePath(strings.ReplaceAll(partial, "\\ ", " "), dir, nil)
+	for i, path := range matches {
+		matches[i] = "@" + strings.ReplaceAll(path, " ", "\\ ")
+	}
+	return matches
+}
+
+// CompletePath returns the completions of a partial path, relative to dir:
+// matching files, and directories with a trailing slash, sorted. Hidden
+// entries are only offered once the name starts with a dot, and entries
+// skip reports true for (given their full path) are left out.
+func CompletePath(partial, dir string, skip func(path string, isDir bool) bool) []string {
+	parent, prefix := filepath.Split(partial)
+	base := resolveMention(parent+".", dir)
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return nil
+	}
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+		if skip != nil && skip(filepath.Join(base, name), entry.IsDir()) {
+			continue
+		}
+		path := parent + name
+		if entry.IsDir() {
+			path += "/"
+		}
+		matches = append(matches, path)
+		if len(matches) == maxMentionCompletions {
+			break
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
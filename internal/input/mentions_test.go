@@ -0,0 +1,77 @@
+// Package input provides input handling for geminimini.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"look at @main.go", []string{"main.go"}},
+		{"@a.go and @b.go, then @a.go.", []string{"a.go", "b.go"}},
+		{"mail me at me@example.com", nil},
+		{"see @my\\ file.txt!", []string{"my file.txt"}},
+		{"(@dir/x.go)", nil},
+		{"@", nil},
+	}
+	for _, tt := range tests {
+		if got := Mentions(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Mentions(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLoadMentions(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("notes.txt", []byte("hello"))
+	write("image.bin", []byte("PNG\x00\x01\x02"))
+	write("limit.txt", []byte(strings.Repeat("x", maxMentionBytes)))
+	write("huge.txt", []byte(strings.Repeat("x", maxMentionBytes+1)))
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		text  string
+		paths []string
+		fails bool
+	}{
+		{"text file", "read @notes.txt", []string{"notes.txt"}, false},
+		{"binary file", "read @image.bin", nil, false},
+		{"person", "ask @someone", nil, false},
+		{"directory", "in @sub", nil, false},
+		{"at the limit", "read @limit.txt", []string{"limit.txt"}, false},
+		{"over the limit", "read @notes.txt and @huge.txt", nil, true},
+		{"absolute path", "read @" + filepath.Join(dir, "notes.txt"), []string{filepath.Join(dir, "notes.txt")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachments, err := LoadMentions(tt.text, dir)
+			if (err != nil) != tt.fails {
+				t.Fatalf("LoadMentions(%q) error = %v, want failure %v", tt.text, err, tt.fails)
+			}
+			var paths []string
+			for _, a := range attachments {
+				paths = append(paths, a.Path)
+			}
+			if !slices.Equal(paths, tt.paths) {
+				t.Errorf("LoadMentions(%q) attached %q, want %q", tt.text, paths, tt.paths)
+			}
+		})
+	}
+}
@@ -31,50 +31,35 @@ func ReadStdin() (string, error) {
 
 // ReadFiles reads content from multiple files
 func ReadFiles(paths []string) (string, error) {
-	if len(paths) == 0 {
-		return "", nil
-	}
-
-	var builder strings.Builder
-	for _, path := range paths {
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return "", fmt.Errorf("failed to read file %s: %w", path, err)
-		}
-		builder.WriteString(fmt.Sprintf("=== %s ===\n", path))
-		builder.Write(content)
-		builder.WriteString("\n\n")
+	attachments, err := LoadAttachments(paths)
+	if err != nil {
+		return "", err
 	}
-
-	return builder.String(), nil
+	return FormatAttachments(attachments), nil
 }
 
 // PrepareInput combines stdin, files, and prompt into a single input
 func PrepareInput(prompt string, files []string) (string, error) {
-	var parts []string
-
-	// Read stdin
-	stdin, err := ReadStdin()
+	attachments, err := LoadAttachments(files)
 	if err != nil {
 		return "", err
 	}
-	if stdin != "" {
-		parts = append(parts, stdin)
-	}
+	return CombineInput(prompt, attachments)
+}
 
-	// Read files
-	filesContent, err := ReadFiles(files)
+// CombineInput combines stdin, attached files, and prompt into a single
+// input
+func CombineInput(prompt string, attachments []Attachment) (string, error) {
+	// Read stdin
+	stdin, err := ReadStdin()
 	if err != nil {
 		return "", err
 	}
-	if filesContent != "" {
-		parts = append(parts, filesContent)
+	if stdin == "" {
+		return WithAttachments(prompt, attachments), nil
 	}
-
-	// Add prompt
-	if prompt != "" {
-		parts = append(parts, prompt)
+	if rest := WithAttachments(prompt, attachments); rest != "" {
+		return strings.Join([]string{stdin, rest}, "\n\n"), nil
 	}
-
-	return strings.Join(parts, "\n\n"), nil
+	return stdin, nil
 }
@@ -0,0 +1,207 @@
+// Package locale formats dates, times, numbers and costs for the user's
+// locale, taken from the ui.locale setting or the LC_ALL, LC_TIME and LANG
+// environment variables.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package locale
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Locale holds the formats of one locale
+type Locale struct {
+	Tag     language.Tag
+	printer *message.Printer
+
+	shortDate   string // Month and day as a Go layout
+	date        string // Full date as a Go layout
+	clock       string // Time of day as a Go layout
+	symbolAfter bool   // "1,23 $" rather than "$1.23"
+	relative    relativeWords
+}
+
+// relativeWords spells relative times; %d is the count
+type relativeWords struct {
+	now                         string
+	minutes, hours, days, weeks string
+}
+
+var english = relativeWords{"just now", "%dm ago", "%dh ago", "%dd ago", "%dw ago"}
+
+// dateFormats are the date and time layouts of a language or region, most
+// specific first
+var dateFormats = []struct {
+	match                  string // Language or language-REGION
+	shortDate, date, clock string
+}{
+	{"en-US", "Jan 2", "Jan 2, 2006", "3:04 PM"},
+	{"en-CA", "Jan 2", "2006-01-02", "3:04 PM"},
+	{"en-AU", "2 Jan", "02/01/2006", "3:04 pm"},
+	{"en", "2 Jan", "02/01/2006", "15:04"},
+	{"de", "02.01.", "02.01.2006", "15:04"},
+	{"fr", "02/01", "02/01/2006", "15:04"},
+	{"es", "02/01", "02/01/2006", "15:04"},
+	{"it", "02/01", "02/01/2006", "15:04"},
+	{"pt", "02/01", "02/01/2006", "15:04"},
+	{"nl", "02-01", "02-01-2006", "15:04"},
+	{"ru", "02.01", "02.01.2006", "15:04"},
+	{"pl", "02.01", "02.01.2006", "15:04"},
+	{"sv", "2/1", "2006-01-02", "15:04"},
+	{"ja", "1/2", "2006/01/02", "15:04"},
+	{"zh", "1/2", "2006/01/02", "15:04"},
+	{"ko", "1. 2.", "2006. 1. 2.", "15:04"},
+}
+
+// relativeFormats spell relative times in languages besides English
+var relativeFormats = map[string]relativeWords{
+	"de": {"gerade eben", "vor %d Min.", "vor %d Std.", "vor %d T.", "vor %d Wo."},
+	"fr": {"à l'instant", "il y a %d min", "il y a %d h", "il y a %d j", "il y a %d sem."},
+	"es": {"ahora", "hace %d min", "hace %d h", "hace %d d", "hace %d sem."},
+	"it": {"adesso", "%d min fa", "%d h fa", "%d g fa", "%d sett. fa"},
+	"pt": {"agora", "há %d min", "há %d h", "há %d d", "há %d sem."},
+	"ja": {"たった今", "%d分前", "%d時間前", "%d日前", "%d週間前"},
+	"zh": {"刚刚", "%d分钟前", "%d小时前", "%d天前", "%d周前"},
+	"ko": {"방금", "%d분 전", "%d시간 전", "%d일 전", "%d주 전"},
+}
+
+// symbolAfterLanguages write the currency symbol after the amount
+var symbolAfterLanguages = []string{"de", "fr", "es", "it", "pt", "nl", "ru", "pl", "sv", "cs", "fi", "da", "nb"}
+
+var current = New(Detect())
+
+// Current returns the locale in use
+func Current() *Locale {
+	return current
+}
+
+// Apply selects the locale for a ui.locale value such as "de-DE" or
+// "ja_JP.UTF-8"; "" or "auto" uses the environment
+func Apply(setting string) error {
+	setting = strings.TrimSpace(setting)
+	if setting == "" || strings.EqualFold(setting, "auto") {
+		current = New(Detect())
+		return nil
+	}
+	tag, err := parse(setting)
+	if err != nil {
+		return fmt.Errorf("unknown locale %q (use a tag such as en-US or de-DE)", setting)
+	}
+	current = New(tag)
+	return nil
+}
+
+// Detect returns the locale from LC_ALL, LC_TIME or LANG, or American
+// English if they are unset or "C"
+func Detect() language.Tag {
+	for _, env := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			if tag, err := parse(v); err == nil {
+				return tag
+			}
+			break
+		}
+	}
+	return language.AmericanEnglish
+}
+
+// parse reads a BCP 47 tag or a POSIX locale name ("de_DE.UTF-8@euro")
+func parse(s string) (language.Tag, error) {
+	if i := strings.IndexAny(s, ".@"); i >= 0 {
+		s = s[:i]
+	}
+	if s == "C" || s == "POSIX" {
+		return language.AmericanEnglish, nil
+	}
+	return language.Parse(strings.ReplaceAll(s, "_", "-"))
+}
+
+// New returns the formats for a locale
+func New(tag language.Tag) *Locale {
+	l := &Locale{Tag: tag, printer: message.NewPrinter(tag), relative: english}
+	base, _ := tag.Base()
+	region, _ := tag.Region()
+	lang, full := base.String(), base.String()+"-"+region.String()
+
+	for _, f := range dateFormats {
+		if f.match == full || f.match == lang {
+			l.shortDate, l.date, l.clock = f.shortDate, f.date, f.clock
+			break
+		}
+	}
+	if l.date == "" {
+		l.shortDate, l.date, l.clock = "01-02", "2006-01-02", "15:04"
+	}
+	if words, ok := relativeFormats[lang]; ok {
+		l.relative = words
+	}
+	for _, s := range symbolAfterLanguages {
+		if s == lang {
+			l.symbolAfter = true
+		}
+	}
+	return l
+}
+
+// Clock formats the time of day, e.g. "15:04" or "3:04 PM"
+func (l *Locale) Clock(t time.Time) string {
+	return t.Format(l.clock)
+}
+
+// Date formats a full date
+func (l *Locale) Date(t time.Time) string {
+	return t.Format(l.date)
+}
+
+// DateTime formats a full date and time of day
+func (l *Locale) DateTime(t time.Time) string {
+	return t.Format(l.date + " " + l.clock)
+}
+
+// ShortDateTime formats a date without the year, and the time of day
+func (l *Locale) ShortDateTime(t time.Time) string {
+	return t.Format(l.shortDate + " " + l.clock)
+}
+
+// Relative describes how long ago t was ("2h ago"); times more than four
+// weeks ago, or in the future, are shown as a date
+func (l *Locale) Relative(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < 0 || d >= 28*24*time.Hour:
+		if t.Year() == now.Year() {
+			return t.Format(l.shortDate)
+		}
+		return l.Date(t)
+	case d < time.Minute:
+		return l.relative.now
+	case d < time.Hour:
+		return fmt.Sprintf(l.relative.minutes, int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf(l.relative.hours, int(d/time.Hour))
+	case d < 7*24*time.Hour:
+		return fmt.Sprintf(l.relative.days, int(d/(24*time.Hour)))
+	}
+	return fmt.Sprintf(l.relative.weeks, int(d/(7*24*time.Hour)))
+}
+
+// Int formats an integer with digit grouping, e.g. "12,345" or "12.345"
+func (l *Locale) Int(n int) string {
+	return l.printer.Sprintf("%d", n)
+}
+
+// Cost formats an amount in US dollars with the given number of decimals,
+// e.g. "$0.0123" or "0,0123 $"
+func (l *Locale) Cost(usd float64, decimals int) string {
+	amount := l.printer.Sprintf("%.*f", decimals, usd)
+	if l.symbolAfter {
+		return amount + " $"
+	}
+	return "$" + amount
+}
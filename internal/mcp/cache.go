@@ -0,0 +1,115 @@
+// Package mcp provides MCP (Model Context Protocol) client implementation.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+// ToolCacheFile is the name of the tool cache in gmn's cache directory
+const ToolCacheFile = "mcp-cache.json"
+
+// ToolCache remembers the tools each server offered, so later runs can
+// show them to the model without starting the server. Entries expire after
+// a TTL and when the server's settings change. A nil ToolCache caches
+// nothing.
+type ToolCache struct {
+	path string
+	ttl  time.Duration
+	mu   sync.Mutex
+}
+
+// CachedServer is what a server reported when it was last started
+type CachedServer struct {
+	Fingerprint   string    `json:"fingerprint"` // Hash of the server's settings
+	ServerName    string    `json:"serverName,omitempty"`
+	ServerVersion string    `json:"serverVersion,omitempty"`
+	Tools         []Tool    `json:"tools"`
+	Updated       time.Time `json:"updated"`
+}
+
+// NewToolCache returns a cache stored at path whose entries last ttl
+func NewToolCache(path string, ttl time.Duration) *ToolCache {
+	return &ToolCache{path: path, ttl: ttl}
+}
+
+// Lookup returns the cached tools of a server if they are still fresh
+func (c *ToolCache) Lookup(name string, cfg config.MCPServerConfig) (*CachedServer, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.read()[cacheKey(name, cfg)]
+	if !ok || time.Since(entry.Updated) > c.ttl {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Store records the tools of an initialized client
+func (c *ToolCache) Store(name string, cfg config.MCPServerConfig, client *Client) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := c.read()
+	for key, entry := range entries {
+		if time.Since(entry.Updated) > c.ttl {
+			delete(entries, key)
+		}
+	}
+	entries[cacheKey(name, cfg)] = &CachedServer{
+		Fingerprint:   fingerprint(cfg),
+		ServerName:    client.ServerName,
+		ServerVersion: client.ServerVersion,
+		Tools:         client.Tools,
+		Updated:       time.Now(),
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0600)
+}
+
+// read loads the cache file; a missing or damaged file is an empty cache.
+// The caller holds c.mu.
+func (c *ToolCache) read() map[string]*CachedServer {
+	entries := make(map[string]*CachedServer)
+	if data, err := os.ReadFile(c.path); err == nil {
+		if json.Unmarshal(data, &entries) != nil {
+			entries = make(map[string]*CachedServer)
+		}
+	}
+	return entries
+}
+
+// cacheKey is the key of a server's entry: its name and the hash of its
+// settings, so editing them (or switching to a profile that configures the
+// server differently) never finds the tools of the old settings
+func cacheKey(name string, cfg config.MCPServerConfig) string {
+	return name + "@" + fingerprint(cfg)
+}
+
+// fingerprint identifies a server's settings
+func fingerprint(cfg config.MCPServerConfig) string {
+	data, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
@@ -4,26 +4,32 @@
 package mcp
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
 )
 
-// Client is an MCP client using stdio transport
+// Client is an MCP client. It talks to a local server over stdio or to a
+// remote one over HTTP.
 type Client struct {
-	cmd       *exec.Cmd
-	stdin     io.WriteCloser
-	stdout    io.ReadCloser
-	scanner   *bufio.Scanner
+	t         transport
 	requestID atomic.Int64
 	mu        sync.Mutex
 
+	// Set before Initialize to offer client features to the server
+	Name    string          // Name from settings, shown in sampling prompts
+	Roots   []Root          // Directories advertised through roots/list
+	Sampler SamplingHandler // Answers sampling/createMessage; nil declines sampling
+
 	// Server info after initialization
 	ServerName    string
 	ServerVersion string
@@ -47,7 +53,9 @@ type jsonRPCRequest struct {
 
 type jsonRPCResponse struct {
 	JSONRPC string          `json:"jsonrpc"`
-	ID      int64           `json:"id"`
+	ID      json.RawMessage `json:"id,omitempty"`     // Servers may use string IDs for their requests
+	Method  string          `json:"method,omitempty"` // Set on requests and notifications from the server
+	Params  json.RawMessage `json:"params,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   *jsonRPCError   `json:"error,omitempty"`
 }
@@ -57,49 +65,137 @@ type jsonRPCError struct {
 	Message string `json:"message"`
 }
 
+// RPCError is an error response from the server. It means the server is
+// alive, unlike transport errors.
+type RPCError struct {
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("RPC error %d: %s", e.Code, e.Message)
+}
+
+// HTTPError is an error status from a remote server. Like RPCError, it
+// means the server is reachable.
+type HTTPError struct {
+	Status  int
+	Message string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Status, e.Message)
+}
+
 // NewClient creates a new MCP client
 func NewClient(command string, args []string, env map[string]string) (*Client, error) {
-	cmd := exec.Command(command, args...)
-
-	// Set environment
-	cmd.Env = os.Environ()
-	for k, v := range env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	t, err := startStdio(command, args, env, "")
+	if err != nil {
+		return nil, err
 	}
+	return &Client{t: t}, nil
+}
 
-	stdin, err := cmd.StdinPipe()
+// NewClientFromConfig connects to the MCP server called name: it starts a
+// stdio server, or prepares requests to a remote one (the connection is
+// made by Initialize)
+func NewClientFromConfig(name string, cfg config.MCPServerConfig) (*Client, error) {
+	var t transport
+	var err error
+	if cfg.Command != "" {
+		t, err = startStdio(cfg.Command, cfg.Args, cfg.Env, cfg.CWD)
+	} else {
+		t, err = newHTTPTransport(name, cfg)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
+		return nil, err
 	}
+	return &Client{t: t, Name: name}, nil
+}
 
-	stdout, err := cmd.StdoutPipe()
+// ValidateServer checks a server definition without starting the server:
+// it needs a command or a URL, and the URL and transport type must be valid
+func ValidateServer(cfg config.MCPServerConfig) error {
+	switch {
+	case cfg.Command != "" && cfg.URL != "":
+		return fmt.Errorf("both command and url are set; use one")
+	case cfg.Command == "" && cfg.URL == "":
+		return fmt.Errorf("neither command nor url is set")
+	case cfg.Command != "":
+		return nil
+	}
+	t, err := newHTTPTransport("", cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+		return err
 	}
+	t.kill()
+	return nil
+}
 
-	// Redirect stderr to our stderr for debugging
-	cmd.Stderr = os.Stderr
+// CheckCommand checks that the command of a stdio server can be found,
+// relative to its working directory if the command is a relative path
+func CheckCommand(cfg config.MCPServerConfig) error {
+	if cfg.Command == "" {
+		return nil
+	}
+	command := cfg.Command
+	if strings.ContainsRune(command, filepath.Separator) && !filepath.IsAbs(command) && cfg.CWD != "" {
+		command = filepath.Join(cfg.CWD, command)
+	}
+	if _, err := exec.LookPath(command); err != nil {
+		return fmt.Errorf("command %q not found", cfg.Command)
+	}
+	return nil
+}
 
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start MCP server: %w", err)
+// Alive reports whether the connection to the server is still open: for
+// stdio servers, whether the process is running
+func (c *Client) Alive() bool {
+	select {
+	case <-c.t.done():
+		return false
+	default:
+		return true
 	}
+}
+
+// isResponse reports whether err is an answer from the server rather than
+// a transport failure
+func isResponse(err error) bool {
+	var rpcErr *RPCError
+	var httpErr *HTTPError
+	return errors.As(err, &rpcErr) || errors.As(err, &httpErr)
+}
 
-	client := &Client{
-		cmd:     cmd,
-		stdin:   stdin,
-		stdout:  stdout,
-		scanner: bufio.NewScanner(stdout),
+// Ping checks that the server responds. Servers that do not implement ping
+// still answer with an error, which counts as alive. A client busy with
+// another request is not pinged.
+func (c *Client) Ping(ctx context.Context) error {
+	if !c.mu.TryLock() {
+		return nil
 	}
+	defer c.mu.Unlock()
 
-	return client, nil
+	_, err := c.callLocked(ctx, "ping", nil)
+	if isResponse(err) {
+		return nil
+	}
+	return err
 }
 
 // Initialize performs the MCP initialization handshake
 func (c *Client) Initialize(ctx context.Context) error {
 	// Send initialize request
+	capabilities := map[string]interface{}{}
+	if c.Roots != nil {
+		capabilities["roots"] = map[string]interface{}{"listChanged": false}
+	}
+	if c.Sampler != nil {
+		capabilities["sampling"] = map[string]interface{}{}
+	}
 	initParams := map[string]interface{}{
 		"protocolVersion": "2024-11-05",
-		"capabilities":    map[string]interface{}{},
+		"capabilities":    capabilities,
 		"clientInfo": map[string]string{
 			"name":    "gmn",
 			"version": "1.0.0",
@@ -132,21 +228,29 @@ func (c *Client) Initialize(ctx context.Context) error {
 	}
 
 	// List tools
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		return err
+	}
+	c.Tools = tools
+
+	return nil
+}
+
+// ListTools fetches the server's tools
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
 	toolsResult, err := c.call(ctx, "tools/list", nil)
 	if err != nil {
-		return fmt.Errorf("tools/list failed: %w", err)
+		return nil, fmt.Errorf("tools/list failed: %w", err)
 	}
 
 	var toolsResp struct {
 		Tools []Tool `json:"tools"`
 	}
 	if err := json.Unmarshal(toolsResult, &toolsResp); err != nil {
-		return fmt.Errorf("failed to parse tools: %w", err)
+		return nil, fmt.Errorf("failed to parse tools: %w", err)
 	}
-
-	c.Tools = toolsResp.Tools
-
-	return nil
+	return toolsResp.Tools, nil
 }
 
 // CallTool calls an MCP tool
@@ -191,17 +295,63 @@ func (c *Client) CallTool(ctx context.Context, name string, args map[string]inte
 	return text, nil
 }
 
-// Close shuts down the MCP client
+// Close shuts down the MCP client. Stdio servers that do not exit after
+// their input is closed are killed.
 func (c *Client) Close() error {
-	c.stdin.Close()
-	c.stdout.Close()
-	return c.cmd.Wait()
+	return c.t.close()
 }
 
+// Kill ends the connection at once; a stdio server is killed
+func (c *Client) Kill() {
+	c.t.kill()
+}
+
+// call sends a request and waits for its response. If ctx ends first, the
+// connection is closed (a stdio server is killed), since its response could
+// no longer be told apart from later ones.
 func (c *Client) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	return c.callLocked(ctx, method, params)
+}
 
+func (c *Client) callLocked(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	if !c.Alive() {
+		return nil, fmt.Errorf("MCP connection closed: %w", c.t.err())
+	}
+
+	type response struct {
+		result json.RawMessage
+		err    error
+	}
+	done := make(chan response, 1)
+	go func() {
+		result, err := c.roundTrip(ctx, method, params)
+		done <- response{result, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil && !isResponse(r.err) {
+			// EOF usually means the server crashed; wait for its exit so
+			// Alive reports it
+			select {
+			case <-c.t.done():
+				return nil, fmt.Errorf("MCP connection closed (%v): %w", c.t.err(), r.err)
+			case <-time.After(time.Second):
+			}
+		}
+		return r.result, r.err
+	case <-ctx.Done():
+		c.t.kill()
+		<-done
+		return nil, fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// roundTrip writes a request and reads until its response, answering
+// requests from the server and skipping its notifications
+func (c *Client) roundTrip(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
 	id := c.requestID.Add(1)
 
 	req := jsonRPCRequest{
@@ -217,28 +367,98 @@ func (c *Client) call(ctx context.Context, method string, params interface{}) (j
 	}
 
 	// Write request
-	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+	if err := c.t.send(ctx, data); err != nil {
+		if isResponse(err) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to write request: %w", err)
 	}
 
 	// Read response
-	if !c.scanner.Scan() {
-		if err := c.scanner.Err(); err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
+	for {
+		msg, err := c.t.receive()
+		if err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("EOF while reading response")
-	}
 
-	var resp jsonRPCResponse
-	if err := json.Unmarshal(c.scanner.Bytes(), &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		var resp jsonRPCResponse
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		if resp.Method != "" {
+			if len(resp.ID) > 0 {
+				if err := c.handleRequest(ctx, &resp); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		var respID int64
+		if json.Unmarshal(resp.ID, &respID) != nil || respID != id {
+			continue
+		}
+
+		if resp.Error != nil {
+			return nil, &RPCError{Code: resp.Error.Code, Message: resp.Error.Message}
+		}
+
+		return resp.Result, nil
 	}
+}
 
-	if resp.Error != nil {
-		return nil, fmt.Errorf("RPC error %d: %s", resp.Error.Code, resp.Error.Message)
+// handleRequest answers a request the server sent while waiting for a
+// response. The caller holds c.mu.
+func (c *Client) handleRequest(ctx context.Context, req *jsonRPCResponse) error {
+	var result interface{}
+	var rpcErr *jsonRPCError
+
+	switch req.Method {
+	case "ping":
+		result = map[string]interface{}{}
+	case "roots/list":
+		if c.Roots == nil {
+			rpcErr = &jsonRPCError{Code: -32601, Message: "roots are not supported"}
+			break
+		}
+		result = map[string]interface{}{"roots": c.Roots}
+	case "sampling/createMessage":
+		if c.Sampler == nil {
+			rpcErr = &jsonRPCError{Code: -32601, Message: "sampling is not supported"}
+			break
+		}
+		var params SamplingRequest
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			rpcErr = &jsonRPCError{Code: -32602, Message: fmt.Sprintf("invalid params: %v", err)}
+			break
+		}
+		name := c.Name
+		if name == "" {
+			name = c.ServerName
+		}
+		sampled, err := c.Sampler(ctx, name, &params)
+		if err != nil {
+			rpcErr = &jsonRPCError{Code: -1, Message: err.Error()}
+			break
+		}
+		result = sampled
+	default:
+		rpcErr = &jsonRPCError{Code: -32601, Message: "method not found: " + req.Method}
 	}
 
-	return resp.Result, nil
+	resp := struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  interface{}     `json:"result,omitempty"`
+		Error   *jsonRPCError   `json:"error,omitempty"`
+	}{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %w", err)
+	}
+	if err := c.t.send(ctx, data); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
 }
 
 func (c *Client) notify(method string, params interface{}) error {
@@ -261,7 +481,7 @@ func (c *Client) notify(method string, params interface{}) error {
 		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
 
-	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+	if err := c.t.send(context.Background(), data); err != nil {
 		return fmt.Errorf("failed to write notification: %w", err)
 	}
 
@@ -0,0 +1,221 @@
+// Package mcp provides MCP (Model Context Protocol) client implementation.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+const (
+	// HealthInterval is how often idle connections are pinged
+	HealthInterval = 30 * time.Second
+	// maxRestarts is how many times in a row a crashing server is restarted
+	// before Conn gives up on it
+	maxRestarts = 3
+	// startTimeout bounds starting and initializing a server
+	startTimeout = 30 * time.Second
+	// pingTimeout is how long a server may take to answer a health check
+	pingTimeout = 10 * time.Second
+)
+
+// Conn is a long-lived connection to an MCP server for a chat session. It
+// keeps one server process running across tool calls, checks its health
+// in the background and restarts it when it has crashed. A Conn from Open
+// may not have started the server yet; it does so on the first call. It is
+// safe for concurrent use.
+type Conn struct {
+	name string
+	cfg  config.MCPServerConfig
+	opts Options
+
+	mu       sync.Mutex
+	client   *Client // nil until the server is started
+	tools    []Tool
+	failures int // Restarts since the last successful call
+	closed   bool
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Options are the client features offered to servers and where their
+// tools are cached
+type Options struct {
+	Roots   []Root
+	Sampler SamplingHandler
+	Cache   *ToolCache
+}
+
+// Dial starts and initializes an MCP server and begins health checks
+func Dial(ctx context.Context, name string, cfg config.MCPServerConfig, opts Options) (*Conn, error) {
+	c := &Conn{name: name, cfg: cfg, opts: opts, stop: make(chan struct{})}
+	if err := c.start(ctx); err != nil {
+		return nil, err
+	}
+	c.wg.Add(1)
+	go c.healthLoop(HealthInterval)
+	return c, nil
+}
+
+// Open is like Dial, but when opts.Cache knows the server's tools it
+// returns them without starting the server, which then starts on the
+// first call
+func Open(ctx context.Context, name string, cfg config.MCPServerConfig, opts Options) (*Conn, error) {
+	cached, ok := opts.Cache.Lookup(name, cfg)
+	if !ok {
+		return Dial(ctx, name, cfg, opts)
+	}
+	c := &Conn{name: name, cfg: cfg, opts: opts, tools: cached.Tools, stop: make(chan struct{})}
+	c.wg.Add(1)
+	go c.healthLoop(HealthInterval)
+	return c, nil
+}
+
+// Name returns the server name from settings
+func (c *Conn) Name() string {
+	return c.name
+}
+
+// Tools returns the server's tools as of its last start, or the cached
+// ones if it has not been started
+func (c *Conn) Tools() []Tool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tools
+}
+
+// start launches the server process. The caller holds c.mu or owns c.
+func (c *Conn) start(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+
+	client, err := NewClientFromConfig(c.name, c.cfg)
+	if err != nil {
+		return fmt.Errorf("MCP server '%s': %w", c.name, err)
+	}
+	client.Roots = c.opts.Roots
+	client.Sampler = c.opts.Sampler
+	if err := client.Initialize(ctx); err != nil {
+		client.Close()
+		return fmt.Errorf("MCP server '%s': %w", c.name, err)
+	}
+	c.client = client
+	c.tools = client.Tools
+	if err := c.opts.Cache.Store(c.name, c.cfg, client); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not cache the tools of MCP server '%s': %v\n", c.name, err)
+	}
+	return nil
+}
+
+// live returns a running client, restarting the server if it has exited
+func (c *Conn) live(ctx context.Context) (*Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, fmt.Errorf("MCP server '%s' is closed", c.name)
+	}
+	if c.client != nil && c.client.Alive() {
+		return c.client, nil
+	}
+	if c.failures >= maxRestarts {
+		return nil, fmt.Errorf("MCP server '%s' keeps crashing; gave up after %d restarts", c.name, maxRestarts)
+	}
+
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+		if c.cfg.Command == "" {
+			fmt.Fprintf(os.Stderr, "Lost the connection to MCP server '%s'; reconnecting\n", c.name)
+		} else {
+			fmt.Fprintf(os.Stderr, "MCP server '%s' exited; restarting\n", c.name)
+		}
+	}
+	c.failures++
+	if err := c.start(ctx); err != nil {
+		return nil, err
+	}
+	return c.client, nil
+}
+
+// CallTool calls a tool, restarting the server first if it is not running.
+// A call that fails because the server crashed is not retried, since it may
+// already have had side effects.
+func (c *Conn) CallTool(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	client, err := c.live(ctx)
+	if err != nil {
+		return "", err
+	}
+	result, err := client.CallTool(ctx, name, args)
+	if err != nil {
+		switch {
+		case ctx.Err() != nil:
+			return "", fmt.Errorf("MCP server '%s' did not answer in time and was stopped (it is restarted on the next call): %w", c.name, err)
+		case !client.Alive():
+			return "", fmt.Errorf("MCP server '%s' crashed during the call (it is restarted on the next call): %w", c.name, err)
+		}
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.failures = 0
+	c.mu.Unlock()
+	return result, nil
+}
+
+// healthLoop pings the server periodically and kills it if it stops
+// responding, so the next call starts a fresh one
+func (c *Conn) healthLoop(interval time.Duration) {
+	defer c.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		c.mu.Lock()
+		client := c.client
+		c.mu.Unlock()
+		if client == nil || !client.Alive() {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := client.Ping(ctx)
+		cancel()
+		if err != nil && client.Alive() {
+			client.Kill()
+		}
+	}
+}
+
+// Close stops health checks and shuts the server down
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	client := c.client
+	c.client = nil
+	c.mu.Unlock()
+
+	close(c.stop)
+	c.wg.Wait()
+	if client == nil || !client.Alive() {
+		return nil // A crash was already reported by the call that saw it
+	}
+	return client.Close()
+}
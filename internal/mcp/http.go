@@ -0,0 +1,433 @@
+// Package mcp provides MCP (Model Context Protocol) client implementation.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+const (
+	// sessionHeader carries the session a streamable HTTP server assigned
+	sessionHeader = "Mcp-Session-Id"
+	// endpointTimeout bounds waiting for an SSE server to announce where
+	// messages are posted
+	endpointTimeout = 30 * time.Second
+	// maxMessageSize caps a JSON response body
+	maxMessageSize = 64 << 20
+)
+
+// errConnectionLost ends a connection after a request failed to reach the
+// server; the next call connects again
+var errConnectionLost = errors.New("connection lost")
+
+// httpTransport talks to a remote server. Streamable HTTP servers answer
+// each posted message in the response, as JSON or an event stream; older
+// SSE servers answer on one long-lived event stream and announce the URL
+// messages are posted to. Servers without a type are tried with streamable
+// HTTP first and with SSE if they reject it.
+type httpTransport struct {
+	name   string
+	cfg    config.MCPServerConfig
+	base   *url.URL
+	oauth  bool // Send the stored OAuth token
+	client *http.Client
+
+	incoming chan []byte
+	ended    chan struct{}
+	endOnce  sync.Once
+	endErr   error
+	ctx      context.Context // Canceled when the connection ends
+	cancel   context.CancelFunc
+
+	mu          sync.Mutex
+	sessionID   string
+	established bool // A message was accepted, so the transport is settled
+	sse         bool
+	streaming   bool          // The SSE stream has been opened
+	endpoint    string        // Where SSE servers take messages
+	ready       chan struct{} // Closed once endpoint is known
+	readyOnce   sync.Once
+}
+
+// newHTTPTransport prepares a connection to a remote server; nothing is
+// sent until the first message
+func newHTTPTransport(name string, cfg config.MCPServerConfig) (*httpTransport, error) {
+	base, err := url.Parse(cfg.URL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", cfg.URL)
+	}
+	switch cfg.Type {
+	case "", "http", "sse":
+	default:
+		return nil, fmt.Errorf("unknown transport type %q (use sse or http)", cfg.Type)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &httpTransport{
+		name:     name,
+		cfg:      cfg,
+		base:     base,
+		oauth:    UsesOAuth(name, cfg),
+		client:   &http.Client{},
+		incoming: make(chan []byte, 64),
+		ended:    make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+		sse:      cfg.Type == "sse",
+		ready:    make(chan struct{}),
+	}, nil
+}
+
+func (t *httpTransport) send(ctx context.Context, msg []byte) error {
+	t.mu.Lock()
+	sse, established := t.sse, t.established
+	t.mu.Unlock()
+	if sse {
+		return t.postSSE(ctx, msg)
+	}
+
+	err := t.post(ctx, msg)
+	var httpErr *HTTPError
+	if t.cfg.Type == "" && !established && errors.As(err, &httpErr) &&
+		(httpErr.Status == http.StatusBadRequest || httpErr.Status == http.StatusNotFound || httpErr.Status == http.StatusMethodNotAllowed) {
+		// Servers from before streamable HTTP only take messages over SSE
+		t.mu.Lock()
+		t.sse = true
+		t.mu.Unlock()
+		return t.postSSE(ctx, msg)
+	}
+	return err
+}
+
+// post sends a message to a streamable HTTP server and delivers the
+// messages of its response
+func (t *httpTransport) post(ctx context.Context, msg []byte) error {
+	req, err := t.newRequest(ctx, http.MethodPost, t.base.String(), msg)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		t.end(errConnectionLost)
+		return err
+	}
+
+	t.mu.Lock()
+	if id := resp.Header.Get(sessionHeader); id != "" {
+		t.sessionID = id
+	}
+	session := t.sessionID
+	t.mu.Unlock()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && session != "":
+		resp.Body.Close()
+		err := fmt.Errorf("the server ended the session")
+		t.end(err)
+		return err
+	case resp.StatusCode >= 300:
+		return t.statusError(resp)
+	}
+	t.mu.Lock()
+	t.established = true
+	t.mu.Unlock()
+
+	if resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusNoContent {
+		resp.Body.Close()
+		return nil
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// The stream carries the response and any requests the server
+		// makes while handling the message
+		go func() {
+			defer resp.Body.Close()
+			readEvents(resp.Body, func(event, data string) {
+				if event == "" || event == "message" {
+					t.push([]byte(data))
+				}
+			})
+		}()
+		return nil
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		t.end(errConnectionLost)
+		return err
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	if body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return fmt.Errorf("invalid response: %w", err)
+		}
+		for _, m := range batch {
+			t.push(m)
+		}
+		return nil
+	}
+	t.push(body)
+	return nil
+}
+
+// postSSE sends a message to an SSE server, opening its event stream first
+func (t *httpTransport) postSSE(ctx context.Context, msg []byte) error {
+	if err := t.openStream(); err != nil {
+		return err
+	}
+	select {
+	case <-t.ready:
+	case <-t.ended:
+		return t.endErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	t.mu.Lock()
+	endpoint := t.endpoint
+	t.mu.Unlock()
+	req, err := t.newRequest(ctx, http.MethodPost, endpoint, msg)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		t.end(errConnectionLost)
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return t.statusError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// openStream opens the event stream of an SSE server once. The server has
+// endpointTimeout to announce its endpoint; if the stream cannot be opened,
+// the connection ends.
+func (t *httpTransport) openStream() error {
+	t.mu.Lock()
+	opened := t.streaming
+	t.streaming = true
+	t.mu.Unlock()
+	if opened {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	timer := time.AfterFunc(endpointTimeout, cancel)
+	req, err := t.newRequest(ctx, http.MethodGet, t.base.String(), nil)
+	if err != nil {
+		cancel()
+		t.end(err)
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		cancel()
+		t.end(errConnectionLost)
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		cancel()
+		err := t.statusError(resp)
+		t.end(err)
+		return err
+	}
+
+	go func() {
+		defer cancel()
+		defer resp.Body.Close()
+		err := readEvents(resp.Body, func(event, data string) {
+			switch event {
+			case "endpoint":
+				endpoint, err := sseEndpoint(t.base, data)
+				if err != nil {
+					t.end(err)
+					return
+				}
+				timer.Stop()
+				t.mu.Lock()
+				t.endpoint = endpoint
+				t.mu.Unlock()
+				t.readyOnce.Do(func() { close(t.ready) })
+			case "", "message":
+				t.push([]byte(data))
+			}
+		})
+		if err == nil {
+			err = io.EOF
+		}
+		t.end(fmt.Errorf("event stream closed: %w", err))
+	}()
+	return nil
+}
+
+// sseEndpoint resolves the endpoint an SSE server sends messages to. It
+// must be on the server's own origin, since the configured headers and the
+// OAuth token are sent to it.
+func sseEndpoint(base *url.URL, data string) (string, error) {
+	endpoint, err := base.Parse(strings.TrimSpace(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %w", data, err)
+	}
+	if !strings.EqualFold(endpoint.Scheme, base.Scheme) || !strings.EqualFold(endpoint.Host, base.Host) {
+		return "", fmt.Errorf("endpoint %s is not on the server's origin %s://%s", endpoint.Redacted(), base.Scheme, base.Host)
+	}
+	return endpoint.String(), nil
+}
+
+// newRequest builds a request with the configured headers, the session and
+// the OAuth token
+func (t *httpTransport) newRequest(ctx context.Context, method, target string, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range t.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set(sessionHeader, t.sessionID)
+	}
+	t.mu.Unlock()
+	if t.oauth && req.Header.Get("Authorization") == "" {
+		token, err := AccessToken(ctx, t.name, t.cfg)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// statusError reads an error response into an HTTPError
+func (t *httpTransport) statusError(resp *http.Response) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		msg += fmt.Sprintf(" (run 'gmn mcp auth %s' if the server uses OAuth)", t.name)
+	}
+	return &HTTPError{Status: resp.StatusCode, Message: msg}
+}
+
+// push delivers a message from the server to receive
+func (t *httpTransport) push(msg []byte) {
+	select {
+	case t.incoming <- msg:
+	case <-t.ended:
+	}
+}
+
+func (t *httpTransport) receive() ([]byte, error) {
+	select {
+	case msg := <-t.incoming:
+		return msg, nil
+	case <-t.ended:
+		return nil, t.endErr
+	}
+}
+
+// end closes the connection, recording why
+func (t *httpTransport) end(err error) {
+	t.endOnce.Do(func() {
+		t.endErr = err
+		close(t.ended)
+		t.cancel()
+	})
+}
+
+func (t *httpTransport) done() <-chan struct{} {
+	return t.ended
+}
+
+func (t *httpTransport) err() error {
+	select {
+	case <-t.ended:
+		return t.endErr
+	default:
+		return nil
+	}
+}
+
+func (t *httpTransport) kill() {
+	t.end(errors.New("connection closed"))
+}
+
+// close ends the session on the server, if it assigned one
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	session := t.sessionID
+	t.mu.Unlock()
+	if session != "" && t.err() == nil {
+		ctx, cancel := context.WithTimeout(t.ctx, 5*time.Second)
+		if req, err := t.newRequest(ctx, http.MethodDelete, t.base.String(), nil); err == nil {
+			if resp, err := t.client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+		cancel()
+	}
+	t.kill()
+	return nil
+}
+
+// readEvents reads a server-sent event stream, calling fn for each event
+// until the stream ends
+func readEvents(r io.Reader, fn func(event, data string)) error {
+	reader := bufio.NewReader(r)
+	var event string
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				fn(event, strings.Join(data, "\n"))
+			}
+			event, data = "", nil
+		case field == "event":
+			event = value
+		case field == "data":
+			data = append(data, value)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Package mcp provides MCP (Model Context Protocol) client implementation.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package mcp
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSSEEndpoint(t *testing.T) {
+	base, err := url.Parse("https://mcp.example.com/sse?token=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data string
+		want string // "" if the endpoint is refused
+	}{
+		{"relative path", "/messages?session=1", "https://mcp.example.com/messages?session=1"},
+		{"relative to base", "messages", "https://mcp.example.com/messages"},
+		{"surrounding whitespace", " /messages\n", "https://mcp.example.com/messages"},
+		{"same origin", "https://mcp.example.com/messages", "https://mcp.example.com/messages"},
+		{"origin case", "HTTPS://MCP.example.com/messages", "https://MCP.example.com/messages"},
+		{"other host", "https://evil.example.net/messages", ""},
+		{"protocol-relative other host", "//evil.example.net/messages", ""},
+		{"subdomain", "https://a.mcp.example.com/messages", ""},
+		{"other port", "https://mcp.example.com:8443/messages", ""},
+		{"other scheme", "http://mcp.example.com/messages", ""},
+		{"userinfo trick", "https://mcp.example.com@evil.example.net/messages", ""},
+		{"invalid", "https://[::1/messages", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sseEndpoint(base, tt.data)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("sseEndpoint(%q) = %q, want an error", tt.data, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("sseEndpoint(%q) = %q, %v, want %q", tt.data, got, err, tt.want)
+			}
+		})
+	}
+}
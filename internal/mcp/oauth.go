@@ -0,0 +1,494 @@
+// Package mcp provides MCP (Model Context Protocol) client implementation.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+const callbackPath = "/oauth/callback"
+
+// authServerMetadata is OAuth authorization server metadata (RFC 8414)
+type authServerMetadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	RegistrationEndpoint  string   `json:"registration_endpoint,omitempty"`
+	ScopesSupported       []string `json:"scopes_supported,omitempty"`
+}
+
+// resourceMetadata is OAuth protected resource metadata (RFC 9728)
+type resourceMetadata struct {
+	Resource             string   `json:"resource"`
+	AuthorizationServers []string `json:"authorization_servers"`
+	ScopesSupported      []string `json:"scopes_supported,omitempty"`
+}
+
+// tokenResponse is the token endpoint response
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	Error        string `json:"error"`
+	ErrorDesc    string `json:"error_description"`
+}
+
+var resourceMetadataRe = regexp.MustCompile(`resource_metadata="([^"]+)"`)
+
+// UsesOAuth reports whether a server is configured for OAuth or already has
+// stored tokens
+func UsesOAuth(name string, server config.MCPServerConfig) bool {
+	if server.URL == "" {
+		return false
+	}
+	if server.OAuth != nil && server.OAuth.Enabled {
+		return true
+	}
+	creds, _ := LoadOAuthCredentials(name, server.URL)
+	return creds != nil
+}
+
+// Authorize runs the OAuth authorization code flow with PKCE for a remote
+// MCP server: it discovers the authorization server, registers gmn as a
+// client if no client ID is configured, opens the browser and waits for the
+// redirect. The tokens are stored and returned.
+func Authorize(ctx context.Context, name string, server config.MCPServerConfig, out io.Writer) (*OAuthCredentials, error) {
+	if server.URL == "" {
+		return nil, fmt.Errorf("MCP server '%s' has no URL; OAuth only applies to HTTP/SSE servers", name)
+	}
+	oauthCfg := config.MCPOAuthConfig{}
+	if server.OAuth != nil {
+		oauthCfg = *server.OAuth
+	}
+
+	meta := &authServerMetadata{
+		AuthorizationEndpoint: oauthCfg.AuthorizationURL,
+		TokenEndpoint:         oauthCfg.TokenURL,
+	}
+	var resourceScopes []string
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" {
+		discovered, scopes, err := discoverOAuth(ctx, server.URL)
+		if err != nil {
+			return nil, err
+		}
+		if meta.AuthorizationEndpoint == "" {
+			meta.AuthorizationEndpoint = discovered.AuthorizationEndpoint
+		}
+		if meta.TokenEndpoint == "" {
+			meta.TokenEndpoint = discovered.TokenEndpoint
+		}
+		meta.RegistrationEndpoint = discovered.RegistrationEndpoint
+		resourceScopes = scopes
+	}
+
+	scopes := oauthCfg.Scopes
+	if len(scopes) == 0 {
+		scopes = resourceScopes
+	}
+
+	// Listen for the redirect before registering, since the redirect URI
+	// includes the port. The listener and the redirect use the same host,
+	// so a browser that resolves localhost to ::1 still reaches it.
+	listenAddr := "127.0.0.1:0"
+	redirectURI := oauthCfg.RedirectURI
+	if redirectURI != "" {
+		u, err := url.Parse(redirectURI)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirectUri: %w", err)
+		}
+		listenAddr = net.JoinHostPort(u.Hostname(), u.Port())
+	}
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the OAuth redirect: %w", err)
+	}
+	defer listener.Close()
+	if redirectURI == "" {
+		redirectURI = fmt.Sprintf("http://127.0.0.1:%d%s", listener.Addr().(*net.TCPAddr).Port, callbackPath)
+	}
+	u, _ := url.Parse(redirectURI)
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	clientID := oauthCfg.ClientID
+	if clientID == "" {
+		// Reuse an earlier registration for the same token endpoint
+		if prev, _ := LoadOAuthCredentials(name, server.URL); prev != nil && prev.ClientID != "" && prev.TokenURL == meta.TokenEndpoint {
+			clientID = prev.ClientID
+		}
+	}
+	if clientID == "" {
+		if meta.RegistrationEndpoint == "" {
+			return nil, fmt.Errorf("the authorization server does not support dynamic client registration; set oauth.clientId for '%s'", name)
+		}
+		clientID, err = registerClient(ctx, meta.RegistrationEndpoint, redirectURI, scopes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	verifier := randomString(32)
+	challenge := sha256.Sum256([]byte(verifier))
+	state := randomString(16)
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", clientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+	params.Set("state", state)
+	params.Set("resource", server.URL)
+	if len(scopes) > 0 {
+		params.Set("scope", strings.Join(scopes, " "))
+	}
+	authURL := meta.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + params.Encode()
+	} else {
+		authURL += "?" + params.Encode()
+	}
+
+	type callback struct {
+		code string
+		err  error
+	}
+	results := make(chan callback, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var cb callback
+		switch {
+		case q.Get("state") != state:
+			cb.err = fmt.Errorf("OAuth state mismatch")
+		case q.Get("error") != "":
+			cb.err = fmt.Errorf("authorization failed: %s %s", q.Get("error"), q.Get("error_description"))
+		case q.Get("code") == "":
+			cb.err = fmt.Errorf("authorization response has no code")
+		default:
+			cb.code = q.Get("code")
+		}
+		if cb.err != nil {
+			http.Error(w, cb.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprint(w, "<html><body><h3>gmn: authentication complete</h3><p>You can close this window.</p></body></html>")
+		}
+		select {
+		case results <- cb:
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	fmt.Fprintf(out, "Opening the browser to authorize '%s'. If it does not open, visit:\n\n  %s\n\n", name, authURL)
+	openBrowser(authURL)
+
+	var cb callback
+	select {
+	case cb = <-results:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out waiting for authorization: %w", ctx.Err())
+	}
+	if cb.err != nil {
+		return nil, cb.err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", cb.code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", clientID)
+	form.Set("code_verifier", verifier)
+	form.Set("resource", server.URL)
+	if oauthCfg.ClientSecret != "" {
+		form.Set("client_secret", oauthCfg.ClientSecret)
+	}
+	token, err := requestToken(ctx, meta.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &OAuthCredentials{
+		ServerName:   name,
+		Token:        *token,
+		ClientID:     clientID,
+		TokenURL:     meta.TokenEndpoint,
+		MCPServerURL: server.URL,
+	}
+	if err := SaveOAuthCredentials(creds); err != nil {
+		return nil, fmt.Errorf("failed to save token: %w", err)
+	}
+	return creds, nil
+}
+
+// AccessToken returns a valid access token for a server, refreshing and
+// storing it if it has expired
+func AccessToken(ctx context.Context, name string, server config.MCPServerConfig) (string, error) {
+	creds, err := LoadOAuthCredentials(name, server.URL)
+	if err != nil {
+		return "", err
+	}
+	if creds == nil {
+		return "", fmt.Errorf("MCP server '%s' requires authorization: run 'gmn mcp auth %s'", name, name)
+	}
+	if !creds.Token.Expired() {
+		return creds.Token.AccessToken, nil
+	}
+	if creds.Token.RefreshToken == "" || creds.TokenURL == "" {
+		return "", fmt.Errorf("OAuth token for '%s' has expired: run 'gmn mcp auth %s'", name, name)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", creds.Token.RefreshToken)
+	form.Set("client_id", creds.ClientID)
+	form.Set("resource", server.URL)
+	if server.OAuth != nil && server.OAuth.ClientSecret != "" {
+		form.Set("client_secret", server.OAuth.ClientSecret)
+	}
+	token, err := requestToken(ctx, creds.TokenURL, form)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh OAuth token for '%s' (run 'gmn mcp auth %s'): %w", name, name, err)
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = creds.Token.RefreshToken
+	}
+	creds.Token = *token
+	if err := SaveOAuthCredentials(creds); err != nil {
+		return "", fmt.Errorf("failed to save token: %w", err)
+	}
+	return creds.Token.AccessToken, nil
+}
+
+// discoverOAuth finds the authorization server of an MCP server through its
+// protected resource metadata, falling back to the server's own origin
+func discoverOAuth(ctx context.Context, serverURL string) (*authServerMetadata, []string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	origin := u.Scheme + "://" + u.Host
+	path := strings.TrimSuffix(u.Path, "/")
+
+	// The server names its metadata in the 401 challenge; otherwise try
+	// the well-known locations
+	var candidates []string
+	if metaURL := probeResourceMetadata(ctx, serverURL); metaURL != "" {
+		candidates = append(candidates, metaURL)
+	}
+	if path != "" {
+		candidates = append(candidates, origin+"/.well-known/oauth-protected-resource"+path)
+	}
+	candidates = append(candidates, origin+"/.well-known/oauth-protected-resource")
+
+	issuer := origin
+	var scopes []string
+	for _, c := range candidates {
+		var rm resourceMetadata
+		if getJSON(ctx, c, &rm) == nil && len(rm.AuthorizationServers) > 0 {
+			issuer = strings.TrimSuffix(rm.AuthorizationServers[0], "/")
+			scopes = rm.ScopesSupported
+			break
+		}
+	}
+
+	iu, err := url.Parse(issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid authorization server %q: %w", issuer, err)
+	}
+	issuerOrigin := iu.Scheme + "://" + iu.Host
+	issuerPath := strings.TrimSuffix(iu.Path, "/")
+	candidates = []string{
+		issuerOrigin + "/.well-known/oauth-authorization-server" + issuerPath,
+		issuerOrigin + "/.well-known/openid-configuration" + issuerPath,
+	}
+	if issuerPath != "" {
+		candidates = append(candidates, issuer+"/.well-known/openid-configuration")
+	}
+	for _, c := range candidates {
+		var meta authServerMetadata
+		if getJSON(ctx, c, &meta) == nil && meta.AuthorizationEndpoint != "" && meta.TokenEndpoint != "" {
+			if len(scopes) == 0 {
+				scopes = meta.ScopesSupported
+			}
+			return &meta, scopes, nil
+		}
+	}
+
+	// Servers without metadata use default endpoints on their origin
+	if issuer == origin {
+		return &authServerMetadata{
+			AuthorizationEndpoint: origin + "/authorize",
+			TokenEndpoint:         origin + "/token",
+			RegistrationEndpoint:  origin + "/register",
+		}, scopes, nil
+	}
+	return nil, nil, fmt.Errorf("could not discover OAuth endpoints of %s; set oauth.authorizationUrl and oauth.tokenUrl", issuer)
+}
+
+// probeResourceMetadata requests the server without a token and returns
+// the resource metadata URL from its WWW-Authenticate challenge
+func probeResourceMetadata(ctx context.Context, serverURL string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverURL, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		return ""
+	}
+	if m := resourceMetadataRe.FindStringSubmatch(resp.Header.Get("WWW-Authenticate")); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// registerClient registers gmn as a public OAuth client (RFC 7591)
+func registerClient(ctx context.Context, endpoint, redirectURI string, scopes []string) (string, error) {
+	body := map[string]interface{}{
+		"client_name":                "gmn",
+		"redirect_uris":              []string{redirectURI},
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	}
+	if len(scopes) > 0 {
+		body["scope"] = strings.Join(scopes, " ")
+	}
+	data, _ := json.Marshal(body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(string(data)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("client registration failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("client registration failed (status %d): %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var reg struct {
+		ClientID string `json:"client_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reg); err != nil || reg.ClientID == "" {
+		return "", fmt.Errorf("client registration returned no client_id")
+	}
+	return reg.ClientID, nil
+}
+
+// requestToken posts a token request and parses the response
+func requestToken(ctx context.Context, endpoint string, form url.Values) (*OAuthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Some servers (e.g. GitHub) answer form-encoded unless asked for JSON
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tr tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("token request failed (status %d): invalid response", resp.StatusCode)
+	}
+	if tr.Error != "" {
+		return nil, fmt.Errorf("token request failed: %s %s", tr.Error, tr.ErrorDesc)
+	}
+	if resp.StatusCode != http.StatusOK || tr.AccessToken == "" {
+		return nil, fmt.Errorf("token request failed (status %d)", resp.StatusCode)
+	}
+
+	token := &OAuthToken{
+		AccessToken:  tr.AccessToken,
+		RefreshToken: tr.RefreshToken,
+		TokenType:    tr.TokenType,
+		Scope:        tr.Scope,
+	}
+	if token.TokenType == "" {
+		token.TokenType = "Bearer"
+	}
+	if tr.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second).UnixMilli()
+	}
+	return token, nil
+}
+
+// getJSON fetches a JSON document
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// randomString returns n random bytes, base64url encoded
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// openBrowser opens url in the default browser, ignoring failures since
+// the URL is also printed
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if cmd.Start() == nil {
+		go cmd.Wait()
+	}
+}
@@ -0,0 +1,212 @@
+// Package mcp provides MCP (Model Context Protocol) client implementation.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkalls/gmn/internal/api"
+)
+
+// Root is a directory the client exposes to servers (MCP roots)
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+}
+
+// WorkspaceRoots returns the roots advertising dir as the workspace
+func WorkspaceRoots(dir string) []Root {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path // Windows drive paths
+	}
+	return []Root{{URI: u.String(), Name: filepath.Base(abs)}}
+}
+
+// SamplingContent is the content of a sampling message. Only text is
+// supported.
+type SamplingContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// SamplingMessage is one message of a sampling request
+type SamplingMessage struct {
+	Role    string          `json:"role"` // "user" or "assistant"
+	Content SamplingContent `json:"content"`
+}
+
+// SamplingRequest are the params of sampling/createMessage, in which a
+// server asks the client's model for a completion
+type SamplingRequest struct {
+	Messages         []SamplingMessage `json:"messages"`
+	SystemPrompt     string            `json:"systemPrompt,omitempty"`
+	MaxTokens        int               `json:"maxTokens"`
+	Temperature      *float64          `json:"temperature,omitempty"`
+	StopSequences    []string          `json:"stopSequences,omitempty"`
+	ModelPreferences *struct {
+		Hints []struct {
+			Name string `json:"name"`
+		} `json:"hints,omitempty"`
+		CostPriority         *float64 `json:"costPriority,omitempty"`
+		SpeedPriority        *float64 `json:"speedPriority,omitempty"`
+		IntelligencePriority *float64 `json:"intelligencePriority,omitempty"`
+	} `json:"modelPreferences,omitempty"`
+}
+
+// SamplingResult is the result of sampling/createMessage
+type SamplingResult struct {
+	Role       string          `json:"role"`
+	Content    SamplingContent `json:"content"`
+	Model      string          `json:"model"`
+	StopReason string          `json:"stopReason,omitempty"`
+}
+
+// SamplingHandler answers sampling requests from a server
+type SamplingHandler func(ctx context.Context, server string, req *SamplingRequest) (*SamplingResult, error)
+
+// SamplingConfirm asks the user whether a server may run a sampling request
+type SamplingConfirm func(server string, req *SamplingRequest) bool
+
+// GeminiSampler answers sampling requests with Gemini after the user
+// confirms them. newClient is called on the first request, so commands
+// only authenticate when a server actually asks for sampling. A model hint
+// naming a Gemini model is honored; otherwise model is used.
+func GeminiSampler(newClient func(ctx context.Context) (*api.Client, string, error), model string, confirm SamplingConfirm) SamplingHandler {
+	var mu sync.Mutex
+	var client *api.Client
+	var projectID string
+
+	return func(ctx context.Context, server string, req *SamplingRequest) (*SamplingResult, error) {
+		if !confirm(server, req) {
+			return nil, fmt.Errorf("the user declined the sampling request")
+		}
+
+		mu.Lock()
+		if client == nil {
+			c, p, err := newClient(ctx)
+			if err != nil {
+				mu.Unlock()
+				return nil, err
+			}
+			client, projectID = c, p
+		}
+		mu.Unlock()
+
+		useModel := model
+		if req.ModelPreferences != nil {
+			for _, hint := range req.ModelPreferences.Hints {
+				if strings.HasPrefix(hint.Name, "gemini-") {
+					useModel = hint.Name
+					break
+				}
+			}
+		}
+
+		contents := make([]api.Content, 0, len(req.Messages))
+		for _, m := range req.Messages {
+			if m.Content.Type != "text" {
+				return nil, fmt.Errorf("unsupported sampling content type %q (only text is supported)", m.Content.Type)
+			}
+			role := "user"
+			if m.Role == "assistant" {
+				role = "model"
+			}
+			contents = append(contents, api.Content{Role: role, Parts: []api.Part{{Text: m.Content.Text}}})
+		}
+
+		genReq := &api.GenerateRequest{
+			Model:        useModel,
+			Project:      projectID,
+			UserPromptID: fmt.Sprintf("gmn-sampling-%d", time.Now().UnixNano()),
+			Request: api.InnerRequest{
+				Contents: contents,
+				Config:   api.GenerationConfig{MaxOutputTokens: req.MaxTokens},
+			},
+		}
+		if req.Temperature != nil {
+			genReq.Request.Config.Temperature = *req.Temperature
+		}
+		if req.SystemPrompt != "" {
+			genReq.Request.SystemInstruction = &api.Content{Role: "user", Parts: []api.Part{{Text: req.SystemPrompt}}}
+		}
+
+		resp, err := client.Generate(ctx, genReq)
+		if err != nil {
+			return nil, fmt.Errorf("sampling failed: %w", err)
+		}
+
+		var text strings.Builder
+		stopReason := "endTurn"
+		if len(resp.Response.Candidates) > 0 {
+			candidate := resp.Response.Candidates[0]
+			for _, p := range candidate.Content.Parts {
+				text.WriteString(p.Text)
+			}
+			if candidate.FinishReason == "MAX_TOKENS" {
+				stopReason = "maxTokens"
+			}
+		}
+		return &SamplingResult{
+			Role:       "assistant",
+			Content:    SamplingContent{Type: "text", Text: text.String()},
+			Model:      useModel,
+			StopReason: stopReason,
+		}, nil
+	}
+}
+
+// SamplingPreview summarizes a sampling request for a confirmation prompt
+func SamplingPreview(req *SamplingRequest) map[string]interface{} {
+	preview := map[string]interface{}{"maxTokens": req.MaxTokens}
+	if req.SystemPrompt != "" {
+		preview["systemPrompt"] = req.SystemPrompt
+	}
+	messages := make([]string, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		text := m.Content.Text
+		if m.Content.Type != "text" {
+			text = "[" + m.Content.Type + "]"
+		}
+		messages = append(messages, m.Role+": "+text)
+	}
+	preview["messages"] = messages
+	// Show what model the server asked for, so the user can judge the
+	// request; only a gemini-* hint changes the model used
+	if prefs := req.ModelPreferences; prefs != nil {
+		shown := make(map[string]interface{})
+		if len(prefs.Hints) > 0 {
+			hints := make([]string, 0, len(prefs.Hints))
+			for _, hint := range prefs.Hints {
+				hints = append(hints, hint.Name)
+			}
+			shown["hints"] = hints
+		}
+		for name, priority := range map[string]*float64{
+			"costPriority":         prefs.CostPriority,
+			"speedPriority":        prefs.SpeedPriority,
+			"intelligencePriority": prefs.IntelligencePriority,
+		} {
+			if priority != nil {
+				shown[name] = *priority
+			}
+		}
+		if len(shown) > 0 {
+			preview["modelPreferences"] = shown
+		}
+	}
+	return preview
+}
@@ -0,0 +1,179 @@
+// Package mcp provides MCP (Model Context Protocol) client implementation.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// serverProtocolVersions are the protocol versions Server speaks, newest
+// first
+var serverProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// Server offers tools to an MCP client over a stream of JSON lines, as
+// stdio servers do
+type Server struct {
+	Name    string
+	Version string
+	Tools   []Tool
+	// Call runs a tool, returning its output as text and whether it failed.
+	// Calls may run concurrently; ctx ends when the client cancels the call.
+	Call func(ctx context.Context, name string, args map[string]interface{}) (text string, isError bool)
+
+	writeMu sync.Mutex
+	w       io.Writer
+
+	callsMu sync.Mutex
+	calls   map[string]context.CancelFunc // Running tool calls by request ID
+}
+
+// Serve answers requests read from r until it ends or ctx is done. Tool
+// calls run in the background so a slow one does not hold up the rest.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.w = w
+	s.calls = make(map[string]context.CancelFunc)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Calls still running when the input ends are answered before returning
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		var msg jsonRPCResponse
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			s.reply(json.RawMessage("null"), nil, &jsonRPCError{Code: -32700, Message: "parse error"})
+			continue
+		}
+		if msg.Method == "" {
+			continue // A response; Server sends no requests
+		}
+		if len(msg.ID) == 0 {
+			s.notification(&msg)
+			continue
+		}
+		if msg.Method == "tools/call" {
+			callCtx, cancelCall := context.WithCancel(ctx)
+			s.callsMu.Lock()
+			s.calls[string(msg.ID)] = cancelCall
+			s.callsMu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer s.finishCall(msg.ID)
+				s.callTool(callCtx, &msg)
+			}()
+			continue
+		}
+		s.handle(&msg)
+	}
+	return scanner.Err()
+}
+
+// handle answers a request other than tools/call
+func (s *Server) handle(msg *jsonRPCResponse) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		version := serverProtocolVersions[0]
+		if slices.Contains(serverProtocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		s.reply(msg.ID, map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+		}, nil)
+	case "ping":
+		s.reply(msg.ID, map[string]interface{}{}, nil)
+	case "tools/list":
+		tools := s.Tools
+		if tools == nil {
+			tools = []Tool{}
+		}
+		s.reply(msg.ID, map[string]interface{}{"tools": tools}, nil)
+	default:
+		s.reply(msg.ID, nil, &jsonRPCError{Code: -32601, Message: "method not found: " + msg.Method})
+	}
+}
+
+// notification handles a message that needs no answer
+func (s *Server) notification(msg *jsonRPCResponse) {
+	if msg.Method != "notifications/cancelled" {
+		return
+	}
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(msg.Params, &params) == nil {
+		s.callsMu.Lock()
+		if cancel, ok := s.calls[string(params.RequestID)]; ok {
+			cancel()
+		}
+		s.callsMu.Unlock()
+	}
+}
+
+// callTool runs a tool and answers with its output
+func (s *Server) callTool(ctx context.Context, msg *jsonRPCResponse) {
+	var params struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		s.reply(msg.ID, nil, &jsonRPCError{Code: -32602, Message: fmt.Sprintf("invalid params: %v", err)})
+		return
+	}
+	if !slices.ContainsFunc(s.Tools, func(t Tool) bool { return t.Name == params.Name }) {
+		s.reply(msg.ID, nil, &jsonRPCError{Code: -32602, Message: "unknown tool: " + params.Name})
+		return
+	}
+
+	text, isError := s.Call(ctx, params.Name, params.Arguments)
+	if ctx.Err() != nil {
+		return // Cancelled calls are not answered
+	}
+	s.reply(msg.ID, map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}, nil)
+}
+
+// finishCall forgets a tool call once it has been answered
+func (s *Server) finishCall(id json.RawMessage) {
+	s.callsMu.Lock()
+	if cancel, ok := s.calls[string(id)]; ok {
+		cancel()
+		delete(s.calls, string(id))
+	}
+	s.callsMu.Unlock()
+}
+
+// reply writes a response line
+func (s *Server) reply(id json.RawMessage, result interface{}, rpcErr *jsonRPCError) {
+	resp := struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  interface{}     `json:"result,omitempty"`
+		Error   *jsonRPCError   `json:"error,omitempty"`
+	}{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.w.Write(append(data, '\n'))
+}
@@ -0,0 +1,191 @@
+// Package mcp provides MCP (Model Context Protocol) client implementation.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+// oauthTokensFile stores MCP OAuth tokens in ~/.gemini. The format is the
+// one the official Gemini CLI uses, so servers authorized there work in gmn
+// and vice versa.
+const oauthTokensFile = "mcp-oauth-tokens.json"
+
+var tokensMu sync.Mutex
+
+// OAuthToken is an OAuth access token for an MCP server
+type OAuthToken struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	ExpiresAt    int64  `json:"expiresAt,omitempty"` // Unix milliseconds
+	TokenType    string `json:"tokenType"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// Expired reports whether the token is expired or about to expire
+func (t *OAuthToken) Expired() bool {
+	if t.ExpiresAt == 0 {
+		return false
+	}
+	return time.Now().Add(time.Minute).After(time.UnixMilli(t.ExpiresAt))
+}
+
+// OAuthCredentials are the stored OAuth tokens of one MCP server
+type OAuthCredentials struct {
+	ServerName   string     `json:"serverName"`
+	Token        OAuthToken `json:"token"`
+	ClientID     string     `json:"clientId,omitempty"`
+	TokenURL     string     `json:"tokenUrl,omitempty"`
+	MCPServerURL string     `json:"mcpServerUrl,omitempty"`
+	UpdatedAt    int64      `json:"updatedAt"`
+}
+
+func tokensPath() (string, error) {
+	geminiPath, err := config.GeminiDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(geminiPath, oauthTokensFile), nil
+}
+
+// readTokens returns the raw entries of the token file, keeping fields gmn
+// does not know about
+func readTokens(path string) ([]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+func entryServer(entry json.RawMessage) string {
+	var e struct {
+		ServerName string `json:"serverName"`
+	}
+	json.Unmarshal(entry, &e)
+	return e.ServerName
+}
+
+// LoadOAuthCredentials returns the stored credentials of a server at
+// serverURL, or nil if it has not been authorized there. Tokens are only
+// used for the URL they were issued for, so settings that point a server
+// of the same name elsewhere never get them.
+func LoadOAuthCredentials(serverName, serverURL string) (*OAuthCredentials, error) {
+	creds, err := loadCredentials(serverName)
+	if err != nil || creds == nil || creds.MCPServerURL != serverURL {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// loadCredentials returns the stored credentials of a server for any URL
+func loadCredentials(serverName string) (*OAuthCredentials, error) {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+
+	path, err := tokensPath()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readTokens(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entryServer(entry) != serverName {
+			continue
+		}
+		var creds OAuthCredentials
+		if err := json.Unmarshal(entry, &creds); err != nil {
+			return nil, fmt.Errorf("failed to parse OAuth token for %s: %w", serverName, err)
+		}
+		return &creds, nil
+	}
+	return nil, nil
+}
+
+// SaveOAuthCredentials stores the credentials of a server, replacing any
+// previous ones
+func SaveOAuthCredentials(creds *OAuthCredentials) error {
+	creds.UpdatedAt = time.Now().UnixMilli()
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	return updateTokens(creds.ServerName, data)
+}
+
+// DeleteOAuthCredentials removes the stored credentials of a server. It
+// reports whether there were any.
+func DeleteOAuthCredentials(serverName string) (bool, error) {
+	existing, err := loadCredentials(serverName)
+	if err != nil || existing == nil {
+		return false, err
+	}
+	return true, updateTokens(serverName, nil)
+}
+
+// updateTokens replaces the entry of serverName with entry, or removes it
+// if entry is nil
+func updateTokens(serverName string, entry json.RawMessage) error {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+
+	path, err := tokensPath()
+	if err != nil {
+		return err
+	}
+	entries, err := readTokens(path)
+	if err != nil {
+		return err
+	}
+
+	updated := make([]json.RawMessage, 0, len(entries)+1)
+	for _, e := range entries {
+		if entryServer(e) != serverName {
+			updated = append(updated, e)
+		}
+	}
+	if entry != nil {
+		updated = append(updated, entry)
+	}
+
+	data, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// Through a temporary file, so a crash or a full disk cannot leave the
+	// tokens of every server half written
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mcp-oauth-tokens-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
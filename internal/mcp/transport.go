@@ -0,0 +1,145 @@
+// Package mcp provides MCP (Model Context Protocol) client implementation.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// transport carries JSON-RPC messages between the client and a server. The
+// client holds its lock around send and receive, so transports need not
+// serialize them.
+type transport interface {
+	// send delivers one message to the server
+	send(ctx context.Context, msg []byte) error
+	// receive returns the next message from the server. The slice may be
+	// reused by the next call.
+	receive() ([]byte, error)
+	// done is closed when the connection has ended; err tells why
+	done() <-chan struct{}
+	err() error
+	// kill ends the connection at once, unblocking send and receive
+	kill()
+	// close ends the connection gracefully
+	close() error
+}
+
+// stdioTransport talks to a server process over its stdin and stdout
+type stdioTransport struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  io.ReadCloser
+	scanner *bufio.Scanner
+	exited  chan struct{} // Closed when the server process exits
+	exitErr error
+}
+
+// startStdio starts a server process
+func startStdio(command string, args []string, env map[string]string, dir string) (*stdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Dir = dir
+
+	// Set environment
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
+	}
+
+	// A plain pipe rather than StdoutPipe, which Wait closes even if
+	// output is still unread
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+	cmd.Stdout = stdoutWriter
+
+	// Redirect stderr to our stderr for debugging
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Start()
+	stdoutWriter.Close()
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		return nil, fmt.Errorf("failed to start MCP server: %w", err)
+	}
+
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		stdout:  stdout,
+		scanner: bufio.NewScanner(stdout),
+		exited:  make(chan struct{}),
+	}
+	// Tool results can be far larger than the default 64 KB line limit
+	t.scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	go func() {
+		t.exitErr = cmd.Wait()
+		close(t.exited)
+	}()
+	return t, nil
+}
+
+func (t *stdioTransport) send(_ context.Context, msg []byte) error {
+	_, err := t.stdin.Write(append(msg, '\n'))
+	return err
+}
+
+func (t *stdioTransport) receive() ([]byte, error) {
+	if !t.scanner.Scan() {
+		if err := t.scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		return nil, fmt.Errorf("EOF while reading response")
+	}
+	return t.scanner.Bytes(), nil
+}
+
+func (t *stdioTransport) done() <-chan struct{} {
+	return t.exited
+}
+
+func (t *stdioTransport) err() error {
+	select {
+	case <-t.exited:
+	default:
+		return nil
+	}
+	if t.exitErr != nil {
+		return fmt.Errorf("server exited: %w", t.exitErr)
+	}
+	return fmt.Errorf("server exited")
+}
+
+func (t *stdioTransport) kill() {
+	t.cmd.Process.Kill()
+	t.stdout.Close() // Unblocks the read even if a child holds the pipe
+	<-t.exited
+}
+
+// close closes the server's input; servers that do not exit then are
+// killed
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.exited:
+	case <-time.After(2 * time.Second):
+		t.cmd.Process.Kill()
+		<-t.exited
+	}
+	t.stdout.Close()
+	return t.exitErr
+}
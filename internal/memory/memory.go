@@ -0,0 +1,227 @@
+// Package memory keeps facts the model should remember across sessions in
+// Markdown files: a global one in the gmn data directory and GEMINI.md in
+// the project. Both are sent with every chat request.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+// Scopes of a memory file
+const (
+	ScopeGlobal  = "global"
+	ScopeProject = "project"
+)
+
+const (
+	// globalFile is the global memory file in the data directory
+	globalFile = "memory.md"
+	// ProjectFile is the project memory file in the working directory,
+	// shared with the official Gemini CLI
+	ProjectFile = "GEMINI.md"
+	// sectionHeader is the section facts are added to, as in the official CLI
+	sectionHeader = "## Gemini Added Memories"
+	// maxFileBytes caps how much of each file is sent to the model
+	maxFileBytes = 64 << 10
+)
+
+// File is a loaded memory file
+type File struct {
+	Scope   string
+	Path    string
+	Content string
+}
+
+// Store holds the memory files of a chat session. It is safe for
+// concurrent use.
+type Store struct {
+	cwd string
+
+	mu    sync.Mutex
+	files []File
+}
+
+// New creates a store for the project in cwd and loads its files
+func New(cwd string) *Store {
+	s := &Store{cwd: cwd}
+	s.Refresh()
+	return s
+}
+
+// Path returns the memory file of a scope
+func (s *Store) Path(scope string) (string, error) {
+	switch scope {
+	case ScopeGlobal, "":
+		dir, err := config.DataDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, globalFile), nil
+	case ScopeProject:
+		return filepath.Join(s.cwd, ProjectFile), nil
+	}
+	return "", fmt.Errorf("unknown memory scope %q (use %s or %s)", scope, ScopeGlobal, ScopeProject)
+}
+
+// Refresh re-reads the memory files, e.g. after they were edited by hand
+func (s *Store) Refresh() []File {
+	var files []File
+	for _, scope := range []string{ScopeGlobal, ScopeProject} {
+		path, err := s.Path(scope)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || strings.TrimSpace(string(data)) == "" {
+			continue
+		}
+		if len(data) > maxFileBytes {
+			data = data[:maxFileBytes]
+		}
+		files = append(files, File{Scope: scope, Path: path, Content: string(data)})
+	}
+
+	s.mu.Lock()
+	s.files = files
+	s.mu.Unlock()
+	return files
+}
+
+// Files returns the loaded memory files
+func (s *Store) Files() []File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]File(nil), s.files...)
+}
+
+// Instruction returns the memory as system instruction text, or "" if there
+// is none
+func (s *Store) Instruction() string {
+	var b strings.Builder
+	for _, f := range s.Files() {
+		fmt.Fprintf(&b, "--- Context from: %s ---\n%s\n--- End of Context from: %s ---\n", f.Path, strings.TrimSpace(f.Content), f.Path)
+	}
+	return b.String()
+}
+
+// Plan returns the path of the scope's file with its current and updated
+// content after adding fact, without writing anything
+func (s *Store) Plan(scope, fact string) (path, original, updated string, err error) {
+	fact = normalizeFact(fact)
+	if fact == "" {
+		return "", "", "", errors.New("fact is required and cannot be empty")
+	}
+	path, err = s.Path(scope)
+	if err != nil {
+		return "", "", "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", "", err
+	}
+	original = string(data)
+	return path, original, addFact(original, fact), nil
+}
+
+// Add saves a fact to the scope's file and reloads the memory
+func (s *Store) Add(scope, fact string) (path string, err error) {
+	path, _, updated, err := s.Plan(scope, fact)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		return "", err
+	}
+	s.Refresh()
+	return path, nil
+}
+
+// normalizeFact turns a fact into a single list item text
+func normalizeFact(fact string) string {
+	fact = strings.Join(strings.Fields(fact), " ")
+	return strings.TrimSpace(strings.TrimLeft(fact, "-* "))
+}
+
+// addFact appends "- fact" to the memories section of content, creating
+// the section at the end if it does not exist
+func addFact(content, fact string) string {
+	item := "- " + fact + "\n"
+	start := strings.Index(content, sectionHeader)
+	if start < 0 {
+		if content != "" {
+			content = strings.TrimRight(content, "\n") + "\n\n"
+		}
+		return content + sectionHeader + "\n" + item
+	}
+
+	// Insert at the end of the section, before the next heading
+	bodyStart := start + len(sectionHeader)
+	end := len(content)
+	if next := strings.Index(content[bodyStart:], "\n## "); next >= 0 {
+		end = bodyStart + next + 1
+	}
+	section := strings.TrimRight(content[:end], "\n") + "\n"
+	rest := content[end:]
+	if rest != "" {
+		rest = "\n" + rest
+	}
+	return section + item + rest
+}
+
+// CommandUsage describes the /memory command
+const CommandUsage = "Usage: /memory add [--project] <fact> | show | refresh"
+
+// RunCommand runs a /memory subcommand (add, show, refresh) and returns the
+// text to show the user
+func (s *Store) RunCommand(args string) (string, error) {
+	sub, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	rest = strings.TrimSpace(rest)
+
+	switch sub {
+	case "add":
+		scope := ScopeGlobal
+		if after, ok := strings.CutPrefix(rest, "--project"); ok {
+			scope, rest = ScopeProject, strings.TrimSpace(after)
+		}
+		if rest == "" {
+			return "", errors.New(CommandUsage)
+		}
+		path, err := s.Add(scope, rest)
+		if err != nil {
+			return "", err
+		}
+		return "Saved to " + path, nil
+
+	case "show", "":
+		files := s.Files()
+		if len(files) == 0 {
+			global, _ := s.Path(ScopeGlobal)
+			return fmt.Sprintf("No memory yet. Facts are saved to %s (global) or %s (project).", global, ProjectFile), nil
+		}
+		var b strings.Builder
+		for i, f := range files {
+			if i > 0 {
+				b.WriteString("\n\n")
+			}
+			fmt.Fprintf(&b, "%s (%s):\n%s", f.Path, f.Scope, strings.TrimSpace(f.Content))
+		}
+		return b.String(), nil
+
+	case "refresh":
+		files := s.Refresh()
+		return fmt.Sprintf("Reloaded %d memory file(s)", len(files)), nil
+	}
+	return "", errors.New(CommandUsage)
+}
@@ -0,0 +1,114 @@
+// Package notify tells the user a long task has finished through the
+// terminal's OSC 9 notification sequence, the platform's desktop
+// notification tool (notify-send or osascript) or the terminal bell.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Values of the notifications.method setting
+const (
+	MethodAuto     = "auto"     // Terminal where it shows them, else desktop, else bell
+	MethodTerminal = "terminal" // OSC 9 escape sequence
+	MethodDesktop  = "desktop"  // notify-send or osascript
+	MethodBell     = "bell"     // Terminal bell
+)
+
+// ErrUnavailable is returned when desktop notifications are not available
+var ErrUnavailable = errors.New("no desktop notification tool available")
+
+// Send shows a notification with title and body by method ("" is auto)
+func Send(method, title, body string) error {
+	switch method {
+	case MethodTerminal:
+		return writeTerminal(osc9(title + ": " + body))
+	case MethodDesktop:
+		return desktop(title, body)
+	case MethodBell:
+		return writeTerminal("\a")
+	case MethodAuto, "":
+		if terminalNotifies() {
+			return writeTerminal(osc9(title + ": " + body))
+		}
+		// Over SSH a desktop notification would show on the remote machine
+		if !isSSH() {
+			if err := desktop(title, body); !errors.Is(err, ErrUnavailable) {
+				return err
+			}
+		}
+		return writeTerminal("\a")
+	}
+	return fmt.Errorf("unknown notification method %q (use %s, %s, %s or %s)",
+		method, MethodAuto, MethodTerminal, MethodDesktop, MethodBell)
+}
+
+// terminalNotifies reports whether the terminal is known to turn OSC 9
+// into a notification
+func terminalNotifies() bool {
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "ghostty":
+		return true
+	}
+	return strings.Contains(os.Getenv("TERM"), "kitty")
+}
+
+// isSSH reports whether gmn runs in an SSH session
+func isSSH() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+// osc9 returns the OSC 9 sequence showing text as a notification, without
+// control characters that would end it early
+func osc9(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, text)
+	return "\x1b]9;" + text + "\x07"
+}
+
+// writeTerminal writes seq to the terminal, wrapped for tmux so it passes
+// it on
+func writeTerminal(seq string) error {
+	var w io.Writer = os.Stderr
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		defer tty.Close()
+		w = tty
+	}
+	if os.Getenv("TMUX") != "" && strings.HasPrefix(seq, "\x1b") {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	_, err := io.WriteString(w, seq)
+	return err
+}
+
+// desktop shows a desktop notification with the platform's tool
+func desktop(title, body string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleString(body), appleString(title))
+		return exec.Command("osascript", "-e", script).Run()
+	case "windows":
+		return ErrUnavailable
+	}
+	if _, err := exec.LookPath("notify-send"); err != nil {
+		return ErrUnavailable
+	}
+	return exec.Command("notify-send", "--app-name=gmn", title, body).Run()
+}
+
+// appleString quotes s as an AppleScript string
+func appleString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,44 @@
+// Package session provides session management for gmn chat.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package session
+
+// ToolCall is a function call the model made in a session
+type ToolCall struct {
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// Activity is what happened in a session: the prompts the user typed and
+// the tools the model called, in order
+type Activity struct {
+	Prompts   []string   `json:"prompts"`
+	ToolCalls []ToolCall `json:"tool_calls"`
+}
+
+// Activity extracts the prompts and tool calls of a session. Sessions saved
+// before tool calls were stored only have prompts.
+func (s *Session) Activity() Activity {
+	var a Activity
+	for _, msg := range s.Messages {
+		role, _ := msg["role"].(string)
+		if role == "user" {
+			if text := MessageText(msg); text != "" {
+				a.Prompts = append(a.Prompts, text)
+			}
+			continue
+		}
+		parts, _ := msg["parts"].([]interface{})
+		for _, p := range parts {
+			partMap, _ := p.(map[string]interface{})
+			call, ok := partMap["functionCall"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := call["name"].(string)
+			args, _ := call["args"].(map[string]interface{})
+			a.ToolCalls = append(a.ToolCalls, ToolCall{Name: name, Args: args})
+		}
+	}
+	return a
+}
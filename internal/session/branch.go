@@ -0,0 +1,39 @@
+// Package session provides session management for gmn chat.
+// SPDX-License-Identifier: Apache-2.0
+package session
+
+import (
+	"fmt"
+	"time"
+)
+
+// Branch forks parent into a new session that shares its history so far.
+// The new session records parent's ID and becomes the current session.
+func (m *Manager) Branch(parent *Session, name string) (*Session, error) {
+	now := time.Now()
+	base := now.Format("20060102-150405")
+
+	messages := make([]map[string]interface{}, len(parent.Messages))
+	copy(messages, parent.Messages)
+
+	s := &Session{
+		ID:        base,
+		Name:      name,
+		ParentID:  parent.ID,
+		Model:     parent.Model,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Messages:  messages,
+		Tokens:    parent.Tokens,
+	}
+	// The parent may have been created within the same second
+	for i := 1; m.exists(s.ID); i++ {
+		s.ID = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	if err := m.Save(s); err != nil {
+		return nil, err
+	}
+	m.currentID = s.ID
+	return s, nil
+}
@@ -0,0 +1,130 @@
+// Package session provides session management for gmn chat.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package session
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+)
+
+// EnvSessionKey overrides the keychain with a base64-encoded 32-byte key,
+// for machines without an OS keychain (e.g. headless Linux)
+const EnvSessionKey = "GMN_SESSION_KEY"
+
+const (
+	keyringService = "gmn"
+	keyringUser    = "session-key"
+	keySize        = 32 // AES-256
+)
+
+// encryptedMagic prefixes encrypted session files so plain and encrypted
+// sessions can live side by side
+var encryptedMagic = []byte("GMNENC1\n")
+
+// sealer encrypts session files with AES-256-GCM. The key is read from the
+// OS keychain on first use and created there if missing.
+type sealer struct {
+	once sync.Once
+	aead cipher.AEAD
+	err  error
+}
+
+// isEncrypted reports whether data is an encrypted session file
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// seal encrypts data as magic || nonce || ciphertext
+func (s *sealer) seal(data []byte) ([]byte, error) {
+	aead, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := append([]byte{}, encryptedMagic...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, encryptedMagic), nil
+}
+
+// open decrypts data produced by seal
+func (s *sealer) open(data []byte) ([]byte, error) {
+	aead, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted session is truncated")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, encryptedMagic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt session (wrong key?)")
+	}
+	return plain, nil
+}
+
+// cipher loads the key once and returns the AEAD
+func (s *sealer) cipher() (cipher.AEAD, error) {
+	s.once.Do(func() {
+		key, err := loadKey()
+		if err != nil {
+			s.err = err
+			return
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			s.err = fmt.Errorf("invalid session key: %w", err)
+			return
+		}
+		s.aead, s.err = cipher.NewGCM(block)
+	})
+	return s.aead, s.err
+}
+
+// loadKey returns the session key from $GMN_SESSION_KEY or the OS keychain,
+// generating and storing a new key in the keychain if none exists
+func loadKey() ([]byte, error) {
+	if v := os.Getenv(EnvSessionKey); v != "" {
+		return decodeKey(v, EnvSessionKey)
+	}
+
+	v, err := keyring.Get(keyringService, keyringUser)
+	if err == nil {
+		return decodeKey(v, "keychain entry")
+	}
+	if !errors.Is(err, keyring.ErrNotFound) {
+		return nil, fmt.Errorf("OS keychain unavailable (%v); set %s to a base64-encoded 32-byte key", err, EnvSessionKey)
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate session key: %w", err)
+	}
+	if err := keyring.Set(keyringService, keyringUser, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("failed to store session key in OS keychain (%v); set %s to a base64-encoded 32-byte key", err, EnvSessionKey)
+	}
+	return key, nil
+}
+
+// decodeKey decodes a base64 key and checks its length
+func decodeKey(v, source string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("invalid session key in %s: expected base64-encoded %d bytes", source, keySize)
+	}
+	return key, nil
+}
@@ -0,0 +1,50 @@
+// Package session provides session management for gmn chat.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package session
+
+import (
+	"encoding/json"
+
+	"github.com/linkalls/gmn/internal/api"
+)
+
+// FromHistory converts chat history to stored messages, keeping tool calls
+// and their results. Inline images are left out to keep sessions small.
+func FromHistory(history []api.Content) []map[string]interface{} {
+	messages := make([]map[string]interface{}, 0, len(history))
+	for _, c := range history {
+		parts := make([]interface{}, 0, len(c.Parts))
+		for _, p := range c.Parts {
+			if p.InlineData != nil {
+				continue
+			}
+			var part map[string]interface{}
+			data, err := json.Marshal(p)
+			if err != nil || json.Unmarshal(data, &part) != nil {
+				continue
+			}
+			parts = append(parts, part)
+		}
+		messages = append(messages, map[string]interface{}{
+			"role":  c.Role,
+			"parts": parts,
+		})
+	}
+	return messages
+}
+
+// ToHistory converts stored messages back to chat history
+func ToHistory(messages []map[string]interface{}) []api.Content {
+	history := make([]api.Content, 0, len(messages))
+	for _, msg := range messages {
+		var content api.Content
+		data, err := json.Marshal(msg)
+		if err != nil || json.Unmarshal(data, &content) != nil {
+			content = api.Content{}
+			content.Role, _ = msg["role"].(string)
+		}
+		history = append(history, content)
+	}
+	return history
+}
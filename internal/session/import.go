@@ -0,0 +1,251 @@
+// Package session provides session management for gmn chat.
+// SPDX-License-Identifier: Apache-2.0
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Transcript formats supported by Import
+const (
+	FormatGeminiCheckpoint = "gemini-checkpoint" // gemini-cli /chat save (array of Content)
+	FormatGeminiSession    = "gemini-session"    // gemini-cli chats/session-*.json
+	FormatClaudeJSONL      = "claude-jsonl"      // Claude Code ~/.claude/projects/*/*.jsonl
+)
+
+// transcript is the intermediate result of parsing a foreign transcript
+type transcript struct {
+	format    string
+	model     string
+	startedAt time.Time
+	messages  []map[string]interface{}
+}
+
+// Import parses a transcript from another tool and saves it as a new session.
+// The format is detected from the file contents. defaultModel is used when
+// the transcript does not name a Gemini model.
+func (m *Manager) Import(path, defaultModel string) (*Session, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read transcript: %w", err)
+	}
+
+	t, err := parseTranscript(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(t.messages) == 0 {
+		return nil, t.format, fmt.Errorf("no messages found in %s", path)
+	}
+
+	now := time.Now()
+	created := t.startedAt
+	if created.IsZero() {
+		created = now
+	}
+
+	model := t.model
+	if !strings.HasPrefix(model, "gemini") {
+		model = defaultModel
+	}
+
+	s := &Session{
+		ID:        created.Format("20060102-150405"),
+		Name:      strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Model:     model,
+		CreatedAt: created,
+		UpdatedAt: now,
+		Messages:  t.messages,
+	}
+	// Avoid clobbering an existing session with the same timestamp ID
+	for i := 1; m.exists(s.ID); i++ {
+		s.ID = fmt.Sprintf("%s-%d", created.Format("20060102-150405"), i)
+	}
+
+	if err := m.Save(s); err != nil {
+		return nil, t.format, err
+	}
+	return s, t.format, nil
+}
+
+// exists reports whether a session with the exact ID exists
+func (m *Manager) exists(id string) bool {
+	return m.store.Exists(id)
+}
+
+// parseTranscript detects the transcript format and converts it
+func parseTranscript(data []byte) (*transcript, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("transcript is empty")
+	}
+
+	switch trimmed[0] {
+	case '[':
+		return parseGeminiCheckpoint(trimmed)
+	case '{':
+		// A single JSON object is a gemini-cli session file; multiple
+		// objects (one per line) are a Claude Code JSONL transcript.
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &obj); err == nil {
+			if _, ok := obj["messages"]; ok {
+				return parseGeminiSession(trimmed)
+			}
+		}
+		return parseClaudeJSONL(trimmed)
+	default:
+		return nil, fmt.Errorf("unrecognized transcript format")
+	}
+}
+
+// parseGeminiCheckpoint parses a checkpoint saved with gemini-cli's /chat save
+func parseGeminiCheckpoint(data []byte) (*transcript, error) {
+	var contents []struct {
+		Role  string `json:"role"`
+		Parts []struct {
+			Text string `json:"text"`
+		} `json:"parts"`
+	}
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return nil, fmt.Errorf("failed to parse gemini checkpoint: %w", err)
+	}
+
+	t := &transcript{format: FormatGeminiCheckpoint}
+	for _, c := range contents {
+		for _, p := range c.Parts {
+			t.append(c.Role, p.Text)
+		}
+	}
+	return t, nil
+}
+
+// parseGeminiSession parses a gemini-cli chat recording (chats/session-*.json)
+func parseGeminiSession(data []byte) (*transcript, error) {
+	var rec struct {
+		StartTime time.Time `json:"startTime"`
+		Messages  []struct {
+			Type    string          `json:"type"`
+			Model   string          `json:"model"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse gemini session: %w", err)
+	}
+
+	t := &transcript{format: FormatGeminiSession, startedAt: rec.StartTime}
+	for _, msg := range rec.Messages {
+		var role string
+		switch msg.Type {
+		case "user":
+			role = "user"
+		case "gemini", "model":
+			role = "model"
+			if msg.Model != "" {
+				t.model = msg.Model
+			}
+		default:
+			continue // info, error, tool messages
+		}
+		t.append(role, contentText(msg.Content))
+	}
+	return t, nil
+}
+
+// parseClaudeJSONL parses a Claude Code JSONL transcript
+func parseClaudeJSONL(data []byte) (*transcript, error) {
+	t := &transcript{format: FormatClaudeJSONL}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry struct {
+			Type      string    `json:"type"`
+			IsMeta    bool      `json:"isMeta"`
+			Timestamp time.Time `json:"timestamp"`
+			Message   struct {
+				Role    string          `json:"role"`
+				Content json.RawMessage `json:"content"`
+			} `json:"message"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse Claude transcript line: %w", err)
+		}
+		if entry.IsMeta || (entry.Type != "user" && entry.Type != "assistant") {
+			continue
+		}
+
+		if t.startedAt.IsZero() {
+			t.startedAt = entry.Timestamp
+		}
+
+		role := "user"
+		if entry.Type == "assistant" {
+			role = "model"
+		}
+		t.append(role, contentText(entry.Message.Content))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Claude transcript: %w", err)
+	}
+	return t, nil
+}
+
+// contentText extracts text from a content field that is either a plain
+// string or a list of typed blocks ({"type": "text", "text": ...}).
+// Tool use and tool result blocks are skipped.
+func contentText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+	var texts []string
+	for _, b := range blocks {
+		if (b.Type == "text" || b.Type == "") && b.Text != "" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// append adds text to the transcript, merging consecutive turns of the same role
+func (t *transcript) append(role, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	part := map[string]interface{}{"text": text}
+
+	if n := len(t.messages); n > 0 && t.messages[n-1]["role"] == role {
+		parts := t.messages[n-1]["parts"].([]interface{})
+		t.messages[n-1]["parts"] = append(parts, part)
+		return
+	}
+	t.messages = append(t.messages, map[string]interface{}{
+		"role":  role,
+		"parts": []interface{}{part},
+	})
+}
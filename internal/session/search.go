@@ -0,0 +1,121 @@
+// Package session provides session management for gmn chat.
+// SPDX-License-Identifier: Apache-2.0
+package session
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// snippetContext is the number of characters shown on each side of a match
+const snippetContext = 40
+
+// SearchResult is a single message matching a search query
+type SearchResult struct {
+	SessionID string    `json:"session_id"`
+	Name      string    `json:"name,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Message   int       `json:"message"` // Index into Session.Messages
+	Role      string    `json:"role"`
+	Snippet   string    `json:"snippet"`
+}
+
+// Search finds messages containing query across all saved sessions, newest
+// session first. Plain queries are matched case-insensitively; with useRegex
+// the query is compiled as a Go regular expression. A limit of 0 returns all
+// matches.
+func (m *Manager) Search(query string, useRegex bool, limit int) ([]SearchResult, error) {
+	if query == "" {
+		return nil, fmt.Errorf("search query is empty")
+	}
+
+	return m.store.Search(query, useRegex, limit)
+}
+
+// compileQuery compiles a search query into a regular expression
+func compileQuery(query string, useRegex bool) (*regexp.Regexp, error) {
+	pattern := "(?i)" + regexp.QuoteMeta(query)
+	if useRegex {
+		pattern = query
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return re, nil
+}
+
+// matchMessage returns a search result if the message text matches re
+func matchMessage(re *regexp.Regexp, s *Session, index int, role, text string) (SearchResult, bool) {
+	loc := re.FindStringIndex(text)
+	if loc == nil {
+		return SearchResult{}, false
+	}
+	return SearchResult{
+		SessionID: s.ID,
+		Name:      s.Name,
+		UpdatedAt: s.UpdatedAt,
+		Message:   index,
+		Role:      role,
+		Snippet:   snippet(text, loc[0], loc[1]),
+	}, true
+}
+
+// ParseSearchArgs splits "[--regex|-e] <query>" into the query and regex flag
+func ParseSearchArgs(args string) (string, bool) {
+	args = strings.TrimSpace(args)
+	for _, flag := range []string{"--regex", "-e"} {
+		if args == flag {
+			return "", true
+		}
+		if strings.HasPrefix(args, flag+" ") {
+			return strings.TrimSpace(args[len(flag):]), true
+		}
+	}
+	return args, false
+}
+
+// MessageText returns the concatenated text parts of a stored message
+func MessageText(msg map[string]interface{}) string {
+	parts, _ := msg["parts"].([]interface{})
+	var texts []string
+	for _, p := range parts {
+		if partMap, ok := p.(map[string]interface{}); ok {
+			if text, ok := partMap["text"].(string); ok && text != "" {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// snippet returns the text around [start, end) on a single line
+func snippet(text string, start, end int) string {
+	from := start - snippetContext
+	if from < 0 {
+		from = 0
+	}
+	to := end + snippetContext
+	if to > len(text) {
+		to = len(text)
+	}
+	// Do not cut multi-byte characters in half
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+
+	s := strings.Join(strings.Fields(text[from:to]), " ")
+	if from > 0 {
+		s = "…" + s
+	}
+	if to < len(text) {
+		s += "…"
+	}
+	return s
+}
@@ -3,24 +3,34 @@
 package session
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
-	"sort"
-	"strings"
 	"time"
+
+	"github.com/linkalls/gmn/internal/config"
 )
 
 // Session represents a chat session
 type Session struct {
 	ID        string                   `json:"id"`
 	Name      string                   `json:"name,omitempty"`
+	ParentID  string                   `json:"parent_id,omitempty"` // Session this one was branched from
 	Model     string                   `json:"model"`
 	CreatedAt time.Time                `json:"created_at"`
 	UpdatedAt time.Time                `json:"updated_at"`
 	Messages  []map[string]interface{} `json:"messages"`
 	Tokens    TokenUsage               `json:"tokens"`
+
+	// messageCount is set by stores that list sessions without messages
+	messageCount int
+}
+
+// MessageCount returns the number of messages in the session
+func (s *Session) MessageCount() int {
+	if len(s.Messages) == 0 {
+		return s.messageCount
+	}
+	return len(s.Messages)
 }
 
 // TokenUsage tracks token usage
@@ -29,27 +39,57 @@ type TokenUsage struct {
 	Output int `json:"output"`
 }
 
+// Session storage backends
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+)
+
 // Manager handles session operations
 type Manager struct {
-	sessionsDir string
-	currentID   string
+	store     Store
+	currentID string
 }
 
-// NewManager creates a new session manager
+// NewManager creates a new session manager using the backend and location
+// configured in settings.json ("sessions.backend", "paths.sessionsDir"),
+// defaulting to JSON files in the data directory.
 func NewManager() (*Manager, error) {
-	homeDir, err := os.UserHomeDir()
+	cfg, err := config.Load()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		cfg = config.DefaultConfig()
 	}
+	dir, err := config.SessionsDir(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine sessions directory: %w", err)
+	}
+	return NewManagerAt(cfg.Sessions.Backend, dir, cfg.Sessions.Encrypt)
+}
 
-	sessionsDir := filepath.Join(homeDir, ".gmn", "sessions")
-	if err := os.MkdirAll(sessionsDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create sessions directory: %w", err)
+// NewManagerAt creates a session manager for the named backend storing
+// sessions in dir. The SQLite database is kept next to dir as <dir>.db.
+// With encrypt set, session files are encrypted at rest (JSON backend only).
+func NewManagerAt(backend, dir string, encrypt bool) (*Manager, error) {
+	var (
+		store Store
+		err   error
+	)
+	switch backend {
+	case BackendJSON, "":
+		store, err = newJSONStore(dir, encrypt)
+	case BackendSQLite:
+		if encrypt {
+			return nil, fmt.Errorf("sessions.encrypt is not supported with the %s backend", BackendSQLite)
+		}
+		store, err = newSQLiteStore(filepath.Clean(dir)+".db", dir)
+	default:
+		return nil, fmt.Errorf("unknown session backend %q (use %q or %q)", backend, BackendJSON, BackendSQLite)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return &Manager{
-		sessionsDir: sessionsDir,
-	}, nil
+	return &Manager{store: store}, nil
 }
 
 // NewSession creates a new session with auto-generated ID
@@ -68,68 +108,20 @@ func (m *Manager) NewSession(model string) *Session {
 	}
 }
 
-// Save saves a session to disk
+// Save saves a session
 func (m *Manager) Save(session *Session) error {
 	session.UpdatedAt = time.Now()
-
-	filename := session.ID + ".json"
-	if session.Name != "" {
-		// Also save with name as alias
-		filename = session.ID + ".json"
-	}
-
-	path := filepath.Join(m.sessionsDir, filename)
-	data, err := json.MarshalIndent(session, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal session: %w", err)
-	}
-
-	if err := os.WriteFile(path, data, 0644); err != nil {
-		return fmt.Errorf("failed to write session file: %w", err)
-	}
-
-	// If session has a name, create a symlink or alias file
-	if session.Name != "" {
-		aliasPath := filepath.Join(m.sessionsDir, session.Name+".json")
-		// Remove existing alias if any
-		os.Remove(aliasPath)
-		// Create alias by copying (Windows doesn't support symlinks well)
-		if err := os.WriteFile(aliasPath, data, 0644); err != nil {
-			// Ignore alias creation errors
-		}
-	}
-
-	return nil
+	return m.store.Save(session)
 }
 
 // Load loads a session by ID or name
 func (m *Manager) Load(idOrName string) (*Session, error) {
-	// Try exact match first
-	path := filepath.Join(m.sessionsDir, idOrName+".json")
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		// Try to find by prefix
-		matches, _ := filepath.Glob(filepath.Join(m.sessionsDir, idOrName+"*.json"))
-		if len(matches) == 0 {
-			return nil, fmt.Errorf("session not found: %s", idOrName)
-		}
-		if len(matches) > 1 {
-			return nil, fmt.Errorf("multiple sessions match '%s', be more specific", idOrName)
-		}
-		path = matches[0]
-	}
-
-	data, err := os.ReadFile(path)
+	session, err := m.store.Load(idOrName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read session file: %w", err)
-	}
-
-	var session Session
-	if err := json.Unmarshal(data, &session); err != nil {
-		return nil, fmt.Errorf("failed to parse session file: %w", err)
+		return nil, err
 	}
-
 	m.currentID = session.ID
-	return &session, nil
+	return session, nil
 }
 
 // LoadLatest loads the most recent session
@@ -145,47 +137,10 @@ func (m *Manager) LoadLatest() (*Session, error) {
 	return m.Load(sessions[0].ID)
 }
 
-// List returns all sessions sorted by update time (newest first)
+// List returns all sessions sorted by update time (newest first).
+// Depending on the backend, Messages may be empty; use MessageCount.
 func (m *Manager) List() ([]*Session, error) {
-	files, err := os.ReadDir(m.sessionsDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
-	}
-
-	var sessions []*Session
-	seen := make(map[string]bool)
-
-	for _, f := range files {
-		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
-			continue
-		}
-
-		path := filepath.Join(m.sessionsDir, f.Name())
-		data, err := os.ReadFile(path)
-		if err != nil {
-			continue
-		}
-
-		var session Session
-		if err := json.Unmarshal(data, &session); err != nil {
-			continue
-		}
-
-		// Skip duplicates (aliases)
-		if seen[session.ID] {
-			continue
-		}
-		seen[session.ID] = true
-
-		sessions = append(sessions, &session)
-	}
-
-	// Sort by update time (newest first)
-	sort.Slice(sessions, func(i, j int) bool {
-		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
-	})
-
-	return sessions, nil
+	return m.store.List()
 }
 
 // Delete removes a session
@@ -194,20 +149,7 @@ func (m *Manager) Delete(idOrName string) error {
 	if err != nil {
 		return err
 	}
-
-	// Remove main file
-	path := filepath.Join(m.sessionsDir, session.ID+".json")
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete session: %w", err)
-	}
-
-	// Remove alias if exists
-	if session.Name != "" {
-		aliasPath := filepath.Join(m.sessionsDir, session.Name+".json")
-		os.Remove(aliasPath)
-	}
-
-	return nil
+	return m.store.Delete(session)
 }
 
 // GetCurrentID returns the current session ID
@@ -221,13 +163,52 @@ func (m *Manager) Rename(idOrName, newName string) error {
 	if err != nil {
 		return err
 	}
+	session.UpdatedAt = time.Now()
+	return m.store.Rename(session, newName)
+}
+
+// UniqueName returns name, or name with a " (2)", " (3)", ... suffix if
+// another session already uses it
+func (m *Manager) UniqueName(name string) string {
+	sessions, err := m.List()
+	if err != nil {
+		return name
+	}
+	taken := make(map[string]bool, len(sessions))
+	for _, s := range sessions {
+		taken[s.Name] = true
+	}
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)", name, i)
+	}
+	return unique
+}
 
-	// Remove old alias if exists
-	if session.Name != "" {
-		oldAliasPath := filepath.Join(m.sessionsDir, session.Name+".json")
-		os.Remove(oldAliasPath)
+// Prune deletes sessions last updated before olderThan ago, always keeping
+// the keep most recent sessions. A zero olderThan matches every session.
+// With dryRun set nothing is deleted. The matching sessions are returned.
+func (m *Manager) Prune(olderThan time.Duration, keep int, dryRun bool) ([]*Session, error) {
+	sessions, err := m.List()
+	if err != nil {
+		return nil, err
 	}
 
-	session.Name = newName
-	return m.Save(session)
+	cutoff := time.Now().Add(-olderThan)
+	var pruned []*Session
+	for i, s := range sessions {
+		if i < keep {
+			continue
+		}
+		if olderThan > 0 && s.UpdatedAt.After(cutoff) {
+			continue
+		}
+		if !dryRun {
+			if err := m.Delete(s.ID); err != nil {
+				return pruned, err
+			}
+		}
+		pruned = append(pruned, s)
+	}
+	return pruned, nil
 }
@@ -0,0 +1,232 @@
+// Package session provides session management for gmn chat.
+// SPDX-License-Identifier: Apache-2.0
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Store persists sessions. The JSON file store is the default; the SQLite
+// store is selected with "sessions.backend": "sqlite" in settings.json.
+type Store interface {
+	// Save creates or replaces a session
+	Save(s *Session) error
+	// Load finds a session by exact ID, name, or unique ID/name prefix
+	Load(idOrName string) (*Session, error)
+	// List returns all sessions, newest first. Messages may be omitted;
+	// use Session.MessageCount for the number of messages.
+	List() ([]*Session, error)
+	// Delete removes a session
+	Delete(s *Session) error
+	// Rename changes the name of a session
+	Rename(s *Session, newName string) error
+	// Exists reports whether a session with the exact ID exists
+	Exists(id string) bool
+	// Search finds messages matching query (see Manager.Search)
+	Search(query string, useRegex bool, limit int) ([]SearchResult, error)
+}
+
+// jsonStore keeps one JSON file per session in a directory. Named sessions
+// also get a copy saved as <name>.json so they can be loaded by name.
+// With encrypt set, files are written with AES-GCM; encrypted files are
+// always readable as long as the key is available.
+type jsonStore struct {
+	dir     string
+	encrypt bool
+	sealer  sealer
+}
+
+// newJSONStore creates a JSON file store in dir
+func newJSONStore(dir string, encrypt bool) (*jsonStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create sessions directory: %w", err)
+	}
+	return &jsonStore{dir: dir, encrypt: encrypt}, nil
+}
+
+// encode marshals a session into file contents
+func (j *jsonStore) encode(session *Session) ([]byte, error) {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if !j.encrypt {
+		return data, nil
+	}
+	data, err = j.sealer.seal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt session: %w", err)
+	}
+	return data, nil
+}
+
+// decode parses file contents, decrypting them if needed
+func (j *jsonStore) decode(data []byte) (*Session, error) {
+	if isEncrypted(data) {
+		var err error
+		if data, err = j.sealer.open(data); err != nil {
+			return nil, err
+		}
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session file: %w", err)
+	}
+	return &session, nil
+}
+
+func (j *jsonStore) Save(session *Session) error {
+	path := filepath.Join(j.dir, session.ID+".json")
+	data, err := j.encode(session)
+	if err != nil {
+		return err
+	}
+
+	perm := os.FileMode(0644)
+	if j.encrypt {
+		perm = 0600
+	}
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+
+	// If session has a name, create a symlink or alias file
+	if session.Name != "" {
+		aliasPath := filepath.Join(j.dir, session.Name+".json")
+		// Remove existing alias if any
+		os.Remove(aliasPath)
+		// Create alias by copying (Windows doesn't support symlinks well)
+		if err := os.WriteFile(aliasPath, data, perm); err != nil {
+			// Ignore alias creation errors
+		}
+	}
+
+	return nil
+}
+
+func (j *jsonStore) Load(idOrName string) (*Session, error) {
+	// Try exact match first
+	path := filepath.Join(j.dir, idOrName+".json")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// Try to find by prefix
+		matches, _ := filepath.Glob(filepath.Join(j.dir, idOrName+"*.json"))
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("session not found: %s", idOrName)
+		}
+		if len(matches) > 1 {
+			return nil, fmt.Errorf("multiple sessions match '%s', be more specific", idOrName)
+		}
+		path = matches[0]
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+	return j.decode(data)
+}
+
+func (j *jsonStore) List() ([]*Session, error) {
+	files, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sessions directory: %w", err)
+	}
+
+	var sessions []*Session
+	seen := make(map[string]bool)
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+
+		path := filepath.Join(j.dir, f.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		session, err := j.decode(data)
+		if err != nil {
+			continue
+		}
+
+		// Skip duplicates (aliases)
+		if seen[session.ID] {
+			continue
+		}
+		seen[session.ID] = true
+
+		sessions = append(sessions, session)
+	}
+
+	// Sort by update time (newest first)
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+
+	return sessions, nil
+}
+
+func (j *jsonStore) Delete(session *Session) error {
+	// Remove main file
+	path := filepath.Join(j.dir, session.ID+".json")
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	// Remove alias if exists
+	if session.Name != "" {
+		aliasPath := filepath.Join(j.dir, session.Name+".json")
+		os.Remove(aliasPath)
+	}
+
+	return nil
+}
+
+func (j *jsonStore) Rename(session *Session, newName string) error {
+	// Remove old alias if exists
+	if session.Name != "" {
+		oldAliasPath := filepath.Join(j.dir, session.Name+".json")
+		os.Remove(oldAliasPath)
+	}
+
+	session.Name = newName
+	return j.Save(session)
+}
+
+func (j *jsonStore) Exists(id string) bool {
+	_, err := os.Stat(filepath.Join(j.dir, id+".json"))
+	return err == nil
+}
+
+func (j *jsonStore) Search(query string, useRegex bool, limit int) ([]SearchResult, error) {
+	re, err := compileQuery(query, useRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := j.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, s := range sessions {
+		for i, msg := range s.Messages {
+			role, _ := msg["role"].(string)
+			if r, ok := matchMessage(re, s, i, role, MessageText(msg)); ok {
+				results = append(results, r)
+				if limit > 0 && len(results) >= limit {
+					return results, nil
+				}
+			}
+		}
+	}
+	return results, nil
+}
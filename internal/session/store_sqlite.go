@@ -0,0 +1,326 @@
+// Package session provides session management for gmn chat.
+// SPDX-License-Identifier: Apache-2.0
+package session
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchemaVersion is stored in PRAGMA user_version. Version 1 means the
+// schema exists and existing JSON sessions have been imported.
+const sqliteSchemaVersion = 1
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id            TEXT PRIMARY KEY,
+	name          TEXT NOT NULL DEFAULT '',
+	parent_id     TEXT NOT NULL DEFAULT '',
+	model         TEXT NOT NULL DEFAULT '',
+	created_at    INTEGER NOT NULL,
+	updated_at    INTEGER NOT NULL,
+	tokens_in     INTEGER NOT NULL DEFAULT 0,
+	tokens_out    INTEGER NOT NULL DEFAULT 0,
+	message_count INTEGER NOT NULL DEFAULT 0,
+	messages      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_updated_at ON sessions(updated_at DESC);
+CREATE INDEX IF NOT EXISTS sessions_name ON sessions(name);
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+	session_id UNINDEXED,
+	idx UNINDEXED,
+	role UNINDEXED,
+	text,
+	tokenize = 'trigram'
+);
+`
+
+// sqliteStore keeps sessions in a single SQLite database. Listing only reads
+// the indexed metadata columns, and message text is indexed for search.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// newSQLiteStore opens (or creates) the database at path. On first use the
+// sessions in jsonDir are imported so switching backends keeps history.
+func newSQLiteStore(path, jsonDir string) (*sqliteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create session database directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session database: %w", err)
+	}
+	// gmn processes may share the database; one connection per process
+	// keeps writes serialized within the process
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create session database: %w", err)
+	}
+
+	s := &sqliteStore{db: db}
+	if err := s.migrate(jsonDir); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate imports JSON sessions once, when the database is new
+func (s *sqliteStore) migrate(jsonDir string) error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read session database version: %w", err)
+	}
+	if version >= sqliteSchemaVersion {
+		return nil
+	}
+
+	var sessions []*Session
+	if _, err := os.Stat(jsonDir); err == nil {
+		if sessions, err = (&jsonStore{dir: jsonDir}).List(); err != nil {
+			return fmt.Errorf("failed to import JSON sessions: %w", err)
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to import JSON sessions: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, session := range sessions {
+		if err := saveTx(tx, session); err != nil {
+			return fmt.Errorf("failed to import session %s: %w", session.ID, err)
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", sqliteSchemaVersion)); err != nil {
+		return fmt.Errorf("failed to update session database version: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) Save(session *Session) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveTx(tx, session); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// saveTx writes a session row and replaces its search index entries
+func saveTx(tx *sql.Tx, session *Session) error {
+	messages, err := json.Marshal(session.Messages)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO sessions
+		(id, name, parent_id, model, created_at, updated_at, tokens_in, tokens_out, message_count, messages)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.ID, session.Name, session.ParentID, session.Model,
+		session.CreatedAt.UnixNano(), session.UpdatedAt.UnixNano(),
+		session.Tokens.Input, session.Tokens.Output, len(session.Messages), string(messages))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM messages_fts WHERE session_id = ?", session.ID); err != nil {
+		return err
+	}
+	for i, msg := range session.Messages {
+		text := MessageText(msg)
+		if text == "" {
+			continue
+		}
+		role, _ := msg["role"].(string)
+		if _, err := tx.Exec("INSERT INTO messages_fts (session_id, idx, role, text) VALUES (?, ?, ?, ?)",
+			session.ID, i, role, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) Load(idOrName string) (*Session, error) {
+	const query = `SELECT id, name, parent_id, model, created_at, updated_at,
+		tokens_in, tokens_out, message_count, messages FROM sessions `
+
+	// Try exact ID, then exact name, then a unique prefix of either
+	rows, err := s.db.Query(query+"WHERE id = ? OR name = ? ORDER BY id = ? DESC, updated_at DESC LIMIT 1",
+		idOrName, idOrName, idOrName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	sessions, err := scanSessions(rows, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		pattern := escapeLike(idOrName) + "%"
+		rows, err = s.db.Query(query+`WHERE id LIKE ? ESCAPE '\' OR name LIKE ? ESCAPE '\' LIMIT 2`,
+			pattern, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load session: %w", err)
+		}
+		if sessions, err = scanSessions(rows, true); err != nil {
+			return nil, err
+		}
+	}
+
+	switch len(sessions) {
+	case 0:
+		return nil, fmt.Errorf("session not found: %s", idOrName)
+	case 1:
+		return sessions[0], nil
+	default:
+		return nil, fmt.Errorf("multiple sessions match '%s', be more specific", idOrName)
+	}
+}
+
+func (s *sqliteStore) List() ([]*Session, error) {
+	rows, err := s.db.Query(`SELECT id, name, parent_id, model, created_at, updated_at,
+		tokens_in, tokens_out, message_count FROM sessions ORDER BY updated_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return scanSessions(rows, false)
+}
+
+// scanSessions reads session rows; withMessages selects whether the query
+// includes the messages column
+func scanSessions(rows *sql.Rows, withMessages bool) ([]*Session, error) {
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		var (
+			session          Session
+			created, updated int64
+			messages         string
+		)
+		dest := []interface{}{
+			&session.ID, &session.Name, &session.ParentID, &session.Model, &created, &updated,
+			&session.Tokens.Input, &session.Tokens.Output, &session.messageCount,
+		}
+		if withMessages {
+			dest = append(dest, &messages)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to read session: %w", err)
+		}
+		session.CreatedAt = time.Unix(0, created)
+		session.UpdatedAt = time.Unix(0, updated)
+		if withMessages {
+			if err := json.Unmarshal([]byte(messages), &session.Messages); err != nil {
+				return nil, fmt.Errorf("failed to parse session %s: %w", session.ID, err)
+			}
+		}
+		sessions = append(sessions, &session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (s *sqliteStore) Delete(session *Session) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE id = ?", session.ID); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM messages_fts WHERE session_id = ?", session.ID); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) Rename(session *Session, newName string) error {
+	if _, err := s.db.Exec("UPDATE sessions SET name = ?, updated_at = ? WHERE id = ?",
+		newName, session.UpdatedAt.UnixNano(), session.ID); err != nil {
+		return fmt.Errorf("failed to rename session: %w", err)
+	}
+	session.Name = newName
+	return nil
+}
+
+func (s *sqliteStore) Exists(id string) bool {
+	var one int
+	return s.db.QueryRow("SELECT 1 FROM sessions WHERE id = ?", id).Scan(&one) == nil
+}
+
+// Search uses the trigram index to narrow plain queries with LIKE (which is
+// case-insensitive for ASCII), then matches candidates with the same rules
+// as the JSON store. Regular expressions are matched against every message.
+func (s *sqliteStore) Search(query string, useRegex bool, limit int) ([]SearchResult, error) {
+	re, err := compileQuery(query, useRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQuery := `SELECT f.session_id, s.name, s.updated_at, f.idx, f.role, f.text
+		FROM messages_fts f JOIN sessions s ON s.id = f.session_id `
+	var args []interface{}
+	if !useRegex {
+		sqlQuery += `WHERE f.text LIKE ? ESCAPE '\' `
+		args = append(args, "%"+escapeLike(query)+"%")
+	}
+	sqlQuery += "ORDER BY s.updated_at DESC, f.session_id, f.idx"
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var (
+			session    Session
+			updated    int64
+			idx        int
+			role, text string
+		)
+		if err := rows.Scan(&session.ID, &session.Name, &updated, &idx, &role, &text); err != nil {
+			return nil, fmt.Errorf("failed to search sessions: %w", err)
+		}
+		session.UpdatedAt = time.Unix(0, updated)
+		if r, ok := matchMessage(re, &session, idx, role, text); ok {
+			results = append(results, r)
+			if limit > 0 && len(results) >= limit {
+				break
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search sessions: %w", err)
+	}
+	return results, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s (using \ as the escape character)
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,142 @@
+// Package shellhook provides shell integration hooks that record the user's
+// most recent shell command and its exit code, but not its output, so it
+// can be attached to a chat prompt.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package shellhook
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+const lastCommandFile = "last_command"
+
+// LastCommand is the most recent command recorded by the shell hook
+type LastCommand struct {
+	Command  string
+	ExitCode int
+	Cwd      string
+	Time     time.Time
+}
+
+// StatePath returns the path of the file the shell hooks write to
+func StatePath() (string, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine state directory: %w", err)
+	}
+	return filepath.Join(dir, lastCommandFile), nil
+}
+
+// Script returns the integration script for the given shell
+func Script(shell string) (string, error) {
+	path, err := StatePath()
+	if err != nil {
+		return "", err
+	}
+
+	switch shell {
+	case "bash":
+		return fmt.Sprintf(bashHook, path), nil
+	case "zsh":
+		return fmt.Sprintf(zshHook, path), nil
+	case "fish":
+		return fmt.Sprintf(fishHook, path), nil
+	default:
+		return "", fmt.Errorf("unsupported shell: %s (supported: bash, zsh, fish)", shell)
+	}
+}
+
+// LoadLast reads the most recent command recorded by the shell hook.
+// The state file holds the exit code, working directory and command line,
+// one per line (the command may span several lines).
+func LoadLast() (*LastCommand, error) {
+	path, err := StatePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no command recorded yet; enable shell integration with: eval \"$(gmn shell-init bash)\"")
+		}
+		return nil, fmt.Errorf("failed to read last command: %w", err)
+	}
+
+	lines := strings.SplitN(strings.TrimRight(string(data), "\n"), "\n", 3)
+	if len(lines) < 3 || strings.TrimSpace(lines[2]) == "" {
+		return nil, fmt.Errorf("last command file is malformed: %s", path)
+	}
+
+	exitCode, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, fmt.Errorf("last command file is malformed: %s", path)
+	}
+
+	info, _ := os.Stat(path)
+	last := &LastCommand{
+		ExitCode: exitCode,
+		Cwd:      lines[1],
+		Command:  strings.TrimSpace(lines[2]),
+	}
+	if info != nil {
+		last.Time = info.ModTime()
+	}
+	return last, nil
+}
+
+// ContextText formats the command as prompt context. The hook records no
+// output, so the model is told it was not captured.
+func (c *LastCommand) ContextText() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "My last shell command (in %s) exited with code %d (its output was not captured):\n", c.Cwd, c.ExitCode)
+	fmt.Fprintf(&b, "```console\n$ %s\n```", c.Command)
+	return b.String()
+}
+
+const bashHook = `# gmn shell integration (bash)
+__gmn_record_last_cmd() {
+  local exit_code=$?
+  local cmd
+  cmd=$(HISTTIMEFORMAT= builtin history 1 | sed 's/^ *[0-9]* *//')
+  case "$cmd" in gmn*) return $exit_code ;; esac
+  mkdir -p "$(dirname '%[1]s')"
+  printf '%%s\n%%s\n%%s\n' "$exit_code" "$PWD" "$cmd" > '%[1]s'
+  return $exit_code
+}
+case ";$PROMPT_COMMAND;" in
+  *";__gmn_record_last_cmd;"*) ;;
+  *) PROMPT_COMMAND="__gmn_record_last_cmd${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+esac
+`
+
+const zshHook = `# gmn shell integration (zsh)
+__gmn_record_last_cmd() {
+  local exit_code=$?
+  local cmd
+  cmd=$(fc -ln -1)
+  case "$cmd" in gmn*) return $exit_code ;; esac
+  mkdir -p "$(dirname '%[1]s')"
+  printf '%%s\n%%s\n%%s\n' "$exit_code" "$PWD" "$cmd" > '%[1]s'
+  return $exit_code
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook precmd __gmn_record_last_cmd
+`
+
+const fishHook = `# gmn shell integration (fish)
+function __gmn_record_last_cmd --on-event fish_postexec
+  set -l last_status $status
+  string match -q 'gmn*' -- $argv[1]; and return
+  mkdir -p (dirname '%[1]s')
+  printf '%%s\n%%s\n%%s\n' $last_status $PWD $argv[1] > '%[1]s'
+end
+`
@@ -0,0 +1,210 @@
+// Package telemetry records request and tool spans and metrics.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// exporter sends OTLP/HTTP requests with JSON encoding, which every
+// OpenTelemetry collector accepts, without pulling in the OTel SDK
+type exporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+	resource map[string]interface{}
+	scope    map[string]interface{}
+}
+
+func newExporter(endpoint string, headers map[string]string, version string) *exporter {
+	return &exporter{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		resource: map[string]interface{}{
+			"attributes": encodeAttrs([]attr{
+				{"service.name", "gmn"},
+				{"service.version", version},
+				{"os.type", runtime.GOOS},
+				{"host.arch", runtime.GOARCH},
+			}),
+		},
+		scope: map[string]interface{}{"name": "gmn", "version": version},
+	}
+}
+
+func (e *exporter) exportSpans(ctx context.Context, traceID []byte, spans []span) error {
+	encoded := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		status := map[string]interface{}{"code": 1} // OK
+		if s.errMsg != "" {
+			status = map[string]interface{}{"code": 2, "message": s.errMsg} // ERROR
+		}
+		encoded = append(encoded, map[string]interface{}{
+			"traceId":           hex.EncodeToString(traceID),
+			"spanId":            hex.EncodeToString(s.id),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": unixNano(s.start),
+			"endTimeUnixNano":   unixNano(s.end),
+			"attributes":        encodeAttrs(s.attrs),
+			"status":            status,
+		})
+	}
+
+	return e.post(ctx, "/v1/traces", map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": e.resource,
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": e.scope,
+				"spans": encoded,
+			}},
+		}},
+	})
+}
+
+func (e *exporter) exportMetrics(ctx context.Context, metrics []interface{}) error {
+	return e.post(ctx, "/v1/metrics", map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource": e.resource,
+			"scopeMetrics": []interface{}{map[string]interface{}{
+				"scope":   e.scope,
+				"metrics": metrics,
+			}},
+		}},
+	})
+}
+
+func (e *exporter) post(ctx context.Context, path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", e.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// metricsPayload encodes the cumulative metrics, grouped by metric name.
+// Callers hold r.mu.
+func (r *recorder) metricsPayload(now time.Time) []interface{} {
+	if len(r.sums) == 0 && len(r.hists) == 0 {
+		return nil
+	}
+	start, ts := unixNano(r.start), unixNano(now)
+
+	sums := make(map[string][]interface{})
+	units := make(map[string]string)
+	for _, p := range r.sums {
+		units[p.name] = p.unit
+		sums[p.name] = append(sums[p.name], map[string]interface{}{
+			"attributes":        encodeAttrs(p.attrs),
+			"startTimeUnixNano": start,
+			"timeUnixNano":      ts,
+			"asInt":             strconv.FormatInt(p.value, 10),
+		})
+	}
+	hists := make(map[string][]interface{})
+	for _, p := range r.hists {
+		units[p.name] = p.unit
+		buckets := make([]string, len(p.buckets))
+		for i, n := range p.buckets {
+			buckets[i] = strconv.FormatUint(n, 10)
+		}
+		hists[p.name] = append(hists[p.name], map[string]interface{}{
+			"attributes":        encodeAttrs(p.attrs),
+			"startTimeUnixNano": start,
+			"timeUnixNano":      ts,
+			"count":             strconv.FormatUint(p.count, 10),
+			"sum":               p.sum,
+			"bucketCounts":      buckets,
+			"explicitBounds":    durationBounds,
+		})
+	}
+
+	var metrics []interface{}
+	for _, name := range sortedKeys(sums) {
+		metrics = append(metrics, map[string]interface{}{
+			"name": name,
+			"unit": units[name],
+			"sum": map[string]interface{}{
+				"aggregationTemporality": 2, // CUMULATIVE
+				"isMonotonic":            true,
+				"dataPoints":             sums[name],
+			},
+		})
+	}
+	for _, name := range sortedKeys(hists) {
+		metrics = append(metrics, map[string]interface{}{
+			"name": name,
+			"unit": units[name],
+			"histogram": map[string]interface{}{
+				"aggregationTemporality": 2, // CUMULATIVE
+				"dataPoints":             hists[name],
+			},
+		})
+	}
+	return metrics
+}
+
+func encodeAttrs(attrs []attr) []interface{} {
+	out := make([]interface{}, 0, len(attrs))
+	for _, a := range attrs {
+		var value map[string]interface{}
+		switch v := a.value.(type) {
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		default:
+			value = map[string]interface{}{"stringValue": attrString(v)}
+		}
+		out = append(out, map[string]interface{}{"key": a.key, "value": value})
+	}
+	return out
+}
+
+func attrString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func sortedKeys(m map[string][]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
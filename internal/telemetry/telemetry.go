@@ -0,0 +1,295 @@
+// Package telemetry records request and tool spans and metrics and exports
+// them to a user-configured OpenTelemetry collector. It is strictly opt-in:
+// nothing is recorded or sent unless "telemetry.enabled" is set.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
+	"github.com/linkalls/gmn/internal/timing"
+)
+
+const (
+	defaultEndpoint = "http://localhost:4318"
+	flushInterval   = 30 * time.Second
+	maxPendingSpans = 512
+	shutdownTimeout = 5 * time.Second
+)
+
+// durationBounds are the histogram bucket bounds in milliseconds
+var durationBounds = []float64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000, 120000}
+
+// recorder holds pending spans and cumulative metrics between exports
+type recorder struct {
+	mu       sync.Mutex
+	exporter *exporter
+	traceID  []byte
+	start    time.Time
+	spans    []span
+	sums     map[string]*sumPoint
+	hists    map[string]*histPoint
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// span is a finished operation
+type span struct {
+	id     []byte
+	name   string
+	kind   int
+	start  time.Time
+	end    time.Time
+	attrs  []attr
+	errMsg string
+}
+
+type attr struct {
+	key   string
+	value interface{} // string or int
+}
+
+type sumPoint struct {
+	name, unit string
+	attrs      []attr
+	value      int64
+}
+
+type histPoint struct {
+	name, unit string
+	attrs      []attr
+	count      uint64
+	sum        float64
+	buckets    []uint64
+}
+
+var (
+	mu     sync.Mutex
+	global *recorder
+)
+
+// Init enables telemetry if configured. It is a no-op when disabled.
+func Init(cfg config.TelemetryConfig, version string) {
+	if !cfg.Enabled {
+		return
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	r := &recorder{
+		exporter: newExporter(strings.TrimRight(endpoint, "/"), cfg.Headers, version),
+		traceID:  randomID(16),
+		start:    time.Now(),
+		sums:     make(map[string]*sumPoint),
+		hists:    make(map[string]*histPoint),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go r.loop()
+
+	mu.Lock()
+	global = r
+	mu.Unlock()
+}
+
+// Shutdown exports everything recorded so far and stops telemetry
+func Shutdown() {
+	mu.Lock()
+	r := global
+	global = nil
+	mu.Unlock()
+	if r == nil {
+		return
+	}
+
+	close(r.stop)
+	<-r.done
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	r.flush(ctx)
+}
+
+// RecordRequest records a model API request started at start. op is the
+// API operation ("generate" or "stream").
+func RecordRequest(op, model string, start time.Time, inputTokens, outputTokens int, err error) {
+	r := current()
+	if r == nil {
+		return
+	}
+	end := time.Now()
+	status := statusOf(err)
+	attrs := []attr{{"gen_ai.request.model", model}}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addSpan("gemini."+op, 3, start, end, append(attrs,
+		attr{"gen_ai.operation.name", op},
+		attr{"gen_ai.usage.input_tokens", inputTokens},
+		attr{"gen_ai.usage.output_tokens", outputTokens},
+	), err)
+	r.add("gmn.requests", "1", append(attrs, attr{"status", status}), 1)
+	r.observe("gmn.request.duration", "ms", attrs, end.Sub(start))
+	if inputTokens > 0 {
+		r.add("gmn.tokens", "1", append(attrs, attr{"type", "input"}), int64(inputTokens))
+	}
+	if outputTokens > 0 {
+		r.add("gmn.tokens", "1", append(attrs, attr{"type", "output"}), int64(outputTokens))
+	}
+}
+
+// RecordTool records a tool execution started at start
+func RecordTool(name string, start time.Time, err error) {
+	r := current()
+	if r == nil {
+		return
+	}
+	end := time.Now()
+	attrs := []attr{{"tool", name}}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addSpan("tool."+name, 1, start, end, attrs, err)
+	r.add("gmn.tool.calls", "1", append(attrs, attr{"status", statusOf(err)}), 1)
+	r.observe("gmn.tool.duration", "ms", attrs, end.Sub(start))
+}
+
+// RecordTurn records where the time of a chat turn went
+func RecordTurn(b timing.Breakdown) {
+	r := current()
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, phase := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"total", b.Total},
+		{"first_token", b.FirstToken},
+		{"streaming", b.Streaming},
+		{"tools", b.Tools},
+		{"render", b.Render},
+	} {
+		r.observe("gmn.turn.duration", "ms", []attr{{"phase", phase.name}}, phase.d)
+	}
+}
+
+func current() *recorder {
+	mu.Lock()
+	defer mu.Unlock()
+	return global
+}
+
+func statusOf(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// addSpan buffers a span; the oldest spans are dropped if the collector
+// cannot keep up. Callers hold r.mu.
+func (r *recorder) addSpan(name string, kind int, start, end time.Time, attrs []attr, err error) {
+	s := span{id: randomID(8), name: name, kind: kind, start: start, end: end, attrs: attrs}
+	if err != nil {
+		s.errMsg = err.Error()
+	}
+	if len(r.spans) >= maxPendingSpans {
+		r.spans = r.spans[1:]
+	}
+	r.spans = append(r.spans, s)
+}
+
+// add increments a cumulative counter. Callers hold r.mu.
+func (r *recorder) add(name, unit string, attrs []attr, n int64) {
+	key := seriesKey(name, attrs)
+	p, ok := r.sums[key]
+	if !ok {
+		p = &sumPoint{name: name, unit: unit, attrs: attrs}
+		r.sums[key] = p
+	}
+	p.value += n
+}
+
+// observe records a duration in a histogram. Callers hold r.mu.
+func (r *recorder) observe(name, unit string, attrs []attr, d time.Duration) {
+	key := seriesKey(name, attrs)
+	p, ok := r.hists[key]
+	if !ok {
+		p = &histPoint{name: name, unit: unit, attrs: attrs, buckets: make([]uint64, len(durationBounds)+1)}
+		r.hists[key] = p
+	}
+	ms := float64(d) / float64(time.Millisecond)
+	i := sort.SearchFloat64s(durationBounds, ms)
+	p.buckets[i]++
+	p.count++
+	p.sum += ms
+}
+
+// loop exports periodically so long chat sessions report while running
+func (r *recorder) loop() {
+	defer close(r.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), flushInterval)
+			r.flush(ctx)
+			cancel()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// flush exports pending spans and the current metric values. Export
+// errors are ignored: telemetry must never interfere with the CLI.
+func (r *recorder) flush(ctx context.Context) {
+	r.mu.Lock()
+	spans := r.spans
+	r.spans = nil
+	metrics := r.metricsPayload(time.Now())
+	r.mu.Unlock()
+
+	if len(spans) > 0 {
+		_ = r.exporter.exportSpans(ctx, r.traceID, spans)
+	}
+	if metrics != nil {
+		_ = r.exporter.exportMetrics(ctx, metrics)
+	}
+}
+
+// seriesKey identifies a metric series by name and attributes
+func seriesKey(name string, attrs []attr) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, a := range attrs {
+		b.WriteString("|")
+		b.WriteString(a.key)
+		b.WriteString("=")
+		b.WriteString(attrString(a.value))
+	}
+	return b.String()
+}
+
+func randomID(n int) []byte {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return b
+}
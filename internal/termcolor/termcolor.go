@@ -0,0 +1,107 @@
+// Package termcolor adapts gmn's color palette to what the terminal can
+// display: truecolor, 256 colors, 16 colors or none.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package termcolor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+)
+
+// Values of the ui.colorMode setting
+const (
+	ModeAuto      = "auto"
+	ModeTrueColor = "truecolor"
+	ModeANSI256   = "256"
+	ModeANSI      = "16"
+	ModeNone      = "none"
+)
+
+// The 16 standard ANSI colors, used as fallbacks on 16-color terminals.
+// Their actual shades depend on the terminal's theme.
+const (
+	Black         = "0"
+	Red           = "1"
+	Green         = "2"
+	Yellow        = "3"
+	Blue          = "4"
+	Magenta       = "5"
+	Cyan          = "6"
+	White         = "7"
+	BrightBlack   = "8"
+	BrightRed     = "9"
+	BrightGreen   = "10"
+	BrightYellow  = "11"
+	BrightBlue    = "12"
+	BrightMagenta = "13"
+	BrightCyan    = "14"
+	BrightWhite   = "15"
+)
+
+// Color returns a palette color for text: hex on truecolor terminals, the
+// nearest xterm color on 256-color terminals and ansi (one of the constants
+// above) on 16-color terminals. An empty ansi leaves the text uncolored
+// there, which suits subtle backgrounds that have no 16-color equivalent.
+// The color is adjusted for the theme and the minimum contrast.
+func Color(hex, ansi string) lipgloss.CompleteColor {
+	return complete(themeText(hex, ansi))
+}
+
+// complete returns hex with its xterm and 16-color equivalents
+func complete(hex, ansi string) lipgloss.CompleteColor {
+	c := lipgloss.CompleteColor{TrueColor: hex, ANSI: ansi}
+	if xterm, ok := termenv.ANSI256.Color(hex).(termenv.ANSI256Color); ok {
+		c.ANSI256 = strconv.Itoa(int(xterm))
+	}
+	return c
+}
+
+// Apply sets the color profile used for all output. Auto (or "") keeps the
+// profile detected from the terminal and environment (COLORTERM, TERM,
+// NO_COLOR); colors are off when stdout is not a terminal. A mode set in
+// the settings overrides NO_COLOR, but not a redirected stdout.
+func Apply(mode string) error {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode != ModeNone && !term.IsTerminal(os.Stdout.Fd()) {
+		return validateMode(mode)
+	}
+	switch mode {
+	case ModeAuto, "":
+	case ModeTrueColor, "24bit":
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	case ModeANSI256, "256color":
+		lipgloss.SetColorProfile(termenv.ANSI256)
+	case ModeANSI, "16color", "ansi":
+		lipgloss.SetColorProfile(termenv.ANSI)
+	case ModeNone, "mono", "monochrome":
+		lipgloss.SetColorProfile(termenv.Ascii)
+	default:
+		return validateMode(mode)
+	}
+	return nil
+}
+
+// validateMode reports whether mode is a known ui.colorMode value
+func validateMode(mode string) error {
+	switch mode {
+	case ModeAuto, "", ModeTrueColor, "24bit", ModeANSI256, "256color",
+		ModeANSI, "16color", "ansi", ModeNone, "mono", "monochrome":
+		return nil
+	}
+	return fmt.Errorf("unknown color mode %q (use %s, %s, %s, %s or %s)",
+		mode, ModeAuto, ModeTrueColor, ModeANSI256, ModeANSI, ModeNone)
+}
+
+// Dumb reports whether the terminal cannot show full-screen programs or
+// animations: TERM is "dumb", or stdin or stdout is not a terminal (a pipe,
+// a file or a log). gmn then uses line-based prompts and plain output.
+func Dumb() bool {
+	return os.Getenv("TERM") == "dumb" || !term.IsTerminal(os.Stdin.Fd()) || !term.IsTerminal(os.Stdout.Fd())
+}
@@ -0,0 +1,200 @@
+// Package termcolor adapts gmn's color palette to what the terminal can
+// display: truecolor, 256 colors, 16 colors or none.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package termcolor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Values of the ui.theme setting
+const (
+	ThemeDefault      = "default"
+	ThemeHighContrast = "high-contrast"
+)
+
+const (
+	// DefaultMinContrast is the contrast ratio text colors are raised to
+	// unless ui.minContrast is set (WCAG AA for body text)
+	DefaultMinContrast = 4.5
+	// highContrastMin is the text contrast of the high-contrast theme
+	// (WCAG AAA)
+	highContrastMin = 7
+	// borderContrast is the contrast of borders and other non-text marks in
+	// the high-contrast theme
+	borderContrast = 3
+	// referenceBackground is the lightest surface the palette puts text on;
+	// text colors readable on it are readable on every darker background
+	referenceBackground = "#1F2937"
+)
+
+// theme is the active theme
+var theme = struct {
+	highContrast bool
+	minContrast  float64 // 0 turns the check off
+}{minContrast: DefaultMinContrast}
+
+// rebuilders rebuild the palettes made from this package's colors
+var rebuilders []func()
+
+// OnTheme builds a palette with fn now and again whenever the theme
+// changes. Packages keep their colors and styles in variables set by fn.
+func OnTheme(fn func()) {
+	rebuilders = append(rebuilders, fn)
+	fn()
+}
+
+// SetTheme selects the theme and the minimum contrast ratio of text colors:
+// 0 uses the theme's default and a negative ratio turns the check off. The
+// palettes registered with OnTheme are rebuilt for it.
+func SetTheme(name string, minContrast float64) error {
+	if err := ValidateTheme(name); err != nil {
+		return err
+	}
+	theme.highContrast = strings.EqualFold(strings.TrimSpace(name), ThemeHighContrast)
+	switch {
+	case minContrast < 0:
+		theme.minContrast = 0
+	case minContrast == 0 && theme.highContrast:
+		theme.minContrast = highContrastMin
+	case minContrast == 0:
+		theme.minContrast = DefaultMinContrast
+	default:
+		theme.minContrast = math.Min(minContrast, 21)
+	}
+	for _, fn := range rebuilders {
+		fn()
+	}
+	return nil
+}
+
+// ValidateTheme reports whether name is a known ui.theme value
+func ValidateTheme(name string) error {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ThemeDefault, ThemeHighContrast:
+		return nil
+	}
+	return fmt.Errorf("unknown theme %q (use %s or %s)", name, ThemeDefault, ThemeHighContrast)
+}
+
+// Background returns a palette color for surfaces that text is drawn on.
+// The high-contrast theme darkens it to near black.
+func Background(hex, ansi string) lipgloss.CompleteColor {
+	if theme.highContrast {
+		hex = mix(hex, "#000000", 0.7)
+		ansi = Black
+	}
+	return complete(hex, ansi)
+}
+
+// Border returns a palette color for borders, rules and other marks that
+// are not text. The high-contrast theme makes it stand out from the
+// background.
+func Border(hex, ansi string) lipgloss.CompleteColor {
+	if theme.highContrast {
+		hex = raiseContrast(hex, borderContrast)
+		ansi = White
+	}
+	return complete(hex, ansi)
+}
+
+// On returns fg for text on the background bg if the two meet the minimum
+// contrast, or else black or white, whichever is easier to read
+func On(fg string, bg lipgloss.CompleteColor) lipgloss.CompleteColor {
+	if theme.minContrast == 0 || contrast(fg, bg.TrueColor) >= theme.minContrast {
+		return complete(fg, "")
+	}
+	if contrast("#000000", bg.TrueColor) >= contrast("#FFFFFF", bg.TrueColor) {
+		return complete("#000000", Black)
+	}
+	return complete("#FFFFFF", BrightWhite)
+}
+
+// themeText adjusts a text color for the theme: it is lightened until it
+// has the minimum contrast against the reference background, and the
+// high-contrast theme replaces gray 16-color fallbacks with brighter ones
+func themeText(hex, ansi string) (string, string) {
+	if theme.minContrast > 0 {
+		hex = raiseContrast(hex, theme.minContrast)
+	}
+	if theme.highContrast {
+		switch ansi {
+		case BrightBlack:
+			ansi = White
+		case White:
+			ansi = BrightWhite
+		}
+	}
+	return hex, ansi
+}
+
+// raiseContrast lightens hex until it has at least ratio contrast against
+// the background text is drawn on
+func raiseContrast(hex string, ratio float64) string {
+	bg := referenceBackground
+	if theme.highContrast {
+		bg = mix(bg, "#000000", 0.7)
+	}
+	raised := hex
+	for amount := 0.05; amount <= 1 && contrast(raised, bg) < ratio; amount += 0.05 {
+		raised = mix(hex, "#FFFFFF", amount)
+	}
+	return raised
+}
+
+// contrast returns the WCAG contrast ratio of two colors, from 1 to 21.
+// Colors that are not hex count as having enough contrast.
+func contrast(a, b string) float64 {
+	la, okA := luminance(a)
+	lb, okB := luminance(b)
+	if !okA || !okB {
+		return 21
+	}
+	return (math.Max(la, lb) + 0.05) / (math.Min(la, lb) + 0.05)
+}
+
+// luminance returns the relative luminance of a hex color
+func luminance(hex string) (float64, bool) {
+	r, g, b, ok := parseHex(hex)
+	if !ok {
+		return 0, false
+	}
+	linear := func(c float64) float64 {
+		if c <= 0.03928 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b), true
+}
+
+// mix moves hex toward target by amount (0 to 1)
+func mix(hex, target string, amount float64) string {
+	r, g, b, ok := parseHex(hex)
+	tr, tg, tb, okT := parseHex(target)
+	if !ok || !okT {
+		return hex
+	}
+	blend := func(c, t float64) int {
+		return int(math.Round((c + (t-c)*amount) * 255))
+	}
+	return fmt.Sprintf("#%02X%02X%02X", blend(r, tr), blend(g, tg), blend(b, tb))
+}
+
+// parseHex reads "#RRGGBB" into components from 0 to 1
+func parseHex(hex string) (r, g, b float64, ok bool) {
+	if len(hex) != 7 || hex[0] != '#' {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return float64(v>>16&0xFF) / 255, float64(v>>8&0xFF) / 255, float64(v&0xFF) / 255, true
+}
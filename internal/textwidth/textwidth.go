@@ -0,0 +1,56 @@
+// Package textwidth measures, truncates and pads text by its width on
+// screen, where CJK characters and most emoji take two columns, so that
+// clipping never splits a UTF-8 sequence or misaligns a layout.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package textwidth
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// ellipsis marks where text was cut
+const ellipsis = "..."
+
+// Width returns the number of columns s takes on screen. s must not
+// contain escape sequences.
+func Width(s string) int {
+	return runewidth.StringWidth(s)
+}
+
+// Truncate shortens s to at most width columns, ending it with "..." when
+// cut
+func Truncate(s string, width int) string {
+	return runewidth.Truncate(s, width, ellipsis)
+}
+
+// TruncateLeft shortens s to at most width columns by cutting its start,
+// beginning it with "..." when cut. It suits paths, whose end matters most.
+func TruncateLeft(s string, width int) string {
+	if Width(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	used := Width(ellipsis)
+	start := len(runes)
+	for start > 0 {
+		w := runewidth.RuneWidth(runes[start-1])
+		if used+w > width {
+			break
+		}
+		used += w
+		start--
+	}
+	return ellipsis + string(runes[start:])
+}
+
+// Pad fills s with spaces on the right to width columns. Text already as
+// wide is returned unchanged.
+func Pad(s string, width int) string {
+	if n := width - Width(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
@@ -0,0 +1,160 @@
+// Package timing breaks the time of a chat turn down into waiting for the
+// API, streaming, running tools and local rendering, to tell which of them
+// makes a turn slow.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package timing
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Turn records where the time of one user turn goes. A turn can make
+// several API requests when the model calls tools. It is safe for
+// concurrent use.
+type Turn struct {
+	mu sync.Mutex
+
+	start         time.Time
+	end           time.Time
+	requests      int
+	toolCalls     int
+	requestAt     time.Time     // Start of the current request
+	firstAt       time.Time     // First event of the current request
+	renderAtFirst time.Duration // Render total when the first event came
+
+	firstToken time.Duration // Request sent until first event, summed
+	streaming  time.Duration // First event until end of stream, minus rendering
+	tools      time.Duration
+	render     time.Duration
+}
+
+// Start begins timing a turn
+func Start() *Turn {
+	return &Turn{start: time.Now()}
+}
+
+// RequestStarted marks an API request being sent
+func (t *Turn) RequestStarted() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests++
+	t.requestAt = time.Now()
+	t.firstAt = time.Time{}
+}
+
+// FirstEvent marks an event of the response stream; only the first one of
+// each request counts
+func (t *Turn) FirstEvent() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.requestAt.IsZero() || !t.firstAt.IsZero() {
+		return
+	}
+	t.firstAt = time.Now()
+	t.firstToken += t.firstAt.Sub(t.requestAt)
+	t.renderAtFirst = t.render
+}
+
+// StreamEnded marks the end of the response stream
+func (t *Turn) StreamEnded() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.requestAt.IsZero() {
+		return
+	}
+	if t.firstAt.IsZero() {
+		// Nothing was streamed; the whole wait counts as time to first token
+		t.firstToken += time.Since(t.requestAt)
+	} else {
+		t.streaming += time.Since(t.firstAt) - (t.render - t.renderAtFirst)
+	}
+	t.requestAt = time.Time{}
+}
+
+// AddTools records tool execution time (not confirmation prompts) for n
+// calls
+func (t *Turn) AddTools(d time.Duration, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tools += d
+	t.toolCalls += n
+}
+
+// AddRender records time spent drawing output
+func (t *Turn) AddRender(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.render += d
+}
+
+// Finish ends the turn; later calls have no effect
+func (t *Turn) Finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.end.IsZero() {
+		t.end = time.Now()
+	}
+}
+
+// Breakdown is a snapshot of a turn's timing
+type Breakdown struct {
+	Total      time.Duration
+	FirstToken time.Duration
+	Streaming  time.Duration
+	Tools      time.Duration
+	Render     time.Duration
+	Requests   int
+	ToolCalls  int
+}
+
+// Breakdown returns the timing so far (or of the finished turn)
+func (t *Turn) Breakdown() Breakdown {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	end := t.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return Breakdown{
+		Total:      end.Sub(t.start),
+		FirstToken: t.firstToken,
+		Streaming:  t.streaming,
+		Tools:      t.tools,
+		Render:     t.render,
+		Requests:   t.requests,
+		ToolCalls:  t.toolCalls,
+	}
+}
+
+// String describes the breakdown in one line, e.g. "turn 4.2s: first
+// token 1.1s, streaming 2.0s, tools 0.9s (3 calls), render 12ms, 2
+// requests"
+func (b Breakdown) String() string {
+	parts := []string{
+		"first token " + format(b.FirstToken),
+		"streaming " + format(b.Streaming),
+	}
+	if b.ToolCalls > 0 {
+		parts = append(parts, fmt.Sprintf("tools %s (%d calls)", format(b.Tools), b.ToolCalls))
+	}
+	parts = append(parts, "render "+format(b.Render))
+	if b.Requests > 1 {
+		parts = append(parts, fmt.Sprintf("%d requests", b.Requests))
+	}
+	return fmt.Sprintf("turn %s: %s", format(b.Total), strings.Join(parts, ", "))
+}
+
+// format rounds a duration for display
+func format(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(100 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
@@ -0,0 +1,114 @@
+// Package title generates short, human-readable session titles so saved
+// sessions can be recognized in lists and the sidebar.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package title
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/compact"
+)
+
+// DefaultModel is the cheap model used to write titles
+const DefaultModel = "gemini-2.5-flash"
+
+const (
+	maxTranscript = 4000 // Bytes of conversation sent to the model
+	maxWords      = 8
+	maxLength     = 60 // Characters, not bytes
+)
+
+const titlePrompt = `Write a title of 5 to 8 words for the conversation below.
+Reply with the title only: no quotes, no trailing punctuation, no markdown.
+
+Conversation:
+`
+
+// Generate asks the model for a title summarizing history. The result is
+// already sanitized for use as a session name.
+func Generate(ctx context.Context, client *api.Client, projectID string, history []api.Content) (string, error) {
+	transcript := compact.Transcript(history)
+	if len(transcript) > maxTranscript {
+		end := maxTranscript
+		for end > 0 && !utf8.RuneStart(transcript[end]) {
+			end--
+		}
+		transcript = transcript[:end]
+	}
+
+	req := &api.GenerateRequest{
+		Model:        DefaultModel,
+		Project:      projectID,
+		UserPromptID: fmt.Sprintf("gmn-title-%d", time.Now().UnixNano()),
+		Request: api.InnerRequest{
+			Contents: []api.Content{{
+				Role:  "user",
+				Parts: []api.Part{{Text: titlePrompt + transcript}},
+			}},
+			Config: api.GenerationConfig{
+				Temperature:     0.3,
+				MaxOutputTokens: 1024,
+			},
+		},
+	}
+
+	resp, err := client.Generate(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate title: %w", err)
+	}
+
+	var text strings.Builder
+	if len(resp.Response.Candidates) > 0 {
+		for _, p := range resp.Response.Candidates[0].Content.Parts {
+			text.WriteString(p.Text)
+		}
+	}
+	t := Sanitize(text.String())
+	if t == "" {
+		return "", fmt.Errorf("failed to generate title: empty response")
+	}
+	return t, nil
+}
+
+// Sanitize turns model output into a session name: the first line, without
+// quotes, markdown or characters that are not allowed in file names, limited
+// to maxWords words.
+func Sanitize(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimPrefix(s, "Title:")
+
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case strings.ContainsRune(`<>:"/\|?*#`+"`", r), unicode.IsControl(r):
+			return -1
+		case unicode.IsSpace(r):
+			return ' '
+		}
+		return r
+	}, s)
+
+	words := strings.Fields(s)
+	if len(words) > maxWords {
+		words = words[:maxWords]
+	}
+	s = strings.Join(words, " ")
+	s = strings.Trim(s, ` '.,;!*_-`)
+
+	if runes := []rune(s); len(runes) > maxLength {
+		s = string(runes[:maxLength])
+		if i := strings.LastIndexByte(s, ' '); i > 0 {
+			s = s[:i]
+		}
+	}
+	return s
+}
@@ -0,0 +1,129 @@
+// Package tools provides built-in tool implementations for the Gemini CLI.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SplitCommandLine splits a line into words, honoring single and double quotes
+// and backslash escapes so that values like command="ls -la" stay together.
+func SplitCommandLine(line string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	var quote rune
+	inWord := false
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in: %s", line)
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+// ParseKeyValueArgs parses key=value pairs into tool arguments.
+// Values are decoded as JSON when possible (numbers, booleans, arrays),
+// otherwise they are kept as plain strings.
+func ParseKeyValueArgs(pairs []string) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid argument %q (expected key=value)", pair)
+		}
+		var val interface{}
+		if err := json.Unmarshal([]byte(parts[1]), &val); err != nil {
+			val = parts[1]
+		}
+		args[parts[0]] = val
+	}
+	return args, nil
+}
+
+// FormatResult renders a tool result as indented JSON for display or for
+// injection into the conversation.
+func FormatResult(result map[string]interface{}) string {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", result)
+	}
+	return string(data)
+}
+
+// Invocation is a tool call requested directly by the user (e.g. /run)
+type Invocation struct {
+	Name   string
+	Args   map[string]interface{}
+	Inject bool // Attach the result to the next prompt as context
+}
+
+// ParseInvocation parses "<tool> [--inject] key=value..." into an Invocation
+func ParseInvocation(line string) (*Invocation, error) {
+	words, err := SplitCommandLine(line)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &Invocation{}
+	var pairs []string
+	for _, w := range words {
+		switch {
+		case w == "-i" || w == "--inject":
+			inv.Inject = true
+		case inv.Name == "":
+			inv.Name = w
+		default:
+			pairs = append(pairs, w)
+		}
+	}
+	if inv.Name == "" {
+		return nil, fmt.Errorf("tool name is required")
+	}
+
+	inv.Args, err = ParseKeyValueArgs(pairs)
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// ContextText formats the invocation result as context for the model
+func (inv *Invocation) ContextText(result map[string]interface{}) string {
+	argsJSON, _ := json.Marshal(inv.Args)
+	return fmt.Sprintf("Output of tool %s %s:\n```json\n%s\n```", inv.Name, argsJSON, FormatResult(result))
+}
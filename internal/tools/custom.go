@@ -0,0 +1,197 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+const (
+	// defaultCustomTimeout applies to custom tools without a timeout
+	defaultCustomTimeout = 60 * time.Second
+	// maxCustomOutput caps the output returned to the model
+	maxCustomOutput = 50000
+)
+
+// customToolName matches names the API accepts for functions
+var customToolName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]{0,63}$`)
+
+// placeholder matches {{param}} in custom tool arguments
+var placeholder = regexp.MustCompile(`\{\{\s*([a-zA-Z0-9_.-]+)\s*\}\}`)
+
+// =============================================================================
+// CustomTool - A tool backed by a program configured in settings.json
+// =============================================================================
+
+// CustomTool runs a program declared under tools.custom. The arguments of
+// the call are written to its stdin as a JSON object; stdout is the result.
+type CustomTool struct {
+	cfg     config.CustomToolConfig
+	rootDir string
+}
+
+func (t *CustomTool) Name() string        { return t.cfg.Name }
+func (t *CustomTool) DisplayName() string { return t.cfg.Name }
+func (t *CustomTool) Description() string { return t.cfg.Description }
+
+func (t *CustomTool) Parameters() json.RawMessage {
+	if len(t.cfg.Parameters) == 0 {
+		return json.RawMessage(`{"type": "object", "properties": {}}`)
+	}
+	return t.cfg.Parameters
+}
+
+func (t *CustomTool) RequiresConfirmation() bool { return !t.cfg.ReadOnly }
+func (t *CustomTool) ConfirmationType() string {
+	if t.cfg.ReadOnly {
+		return ""
+	}
+	return "exec"
+}
+
+func (t *CustomTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	input, err := json.Marshal(args)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to encode arguments: %v", err)}, nil
+	}
+
+	timeout := defaultCustomTimeout
+	if t.cfg.Timeout > 0 {
+		timeout = time.Duration(t.cfg.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, t.cfg.Command, expandPlaceholders(t.cfg.Args, args)...)
+	cmd.Dir = t.rootDir
+	cmd.Env = os.Environ()
+	for k, v := range t.cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err = cmd.Run()
+	duration := time.Since(start)
+
+	output := truncateOutput(stdout.String())
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return map[string]interface{}{
+			"error":  fmt.Sprintf("%s timed out after %s", t.cfg.Name, timeout),
+			"output": output,
+		}, nil
+	case err != nil:
+		result := map[string]interface{}{
+			"error":  fmt.Sprintf("%s failed: %v", t.cfg.Name, err),
+			"output": output,
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			result["stderr"] = truncateOutput(msg)
+		}
+		return result, nil
+	}
+
+	// Programs can return a JSON object to control the result fields
+	var result map[string]interface{}
+	if json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &result) != nil || result == nil {
+		result = map[string]interface{}{
+			"output":     output,
+			KeyMediaType: MediaText,
+			KeyBody:      "output",
+		}
+	}
+	if _, ok := result[KeySummary]; !ok {
+		result[KeySummary] = fmt.Sprintf("done in %dms", duration.Milliseconds())
+	}
+	return result, nil
+}
+
+// expandPlaceholders replaces {{param}} in args with argument values.
+// Strings are inserted as is, other values as JSON; missing ones are empty.
+func expandPlaceholders(templates []string, args map[string]interface{}) []string {
+	expanded := make([]string, len(templates))
+	for i, tmpl := range templates {
+		expanded[i] = placeholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+			switch v := args[placeholder.FindStringSubmatch(m)[1]].(type) {
+			case nil:
+				return ""
+			case string:
+				return v
+			default:
+				data, _ := json.Marshal(v)
+				return string(data)
+			}
+		})
+	}
+	return expanded
+}
+
+// truncateOutput caps text returned to the model
+func truncateOutput(s string) string {
+	if len(s) > maxCustomOutput {
+		return s[:maxCustomOutput] + "\n[Output truncated...]"
+	}
+	return s
+}
+
+// RegisterCustom registers the custom tools from settings, replacing the
+// ones registered before. Tools that are invalid or would replace another
+// tool are skipped and reported in the error.
+func (r *Registry) RegisterCustom(custom []config.CustomToolConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, tool := range r.tools {
+		if _, ok := tool.(*CustomTool); ok {
+			delete(r.tools, name)
+		}
+	}
+
+	var errs []error
+	for _, cfg := range custom {
+		switch {
+		case !customToolName.MatchString(cfg.Name):
+			errs = append(errs, fmt.Errorf("invalid tool name %q (use letters, digits, _, . and -)", cfg.Name))
+			continue
+		case cfg.Command == "":
+			errs = append(errs, fmt.Errorf("%s: command is required", cfg.Name))
+			continue
+		case len(cfg.Parameters) > 0 && !isSchemaObject(cfg.Parameters):
+			errs = append(errs, fmt.Errorf("%s: parameters must be a JSON schema object", cfg.Name))
+			continue
+		}
+		if _, exists := r.tools[cfg.Name]; exists {
+			errs = append(errs, fmt.Errorf("%s: a tool with this name already exists", cfg.Name))
+			continue
+		}
+		if cfg.Description == "" {
+			cfg.Description = "Runs " + cfg.Command
+		}
+		r.tools[cfg.Name] = &CustomTool{cfg: cfg, rootDir: r.rootDir}
+	}
+	return errors.Join(errs...)
+}
+
+// isSchemaObject reports whether data is a JSON object
+func isSchemaObject(data json.RawMessage) bool {
+	var schema map[string]interface{}
+	return json.Unmarshal(data, &schema) == nil && schema != nil
+}
@@ -0,0 +1,138 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	diffContext  = 3        // Unchanged lines shown around each change
+	maxDiffBytes = 64 << 10 // Larger diffs are left out of tool results
+)
+
+// diffLine is one line of a line-level diff: ' ', '-' or '+' and the text,
+// including its trailing newline if it has one
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// UnifiedDiff returns a unified diff between oldText and newText labelled
+// with path, and the number of lines added and removed. An empty oldText is
+// treated as a new file. The diff is "" if the texts are equal.
+func UnifiedDiff(path, oldText, newText string) (diff string, added, removed int) {
+	lines := diffLines(oldText, newText)
+
+	// Line numbers before each diff line, for hunk headers
+	oldNum := make([]int, len(lines)+1)
+	newNum := make([]int, len(lines)+1)
+	var changes []int
+	for i, l := range lines {
+		oldNum[i+1], newNum[i+1] = oldNum[i], newNum[i]
+		switch l.op {
+		case '-':
+			oldNum[i+1]++
+			removed++
+			changes = append(changes, i)
+		case '+':
+			newNum[i+1]++
+			added++
+			changes = append(changes, i)
+		default:
+			oldNum[i+1]++
+			newNum[i+1]++
+		}
+	}
+	if len(changes) == 0 {
+		return "", 0, 0
+	}
+
+	var b strings.Builder
+	if oldText == "" {
+		b.WriteString("--- /dev/null\n")
+	} else {
+		fmt.Fprintf(&b, "--- a/%s\n", path)
+	}
+	fmt.Fprintf(&b, "+++ b/%s\n", path)
+
+	// Group changes whose context would overlap into one hunk
+	for g := 0; g < len(changes); {
+		last := g
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*diffContext+1 {
+			last++
+		}
+		start := max(changes[g]-diffContext, 0)
+		end := min(changes[last]+diffContext+1, len(lines))
+		writeHunk(&b, lines[start:end], oldNum[start], oldNum[end]-oldNum[start], newNum[start], newNum[end]-newNum[start])
+		g = last + 1
+	}
+	return b.String(), added, removed
+}
+
+// diffResult adds the diff between oldText and newText to a write or edit
+// result, so UIs can show the change without re-reading the file
+func diffResult(result map[string]interface{}, path, oldText, newText string) map[string]interface{} {
+	diff, added, removed := UnifiedDiff(path, oldText, newText)
+	result["lines_added"] = added
+	result["lines_removed"] = removed
+	result[KeySummary] = fmt.Sprintf("+%d -%d lines", added, removed)
+	if diff == "" {
+		return result
+	}
+	if len(diff) > maxDiffBytes {
+		result["diff_omitted"] = true
+		return result
+	}
+	result["diff"] = diff
+	result[KeyMediaType] = MediaDiff
+	return result
+}
+
+// diffLines computes a line-level diff of oldText and newText
+func diffLines(oldText, newText string) []diffLine {
+	dmp := diffmatchpatch.New()
+	a, b, lineArray := dmp.DiffLinesToChars(oldText, newText)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lineArray)
+
+	var lines []diffLine
+	for _, d := range diffs {
+		op := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text != "" {
+				lines = append(lines, diffLine{op, text})
+			}
+		}
+	}
+	return lines
+}
+
+// writeHunk writes one "@@ -l,s +l,s @@" hunk. Start lines are 0-based.
+func writeHunk(b *strings.Builder, lines []diffLine, oldStart, oldLen, newStart, newLen int) {
+	// Empty ranges start at the line before, as in diff -u
+	if oldLen > 0 {
+		oldStart++
+	}
+	if newLen > 0 {
+		newStart++
+	}
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+	for _, l := range lines {
+		b.WriteByte(l.op)
+		b.WriteString(strings.TrimSuffix(l.text, "\n"))
+		b.WriteByte('\n')
+		if !strings.HasSuffix(l.text, "\n") {
+			b.WriteString("\\ No newline at end of file\n")
+		}
+	}
+}
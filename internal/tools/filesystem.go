@@ -4,12 +4,10 @@
 package tools
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
@@ -17,15 +15,29 @@ import (
 // ReadFileTool - Read file contents
 // =============================================================================
 
+const (
+	// defaultReadLimit is the number of lines read_file returns by default
+	defaultReadLimit = 2000
+	// defaultGlobLimit is the number of files glob returns by default
+	defaultGlobLimit = 500
+	// defaultSearchLimit and defaultSearchPerFile cap search_file_content
+	// results in total and per file by default
+	defaultSearchLimit   = 200
+	defaultSearchPerFile = 20
+	// maxSearchContext caps the context lines around each search match
+	maxSearchContext = 20
+)
+
 // ReadFileTool reads file contents
 type ReadFileTool struct {
-	rootDir string
+	rootDir  string
+	settings *Settings
 }
 
 func (t *ReadFileTool) Name() string        { return "read_file" }
 func (t *ReadFileTool) DisplayName() string { return "ReadFile" }
 func (t *ReadFileTool) Description() string {
-	return "Read the contents of a file at the specified path. Use this when you need to examine the contents of an existing file."
+	return fmt.Sprintf("Read the contents of a file at the specified path. Use this when you need to examine the contents of an existing file. Returns at most %d lines at a time; use offset and limit to read large files in chunks. Image files (PNG, JPEG, GIF, WebP) are returned as images you can examine, e.g. screenshots.", defaultReadLimit)
 }
 
 func (t *ReadFileTool) Parameters() json.RawMessage {
@@ -35,6 +47,14 @@ func (t *ReadFileTool) Parameters() json.RawMessage {
 			"path": {
 				"type": "string",
 				"description": "The path of the file to read (relative to working directory or absolute)"
+			},
+			"offset": {
+				"type": "integer",
+				"description": "1-based line number to start reading from (default: 1)"
+			},
+			"limit": {
+				"type": "integer",
+				"description": "Maximum number of lines to return (default: 2000)"
 			}
 		},
 		"required": ["path"]
@@ -43,6 +63,7 @@ func (t *ReadFileTool) Parameters() json.RawMessage {
 
 func (t *ReadFileTool) RequiresConfirmation() bool { return false }
 func (t *ReadFileTool) ConfirmationType() string   { return "" }
+func (t *ReadFileTool) ReadOnly() bool             { return true }
 
 func (t *ReadFileTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
 	path, ok := args["path"].(string)
@@ -50,24 +71,73 @@ func (t *ReadFileTool) Execute(args map[string]interface{}) (map[string]interfac
 		return map[string]interface{}{"error": "path is required and must be a string"}, nil
 	}
 
-	fullPath := t.resolvePath(path)
+	fullPath, err := t.resolvePath(path)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
 
+	offset, ok := intArg(args, "offset")
+	if !ok || offset < 1 {
+		offset = 1
+	}
+	limit, ok := intArg(args, "limit")
+	if !ok || limit < 1 {
+		limit = defaultReadLimit
+	}
+
+	// Images are returned to the model as they are
+	if result := readImage(fullPath); result != nil {
+		return result, nil
+	}
+	if result := t.settings.checkReadable(fullPath); result != nil {
+		return result, nil
+	}
 	content, err := os.ReadFile(fullPath)
 	if err != nil {
 		return map[string]interface{}{"error": fmt.Sprintf("failed to read file: %v", err)}, nil
 	}
 
-	return map[string]interface{}{
-		"content": string(content),
-		"path":    fullPath,
-	}, nil
-}
+	lines := splitLines(string(content))
+	total := len(lines)
+	// An empty file can only be read from the start
+	if offset > max(total, 1) {
+		return map[string]interface{}{"error": fmt.Sprintf("offset %d is past the end of the file (%d lines)", offset, total)}, nil
+	}
+	end := min(offset-1+limit, total)
+	lines = lines[offset-1 : end]
+	cut := truncateLongLines(lines)
 
-func (t *ReadFileTool) resolvePath(path string) string {
-	if filepath.IsAbs(path) {
-		return path
+	result := map[string]interface{}{
+		"content":     strings.Join(lines, ""),
+		"path":        fullPath,
+		"total_lines": total,
+		"media_type":  MediaText,
+		"summary":     fmt.Sprintf("%d lines", total),
+	}
+	if cut > 0 {
+		result["lines_truncated"] = cut
+		result["notice"] = fmt.Sprintf("%d line(s) longer than %d bytes were cut off (minified or generated content?).", cut, maxLineLength)
 	}
-	return filepath.Join(t.rootDir, path)
+	if offset == 1 && end == total {
+		return result, nil
+	}
+
+	result["start_line"] = offset
+	result["end_line"] = end
+	result["summary"] = fmt.Sprintf("lines %d-%d of %d", offset, end, total)
+	if end < total {
+		result["truncated"] = true
+		notice := fmt.Sprintf("Showing lines %d-%d of %d. Call read_file with offset=%d to read more.", offset, end, total, end+1)
+		if cut > 0 {
+			notice += " " + result["notice"].(string)
+		}
+		result["notice"] = notice
+	}
+	return result, nil
+}
+
+func (t *ReadFileTool) resolvePath(path string) (string, error) {
+	return t.settings.resolveWorkspacePath(t.rootDir, path)
 }
 
 // =============================================================================
@@ -76,7 +146,8 @@ func (t *ReadFileTool) resolvePath(path string) string {
 
 // WriteFileTool writes content to a file
 type WriteFileTool struct {
-	rootDir string
+	rootDir  string
+	settings *Settings
 }
 
 func (t *WriteFileTool) Name() string        { return "write_file" }
@@ -116,7 +187,11 @@ func (t *WriteFileTool) Execute(args map[string]interface{}) (map[string]interfa
 		return map[string]interface{}{"error": "content is required and must be a string"}, nil
 	}
 
-	fullPath := t.resolvePath(path)
+	fullPath, err := t.resolvePath(path)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	original, _ := os.ReadFile(fullPath) // Empty for new files
 
 	// Ensure directory exists
 	dir := filepath.Dir(fullPath)
@@ -128,18 +203,15 @@ func (t *WriteFileTool) Execute(args map[string]interface{}) (map[string]interfa
 		return map[string]interface{}{"error": fmt.Sprintf("failed to write file: %v", err)}, nil
 	}
 
-	return map[string]interface{}{
+	return diffResult(map[string]interface{}{
 		"success": true,
 		"path":    fullPath,
 		"message": fmt.Sprintf("Successfully wrote %d bytes to %s", len(content), fullPath),
-	}, nil
+	}, filepath.ToSlash(path), string(original), content), nil
 }
 
-func (t *WriteFileTool) resolvePath(path string) string {
-	if filepath.IsAbs(path) {
-		return path
-	}
-	return filepath.Join(t.rootDir, path)
+func (t *WriteFileTool) resolvePath(path string) (string, error) {
+	return t.settings.resolveWritablePath(t.rootDir, path)
 }
 
 // GetOriginalContent returns the current content of a file (for diff display)
@@ -148,7 +220,10 @@ func (t *WriteFileTool) GetOriginalContent(args map[string]interface{}) (string,
 	if !ok {
 		return "", fmt.Errorf("path is required")
 	}
-	fullPath := t.resolvePath(path)
+	fullPath, err := t.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
 	content, err := os.ReadFile(fullPath)
 	if os.IsNotExist(err) {
 		return "", nil // New file
@@ -174,7 +249,8 @@ func (t *WriteFileTool) GetNewContent(args map[string]interface{}) (string, erro
 
 // ListDirectoryTool lists the contents of a directory
 type ListDirectoryTool struct {
-	rootDir string
+	rootDir  string
+	settings *Settings
 }
 
 func (t *ListDirectoryTool) Name() string        { return "list_directory" }
@@ -198,6 +274,7 @@ func (t *ListDirectoryTool) Parameters() json.RawMessage {
 
 func (t *ListDirectoryTool) RequiresConfirmation() bool { return false }
 func (t *ListDirectoryTool) ConfirmationType() string   { return "" }
+func (t *ListDirectoryTool) ReadOnly() bool             { return true }
 
 func (t *ListDirectoryTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
 	path, ok := args["path"].(string)
@@ -205,7 +282,10 @@ func (t *ListDirectoryTool) Execute(args map[string]interface{}) (map[string]int
 		return map[string]interface{}{"error": "path is required and must be a string"}, nil
 	}
 
-	fullPath := t.resolvePath(path)
+	fullPath, err := t.resolvePath(path)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
 
 	entries, err := os.ReadDir(fullPath)
 	if err != nil {
@@ -226,16 +306,17 @@ func (t *ListDirectoryTool) Execute(args map[string]interface{}) (map[string]int
 	}
 
 	return map[string]interface{}{
-		"path":    fullPath,
-		"entries": files,
+		"path":       fullPath,
+		"entries":    files,
+		"media_type": MediaTable,
+		"body":       "entries",
+		"columns":    []string{"name", "isDir", "size"},
+		"summary":    fmt.Sprintf("%d entries", len(files)),
 	}, nil
 }
 
-func (t *ListDirectoryTool) resolvePath(path string) string {
-	if filepath.IsAbs(path) {
-		return path
-	}
-	return filepath.Join(t.rootDir, path)
+func (t *ListDirectoryTool) resolvePath(path string) (string, error) {
+	return t.settings.resolveWorkspacePath(t.rootDir, path)
 }
 
 // =============================================================================
@@ -244,13 +325,14 @@ func (t *ListDirectoryTool) resolvePath(path string) string {
 
 // GlobTool finds files matching a glob pattern
 type GlobTool struct {
-	rootDir string
+	rootDir  string
+	settings *Settings
 }
 
 func (t *GlobTool) Name() string        { return "glob" }
 func (t *GlobTool) DisplayName() string { return "FindFiles" }
 func (t *GlobTool) Description() string {
-	return "Find files matching a glob pattern. Supports wildcards like *, **, and ?."
+	return "Find files matching a glob pattern. ** matches any number of directories, * and ? match within a name, and {a,b} matches either alternative (e.g. 'src/**/*.{ts,tsx}'). Files and directories ignored by .gitignore or .gmnignore, .git and node_modules are skipped."
 }
 
 func (t *GlobTool) Parameters() json.RawMessage {
@@ -260,6 +342,14 @@ func (t *GlobTool) Parameters() json.RawMessage {
 			"pattern": {
 				"type": "string",
 				"description": "The glob pattern to match (e.g., '**/*.go', 'src/*.ts')"
+			},
+			"include_ignored": {
+				"type": "boolean",
+				"description": "Also return files ignored by .gitignore/.gmnignore (default: false)"
+			},
+			"limit": {
+				"type": "integer",
+				"description": "Maximum number of files to return (default: 500)"
 			}
 		},
 		"required": ["pattern"]
@@ -268,83 +358,53 @@ func (t *GlobTool) Parameters() json.RawMessage {
 
 func (t *GlobTool) RequiresConfirmation() bool { return false }
 func (t *GlobTool) ConfirmationType() string   { return "" }
+func (t *GlobTool) ReadOnly() bool             { return true }
 
 func (t *GlobTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
 	pattern, ok := args["pattern"].(string)
 	if !ok {
 		return map[string]interface{}{"error": "pattern is required and must be a string"}, nil
 	}
+	includeIgnored, _ := args["include_ignored"].(bool)
+	limit, ok := intArg(args, "limit")
+	if !ok || limit <= 0 {
+		limit = defaultGlobLimit
+	}
 
-	var matches []string
-
-	// Handle ** pattern by walking the directory tree
-	if strings.Contains(pattern, "**") {
-		matches = t.globRecursive(pattern)
-	} else {
-		fullPattern := filepath.Join(t.rootDir, pattern)
-		var err error
-		matches, err = filepath.Glob(fullPattern)
-		if err != nil {
-			return map[string]interface{}{"error": fmt.Sprintf("invalid pattern: %v", err)}, nil
-		}
+	fullPattern := pattern
+	if !filepath.IsAbs(fullPattern) {
+		fullPattern = filepath.Join(t.rootDir, pattern)
+	}
+	if err := t.settings.checkSandbox(t.rootDir, filepath.Clean(filepath.FromSlash(globBase(filepath.ToSlash(fullPattern))))); err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	matches, truncated, err := globFiles(t.rootDir, filepath.ToSlash(fullPattern), includeIgnored, limit)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("invalid pattern: %v", err)}, nil
 	}
 
 	// Convert to relative paths
 	relMatches := make([]string, 0, len(matches))
 	for _, m := range matches {
 		rel, err := filepath.Rel(t.rootDir, m)
-		if err != nil {
+		if err != nil || strings.HasPrefix(rel, "..") {
 			rel = m
 		}
 		relMatches = append(relMatches, rel)
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"pattern": pattern,
 		"matches": relMatches,
 		"count":   len(relMatches),
-	}, nil
-}
-
-func (t *GlobTool) globRecursive(pattern string) []string {
-	var matches []string
-
-	// Split pattern at **
-	parts := strings.Split(pattern, "**")
-	if len(parts) != 2 {
-		// For simplicity, only handle one ** in the pattern
-		return matches
+		"summary": fmt.Sprintf("%d files", len(relMatches)),
 	}
-
-	prefix := strings.TrimSuffix(parts[0], string(filepath.Separator))
-	suffix := strings.TrimPrefix(parts[1], string(filepath.Separator))
-
-	startDir := t.rootDir
-	if prefix != "" {
-		startDir = filepath.Join(t.rootDir, prefix)
+	if truncated {
+		result["truncated"] = true
+		result["notice"] = fmt.Sprintf("Only the first %d matches are shown. Use a more specific pattern or a higher limit.", limit)
+		result["summary"] = fmt.Sprintf("%d+ files", len(relMatches))
 	}
-
-	filepath.Walk(startDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-		if info.IsDir() {
-			return nil
-		}
-
-		// Check if the file matches the suffix pattern
-		if suffix == "" {
-			matches = append(matches, path)
-		} else {
-			matched, _ := filepath.Match(suffix, filepath.Base(path))
-			if matched {
-				matches = append(matches, path)
-			}
-		}
-		return nil
-	})
-
-	return matches
+	return result, nil
 }
 
 // =============================================================================
@@ -353,13 +413,14 @@ func (t *GlobTool) globRecursive(pattern string) []string {
 
 // SearchFileContentTool searches for text content in files
 type SearchFileContentTool struct {
-	rootDir string
+	rootDir  string
+	settings *Settings
 }
 
 func (t *SearchFileContentTool) Name() string        { return "search_file_content" }
 func (t *SearchFileContentTool) DisplayName() string { return "SearchText" }
 func (t *SearchFileContentTool) Description() string {
-	return "Search for text or regex pattern in files. Returns matching lines with context."
+	return "Search for text or a regex pattern in files. Returns matching lines, optionally with context lines. Files ignored by .gitignore or .gmnignore, .git, node_modules and binary files are skipped. Results are capped per file and in total; narrow the path or pattern when a search is truncated."
 }
 
 func (t *SearchFileContentTool) Parameters() json.RawMessage {
@@ -377,6 +438,34 @@ func (t *SearchFileContentTool) Parameters() json.RawMessage {
 			"regex": {
 				"type": "boolean",
 				"description": "Whether to treat pattern as regex (default: false)"
+			},
+			"case_insensitive": {
+				"type": "boolean",
+				"description": "Ignore case when matching (default: false)"
+			},
+			"whole_word": {
+				"type": "boolean",
+				"description": "Only match whole words (default: false)"
+			},
+			"before": {
+				"type": "integer",
+				"description": "Number of context lines to return before each match (default: 0)"
+			},
+			"after": {
+				"type": "integer",
+				"description": "Number of context lines to return after each match (default: 0)"
+			},
+			"max_per_file": {
+				"type": "integer",
+				"description": "Maximum number of matches per file (default: 20)"
+			},
+			"limit": {
+				"type": "integer",
+				"description": "Maximum number of matches in total (default: 200)"
+			},
+			"include_ignored": {
+				"type": "boolean",
+				"description": "Also search files ignored by .gitignore/.gmnignore (default: false)"
 			}
 		},
 		"required": ["pattern", "path"]
@@ -385,6 +474,7 @@ func (t *SearchFileContentTool) Parameters() json.RawMessage {
 
 func (t *SearchFileContentTool) RequiresConfirmation() bool { return false }
 func (t *SearchFileContentTool) ConfirmationType() string   { return "" }
+func (t *SearchFileContentTool) ReadOnly() bool             { return true }
 
 func (t *SearchFileContentTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
 	pattern, ok := args["pattern"].(string)
@@ -398,87 +488,88 @@ func (t *SearchFileContentTool) Execute(args map[string]interface{}) (map[string
 	}
 
 	isRegex, _ := args["regex"].(bool)
+	ignoreCase, _ := args["case_insensitive"].(bool)
+	wholeWord, _ := args["whole_word"].(bool)
 
-	fullPath := t.resolvePath(path)
-
-	var re *regexp.Regexp
-	var err error
-	if isRegex {
-		re, err = regexp.Compile(pattern)
-		if err != nil {
-			return map[string]interface{}{"error": fmt.Sprintf("invalid regex: %v", err)}, nil
-		}
+	opts := searchOptions{maxPerFile: defaultSearchPerFile, limit: defaultSearchLimit, maxBytes: t.settings.GetMaxReadBytes()}
+	opts.includeIgnored, _ = args["include_ignored"].(bool)
+	if n, ok := intArg(args, "before"); ok && n > 0 {
+		opts.before = min(n, maxSearchContext)
 	}
-
-	results := make([]map[string]interface{}, 0)
-
-	info, err := os.Stat(fullPath)
-	if err != nil {
-		return map[string]interface{}{"error": fmt.Sprintf("path not found: %v", err)}, nil
+	if n, ok := intArg(args, "after"); ok && n > 0 {
+		opts.after = min(n, maxSearchContext)
 	}
-
-	if info.IsDir() {
-		// Search in directory
-		filepath.Walk(fullPath, func(filePath string, info os.FileInfo, err error) error {
-			if err != nil || info.IsDir() {
-				return nil
-			}
-			matches := t.searchInFile(filePath, pattern, re)
-			results = append(results, matches...)
-			return nil
-		})
-	} else {
-		// Search in single file
-		results = t.searchInFile(fullPath, pattern, re)
+	if n, ok := intArg(args, "max_per_file"); ok && n > 0 {
+		opts.maxPerFile = n
+	}
+	if n, ok := intArg(args, "limit"); ok && n > 0 {
+		opts.limit = n
 	}
 
-	return map[string]interface{}{
-		"pattern": pattern,
-		"matches": results,
-		"count":   len(results),
-	}, nil
-}
-
-func (t *SearchFileContentTool) searchInFile(filePath, pattern string, re *regexp.Regexp) []map[string]interface{} {
-	var results []map[string]interface{}
-
-	file, err := os.Open(filePath)
+	re, err := compileSearch(pattern, isRegex, ignoreCase, wholeWord)
 	if err != nil {
-		return results
+		return map[string]interface{}{"error": fmt.Sprintf("invalid regex: %v", err)}, nil
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
+	fullPath, err := t.resolvePath(path)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("path not found: %v", err)}, nil
+	}
 
-		var matched bool
-		if re != nil {
-			matched = re.MatchString(line)
-		} else {
-			matched = strings.Contains(line, pattern)
+	found, stats := searchTree(t.rootDir, fullPath, re, opts)
+	results := make([]map[string]interface{}, 0, len(found))
+	for _, m := range found {
+		rel, err := filepath.Rel(t.rootDir, m.file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = m.file
 		}
-
-		if matched {
-			rel, _ := filepath.Rel(t.rootDir, filePath)
-			results = append(results, map[string]interface{}{
-				"file": rel,
-				"line": lineNum,
-				"text": line,
-			})
+		row := map[string]interface{}{
+			"file": rel,
+			"line": m.line,
+			"text": m.text,
+		}
+		if len(m.before) > 0 {
+			row["before"] = m.before
 		}
+		if len(m.after) > 0 {
+			row["after"] = m.after
+		}
+		results = append(results, row)
 	}
 
-	return results
-}
+	result := map[string]interface{}{
+		"pattern":    pattern,
+		"matches":    results,
+		"count":      len(results),
+		"media_type": MediaTable,
+		"body":       "matches",
+		"columns":    []string{"file", "line", "text"},
+		"summary":    fmt.Sprintf("%d matches", len(results)),
+	}
 
-func (t *SearchFileContentTool) resolvePath(path string) string {
-	if filepath.IsAbs(path) {
-		return path
+	var notices []string
+	if stats.truncated {
+		result["truncated"] = true
+		result["summary"] = fmt.Sprintf("%d+ matches", len(results))
+		notices = append(notices, fmt.Sprintf("Only the first %d matches are shown. Use a more specific pattern or path, or a higher limit.", opts.limit))
+	}
+	if stats.fileCaps > 0 {
+		notices = append(notices, fmt.Sprintf("%d file(s) had more than %d matches; only the first %d per file are shown.", stats.fileCaps, opts.maxPerFile, opts.maxPerFile))
+	}
+	if stats.binary > 0 || stats.tooLarge > 0 {
+		result["skipped"] = map[string]int{"binary": stats.binary, "too_large": stats.tooLarge}
 	}
-	return filepath.Join(t.rootDir, path)
+	if len(notices) > 0 {
+		result["notice"] = strings.Join(notices, " ")
+	}
+	return result, nil
+}
+
+func (t *SearchFileContentTool) resolvePath(path string) (string, error) {
+	return t.settings.resolveWorkspacePath(t.rootDir, path)
 }
 
 // =============================================================================
@@ -487,7 +578,8 @@ func (t *SearchFileContentTool) resolvePath(path string) string {
 
 // EditFileTool edits specific parts of a file (search and replace)
 type EditFileTool struct {
-	rootDir string
+	rootDir  string
+	settings *Settings
 }
 
 func (t *EditFileTool) Name() string        { return "edit_file" }
@@ -536,7 +628,10 @@ func (t *EditFileTool) Execute(args map[string]interface{}) (map[string]interfac
 		return map[string]interface{}{"error": "new_text is required and must be a string"}, nil
 	}
 
-	fullPath := t.resolvePath(path)
+	fullPath, err := t.resolvePath(path)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
 
 	content, err := os.ReadFile(fullPath)
 	if err != nil {
@@ -554,18 +649,15 @@ func (t *EditFileTool) Execute(args map[string]interface{}) (map[string]interfac
 		return map[string]interface{}{"error": fmt.Sprintf("failed to write file: %v", err)}, nil
 	}
 
-	return map[string]interface{}{
+	return diffResult(map[string]interface{}{
 		"success": true,
 		"path":    fullPath,
 		"message": "Successfully edited file",
-	}, nil
+	}, filepath.ToSlash(path), contentStr, newContent), nil
 }
 
-func (t *EditFileTool) resolvePath(path string) string {
-	if filepath.IsAbs(path) {
-		return path
-	}
-	return filepath.Join(t.rootDir, path)
+func (t *EditFileTool) resolvePath(path string) (string, error) {
+	return t.settings.resolveWritablePath(t.rootDir, path)
 }
 
 // GetOriginalContent returns the current content of a file (for diff display)
@@ -574,7 +666,10 @@ func (t *EditFileTool) GetOriginalContent(args map[string]interface{}) (string,
 	if !ok {
 		return "", fmt.Errorf("path is required")
 	}
-	fullPath := t.resolvePath(path)
+	fullPath, err := t.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
 	content, err := os.ReadFile(fullPath)
 	if err != nil {
 		return "", err
@@ -599,7 +694,10 @@ func (t *EditFileTool) GetNewContent(args map[string]interface{}) (string, error
 		return "", fmt.Errorf("new_text is required")
 	}
 
-	fullPath := t.resolvePath(path)
+	fullPath, err := t.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
 	content, err := os.ReadFile(fullPath)
 	if err != nil {
 		return "", err
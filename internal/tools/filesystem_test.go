@@ -0,0 +1,98 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFileRange(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"empty.txt": "",
+		"three.txt": "one\ntwo\nthree\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry := NewRegistry(root)
+	read, _ := registry.Get("read_file")
+
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		content string // Ignored when the call fails
+		fails   bool
+	}{
+		{"empty file", map[string]interface{}{"path": "empty.txt"}, "", false},
+		{"empty file from the start", map[string]interface{}{"path": "empty.txt", "offset": 1.0}, "", false},
+		{"empty file past the end", map[string]interface{}{"path": "empty.txt", "offset": 2.0}, "", true},
+		{"whole file", map[string]interface{}{"path": "three.txt"}, "one\ntwo\nthree\n", false},
+		{"offset", map[string]interface{}{"path": "three.txt", "offset": 2.0}, "two\nthree\n", false},
+		{"limit", map[string]interface{}{"path": "three.txt", "limit": 2.0}, "one\ntwo\n", false},
+		{"last line", map[string]interface{}{"path": "three.txt", "offset": 3.0, "limit": 5.0}, "three\n", false},
+		{"past the end", map[string]interface{}{"path": "three.txt", "offset": 4.0}, "", true},
+		{"offset below 1", map[string]interface{}{"path": "three.txt", "offset": 0.0, "limit": 1.0}, "one\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := read.Execute(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, failed := result["error"]; failed != tt.fails {
+				t.Fatalf("read_file(%v) = %v, want failure %v", tt.args, result, tt.fails)
+			}
+			if !tt.fails && result["content"] != tt.content {
+				t.Errorf("read_file(%v) content = %q, want %q", tt.args, result["content"], tt.content)
+			}
+		})
+	}
+}
+
+func TestWriteFileProtected(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".gmn"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, ".gmn", "settings.json"), filepath.Join(root, "link.json")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	registry := NewRegistry(root)
+	write, _ := registry.Get("write_file")
+	edit, _ := registry.Get("edit_file")
+
+	tests := []struct {
+		tool    BuiltinTool
+		path    string
+		refused bool
+	}{
+		{write, ".gmn/settings.json", true},
+		{write, ".gemini/settings.json", true},
+		{write, "sub/.gemini/settings.json", true},
+		{write, ".GMN/Settings.json", true},
+		{write, "link.json", true},
+		{edit, ".gmn/settings.json", true},
+		{write, "settings.json", false},
+		{write, ".gmn/commands/review.toml", false},
+		{write, ".gmn/settings.json.bak", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool.Name()+" "+tt.path, func(t *testing.T) {
+			args := map[string]interface{}{"path": tt.path, "content": "{}", "old_text": "{", "new_text": "["}
+			result, err := tt.tool.Execute(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, _ := result["error"].(string)
+			if refused := strings.Contains(msg, "gmn's settings"); refused != tt.refused {
+				t.Errorf("%s(%s) = %v, want refused %v", tt.tool.Name(), tt.path, result, tt.refused)
+			}
+		})
+	}
+}
@@ -0,0 +1,561 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// gitTimeout bounds every git command
+	gitTimeout = 30 * time.Second
+	// defaultGitLogCount and maxGitLogCount limit git_log
+	defaultGitLogCount = 20
+	maxGitLogCount     = 200
+)
+
+// gitSafeConfig overrides the repository settings that make read-only git
+// commands run programs: the git tools run without confirmation, and a
+// cloned repository's .git/config must not turn git_status into code
+// execution. Diffs also pass --no-ext-diff and --no-textconv.
+var gitSafeConfig = []string{
+	"-c", "core.fsmonitor=",
+	"-c", "diff.external=",
+}
+
+// runGit runs git in dir and returns its stdout. Failures include git's
+// error output.
+func runGit(dir string, env []string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", slices.Concat(gitSafeConfig, args)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("git %s timed out after %s", args[0], gitTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// stringListArg returns a list of strings argument
+func stringListArg(args map[string]interface{}, key string) []string {
+	var out []string
+	switch v := args[key].(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+	case []string:
+		out = v
+	case string:
+		if v != "" {
+			out = []string{v}
+		}
+	}
+	return out
+}
+
+// diffStats counts added and removed lines of a unified diff
+func diffStats(diff string) (files, added, removed int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files++
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return files, added, removed
+}
+
+// gitDiffResult builds a diff result, cutting diffs that are too large for
+// the model's context
+func gitDiffResult(diff string) map[string]interface{} {
+	files, added, removed := diffStats(diff)
+	result := map[string]interface{}{
+		"files_changed": files,
+		"lines_added":   added,
+		"lines_removed": removed,
+		KeySummary:      fmt.Sprintf("%d files, +%d -%d lines", files, added, removed),
+	}
+	if diff == "" {
+		result["diff"] = ""
+		result[KeySummary] = "no changes"
+		return result
+	}
+	if len(diff) > maxDiffBytes {
+		cut := strings.LastIndexByte(diff[:maxDiffBytes], '\n') + 1
+		diff = diff[:cut]
+		result["truncated"] = true
+		result["notice"] = fmt.Sprintf("The diff is larger than %s and was cut off. Pass a path to see individual files.", formatBytes(maxDiffBytes))
+	}
+	result["diff"] = diff
+	result[KeyMediaType] = MediaDiff
+	return result
+}
+
+// =============================================================================
+// GitStatusTool - Show the working tree status
+// =============================================================================
+
+// GitStatusTool shows the branch and changed files of the repository
+type GitStatusTool struct {
+	rootDir string
+}
+
+func (t *GitStatusTool) Name() string        { return "git_status" }
+func (t *GitStatusTool) DisplayName() string { return "GitStatus" }
+func (t *GitStatusTool) Description() string {
+	return "Show the current git branch, its upstream, and the staged, unstaged and untracked files."
+}
+
+func (t *GitStatusTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {}
+	}`)
+}
+
+func (t *GitStatusTool) RequiresConfirmation() bool { return false }
+func (t *GitStatusTool) ConfirmationType() string   { return "" }
+
+func (t *GitStatusTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	out, err := runGit(t.rootDir, nil, "status", "--porcelain=v1", "--branch", "-z")
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	result := map[string]interface{}{}
+	files := make([]map[string]interface{}, 0)
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if strings.HasPrefix(entry, "## ") {
+			branch := strings.TrimPrefix(entry, "## ")
+			if idx := strings.Index(branch, " ["); idx >= 0 {
+				result["tracking"] = strings.Trim(branch[idx+1:], "[]")
+				branch = branch[:idx]
+			}
+			if local, upstream, ok := strings.Cut(branch, "..."); ok {
+				branch = local
+				result["upstream"] = upstream
+			}
+			result["branch"] = branch
+			continue
+		}
+		if len(entry) < 4 {
+			continue
+		}
+		file := map[string]interface{}{
+			"status": strings.TrimSpace(entry[:2]),
+			"path":   entry[3:],
+		}
+		switch x, y := entry[0], entry[1]; {
+		case x == '?':
+			file["state"] = "untracked"
+		case x != ' ' && y != ' ':
+			file["state"] = "staged+unstaged"
+		case x != ' ':
+			file["state"] = "staged"
+		default:
+			file["state"] = "unstaged"
+		}
+		// Renames and copies are followed by the original path
+		if entry[0] == 'R' || entry[0] == 'C' {
+			if i+1 < len(entries) {
+				file["from"] = entries[i+1]
+				i++
+			}
+		}
+		files = append(files, file)
+	}
+
+	result["files"] = files
+	result["count"] = len(files)
+	result[KeyMediaType] = MediaTable
+	result[KeyBody] = "files"
+	result[KeyColumns] = []string{"status", "state", "path"}
+	branch, _ := result["branch"].(string)
+	if len(files) == 0 {
+		result[KeySummary] = fmt.Sprintf("%s, clean", branch)
+	} else {
+		result[KeySummary] = fmt.Sprintf("%s, %d changed files", branch, len(files))
+	}
+	return result, nil
+}
+
+// =============================================================================
+// GitDiffTool - Show changes
+// =============================================================================
+
+// GitDiffTool shows unstaged, staged, or ref-to-ref changes
+type GitDiffTool struct {
+	rootDir string
+}
+
+func (t *GitDiffTool) Name() string        { return "git_diff" }
+func (t *GitDiffTool) DisplayName() string { return "GitDiff" }
+func (t *GitDiffTool) Description() string {
+	return "Show a git diff as a unified diff. By default shows unstaged changes; set staged for what would be committed, or ref to compare the working tree (or another ref, e.g. 'main...HEAD') against it."
+}
+
+func (t *GitDiffTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"staged": {
+				"type": "boolean",
+				"description": "Show staged changes instead of unstaged ones (default: false)"
+			},
+			"ref": {
+				"type": "string",
+				"description": "Commit, branch or range to diff against (e.g. 'HEAD~3', 'main...HEAD')"
+			},
+			"path": {
+				"type": "string",
+				"description": "Limit the diff to a file or directory"
+			}
+		}
+	}`)
+}
+
+func (t *GitDiffTool) RequiresConfirmation() bool { return false }
+func (t *GitDiffTool) ConfirmationType() string   { return "" }
+
+func (t *GitDiffTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	gitArgs := []string{"diff", "--no-color", "--no-ext-diff", "--no-textconv"}
+	if staged, _ := args["staged"].(bool); staged {
+		gitArgs = append(gitArgs, "--cached")
+	}
+	if ref, _ := args["ref"].(string); ref != "" {
+		if strings.HasPrefix(ref, "-") {
+			return map[string]interface{}{"error": "ref must not start with '-'"}, nil
+		}
+		gitArgs = append(gitArgs, ref)
+	}
+	gitArgs = append(gitArgs, "--")
+	if path, _ := args["path"].(string); path != "" {
+		gitArgs = append(gitArgs, path)
+	}
+
+	out, err := runGit(t.rootDir, nil, gitArgs...)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return gitDiffResult(out), nil
+}
+
+// =============================================================================
+// GitLogTool - Show commit history
+// =============================================================================
+
+// GitLogTool lists recent commits
+type GitLogTool struct {
+	rootDir string
+}
+
+func (t *GitLogTool) Name() string        { return "git_log" }
+func (t *GitLogTool) DisplayName() string { return "GitLog" }
+func (t *GitLogTool) Description() string {
+	return fmt.Sprintf("List recent git commits (hash, date, author, subject), newest first. Returns %d commits by default.", defaultGitLogCount)
+}
+
+func (t *GitLogTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"ref": {
+				"type": "string",
+				"description": "Branch, commit or range to list (default: HEAD)"
+			},
+			"path": {
+				"type": "string",
+				"description": "Only list commits touching this file or directory"
+			},
+			"limit": {
+				"type": "integer",
+				"description": "Maximum number of commits (default: 20, max: 200)"
+			}
+		}
+	}`)
+}
+
+func (t *GitLogTool) RequiresConfirmation() bool { return false }
+func (t *GitLogTool) ConfirmationType() string   { return "" }
+
+func (t *GitLogTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	limit, ok := intArg(args, "limit")
+	if !ok || limit <= 0 {
+		limit = defaultGitLogCount
+	}
+	limit = min(limit, maxGitLogCount)
+
+	gitArgs := []string{"log", "--no-color", fmt.Sprintf("--max-count=%d", limit), "--format=%h%x1f%aI%x1f%an%x1f%s%x1e"}
+	if ref, _ := args["ref"].(string); ref != "" {
+		if strings.HasPrefix(ref, "-") {
+			return map[string]interface{}{"error": "ref must not start with '-'"}, nil
+		}
+		gitArgs = append(gitArgs, ref)
+	}
+	gitArgs = append(gitArgs, "--")
+	if path, _ := args["path"].(string); path != "" {
+		gitArgs = append(gitArgs, path)
+	}
+
+	out, err := runGit(t.rootDir, nil, gitArgs...)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	commits := make([]map[string]interface{}, 0, limit)
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		commits = append(commits, map[string]interface{}{
+			"commit":  fields[0],
+			"date":    fields[1],
+			"author":  fields[2],
+			"subject": fields[3],
+		})
+	}
+
+	return map[string]interface{}{
+		"commits":    commits,
+		"count":      len(commits),
+		KeyMediaType: MediaTable,
+		KeyBody:      "commits",
+		KeyColumns:   []string{"commit", "date", "author", "subject"},
+		KeySummary:   fmt.Sprintf("%d commits", len(commits)),
+	}, nil
+}
+
+// =============================================================================
+// GitBranchTool - List branches
+// =============================================================================
+
+// GitBranchTool lists branches
+type GitBranchTool struct {
+	rootDir string
+}
+
+func (t *GitBranchTool) Name() string        { return "git_branch" }
+func (t *GitBranchTool) DisplayName() string { return "GitBranch" }
+func (t *GitBranchTool) Description() string {
+	return "List git branches with the current branch, upstreams and last commits."
+}
+
+func (t *GitBranchTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"remote": {
+				"type": "boolean",
+				"description": "Also list remote-tracking branches (default: false)"
+			}
+		}
+	}`)
+}
+
+func (t *GitBranchTool) RequiresConfirmation() bool { return false }
+func (t *GitBranchTool) ConfirmationType() string   { return "" }
+
+func (t *GitBranchTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	gitArgs := []string{"branch", "--no-color", "--format=%(HEAD)%1f%(refname:short)%1f%(upstream:short)%1f%(objectname:short)%1f%(contents:subject)"}
+	if remote, _ := args["remote"].(bool); remote {
+		gitArgs = append(gitArgs, "--all")
+	}
+
+	out, err := runGit(t.rootDir, nil, gitArgs...)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	current := ""
+	branches := make([]map[string]interface{}, 0)
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		isCurrent := fields[0] == "*"
+		if isCurrent {
+			current = fields[1]
+		}
+		branches = append(branches, map[string]interface{}{
+			"current":  isCurrent,
+			"name":     fields[1],
+			"upstream": fields[2],
+			"commit":   fields[3],
+			"subject":  fields[4],
+		})
+	}
+
+	return map[string]interface{}{
+		"current":    current,
+		"branches":   branches,
+		"count":      len(branches),
+		KeyMediaType: MediaTable,
+		KeyBody:      "branches",
+		KeyColumns:   []string{"name", "upstream", "commit", "subject"},
+		KeySummary:   fmt.Sprintf("%d branches, on %s", len(branches), current),
+	}, nil
+}
+
+// =============================================================================
+// GitCommitTool - Create a commit
+// =============================================================================
+
+// GitCommitTool stages files and commits. The confirmation prompt shows the
+// message and exactly the diff that will be committed.
+type GitCommitTool struct {
+	rootDir string
+}
+
+func (t *GitCommitTool) Name() string        { return "git_commit" }
+func (t *GitCommitTool) DisplayName() string { return "GitCommit" }
+func (t *GitCommitTool) Description() string {
+	return "Create a git commit with the given message. Commits what is staged; pass paths to stage those files first, or all to stage every modified and deleted tracked file. The user sees the message and the diff to be committed before approving."
+}
+
+func (t *GitCommitTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"message": {
+				"type": "string",
+				"description": "The commit message (subject line, optionally followed by a blank line and a body)"
+			},
+			"paths": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "Files or directories to stage before committing (new files included)"
+			},
+			"all": {
+				"type": "boolean",
+				"description": "Stage all modified and deleted tracked files before committing (default: false)"
+			}
+		},
+		"required": ["message"]
+	}`)
+}
+
+func (t *GitCommitTool) RequiresConfirmation() bool { return true }
+func (t *GitCommitTool) ConfirmationType() string   { return "exec" }
+
+func (t *GitCommitTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	message, _ := args["message"].(string)
+	if strings.TrimSpace(message) == "" {
+		return map[string]interface{}{"error": "message is required and cannot be empty"}, nil
+	}
+
+	if err := t.stage(args, nil); err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	diff, err := runGit(t.rootDir, nil, "diff", "--cached", "--no-color", "--no-ext-diff", "--no-textconv")
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	if diff == "" {
+		return map[string]interface{}{"error": "nothing to commit: no staged changes (pass paths or all to stage files)"}, nil
+	}
+
+	msgFile, err := os.CreateTemp("", "gmn-commit-*.txt")
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to write commit message: %v", err)}, nil
+	}
+	defer os.Remove(msgFile.Name())
+	msgFile.WriteString(message)
+	msgFile.Close()
+
+	if _, err := runGit(t.rootDir, nil, "commit", "--quiet", "--file="+msgFile.Name()); err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	hash, err := runGit(t.rootDir, nil, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	files, added, removed := diffStats(diff)
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return map[string]interface{}{
+		"success":       true,
+		"commit":        strings.TrimSpace(hash),
+		"subject":       subject,
+		"files_changed": files,
+		"lines_added":   added,
+		"lines_removed": removed,
+		KeySummary:      fmt.Sprintf("%s %s (%d files, +%d -%d)", strings.TrimSpace(hash), subject, files, added, removed),
+	}, nil
+}
+
+// Preview returns the diff that would be committed, shown in the
+// confirmation prompt. Staging is simulated on a copy of the index, so
+// nothing changes until the user approves.
+func (t *GitCommitTool) Preview(args map[string]interface{}) (string, error) {
+	index, err := runGit(t.rootDir, nil, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", err
+	}
+	index = strings.TrimSpace(index)
+	if !filepath.IsAbs(index) {
+		index = filepath.Join(t.rootDir, index)
+	}
+
+	tmp, err := os.CreateTemp("", "gmn-index-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if data, err := os.ReadFile(index); err == nil {
+		tmp.Write(data)
+	}
+	tmp.Close()
+
+	env := []string{"GIT_INDEX_FILE=" + tmp.Name()}
+	if err := t.stage(args, env); err != nil {
+		return "", err
+	}
+	return runGit(t.rootDir, env, "diff", "--cached", "--no-color", "--no-ext-diff", "--no-textconv")
+}
+
+// stage stages the requested paths, using env to select the index
+func (t *GitCommitTool) stage(args map[string]interface{}, env []string) error {
+	if all, _ := args["all"].(bool); all {
+		if _, err := runGit(t.rootDir, env, "add", "--update"); err != nil {
+			return err
+		}
+	}
+	if paths := stringListArg(args, "paths"); len(paths) > 0 {
+		if _, err := runGit(t.rootDir, env, append([]string{"add", "--"}, paths...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// errGlobLimit stops a walk once enough matches were found
+var errGlobLimit = errors.New("glob limit reached")
+
+// matchGlob matches a slash-separated path against a pattern in which **
+// matches any number of directories, * and ? match within one path
+// segment, and {a,b} matches either alternative
+func matchGlob(pattern, name string) bool {
+	for _, p := range expandBraces(pattern) {
+		if matchSegments(strings.Split(p, "/"), strings.Split(name, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse repeated ** and try every split point
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// expandBraces expands {a,b} alternatives, including nested ones
+func expandBraces(pattern string) []string {
+	start := strings.IndexByte(pattern, '{')
+	if start < 0 {
+		return []string{pattern}
+	}
+	depth := 0
+	var alternatives []string
+	last := start + 1
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				alternatives = append(alternatives, pattern[last:i])
+				var out []string
+				for _, alt := range alternatives {
+					out = append(out, expandBraces(pattern[:start]+alt+pattern[i+1:])...)
+				}
+				return out
+			}
+		case ',':
+			if depth == 1 {
+				alternatives = append(alternatives, pattern[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return []string{pattern} // Unbalanced braces are literal
+}
+
+// globBase returns the directory before the first segment of pattern with
+// wildcards, where the walk can start
+func globBase(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, s := range segments {
+		if strings.ContainsAny(s, "*?[{") {
+			if i == 0 {
+				return "."
+			}
+			base := strings.Join(segments[:i], "/")
+			if base == "" {
+				return "/"
+			}
+			return base
+		}
+	}
+	return path.Dir(pattern)
+}
+
+// globFiles returns the files matching pattern (an absolute slash path),
+// skipping ignored files and directories unless includeIgnored is set. It
+// stops after limit matches, reporting whether more were left.
+func globFiles(rootDir, pattern string, includeIgnored bool, limit int) (matches []string, truncated bool, err error) {
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return nil, false, err
+	}
+
+	start := filepath.FromSlash(globBase(pattern))
+	ignore := newIgnoreMatcher()
+	if !includeIgnored {
+		ignore.loadParents(rootDir, start)
+	}
+
+	err = filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != start && !includeIgnored {
+				if ignore.ignored(p, true) {
+					return filepath.SkipDir
+				}
+				ignore.load(p)
+			}
+			return nil
+		}
+		if !matchGlob(pattern, filepath.ToSlash(p)) {
+			return nil
+		}
+		if !includeIgnored && ignore.ignored(p, false) {
+			return nil
+		}
+		if len(matches) == limit {
+			truncated = true
+			return errGlobLimit
+		}
+		matches = append(matches, p)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errGlobLimit) {
+		return nil, false, err
+	}
+	return matches, truncated, nil
+}
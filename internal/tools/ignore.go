@@ -0,0 +1,179 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFiles are read in every directory, in this order. .gmnignore holds
+// rules for gmn only, such as generated files that are checked in.
+var ignoreFiles = []string{".gitignore", ".gmnignore"}
+
+// alwaysIgnoredDirs are skipped even without ignore files
+var alwaysIgnoredDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+}
+
+// ignoreRule is one line of an ignore file
+type ignoreRule struct {
+	base    string // Slash path of the directory holding the ignore file
+	pattern string
+	negate  bool
+	dirOnly bool
+	rooted  bool // Pattern contains a slash, so it matches relative to base
+}
+
+// ignoreMatcher applies .gitignore and .gmnignore rules while walking a
+// tree. Rules are loaded lazily per directory.
+type ignoreMatcher struct {
+	rules map[string][]ignoreRule // By directory (slash path)
+}
+
+func newIgnoreMatcher() *ignoreMatcher {
+	return &ignoreMatcher{rules: make(map[string][]ignoreRule)}
+}
+
+// load reads the ignore files of dir if not done yet
+func (m *ignoreMatcher) load(dir string) {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	if _, ok := m.rules[dir]; ok {
+		return
+	}
+	var rules []ignoreRule
+	files := ignoreFiles
+	if _, err := os.Stat(filepath.Join(filepath.FromSlash(dir), ".git")); err == nil {
+		files = append([]string{".git/info/exclude"}, files...)
+	}
+	for _, name := range files {
+		rules = append(rules, readIgnoreFile(dir, filepath.Join(filepath.FromSlash(dir), name))...)
+	}
+	m.rules[dir] = rules
+}
+
+// loadParents reads the ignore files of root and every directory between
+// it and dir, so rules from above the walk start apply
+func (m *ignoreMatcher) loadParents(root, dir string) {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		m.load(dir)
+		return
+	}
+	m.load(root)
+	current := root
+	if rel != "." {
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			current = filepath.Join(current, part)
+			m.load(current)
+		}
+	}
+}
+
+func readIgnoreFile(base, file string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`) // Escaped leading # or !
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.rooted = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ignored reports whether the entry at p is ignored. The rules of all
+// directories above p must have been loaded; the last matching rule wins.
+func (m *ignoreMatcher) ignored(p string, isDir bool) bool {
+	p = filepath.ToSlash(filepath.Clean(p))
+	if isDir && alwaysIgnoredDirs[path.Base(p)] {
+		return true
+	}
+
+	// Collect the directories above p, then apply their rules from the
+	// top down so deeper ignore files take precedence
+	var dirs []string
+	for dir := path.Dir(p); ; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+		if path.Dir(dir) == dir {
+			break
+		}
+	}
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		for _, r := range m.rules[dirs[i]] {
+			if r.matches(p, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) matches(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel := strings.TrimPrefix(p, strings.TrimSuffix(r.base, "/")+"/")
+	if rel == p && r.base != "/" {
+		return false // Not below the ignore file
+	}
+	if r.rooted {
+		return matchGlob(r.pattern, rel)
+	}
+	return matchGlob(r.pattern, path.Base(p))
+}
+
+// IgnoreFilter tells which paths below a root the file tools skip: those
+// ignored by the .gitignore and .gmnignore files from the root down, .git
+// and node_modules
+type IgnoreFilter struct {
+	root    string
+	matcher *ignoreMatcher
+}
+
+// NewIgnoreFilter returns a filter for the paths below root
+func NewIgnoreFilter(root string) *IgnoreFilter {
+	root, _ = filepath.Abs(root)
+	return &IgnoreFilter{root: root, matcher: newIgnoreMatcher()}
+}
+
+// Ignored reports whether the file or directory at p is skipped
+func (f *IgnoreFilter) Ignored(p string, isDir bool) bool {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return false
+	}
+	f.matcher.loadParents(f.root, filepath.Dir(p))
+	return f.matcher.ignored(p, isDir)
+}
@@ -0,0 +1,241 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/linkalls/gmn/internal/api"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register the WebP decoder
+)
+
+const (
+	// KeyInlineImage holds an *InlineImage in a tool result. It is sent to
+	// the model as inline data next to the function response.
+	KeyInlineImage = "inline_image"
+
+	// maxImageFileBytes is the largest image read_file and web_fetch load
+	maxImageFileBytes = 20 << 20
+	// maxImageSide is the longest side images are downscaled to
+	maxImageSide = 2048
+	// maxInlineImageBytes is the largest encoded image sent to the model;
+	// larger ones are re-encoded as JPEG
+	maxInlineImageBytes = 3 << 20
+	// maxImagePixels is the most pixels an image may declare; a small file
+	// can claim huge dimensions, and decoding allocates all of them
+	maxImagePixels = 50_000_000
+)
+
+// InlineImage is an image returned to the model. It marshals to a short
+// description so results stay readable when displayed or logged.
+type InlineImage struct {
+	MimeType string
+	Data     []byte
+}
+
+// MarshalJSON describes the image instead of encoding its data
+func (img *InlineImage) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%q", fmt.Sprintf("<%s, %s>", img.MimeType, formatBytes(int64(len(img.Data)))))), nil
+}
+
+// isImageType reports whether a MIME type is an image the tools can return
+func isImageType(mimeType string) bool {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	switch strings.TrimSpace(strings.ToLower(mimeType)) {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// readImage returns the result for an image file, or nil if fullPath is
+// not an image
+func readImage(fullPath string) map[string]interface{} {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	if !isImageType(http.DetectContentType(head[:n])) {
+		return nil
+	}
+	if info.Size() > maxImageFileBytes {
+		return map[string]interface{}{
+			"error": fmt.Sprintf("image is too large (%s, limit %s)",
+				formatBytes(info.Size()), formatBytes(maxImageFileBytes)),
+			"path": fullPath,
+		}
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to read file: %v", err)}
+	}
+	return imageResult(data, fullPath)
+}
+
+// imageResult decodes an image, downscales it to the size limits and
+// returns a tool result that carries it to the model. Images that declare
+// more than maxImagePixels are refused before they are decoded. source is the path
+// or URL shown in the UI.
+func imageResult(data []byte, source string) map[string]interface{} {
+	if len(data) > maxImageFileBytes {
+		return map[string]interface{}{
+			"error": fmt.Sprintf("image is too large (%s, limit %s)",
+				formatBytes(int64(len(data))), formatBytes(maxImageFileBytes)),
+			"path": source,
+		}
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to decode image: %v", err), "path": source}
+	}
+	if pixels := int64(config.Width) * int64(config.Height); pixels > maxImagePixels {
+		return map[string]interface{}{
+			"error": fmt.Sprintf("image is too large (%d×%d pixels, limit %d megapixels)",
+				config.Width, config.Height, maxImagePixels/1_000_000),
+			"path": source,
+		}
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to decode image: %v", err), "path": source}
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	inline := &InlineImage{MimeType: "image/" + format, Data: data}
+	scaled := false
+	if width > maxImageSide || height > maxImageSide {
+		img = downscale(img, maxImageSide)
+		scaled = true
+	}
+	// GIFs are re-encoded since the model does not accept them
+	if scaled || format == "gif" || len(data) > maxInlineImageBytes {
+		if inline, err = encodeImage(img); err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("failed to encode image: %v", err), "path": source}
+		}
+	}
+
+	size := img.Bounds()
+	summary := fmt.Sprintf("%s image, %d×%d", format, width, height)
+	if scaled {
+		summary += fmt.Sprintf(" (sent as %d×%d)", size.Dx(), size.Dy())
+	}
+	return map[string]interface{}{
+		"path":         source,
+		"format":       format,
+		"width":        width,
+		"height":       height,
+		"size":         len(data),
+		"note":         "the image is attached to this response",
+		"media_type":   MediaImage,
+		"summary":      summary,
+		KeyInlineImage: inline,
+	}
+}
+
+// NewInlineImage prepares an image the user attaches (e.g. from the
+// clipboard) for the model, downscaled like images the tools return. It
+// also returns a short description such as "png image, 800×600".
+func NewInlineImage(data []byte, source string) (*InlineImage, string, error) {
+	result := imageResult(data, source)
+	if msg, ok := result["error"].(string); ok {
+		return nil, "", errors.New(msg)
+	}
+	return result[KeyInlineImage].(*InlineImage), result["summary"].(string), nil
+}
+
+// Part returns the image as inline data for a message
+func (img *InlineImage) Part() api.Part {
+	return api.Part{InlineData: &api.Blob{MimeType: img.MimeType, Data: base64.StdEncoding.EncodeToString(img.Data)}}
+}
+
+// downscale resizes img so its longest side is maxSide pixels
+func downscale(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w >= h {
+		h = max(1, h*maxSide/w)
+		w = maxSide
+	} else {
+		w = max(1, w*maxSide/h)
+		h = maxSide
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
+
+// encodeImage encodes img as PNG, or as JPEG if the PNG is over the inline
+// size limit
+func encodeImage(img image.Image) (*InlineImage, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	if buf.Len() <= maxInlineImageBytes {
+		return &InlineImage{MimeType: "image/png", Data: buf.Bytes()}, nil
+	}
+
+	// JPEG has no alpha channel; flatten onto white
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	for _, quality := range []int{85, 70, 50} {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		if buf.Len() <= maxInlineImageBytes {
+			break
+		}
+	}
+	if buf.Len() > maxInlineImageBytes {
+		return nil, fmt.Errorf("image is still %s after compression (limit %s)",
+			formatBytes(int64(buf.Len())), formatBytes(maxInlineImageBytes))
+	}
+	return &InlineImage{MimeType: "image/jpeg", Data: buf.Bytes()}, nil
+}
+
+// ResponseParts returns the parts that carry a tool result back to the
+// model: the function response, followed by any image the tool returned as
+// inline data
+func ResponseParts(id, name string, result map[string]interface{}) []api.Part {
+	img, ok := result[KeyInlineImage].(*InlineImage)
+	if !ok {
+		return []api.Part{{FunctionResp: &api.FunctionResp{ID: id, Name: name, Response: result}}}
+	}
+
+	response := make(map[string]interface{}, len(result))
+	for k, v := range result {
+		if k != KeyInlineImage {
+			response[k] = v
+		}
+	}
+	return []api.Part{
+		{FunctionResp: &api.FunctionResp{ID: id, Name: name, Response: response}},
+		img.Part(),
+	}
+}
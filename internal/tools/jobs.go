@@ -0,0 +1,381 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxJobOutput caps the output kept per background job; older output is
+// dropped first
+const maxJobOutput = 1 << 20
+
+// Job is a shell command running in the background
+type Job struct {
+	ID      int
+	Command string
+	PID     int
+	Started time.Time
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	output   []byte
+	dropped  int // Bytes dropped from the start of output
+	read     int // Output offset (including dropped) read by check_job_output
+	done     bool
+	exitCode int
+	ended    time.Time
+}
+
+// Write appends command output, keeping the last maxJobOutput bytes
+func (j *Job) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.output = append(j.output, p...)
+	if over := len(j.output) - maxJobOutput; over > 0 {
+		j.output = append(j.output[:0:0], j.output[over:]...)
+		j.dropped += over
+	}
+	return len(p), nil
+}
+
+// JobStatus is a snapshot of a job's state
+type JobStatus struct {
+	ID       int
+	Command  string
+	PID      int
+	Running  bool
+	ExitCode int
+	Duration time.Duration
+}
+
+// Status returns a snapshot of the job's state
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := JobStatus{ID: j.ID, Command: j.Command, PID: j.PID, Running: !j.done, ExitCode: j.exitCode}
+	if j.done {
+		s.Duration = j.ended.Sub(j.Started)
+	} else {
+		s.Duration = time.Since(j.Started)
+	}
+	return s
+}
+
+// Output returns the output since the last call (all kept output if all is
+// set) and marks it as read. skipped is how many unread bytes were dropped
+// because the buffer was full.
+func (j *Job) Output(all bool) (out string, skipped int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	start := j.read - j.dropped
+	if all || start < 0 {
+		if !all {
+			skipped = -start
+		}
+		start = 0
+	}
+	j.read = j.dropped + len(j.output)
+	return string(j.output[start:]), skipped
+}
+
+// Jobs tracks the background commands of a session. It is safe for
+// concurrent use.
+type Jobs struct {
+	mu     sync.Mutex
+	jobs   map[int]*Job
+	nextID int
+}
+
+// NewJobs creates an empty job table
+func NewJobs() *Jobs {
+	return &Jobs{jobs: make(map[int]*Job), nextID: 1}
+}
+
+// Start runs cmd in the background, capturing stdout and stderr together
+func (js *Jobs) Start(command string, cmd *exec.Cmd) (*Job, error) {
+	job := &Job{Command: command, cmd: cmd}
+	cmd.Stdout = job
+	cmd.Stderr = job
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	job.PID = cmd.Process.Pid
+	job.Started = time.Now()
+
+	js.mu.Lock()
+	job.ID = js.nextID
+	js.nextID++
+	js.jobs[job.ID] = job
+	js.mu.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		job.done = true
+		job.ended = time.Now()
+		job.exitCode = 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			job.exitCode = exitErr.ExitCode()
+		} else if err != nil {
+			job.exitCode = -1
+		}
+	}()
+	return job, nil
+}
+
+// Get returns a job by ID
+func (js *Jobs) Get(id int) (*Job, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	job, ok := js.jobs[id]
+	return job, ok
+}
+
+// List returns the status of every job, oldest first
+func (js *Jobs) List() []JobStatus {
+	js.mu.Lock()
+	jobs := make([]*Job, 0, len(js.jobs))
+	for _, job := range js.jobs {
+		jobs = append(jobs, job)
+	}
+	js.mu.Unlock()
+
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].ID < jobs[k].ID })
+	list := make([]JobStatus, len(jobs))
+	for i, job := range jobs {
+		list[i] = job.Status()
+	}
+	return list
+}
+
+// Kill stops a job and the processes it started
+func (js *Jobs) Kill(id int) error {
+	job, ok := js.Get(id)
+	if !ok {
+		return fmt.Errorf("no job with ID %d", id)
+	}
+	job.mu.Lock()
+	done := job.done
+	job.mu.Unlock()
+	if done {
+		return nil
+	}
+	return killProcessGroup(job.cmd)
+}
+
+// KillAll stops every running job, e.g. when gmn exits
+func (js *Jobs) KillAll() {
+	for _, s := range js.List() {
+		if s.Running {
+			js.Kill(s.ID)
+		}
+	}
+}
+
+// JobsCommandUsage describes the /jobs command
+const JobsCommandUsage = "Usage: /jobs [kill <id>]"
+
+// RunCommand runs a /jobs subcommand (list or kill) and returns the text to
+// show the user
+func (js *Jobs) RunCommand(args string) (string, error) {
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		list := js.List()
+		if len(list) == 0 {
+			return "No background jobs", nil
+		}
+		var b strings.Builder
+		for i, s := range list {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			state := "running"
+			if !s.Running {
+				state = fmt.Sprintf("exit %d", s.ExitCode)
+			}
+			fmt.Fprintf(&b, "#%d  %-8s %6s  %s", s.ID, state, s.Duration.Round(time.Second), s.Command)
+		}
+		return b.String(), nil
+
+	case len(fields) == 2 && fields[0] == "kill":
+		id, err := strconv.Atoi(strings.TrimPrefix(fields[1], "#"))
+		if err != nil {
+			return "", errors.New(JobsCommandUsage)
+		}
+		if err := js.Kill(id); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Stopped job %d", id), nil
+	}
+	return "", errors.New(JobsCommandUsage)
+}
+
+// jobResult describes a job for the model
+func jobResult(s JobStatus) map[string]interface{} {
+	result := map[string]interface{}{
+		"job_id":      s.ID,
+		"command":     s.Command,
+		"pid":         s.PID,
+		"running":     s.Running,
+		"duration_ms": s.Duration.Milliseconds(),
+	}
+	if !s.Running {
+		result["exit_code"] = s.ExitCode
+	}
+	return result
+}
+
+// jobArg returns the job of the job_id argument
+func jobArg(jobs *Jobs, args map[string]interface{}) (*Job, error) {
+	id, ok := intArg(args, "job_id")
+	if !ok {
+		return nil, fmt.Errorf("job_id is required")
+	}
+	job, ok := jobs.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("no job with ID %d", id)
+	}
+	return job, nil
+}
+
+// =============================================================================
+// CheckJobOutputTool - Read output of background commands
+// =============================================================================
+
+// CheckJobOutputTool returns the status and new output of a background job,
+// or lists all jobs
+type CheckJobOutputTool struct {
+	jobs *Jobs
+}
+
+func (t *CheckJobOutputTool) Name() string        { return "check_job_output" }
+func (t *CheckJobOutputTool) DisplayName() string { return "CheckJobOutput" }
+func (t *CheckJobOutputTool) Description() string {
+	return "Check a background job started with shell's background option: whether it is still running, its exit code, and the output written since the last check. Without job_id, lists all background jobs."
+}
+
+func (t *CheckJobOutputTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"job_id": {
+				"type": "integer",
+				"description": "ID returned by shell when the job was started. Omit to list all jobs."
+			},
+			"all": {
+				"type": "boolean",
+				"description": "Return all kept output (up to the last 1 MB) instead of only new output"
+			}
+		}
+	}`)
+}
+
+func (t *CheckJobOutputTool) RequiresConfirmation() bool { return false }
+func (t *CheckJobOutputTool) ConfirmationType() string   { return "" }
+
+func (t *CheckJobOutputTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := args["job_id"]; !ok {
+		list := t.jobs.List()
+		rows := make([]map[string]interface{}, len(list))
+		for i, s := range list {
+			rows[i] = jobResult(s)
+		}
+		return map[string]interface{}{
+			"jobs":       rows,
+			"count":      len(rows),
+			KeyMediaType: MediaTable,
+			KeyBody:      "jobs",
+			KeyColumns:   []string{"job_id", "running", "exit_code", "command"},
+			KeySummary:   fmt.Sprintf("%d job(s)", len(rows)),
+		}, nil
+	}
+
+	job, err := jobArg(t.jobs, args)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	all, _ := args["all"].(bool)
+	out, skipped := job.Output(all)
+	const maxOutput = 50000
+	if len(out) > maxOutput {
+		skipped += len(out) - maxOutput
+		out = out[len(out)-maxOutput:]
+	}
+
+	s := job.Status()
+	result := jobResult(s)
+	result["output"] = out
+	if skipped > 0 {
+		result["notice"] = fmt.Sprintf("%d earlier bytes of output were skipped", skipped)
+	}
+	result[KeyMediaType] = MediaText
+	result[KeyBody] = "output"
+	if s.Running {
+		result[KeySummary] = fmt.Sprintf("job %d running, %d new bytes", s.ID, len(out))
+	} else {
+		result[KeySummary] = fmt.Sprintf("job %d exited %d", s.ID, s.ExitCode)
+	}
+	return result, nil
+}
+
+// =============================================================================
+// KillJobTool - Stop background commands
+// =============================================================================
+
+// KillJobTool stops a background job
+type KillJobTool struct {
+	jobs *Jobs
+}
+
+func (t *KillJobTool) Name() string        { return "kill_job" }
+func (t *KillJobTool) DisplayName() string { return "KillJob" }
+func (t *KillJobTool) Description() string {
+	return "Stop a background job started with shell's background option, including the processes it started (e.g. a dev server)."
+}
+
+func (t *KillJobTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"job_id": {
+				"type": "integer",
+				"description": "ID returned by shell when the job was started"
+			}
+		},
+		"required": ["job_id"]
+	}`)
+}
+
+func (t *KillJobTool) RequiresConfirmation() bool { return false }
+func (t *KillJobTool) ConfirmationType() string   { return "" }
+
+func (t *KillJobTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	job, err := jobArg(t.jobs, args)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	if err := t.jobs.Kill(job.ID); err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to kill job %d: %v", job.ID, err)}, nil
+	}
+
+	// Give the process a moment to exit so the status is accurate
+	for i := 0; i < 20 && job.Status().Running; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	result := jobResult(job.Status())
+	result["message"] = fmt.Sprintf("Stopped job %d (%s)", job.ID, strings.TrimSpace(job.Command))
+	return result, nil
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+// Process handling for background jobs on Windows
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on Windows; taskkill /T finds the children
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command and its child processes
+func killProcessGroup(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
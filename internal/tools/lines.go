@@ -0,0 +1,256 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// =============================================================================
+// InsertAtLineTool - Insert lines at a line number
+// =============================================================================
+
+// InsertAtLineTool inserts content before a given line of a file
+type InsertAtLineTool struct {
+	rootDir  string
+	settings *Settings
+}
+
+func (t *InsertAtLineTool) Name() string        { return "insert_at_line" }
+func (t *InsertAtLineTool) DisplayName() string { return "InsertLines" }
+func (t *InsertAtLineTool) Description() string {
+	return "Insert content into a file before the given 1-based line number, using line numbers from read_file. Use a line one past the last line to append. Cheaper than edit_file when the exact position is known."
+}
+
+func (t *InsertAtLineTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"path": {
+				"type": "string",
+				"description": "The path of the file to edit"
+			},
+			"line": {
+				"type": "integer",
+				"description": "1-based line number the content is inserted before"
+			},
+			"content": {
+				"type": "string",
+				"description": "The lines to insert"
+			}
+		},
+		"required": ["path", "line", "content"]
+	}`)
+}
+
+func (t *InsertAtLineTool) RequiresConfirmation() bool { return true }
+func (t *InsertAtLineTool) ConfirmationType() string   { return "edit" }
+
+func (t *InsertAtLineTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	path, fullPath, original, updated, err := t.apply(args)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	if err := os.WriteFile(fullPath, []byte(updated), 0644); err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to write file: %v", err)}, nil
+	}
+	return diffResult(map[string]interface{}{
+		"success": true,
+		"path":    fullPath,
+		"message": "Successfully inserted lines",
+	}, filepath.ToSlash(path), original, updated), nil
+}
+
+// apply computes the file content after the insertion
+func (t *InsertAtLineTool) apply(args map[string]interface{}) (path, fullPath, original, updated string, err error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return "", "", "", "", fmt.Errorf("path is required and must be a string")
+	}
+	line, ok := intArg(args, "line")
+	if !ok {
+		return "", "", "", "", fmt.Errorf("line is required and must be an integer")
+	}
+	content, ok := args["content"].(string)
+	if !ok {
+		return "", "", "", "", fmt.Errorf("content is required and must be a string")
+	}
+
+	fullPath, err = t.resolvePath(path)
+	if err != nil {
+		return "", "", "", "", err
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", "", "", "", fmt.Errorf("failed to read file: %v", err)
+	}
+	original = string(data)
+	lines := splitLines(original)
+	if line < 1 || line > len(lines)+1 {
+		return "", "", "", "", fmt.Errorf("line %d is out of range (file has %d lines)", line, len(lines))
+	}
+
+	// The inserted block must end with a newline unless it ends the file,
+	// and the line before it must too
+	if content != "" && !strings.HasSuffix(content, "\n") && line <= len(lines) {
+		content += "\n"
+	}
+	if line == len(lines)+1 && line > 1 && !strings.HasSuffix(lines[line-2], "\n") {
+		lines[line-2] += "\n"
+	}
+
+	var b strings.Builder
+	for _, l := range lines[:line-1] {
+		b.WriteString(l)
+	}
+	b.WriteString(content)
+	for _, l := range lines[line-1:] {
+		b.WriteString(l)
+	}
+	return path, fullPath, original, b.String(), nil
+}
+
+func (t *InsertAtLineTool) resolvePath(path string) (string, error) {
+	return t.settings.resolveWritablePath(t.rootDir, path)
+}
+
+// GetOriginalContent returns the current content of a file (for diff display)
+func (t *InsertAtLineTool) GetOriginalContent(args map[string]interface{}) (string, error) {
+	_, _, original, _, err := t.apply(args)
+	return original, err
+}
+
+// GetNewContent returns the content after the insertion (for diff display)
+func (t *InsertAtLineTool) GetNewContent(args map[string]interface{}) (string, error) {
+	_, _, _, updated, err := t.apply(args)
+	return updated, err
+}
+
+// =============================================================================
+// DeleteLinesTool - Delete a range of lines
+// =============================================================================
+
+// DeleteLinesTool deletes a range of lines from a file
+type DeleteLinesTool struct {
+	rootDir  string
+	settings *Settings
+}
+
+func (t *DeleteLinesTool) Name() string        { return "delete_lines" }
+func (t *DeleteLinesTool) DisplayName() string { return "DeleteLines" }
+func (t *DeleteLinesTool) Description() string {
+	return "Delete lines start_line through end_line (1-based, inclusive) from a file, using line numbers from read_file. Cheaper than edit_file when the exact lines are known."
+}
+
+func (t *DeleteLinesTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"path": {
+				"type": "string",
+				"description": "The path of the file to edit"
+			},
+			"start_line": {
+				"type": "integer",
+				"description": "First line to delete (1-based)"
+			},
+			"end_line": {
+				"type": "integer",
+				"description": "Last line to delete, inclusive (default: start_line)"
+			}
+		},
+		"required": ["path", "start_line"]
+	}`)
+}
+
+func (t *DeleteLinesTool) RequiresConfirmation() bool { return true }
+func (t *DeleteLinesTool) ConfirmationType() string   { return "edit" }
+
+func (t *DeleteLinesTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	path, fullPath, original, updated, err := t.apply(args)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	if err := os.WriteFile(fullPath, []byte(updated), 0644); err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to write file: %v", err)}, nil
+	}
+	return diffResult(map[string]interface{}{
+		"success": true,
+		"path":    fullPath,
+		"message": "Successfully deleted lines",
+	}, filepath.ToSlash(path), original, updated), nil
+}
+
+// apply computes the file content after the deletion
+func (t *DeleteLinesTool) apply(args map[string]interface{}) (path, fullPath, original, updated string, err error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return "", "", "", "", fmt.Errorf("path is required and must be a string")
+	}
+	start, ok := intArg(args, "start_line")
+	if !ok {
+		return "", "", "", "", fmt.Errorf("start_line is required and must be an integer")
+	}
+	end, ok := intArg(args, "end_line")
+	if !ok {
+		end = start
+	}
+
+	fullPath, err = t.resolvePath(path)
+	if err != nil {
+		return "", "", "", "", err
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", "", "", "", fmt.Errorf("failed to read file: %v", err)
+	}
+	original = string(data)
+	lines := splitLines(original)
+	if start < 1 || end < start || end > len(lines) {
+		return "", "", "", "", fmt.Errorf("lines %d-%d are out of range (file has %d lines)", start, end, len(lines))
+	}
+
+	updated = strings.Join(lines[:start-1], "") + strings.Join(lines[end:], "")
+	return path, fullPath, original, updated, nil
+}
+
+func (t *DeleteLinesTool) resolvePath(path string) (string, error) {
+	return t.settings.resolveWritablePath(t.rootDir, path)
+}
+
+// GetOriginalContent returns the current content of a file (for diff display)
+func (t *DeleteLinesTool) GetOriginalContent(args map[string]interface{}) (string, error) {
+	_, _, original, _, err := t.apply(args)
+	return original, err
+}
+
+// GetNewContent returns the content after the deletion (for diff display)
+func (t *DeleteLinesTool) GetNewContent(args map[string]interface{}) (string, error) {
+	_, _, _, updated, err := t.apply(args)
+	return updated, err
+}
+
+// splitLines splits s into lines, each keeping its trailing newline
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// intArg returns an integer argument, which arrives as float64 from JSON
+func intArg(args map[string]interface{}, key string) (int, bool) {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v), v == float64(int(v))
+	case int:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,178 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
+	"github.com/linkalls/gmn/internal/mcp"
+)
+
+const (
+	// mcpNameSep separates the server and tool parts of MCP tool names
+	mcpNameSep = "__"
+	// maxToolNameLen is the longest function name the API accepts
+	maxToolNameLen = 64
+	// defaultMCPTimeout applies to MCP tool calls without a configured timeout
+	defaultMCPTimeout = 10 * time.Minute
+)
+
+// MCPPolicy is the confirmation policy of an MCP tool
+type MCPPolicy int
+
+const (
+	MCPPolicyDefault MCPPolicy = iota // Ask unless allowed in settings or for the session
+	MCPPolicyTrusted                  // Never ask
+	MCPPolicyConfirm                  // Always ask, even if allowed
+)
+
+// MCPCaller calls tools on an MCP server; *mcp.Client implements it
+type MCPCaller interface {
+	CallTool(ctx context.Context, name string, args map[string]interface{}) (string, error)
+}
+
+// =============================================================================
+// MCPTool - A tool provided by an MCP server
+// =============================================================================
+
+// MCPTool exposes a tool of an MCP server in the registry under a name
+// namespaced by its server (server__tool), so it cannot shadow a built-in
+type MCPTool struct {
+	server  string
+	tool    mcp.Tool
+	client  MCPCaller
+	policy  MCPPolicy
+	timeout time.Duration
+}
+
+func (t *MCPTool) Name() string        { return MCPToolName(t.server, t.tool.Name) }
+func (t *MCPTool) DisplayName() string { return t.server + "/" + t.tool.Name }
+func (t *MCPTool) Description() string {
+	return fmt.Sprintf("[MCP server %s] %s", t.server, t.tool.Description)
+}
+
+func (t *MCPTool) Parameters() json.RawMessage {
+	if len(t.tool.InputSchema) == 0 {
+		return json.RawMessage(`{"type": "object", "properties": {}}`)
+	}
+	return t.tool.InputSchema
+}
+
+func (t *MCPTool) RequiresConfirmation() bool { return t.policy != MCPPolicyTrusted }
+func (t *MCPTool) ConfirmationType() string   { return "mcp" }
+
+// AlwaysConfirm reports whether the tool must be confirmed even if it is
+// in the allow list
+func (t *MCPTool) AlwaysConfirm() bool { return t.policy == MCPPolicyConfirm }
+
+func (t *MCPTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext calls the tool on its server, giving up when ctx is
+// cancelled or the server's timeout passes
+func (t *MCPTool) ExecuteContext(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	output, err := t.client.CallTool(ctx, t.tool.Name, args)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	return map[string]interface{}{"output": output}, nil
+}
+
+// MCPToolName returns the registry name of an MCP tool. Characters the API
+// does not accept in function names are replaced with underscores. Names
+// too long for the API are cut and end in a hash of the full name, so
+// tools that only differ after the cut keep different names.
+func MCPToolName(server, tool string) string {
+	name := sanitizeToolName(server) + mcpNameSep + sanitizeToolName(tool)
+	if len(name) > maxToolNameLen {
+		sum := sha256.Sum256([]byte(server + "\x00" + tool))
+		suffix := "_" + hex.EncodeToString(sum[:4])
+		name = name[:maxToolNameLen-len(suffix)] + suffix
+	}
+	return name
+}
+
+func sanitizeToolName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 128 && (isWordByte(byte(r)) || r == '-' || r == '.') {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// MCPToolPolicy returns the confirmation policy of a tool from its server's
+// settings. confirmTools wins over trust and trustedTools.
+func MCPToolPolicy(cfg config.MCPServerConfig, tool string) MCPPolicy {
+	switch {
+	case slices.Contains(cfg.ConfirmTools, tool) || slices.Contains(cfg.ConfirmTools, "*"):
+		return MCPPolicyConfirm
+	case cfg.Trust || slices.Contains(cfg.TrustedTools, tool):
+		return MCPPolicyTrusted
+	}
+	return MCPPolicyDefault
+}
+
+// MCPToolEnabled reports whether a tool passes its server's includeTools
+// and excludeTools settings
+func MCPToolEnabled(cfg config.MCPServerConfig, tool string) bool {
+	if len(cfg.IncludeTools) > 0 && !slices.Contains(cfg.IncludeTools, tool) {
+		return false
+	}
+	return !slices.Contains(cfg.ExcludeTools, tool)
+}
+
+// RegisterMCP registers the enabled tools of an MCP server, returning their
+// registry names. Tools whose names are already taken, by a built-in or by
+// another server's tool with the same name once sanitized, are left out and
+// reported in the error.
+func (r *Registry) RegisterMCP(server string, cfg config.MCPServerConfig, client MCPCaller, mcpTools []mcp.Tool) ([]string, error) {
+	timeout := defaultMCPTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Millisecond
+	}
+
+	var names, clashes []string
+	for _, tool := range mcpTools {
+		if !MCPToolEnabled(cfg, tool.Name) {
+			continue
+		}
+		t := &MCPTool{
+			server:  server,
+			tool:    tool,
+			client:  client,
+			policy:  MCPToolPolicy(cfg, tool.Name),
+			timeout: timeout,
+		}
+		if _, taken := r.Get(t.Name()); taken {
+			clashes = append(clashes, fmt.Sprintf("%s (%s)", tool.Name, t.Name()))
+			continue
+		}
+		r.Register(t)
+		names = append(names, t.Name())
+	}
+	if len(clashes) > 0 {
+		return names, fmt.Errorf("%s: tools left out because their names are taken: %s", server, strings.Join(clashes, ", "))
+	}
+	return names, nil
+}
+
+// AlwaysConfirm reports whether a tool must be confirmed even if it is in
+// the allow list
+func AlwaysConfirm(t BuiltinTool) bool {
+	c, ok := t.(interface{ AlwaysConfirm() bool })
+	return ok && c.AlwaysConfirm()
+}
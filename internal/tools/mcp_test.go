@@ -0,0 +1,54 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMCPToolName(t *testing.T) {
+	long := strings.Repeat("a", 80)
+	tests := []struct {
+		server, tool string
+		want         string // "" to only check the length
+	}{
+		{"github", "create_issue", "github__create_issue"},
+		{"my server", "tool/name", "my_server__tool_name"},
+		{"srv", "résumé", "srv__r_sum_"},
+		{"srv", "a-b.c", "srv__a-b.c"},
+		{"srv", long, ""},
+		{long, "tool", ""},
+	}
+	for _, tt := range tests {
+		got := MCPToolName(tt.server, tt.tool)
+		if tt.want != "" && got != tt.want {
+			t.Errorf("MCPToolName(%q, %q) = %q, want %q", tt.server, tt.tool, got, tt.want)
+		}
+		if len(got) > maxToolNameLen {
+			t.Errorf("MCPToolName(%q, %q) = %q, longer than %d", tt.server, tt.tool, got, maxToolNameLen)
+		}
+	}
+}
+
+func TestMCPToolNameDistinct(t *testing.T) {
+	prefix := strings.Repeat("x", 70)
+	pairs := [][2]string{
+		{"srv", prefix + "_one"},
+		{"srv", prefix + "_two"},
+		{"srv_a", prefix},
+		{"srv", "a_" + prefix},
+	}
+	seen := make(map[string][2]string)
+	for _, pair := range pairs {
+		name := MCPToolName(pair[0], pair[1])
+		if other, ok := seen[name]; ok {
+			t.Errorf("MCPToolName(%q, %q) and MCPToolName(%q, %q) are both %q", pair[0], pair[1], other[0], other[1], name)
+		}
+		seen[name] = pair
+		if again := MCPToolName(pair[0], pair[1]); again != name {
+			t.Errorf("MCPToolName(%q, %q) is not stable: %q, then %q", pair[0], pair[1], name, again)
+		}
+	}
+}
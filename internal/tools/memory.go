@@ -0,0 +1,87 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/linkalls/gmn/internal/memory"
+)
+
+// =============================================================================
+// SaveMemoryTool - Remember facts across sessions
+// =============================================================================
+
+// SaveMemoryTool adds a fact to the global or project memory file, which is
+// sent with every later request
+type SaveMemoryTool struct {
+	memory *memory.Store
+}
+
+func (t *SaveMemoryTool) Name() string        { return "save_memory" }
+func (t *SaveMemoryTool) DisplayName() string { return "SaveMemory" }
+func (t *SaveMemoryTool) Description() string {
+	return "Save a short fact to long-term memory so it is remembered in future sessions. Use it when the user asks you to remember something or states a lasting preference (e.g. 'I prefer tabs'). Do not save conversation details or temporary information. Global facts apply everywhere; project facts go to GEMINI.md in the working directory."
+}
+
+func (t *SaveMemoryTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"fact": {
+				"type": "string",
+				"description": "The fact to remember, as one short self-contained sentence"
+			},
+			"scope": {
+				"type": "string",
+				"enum": ["global", "project"],
+				"description": "Where to save it: global (all projects, default) or project (this project's GEMINI.md)"
+			}
+		},
+		"required": ["fact"]
+	}`)
+}
+
+func (t *SaveMemoryTool) RequiresConfirmation() bool { return true }
+func (t *SaveMemoryTool) ConfirmationType() string   { return "edit" }
+
+func (t *SaveMemoryTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	fact, _ := args["fact"].(string)
+	scope, _ := args["scope"].(string)
+	_, original, updated, err := t.memory.Plan(scope, fact)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	path, err := t.memory.Add(scope, fact)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to save memory: %v", err)}, nil
+	}
+	result := diffResult(map[string]interface{}{
+		"success": true,
+		"path":    path,
+		"message": "Saved to memory",
+	}, filepath.Base(path), original, updated)
+	result[KeySummary] = "Saved to " + path
+	return result, nil
+}
+
+// GetOriginalContent returns the memory file before the fact is added (for
+// diff display)
+func (t *SaveMemoryTool) GetOriginalContent(args map[string]interface{}) (string, error) {
+	fact, _ := args["fact"].(string)
+	scope, _ := args["scope"].(string)
+	_, original, _, err := t.memory.Plan(scope, fact)
+	return original, err
+}
+
+// GetNewContent returns the memory file with the fact added (for diff
+// display)
+func (t *SaveMemoryTool) GetNewContent(args map[string]interface{}) (string, error) {
+	fact, _ := args["fact"].(string)
+	scope, _ := args["scope"].(string)
+	_, _, updated, err := t.memory.Plan(scope, fact)
+	return updated, err
+}
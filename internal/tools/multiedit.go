@@ -0,0 +1,136 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// =============================================================================
+// MultiEditTool - Several replacements in one file
+// =============================================================================
+
+// MultiEditTool applies a batch of replacements to one file. Either all of
+// them apply or the file is left unchanged.
+type MultiEditTool struct {
+	rootDir  string
+	settings *Settings
+}
+
+func (t *MultiEditTool) Name() string        { return "edit_file_multi" }
+func (t *MultiEditTool) DisplayName() string { return "MultiEdit" }
+func (t *MultiEditTool) Description() string {
+	return "Apply several text replacements to one file in a single step, confirmed together. Edits are applied in order, each to the result of the previous one; if any old_text is not found, nothing is changed. Prefer this over repeated edit_file calls on the same file."
+}
+
+func (t *MultiEditTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"path": {
+				"type": "string",
+				"description": "The path of the file to edit"
+			},
+			"edits": {
+				"type": "array",
+				"description": "The replacements, applied in order",
+				"items": {
+					"type": "object",
+					"properties": {
+						"old_text": {
+							"type": "string",
+							"description": "The exact text to find and replace (first occurrence)"
+						},
+						"new_text": {
+							"type": "string",
+							"description": "The text to replace with"
+						}
+					},
+					"required": ["old_text", "new_text"]
+				}
+			}
+		},
+		"required": ["path", "edits"]
+	}`)
+}
+
+func (t *MultiEditTool) RequiresConfirmation() bool { return true }
+func (t *MultiEditTool) ConfirmationType() string   { return "edit" }
+
+func (t *MultiEditTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	path, fullPath, original, updated, count, err := t.apply(args)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	if err := os.WriteFile(fullPath, []byte(updated), 0644); err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to write file: %v", err)}, nil
+	}
+	return diffResult(map[string]interface{}{
+		"success": true,
+		"path":    fullPath,
+		"edits":   count,
+		"message": fmt.Sprintf("Successfully applied %d edits", count),
+	}, filepath.ToSlash(path), original, updated), nil
+}
+
+// apply computes the file content after all edits, failing if any of them
+// does not apply
+func (t *MultiEditTool) apply(args map[string]interface{}) (path, fullPath, original, updated string, count int, err error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return "", "", "", "", 0, fmt.Errorf("path is required and must be a string")
+	}
+	edits, ok := args["edits"].([]interface{})
+	if !ok || len(edits) == 0 {
+		return "", "", "", "", 0, fmt.Errorf("edits is required and must be a non-empty array")
+	}
+
+	fullPath, err = t.resolvePath(path)
+	if err != nil {
+		return "", "", "", "", 0, err
+	}
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", "", "", "", 0, fmt.Errorf("failed to read file: %v", err)
+	}
+	original = string(data)
+
+	updated = original
+	for i, e := range edits {
+		edit, _ := e.(map[string]interface{})
+		oldText, ok := edit["old_text"].(string)
+		if !ok || oldText == "" {
+			return "", "", "", "", 0, fmt.Errorf("edit %d: old_text is required and must be a non-empty string", i+1)
+		}
+		newText, ok := edit["new_text"].(string)
+		if !ok {
+			return "", "", "", "", 0, fmt.Errorf("edit %d: new_text is required and must be a string", i+1)
+		}
+		if !strings.Contains(updated, oldText) {
+			return "", "", "", "", 0, fmt.Errorf("edit %d: old_text not found in file (after applying the edits before it); no changes were made", i+1)
+		}
+		updated = strings.Replace(updated, oldText, newText, 1)
+	}
+	return path, fullPath, original, updated, len(edits), nil
+}
+
+func (t *MultiEditTool) resolvePath(path string) (string, error) {
+	return t.settings.resolveWritablePath(t.rootDir, path)
+}
+
+// GetOriginalContent returns the current content of a file (for diff display)
+func (t *MultiEditTool) GetOriginalContent(args map[string]interface{}) (string, error) {
+	_, _, original, _, _, err := t.apply(args)
+	return original, err
+}
+
+// GetNewContent returns the content after all edits (for diff display)
+func (t *MultiEditTool) GetNewContent(args map[string]interface{}) (string, error) {
+	_, _, _, updated, _, err := t.apply(args)
+	return updated, err
+}
@@ -0,0 +1,52 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import "sync"
+
+// MaxParallel bounds how many read-only tool calls run at once
+const MaxParallel = 4
+
+// ReadOnlyTool is implemented by tools that change nothing: no files,
+// processes, jobs or other state. Needing no confirmation is not enough,
+// since trusted tools may still change things.
+type ReadOnlyTool interface {
+	ReadOnly() bool
+}
+
+// ReadOnly reports whether a tool changes nothing, so that its calls can
+// run concurrently and read-only runs may offer it. Only tools that say so
+// with a ReadOnly method are.
+func ReadOnly(t BuiltinTool) bool {
+	r, ok := t.(ReadOnlyTool)
+	return ok && r.ReadOnly()
+}
+
+// Parallel calls fn(0) ... fn(n-1) on at most workers goroutines and waits
+// for all of them to return
+func Parallel(n, workers int, fn func(i int)) {
+	if n == 1 || workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,45 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import "testing"
+
+func TestReadOnly(t *testing.T) {
+	registry := NewRegistry(t.TempDir())
+	tests := []struct {
+		name     string
+		readOnly bool
+	}{
+		{"read_file", true},
+		{"list_directory", true},
+		{"glob", true},
+		{"search_file_content", true},
+		{"web_search", true},
+		{"write_file", false},
+		{"edit_file", false},
+		{"edit_file_multi", false},
+		{"insert_at_line", false},
+		{"delete_lines", false},
+		{"rename_symbol", false},
+		{"save_memory", false},
+		{"write_todos", false},
+		{"git_status", false},
+		{"git_commit", false},
+		{"web_fetch", false},
+		{"shell", false},
+		{"check_job_output", false},
+		{"kill_job", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool, ok := registry.Get(tt.name)
+			if !ok {
+				t.Fatalf("no tool %s", tt.name)
+			}
+			if got := ReadOnly(tool); got != tt.readOnly {
+				t.Errorf("ReadOnly(%s) = %v, want %v", tt.name, got, tt.readOnly)
+			}
+		})
+	}
+}
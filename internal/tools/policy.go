@@ -0,0 +1,250 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Decision is what the command policy says about a tool call
+type Decision int
+
+const (
+	// DecisionAsk leaves the call to the usual confirmation
+	DecisionAsk Decision = iota
+	// DecisionAllow runs the call without confirmation
+	DecisionAllow
+	// DecisionBlock refuses the call, even in YOLO mode
+	DecisionBlock
+)
+
+// DefaultBlockedCommands are always blocked, in addition to
+// tools.shell.blockedCommands
+var DefaultBlockedCommands = []string{
+	`/\brm\s+(-\S+\s+)*-[a-zA-Z]*[rR][a-zA-Z]*\s+(-\S+\s+)*(/|/\*|~/?|\$HOME/?)(\s|;|&|\||$)/`, // rm -rf / or ~
+	`/\b(curl|wget)\b[^|;&]*\|\s*(sudo\s+)?(ba|z|da|k)?sh\b/`,                                  // curl ... | sh
+	`/\bmkfs(\.\w+)?\s/`,
+	`/\bdd\b.*\bof=/dev/(sd|hd|nvme|disk|mmcblk)/`,
+	`/>\s*/dev/(sd|hd|nvme|disk|mmcblk)/`,
+	`/:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:/`, // Fork bomb
+}
+
+// commandPattern is a glob or /regex/ from the command policy
+type commandPattern struct {
+	text string
+	re   *regexp.Regexp // Set for /regex/ patterns
+}
+
+// match reports whether the pattern matches a command. A regex matches
+// anywhere in it; a glob must match all of it, and a glob without
+// wildcards also matches the command followed by arguments ("ls" matches
+// "ls -la" but not "lsblk").
+func (p commandPattern) match(command string) bool {
+	if p.re != nil {
+		return p.re.MatchString(command)
+	}
+	if !strings.ContainsAny(p.text, "*?[") {
+		return command == p.text || strings.HasPrefix(command, p.text+" ")
+	}
+	// path.Match stops * at slashes; commands are matched as one string
+	ok, _ := path.Match(strings.ReplaceAll(p.text, "/", "\x00"), strings.ReplaceAll(command, "/", "\x00"))
+	return ok
+}
+
+// commandPolicy holds the compiled tools.shell command lists
+type commandPolicy struct {
+	sync.RWMutex
+	allowed []commandPattern
+	blocked []commandPattern
+}
+
+// SetCommandPolicy sets the commands that run without confirmation and the
+// ones that are refused. Patterns are globs ("go test*") or regular
+// expressions between slashes ("/^npm (test|run lint)$/"). Invalid patterns
+// are skipped and reported in the error.
+func (s *Settings) SetCommandPolicy(allowed, blocked []string) error {
+	var errs []error
+	compile := func(list []string) []commandPattern {
+		patterns := make([]commandPattern, 0, len(list))
+		for _, text := range list {
+			text = strings.TrimSpace(text)
+			if text == "" {
+				continue
+			}
+			p := commandPattern{text: text}
+			if len(text) > 2 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/") {
+				re, err := regexp.Compile(text[1 : len(text)-1])
+				if err != nil {
+					errs = append(errs, fmt.Errorf("invalid command pattern %s: %w", text, err))
+					continue
+				}
+				p.re = re
+			} else if _, err := path.Match(text, ""); err != nil {
+				errs = append(errs, fmt.Errorf("invalid command pattern %s: %w", text, err))
+				continue
+			}
+			patterns = append(patterns, p)
+		}
+		return patterns
+	}
+	allowedPatterns := compile(allowed)
+	blockedPatterns := compile(append(append([]string(nil), DefaultBlockedCommands...), blocked...))
+
+	s.commands.Lock()
+	s.commands.allowed = allowedPatterns
+	s.commands.blocked = blockedPatterns
+	s.commands.Unlock()
+	return errors.Join(errs...)
+}
+
+// unsafeForAllow are shell constructs that can hide another command or
+// write files, so commands using them are never auto-approved
+var unsafeForAllow = []string{"`", "$(", "<(", ">(", ">", "<"}
+
+// CheckCommand applies the command policy to a shell command. The reason
+// names the matching pattern.
+func (s *Settings) CheckCommand(command string) (Decision, string) {
+	command = normalizeCommand(command)
+	segments := commandSegments(command)
+
+	s.commands.RLock()
+	defer s.commands.RUnlock()
+
+	for _, p := range s.commands.blocked {
+		if p.match(command) {
+			return DecisionBlock, p.text
+		}
+		for _, seg := range segments {
+			if p.match(seg) {
+				return DecisionBlock, p.text
+			}
+		}
+	}
+
+	if len(s.commands.allowed) == 0 || len(segments) == 0 {
+		return DecisionAsk, ""
+	}
+	for _, construct := range unsafeForAllow {
+		if strings.Contains(command, construct) {
+			return DecisionAsk, ""
+		}
+	}
+	var matched []string
+	for _, seg := range segments {
+		found := ""
+		for _, p := range s.commands.allowed {
+			if p.match(seg) {
+				found = p.text
+				break
+			}
+		}
+		if found == "" {
+			return DecisionAsk, ""
+		}
+		matched = append(matched, found)
+	}
+	return DecisionAllow, strings.Join(matched, ", ")
+}
+
+// CheckCall applies the command policy of the tool's registry to a tool
+// call. Only shell calls are affected; everything else is left to the usual confirmation. An
+// allowed command still asks when the call adds variables or input, or
+// runs outside the workspace, since the command line alone no longer says
+// what runs.
+func CheckCall(t BuiltinTool, args map[string]interface{}) (Decision, string) {
+	shell, ok := t.(*ShellTool)
+	if !ok {
+		return DecisionAsk, ""
+	}
+	command, _ := args["command"].(string)
+	decision, reason := shell.settings.CheckCommand(command)
+	if decision == DecisionAllow && !shell.plainCall(args) {
+		return DecisionAsk, ""
+	}
+	return decision, reason
+}
+
+// codeEnv are environment variables that make the shell or the dynamic
+// linker run other code than the command names
+var codeEnv = []string{"BASH_ENV", "ENV", "PATH", "LD_*", "DYLD_*"}
+
+// isCodeEnv reports whether setting the named variable can run other code
+func isCodeEnv(name string) bool {
+	for _, pattern := range codeEnv {
+		if ok, _ := path.Match(pattern, strings.ToUpper(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// plainCall reports whether a shell call runs what its command line says:
+// it sets no variables of its own and gives no input, the configured
+// variables cannot run other code, and it runs inside the workspace
+func (t *ShellTool) plainCall(args map[string]interface{}) bool {
+	if env, err := EnvArg(args); err != nil || len(env) > 0 {
+		return false
+	}
+	if stdin, _ := args["stdin"].(string); stdin != "" {
+		return false
+	}
+
+	t.settings.shell.Lock()
+	dir, defaultEnv := t.settings.shell.dir, t.settings.shell.env
+	t.settings.shell.Unlock()
+	for name := range defaultEnv {
+		if isCodeEnv(name) {
+			return false
+		}
+	}
+
+	if cwd, ok := args["cwd"].(string); ok && strings.TrimSpace(cwd) != "" {
+		dir = cwd
+	}
+	if dir == "" {
+		return true
+	}
+	root, err := filepath.Abs(t.rootDir)
+	if err != nil {
+		return false
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return within(realPath(root), realPath(filepath.Clean(dir)))
+}
+
+// BlockedError is the message returned for a blocked command
+func BlockedError(reason string) string {
+	return fmt.Sprintf("command blocked by the shell command policy (matches %s)", reason)
+}
+
+// normalizeCommand collapses whitespace so patterns need not account for it
+func normalizeCommand(command string) string {
+	lines := strings.Split(command, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// commandSeparator splits command lists and pipelines
+var commandSeparator = regexp.MustCompile(`\s*(?:&&|\|\||[;|&\n])\s*`)
+
+// commandSegments returns the simple commands of a command line
+func commandSegments(command string) []string {
+	var segments []string
+	for _, seg := range commandSeparator.Split(command, -1) {
+		if seg = strings.TrimSpace(seg); seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
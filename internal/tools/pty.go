@@ -0,0 +1,220 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+const (
+	// maxPTYScrollback caps the terminal output kept for the model; older
+	// output is dropped first
+	maxPTYScrollback = 256 << 10
+	// ptyRows and ptyCols are the terminal size until the user takes over
+	ptyRows = 24
+	ptyCols = 120
+	// DetachKey (Ctrl+]) hands the terminal back to gmn after taking over
+	DetachKey = 0x1d
+)
+
+// PTYSession is a shell command running in a pseudo-terminal. The user can
+// take it over to answer prompts while the tool waits for it to finish.
+type PTYSession struct {
+	Command string
+
+	pty  *os.File
+	done chan struct{}
+
+	mu         sync.Mutex
+	scrollback []byte
+	attached   io.Writer // Terminal of the user who took over, or nil
+}
+
+// activePTY is the PTY command currently running, if any
+var activePTY atomic.Pointer[PTYSession]
+
+// ActivePTY returns the PTY command currently running, or nil
+func ActivePTY() *PTYSession {
+	return activePTY.Load()
+}
+
+// Write records terminal output and forwards it to the user while attached
+func (s *PTYSession) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scrollback = append(s.scrollback, p...)
+	if over := len(s.scrollback) - maxPTYScrollback; over > 0 {
+		s.scrollback = append(s.scrollback[:0:0], s.scrollback[over:]...)
+	}
+	if s.attached != nil {
+		s.attached.Write(p)
+	}
+	return len(p), nil
+}
+
+// Attach connects the user's terminal to the command until it exits or the
+// user presses DetachKey. The screen is redrawn from the scrollback first.
+// stdin must be in raw mode; rows and cols are the user's terminal size.
+func (s *PTYSession) Attach(stdin io.Reader, stdout io.Writer, rows, cols int) error {
+	if rows > 0 && cols > 0 {
+		pty.Setsize(s.pty, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+	}
+
+	s.mu.Lock()
+	stdout.Write(s.scrollback)
+	s.attached = stdout
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.attached = nil
+		s.mu.Unlock()
+	}()
+
+	detached := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := stdin.Read(buf)
+			if i := bytes.IndexByte(buf[:n], DetachKey); i >= 0 {
+				s.pty.Write(buf[:i])
+				detached <- nil
+				return
+			}
+			if n > 0 {
+				if _, werr := s.pty.Write(buf[:n]); werr != nil {
+					detached <- werr
+					return
+				}
+			}
+			if err != nil {
+				detached <- err
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-s.done:
+		return nil
+	case err := <-detached:
+		return err
+	}
+}
+
+// Done is closed when the command has exited
+func (s *PTYSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// runPTY runs a command in a pseudo-terminal and returns its cleaned-up
+// terminal output
+func (t *ShellTool) runPTY(command string, timeout int, opts shellOptions) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmd := t.command(ctx, command, opts)
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "TERM=xterm-256color")
+	f, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: ptyRows, Cols: ptyCols})
+	if err != nil {
+		if errors.Is(err, pty.ErrUnsupported) {
+			return map[string]interface{}{"error": "pty mode is not supported on this platform"}, nil
+		}
+		return map[string]interface{}{"error": fmt.Sprintf("failed to start command: %v", err)}, nil
+	}
+	defer f.Close()
+
+	s := &PTYSession{Command: command, pty: f, done: make(chan struct{})}
+	if !activePTY.CompareAndSwap(nil, s) {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return map[string]interface{}{"error": "another pty command is already running"}, nil
+	}
+	defer activePTY.CompareAndSwap(s, nil)
+
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(s, f) // Ends with EIO once the command and its children exit
+		close(copied)
+	}()
+
+	startTime := time.Now()
+	err = cmd.Wait()
+	close(s.done)
+	select {
+	case <-copied:
+	case <-time.After(500 * time.Millisecond): // A child still holds the terminal
+	}
+	duration := time.Since(startTime)
+
+	s.mu.Lock()
+	output := cleanTerminalOutput(string(s.scrollback))
+	s.mu.Unlock()
+	const maxOutput = 50000
+	if len(output) > maxOutput {
+		output = "[Earlier output truncated...]\n" + output[len(output)-maxOutput:]
+	}
+
+	result := map[string]interface{}{
+		"command":     command,
+		"output":      output,
+		"pty":         true,
+		"duration_ms": duration.Milliseconds(),
+		KeyMediaType:  MediaText,
+		KeyBody:       "output",
+	}
+	if opts.dir != "" {
+		result["cwd"] = opts.dir
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		result["error"] = fmt.Sprintf("command timed out after %d seconds (it may be waiting for input)", timeout)
+		result["exit_code"] = -1
+		return result, nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		result["exit_code"] = exitErr.ExitCode()
+	} else if err != nil {
+		result["error"] = err.Error()
+		result["exit_code"] = -1
+	} else {
+		result["exit_code"] = 0
+	}
+	result[KeySummary] = fmt.Sprintf("exit %v in %dms (pty)", result["exit_code"], duration.Milliseconds())
+	return result, nil
+}
+
+// ansiSequence matches terminal escape sequences (CSI, OSC and two-byte
+// escapes)
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// cleanTerminalOutput turns raw terminal output into plain text: escape
+// sequences are removed and carriage returns (progress bars, spinners) keep
+// only the last rewrite of each line
+func cleanTerminalOutput(s string) string {
+	s = ansiSequence.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if j := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = strings.TrimRight(line, "\r")
+	}
+	return strings.Join(lines, "\n")
+}
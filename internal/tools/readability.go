@@ -0,0 +1,454 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+var (
+	// unlikelyContent marks elements that are rarely part of an article
+	unlikelyContent = regexp.MustCompile(`(?i)comment|sidebar|footer|menu|share|social|promo|advert|sponsor|cookie|banner|popup|modal|related|breadcrumb|pagination|subscribe|newsletter`)
+	// likelyContent marks elements that usually hold the article
+	likelyContent = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story|markdown|prose|doc`)
+	// negativeContent lowers the score of candidates
+	negativeContent = regexp.MustCompile(`(?i)comment|footer|meta|nav|sidebar|share|social|related|promo|ad-|sponsor|widget`)
+	spaces          = regexp.MustCompile(`[ \t\r\n\f]+`)
+)
+
+// prepareDocument removes what is never content and unwraps <noscript>
+// fallbacks, which often hold the real content of script-heavy pages
+func prepareDocument(doc *goquery.Document) {
+	doc.Find("script, style, template, svg, canvas, iframe, form, button, input, select, textarea, dialog").Remove()
+	doc.Find("noscript").Each(func(_ int, s *goquery.Selection) {
+		s.ReplaceWithHtml(s.Text())
+	})
+	// Site chrome; headers inside the article keep its title
+	doc.Find("nav, aside, footer, body > header, [role=navigation], [role=banner], [role=contentinfo], [aria-hidden=true]").Remove()
+	doc.Find("div, section, span, ul, header").Each(func(_ int, s *goquery.Selection) {
+		hint := classAndID(s)
+		if hint != "" && unlikelyContent.MatchString(hint) && !likelyContent.MatchString(hint) {
+			s.Remove()
+		}
+	})
+}
+
+// classAndID returns an element's class and id for matching
+func classAndID(s *goquery.Selection) string {
+	class, _ := s.Attr("class")
+	id, _ := s.Attr("id")
+	return class + " " + id
+}
+
+// mainContent returns the element holding the main content of a page: an
+// <article> or <main> if there is exactly one, otherwise the best scoring
+// container, scored by the paragraphs it holds (as in Readability)
+func mainContent(doc *goquery.Document) *goquery.Selection {
+	for _, sel := range []string{"article", "main", "[role=main]"} {
+		if s := doc.Find(sel); s.Length() == 1 && len(strings.TrimSpace(s.Text())) > 200 {
+			return s
+		}
+	}
+
+	scores := map[*html.Node]float64{}
+	doc.Find("p, pre, td, blockquote, li").Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		parent := s.Parent()
+		if parent.Length() == 0 {
+			return
+		}
+		addScore(scores, parent, score)
+		if grand := parent.Parent(); grand.Length() > 0 {
+			addScore(scores, grand, score/2)
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for node, score := range scores {
+		s := goquery.NewDocumentFromNode(node).Selection
+		score *= 1 - linkDensity(s)
+		if score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	if best == nil {
+		return doc.Find("body")
+	}
+	return goquery.NewDocumentFromNode(best).Selection
+}
+
+// addScore adds to a candidate's score, starting from a base score for its
+// tag and class the first time it is seen
+func addScore(scores map[*html.Node]float64, s *goquery.Selection, score float64) {
+	node := s.Get(0)
+	if _, ok := scores[node]; !ok {
+		base := 0.0
+		switch goquery.NodeName(s) {
+		case "article", "main":
+			base = 10
+		case "div", "section":
+			base = 5
+		case "pre", "td", "blockquote":
+			base = 3
+		case "ol", "ul", "dl", "form", "th", "li":
+			base = -3
+		case "body":
+			base = -5
+		}
+		hint := classAndID(s)
+		if likelyContent.MatchString(hint) {
+			base += 25
+		}
+		if negativeContent.MatchString(hint) {
+			base -= 25
+		}
+		scores[node] = base
+	}
+	scores[node] += score
+}
+
+// linkDensity returns the share of an element's text that is link text
+func linkDensity(s *goquery.Selection) float64 {
+	total := len(strings.TrimSpace(s.Text()))
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		links += len(strings.TrimSpace(a.Text()))
+	})
+	return math.Min(float64(links)/float64(total), 1)
+}
+
+// pageTitle returns the title of a page
+func pageTitle(doc *goquery.Document) string {
+	if t, ok := doc.Find(`meta[property="og:title"]`).Attr("content"); ok && strings.TrimSpace(t) != "" {
+		return strings.TrimSpace(t)
+	}
+	if t := strings.TrimSpace(doc.Find("title").First().Text()); t != "" {
+		return t
+	}
+	return strings.TrimSpace(doc.Find("h1").First().Text())
+}
+
+// nextPageWords are link texts that point to the next page
+var nextPageWords = map[string]bool{
+	"next": true, "next page": true, "next »": true, "next ›": true, "next →": true,
+	"»": true, "›": true, "older posts": true, "次へ": true, "次のページ": true,
+}
+
+// nextPage returns the absolute URL of the next page of a paginated page
+// on the same host, or ""
+func nextPage(doc *goquery.Document, base *url.URL) string {
+	var href string
+	if h, ok := doc.Find(`link[rel~="next"], a[rel~="next"]`).First().Attr("href"); ok {
+		href = h
+	} else {
+		doc.Find("a[href]").EachWithBreak(func(_ int, a *goquery.Selection) bool {
+			text := strings.ToLower(strings.TrimSpace(spaces.ReplaceAllString(a.Text(), " ")))
+			label, _ := a.Attr("aria-label")
+			if nextPageWords[text] || strings.HasPrefix(strings.ToLower(label), "next page") {
+				href, _ = a.Attr("href")
+				return false
+			}
+			return true
+		})
+	}
+	if href == "" {
+		return ""
+	}
+	next, err := base.Parse(href)
+	if err != nil || next.Host != base.Host || (next.Scheme != "http" && next.Scheme != "https") {
+		return ""
+	}
+	next.Fragment = ""
+	if next.String() == base.String() {
+		return ""
+	}
+	return next.String()
+}
+
+// toMarkdown converts HTML to markdown, resolving links against base
+func toMarkdown(s *goquery.Selection, base *url.URL) string {
+	var b strings.Builder
+	for _, n := range s.Nodes {
+		b.WriteString(markdownNode(n, base))
+	}
+	return normalizeMarkdown(b.String())
+}
+
+// markdownChildren converts the children of a node
+func markdownChildren(n *html.Node, base *url.URL) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(markdownNode(c, base))
+	}
+	return b.String()
+}
+
+// markdownNode converts a node; block elements are surrounded by blank
+// lines, which normalizeMarkdown collapses
+func markdownNode(n *html.Node, base *url.URL) string {
+	switch n.Type {
+	case html.TextNode:
+		return spaces.ReplaceAllString(n.Data, " ")
+	case html.ElementNode:
+	case html.DocumentNode:
+		return markdownChildren(n, base)
+	default:
+		return ""
+	}
+
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := oneLine(markdownChildren(n, base))
+		if text == "" {
+			return ""
+		}
+		return "\n\n" + strings.Repeat("#", int(n.Data[1]-'0')) + " " + text + "\n\n"
+	case "p", "div", "section", "article", "main", "header", "figure", "figcaption", "dl", "dd", "dt", "details", "summary", "address":
+		return "\n\n" + strings.TrimSpace(markdownChildren(n, base)) + "\n\n"
+	case "br":
+		return "\n"
+	case "hr":
+		return "\n\n---\n\n"
+	case "strong", "b":
+		return wrapInline(markdownChildren(n, base), "**")
+	case "em", "i":
+		return wrapInline(markdownChildren(n, base), "_")
+	case "code", "kbd", "samp":
+		text := strings.TrimSpace(nodeText(n))
+		if text == "" {
+			return ""
+		}
+		return "`" + text + "`"
+	case "pre":
+		return "\n\n```" + codeLanguage(n) + "\n" + strings.Trim(nodeText(n), "\n") + "\n```\n\n"
+	case "a":
+		text := oneLine(markdownChildren(n, base))
+		href := attr(n, "href")
+		if text == "" || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return text
+		}
+		if u, err := base.Parse(href); err == nil {
+			href = u.String()
+		}
+		return "[" + text + "](" + href + ")"
+	case "img":
+		alt := strings.TrimSpace(attr(n, "alt"))
+		src := attr(n, "src")
+		if alt == "" || src == "" || strings.HasPrefix(src, "data:") {
+			return ""
+		}
+		if u, err := base.Parse(src); err == nil {
+			src = u.String()
+		}
+		return "![" + alt + "](" + src + ")"
+	case "ul", "ol":
+		return "\n\n" + markdownList(n, base) + "\n\n"
+	case "blockquote":
+		lines := strings.Split(normalizeMarkdown(markdownChildren(n, base)), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+	case "table":
+		return "\n\n" + markdownTable(n, base) + "\n\n"
+	case "head", "title", "meta", "link":
+		return ""
+	}
+	return markdownChildren(n, base)
+}
+
+// markdownList converts the items of a list, indenting nested content
+func markdownList(n *html.Node, base *url.URL) string {
+	var items []string
+	i := 1
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.Data != "li" {
+			continue
+		}
+		marker := "- "
+		if n.Data == "ol" {
+			marker = fmt.Sprintf("%d. ", i)
+			i++
+		}
+		content := normalizeMarkdown(markdownChildren(c, base))
+		// Keep items tight: no blank lines inside an item
+		var lines []string
+		for _, line := range strings.Split(content, "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		indent := strings.Repeat(" ", len(marker))
+		for j := range lines {
+			if j == 0 {
+				lines[j] = marker + lines[j]
+			} else {
+				lines[j] = indent + lines[j]
+			}
+		}
+		items = append(items, strings.Join(lines, "\n"))
+	}
+	return strings.Join(items, "\n")
+}
+
+// markdownTable converts a table, using its first row as the header
+func markdownTable(n *html.Node, base *url.URL) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.Data {
+			case "thead", "tbody", "tfoot":
+				walk(c)
+			case "tr":
+				var row []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						row = append(row, strings.ReplaceAll(oneLine(markdownChildren(cell, base)), "|", `\|`))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", cols))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// codeLanguage returns the language of a code block from its class or its
+// <code> child's class ("language-go", "lang-go")
+func codeLanguage(n *html.Node) string {
+	classes := attr(n, "class")
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "code" {
+			classes += " " + attr(c, "class")
+		}
+	}
+	for _, class := range strings.Fields(classes) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(class, prefix) {
+				return strings.TrimPrefix(class, prefix)
+			}
+		}
+	}
+	return ""
+}
+
+// nodeText returns the raw text of a node
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "br" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
+
+// attr returns an attribute of a node
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// oneLine collapses text to a single line
+func oneLine(s string) string {
+	return strings.TrimSpace(spaces.ReplaceAllString(s, " "))
+}
+
+// wrapInline wraps inline text in a markdown marker, keeping the spaces
+// around it outside the marker
+func wrapInline(s, marker string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	lead := s[:strings.Index(s, trimmed)]
+	trail := s[len(lead)+len(trimmed):]
+	return lead + marker + trimmed + marker + trail
+}
+
+// normalizeMarkdown trims trailing spaces and collapses blank lines outside
+// code blocks
+func normalizeMarkdown(s string) string {
+	var out []string
+	inFence, blank := false, true
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			out = append(out, strings.TrimSpace(line))
+			blank = false
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+		line = strings.TrimRight(line, " ")
+		if strings.TrimSpace(line) == "" {
+			if !blank {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		// Text nodes after a block or <br> start with a collapsed space
+		if strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "  ") {
+			line = line[1:]
+		}
+		out = append(out, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,137 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxReadBytes is the largest file read_file reads by default
+	DefaultMaxReadBytes = 10 << 20
+	// maxLineLength caps each returned line; longer lines (minified code)
+	// are cut off
+	maxLineLength = 2000
+	// sniffLen is how much of a file is inspected to detect binary content
+	sniffLen = 8 << 10
+)
+
+// SetMaxReadBytes sets the largest file read_file will read. Zero or less
+// restores the default.
+func (s *Settings) SetMaxReadBytes(n int64) {
+	if n <= 0 {
+		n = DefaultMaxReadBytes
+	}
+	s.maxReadBytes.Store(n)
+}
+
+// GetMaxReadBytes returns the largest file read_file will read
+func (s *Settings) GetMaxReadBytes() int64 {
+	if n := s.maxReadBytes.Load(); n > 0 {
+		return n
+	}
+	return DefaultMaxReadBytes
+}
+
+// checkReadable returns an error result with the file's size and type if
+// it is a directory, too large, or binary, or nil if it can be read as text
+func (s *Settings) checkReadable(fullPath string) map[string]interface{} {
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to read file: %v", err)}
+	}
+	if info.IsDir() {
+		return map[string]interface{}{"error": fmt.Sprintf("%s is a directory; use list_directory instead", fullPath)}
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to read file: %v", err)}
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	mimeType := http.DetectContentType(head)
+
+	if limit := s.GetMaxReadBytes(); info.Size() > limit {
+		return map[string]interface{}{
+			"error": fmt.Sprintf("file is too large to read (%s, limit %s); inspect it with search_file_content or a shell command such as head or grep instead",
+				formatBytes(info.Size()), formatBytes(limit)),
+			"path": fullPath,
+			"size": info.Size(),
+			"type": mimeType,
+		}
+	}
+	if isBinary(head) {
+		return map[string]interface{}{
+			"error": fmt.Sprintf("file appears to be binary (%s, %s) and was not read; use a shell command (e.g. file, strings, xxd) to inspect it",
+				mimeType, formatBytes(info.Size())),
+			"path": fullPath,
+			"size": info.Size(),
+			"type": mimeType,
+		}
+	}
+	return nil
+}
+
+// maxControlRatio is the share of control characters above which a file
+// without NUL bytes is still taken for binary data
+const maxControlRatio = 0.1
+
+// isBinary reports whether the start of a file looks like binary data: it
+// contains NUL bytes or many control characters. Text in other encodings
+// than UTF-8, such as Latin-1 or Shift_JIS, is still text.
+func isBinary(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	control := 0
+	for _, c := range head {
+		switch {
+		case c == '\t', c == '\n', c == '\r', c == '\f', c == '\v', c == '\b', c == 0x1b: // 0x1b starts ANSI colors in logs
+		case c < 0x20, c == 0x7f:
+			control++
+		}
+	}
+	return float64(control) > maxControlRatio*float64(len(head))
+}
+
+// truncateLongLines cuts lines longer than maxLineLength bytes, returning
+// the number of lines cut
+func truncateLongLines(lines []string) int {
+	cut := 0
+	for i, line := range lines {
+		if len(line) <= maxLineLength {
+			continue
+		}
+		end := maxLineLength
+		for end > 0 && !utf8.RuneStart(line[end]) {
+			end--
+		}
+		nl := ""
+		if line[len(line)-1] == '\n' {
+			nl = "\n"
+		}
+		lines[i] = fmt.Sprintf("%s... [line truncated, %d bytes]%s", line[:end], len(line), nl)
+		cut++
+	}
+	return cut
+}
+
+// formatBytes formats a byte count for messages
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
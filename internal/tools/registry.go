@@ -4,9 +4,16 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
 
 	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/memory"
 )
 
 // BuiltinTool defines the interface for built-in tools
@@ -27,17 +34,85 @@ type BuiltinTool interface {
 	ConfirmationType() string
 }
 
+// ContextTool is a tool whose calls stop when the caller's context is
+// cancelled, such as a tool of an MCP server
+type ContextTool interface {
+	ExecuteContext(ctx context.Context, args map[string]interface{}) (map[string]interface{}, error)
+}
+
+// ExecuteContext runs a tool, with ctx if it takes one
+func ExecuteContext(ctx context.Context, t BuiltinTool, args map[string]interface{}) (map[string]interface{}, error) {
+	if c, ok := t.(ContextTool); ok {
+		return c.ExecuteContext(ctx, args)
+	}
+	return t.Execute(args)
+}
+
 // Registry holds all registered tools
 type Registry struct {
-	tools   map[string]BuiltinTool
-	rootDir string
+	mu       sync.RWMutex // Guards tools, which settings reloads change
+	tools    map[string]BuiltinTool
+	rootDir  string
+	stats    *Stats
+	memory   *memory.Store
+	jobs     *Jobs
+	settings *Settings
+	filter   toolFilter
+	prompt   systemPrompt
+}
+
+// systemPrompt is the general.systemPrompt setting
+type systemPrompt struct {
+	sync.RWMutex
+	text string
+}
+
+// toolFilter decides which registered tools are offered to the model
+type toolFilter struct {
+	sync.RWMutex
+	only     []string // Patterns from --tools; empty enables every tool
+	disabled []string // Patterns from tools.disabled
+}
+
+// Handle carries a registry inside the Registry of package
+// github.com/linkalls/gmn/pkg/tools, which embeds it to hide the registry
+// from other programs so only its stable methods are public. gmn's own
+// commands get the registry back with RegistryOf.
+type Handle struct {
+	registry *Registry
+}
+
+// NewHandle returns a Handle carrying r
+func NewHandle(r *Registry) Handle {
+	return Handle{registry: r}
+}
+
+func (h Handle) internalRegistry() *Registry { return h.registry }
+
+// handleHolder is implemented by types that embed a Handle
+type handleHolder interface {
+	internalRegistry() *Registry
+}
+
+// RegistryOf returns the registry behind a Registry of package
+// github.com/linkalls/gmn/pkg/tools, or nil for other values. public must
+// not be a nil pointer.
+func RegistryOf(public any) *Registry {
+	if h, ok := public.(handleHolder); ok {
+		return h.internalRegistry()
+	}
+	return nil
 }
 
 // NewRegistry creates a new tool registry
 func NewRegistry(rootDir string) *Registry {
 	r := &Registry{
-		tools:   make(map[string]BuiltinTool),
-		rootDir: rootDir,
+		tools:    make(map[string]BuiltinTool),
+		rootDir:  rootDir,
+		stats:    NewStats(),
+		memory:   memory.New(rootDir),
+		jobs:     NewJobs(),
+		settings: NewSettings(),
 	}
 	r.registerBuiltins()
 	return r
@@ -46,45 +121,129 @@ func NewRegistry(rootDir string) *Registry {
 // registerBuiltins registers all built-in tools
 func (r *Registry) registerBuiltins() {
 	// File system tools
-	r.Register(&ReadFileTool{rootDir: r.rootDir})
-	r.Register(&WriteFileTool{rootDir: r.rootDir})
-	r.Register(&ListDirectoryTool{rootDir: r.rootDir})
-	r.Register(&GlobTool{rootDir: r.rootDir})
-	r.Register(&SearchFileContentTool{rootDir: r.rootDir})
-	r.Register(&EditFileTool{rootDir: r.rootDir})
+	r.Register(&ReadFileTool{rootDir: r.rootDir, settings: r.settings})
+	r.Register(&WriteFileTool{rootDir: r.rootDir, settings: r.settings})
+	r.Register(&ListDirectoryTool{rootDir: r.rootDir, settings: r.settings})
+	r.Register(&GlobTool{rootDir: r.rootDir, settings: r.settings})
+	r.Register(&SearchFileContentTool{rootDir: r.rootDir, settings: r.settings})
+	r.Register(&EditFileTool{rootDir: r.rootDir, settings: r.settings})
+	r.Register(&MultiEditTool{rootDir: r.rootDir, settings: r.settings})
+	r.Register(&InsertAtLineTool{rootDir: r.rootDir, settings: r.settings})
+	r.Register(&DeleteLinesTool{rootDir: r.rootDir, settings: r.settings})
+	r.Register(&RenameTool{rootDir: r.rootDir, settings: r.settings})
+
+	// Memory tool
+	r.Register(&SaveMemoryTool{memory: r.memory})
+
+	// Planning tool
+	r.Register(&WriteTodosTool{})
+
+	// Git tools
+	r.Register(&GitStatusTool{rootDir: r.rootDir})
+	r.Register(&GitDiffTool{rootDir: r.rootDir})
+	r.Register(&GitLogTool{rootDir: r.rootDir})
+	r.Register(&GitBranchTool{rootDir: r.rootDir})
+	r.Register(&GitCommitTool{rootDir: r.rootDir})
 
 	// Web tools
-	r.Register(&WebSearchTool{})
-	r.Register(&WebFetchTool{})
+	r.Register(&WebSearchTool{settings: r.settings})
+	r.Register(&WebFetchTool{settings: r.settings})
 
-	// Shell tool
-	r.Register(&ShellTool{rootDir: r.rootDir})
+	// Shell tools
+	r.Register(&ShellTool{rootDir: r.rootDir, jobs: r.jobs, settings: r.settings})
+	r.Register(&CheckJobOutputTool{jobs: r.jobs})
+	r.Register(&KillJobTool{jobs: r.jobs})
 }
 
 // Register adds a tool to the registry
 func (r *Registry) Register(tool BuiltinTool) {
+	r.mu.Lock()
 	r.tools[tool.Name()] = tool
+	r.mu.Unlock()
+}
+
+// Stats returns the call statistics of this registry's session
+func (r *Registry) Stats() *Stats {
+	return r.stats
+}
+
+// Settings returns the settings the registry's tools share
+func (r *Registry) Settings() *Settings {
+	return r.settings
+}
+
+// Memory returns the memory files sent with every request
+func (r *Registry) Memory() *memory.Store {
+	return r.memory
+}
+
+// Jobs returns the background shell commands of this session
+func (r *Registry) Jobs() *Jobs {
+	return r.jobs
+}
+
+// SetSystemPrompt sets text to put first in the system instruction, from
+// the general.systemPrompt setting
+func (r *Registry) SetSystemPrompt(text string) {
+	r.prompt.Lock()
+	r.prompt.text = text
+	r.prompt.Unlock()
+}
+
+// SystemInstruction returns the system instruction for a chat request: the
+// configured system prompt, the saved memory and, if failureNotes is set,
+// notes on tools that keep failing. It returns nil if there are none.
+func (r *Registry) SystemInstruction(failureNotes bool) *api.Content {
+	var parts []string
+	r.prompt.RLock()
+	if text := strings.TrimSpace(r.prompt.text); text != "" {
+		parts = append(parts, text)
+	}
+	r.prompt.RUnlock()
+	if m := r.memory.Instruction(); m != "" {
+		parts = append(parts, m)
+	}
+	if failureNotes {
+		if note := r.stats.FailureNote(); note != "" {
+			parts = append(parts, note)
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return &api.Content{Role: "user", Parts: []api.Part{{Text: strings.Join(parts, "\n\n")}}}
 }
 
-// Get returns a tool by name
+// Get returns an enabled tool by name
 func (r *Registry) Get(name string) (BuiltinTool, bool) {
+	r.mu.RLock()
 	tool, ok := r.tools[name]
-	return tool, ok
+	r.mu.RUnlock()
+	if !ok || !r.Enabled(name) {
+		return nil, false
+	}
+	return tool, true
 }
 
-// GetAll returns all registered tools
+// GetAll returns all enabled tools
 func (r *Registry) GetAll() []BuiltinTool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	result := make([]BuiltinTool, 0, len(r.tools))
-	for _, tool := range r.tools {
-		result = append(result, tool)
+	for name, tool := range r.tools {
+		if r.Enabled(name) {
+			result = append(result, tool)
+		}
 	}
 	return result
 }
 
-// GetFunctionDeclarations returns API-compatible function declarations for all tools
+// GetFunctionDeclarations returns API-compatible function declarations for
+// all enabled tools
 func (r *Registry) GetFunctionDeclarations() []api.FunctionDecl {
-	decls := make([]api.FunctionDecl, 0, len(r.tools))
-	for _, tool := range r.tools {
+	all := r.GetAll()
+	decls := make([]api.FunctionDecl, 0, len(all))
+	for _, tool := range all {
 		decls = append(decls, api.FunctionDecl{
 			Name:        tool.Name(),
 			Description: tool.Description(),
@@ -101,11 +260,99 @@ func (r *Registry) GetTools() []api.Tool {
 	}
 }
 
-// GetToolNames returns all registered tool names for completion
+// GetToolNames returns all enabled tool names for completion
 func (r *Registry) GetToolNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	result := make([]string, 0, len(r.tools))
 	for name := range r.tools {
-		result = append(result, name)
+		if r.Enabled(name) {
+			result = append(result, name)
+		}
 	}
 	return result
 }
+
+// Enabled reports whether a tool is offered to the model: it matches the
+// patterns given to Restrict, if any, and none given to SetDisabled
+func (r *Registry) Enabled(name string) bool {
+	r.filter.RLock()
+	defer r.filter.RUnlock()
+	if len(r.filter.only) > 0 && !matchAny(r.filter.only, name) {
+		return false
+	}
+	return !matchAny(r.filter.disabled, name)
+}
+
+// Restrict offers only the tools matching one of the patterns (names or
+// globs such as "git_*") to the model; no patterns enable every tool. It
+// fails, changing nothing, if a pattern is invalid or matches no tool.
+func (r *Registry) Restrict(patterns []string) error {
+	patterns, err := r.checkPatterns(patterns)
+	if err != nil {
+		return err
+	}
+	r.filter.Lock()
+	r.filter.only = patterns
+	r.filter.Unlock()
+	return nil
+}
+
+// SetDisabled hides the tools matching one of the patterns from the model.
+// Patterns that are invalid or match no tool are skipped and reported in
+// the error.
+func (r *Registry) SetDisabled(patterns []string) error {
+	var errs []error
+	valid := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		checked, err := r.checkPatterns([]string{p})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		valid = append(valid, checked...)
+	}
+	r.filter.Lock()
+	r.filter.disabled = valid
+	r.filter.Unlock()
+	return errors.Join(errs...)
+}
+
+// checkPatterns trims the patterns, dropping blank ones, and checks that
+// each is valid and matches a registered tool
+func (r *Registry) checkPatterns(patterns []string) ([]string, error) {
+	var checked []string
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid tool pattern %q", p)
+		}
+		r.mu.RLock()
+		found := false
+		for name := range r.tools {
+			if ok, _ := path.Match(p, name); ok {
+				found = true
+				break
+			}
+		}
+		r.mu.RUnlock()
+		if !found {
+			return nil, fmt.Errorf("unknown tool %q", p)
+		}
+		checked = append(checked, p)
+	}
+	return checked, nil
+}
+
+// matchAny reports whether name matches one of the patterns
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
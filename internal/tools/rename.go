@@ -0,0 +1,253 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxRenameFiles    = 200     // Refuse renames touching more files than this
+	maxRenameFileSize = 2 << 20 // Larger files are skipped
+)
+
+// renameSkipDirs are never descended into
+var renameSkipDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// =============================================================================
+// RenameTool - Project-wide symbol or string rename
+// =============================================================================
+
+// RenameTool replaces a symbol or string in every matching file under a
+// directory. All changes are previewed and confirmed together.
+type RenameTool struct {
+	rootDir  string
+	settings *Settings
+}
+
+// renameChange is the planned edit of one file
+type renameChange struct {
+	path     string // Relative to rootDir, with forward slashes
+	fullPath string
+	old, new string
+	count    int
+}
+
+func (t *RenameTool) Name() string        { return "rename_symbol" }
+func (t *RenameTool) DisplayName() string { return "Rename" }
+func (t *RenameTool) Description() string {
+	return "Rename a symbol or replace a string across all files in a directory in one step. Prefer this over many edit_file calls for project-wide renames. Matches whole words by default; hidden directories, node_modules, vendor and binary files are skipped."
+}
+
+func (t *RenameTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"old_name": {
+				"type": "string",
+				"description": "The symbol or text to replace"
+			},
+			"new_name": {
+				"type": "string",
+				"description": "The replacement"
+			},
+			"path": {
+				"type": "string",
+				"description": "Directory to search (default: the working directory)"
+			},
+			"include": {
+				"type": "string",
+				"description": "Only change files whose name matches this glob (e.g. *.go)"
+			},
+			"whole_word": {
+				"type": "boolean",
+				"description": "Match whole words only (default: true). Set to false for plain substring replacement"
+			}
+		},
+		"required": ["old_name", "new_name"]
+	}`)
+}
+
+func (t *RenameTool) RequiresConfirmation() bool { return true }
+func (t *RenameTool) ConfirmationType() string   { return "edit" }
+
+func (t *RenameTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	changes, err := t.plan(args)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+	if len(changes) == 0 {
+		return map[string]interface{}{
+			"success": true,
+			"files":   []map[string]interface{}{},
+			"count":   0,
+			"summary": "No occurrences found",
+		}, nil
+	}
+
+	files := make([]map[string]interface{}, 0, len(changes))
+	total := 0
+	for _, c := range changes {
+		if err := os.WriteFile(c.fullPath, []byte(c.new), 0644); err != nil {
+			// Report what was already changed so the model can recover
+			return map[string]interface{}{
+				"error": fmt.Sprintf("failed to write %s: %v", c.path, err),
+				"files": files,
+			}, nil
+		}
+		files = append(files, map[string]interface{}{"path": c.path, "replacements": c.count})
+		total += c.count
+	}
+
+	diff, added, removed := renameDiff(changes)
+	result := map[string]interface{}{
+		"success":       true,
+		"files":         files,
+		"count":         total,
+		"lines_added":   added,
+		"lines_removed": removed,
+		KeySummary:      fmt.Sprintf("%d replacements in %d files", total, len(changes)),
+	}
+	if len(diff) > maxDiffBytes {
+		result["diff_omitted"] = true
+	} else {
+		result["diff"] = diff
+		result[KeyMediaType] = MediaDiff
+	}
+	return result, nil
+}
+
+// Preview returns the combined diff of all planned changes, shown in the
+// confirmation prompt
+func (t *RenameTool) Preview(args map[string]interface{}) (string, error) {
+	changes, err := t.plan(args)
+	if err != nil {
+		return "", err
+	}
+	diff, _, _ := renameDiff(changes)
+	return diff, nil
+}
+
+// plan finds every file that would change, without writing anything
+func (t *RenameTool) plan(args map[string]interface{}) ([]renameChange, error) {
+	oldName, _ := args["old_name"].(string)
+	if oldName == "" {
+		return nil, fmt.Errorf("old_name is required and must be a non-empty string")
+	}
+	newName, ok := args["new_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("new_name is required and must be a string")
+	}
+	path, _ := args["path"].(string)
+	include, _ := args["include"].(string)
+	if include != "" {
+		if _, err := filepath.Match(include, ""); err != nil {
+			return nil, fmt.Errorf("invalid include pattern: %v", err)
+		}
+	}
+	wholeWord := true
+	if v, ok := args["whole_word"].(bool); ok {
+		wholeWord = v
+	}
+
+	pattern := regexp.QuoteMeta(oldName)
+	if wholeWord {
+		// \b only applies next to word characters (e.g. not for "->")
+		if isWordByte(oldName[0]) {
+			pattern = `\b` + pattern
+		}
+		if isWordByte(oldName[len(oldName)-1]) {
+			pattern += `\b`
+		}
+	}
+	re := regexp.MustCompile(pattern)
+
+	dir, err := t.settings.resolveWorkspacePath(t.rootDir, path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("path not found: %v", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("path must be a directory: %s", path)
+	}
+
+	var changes []renameChange
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if p != dir && (strings.HasPrefix(name, ".") || renameSkipDirs[name]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || checkProtected(p) != nil {
+			return nil
+		}
+		if include != "" {
+			if ok, _ := filepath.Match(include, name); !ok {
+				return nil
+			}
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxRenameFileSize {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 { // Skip binary files
+			return nil
+		}
+		matches := re.FindAllIndex(data, -1)
+		if len(matches) == 0 {
+			return nil
+		}
+		if len(changes) == maxRenameFiles {
+			return fmt.Errorf("more than %d files would change; narrow it down with path or include", maxRenameFiles)
+		}
+
+		rel, _ := filepath.Rel(t.rootDir, p)
+		changes = append(changes, renameChange{
+			path:     filepath.ToSlash(rel),
+			fullPath: p,
+			old:      string(data),
+			new:      re.ReplaceAllLiteralString(string(data), newName),
+			count:    len(matches),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// renameDiff concatenates the diffs of all changed files
+func renameDiff(changes []renameChange) (diff string, added, removed int) {
+	var b strings.Builder
+	for _, c := range changes {
+		d, a, r := UnifiedDiff(c.path, c.old, c.new)
+		b.WriteString(d)
+		added += a
+		removed += r
+	}
+	return b.String(), added, removed
+}
+
+// isWordByte reports whether c matches \w
+func isWordByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
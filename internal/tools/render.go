@@ -0,0 +1,127 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Optional keys a tool result may include to tell the UI how to display it.
+// They are plain result fields, so MCP servers can use them too.
+const (
+	KeyMediaType = "media_type" // One of the Media* constants
+	KeySummary   = "summary"    // One-line summary shown next to the tool name
+	KeyBody      = "body"       // Name of the field holding the body (overrides the default)
+	KeyColumns   = "columns"    // Column order for MediaTable
+)
+
+// Media types for KeyMediaType, with the field that holds the body by default
+const (
+	MediaText     = "text"     // "content": plain text
+	MediaMarkdown = "markdown" // "content": markdown
+	MediaDiff     = "diff"     // "diff": unified diff
+	MediaTable    = "table"    // "rows": list of objects (see KeyColumns)
+	MediaImage    = "image"    // "path": path of an image file
+)
+
+var defaultBodyKeys = map[string]string{
+	MediaText:     "content",
+	MediaMarkdown: "content",
+	MediaDiff:     "diff",
+	MediaTable:    "rows",
+	MediaImage:    "path",
+}
+
+// MediaType returns the media type declared by a tool result, or ""
+func MediaType(result map[string]interface{}) string {
+	mt, _ := result[KeyMediaType].(string)
+	if _, ok := defaultBodyKeys[mt]; !ok {
+		return ""
+	}
+	return mt
+}
+
+// Summary returns a one-line summary of a tool result. Results without a
+// summary fall back to their "count" or "message" fields.
+func Summary(result map[string]interface{}) string {
+	if s, ok := result[KeySummary].(string); ok && s != "" {
+		return s
+	}
+	switch count := result["count"].(type) {
+	case int:
+		return fmt.Sprintf("%d items", count)
+	case float64: // Results decoded from JSON (e.g. MCP)
+		return fmt.Sprintf("%d items", int(count))
+	}
+	if msg, ok := result["message"].(string); ok {
+		return msg
+	}
+	return ""
+}
+
+// Body returns the raw body value of a result with a media type
+func Body(result map[string]interface{}) interface{} {
+	key, _ := result[KeyBody].(string)
+	if key == "" {
+		key = defaultBodyKeys[MediaType(result)]
+	}
+	if key == "" {
+		return nil
+	}
+	return result[key]
+}
+
+// BodyText returns the body of a text, markdown, diff, or image result
+func BodyText(result map[string]interface{}) string {
+	s, _ := Body(result).(string)
+	return s
+}
+
+// Table returns the columns and cell values of a table result. Without
+// KeyColumns the columns are taken from the keys of the first row.
+func Table(result map[string]interface{}) ([]string, [][]string) {
+	var rows []map[string]interface{}
+	switch body := Body(result).(type) {
+	case []map[string]interface{}:
+		rows = body
+	case []interface{}:
+		for _, r := range body {
+			if m, ok := r.(map[string]interface{}); ok {
+				rows = append(rows, m)
+			}
+		}
+	}
+
+	var columns []string
+	switch cols := result[KeyColumns].(type) {
+	case []string:
+		columns = cols
+	case []interface{}:
+		for _, c := range cols {
+			if s, ok := c.(string); ok {
+				columns = append(columns, s)
+			}
+		}
+	}
+	if len(columns) == 0 && len(rows) > 0 {
+		for k := range rows[0] {
+			columns = append(columns, k)
+		}
+		sort.Strings(columns)
+	}
+
+	cells := make([][]string, 0, len(rows))
+	for _, r := range rows {
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			if v, ok := r[c]; ok && v != nil {
+				row[i] = strings.ReplaceAll(fmt.Sprint(v), "\n", " ")
+			}
+		}
+		cells = append(cells, row)
+	}
+	return columns, cells
+}
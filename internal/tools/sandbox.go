@@ -0,0 +1,149 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+// sandboxSettings confine the filesystem tools to the workspace root and
+// the extra directories from tools.sandbox.allowedDirs
+type sandboxSettings struct {
+	sync.RWMutex
+	enabled bool
+	allowed []string // Absolute, with symlinks resolved
+}
+
+// SetSandbox turns the workspace sandbox on or off and sets the directories
+// the filesystem tools may use besides the workspace. Relative directories
+// are taken from the current directory and "~" is the home directory.
+func (s *Settings) SetSandbox(enabled bool, allowedDirs []string) {
+	allowed := make([]string, 0, len(allowedDirs))
+	for _, dir := range allowedDirs {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		if dir == "~" || strings.HasPrefix(dir, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				dir = filepath.Join(home, dir[1:])
+			}
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			allowed = append(allowed, realPath(abs))
+		}
+	}
+
+	s.sandbox.Lock()
+	s.sandbox.enabled = enabled
+	s.sandbox.allowed = allowed
+	s.sandbox.Unlock()
+}
+
+// resolveWorkspacePath makes a tool's path argument absolute (relative
+// paths are taken from rootDir) and, in sandbox mode, fails if it is
+// outside the workspace and the allowed directories. Symlinks are followed
+// for the check, so a link inside the workspace cannot point out of it.
+func (s *Settings) resolveWorkspacePath(rootDir, path string) (string, error) {
+	fullPath := path
+	if !filepath.IsAbs(fullPath) {
+		fullPath = filepath.Join(rootDir, fullPath)
+	}
+	fullPath = filepath.Clean(fullPath)
+	if err := s.checkSandbox(rootDir, fullPath); err != nil {
+		return "", err
+	}
+	return fullPath, nil
+}
+
+// resolveWritablePath is resolveWorkspacePath for a file a tool changes.
+// It also fails for the files that hold gmn's settings (see
+// config.Protected), even through a symlink.
+func (s *Settings) resolveWritablePath(rootDir, path string) (string, error) {
+	fullPath, err := s.resolveWorkspacePath(rootDir, path)
+	if err != nil {
+		return "", err
+	}
+	if err := checkProtected(fullPath); err != nil {
+		return "", err
+	}
+	return fullPath, nil
+}
+
+// checkProtected fails if the tools may not change a file. Symlinks are
+// followed even if the file they point to does not exist yet, since writing
+// through them would create it.
+func checkProtected(fullPath string) error {
+	path := fullPath
+	for hops := 0; hops < maxSymlinkHops; hops++ {
+		if config.Protected(path) || config.Protected(realPath(path)) {
+			return fmt.Errorf("%s holds gmn's settings, which tools may not change; edit it yourself", fullPath)
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+	return fmt.Errorf("%s: too many levels of symbolic links", fullPath)
+}
+
+// maxSymlinkHops bounds the symlinks followed to a file
+const maxSymlinkHops = 40
+
+// checkSandbox fails if an absolute path is outside the sandbox
+func (s *Settings) checkSandbox(rootDir, fullPath string) error {
+	s.sandbox.RLock()
+	defer s.sandbox.RUnlock()
+	if !s.sandbox.enabled {
+		return nil
+	}
+
+	real := realPath(fullPath)
+	if root, err := filepath.Abs(rootDir); err == nil && within(realPath(root), real) {
+		return nil
+	}
+	for _, dir := range s.sandbox.allowed {
+		if within(dir, real) {
+			return nil
+		}
+	}
+	if real != fullPath {
+		return fmt.Errorf("%s is outside the workspace (it resolves to %s); add its directory to tools.sandbox.allowedDirs to allow it", fullPath, real)
+	}
+	return fmt.Errorf("%s is outside the workspace; add its directory to tools.sandbox.allowedDirs to allow it", fullPath)
+}
+
+// realPath resolves the symlinks of a path. Parts that do not exist yet
+// (e.g. a file about to be written) are kept as they are, after the
+// resolved path of their deepest existing parent.
+func realPath(path string) string {
+	var rest []string
+	for dir := path; ; {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+		dir = parent
+	}
+}
+
+// within reports whether path is dir or inside it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
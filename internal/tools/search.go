@@ -0,0 +1,199 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// errSearchLimit stops a walk once enough matches were found
+var errSearchLimit = errors.New("search limit reached")
+
+// searchOptions controls search_file_content
+type searchOptions struct {
+	before, after  int // Context lines around each match
+	maxPerFile     int
+	limit          int   // Total matches
+	maxBytes       int64 // Larger files are skipped
+	includeIgnored bool
+}
+
+// searchMatch is one matching line with its context
+type searchMatch struct {
+	file   string
+	line   int
+	text   string
+	before []string
+	after  []string
+}
+
+// searchStats counts what a search skipped
+type searchStats struct {
+	files     int // Files searched
+	binary    int // Binary files skipped
+	tooLarge  int // Files over the read limit skipped
+	fileCaps  int // Files with more matches than maxPerFile
+	truncated bool
+}
+
+// compileSearch builds the regexp for a search. Plain text is quoted;
+// whole-word matches use ASCII word boundaries like rename_symbol.
+func compileSearch(pattern string, isRegex, ignoreCase, wholeWord bool) (*regexp.Regexp, error) {
+	if !isRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if wholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if ignoreCase {
+		pattern = `(?i)` + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// searchTree searches the file or directory at start, skipping ignored
+// files unless opts.includeIgnored is set
+func searchTree(rootDir, start string, re *regexp.Regexp, opts searchOptions) ([]searchMatch, searchStats) {
+	var matches []searchMatch
+	var stats searchStats
+
+	searchOne := func(p string) error {
+		found, capped := searchFile(p, re, opts, &stats)
+		if capped {
+			stats.fileCaps++
+		}
+		for _, m := range found {
+			if len(matches) == opts.limit {
+				stats.truncated = true
+				return errSearchLimit
+			}
+			matches = append(matches, m)
+		}
+		return nil
+	}
+
+	info, err := os.Stat(start)
+	if err != nil {
+		return nil, stats
+	}
+	if !info.IsDir() {
+		searchOne(start)
+		return matches, stats
+	}
+
+	ignore := newIgnoreMatcher()
+	if !opts.includeIgnored {
+		ignore.loadParents(rootDir, start)
+	}
+	filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != start && !opts.includeIgnored {
+				if ignore.ignored(p, true) {
+					return filepath.SkipDir
+				}
+				ignore.load(p)
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || (!opts.includeIgnored && ignore.ignored(p, false)) {
+			return nil
+		}
+		return searchOne(p)
+	})
+	return matches, stats
+}
+
+// searchFile returns the matches in one file, at most opts.maxPerFile, and
+// whether more were left. Binary and oversized files are skipped.
+func searchFile(p string, re *regexp.Regexp, opts searchOptions, stats *searchStats) ([]searchMatch, bool) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, false
+	}
+	if info.Size() > opts.maxBytes {
+		stats.tooLarge++
+		return nil, false
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, sniffLen)
+	head, _ := reader.Peek(sniffLen)
+	if isBinary(head) {
+		stats.binary++
+		return nil, false
+	}
+	stats.files++
+
+	var matches []searchMatch
+	var recent []string // The last opts.before lines
+	pendingAfter := 0   // Lines still owed to the last match's after context
+	lastShown := 0      // Last line number already part of a result
+	lineNum := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if line == "" && err != nil {
+			break
+		}
+		lineNum++
+		line = trimLineEnding(line)
+
+		switch {
+		case re.MatchString(line):
+			if len(matches) == opts.maxPerFile {
+				return matches, true
+			}
+			m := searchMatch{file: p, line: lineNum, text: line}
+			// Context lines already shown with the previous match are not
+			// repeated
+			if n := min(len(recent), lineNum-1-lastShown); n > 0 {
+				m.before = append([]string(nil), recent[len(recent)-n:]...)
+			}
+			matches = append(matches, m)
+			pendingAfter = opts.after
+			lastShown = lineNum
+		case pendingAfter > 0:
+			last := &matches[len(matches)-1]
+			last.after = append(last.after, line)
+			pendingAfter--
+			lastShown = lineNum
+		}
+
+		if opts.before > 0 {
+			recent = append(recent, line)
+			if len(recent) > opts.before {
+				recent = recent[1:]
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return matches, false
+}
+
+// trimLineEnding strips the line ending and cuts overlong lines
+func trimLineEnding(line string) string {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+	}
+	lines := []string{line}
+	truncateLongLines(lines)
+	return lines[0]
+}
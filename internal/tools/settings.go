@@ -0,0 +1,65 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+// Settings decide what the tools of a registry may do: how much they read,
+// where they work, which shell they run and which commands and hosts they
+// may use. Each registry has its own, so programs that embed several agents
+// can configure them apart.
+type Settings struct {
+	maxReadBytes atomic.Int64
+	sandbox      sandboxSettings
+	shell        shellSettings
+	commands     commandPolicy
+	web          webPolicy
+	webCache     webCacheSettings
+}
+
+// NewSettings returns the default settings: the sandbox on, the default
+// blocked commands and hosts, and no web cache
+func NewSettings() *Settings {
+	s := &Settings{}
+	s.sandbox.enabled = true
+	s.SetCommandPolicy(nil, nil)
+	s.SetWebPolicy(config.WebConfig{})
+	return s
+}
+
+// ApplySettings configures the registry from settings: the system prompt,
+// the tool settings and the custom tools. Settings that are invalid are
+// skipped and reported in the error, one "key: reason" per problem.
+// tools.disabled is left to SetDisabled, which needs every tool, those of
+// MCP servers included, to be registered first.
+func (r *Registry) ApplySettings(cfg *config.Config) error {
+	r.SetSystemPrompt(cfg.General.SystemPrompt)
+	s := r.settings
+	s.SetMaxReadBytes(cfg.Tools.MaxReadBytes)
+	s.SetShellDefaults(cfg.Tools.Shell.Dir, cfg.Tools.Shell.Env)
+	s.SetSandbox(cfg.Tools.Sandbox.Enabled, cfg.Tools.Sandbox.AllowedDirs)
+	cacheDir, _ := config.CacheDir(cfg)
+	s.SetWebCacheDir(cacheDir)
+
+	var errs []error
+	add := func(key string, err error) {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	add("tools.shell", s.SetCommandPolicy(cfg.Tools.Shell.AllowedCommands, cfg.Tools.Shell.BlockedCommands))
+	add("tools.web", s.SetWebPolicy(cfg.Tools.Web))
+	add("tools.custom", r.RegisterCustom(cfg.Tools.Custom))
+	return errors.Join(errs...)
+}
@@ -0,0 +1,57 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+func TestApplySettings(t *testing.T) {
+	registry := NewRegistry(t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Tools.Custom = []config.CustomToolConfig{{Name: "lint", Command: "make"}, {Name: "old", Command: "make"}}
+	if err := registry.ApplySettings(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reload replaces the custom tools and reports each problem with its key
+	cfg.Tools.Custom = []config.CustomToolConfig{{Name: "lint", Command: "make lint"}, {Name: "shell", Command: "sh"}}
+	cfg.Tools.Shell.AllowedCommands = []string{"/(/", "["}
+	cfg.Tools.Shell.BlockedCommands = []string{"go test*"}
+	err := registry.ApplySettings(cfg)
+	if _, ok := registry.Get("old"); ok {
+		t.Error("custom tool removed from settings is still registered")
+	}
+	if tool, ok := registry.Get("lint"); !ok || tool.(*CustomTool).cfg.Command != "make lint" {
+		t.Errorf("custom tool changed in settings: got %v", tool)
+	}
+	if tool, _ := registry.Get("shell"); tool == nil || tool.Name() != "shell" || isCustom(tool) {
+		t.Error("custom tool replaced the shell tool")
+	}
+	if decision, _ := registry.Settings().CheckCommand("go test ./..."); decision != DecisionBlock {
+		t.Errorf("command policy not applied: got %v", decision)
+	}
+
+	var lines []string
+	if err != nil {
+		lines = strings.Split(err.Error(), "\n")
+	}
+	want := []string{"tools.shell: ", "tools.shell: ", "tools.custom: "}
+	if len(lines) != len(want) {
+		t.Fatalf("ApplySettings error = %q, want %d problems", lines, len(want))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("problem %d = %q, want it to start with %q", i, lines[i], prefix)
+		}
+	}
+}
+
+func isCustom(t BuiltinTool) bool {
+	_, ok := t.(*CustomTool)
+	return ok
+}
@@ -8,23 +8,79 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
-// shellPath is the global shell path used for executing commands
-var shellPath string = ""
+// shellSettings are the shell commands run with (--shell), and the working
+// directory and environment variables from settings (tools.shell), used
+// when the model does not pass its own
+type shellSettings struct {
+	sync.Mutex
+	path string
+	dir  string
+	env  map[string]string
+}
 
 // SetShellPath sets the shell path for command execution
-func SetShellPath(path string) {
-	shellPath = path
+func (s *Settings) SetShellPath(path string) {
+	s.shell.Lock()
+	defer s.shell.Unlock()
+	s.shell.path = path
 }
 
 // GetShellPath returns the current shell path
-func GetShellPath() string {
-	return shellPath
+func (s *Settings) GetShellPath() string {
+	s.shell.Lock()
+	defer s.shell.Unlock()
+	return s.shell.path
+}
+
+// SetShellDefaults sets the working directory (relative to the workspace)
+// and extra environment variables of shell commands
+func (s *Settings) SetShellDefaults(dir string, env map[string]string) {
+	s.shell.Lock()
+	defer s.shell.Unlock()
+	s.shell.dir = dir
+	s.shell.env = env
+}
+
+// shellOptions is where and how a command runs
+type shellOptions struct {
+	dir   string   // Working directory; empty means the workspace root
+	env   []string // Added to the parent environment, later entries win
+	stdin string
+}
+
+// EnvArg returns the env argument of a shell call as sorted KEY=VALUE
+// pairs, or nil if it is not set
+func EnvArg(args map[string]interface{}) ([]string, error) {
+	raw, ok := args["env"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("env must be an object of variable names to values")
+	}
+	env := make([]string, 0, len(m))
+	for name, value := range m {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return nil, fmt.Errorf("invalid environment variable name %q", name)
+		}
+		if value == nil {
+			value = ""
+		}
+		env = append(env, name+"="+fmt.Sprint(value))
+	}
+	sort.Strings(env)
+	return env, nil
 }
 
 // =============================================================================
@@ -33,13 +89,15 @@ func GetShellPath() string {
 
 // ShellTool executes shell commands
 type ShellTool struct {
-	rootDir string
+	rootDir  string
+	settings *Settings
+	jobs     *Jobs // Background jobs; nil disables the background option
 }
 
 func (t *ShellTool) Name() string        { return "shell" }
 func (t *ShellTool) DisplayName() string { return "Shell" }
 func (t *ShellTool) Description() string {
-	return "Execute a shell command and return its output. Use this for running system commands, scripts, or CLI tools. Be cautious with destructive commands."
+	return "Execute a shell command and return its output. Use this for running system commands, scripts, or CLI tools. Be cautious with destructive commands. For long-running commands such as dev servers or watchers, set background: the command keeps running and you get a job ID to use with check_job_output and kill_job."
 }
 
 func (t *ShellTool) Parameters() json.RawMessage {
@@ -52,7 +110,28 @@ func (t *ShellTool) Parameters() json.RawMessage {
 			},
 			"timeout": {
 				"type": "integer",
-				"description": "Timeout in seconds (default: 60, max: 300)"
+				"description": "Timeout in seconds (default: 60, max: 300). Ignored for background commands."
+			},
+			"background": {
+				"type": "boolean",
+				"description": "Run the command in the background and return a job ID immediately instead of waiting for it to finish"
+			},
+			"pty": {
+				"type": "boolean",
+				"description": "Run the command in a pseudo-terminal, for commands that need a TTY (interactive prompts, sudo, pagers). The user can take over the terminal to answer prompts. Output is returned as plain text."
+			},
+			"cwd": {
+				"type": "string",
+				"description": "Working directory, absolute or relative to the workspace root (default: the workspace root). In sandbox mode it must be inside the workspace or an allowed directory."
+			},
+			"env": {
+				"type": "object",
+				"description": "Extra environment variables, e.g. {\"NODE_ENV\": \"test\"}. The rest of the environment is inherited.",
+				"additionalProperties": {"type": "string"}
+			},
+			"stdin": {
+				"type": "string",
+				"description": "Text passed to the command's standard input. Not available with pty."
 			}
 		},
 		"required": ["command"]
@@ -67,6 +146,9 @@ func (t *ShellTool) Execute(args map[string]interface{}) (map[string]interface{}
 	if !ok || strings.TrimSpace(command) == "" {
 		return map[string]interface{}{"error": "command is required and cannot be empty"}, nil
 	}
+	if decision, reason := t.settings.CheckCommand(command); decision == DecisionBlock {
+		return map[string]interface{}{"error": BlockedError(reason)}, nil
+	}
 
 	// Get timeout (default 60 seconds, max 300 seconds)
 	timeout := 60
@@ -80,43 +162,44 @@ func (t *ShellTool) Execute(args map[string]interface{}) (map[string]interface{}
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer cancel()
-
-	var cmd *exec.Cmd
-	// Use custom shell path if set, otherwise use defaults
-	if shellPath != "" {
-		if strings.Contains(shellPath, "bash") {
-			cmd = exec.CommandContext(ctx, shellPath, "-c", command)
-		} else if strings.Contains(shellPath, "powershell") || shellPath == "powershell" {
-			cmd = exec.CommandContext(ctx, shellPath, "-NoProfile", "-NonInteractive", "-Command", command)
-		} else {
-			// Generic shell
-			cmd = exec.CommandContext(ctx, shellPath, "-c", command)
-		}
-	} else if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
-	} else {
-		cmd = exec.CommandContext(ctx, "bash", "-c", command)
+	opts, err := t.options(args)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
 	}
 
-	// Set working directory
-	if t.rootDir != "" {
-		cmd.Dir = t.rootDir
+	background, _ := args["background"].(bool)
+	usePTY, _ := args["pty"].(bool)
+	switch {
+	case background && usePTY:
+		return map[string]interface{}{"error": "background and pty cannot be combined"}, nil
+	case usePTY && opts.stdin != "":
+		return map[string]interface{}{"error": "stdin cannot be combined with pty; take over the terminal to type input instead"}, nil
+	case background:
+		return t.startJob(command, opts)
+	case usePTY:
+		return t.runPTY(command, timeout, opts)
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmd := t.command(ctx, command, opts)
+
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	startTime := time.Now()
-	err := cmd.Run()
+	err = cmd.Run()
 	duration := time.Since(startTime)
 
 	result := map[string]interface{}{
 		"command":     command,
 		"duration_ms": duration.Milliseconds(),
 	}
+	if opts.dir != "" {
+		result["cwd"] = opts.dir
+	}
 
 	stdoutStr := stdout.String()
 	stderrStr := stderr.String()
@@ -132,6 +215,8 @@ func (t *ShellTool) Execute(args map[string]interface{}) (map[string]interface{}
 
 	result["stdout"] = stdoutStr
 	result["stderr"] = stderrStr
+	result[KeyMediaType] = MediaText
+	result[KeyBody] = "stdout"
 
 	if ctx.Err() == context.DeadlineExceeded {
 		result["error"] = fmt.Sprintf("command timed out after %d seconds", timeout)
@@ -149,10 +234,110 @@ func (t *ShellTool) Execute(args map[string]interface{}) (map[string]interface{}
 	} else {
 		result["exit_code"] = 0
 	}
+	result[KeySummary] = fmt.Sprintf("exit %v in %dms", result["exit_code"], duration.Milliseconds())
 
 	return result, nil
 }
 
+// options resolves the working directory, environment and stdin of a call,
+// falling back to the settings
+func (t *ShellTool) options(args map[string]interface{}) (shellOptions, error) {
+	t.settings.shell.Lock()
+	dir, defaultEnv := t.settings.shell.dir, t.settings.shell.env
+	t.settings.shell.Unlock()
+
+	var opts shellOptions
+	fromCall := false
+	if cwd, ok := args["cwd"].(string); ok && strings.TrimSpace(cwd) != "" {
+		dir, fromCall = cwd, true
+	}
+	if dir != "" {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(t.rootDir, dir)
+		}
+		// The model's cwd stays in the sandbox like the file tools' paths;
+		// tools.shell.dir is the user's choice
+		if fromCall {
+			if err := t.settings.checkSandbox(t.rootDir, filepath.Clean(dir)); err != nil {
+				return opts, fmt.Errorf("invalid cwd: %v", err)
+			}
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			return opts, fmt.Errorf("invalid cwd: %v", err)
+		}
+		if !info.IsDir() {
+			return opts, fmt.Errorf("invalid cwd: %s is not a directory", dir)
+		}
+		opts.dir = dir
+	}
+
+	for name, value := range defaultEnv {
+		opts.env = append(opts.env, name+"="+value)
+	}
+	sort.Strings(opts.env)
+	env, err := EnvArg(args)
+	if err != nil {
+		return opts, err
+	}
+	opts.env = append(opts.env, env...)
+
+	opts.stdin, _ = args["stdin"].(string)
+	return opts, nil
+}
+
+// command builds the command line for the configured shell
+func (t *ShellTool) command(ctx context.Context, command string, opts shellOptions) *exec.Cmd {
+	var cmd *exec.Cmd
+	// Use custom shell path if set, otherwise use defaults
+	if shellPath := t.settings.GetShellPath(); shellPath != "" {
+		if strings.Contains(shellPath, "bash") {
+			cmd = exec.CommandContext(ctx, shellPath, "-c", command)
+		} else if strings.Contains(shellPath, "powershell") || shellPath == "powershell" {
+			cmd = exec.CommandContext(ctx, shellPath, "-NoProfile", "-NonInteractive", "-Command", command)
+		} else {
+			// Generic shell
+			cmd = exec.CommandContext(ctx, shellPath, "-c", command)
+		}
+	} else if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "bash", "-c", command)
+	}
+
+	// Set working directory
+	if opts.dir != "" {
+		cmd.Dir = opts.dir
+	} else if t.rootDir != "" {
+		cmd.Dir = t.rootDir
+	}
+	if len(opts.env) > 0 {
+		cmd.Env = append(os.Environ(), opts.env...)
+	}
+	if opts.stdin != "" {
+		cmd.Stdin = strings.NewReader(opts.stdin)
+	}
+	return cmd
+}
+
+// startJob runs a command in the background
+func (t *ShellTool) startJob(command string, opts shellOptions) (map[string]interface{}, error) {
+	if t.jobs == nil {
+		return map[string]interface{}{"error": "background commands are not available here"}, nil
+	}
+	job, err := t.jobs.Start(command, t.command(context.Background(), command, opts))
+	if err != nil {
+		return map[string]interface{}{"error": fmt.Sprintf("failed to start command: %v", err)}, nil
+	}
+	return map[string]interface{}{
+		"job_id":   job.ID,
+		"pid":      job.PID,
+		"command":  command,
+		"message":  fmt.Sprintf("Started in the background as job %d. Use check_job_output to read its output and kill_job to stop it.", job.ID),
+		KeySummary: fmt.Sprintf("job %d started (pid %d)", job.ID, job.PID),
+	}, nil
+}
+
 func (t *ShellTool) SetRootDir(dir string) {
 	t.rootDir = dir
 }
@@ -0,0 +1,104 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/mattn/go-runewidth"
+)
+
+// ToolStat counts the calls of one tool
+type ToolStat struct {
+	Calls     int
+	Failures  int
+	Streak    int    // Failures since the last success
+	LastError string // Error of the most recent failure
+}
+
+// Stats counts tool calls and failures during a chat session. It is safe
+// for concurrent use.
+type Stats struct {
+	mu    sync.Mutex
+	tools map[string]*ToolStat
+}
+
+// NewStats creates an empty Stats
+func NewStats() *Stats {
+	return &Stats{tools: make(map[string]*ToolStat)}
+}
+
+// Record counts a tool execution; results with an "error" field are failures
+func (s *Stats) Record(name string, result map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.tools[name]
+	if !ok {
+		st = &ToolStat{}
+		s.tools[name] = st
+	}
+	st.Calls++
+	if errMsg, _ := result["error"].(string); errMsg != "" {
+		st.Failures++
+		st.Streak++
+		st.LastError = errMsg
+	} else {
+		st.Streak = 0
+	}
+}
+
+// Snapshot returns a copy of the counts by tool name
+func (s *Stats) Snapshot() map[string]ToolStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]ToolStat, len(s.tools))
+	for name, st := range s.tools {
+		out[name] = *st
+	}
+	return out
+}
+
+// FailureNote returns a short note about tools whose latest calls failed,
+// or "" if there are none. It is sent to the model as system context so it
+// stops retrying tools that cannot work in this environment.
+func (s *Stats) FailureNote() string {
+	stats := s.Snapshot()
+	names := make([]string, 0, len(stats))
+	for name, st := range stats {
+		if st.Streak > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Tool failures earlier in this session:\n")
+	for _, name := range names {
+		st := stats[name]
+		lastErr := runewidth.Truncate(strings.Join(strings.Fields(st.LastError), " "), 120, "...")
+		fmt.Fprintf(&b, "- %s failed %d time(s) in a row, %d of %d calls overall (last error: %s)\n",
+			name, st.Streak, st.Failures, st.Calls, lastErr)
+	}
+	b.WriteString("Do not retry a tool that keeps failing for the same reason; try another approach or tell the user.")
+	return b.String()
+}
+
+// FailureInstruction wraps FailureNote as a system instruction, or returns
+// nil if there is nothing to report
+func (s *Stats) FailureInstruction() *api.Content {
+	note := s.FailureNote()
+	if note == "" {
+		return nil
+	}
+	return &api.Content{Role: "user", Parts: []api.Part{{Text: note}}}
+}
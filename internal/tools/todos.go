@@ -0,0 +1,170 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Statuses of a todo item
+const (
+	TodoPending    = "pending"
+	TodoInProgress = "in_progress"
+	TodoCompleted  = "completed"
+	TodoCancelled  = "cancelled"
+)
+
+// Todo is an item of the task list kept by write_todos
+type Todo struct {
+	Description string `json:"description"`
+	Status      string `json:"status"`
+}
+
+// =============================================================================
+// WriteTodosTool - Track multi-step work
+// =============================================================================
+
+// WriteTodosTool keeps the model's task list for multi-step work. Each call
+// replaces the whole list; the UI shows it as a checklist.
+type WriteTodosTool struct {
+	mu    sync.Mutex
+	todos []Todo
+}
+
+func (t *WriteTodosTool) Name() string        { return "write_todos" }
+func (t *WriteTodosTool) DisplayName() string { return "WriteTodos" }
+func (t *WriteTodosTool) Description() string {
+	return "Create or update the task list for the current request. Use it for complex work that takes three or more steps: write the plan first, then call it again whenever a step starts, finishes or turns out to be unnecessary. Each call replaces the whole list, so always send every item. Keep exactly one item in_progress while working. Do not use it for simple one-step requests."
+}
+
+func (t *WriteTodosTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"todos": {
+				"type": "array",
+				"description": "The complete task list, in order",
+				"items": {
+					"type": "object",
+					"properties": {
+						"description": {
+							"type": "string",
+							"description": "What the step does, in a few words"
+						},
+						"status": {
+							"type": "string",
+							"enum": ["pending", "in_progress", "completed", "cancelled"],
+							"description": "Current state of the step"
+						}
+					},
+					"required": ["description", "status"]
+				}
+			}
+		},
+		"required": ["todos"]
+	}`)
+}
+
+func (t *WriteTodosTool) RequiresConfirmation() bool { return false }
+func (t *WriteTodosTool) ConfirmationType() string   { return "" }
+
+func (t *WriteTodosTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
+	todos, err := parseTodos(args["todos"])
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}, nil
+	}
+
+	t.mu.Lock()
+	t.todos = todos
+	t.mu.Unlock()
+
+	return map[string]interface{}{
+		"success":  true,
+		"message":  "Task list updated",
+		"todos":    todos,
+		KeySummary: TodoSummary(todos),
+	}, nil
+}
+
+// Todos returns the current task list
+func (t *WriteTodosTool) Todos() []Todo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Todo(nil), t.todos...)
+}
+
+// parseTodos validates the todos argument
+func parseTodos(v interface{}) ([]Todo, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("todos must be an array")
+	}
+	todos := make([]Todo, 0, len(items))
+	inProgress := 0
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("todos[%d] must be an object", i)
+		}
+		desc, _ := m["description"].(string)
+		desc = strings.TrimSpace(desc)
+		if desc == "" {
+			return nil, fmt.Errorf("todos[%d].description is required", i)
+		}
+		status, _ := m["status"].(string)
+		switch status {
+		case TodoPending, TodoCompleted, TodoCancelled:
+		case TodoInProgress:
+			inProgress++
+		default:
+			return nil, fmt.Errorf("todos[%d].status must be pending, in_progress, completed or cancelled", i)
+		}
+		todos = append(todos, Todo{Description: desc, Status: status})
+	}
+	if inProgress > 1 {
+		return nil, fmt.Errorf("only one item can be in_progress at a time (got %d)", inProgress)
+	}
+	return todos, nil
+}
+
+// TodoSummary describes the progress of a task list in one line
+func TodoSummary(todos []Todo) string {
+	if len(todos) == 0 {
+		return "Task list cleared"
+	}
+	done, total := 0, 0
+	current := ""
+	for _, todo := range todos {
+		switch todo.Status {
+		case TodoCancelled:
+			continue
+		case TodoCompleted:
+			done++
+		case TodoInProgress:
+			current = todo.Description
+		}
+		total++
+	}
+	summary := fmt.Sprintf("%d/%d done", done, total)
+	if current != "" {
+		summary += ", now: " + current
+	}
+	return summary
+}
+
+// TodoMark returns the checklist mark of a todo status
+func TodoMark(status string) string {
+	switch status {
+	case TodoCompleted:
+		return "✓"
+	case TodoInProgress:
+		return "◐"
+	case TodoCancelled:
+		return "✗"
+	}
+	return "○"
+}
@@ -0,0 +1,117 @@
+// Package tools provides built-in tool implementations for the Gemini CLI.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxUserCommandOutput caps the output of a !command kept for the chat and
+// the model, like the output of the shell tool
+const maxUserCommandOutput = 50000
+
+// UserCommand is a shell command the user ran from the chat with !command
+type UserCommand struct {
+	Command   string
+	Dir       string
+	Output    string // Combined stdout and stderr, capped
+	Truncated bool   // Output was cut at the cap
+	ExitCode  int
+	Duration  time.Duration
+}
+
+// RunUserCommand runs a command typed in the chat in dir with the shell
+// configured for the registry, copying its output to out as it arrives. Unlike the
+// shell tool, it is not confirmed or checked against the command policy:
+// those restrict the model, and the user typed this command. It has no
+// timeout and is killed when ctx is cancelled.
+func (r *Registry) RunUserCommand(ctx context.Context, command, dir string, out io.Writer) (*UserCommand, error) {
+	shell := &ShellTool{rootDir: dir, settings: r.settings}
+	opts, err := shell.options(nil)
+	if err != nil {
+		return nil, err
+	}
+	opts.dir = "" // The chat's directory, not tools.shell.dir
+	cmd := shell.command(ctx, command, opts)
+
+	capture := &capWriter{max: maxUserCommandOutput}
+	w := io.MultiWriter(capture, out)
+	var mu sync.Mutex // stdout and stderr are written concurrently
+	cmd.Stdout = lockedWriter{&mu, w}
+	cmd.Stderr = lockedWriter{&mu, w}
+
+	start := time.Now()
+	err = cmd.Run()
+	result := &UserCommand{
+		Command:   command,
+		Dir:       dir,
+		Output:    capture.String(),
+		Truncated: capture.truncated,
+		Duration:  time.Since(start),
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return nil, err
+	}
+	return result, nil
+}
+
+// Summary describes how the command ended
+func (c *UserCommand) Summary() string {
+	return fmt.Sprintf("exit %d in %s", c.ExitCode, c.Duration.Round(time.Millisecond))
+}
+
+// ContextText formats the command and its output as prompt context
+func (c *UserCommand) ContextText() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "I ran a shell command (in %s); it exited with code %d:\n", c.Dir, c.ExitCode)
+	fmt.Fprintf(&b, "```console\n$ %s\n", c.Command)
+	if c.Output != "" {
+		b.WriteString(strings.TrimRight(c.Output, "\n"))
+		b.WriteString("\n")
+	}
+	if c.Truncated {
+		b.WriteString("[Output truncated...]\n")
+	}
+	b.WriteString("```")
+	return b.String()
+}
+
+// capWriter keeps the first max bytes written to it
+type capWriter struct {
+	strings.Builder
+	max       int
+	truncated bool
+}
+
+func (w *capWriter) Write(p []byte) (int, error) {
+	if room := w.max - w.Len(); room < len(p) {
+		w.truncated = true
+		w.Builder.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return w.Builder.Write(p)
+}
+
+// lockedWriter serializes writes to w
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
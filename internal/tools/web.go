@@ -4,13 +4,13 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strings"
 	"time"
 
@@ -22,7 +22,9 @@ import (
 // =============================================================================
 
 // WebSearchTool performs web searches using DuckDuckGo
-type WebSearchTool struct{}
+type WebSearchTool struct {
+	settings *Settings
+}
 
 func (t *WebSearchTool) Name() string        { return "web_search" }
 func (t *WebSearchTool) DisplayName() string { return "GoogleSearch" }
@@ -45,6 +47,7 @@ func (t *WebSearchTool) Parameters() json.RawMessage {
 
 func (t *WebSearchTool) RequiresConfirmation() bool { return false }
 func (t *WebSearchTool) ConfirmationType() string   { return "" }
+func (t *WebSearchTool) ReadOnly() bool             { return true }
 
 func (t *WebSearchTool) Execute(args map[string]interface{}) (map[string]interface{}, error) {
 	query, ok := args["query"].(string)
@@ -58,9 +61,13 @@ func (t *WebSearchTool) Execute(args map[string]interface{}) (map[string]interfa
 	}
 
 	return map[string]interface{}{
-		"query":   query,
-		"results": results,
-		"count":   len(results),
+		"query":      query,
+		"results":    results,
+		"count":      len(results),
+		"media_type": MediaTable,
+		"body":       "results",
+		"columns":    []string{"title", "url"},
+		"summary":    fmt.Sprintf("%d results", len(results)),
 	}, nil
 }
 
@@ -69,6 +76,9 @@ func (t *WebSearchTool) searchDuckDuckGo(query string) ([]map[string]interface{}
 	defer cancel()
 
 	searchURL := fmt.Sprintf("https://html.duckduckgo.com/html/?q=%s", url.QueryEscape(query))
+	if err := t.settings.checkURL(&url.URL{Host: "html.duckduckgo.com"}); err != nil {
+		return nil, err
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
@@ -76,8 +86,7 @@ func (t *WebSearchTool) searchDuckDuckGo(query string) ([]map[string]interface{}
 	}
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := t.settings.webClient(10 * time.Second).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -107,6 +116,11 @@ func (t *WebSearchTool) searchDuckDuckGo(query string) ([]map[string]interface{}
 			}
 		}
 
+		// Leave out results the model would not be allowed to fetch
+		if u, err := url.Parse(link); err != nil || t.settings.checkURL(u) != nil {
+			return
+		}
+
 		if title != "" && link != "" {
 			results = append(results, map[string]interface{}{
 				"title":   strings.TrimSpace(title),
@@ -128,12 +142,23 @@ func (t *WebSearchTool) searchDuckDuckGo(query string) ([]map[string]interface{}
 // =============================================================================
 
 // WebFetchTool fetches and extracts content from web pages
-type WebFetchTool struct{}
+type WebFetchTool struct {
+	settings *Settings
+}
+
+const (
+	// maxFetchPages caps the max_pages argument
+	maxFetchPages = 5
+	// maxPageContent caps the content extracted from one page
+	maxPageContent = 50000
+	// maxRawContent caps non-HTML responses
+	maxRawContent = 100000
+)
 
 func (t *WebFetchTool) Name() string        { return "web_fetch" }
 func (t *WebFetchTool) DisplayName() string { return "WebFetch" }
 func (t *WebFetchTool) Description() string {
-	return "Fetch and extract the main content from a URL. Use this to read web pages, documentation, or articles."
+	return "Fetch a URL and return its main content as markdown, without navigation, ads or comments. Use this to read web pages, documentation, or articles. Set max_pages to follow \"next page\" links of paginated pages. Image URLs (PNG, JPEG, GIF, WebP) are returned as images you can examine. Pages are cached for a few minutes, so fetching the same URL again is cheap."
 }
 
 func (t *WebFetchTool) Parameters() json.RawMessage {
@@ -146,7 +171,11 @@ func (t *WebFetchTool) Parameters() json.RawMessage {
 			},
 			"selector": {
 				"type": "string",
-				"description": "Optional CSS selector to extract specific content"
+				"description": "Optional CSS selector to extract specific content instead of the main content"
+			},
+			"max_pages": {
+				"type": "integer",
+				"description": "How many pages of a paginated page to fetch by following its next page links (default 1, at most 5)"
 			}
 		},
 		"required": ["url"]
@@ -172,120 +201,197 @@ func (t *WebFetchTool) Execute(args map[string]interface{}) (map[string]interfac
 		urlStr = strings.Replace(urlStr, "github.com", "raw.githubusercontent.com", 1)
 		urlStr = strings.Replace(urlStr, "/blob/", "/", 1)
 	}
-
-	selector, _ := args["selector"].(string)
-
-	content, title, err := t.fetchURL(urlStr, selector)
+	if parsedURL, err = url.Parse(urlStr); err == nil {
+		err = t.settings.checkURL(parsedURL)
+	}
 	if err != nil {
-		return map[string]interface{}{"error": fmt.Sprintf("failed to fetch URL: %v", err)}, nil
+		return map[string]interface{}{"error": err.Error()}, nil
 	}
 
-	return map[string]interface{}{
-		"url":     urlStr,
-		"title":   title,
-		"content": content,
-	}, nil
-}
-
-func (t *WebFetchTool) fetchURL(urlStr, selector string) (string, string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
-	if err != nil {
-		return "", "", err
+	selector, _ := args["selector"].(string)
+	maxPages, ok := intArg(args, "max_pages")
+	if !ok || maxPages < 1 {
+		maxPages = 1
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	maxPages = min(maxPages, maxFetchPages)
+
+	var pages []string
+	var title string
+	cached := true
+	next := urlStr
+	visited := map[string]bool{}
+	for len(pages) < maxPages && next != "" && !visited[next] {
+		visited[next] = true
+		page, err := t.fetchPage(next)
+		if err != nil {
+			if len(pages) > 0 {
+				// Keep the pages fetched so far
+				break
+			}
+			return map[string]interface{}{"error": fmt.Sprintf("failed to fetch URL: %v", err)}, nil
+		}
+		cached = cached && page.cached
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= 10 {
-				return fmt.Errorf("too many redirects")
+		if len(pages) == 0 && isImageType(page.ContentType) {
+			result := imageResult(page.Body, urlStr)
+			result["cached"] = page.cached
+			return result, nil
+		}
+		if !strings.Contains(page.ContentType, "text/html") {
+			// Other content is returned as is
+			body := page.Body
+			if len(pages) > 0 {
+				break
 			}
-			return nil
-		},
-	}
+			if len(body) > maxRawContent {
+				body = body[:maxRawContent]
+			}
+			return map[string]interface{}{
+				"url":        urlStr,
+				"content":    string(body),
+				"cached":     page.cached,
+				"media_type": MediaText,
+				"summary":    urlStr,
+			}, nil
+		}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", "", err
+		content, pageTitle, nextURL, err := t.extract(page, selector)
+		if err != nil {
+			return map[string]interface{}{"error": fmt.Sprintf("failed to parse page: %v", err)}, nil
+		}
+		if title == "" {
+			title = pageTitle
+		}
+		if len(pages) > 0 {
+			content = fmt.Sprintf("--- Page %d (%s) ---\n\n%s", len(pages)+1, next, content)
+		}
+		pages = append(pages, content)
+		next = nextURL
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	summary := title
+	if title == "" {
+		summary = urlStr
 	}
-
-	contentType := resp.Header.Get("Content-Type")
-
-	// For non-HTML content, return raw text
-	if !strings.Contains(contentType, "text/html") {
-		body, err := io.ReadAll(io.LimitReader(resp.Body, 100000))
-		if err != nil {
-			return "", "", err
-		}
-		return string(body), "", nil
+	if len(pages) > 1 {
+		summary = fmt.Sprintf("%s (%d pages)", summary, len(pages))
 	}
+	result := map[string]interface{}{
+		"url":        urlStr,
+		"title":      title,
+		"content":    strings.Join(pages, "\n\n"),
+		"cached":     cached,
+		"media_type": MediaMarkdown,
+		"summary":    summary,
+	}
+	if len(pages) > 1 {
+		result["pages"] = len(pages)
+	}
+	if next != "" && !visited[next] {
+		result["next_page"] = next
+	}
+	return result, nil
+}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+// extract returns the main content of an HTML page as markdown, its title
+// and the URL of its next page
+func (t *WebFetchTool) extract(page *fetchedPage, selector string) (string, string, string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page.Body))
 	if err != nil {
-		return "", "", err
+		return "", "", "", err
+	}
+	base, err := url.Parse(page.FinalURL)
+	if err != nil {
+		return "", "", "", err
 	}
 
-	title := doc.Find("title").First().Text()
-
-	// Remove unwanted elements
-	doc.Find("script, style, nav, footer, header, aside, .sidebar, .nav, .menu, .ads").Remove()
+	title := pageTitle(doc)
+	next := nextPage(doc, base)
+	prepareDocument(doc)
 
 	var content string
 	if selector != "" {
-		content = t.extractText(doc.Find(selector))
+		content = toMarkdown(doc.Find(selector), base)
 	} else {
-		// Try common content selectors
-		for _, sel := range []string{"article", "main", "[role=main]", ".content", ".post-content", "#content"} {
-			if text := t.extractText(doc.Find(sel)); text != "" {
-				content = text
-				break
-			}
-		}
-		if content == "" {
-			content = t.extractText(doc.Find("body"))
-		}
+		content = toMarkdown(mainContent(doc), base)
 	}
 
 	// Truncate if too long
-	if len(content) > 50000 {
-		content = content[:50000] + "\n\n[Content truncated...]"
+	if len(content) > maxPageContent {
+		content = content[:maxPageContent] + "\n\n[Content truncated...]"
 	}
+	return content, title, next, nil
+}
 
-	return strings.TrimSpace(content), strings.TrimSpace(title), nil
+// fetchedPage is a response, from the network or the cache
+type fetchedPage struct {
+	*cachedPage
+	cached bool // Served from the cache, possibly after revalidation
 }
 
-func (t *WebFetchTool) extractText(s *goquery.Selection) string {
-	var lines []string
-	s.Find("p, h1, h2, h3, h4, h5, h6, li, pre, code, blockquote").Each(func(i int, el *goquery.Selection) {
-		text := strings.TrimSpace(el.Text())
-		if text != "" {
-			nodeName := goquery.NodeName(el)
-			switch nodeName {
-			case "h1":
-				text = "# " + text
-			case "h2":
-				text = "## " + text
-			case "h3":
-				text = "### " + text
-			case "li":
-				text = "- " + text
-			case "pre", "code":
-				text = "```\n" + text + "\n```"
-			}
-			lines = append(lines, text)
+// fetchPage fetches a URL. Pages fetched recently are served from the
+// cache; older cached pages are revalidated with their ETag or
+// modification time.
+func (t *WebFetchTool) fetchPage(urlStr string) (*fetchedPage, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.settings.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	entry := t.settings.loadCachedPage(urlStr)
+	if entry != nil && entry.fresh() {
+		return &fetchedPage{cachedPage: entry, cached: true}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
 		}
-	})
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
 
-	result := strings.Join(lines, "\n\n")
-	re := regexp.MustCompile(`\n{3,}`)
-	return re.ReplaceAllString(result, "\n\n")
+	resp, err := t.settings.webClient(30 * time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		entry.FetchedAt = time.Now()
+		t.settings.saveCachedPage(entry)
+		return &fetchedPage{cachedPage: entry, cached: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxImageFileBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	entry = &cachedPage{
+		URL:          urlStr,
+		FinalURL:     resp.Request.URL.String(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		Body:         body,
+		FetchedAt:    time.Now(),
+	}
+	t.settings.saveCachedPage(entry)
+	return &fetchedPage{cachedPage: entry}, nil
 }
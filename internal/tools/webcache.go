@@ -0,0 +1,103 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// webCacheFresh is how long a fetched page is reused without asking
+	// the server; older pages are revalidated with their ETag
+	webCacheFresh = 15 * time.Minute
+	// webCacheMaxBody is the largest response kept in the cache
+	webCacheMaxBody = 5 << 20
+)
+
+// webCacheSettings hold where web_fetch caches pages
+type webCacheSettings struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+// SetWebCacheDir sets the directory web_fetch caches pages in. An empty
+// dir turns the cache off.
+func (s *Settings) SetWebCacheDir(dir string) {
+	s.webCache.mu.Lock()
+	defer s.webCache.mu.Unlock()
+	if dir != "" {
+		dir = filepath.Join(dir, "web")
+	}
+	s.webCache.dir = dir
+}
+
+// cachedPage is a fetched response as stored in the cache
+type cachedPage struct {
+	URL          string    `json:"url"`      // The URL that was requested
+	FinalURL     string    `json:"finalUrl"` // The URL after redirects
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	ContentType  string    `json:"contentType"`
+	Body         []byte    `json:"body"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// fresh reports whether the page can be used without revalidating it
+func (p *cachedPage) fresh() bool {
+	return time.Since(p.FetchedAt) < webCacheFresh
+}
+
+// webCachePath returns the cache file for a URL, or "" if the cache is off
+func (s *Settings) webCachePath(urlStr string) string {
+	s.webCache.mu.RLock()
+	defer s.webCache.mu.RUnlock()
+	if s.webCache.dir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(urlStr))
+	return filepath.Join(s.webCache.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// loadCachedPage returns the cached response for a URL, or nil
+func (s *Settings) loadCachedPage(urlStr string) *cachedPage {
+	path := s.webCachePath(urlStr)
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var p cachedPage
+	if err := json.Unmarshal(data, &p); err != nil || p.URL != urlStr {
+		return nil
+	}
+	return &p
+}
+
+// saveCachedPage stores a response; failures only cost a later refetch
+func (s *Settings) saveCachedPage(p *cachedPage) {
+	path := s.webCachePath(p.URL)
+	if path == "" || len(p.Body) > webCacheMaxBody {
+		return
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, path)
+}
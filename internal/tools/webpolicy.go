@@ -0,0 +1,255 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+// DefaultBlockedHosts are link-local addresses and cloud metadata
+// endpoints, blocked unless tools.web.allowLinkLocal is set
+var DefaultBlockedHosts = []string{
+	"169.254.0.0/16",
+	"fe80::/10",
+	"fd00:ec2::254",   // AWS metadata over IPv6
+	"100.100.100.200", // Alibaba Cloud metadata
+	"metadata.google.internal",
+}
+
+// hostPattern is a domain, IP address or CIDR range from the web policy
+type hostPattern struct {
+	text       string
+	domain     string       // Set for domain patterns
+	subdomains bool         // "*.example.com": only subdomains match
+	prefix     netip.Prefix // Set for addresses and ranges
+}
+
+// matchHost reports whether the pattern matches a host name or address
+func (p hostPattern) matchHost(host string) bool {
+	if p.domain == "" {
+		addr, err := netip.ParseAddr(host)
+		return err == nil && p.prefix.Contains(addr.Unmap().WithZone(""))
+	}
+	if host == p.domain {
+		return !p.subdomains
+	}
+	return strings.HasSuffix(host, "."+p.domain)
+}
+
+// matchAddr reports whether an address pattern contains addr
+func (p hostPattern) matchAddr(addr netip.Addr) bool {
+	return p.domain == "" && p.prefix.Contains(addr)
+}
+
+// webPolicy holds the compiled tools.web settings
+type webPolicy struct {
+	sync.RWMutex
+	allowed      []hostPattern
+	blocked      []hostPattern
+	defaults     []hostPattern // DefaultBlockedHosts, unless allowed
+	blockPrivate bool
+}
+
+// SetWebPolicy sets the hosts web_fetch and web_search may contact.
+// Invalid entries are skipped and reported in the error.
+func (s *Settings) SetWebPolicy(cfg config.WebConfig) error {
+	var errs []error
+	compile := func(list []string) []hostPattern {
+		patterns := make([]hostPattern, 0, len(list))
+		for _, text := range list {
+			p, err := parseHostPattern(text)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if p.text != "" {
+				patterns = append(patterns, p)
+			}
+		}
+		return patterns
+	}
+	allowed := compile(cfg.AllowedDomains)
+	blocked := compile(cfg.BlockedDomains)
+	var defaults []hostPattern
+	if !cfg.AllowLinkLocal {
+		defaults = compile(DefaultBlockedHosts)
+	}
+
+	s.web.Lock()
+	s.web.allowed = allowed
+	s.web.blocked = blocked
+	s.web.defaults = defaults
+	s.web.blockPrivate = cfg.BlockPrivateNetworks
+	s.web.Unlock()
+	return errors.Join(errs...)
+}
+
+// parseHostPattern compiles a policy entry; blank entries yield a zero
+// pattern
+func parseHostPattern(text string) (hostPattern, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return hostPattern{}, nil
+	}
+	if prefix, err := netip.ParsePrefix(text); err == nil {
+		return hostPattern{text: text, prefix: prefix.Masked()}, nil
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(text, "[]")); err == nil {
+		addr = addr.Unmap()
+		return hostPattern{text: text, prefix: netip.PrefixFrom(addr, addr.BitLen())}, nil
+	}
+	p := hostPattern{text: text, domain: strings.TrimSuffix(text, ".")}
+	if rest, ok := strings.CutPrefix(p.domain, "*."); ok {
+		p.domain, p.subdomains = rest, true
+	}
+	if p.domain == "" || strings.ContainsAny(p.domain, "/:*?[] ") {
+		return hostPattern{}, fmt.Errorf("invalid domain pattern %q (use a domain, IP address or CIDR range)", text)
+	}
+	return p, nil
+}
+
+// checkURL applies the web policy to the host of a URL
+func (s *Settings) checkURL(u *url.URL) error {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return fmt.Errorf("url has no host")
+	}
+
+	s.web.RLock()
+	defer s.web.RUnlock()
+
+	for _, p := range s.web.blocked {
+		if p.matchHost(host) {
+			return fmt.Errorf("access to %s is blocked by tools.web.blockedDomains (matches %s)", host, p.text)
+		}
+	}
+	for _, p := range s.web.defaults {
+		if p.matchHost(host) {
+			return linkLocalError(host)
+		}
+	}
+	if len(s.web.allowed) > 0 {
+		allowed := false
+		for _, p := range s.web.allowed {
+			if p.matchHost(host) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("access to %s is not allowed: it is not in tools.web.allowedDomains", host)
+		}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return s.checkAddrLocked(host, addr.Unmap().WithZone(""))
+	}
+	return nil
+}
+
+// checkAddr applies the web policy to an address being connected to, which
+// catches host names that resolve to blocked addresses
+func (s *Settings) checkAddr(host string, addr netip.Addr) error {
+	s.web.RLock()
+	defer s.web.RUnlock()
+	return s.checkAddrLocked(host, addr.Unmap().WithZone(""))
+}
+
+// checkAddrLocked is checkAddr with the web policy held
+func (s *Settings) checkAddrLocked(host string, addr netip.Addr) error {
+	for _, p := range s.web.defaults {
+		if p.matchAddr(addr) {
+			return linkLocalError(host)
+		}
+	}
+	for _, p := range s.web.blocked {
+		if p.matchAddr(addr) {
+			return fmt.Errorf("access to %s is blocked by tools.web.blockedDomains (matches %s)", host, p.text)
+		}
+	}
+	if s.web.blockPrivate && (addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified()) {
+		return fmt.Errorf("access to %s is blocked: private network addresses are refused (tools.web.blockPrivateNetworks)", host)
+	}
+	return nil
+}
+
+// checkResolved applies the web policy to the addresses a host resolves
+// to. Hosts that do not resolve are refused, since they cannot be checked.
+func (s *Settings) checkResolved(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return s.checkAddr(host, addr)
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("cannot check %s against tools.web before sending it to the proxy: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if err := s.checkAddr(host, addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkLocalError is the error for link-local and metadata targets
+func linkLocalError(host string) error {
+	return fmt.Errorf("access to %s is blocked: link-local addresses and cloud metadata endpoints are refused unless tools.web.allowLinkLocal is set", host)
+}
+
+// webClient returns an HTTP client that enforces the web policy on every
+// connection and redirect
+func (s *Settings) webClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			return s.checkAddr(host, addr)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// Through a proxy, the dialer only sees the proxy's address and the
+	// proxy resolves the host, so the host's addresses are checked first
+	if proxy := transport.Proxy; proxy != nil {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			proxyURL, err := proxy(req)
+			if err != nil || proxyURL == nil {
+				return proxyURL, err
+			}
+			if err := s.checkResolved(req.Context(), req.URL.Hostname()); err != nil {
+				return nil, err
+			}
+			return proxyURL, nil
+		}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("too many redirects")
+			}
+			return s.checkURL(req.URL)
+		},
+	}
+}
@@ -0,0 +1,61 @@
+// Package tools provides built-in tools for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/linkalls/gmn/internal/config"
+)
+
+func TestCheckAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.WebConfig
+		addr    string
+		blocked bool
+	}{
+		{"public", config.WebConfig{}, "93.184.216.34", false},
+		{"metadata", config.WebConfig{}, "169.254.169.254", true},
+		{"metadata mapped to IPv6", config.WebConfig{}, "::ffff:169.254.169.254", true},
+		{"IPv6 link-local", config.WebConfig{}, "fe80::1", true},
+		{"IPv6 link-local with zone", config.WebConfig{}, "fe80::1%eth0", true},
+		{"AWS IPv6 metadata", config.WebConfig{}, "fd00:ec2::254", true},
+		{"link-local allowed", config.WebConfig{AllowLinkLocal: true}, "169.254.169.254", false},
+		{"loopback", config.WebConfig{}, "127.0.0.1", false},
+		{"loopback blocked", config.WebConfig{BlockPrivateNetworks: true}, "127.0.0.1", true},
+		{"private blocked", config.WebConfig{BlockPrivateNetworks: true}, "10.1.2.3", true},
+		{"private mapped blocked", config.WebConfig{BlockPrivateNetworks: true}, "::ffff:192.168.1.1", true},
+		{"unspecified blocked", config.WebConfig{BlockPrivateNetworks: true}, "0.0.0.0", true},
+		{"IPv6 loopback blocked", config.WebConfig{BlockPrivateNetworks: true}, "::1", true},
+		{"public with private blocked", config.WebConfig{BlockPrivateNetworks: true}, "8.8.8.8", false},
+		{"blocked range", config.WebConfig{BlockedDomains: []string{"203.0.113.0/24"}}, "203.0.113.7", true},
+		{"outside blocked range", config.WebConfig{BlockedDomains: []string{"203.0.113.0/24"}}, "203.0.114.7", false},
+		{"blocked address", config.WebConfig{BlockedDomains: []string{"2001:db8::1"}}, "2001:db8::1", true},
+		{"blocked domain does not match addresses", config.WebConfig{BlockedDomains: []string{"example.com"}}, "93.184.216.34", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := NewSettings()
+			if err := settings.SetWebPolicy(tt.cfg); err != nil {
+				t.Fatal(err)
+			}
+			err := settings.checkAddr("host.test", netip.MustParseAddr(tt.addr))
+			if (err != nil) != tt.blocked {
+				t.Errorf("checkAddr(%s) = %v, want blocked %v", tt.addr, err, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestSetWebPolicyInvalid(t *testing.T) {
+	settings := NewSettings()
+	if err := settings.SetWebPolicy(config.WebConfig{BlockedDomains: []string{"exa mple.com", "10.0.0.0/8"}}); err == nil {
+		t.Fatal("SetWebPolicy accepted an invalid domain")
+	}
+	if err := settings.checkAddr("host.test", netip.MustParseAddr("10.0.0.1")); err == nil {
+		t.Error("valid entry next to an invalid one was dropped")
+	}
+}
@@ -0,0 +1,148 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// String returns the name used for the activity type in exports
+func (t ActivityType) String() string {
+	switch t {
+	case ActivityTypeAPI:
+		return "api"
+	case ActivityTypeTool:
+		return "tool"
+	case ActivityTypeFile:
+		return "file"
+	case ActivityTypeSearch:
+		return "search"
+	case ActivityTypeShell:
+		return "shell"
+	case ActivityTypeThinking:
+		return "thinking"
+	}
+	return "unknown"
+}
+
+// String returns the name used for the activity status in exports
+func (s ActivityStatus) String() string {
+	switch s {
+	case ActivityStatusPending:
+		return "pending"
+	case ActivityStatusRunning:
+		return "running"
+	case ActivityStatusSuccess:
+		return "success"
+	case ActivityStatusError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// activityRecord is the JSON form of an activity
+type activityRecord struct {
+	Type       string    `json:"type"`
+	Title      string    `json:"title"`
+	Detail     string    `json:"detail,omitempty"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// ExportActivityLog renders an activity log as JSON or, when markdown is
+// set, as a Markdown table for postmortems
+func ExportActivityLog(items []ActivityItem, markdown bool) ([]byte, error) {
+	if !markdown {
+		records := make([]activityRecord, 0, len(items))
+		for _, item := range items {
+			records = append(records, activityRecord{
+				Type:       item.Type.String(),
+				Title:      item.Title,
+				Detail:     item.Detail,
+				Status:     item.Status.String(),
+				StartedAt:  item.Timestamp,
+				DurationMs: item.Duration.Milliseconds(),
+			})
+		}
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+
+	var b strings.Builder
+	var total time.Duration
+	failed := 0
+	for _, item := range items {
+		total += item.Duration
+		if item.Status == ActivityStatusError {
+			failed++
+		}
+	}
+	b.WriteString("# gmn activity log\n\n")
+	if len(items) > 0 {
+		fmt.Fprintf(&b, "%d activities (%d failed) from %s to %s, %s busy in total\n\n",
+			len(items), failed,
+			items[0].Timestamp.Format(time.DateTime), items[len(items)-1].Timestamp.Format(time.DateTime),
+			total.Round(time.Millisecond))
+	}
+	b.WriteString("| # | Started | Type | Activity | Status | Duration |\n")
+	b.WriteString("| ---: | --- | --- | --- | --- | ---: |\n")
+	for i, item := range items {
+		title := item.Title
+		if item.Detail != "" {
+			title += " `" + item.Detail + "`"
+		}
+		fmt.Fprintf(&b, "| %d | %s | %s | %s | %s | %s |\n",
+			i+1, item.Timestamp.Format(time.TimeOnly), item.Type, markdownCell(title),
+			item.Status, item.Duration.Round(time.Millisecond))
+	}
+	return []byte(b.String()), nil
+}
+
+// markdownCell keeps text on one table row
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// exportActivity writes the activity log to a file (/export activity)
+func (a *App) exportActivity(args []string) {
+	if len(args) == 0 || args[0] != "activity" || len(args) > 2 {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Usage: /export activity [file.json|file.md]",
+		})
+		return
+	}
+
+	path := fmt.Sprintf("gmn-activity-%s.json", time.Now().Format("20060102-150405"))
+	if len(args) == 2 {
+		path = args[1]
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	items := a.contextPanel.ActivityLog()
+	data, err := ExportActivityLog(items, ext == ".md" || ext == ".markdown")
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Export failed: " + err.Error(),
+		})
+		return
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: fmt.Sprintf("Exported %d activities to %s", len(items), path),
+	})
+}
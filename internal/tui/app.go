@@ -5,17 +5,39 @@ package tui
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/commands"
+	"github.com/linkalls/gmn/internal/compact"
+	settings "github.com/linkalls/gmn/internal/config"
 	"github.com/linkalls/gmn/internal/confirmation"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/guard"
+	"github.com/linkalls/gmn/internal/history"
+	"github.com/linkalls/gmn/internal/input"
+	"github.com/linkalls/gmn/internal/locale"
 	"github.com/linkalls/gmn/internal/session"
+	"github.com/linkalls/gmn/internal/shellhook"
+	"github.com/linkalls/gmn/internal/telemetry"
+	"github.com/linkalls/gmn/internal/termcolor"
+	"github.com/linkalls/gmn/internal/textwidth"
+	"github.com/linkalls/gmn/internal/timing"
+	"github.com/linkalls/gmn/internal/title"
 	"github.com/linkalls/gmn/internal/tools"
+	"github.com/linkalls/gmn/pkg/agent"
 )
 
 // FocusArea represents which panel is focused
@@ -32,11 +54,25 @@ type Config struct {
 	Model           string
 	YoloMode        bool
 	Cwd             string
-	ProjectID       string
+	Connect         Connector // Resolves the API client in the background
 	Timeout         time.Duration
 	AvailableModels []string
 	InitialPrompt   string
 	ResumeSession   string
+	Compaction      compact.Options
+	AllowedTools    []string                     // Tools that run without confirmation (settings)
+	AutoTitle       bool                         // Name new sessions after the first exchange
+	FailureNotes    bool                         // Tell the model about tools that keep failing
+	AutoSave        time.Duration                // Periodic session save interval (0 disables)
+	Debug           bool                         // Show a timing breakdown after each turn
+	PromptGuard     guard.Limits                 // Size and cost above which requests are confirmed
+	AttachBudget    int                          // Token budget of the files mentioned in a message (0 means no limit)
+	Temperature     float64                      // Sampling temperature
+	Layout          settings.LayoutConfig        // Panel layout
+	Notifications   settings.NotificationsConfig // Notify when a long turn finishes unfocused
+	History         *history.History             // Prompt history of the project (optional)
+	Confirmer       *Confirmer                   // Bound to the dialog while the TUI runs (optional)
+	Fallback        func(model string) []string  // Models tried when the model is unavailable (optional)
 }
 
 // App represents the main TUI application
@@ -55,77 +91,167 @@ type App struct {
 	thinking     ThinkingModel
 	contextPanel ContextPanelModel
 	filePreview  FilePreviewModel
+	palette      PaletteModel
+	promptSearch HistorySearchModel
+	stats        StatsModel
+	modelPicker  ModelPickerModel
 	confirmDlg   ConfirmDialogModel
+	confirmQueue []ConfirmDialogOptions // Confirmations waiting for the dialog
+	searching    bool                   // Typing a chat search
+	searchQuery  string
+
+	// Suggestions under the input
+	completion          CompletionModel
+	completionRows      int    // Rows of suggestions laid out
+	completionDismissed string // Input whose suggestions Esc hid
 
 	// API & Session
-	client     *api.Client
+	connect    Connector
 	sessionMgr *session.Manager
 	session    *session.Session
 	allowList  *confirmation.AllowList
-	registry   *tools.Registry
+	tools      *agent.Registry // The tools the agent runs
+	registry   *tools.Registry // The registry behind tools
+	commands   commands.Set    // Custom slash commands
 	history    []api.Content
+	watcher    *settings.Watcher
+	savedLen   int // History length at the last successful save
 
 	// State
-	width           int
-	height          int
-	focus           FocusArea
-	showSidebar     bool
-	showHelp        bool
-	showContext     bool
-	loading         bool
-	loadingText     string
-	err             error
-	quitting        bool
-	inputTokens     int
-	outputTokens    int
-	startTime       time.Time
-	pendingToolResp chan toolResponse
-	ctx             context.Context
-	cancelFunc      context.CancelFunc
-}
-
-// toolResponse holds the result of a tool execution
-type toolResponse struct {
-	toolName  string
-	result    map[string]interface{}
-	err       error
-	outcome   confirmation.Outcome
-	cancelled bool
+	width          int
+	height         int
+	inputRows      int // Rows of the input in the layout
+	focus          FocusArea
+	showSidebar    bool
+	showHelp       bool
+	confirmingQuit bool // Asking whether to quit while work is in flight
+	showContext    bool
+	sidebarWidth   int          // Sidebar columns when shown
+	contextWidth   int          // Context panel columns when shown
+	zen            bool         // Only the chat and the input are shown
+	layoutChanges  int          // Counts layout changes, to save the last one
+	focusReported  bool         // The terminal reports focus changes
+	blurred        bool         // The terminal is in the background
+	mouseOff       bool         // Mouse capture is off for selecting text
+	gaugeFirst     *api.Content // History the context gauge was estimated for
+	gaugeLen       int
+	loading        bool
+	loadingText    string
+	err            error
+	quitting       bool
+	shutdownSignal os.Signal // Set when a signal ended the session
+	inputTokens    int
+	outputTokens   int
+	metrics        *metrics // Counts shown by the stats dashboard
+	startTime      time.Time
+	pendingContext []string           // Context queued by /run --inject for the next prompt
+	shellCancel    func()             // Interrupts the running !command, if any
+	lastShell      *tools.UserCommand // The last !command that finished, for /last-cmd
+	pasted         []pastedItem       // Pastes queued for the next prompt
+	pasteCount     int                // Numbers pastes in the context panel
+	titledID       string             // Session a title was last requested for
+	toolCalls      []string           // Tools called in the response, until their results are in
+	toolFailed     bool               // One of toolCalls failed
+	defaultModel   string             // Model not chosen by the user (tier default applies)
+	tierModel      string             // Default model of the user's tier, once connected
+	turn           *timing.Turn       // Timing of the turn in progress, or nil
+	guard          *turnGuard         // Prompt guard of the turn in progress
+	program        *tea.Program       // Set by Run; commands send progress through it
+	ctx            context.Context
+	cancelFunc     context.CancelFunc
 }
 
 // Messages for async operations
 type (
-	streamTextMsg  string
-	streamDoneMsg  struct{ usage *api.UsageMetadata }
+	streamTextMsg string
+	// streamDoneMsg and toolResultMsg carry the conversation, which Update
+	// takes over: the turn's command must not touch a.history
+	streamDoneMsg struct {
+		history []api.Content
+	}
 	streamErrorMsg struct{ err error }
-	toolCallMsg    struct {
-		call *api.FunctionCall
-		part *api.Part
-	}
-	toolResultMsg    toolResponse
-	sessionListMsg   []SessionInfo
-	confirmResultMsg confirmation.Outcome
-	tickMsg          time.Time
+	toolCallMsg    struct{ call *api.FunctionCall }
+	toolResultMsg  struct {
+		call    *api.FunctionCall
+		tool    tools.BuiltinTool // nil for unknown tools
+		result  map[string]interface{}
+		history []api.Content
+	}
+	responseDoneMsg struct {
+		model string
+		usage *api.UsageMetadata
+	}
+	requestMsg     struct{} // A request after tool calls is being sent
+	fallbackMsg    string   // Another model answers; the model is unavailable
+	sessionListMsg []SessionInfo
+	tickMsg        time.Time
+	configCheckMsg time.Time
+	autoSaveMsg    time.Time
 )
 
+// shutdownMsg asks the app to save and quit because the process received a
+// termination signal (e.g. the terminal window was closed)
+type shutdownMsg struct{ sig os.Signal }
+
+// shellOutputMsg carries output of a !command as it arrives
+type shellOutputMsg struct {
+	run  *shellRun
+	text string
+}
+
+// shellDoneMsg carries the result of a !command
+type shellDoneMsg struct {
+	run    *shellRun
+	result *tools.UserCommand
+	err    error
+}
+
+// compactMsg carries the result of summarizing older history turns
+type compactMsg struct {
+	history []api.Content
+	result  *compact.Result
+	err     error
+}
+
+// summaryMsg carries a recap of the session (/summary)
+type summaryMsg struct {
+	recap string
+	usage *api.UsageMetadata
+	path  string // NOTES.md, if the recap was saved
+	err   error
+}
+
+// titleMsg carries a generated title for a session
+type titleMsg struct {
+	sessionID string
+	title     string
+	err       error
+}
+
+// toolRunMsg carries the result of a tool invoked directly via /run
+type toolRunMsg struct {
+	inv    *tools.Invocation
+	result map[string]interface{}
+}
+
 // NewApp creates a new TUI application
-func NewApp(config Config, client *api.Client, sessionMgr *session.Manager, registry *tools.Registry) *App {
+func NewApp(config Config, sessionMgr *session.Manager, agentTools *agent.Registry) *App {
+	registry := tools.RegistryOf(agentTools)
 	ctx, cancel := context.WithCancel(context.Background())
 
 	app := &App{
-		config:      config,
-		keys:        DefaultKeyMap(),
-		client:      client,
-		sessionMgr:  sessionMgr,
-		registry:    registry,
-		allowList:   confirmation.NewAllowList(),
-		history:     []api.Content{},
-		focus:       FocusInput,
-		showSidebar: true,
-		showContext: true,
-		startTime:   time.Now(),
-		ctx:         ctx,
-		cancelFunc:  cancel,
+		config:     config,
+		keys:       DefaultKeyMap(),
+		connect:    config.Connect,
+		sessionMgr: sessionMgr,
+		tools:      agentTools,
+		registry:   registry,
+		allowList:  confirmation.NewAllowList(),
+		history:    []api.Content{},
+		focus:      FocusInput,
+		startTime:  time.Now(),
+		ctx:        ctx,
+		cancelFunc: cancel,
 	}
 
 	// Initialize components
@@ -133,12 +259,24 @@ func NewApp(config Config, client *api.Client, sessionMgr *session.Manager, regi
 	app.sidebar = NewSidebarModel()
 	app.chatView = NewChatViewModel()
 	app.input = NewInputModel()
+	app.input.SetHistory(config.History)
 	app.statusBar = NewStatusBarModel()
 	app.spinner = NewSpinnerModel()
 	app.thinking = NewThinkingModel()
 	app.contextPanel = NewContextPanelModel()
+	app.contextPanel.SetJobs(registry.Jobs())
 	app.filePreview = NewFilePreviewModel()
 	app.confirmDlg = NewConfirmDialogModel()
+	app.metrics = newMetrics()
+
+	app.applyLayout(config.Layout)
+	app.defaultModel = config.Model
+	app.allowList.SetConfigured(config.AllowedTools)
+	app.watcher, _ = settings.NewWatcher()
+	var err error
+	if app.commands, err = commands.Load(); err != nil {
+		app.chatView.AddMessage(ChatMessage{Type: MessageTypeError, Content: "commands: " + err.Error()})
+	}
 
 	// Set initial focus
 	app.input.SetFocused(true)
@@ -154,9 +292,89 @@ func (a *App) Init() tea.Cmd {
 		tea.EnableMouseCellMotion,
 		a.loadSessions,
 		a.initSession,
+		a.awaitConnection,
+		checkConfig(),
+		a.scheduleAutoSave(),
+	)
+}
+
+// scheduleAutoSave schedules the next periodic session save
+func (a *App) scheduleAutoSave() tea.Cmd {
+	if a.config.AutoSave <= 0 {
+		return nil
+	}
+	return tea.Tick(a.config.AutoSave, func(t time.Time) tea.Msg {
+		return autoSaveMsg(t)
+	})
+}
+
+// configCheckInterval is how often the settings files are polled for changes
+const configCheckInterval = 2 * time.Second
+
+// checkConfig schedules the next settings change check
+func checkConfig() tea.Cmd {
+	return tea.Tick(configCheckInterval, func(t time.Time) tea.Msg {
+		return configCheckMsg(t)
+	})
+}
+
+// reloadConfig re-reads the settings and applies the changes that are safe
+// to make in a running session (tool allowlist and compaction)
+func (a *App) reloadConfig() error {
+	cfg, err := settings.Load()
+	if err != nil {
+		return err
+	}
+	a.config.AllowedTools = cfg.Tools.Allowed
+	a.config.Compaction = compact.FromConfig(cfg.Compaction)
+	a.config.AutoTitle = cfg.Sessions.AutoTitle
+	a.config.FailureNotes = cfg.Tools.FailureNotes
+	a.config.PromptGuard = guard.FromConfig(cfg.PromptGuard)
+	a.config.AttachBudget = cfg.Attachments.Budget()
+	a.config.Temperature = cfg.Model.GenerationTemperature()
+	a.config.Notifications = cfg.Notifications
+	themeErr := termcolor.SetTheme(cfg.UI.Theme, cfg.UI.MinContrast)
+	if themeErr != nil {
+		themeErr = fmt.Errorf("ui.theme: %w", themeErr)
+	}
+	a.allowList.SetConfigured(cfg.Tools.Allowed)
+	var cmdErr error
+	a.commands, cmdErr = commands.Load()
+	return errors.Join(
+		a.registry.ApplySettings(cfg),
+		a.registry.SetDisabled(cfg.Tools.Disabled),
+		themeErr,
+		cmdErr,
 	)
 }
 
+// settingsSavedMsg reports a failure to save settings changed in the TUI
+type settingsSavedMsg struct{ err error }
+
+// saveSettings sets values, by setting key (e.g. "model.name"), in the user
+// settings in the background
+func saveSettings(values map[string]interface{}) tea.Cmd {
+	return func() tea.Msg {
+		path, err := settings.SettingsPath(settings.ScopeUser)
+		if err != nil {
+			return settingsSavedMsg{err: err}
+		}
+		err = settings.UpdateSettings(path, func(obj *settings.Object) error {
+			for key, value := range values {
+				raw, err := json.Marshal(value)
+				if err != nil {
+					return err
+				}
+				if err := settings.SetKey(obj, key, raw); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return settingsSavedMsg{err: err}
+	}
+}
+
 // loadSessions loads the session list
 func (a *App) loadSessions() tea.Msg {
 	if a.sessionMgr == nil {
@@ -173,14 +391,15 @@ func (a *App) loadSessions() tea.Msg {
 		info := SessionInfo{
 			ID:        s.ID,
 			Name:      s.Name,
-			Messages:  len(s.Messages),
-			UpdatedAt: s.UpdatedAt.Format("01/02 15:04"),
+			ParentID:  s.ParentID,
+			Messages:  s.MessageCount(),
+			UpdatedAt: locale.Current().Relative(s.UpdatedAt, time.Now()),
 			IsCurrent: a.session != nil && s.ID == a.session.ID,
 		}
 		sessionInfos = append(sessionInfos, info)
 	}
 
-	return sessionListMsg(sessionInfos)
+	return sessionListMsg(orderBranches(sessionInfos))
 }
 
 // initSession initializes or resumes a session
@@ -234,22 +453,7 @@ func (a *App) initSession() tea.Msg {
 
 // restoreHistory restores history from a session
 func (a *App) restoreHistory(s *session.Session) {
-	for _, msg := range s.Messages {
-		var content api.Content
-		if roleStr, ok := msg["role"].(string); ok {
-			content.Role = roleStr
-		}
-		if partsRaw, ok := msg["parts"].([]interface{}); ok {
-			for _, p := range partsRaw {
-				if partMap, ok := p.(map[string]interface{}); ok {
-					if text, ok := partMap["text"].(string); ok {
-						content.Parts = append(content.Parts, api.Part{Text: text})
-					}
-				}
-			}
-		}
-		a.history = append(a.history, content)
-	}
+	a.history = append(a.history, session.ToHistory(s.Messages)...)
 }
 
 // addHistoryToChat adds a history item to the chat view
@@ -273,6 +477,10 @@ func (a *App) addHistoryToChat(content api.Content) {
 // Update handles messages
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
+	if turn := a.turn; turn != nil {
+		start := time.Now()
+		defer func() { turn.AddRender(time.Since(start)) }()
+	}
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -280,6 +488,11 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if cmd != nil {
 			cmds = append(cmds, cmd)
 		}
+		// Make room for the input and its suggestions as they grow or shrink
+		a.updateCompletion()
+		if a.input.Height() != a.inputRows || a.completion.Height() != a.completionRows {
+			a.handleWindowSize(a.width, a.height)
+		}
 
 	case tea.MouseMsg:
 		cmd := a.handleMouseMsg(msg)
@@ -290,6 +503,12 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		a.handleWindowSize(msg.Width, msg.Height)
 
+	case tea.FocusMsg:
+		a.focusReported, a.blurred = true, false
+
+	case tea.BlurMsg:
+		a.focusReported, a.blurred = true, true
+
 	case sessionListMsg:
 		// Update current session marker
 		sessions := []SessionInfo(msg)
@@ -298,13 +517,21 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		a.sidebar.SetSessions(sessions)
 
+	case connectedMsg:
+		a.handleConnected(msg)
+
 	case streamTextMsg:
+		// Appended to the response placeholder, even if system messages
+		// (e.g. a settings reload) were added after it
 		text := string(msg)
-		if len(a.chatView.messages) > 0 {
-			last := a.chatView.messages[len(a.chatView.messages)-1]
-			if last.Type == MessageTypeModel {
-				a.chatView.UpdateLastMessage(last.Content + text)
-			}
+		i := len(a.chatView.messages) - 1
+		for i >= 0 && a.chatView.messages[i].Type != MessageTypeModel {
+			i--
+		}
+		if i >= 0 {
+			a.chatView.UpdateMessage(i, func(m *ChatMessage) {
+				m.Content += text
+			})
 		} else {
 			a.chatView.AddMessage(ChatMessage{
 				Type:    MessageTypeModel,
@@ -313,20 +540,79 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case streamDoneMsg:
+		a.history = msg.history
 		a.loading = false
 		a.spinner.Stop()
 		a.thinking.Stop()
 		a.chatView.SetLoading(false, "")
-		if msg.usage != nil {
-			a.inputTokens += msg.usage.PromptTokenCount
-			a.outputTokens += msg.usage.CandidatesTokenCount
-			a.statusBar.SetTokens(a.inputTokens, a.outputTokens)
-		}
 		// Update activity
 		a.contextPanel.UpdateLastActivity(ActivityStatusSuccess, time.Since(a.startTime))
+		if cmd := a.notifyTurn(a.finishTurn(), "Response ready"); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+		a.autoSave()
+		if cmd := a.generateTitle(); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+		if a.config.Compaction.ShouldCompact(a.history) {
+			cmds = append(cmds, a.compactHistory())
+		}
+
+	case titleMsg:
+		// Drop the title if the user switched sessions or named this one
+		if msg.err != nil || a.session == nil || a.session.ID != msg.sessionID || a.session.Name != "" {
+			break
+		}
+		a.session.Name = a.sessionMgr.UniqueName(msg.title)
+		a.autoSave()
+		cmds = append(cmds, a.loadSessions)
+
+	case summaryMsg:
+		a.loading = false
+		a.spinner.Stop()
+		a.chatView.SetLoading(false, "")
+		a.addUsage(a.compactionModel(), msg.usage)
+		if msg.recap != "" {
+			content := "Session recap\n\n" + msg.recap
+			if msg.path != "" {
+				content += "\n\nSaved to " + msg.path
+			}
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeSystem,
+				Content: content,
+			})
+		}
+		if msg.err != nil {
+			a.contextPanel.UpdateLastActivity(ActivityStatusError, 0)
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeError,
+				Content: msg.err.Error(),
+			})
+			break
+		}
+		a.contextPanel.UpdateLastActivity(ActivityStatusSuccess, 0)
+
+	case guardCompactMsg:
+		cmds = append(cmds, a.handleGuardCompacted(msg))
+
+	case compactMsg:
+		a.loading = false
+		a.spinner.Stop()
+		a.chatView.SetLoading(false, "")
+		if msg.err != nil {
+			a.contextPanel.UpdateLastActivity(ActivityStatusError, 0)
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeError,
+				Content: "Compaction failed: " + msg.err.Error(),
+			})
+			break
+		}
+		a.contextPanel.UpdateLastActivity(ActivityStatusSuccess, 0)
+		a.applyCompaction(msg.history, msg.result)
 		a.autoSave()
 
 	case streamErrorMsg:
+		a.toolCalls, a.toolFailed = nil, false
 		a.loading = false
 		a.spinner.Stop()
 		a.thinking.Stop()
@@ -337,88 +623,218 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		})
 		// Update activity
 		a.contextPanel.UpdateLastActivity(ActivityStatusError, time.Since(a.startTime))
+		if cmd := a.notifyTurn(a.finishTurn(), "Request failed"); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
 
-	case toolCallMsg:
-		// Add thinking step for tool call
-		a.thinking.AddStep(fmt.Sprintf("Calling %s", msg.call.Name))
+	case fallbackMsg:
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeSystem,
+			Content: fmt.Sprintf("%s is unavailable; %s answers instead", a.config.Model, string(msg)),
+		})
+
+	case responseDoneMsg:
+		a.addUsage(msg.model, msg.usage)
+		// The tools called in the response run now
+		if len(a.toolCalls) > 0 {
+			a.thinking.AddStep("Calling " + strings.Join(a.toolCalls, ", "))
+		}
 
-		// Add activity
+	case toolCallMsg:
+		a.toolCalls = append(a.toolCalls, msg.call.Name)
 		a.contextPanel.AddActivity(ActivityItem{
 			Type:   ActivityTypeTool,
 			Title:  msg.call.Name,
 			Detail: formatToolArgs(msg.call.Args),
 			Status: ActivityStatusRunning,
 		})
-
 		a.chatView.AddMessage(ChatMessage{
 			Type:     MessageTypeTool,
 			ToolName: msg.call.Name,
 			ToolArgs: formatToolArgs(msg.call.Args),
 		})
-		// Execute tool asynchronously
-		cmds = append(cmds, a.executeTool(msg.call, msg.part))
+		if usePTY, _ := msg.call.Args["pty"].(bool); usePTY {
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeSystem,
+				Content: "Running in a terminal: press Ctrl+G to take over and answer prompts (Ctrl+] to return)",
+			})
+		}
 
 	case toolResultMsg:
-		// Complete thinking step
-		if msg.err != nil || msg.cancelled {
-			a.thinking.FailStep()
-		} else {
-			a.thinking.CompleteStep()
+		a.history = msg.history
+		status := ActivityStatusSuccess
+		if msg.result["error"] != nil {
+			status = ActivityStatusError
+			a.toolFailed = true
 		}
+		// Plain text bodies (file contents, command output) are for the
+		// model; only show a preview for richer media types
+		var body string
+		if status == ActivityStatusSuccess && tools.MediaType(msg.result) != tools.MediaText {
+			body = renderToolBody(msg.result, a.chatView.renderer, 10)
+		}
+		a.chatView.AddMessage(ChatMessage{
+			Type:       MessageTypeTool,
+			Content:    toolSummary(msg.result),
+			Body:       body,
+			Result:     msg.result,
+			ResultTool: msg.call.Name,
+		})
+		a.contextPanel.UpdateOldestRunningActivity(status, 0)
+		if status == ActivityStatusSuccess {
+			a.trackToolContext(msg.call.Name, msg.result)
+			if todos, ok := msg.tool.(*tools.WriteTodosTool); ok {
+				a.contextPanel.SetTodos(todos.Todos())
+			}
+		}
+
+		// Complete the thinking step once every call of the response is done
+		if len(a.toolCalls) > 0 {
+			a.toolCalls = a.toolCalls[1:]
+		}
+		if len(a.toolCalls) == 0 {
+			if a.toolFailed {
+				a.thinking.FailStep()
+			} else {
+				a.thinking.CompleteStep()
+			}
+			a.toolFailed = false
+		}
+
+	case requestMsg:
+		// Continue to get model response after tool execution
+		a.thinking.AddStep("Processing response")
+		a.chatView.SetLoading(true, "Processing...")
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeModel,
+			Content: "",
+		})
 
-		if msg.cancelled {
+	case toolRunMsg:
+		content := toolSummary(msg.result)
+		body := renderToolBody(msg.result, a.chatView.renderer, 20)
+		if body == "" {
+			body = truncateLines(tools.FormatResult(msg.result), 20)
+		}
+		a.chatView.AddMessage(ChatMessage{
+			Type:       MessageTypeTool,
+			ToolName:   msg.inv.Name,
+			ToolArgs:   formatToolArgs(msg.inv.Args),
+			Content:    content,
+			Body:       body,
+			Result:     msg.result,
+			ResultTool: msg.inv.Name,
+		})
+		status := ActivityStatusSuccess
+		if msg.result["error"] != nil {
+			status = ActivityStatusError
+		}
+		a.contextPanel.UpdateLastActivity(status, 0)
+		if msg.inv.Inject {
+			a.pendingContext = append(a.pendingContext, msg.inv.ContextText(msg.result))
 			a.chatView.AddMessage(ChatMessage{
-				Type:    MessageTypeTool,
-				Content: "✗ Cancelled by user",
+				Type:    MessageTypeSystem,
+				Content: "Result will be attached to your next message",
 			})
-			// Update activity
-			a.contextPanel.UpdateLastActivity(ActivityStatusError, 0)
-			// Stop loading and don't continue
-			a.loading = false
-			a.spinner.Stop()
-			a.thinking.Stop()
-			a.chatView.SetLoading(false, "")
-		} else if msg.err != nil {
+		}
+
+	case confirmMsg:
+		a.confirmQueue = append(a.confirmQueue, ConfirmDialogOptions(msg))
+		a.showNextConfirm()
+
+	case copiedMsg:
+		a.handleCopied(msg)
+
+	case layoutSaveMsg:
+		if cmd := a.handleLayoutSave(msg); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+
+	case settingsSavedMsg:
+		if msg.err != nil {
 			a.chatView.AddMessage(ChatMessage{
-				Type:    MessageTypeTool,
-				Content: "✗ " + msg.err.Error(),
+				Type:    MessageTypeError,
+				Content: "Failed to save settings: " + msg.err.Error(),
+			})
+		}
+
+	case clipboardMsg:
+		a.handleClipboard(msg)
+
+	case shellOutputMsg:
+		run := msg.run
+		if room := maxShellDisplay - len(run.shown); room > 0 {
+			run.shown += msg.text[:min(room, len(msg.text))]
+			a.chatView.UpdateMessage(run.index, func(m *ChatMessage) {
+				m.Content = run.shown
 			})
-			// Update activity
+		}
+		cmds = append(cmds, run.next)
+
+	case shellDoneMsg:
+		a.shellCancel()
+		a.shellCancel = nil
+		a.loading = false
+		a.chatView.SetLoading(false, "")
+		if msg.err != nil {
 			a.contextPanel.UpdateLastActivity(ActivityStatusError, 0)
-			// Continue to get model response after tool error
-			a.thinking.AddStep("Processing response")
-			a.chatView.SetLoading(true, "Processing...")
 			a.chatView.AddMessage(ChatMessage{
-				Type:    MessageTypeModel,
-				Content: "",
+				Type:    MessageTypeError,
+				Content: msg.err.Error(),
 			})
-			cmds = append(cmds, a.startStreamingWithUpdates())
-		} else {
-			resultStr := "✓ Completed"
-			if count, ok := msg.result["count"].(int); ok {
-				resultStr = fmt.Sprintf("✓ %d items", count)
-			} else if msgStr, ok := msg.result["message"].(string); ok {
-				if len(msgStr) > 50 {
-					msgStr = msgStr[:47] + "..."
-				}
-				resultStr = "✓ " + msgStr
+			break
+		}
+		status := ActivityStatusSuccess
+		if msg.result.ExitCode != 0 {
+			status = ActivityStatusError
+		}
+		a.contextPanel.UpdateLastActivity(status, msg.result.Duration)
+		a.lastShell = msg.result
+		a.chatView.UpdateMessage(msg.run.index, func(m *ChatMessage) {
+			if msg.result.Truncated {
+				m.Content += "\n" + DimStyle.Render("[Output truncated...]")
 			}
+			m.Body = DimStyle.Render(msg.result.Summary())
+		})
+		if msg.run.attach {
+			a.pendingContext = append(a.pendingContext, msg.result.ContextText())
 			a.chatView.AddMessage(ChatMessage{
-				Type:    MessageTypeTool,
-				Content: resultStr,
-			})
-			// Update activity
-			a.contextPanel.UpdateLastActivity(ActivityStatusSuccess, 0)
-			// Continue to get model response after tool execution
-			a.thinking.AddStep("Processing response")
-			a.chatView.SetLoading(true, "Processing...")
-			a.chatView.AddMessage(ChatMessage{
-				Type:    MessageTypeModel,
-				Content: "",
+				Type:    MessageTypeSystem,
+				Content: "Command output will be attached to your next message",
 			})
-			cmds = append(cmds, a.startStreamingWithUpdates())
 		}
 
+	case autoSaveMsg:
+		// Saves between turns too, e.g. tool results of a long agent run
+		if a.savedLen != len(a.history) {
+			a.autoSave()
+		}
+		cmds = append(cmds, a.scheduleAutoSave())
+
+	case shutdownMsg:
+		a.shutdownSignal = msg.sig
+		a.quitting = true
+		a.cancelFunc()
+		a.autoSave()
+		return a, tea.Quit
+
+	case configCheckMsg:
+		if a.watcher != nil && a.watcher.Changed() {
+			content, msgType := "Settings reloaded", MessageTypeSystem
+			if err := a.reloadConfig(); err != nil {
+				content, msgType = "Failed to reload settings: "+err.Error(), MessageTypeError
+			}
+			a.chatView.AddMessage(ChatMessage{Type: msgType, Content: content})
+		}
+		cmds = append(cmds, checkConfig())
+
+	case takeoverDoneMsg:
+		content, msgType := "Returned from the terminal command", MessageTypeSystem
+		if msg.err != nil {
+			content, msgType = "Terminal takeover failed: "+msg.err.Error(), MessageTypeError
+		}
+		a.chatView.AddMessage(ChatMessage{Type: msgType, Content: content})
+
 	case tickMsg:
 		if a.loading {
 			cmd := a.spinner.Update(msg)
@@ -431,14 +847,6 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
-	// Update confirmation dialog if visible
-	if a.confirmDlg.IsVisible() {
-		cmd := a.confirmDlg.Update(msg)
-		if cmd != nil {
-			cmds = append(cmds, cmd)
-		}
-	}
-
 	// Update spinner if loading
 	if a.loading {
 		cmd := a.spinner.Update(msg)
@@ -452,36 +860,134 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	a.updateContextGauge()
 	return a, tea.Batch(cmds...)
 }
 
+// updateContextGauge re-estimates the size of the conversation for the
+// status bar when the history has changed: grown, or replaced by a
+// compaction, a /clear or another session
+func (a *App) updateContextGauge() {
+	var first *api.Content
+	if len(a.history) > 0 {
+		first = &a.history[0]
+	}
+	if first == a.gaugeFirst && len(a.history) == a.gaugeLen {
+		return
+	}
+	a.gaugeFirst, a.gaugeLen = first, len(a.history)
+	a.statusBar.SetContext(guard.Estimate(a.history, nil, nil).Total())
+}
+
 // handleKeyMsg handles keyboard input
 func (a *App) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
+	// The exit confirmation comes first; Quit again quits at once
+	if a.confirmingQuit {
+		if key.Matches(msg, a.keys.Quit) {
+			return a.requestQuit()
+		}
+		return a.handleQuitKey(msg)
+	}
+
+	// Esc or Ctrl+C interrupts a running !command instead of the app
+	if a.shellCancel != nil && (key.Matches(msg, a.keys.Cancel) || key.Matches(msg, a.keys.Quit)) {
+		a.shellCancel()
+		a.chatView.SetLoading(true, "Interrupting command...")
+		return nil
+	}
+
+	// An open confirmation takes every key but Quit
+	if a.confirmDlg.IsVisible() && !key.Matches(msg, a.keys.Quit) {
+		cmd := a.confirmDlg.Update(msg)
+		a.showNextConfirm()
+		return cmd
+	}
+
+	// A chat search being typed takes every key but Quit
+	if a.searching && !key.Matches(msg, a.keys.Quit) {
+		return a.handleSearchKey(msg)
+	}
+
+	// So do the command palette, the model picker and the history search
+	if a.palette.IsVisible() && !key.Matches(msg, a.keys.Quit) {
+		return a.handlePaletteKey(msg)
+	}
+	if a.modelPicker.IsVisible() && !key.Matches(msg, a.keys.Quit) {
+		return a.handleModelPickerKey(msg)
+	}
+	if a.promptSearch.IsVisible() && !key.Matches(msg, a.keys.Quit) {
+		return a.handleHistorySearchKey(msg)
+	}
+	if a.stats.IsVisible() && !key.Matches(msg, a.keys.Quit) {
+		return a.handleStatsKey(msg)
+	}
+	// The preview scrolls with the movement keys and closes with Esc or q
+	if a.filePreview.IsVisible() && !key.Matches(msg, a.keys.Quit) && !key.Matches(msg, a.keys.TogglePreview) {
+		return a.handlePreviewKey(msg)
+	}
+
 	// Global keys that work regardless of focus
 	switch {
 	case key.Matches(msg, a.keys.Quit):
-		a.quitting = true
-		a.autoSave()
-		return tea.Quit
+		return a.requestQuit()
 
 	case key.Matches(msg, a.keys.Help):
 		a.showHelp = !a.showHelp
 		return nil
 
-	case key.Matches(msg, a.keys.ToggleSidebar):
-		a.showSidebar = !a.showSidebar
-		a.handleWindowSize(a.width, a.height)
+	case key.Matches(msg, a.keys.Palette):
+		a.openPalette()
 		return nil
 
-	case key.Matches(msg, a.keys.ToggleContext):
-		a.showContext = !a.showContext
-		a.handleWindowSize(a.width, a.height)
+	case key.Matches(msg, a.keys.HistorySearch):
+		a.startHistorySearch()
+		return nil
+
+	case key.Matches(msg, a.keys.SwitchModel):
+		a.openModelPicker()
 		return nil
 
+	case key.Matches(msg, a.keys.ShowStats):
+		a.stats.Open()
+		return nil
+
+	case key.Matches(msg, a.keys.ToggleSidebar):
+		return a.toggleSidebar()
+
+	case key.Matches(msg, a.keys.ToggleContext):
+		return a.toggleContext()
+
+	case key.Matches(msg, a.keys.SidebarWider):
+		return a.resizeSidebar(panelStep)
+
+	case key.Matches(msg, a.keys.SidebarNarrower):
+		return a.resizeSidebar(-panelStep)
+
+	case key.Matches(msg, a.keys.ContextWider):
+		return a.resizeContext(-panelStep)
+
+	case key.Matches(msg, a.keys.ContextNarrower):
+		return a.resizeContext(panelStep)
+
+	case key.Matches(msg, a.keys.Zen):
+		return a.toggleZen()
+
 	case key.Matches(msg, a.keys.TogglePreview):
 		a.filePreview.Toggle()
 		return nil
 
+	case key.Matches(msg, a.keys.TakeOver):
+		return a.takeOverTerminal()
+
+	case key.Matches(msg, a.keys.ToggleMouse):
+		return a.setMouseCapture(a.mouseOff)
+
+	case key.Matches(msg, a.keys.CopyResponse):
+		return a.copyResponse()
+
+	case key.Matches(msg, a.keys.CopyCode):
+		return a.copyCode("code")
+
 	case key.Matches(msg, a.keys.FocusInput):
 		a.setFocus(FocusInput)
 		return nil
@@ -491,7 +997,7 @@ func (a *App) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 		return nil
 
 	case key.Matches(msg, a.keys.FocusSidebar):
-		if a.showSidebar {
+		if a.sidebarCols() > 0 {
 			a.setFocus(FocusSidebar)
 		}
 		return nil
@@ -500,20 +1006,11 @@ func (a *App) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 		return a.newSession()
 
 	case key.Matches(msg, a.keys.SaveSession):
-		a.autoSave()
-		a.chatView.AddMessage(ChatMessage{
-			Type:    MessageTypeSystem,
-			Content: "Session saved",
-		})
+		a.saveSession()
 		return nil
 
 	case key.Matches(msg, a.keys.ClearChat):
-		a.history = nil
-		a.chatView.Clear()
-		a.chatView.AddMessage(ChatMessage{
-			Type:    MessageTypeSystem,
-			Content: "Conversation cleared",
-		})
+		a.clearChat()
 		return nil
 	}
 
@@ -532,6 +1029,10 @@ func (a *App) handleKeyMsg(msg tea.KeyMsg) tea.Cmd {
 
 // handleInputKey handles input-focused keys
 func (a *App) handleInputKey(msg tea.KeyMsg) tea.Cmd {
+	if a.completion.IsVisible() && a.handleCompletionKey(msg) {
+		return nil
+	}
+
 	switch msg.Type {
 	case tea.KeyEnter:
 		if msg.Alt || strings.Contains(msg.String(), "shift") {
@@ -545,6 +1046,13 @@ func (a *App) handleInputKey(msg tea.KeyMsg) tea.Cmd {
 			return nil
 		}
 
+		// !command runs in the shell; !?command also attaches the output
+		if command, ok := strings.CutPrefix(value, "!"); ok {
+			a.input.Reset()
+			command, attach := strings.CutPrefix(command, "?")
+			return a.runShellCommand(strings.TrimSpace(command), attach)
+		}
+
 		// Check for commands
 		if strings.HasPrefix(value, "/") {
 			return a.handleCommand(value)
@@ -566,26 +1074,39 @@ func (a *App) handleInputKey(msg tea.KeyMsg) tea.Cmd {
 	case tea.KeyEnd:
 		a.input.MoveToEnd()
 	case tea.KeyUp:
-		a.input.HistoryUp()
+		// Up and Down move within the text, and through the history from
+		// its first and last rows
+		if !a.input.CursorUp() {
+			a.input.HistoryUp()
+		}
 	case tea.KeyDown:
-		a.input.HistoryDown()
+		if !a.input.CursorDown() {
+			a.input.HistoryDown()
+		}
 	case tea.KeyCtrlW:
 		a.input.DeleteWord()
 	case tea.KeyCtrlU:
 		a.input.DeleteLine()
 	case tea.KeyTab:
-		// Autocomplete for commands
+		// Autocomplete @file mentions and commands
 		value := a.input.Value()
-		if strings.HasPrefix(value, "/") {
+		if word := lastWord(value); strings.HasPrefix(word, "@") {
+			if matches := input.CompleteMention(word, a.config.Cwd); len(matches) > 0 {
+				a.input.SetValue(value[:len(value)-len(word)] + commonPrefix(matches))
+			}
+		} else if strings.HasPrefix(value, "/") {
 			completed := a.autocompleteCommand(value)
 			if completed != value {
 				a.input.SetValue(completed)
 			}
 		}
 	case tea.KeyRunes:
-		for _, r := range msg.Runes {
-			a.input.InsertChar(r)
+		// Large pastes are attached instead of filling the input line
+		if msg.Paste && isLargePaste(string(msg.Runes)) {
+			a.attachPastedText(string(msg.Runes))
+			return nil
 		}
+		a.input.InsertString(string(msg.Runes))
 	case tea.KeySpace:
 		a.input.InsertChar(' ')
 	}
@@ -595,19 +1116,36 @@ func (a *App) handleInputKey(msg tea.KeyMsg) tea.Cmd {
 
 // handleChatKey handles chat-focused keys
 func (a *App) handleChatKey(msg tea.KeyMsg) tea.Cmd {
+	if a.chatView.Selecting() {
+		return a.handleSelectionKey(msg)
+	}
 	switch {
 	case key.Matches(msg, a.keys.Up):
-		a.chatView.viewport.LineUp(1)
+		a.chatView.LineUp(1)
 	case key.Matches(msg, a.keys.Down):
-		a.chatView.viewport.LineDown(1)
+		a.chatView.LineDown(1)
 	case key.Matches(msg, a.keys.PageUp):
-		a.chatView.viewport.HalfViewUp()
+		a.chatView.HalfViewUp()
 	case key.Matches(msg, a.keys.PageDown):
-		a.chatView.viewport.HalfViewDown()
+		a.chatView.HalfViewDown()
 	case key.Matches(msg, a.keys.Home):
-		a.chatView.viewport.GotoTop()
+		a.chatView.GotoTop()
 	case key.Matches(msg, a.keys.End):
-		a.chatView.viewport.GotoBottom()
+		a.chatView.GotoBottom()
+	case key.Matches(msg, a.keys.Search):
+		a.startSearch()
+	case key.Matches(msg, a.keys.Select):
+		a.startSelection()
+	case key.Matches(msg, a.keys.Expand):
+		a.expandVisible()
+	case key.Matches(msg, a.keys.NextMatch):
+		a.chatView.NextMatch()
+		a.updateSearchStatus()
+	case key.Matches(msg, a.keys.PrevMatch):
+		a.chatView.PrevMatch()
+		a.updateSearchStatus()
+	case key.Matches(msg, a.keys.Cancel):
+		a.endSearch()
 	}
 	return nil
 }
@@ -637,19 +1175,17 @@ func (a *App) handleMouseMsg(msg tea.MouseMsg) tea.Cmd {
 		x, y := msg.X, msg.Y
 
 		// Header area (top 3 lines)
-		if y < 3 {
+		if y < a.headerRows() {
 			return nil
 		}
 
 		// Status bar (bottom line)
-		if y >= a.height-1 {
+		if y >= a.height-a.statusRows() {
 			return nil
 		}
 
 		// Sidebar (left side if visible)
-		sidebarWidth := 0
-		if a.showSidebar {
-			sidebarWidth = 28
+		if sidebarWidth := a.sidebarCols(); sidebarWidth > 0 {
 			if x < sidebarWidth {
 				a.setFocus(FocusSidebar)
 				// Calculate which session was clicked
@@ -661,14 +1197,17 @@ func (a *App) handleMouseMsg(msg tea.MouseMsg) tea.Cmd {
 			}
 		}
 
-		// Input area (bottom 3 lines above status bar)
-		if y >= a.height-4 {
+		// Input area and its suggestions (above status bar)
+		if y >= a.height-a.statusRows()-a.completionRows-a.inputRows-2 {
 			a.setFocus(FocusInput)
 			return nil
 		}
 
-		// Chat area (everything else)
+		// Chat area (everything else); clicking a tool result expands it
 		a.setFocus(FocusChat)
+		if msg.Button == tea.MouseButtonLeft && x < a.width-a.contextCols() {
+			a.expandAt(y - a.headerRows())
+		}
 
 	case tea.MouseActionMotion:
 		// Could implement hover effects here
@@ -691,46 +1230,88 @@ func (a *App) handleWindowSize(width, height int) {
 	a.height = height
 
 	// Calculate layout
-	headerHeight := 3
-	statusHeight := 1
-	inputHeight := 3
-
-	sidebarWidth := 0
-	if a.showSidebar {
-		sidebarWidth = 28
-	}
+	headerHeight := a.headerRows()
+	statusHeight := a.statusRows()
+	sidebarWidth := a.sidebarCols()
+	contextWidth := a.contextCols()
 
-	contextWidth := 0
-	if a.showContext {
-		contextWidth = 30
-	}
+	// The input grows with its text, so is sized first
+	a.input.SetWidth(width - sidebarWidth)
+	a.inputRows = a.input.Height()
+	inputHeight := a.inputRows + 2
+	a.completion.SetWidth(width - sidebarWidth)
+	a.completionRows = a.completion.Height()
 
 	chatWidth := width - sidebarWidth - contextWidth
-	chatHeight := height - headerHeight - statusHeight - inputHeight
+	chatHeight := height - headerHeight - statusHeight - inputHeight - a.completionRows
 
 	// Update components
 	a.header.SetWidth(width)
 	a.sidebar.SetSize(sidebarWidth, chatHeight)
 	a.chatView.SetSize(chatWidth, chatHeight)
 	a.contextPanel.SetSize(contextWidth, chatHeight)
-	a.input.SetWidth(width - sidebarWidth)
 	a.statusBar.SetWidth(width)
 	a.thinking.SetWidth(chatWidth)
 	a.filePreview.SetSize(chatWidth-4, chatHeight-4)
 	a.confirmDlg.SetSize(width, height)
+	a.palette.SetSize(width, height)
+	a.promptSearch.SetWidth(width)
+	a.stats.SetSize(width, height)
 }
 
-// setFocus sets the focus to a specific area
-func (a *App) setFocus(focus FocusArea) {
-	a.focus = focus
-	a.input.SetFocused(focus == FocusInput)
-	a.chatView.SetFocused(focus == FocusChat)
-	a.sidebar.SetFocused(focus == FocusSidebar)
+// quit saves the session and exits
+func (a *App) quit() tea.Cmd {
+	a.quitting = true
+	a.autoSave()
+	return tea.Quit
 }
 
-// handleCommand handles slash commands
-func (a *App) handleCommand(cmd string) tea.Cmd {
-	parts := strings.Fields(cmd)
+// toggleSidebar shows or hides the session sidebar. In zen mode it leaves
+// zen mode with the sidebar shown.
+func (a *App) toggleSidebar() tea.Cmd {
+	a.showSidebar = !a.showSidebar || a.zen
+	a.zen = false
+	return a.layoutChanged()
+}
+
+// toggleContext shows or hides the context panel, leaving zen mode like
+// toggleSidebar
+func (a *App) toggleContext() tea.Cmd {
+	a.showContext = !a.showContext || a.zen
+	a.zen = false
+	return a.layoutChanged()
+}
+
+// saveSession saves the session now
+func (a *App) saveSession() {
+	a.autoSave()
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: "Session saved",
+	})
+}
+
+// clearChat clears the conversation, keeping context files and todos
+func (a *App) clearChat() {
+	a.history = nil
+	a.chatView.Clear()
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: "Conversation cleared",
+	})
+}
+
+// setFocus sets the focus to a specific area
+func (a *App) setFocus(focus FocusArea) {
+	a.focus = focus
+	a.input.SetFocused(focus == FocusInput)
+	a.chatView.SetFocused(focus == FocusChat)
+	a.sidebar.SetFocused(focus == FocusSidebar)
+}
+
+// handleCommand handles slash commands
+func (a *App) handleCommand(cmd string) tea.Cmd {
+	parts := strings.Fields(cmd)
 	if len(parts) == 0 {
 		return nil
 	}
@@ -741,13 +1322,14 @@ func (a *App) handleCommand(cmd string) tea.Cmd {
 		return nil
 
 	case "/exit", "/quit", "/q":
-		a.quitting = true
-		a.autoSave()
-		return tea.Quit
+		return a.requestQuit()
 
 	case "/clear":
 		a.history = nil
 		a.chatView.Clear()
+		a.contextPanel.SetTodos(nil)
+		a.contextPanel.ClearContext()
+		a.pasted = nil
 		a.chatView.AddMessage(ChatMessage{
 			Type:    MessageTypeSystem,
 			Content: "Conversation cleared",
@@ -755,22 +1337,13 @@ func (a *App) handleCommand(cmd string) tea.Cmd {
 		return nil
 
 	case "/stats":
-		duration := time.Since(a.startTime)
-		stats := fmt.Sprintf("Tokens: %d↑ %d↓ | Duration: %s",
-			a.inputTokens, a.outputTokens, duration.Round(time.Second))
-		a.chatView.AddMessage(ChatMessage{
-			Type:    MessageTypeSystem,
-			Content: stats,
-		})
+		a.stats.Open()
 		return nil
 
 	case "/model":
 		if len(parts) == 1 {
-			// Show current model
-			a.chatView.AddMessage(ChatMessage{
-				Type:    MessageTypeSystem,
-				Content: "Current model: " + a.config.Model,
-			})
+			// Pick from the available models
+			a.openModelPicker()
 		} else {
 			newModel := parts[1]
 			// Validate model
@@ -782,16 +1355,7 @@ func (a *App) handleCommand(cmd string) tea.Cmd {
 				}
 			}
 			if valid {
-				a.config.Model = newModel
-				a.header.SetModel(newModel)
-				a.statusBar.SetModel(newModel)
-				if a.session != nil {
-					a.session.Model = newModel
-				}
-				a.chatView.AddMessage(ChatMessage{
-					Type:    MessageTypeSystem,
-					Content: "Model switched to " + newModel,
-				})
+				a.switchModel(newModel)
 			} else {
 				a.chatView.AddMessage(ChatMessage{
 					Type:    MessageTypeError,
@@ -829,10 +1393,143 @@ func (a *App) handleCommand(cmd string) tea.Cmd {
 		}
 		return a.loadSession(parts[1])
 
+	case "/rewind":
+		n := 1
+		if len(parts) > 1 {
+			var err error
+			if n, err = strconv.Atoi(parts[1]); err != nil || n <= 0 {
+				a.chatView.AddMessage(ChatMessage{
+					Type:    MessageTypeError,
+					Content: "Usage: /rewind [N]",
+				})
+				return nil
+			}
+		}
+		return a.rewind(n)
+
+	case "/branch":
+		name := ""
+		if len(parts) > 1 {
+			name = parts[1]
+		}
+		return a.branchSession(name)
+
+	case "/reload-config":
+		if err := a.reloadConfig(); err != nil {
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeError,
+				Content: err.Error(),
+			})
+			return nil
+		}
+		if a.watcher != nil {
+			a.watcher.Changed() // Already applied
+		}
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeSystem,
+			Content: "Settings reloaded",
+		})
+		return nil
+
+	case "/compact":
+		if len(parts) == 3 && parts[1] == "auto" && (parts[2] == "on" || parts[2] == "off") {
+			a.config.Compaction.Auto = parts[2] == "on"
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeSystem,
+				Content: "Auto-compaction " + parts[2],
+			})
+			return nil
+		}
+		if len(parts) > 1 {
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeError,
+				Content: "Usage: /compact [auto on|off]",
+			})
+			return nil
+		}
+		return a.compactHistory()
+
+	case "/summary":
+		if len(parts) > 2 || len(parts) == 2 && parts[1] != "save" {
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeError,
+				Content: "Usage: /summary [save]",
+			})
+			return nil
+		}
+		return a.summarizeSession(len(parts) == 2)
+
+	case "/search":
+		return a.searchSessions(strings.TrimSpace(cmd[len(parts[0]):]))
+
 	case "/new":
 		return a.newSession()
 
+	case "/run":
+		return a.runTool(strings.TrimSpace(cmd[len(parts[0]):]))
+
+	case "/last-cmd":
+		return a.captureLastCommand()
+
+	case "/paste":
+		return a.pasteClipboard()
+
+	case "/copy":
+		return a.copyCommand(strings.TrimSpace(cmd[len(parts[0]):]))
+
+	case "/mouse":
+		return a.mouseCommand(strings.TrimSpace(cmd[len(parts[0]):]))
+
+	case "/expand":
+		a.expandCommand(strings.TrimSpace(cmd[len(parts[0]):]))
+		return nil
+
+	case "/savecode":
+		a.saveCode(strings.TrimSpace(cmd[len(parts[0]):]))
+		return nil
+
+	case "/context":
+		a.contextCommand(strings.TrimSpace(cmd[len(parts[0]):]))
+		return nil
+
+	case "/memory":
+		text, err := a.registry.Memory().RunCommand(strings.TrimSpace(cmd[len(parts[0]):]))
+		if err != nil {
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeError,
+				Content: err.Error(),
+			})
+			return nil
+		}
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeSystem,
+			Content: text,
+		})
+		return nil
+
+	case "/export":
+		a.exportActivity(parts[1:])
+		return nil
+
+	case "/jobs":
+		text, err := a.registry.Jobs().RunCommand(strings.TrimSpace(cmd[len(parts[0]):]))
+		if err != nil {
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeError,
+				Content: err.Error(),
+			})
+			return nil
+		}
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeSystem,
+			Content: text,
+		})
+		return nil
+
 	default:
+		if custom, args, ok := a.commands.Match(cmd); ok {
+			return a.runCustomCommand(custom, args)
+		}
 		a.chatView.AddMessage(ChatMessage{
 			Type:    MessageTypeError,
 			Content: "Unknown command: " + parts[0],
@@ -842,13 +1539,39 @@ func (a *App) handleCommand(cmd string) tea.Cmd {
 	return nil
 }
 
+// runCustomCommand sends the prompt of a custom command, with its files
+// attached
+func (a *App) runCustomCommand(custom *commands.Command, args string) tea.Cmd {
+	prompt, files, err := custom.Expand(args)
+	if err == nil {
+		err = input.CheckBudget(files, a.config.AttachBudget)
+	}
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: err.Error(),
+		})
+		return nil
+	}
+	if len(files) > 0 {
+		a.pendingContext = append(a.pendingContext, strings.TrimSpace(input.FormatAttachments(files)))
+	}
+	a.input.Reset()
+	return a.sendMessage(prompt)
+}
+
 // autocompleteCommand provides command autocompletion
 func (a *App) autocompleteCommand(partial string) string {
 	commands := []string{
 		"/help", "/exit", "/quit", "/clear", "/stats",
-		"/model", "/sessions", "/save", "/load", "/new",
+		"/model", "/compact", "/summary", "/sessions", "/save", "/load", "/new",
+		"/branch", "/rewind", "/reload-config",
+		"/search", "/run", "/last-cmd", "/paste", "/copy", "/savecode", "/expand", "/mouse", "/context", "/memory", "/export",
+		"/jobs",
 	}
 
+	commands = append(commands, a.commands.Names()...)
+
 	partial = strings.ToLower(partial)
 	for _, cmd := range commands {
 		if strings.HasPrefix(cmd, partial) {
@@ -858,19 +1581,67 @@ func (a *App) autocompleteCommand(partial string) string {
 	return partial
 }
 
+// lastWord returns the word the input ends with
+func lastWord(value string) string {
+	return value[strings.LastIndexAny(value, " \t\n")+1:]
+}
+
+// commonPrefix returns the longest prefix shared by all of words
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
 // sendMessage sends a user message
 func (a *App) sendMessage(text string) tea.Cmd {
+	// Files mentioned as @path are attached and listed in the context panel
+	mentioned, err := input.LoadMentions(text, a.config.Cwd)
+	if err == nil {
+		err = input.CheckBudget(mentioned, a.config.AttachBudget)
+	}
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: err.Error(),
+		})
+		return nil
+	}
+
 	// Add user message to chat
 	a.chatView.AddMessage(ChatMessage{
 		Type:      MessageTypeUser,
 		Content:   text,
-		Timestamp: time.Now().Format("15:04"),
+		Timestamp: locale.Current().Clock(time.Now()),
 	})
 
+	for _, file := range mentioned {
+		a.contextPanel.AddContextItem(ContextItem{
+			Type:      ContextTypeFile,
+			Path:      a.contextKey(file.Path),
+			Name:      file.Path,
+			Size:      int64(len(file.Content)),
+			LineCount: countLines(file.Content),
+		})
+	}
+	// Pasted text and images queued with /paste or a large paste
+	pasted, images := a.takePasted()
+	text = input.WithAttachments(text, append(mentioned, pasted...))
+
+	// Attach any queued context (e.g. from /run --inject)
+	if len(a.pendingContext) > 0 {
+		text = strings.Join(a.pendingContext, "\n\n") + "\n\n" + text
+		a.pendingContext = nil
+	}
+
 	// Add to history
 	a.history = append(a.history, api.Content{
 		Role:  "user",
-		Parts: []api.Part{{Text: text}},
+		Parts: append([]api.Part{{Text: text}}, images...),
 	})
 
 	// Start loading with thinking indicator
@@ -896,222 +1667,343 @@ func (a *App) sendMessage(text string) tea.Cmd {
 	})
 
 	// Start streaming with proper channel-based updates
+	a.turn = timing.Start()
+	a.guard = &turnGuard{}
 	return a.startStreamingWithUpdates()
 }
 
-// streamUpdateMsg is sent during streaming to update the UI
-type streamUpdateMsg struct {
-	text string
+// finishTurn ends the timing of the current turn, showing the breakdown in
+// debug mode, and returns how long the turn took
+func (a *App) finishTurn() time.Duration {
+	if a.turn == nil {
+		return 0
+	}
+	a.turn.Finish()
+	b := a.turn.Breakdown()
+	a.turn = nil
+	telemetry.RecordTurn(b)
+	a.metrics.endTurn()
+	if a.config.Debug {
+		a.chatView.AddMessage(ChatMessage{Type: MessageTypeSystem, Content: b.String()})
+	}
+	return b.Total
 }
 
-// startStreamingWithUpdates starts streaming with real-time updates
-func (a *App) startStreamingWithUpdates() tea.Cmd {
-	return func() tea.Msg {
-		userPromptID := fmt.Sprintf("gmn-tui-%d", time.Now().UnixNano())
-
-		req := &api.GenerateRequest{
-			Model:        a.config.Model,
-			Project:      a.config.ProjectID,
-			UserPromptID: userPromptID,
-			Request: api.InnerRequest{
-				Contents: a.history,
-				Config: api.GenerationConfig{
-					Temperature:     1.0,
-					TopP:            0.95,
-					MaxOutputTokens: 8192,
-				},
-				Tools: a.registry.GetTools(),
-			},
-		}
+// runTool executes a tool directly on behalf of the user (/run)
+func (a *App) runTool(line string) tea.Cmd {
+	inv, err := tools.ParseInvocation(line)
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: err.Error() + " (usage: /run [--inject] <tool> key=value...)",
+		})
+		return nil
+	}
 
-		ctx, cancel := context.WithTimeout(a.ctx, a.config.Timeout)
-		defer cancel()
+	tool, ok := a.registry.Get(inv.Name)
+	if !ok {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Unknown tool: " + inv.Name,
+		})
+		return nil
+	}
 
-		stream, err := a.client.GenerateStream(ctx, req)
+	a.contextPanel.AddActivity(ActivityItem{
+		Type:   ActivityTypeTool,
+		Title:  inv.Name,
+		Detail: formatToolArgs(inv.Args),
+		Status: ActivityStatusRunning,
+	})
+
+	return func() tea.Msg {
+		start := time.Now()
+		result, err := tools.ExecuteContext(a.ctx, tool, inv.Args)
+		telemetry.RecordTool(inv.Name, start, err)
 		if err != nil {
-			return streamErrorMsg{err: err}
+			result = map[string]interface{}{"error": err.Error()}
 		}
+		a.registry.Stats().Record(inv.Name, result)
+		return toolRunMsg{inv: inv, result: result}
+	}
+}
 
-		var fullText strings.Builder
-
-		for event := range stream {
-			switch event.Type {
-			case "error":
-				return streamErrorMsg{err: fmt.Errorf(event.Error)}
+// maxShellDisplay caps the !command output shown in the chat
+const maxShellDisplay = 50000
+
+// shellRun is a !command running in the chat. Its output is streamed to
+// the app through a channel read by next.
+type shellRun struct {
+	index  int // Chat message showing the output
+	attach bool
+	shown  string
+	output chan string
+	done   chan shellDoneMsg
+}
 
-			case "tool_call":
-				if event.ToolCall != nil {
-					// First, save accumulated text to history if any
-					if fullText.Len() > 0 {
-						a.history = append(a.history, api.Content{
-							Role:  "model",
-							Parts: []api.Part{{Text: fullText.String()}},
-						})
-					}
-					return toolCallMsg{call: event.ToolCall, part: event.ToolCallPart}
-				}
+// Write sends output to the app
+func (r *shellRun) Write(p []byte) (int, error) {
+	r.output <- string(p)
+	return len(p), nil
+}
 
-			case "done":
-				// Add model response to history
-				if fullText.Len() > 0 {
-					a.history = append(a.history, api.Content{
-						Role:  "model",
-						Parts: []api.Part{{Text: fullText.String()}},
-					})
-				}
-				return streamDoneMsg{usage: event.Usage}
-
-			default:
-				if event.Text != "" {
-					fullText.WriteString(event.Text)
-					// Update the chat view with accumulated text
-					// Note: This happens in the same goroutine, so we update directly
-					// The final update will happen when done
-				}
+// next waits for more output, joining what has already arrived, or for the
+// command to finish
+func (r *shellRun) next() tea.Msg {
+	text, ok := <-r.output
+	if !ok {
+		return <-r.done
+	}
+	for {
+		select {
+		case more, ok := <-r.output:
+			if !ok {
+				return shellOutputMsg{run: r, text: text}
 			}
+			text += more
+		default:
+			return shellOutputMsg{run: r, text: text}
 		}
+	}
+}
 
-		// Final update with all text
-		if fullText.Len() > 0 {
-			a.history = append(a.history, api.Content{
-				Role:  "model",
-				Parts: []api.Part{{Text: fullText.String()}},
-			})
-			// Update the last message with final content
-			a.chatView.UpdateLastMessage(fullText.String())
-		}
+// runShellCommand runs a command typed as !command without asking the
+// model, streaming its output into the chat. With attach set, the output is
+// added to the next message.
+func (a *App) runShellCommand(command string, attach bool) tea.Cmd {
+	if command == "" {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Usage: !<command>, or !?<command> to attach the output to your next message",
+		})
+		return nil
+	}
+	if a.loading {
+		return nil
+	}
+	a.loading = true
+	a.chatView.SetLoading(true, "Running command...")
+	a.contextPanel.AddActivity(ActivityItem{
+		Type:   ActivityTypeShell,
+		Title:  command,
+		Status: ActivityStatusRunning,
+	})
+
+	run := &shellRun{
+		index:  a.chatView.MessageCount(),
+		attach: attach,
+		output: make(chan string, 64),
+		done:   make(chan shellDoneMsg, 1),
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:     MessageTypeTool,
+		ToolName: "shell",
+		ToolArgs: "$ " + command,
+	})
+	// Its own context, so Esc or Ctrl+C stops the command but not the app
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.shellCancel = cancel
+	go func() {
+		defer a.flushOnPanic()
+		result, err := a.registry.RunUserCommand(ctx, command, a.config.Cwd, run)
+		close(run.output)
+		run.done <- shellDoneMsg{run: run, result: result, err: err}
+	}()
+	return run.next
+}
+
+// captureLastCommand attaches the last shell command to the next prompt
+// (/last-cmd): the last !command of the session with its output, or else
+// the command the shell hook recorded, whose output gmn never saw. Neither
+// is run again.
+func (a *App) captureLastCommand() tea.Cmd {
+	if a.lastShell != nil {
+		a.pendingContext = append(a.pendingContext, a.lastShell.ContextText())
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeSystem,
+			Content: fmt.Sprintf("Last command ($ %s, %s) will be attached to your next message", a.lastShell.Command, a.lastShell.Summary()),
+		})
+		return nil
+	}
 
-		return streamDoneMsg{}
+	last, err := shellhook.LoadLast()
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: err.Error(),
+		})
+		return nil
 	}
+	a.pendingContext = append(a.pendingContext, last.ContextText())
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: fmt.Sprintf("Last command ($ %s, exit %d) will be attached to your next message, without its output", last.Command, last.ExitCode),
+	})
+	return nil
+}
+
+// streamUpdateMsg is sent during streaming to update the UI
+type streamUpdateMsg struct {
+	text string
 }
 
-// executeTool executes a tool call
-func (a *App) executeTool(fc *api.FunctionCall, part *api.Part) tea.Cmd {
+// streamFlushInterval is how often streamed text is sent to the chat view,
+// which re-renders the whole response on each update
+const streamFlushInterval = 50 * time.Millisecond
+
+// send delivers a message to Update from a running command, e.g. progress
+// before the command's own result
+func (a *App) send(msg tea.Msg) {
+	if a.program != nil {
+		a.program.Send(msg)
+	}
+}
+
+// startStreamingWithUpdates runs the turn with the agent of pkg/agent: it
+// streams the response, runs the tools the model calls and sends the
+// results back until the model answers. Progress is sent to Update as it
+// happens, with the conversation after each tool result and at the end, so
+// the command never touches a.history.
+func (a *App) startStreamingWithUpdates() tea.Cmd {
+	turn := a.turn
+	g := a.guard
+	model := a.config.Model
+	history := a.history
 	return func() tea.Msg {
-		tool, ok := a.registry.Get(fc.Name)
-		if !ok {
-			// Add error to history
-			a.addToolResponseToHistory(part, fc, map[string]interface{}{"error": "unknown tool: " + fc.Name})
-			return toolResultMsg{
-				toolName: fc.Name,
-				err:      fmt.Errorf("unknown tool: %s", fc.Name),
-			}
+		conn, err := a.connect()
+		if err != nil {
+			return streamErrorMsg{err: err}
 		}
 
-		// Check confirmation requirement
-		if tool.RequiresConfirmation() && !a.allowList.IsAllowed(fc.Name) {
-			if !a.config.YoloMode {
-				// Show confirmation prompt using the existing confirmation package
-				details := confirmation.Details{
-					Type:     confirmation.ConfirmationType(tool.ConfirmationType()),
-					Title:    fmt.Sprintf("Allow %s?", tool.DisplayName()),
-					ToolName: tool.Name(),
-					Args:     fc.Args,
+		var fallback []string
+		if a.config.Fallback != nil {
+			fallback = a.config.Fallback(model)
+		}
+		var stopped tea.Msg // Ends the turn at the prompt guard
+		var sent time.Time  // When the request in progress was sent
+		ag, err := agent.New(agent.Options{
+			Client:       conn.Client,
+			ProjectID:    conn.ProjectID,
+			Model:        model,
+			Fallback:     fallback,
+			Tools:        a.tools,
+			AllowList:    a.allowList,
+			Confirm:      a.confirmTool,
+			Timeout:      a.config.Timeout,
+			FailureNotes: a.config.FailureNotes,
+			Temperature:  a.config.Temperature,
+			BeforeRequest: func(ctx context.Context, req *agent.Request, iteration int) error {
+				if stopped = a.checkPromptGuard(g, conn, req); stopped != nil {
+					return errRequestNotSent
 				}
-
-				// Get file path if available
-				if path, ok := fc.Args["path"].(string); ok {
-					details.FilePath = path
+				if g != nil {
+					g.sent.Store(true)
 				}
-
-				// Get URL if available (for web_fetch)
-				if urlStr, ok := fc.Args["url"].(string); ok {
-					details.URL = urlStr
+				if iteration > 0 {
+					a.send(requestMsg{})
 				}
-
-				// Get command if available (for shell)
-				if cmd, ok := fc.Args["command"].(string); ok {
-					details.Command = cmd
+				if turn != nil {
+					turn.RequestStarted()
 				}
+				sent = time.Now()
+				return nil
+			},
+		})
+		if err != nil {
+			return streamErrorMsg{err: err}
+		}
+		// The history ends with the user's message, which the agent sends.
+		// Clipped, the agent's appends cannot reach a.history.
+		last := len(history) - 1
+		ag.SetHistory(slices.Clip(history[:last]))
+
+		// Text is shown as it arrives, at most every streamFlushInterval. The
+		// rest is flushed before the other events, so Update sees them in order.
+		var pending strings.Builder
+		lastFlush := time.Now()
+		flush := func() {
+			if pending.Len() > 0 {
+				a.send(streamTextMsg(pending.String()))
+				pending.Reset()
+			}
+			lastFlush = time.Now()
+		}
 
-				// For edit confirmations, try to get diff content
-				if tool.ConfirmationType() == "edit" {
-					if getter, ok := tool.(interface {
-						GetOriginalContent(map[string]interface{}) (string, error)
-						GetNewContent(map[string]interface{}) (string, error)
-					}); ok {
-						if orig, err := getter.GetOriginalContent(fc.Args); err == nil {
-							details.OriginalContent = orig
-						}
-						if newC, err := getter.GetNewContent(fc.Args); err == nil {
-							details.NewContent = newC
-						}
-					}
+		current := model
+		_, err = ag.SendParts(a.ctx, history[last].Parts, func(ev agent.Event) {
+			if ev.Type != agent.EventToolResult {
+				if turn != nil {
+					turn.FirstEvent()
 				}
-
-				outcome, err := confirmation.PromptConfirmation(details)
-				if err != nil {
-					a.addToolResponseToHistory(part, fc, map[string]interface{}{"error": "confirmation error: " + err.Error()})
-					return toolResultMsg{
-						toolName: fc.Name,
-						err:      err,
-					}
+				if !sent.IsZero() {
+					a.metrics.addLatency(time.Since(sent))
+					sent = time.Time{}
 				}
+			}
+			if ev.Type != agent.EventText {
+				flush()
+			}
 
-				switch outcome {
-				case confirmation.OutcomeCancel:
-					a.addToolResponseToHistory(part, fc, map[string]interface{}{"error": "operation cancelled by user"})
-					return toolResultMsg{
-						toolName:  fc.Name,
-						cancelled: true,
+			switch ev.Type {
+			case agent.EventStart:
+				if ev.Model != current {
+					current = ev.Model
+					a.send(fallbackMsg(current))
+				}
+			case agent.EventText:
+				pending.WriteString(ev.Text)
+				if time.Since(lastFlush) >= streamFlushInterval {
+					flush()
+				}
+			case agent.EventToolCall:
+				a.send(toolCallMsg{call: ev.Call})
+			case agent.EventDone:
+				if turn != nil {
+					turn.StreamEnded()
+				}
+				a.send(responseDoneMsg{model: current, usage: ev.Usage})
+			case agent.EventToolResult:
+				if ev.Tool != nil {
+					if turn != nil {
+						turn.AddTools(ev.Duration, 1)
 					}
-				case confirmation.OutcomeProceedAlways:
-					a.allowList.Allow(fc.Name)
+					a.metrics.addTool(ev.Call.Name, ev.Duration, ev.Result["error"] != nil)
 				}
+				a.send(toolResultMsg{call: ev.Call, tool: ev.Tool, result: ev.Result, history: ag.History()})
 			}
-		}
-
-		result, err := tool.Execute(fc.Args)
+		})
+		flush()
 		if err != nil {
-			result = map[string]interface{}{"error": err.Error()}
-		}
-
-		// Add tool call and response to history
-		a.addToolResponseToHistory(part, fc, result)
-
-		return toolResultMsg{
-			toolName: fc.Name,
-			result:   result,
-			err:      err,
+			if stopped != nil {
+				return stopped
+			}
+			return streamErrorMsg{err: err}
 		}
+		return streamDoneMsg{history: ag.History()}
 	}
 }
 
-// addToolResponseToHistory adds tool call and response to history
-func (a *App) addToolResponseToHistory(part *api.Part, fc *api.FunctionCall, result map[string]interface{}) {
-	responseID := fc.ID
-	if responseID == "" {
-		responseID = fmt.Sprintf("%s-%d", fc.Name, time.Now().UnixNano())
-	}
-
-	// Add model's tool call
-	if part != nil {
-		a.history = append(a.history, api.Content{
-			Role:  "model",
-			Parts: []api.Part{*part},
-		})
-	} else {
-		a.history = append(a.history, api.Content{
-			Role:  "model",
-			Parts: []api.Part{{FunctionCall: fc}},
-		})
+// confirmTool asks in the dialog whether the agent may run a tool call
+func (a *App) confirmTool(ctx context.Context, req agent.ConfirmRequest) (agent.Decision, error) {
+	outcome, err := a.confirm(toolDetails(req.Tool, req.Call.Args))
+	switch {
+	case err != nil:
+		return agent.Deny, err
+	case outcome == confirmation.OutcomeProceedAlways:
+		return agent.AllowAlways, nil
+	case outcome == confirmation.OutcomeCancel:
+		return agent.Deny, nil
 	}
-
-	// Add tool response
-	a.history = append(a.history, api.Content{
-		Role: "user",
-		Parts: []api.Part{{FunctionResp: &api.FunctionResp{
-			ID:       responseID,
-			Name:     fc.Name,
-			Response: result,
-		}}},
-	})
+	return agent.Allow, nil
 }
 
 // newSession creates a new session
 func (a *App) newSession() tea.Cmd {
 	a.history = nil
 	a.chatView.Clear()
+	a.contextPanel.SetTodos(nil)
+	a.contextPanel.ClearContext()
+	a.pasted = nil
 	a.inputTokens = 0
 	a.outputTokens = 0
 
@@ -1166,6 +2058,201 @@ func (a *App) loadSession(idOrName string) tea.Cmd {
 	}
 }
 
+// rewind drops the last n turns from the conversation (/rewind)
+func (a *App) rewind(n int) tea.Cmd {
+	if a.loading {
+		return nil
+	}
+	history, err := compact.Rewind(a.history, n)
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: err.Error(),
+		})
+		return nil
+	}
+
+	a.history = history
+	a.chatView.Clear()
+	for _, h := range a.history {
+		a.addHistoryToChat(h)
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: fmt.Sprintf("Rewound %d turn(s)", n),
+	})
+	a.autoSave()
+	return a.loadSessions
+}
+
+// branchSession forks the current session into a new one (/branch)
+func (a *App) branchSession(name string) tea.Cmd {
+	if a.sessionMgr == nil || a.session == nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Session management not available",
+		})
+		return nil
+	}
+
+	a.autoSave()
+	branch, err := a.sessionMgr.Branch(a.session, name)
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: err.Error(),
+		})
+		return nil
+	}
+
+	parentID := a.session.ID
+	a.session = branch
+	a.statusBar.SetSessionID(branch.ID)
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: fmt.Sprintf("Branched %s from %s", branch.ID, parentID),
+	})
+	return a.loadSessions
+}
+
+// applyCompaction replaces the history with its compacted version
+func (a *App) applyCompaction(history []api.Content, result *compact.Result) {
+	a.history = history
+	a.addUsage(a.compactionModel(), result.Usage)
+	a.chatView.AddMessage(ChatMessage{
+		Type: MessageTypeSystem,
+		Content: fmt.Sprintf("Compacted %d messages (~%d → ~%d tokens)",
+			result.Summarized, result.TokensBefore, result.TokensAfter),
+	})
+}
+
+// compactHistory summarizes older turns in the background (/compact)
+func (a *App) compactHistory() tea.Cmd {
+	if a.loading {
+		return nil
+	}
+	a.loading = true
+	a.chatView.SetLoading(true, "Compacting conversation...")
+	a.contextPanel.AddActivity(ActivityItem{
+		Type:   ActivityTypeThinking,
+		Title:  "Compacting history",
+		Status: ActivityStatusRunning,
+	})
+
+	history := append([]api.Content(nil), a.history...)
+	return func() tea.Msg {
+		conn, err := a.connect()
+		if err != nil {
+			return compactMsg{err: err}
+		}
+		ctx, cancel := context.WithTimeout(a.ctx, a.config.Timeout)
+		defer cancel()
+		compacted, result, err := compact.Compact(ctx, conn.Client, conn.ProjectID, a.config.Compaction, history)
+		return compactMsg{history: compacted, result: result, err: err}
+	}
+}
+
+// summarizeSession writes a short recap of the session in the background
+// and, if save is set, appends it to NOTES.md in the workspace (/summary)
+func (a *App) summarizeSession(save bool) tea.Cmd {
+	if a.loading {
+		return nil
+	}
+	a.loading = true
+	a.chatView.SetLoading(true, "Summarizing session...")
+	a.contextPanel.AddActivity(ActivityItem{
+		Type:   ActivityTypeThinking,
+		Title:  "Summarizing session",
+		Status: ActivityStatusRunning,
+	})
+
+	history := append([]api.Content(nil), a.history...)
+	return func() tea.Msg {
+		conn, err := a.connect()
+		if err != nil {
+			return summaryMsg{err: err}
+		}
+		ctx, cancel := context.WithTimeout(a.ctx, a.config.Timeout)
+		defer cancel()
+		recap, usage, err := compact.Recap(ctx, conn.Client, conn.ProjectID, a.config.Compaction.Model, history)
+		if err != nil {
+			return summaryMsg{usage: usage, err: err}
+		}
+		msg := summaryMsg{recap: recap, usage: usage}
+		if save {
+			// The recap is still shown if saving fails
+			msg.path, msg.err = compact.SaveRecap(a.config.Cwd, recap)
+		}
+		return msg
+	}
+}
+
+// generateTitle names an unnamed session after its first exchange using a
+// cheap model, in the background
+func (a *App) generateTitle() tea.Cmd {
+	if !a.config.AutoTitle || a.sessionMgr == nil || a.session == nil ||
+		a.session.Name != "" || a.titledID == a.session.ID {
+		return nil
+	}
+	a.titledID = a.session.ID
+
+	id := a.session.ID
+	history := append([]api.Content(nil), a.history...)
+	return func() tea.Msg {
+		conn, err := a.connect()
+		if err != nil {
+			return titleMsg{sessionID: id, err: err}
+		}
+		ctx, cancel := context.WithTimeout(a.ctx, a.config.Timeout)
+		defer cancel()
+		t, err := title.Generate(ctx, conn.Client, conn.ProjectID, history)
+		return titleMsg{sessionID: id, title: t, err: err}
+	}
+}
+
+// searchSessions greps message content across saved sessions (/search)
+func (a *App) searchSessions(args string) tea.Cmd {
+	query, useRegex := session.ParseSearchArgs(args)
+	if query == "" || a.sessionMgr == nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Usage: /search [--regex] <query>",
+		})
+		return nil
+	}
+
+	results, err := a.sessionMgr.Search(query, useRegex, 20)
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: err.Error(),
+		})
+		return nil
+	}
+	if len(results) == 0 {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeSystem,
+			Content: "No matches for " + query,
+		})
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d match(es) for %s (use /load <id> to open):", len(results), query)
+	for _, r := range results {
+		name := r.SessionID
+		if r.Name != "" {
+			name = r.Name + " (" + r.SessionID + ")"
+		}
+		fmt.Fprintf(&b, "\n%s [%s, %s]\n  %s", name, r.Role, locale.Current().ShortDateTime(r.UpdatedAt), r.Snippet)
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: b.String(),
+	})
+	return nil
+}
+
 // autoSave saves the current session
 func (a *App) autoSave() {
 	if a.sessionMgr == nil || a.session == nil {
@@ -1173,30 +2260,35 @@ func (a *App) autoSave() {
 	}
 
 	// Convert history to session format
-	a.session.Messages = make([]map[string]interface{}, len(a.history))
-	for i, h := range a.history {
-		parts := make([]map[string]interface{}, len(h.Parts))
-		for j, p := range h.Parts {
-			parts[j] = map[string]interface{}{"text": p.Text}
-		}
-		a.session.Messages[i] = map[string]interface{}{
-			"role":  h.Role,
-			"parts": parts,
-		}
-	}
+	a.session.Messages = session.FromHistory(a.history)
 	a.session.Tokens.Input = a.inputTokens
 	a.session.Tokens.Output = a.outputTokens
 	a.session.Model = a.config.Model
 
-	a.sessionMgr.Save(a.session)
+	if err := a.sessionMgr.Save(a.session); err == nil {
+		a.savedLen = len(a.history)
+	}
 }
 
-// View renders the TUI
+// View renders the TUI, in ASCII alone where the terminal needs it
 func (a *App) View() string {
+	if turn := a.turn; turn != nil {
+		start := time.Now()
+		defer func() { turn.AddRender(time.Since(start)) }()
+	}
+	return glyphs.Text(a.view())
+}
+
+// view renders the screen
+func (a *App) view() string {
 	if a.quitting {
 		return a.renderExitStats()
 	}
 
+	if a.confirmingQuit {
+		return a.renderWithOverlay(a.renderQuitConfirm())
+	}
+
 	// Check for confirmation dialog
 	if a.confirmDlg.IsVisible() {
 		return a.renderWithOverlay(a.confirmDlg.View())
@@ -1207,10 +2299,28 @@ func (a *App) View() string {
 		return a.renderWithOverlay(a.filePreview.View())
 	}
 
+	if a.palette.IsVisible() {
+		return a.renderWithOverlay(a.palette.View())
+	}
+
+	if a.modelPicker.IsVisible() {
+		return a.renderWithOverlay(a.modelPicker.View())
+	}
+
+	if a.promptSearch.IsVisible() {
+		return a.renderWithOverlay(a.promptSearch.View())
+	}
+
+	if a.stats.IsVisible() {
+		return a.renderWithOverlay(a.stats.View(a.metrics, time.Since(a.startTime)))
+	}
+
 	var sections []string
 
 	// Header
-	sections = append(sections, a.header.View())
+	if !a.zen {
+		sections = append(sections, a.header.View())
+	}
 
 	// Main content area (sidebar + chat + context)
 	var mainContent string
@@ -1221,14 +2331,15 @@ func (a *App) View() string {
 		chatContent = chatContent + "\n" + a.thinking.View()
 	}
 
-	if a.showSidebar && a.showContext {
+	showSidebar, showContext := a.sidebarCols() > 0, a.contextCols() > 0
+	if showSidebar && showContext {
 		sidebar := a.sidebar.View()
 		context := a.contextPanel.View()
 		mainContent = lipgloss.JoinHorizontal(lipgloss.Top, sidebar, chatContent, context)
-	} else if a.showSidebar {
+	} else if showSidebar {
 		sidebar := a.sidebar.View()
 		mainContent = lipgloss.JoinHorizontal(lipgloss.Top, sidebar, chatContent)
-	} else if a.showContext {
+	} else if showContext {
 		context := a.contextPanel.View()
 		mainContent = lipgloss.JoinHorizontal(lipgloss.Top, chatContent, context)
 	} else {
@@ -1238,9 +2349,14 @@ func (a *App) View() string {
 
 	// Input
 	sections = append(sections, a.input.View())
+	if a.completion.IsVisible() {
+		sections = append(sections, a.completion.View())
+	}
 
 	// Status bar
-	sections = append(sections, a.statusBar.View())
+	if !a.zen {
+		sections = append(sections, a.statusBar.View())
+	}
 
 	// Help overlay
 	if a.showHelp {
@@ -1271,55 +2387,93 @@ func (a *App) renderExitStats() string {
 	outputCost := float64(a.outputTokens) * 0.00000030
 	totalCost := inputCost + outputCost
 
+	goodbye := "Goodbye! 👋"
+	if glyphs.ASCIIOnly() {
+		goodbye = "Goodbye!"
+	}
+
+	loc := locale.Current()
 	stats := fmt.Sprintf(`
 %s
 
-  Input:    %d tokens
-  Output:   %d tokens
-  Total:    %d tokens
+  Input:    %s tokens
+  Output:   %s tokens
+  Total:    %s tokens
   Duration: %s
-  Est Cost: ~$%.6f
+  Est Cost: ~%s
 
 %s
 `,
-		AccentStyle.Render("📊 Session Stats"),
-		a.inputTokens,
-		a.outputTokens,
-		totalTokens,
+		AccentStyle.Render(glyphs.Label(glyphs.Current().Stats, "Session Stats")),
+		loc.Int(a.inputTokens),
+		loc.Int(a.outputTokens),
+		loc.Int(totalTokens),
 		duration.Round(time.Second),
-		totalCost,
-		DimStyle.Render("Goodbye! 👋"),
+		loc.Cost(totalCost, 6),
+		DimStyle.Render(goodbye),
 	)
 
-	return stats
+	return glyphs.Text(stats)
 }
 
 // renderHelpOverlay renders the help overlay
 func (a *App) renderHelpOverlay(background string) string {
-	help := `
+	title := lipgloss.PlaceHorizontal(43, lipgloss.Center, glyphs.Label(glyphs.Current().Sessions, "Help - gmn TUI"))
+	help := fmt.Sprintf(`
 ╭───────────────────────────────────────────╮
-│             📋 Help - gmn TUI             │
+│%s│
 ├───────────────────────────────────────────┤
 │  Navigation                               │
 │    ↑/↓         Scroll / History           │
 │    PgUp/PgDn   Page up/down               │
 │    Tab         Autocomplete               │
+│    /  n/N      Search chat (chat focus)   │
+│    C-r         Search prompt history      │
+│    v  y        Select/copy lines (chat)   │
+│    e / click   Expand tool output (chat)  │
+│    C-y / M-y   Copy response / code block │
+│    C-k         Command palette            │
 │                                           │
 │  Panels                                   │
 │    C-b         Toggle sidebar             │
 │    C-e         Toggle context panel       │
+│    M-←/→       Resize sidebar             │
+│    M-S-←/→     Resize context panel       │
+│    M-z         Zen mode (chat only)       │
 │    C-p         Toggle file preview        │
+│    C-g         Take over terminal command │
+│    M-S-m       Mouse capture on/off       │
 │    C-1/2/3     Focus chat/side/input      │
 │                                           │
 │  Commands                                 │
 │    /help       Show this help             │
 │    /clear      Clear conversation         │
-│    /stats      Show token usage           │
-│    /model      Show/switch model          │
+│    /stats      Stats dashboard (or C-t)   │
+│    /model      Pick a model (or M-m)      │
+│    /compact    Summarize older turns      │
+│    /summary    Recap the session          │
+│    /reload-config  Re-read settings       │
 │    /sessions   List sessions              │
 │    /save       Save session               │
 │    /load       Load session               │
 │    /new        New session                │
+│    /branch     Fork into a new session    │
+│    /rewind N   Drop the last N turns      │
+│    /search     Search all sessions        │
+│    /run        Run a tool directly        │
+│    /last-cmd   Attach last shell command  │
+│    /paste      Attach clipboard contents  │
+│    /copy [N]   Copy response/code block N │
+│    /savecode N path  Save code block N    │
+│    /expand     Expand last tool output    │
+│    /mouse      Select text with terminal  │
+│    /context    List/drop context files    │
+│    /memory     Show/add/refresh memory    │
+│    /export activity  Save activity log    │
+│    /jobs       List/kill background jobs  │
+│    /<name>     Custom (.gmn/commands)     │
+│    !cmd        Run a shell command        │
+│    !?cmd       ...and attach its output   │
 │    /exit       Exit                       │
 │                                           │
 │  General                                  │
@@ -1332,7 +2486,7 @@ func (a *App) renderHelpOverlay(background string) string {
 │    Shows files in context                 │
 │    Shows recent tool activity             │
 ╰───────────────────────────────────────────╯
-`
+`, title)
 
 	helpBox := lipgloss.NewStyle().
 		Foreground(TextColor).
@@ -1351,6 +2505,15 @@ func (a *App) renderHelpOverlay(background string) string {
 	)
 }
 
+// truncateLines limits text to maxLines lines for display
+func truncateLines(text string, maxLines int) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) <= maxLines {
+		return text
+	}
+	return strings.Join(lines[:maxLines], "\n") + fmt.Sprintf("\n... (%d more lines)", len(lines)-maxLines)
+}
+
 // formatToolArgs formats tool arguments for display
 func formatToolArgs(args map[string]interface{}) string {
 	if path, ok := args["path"].(string); ok {
@@ -1363,41 +2526,84 @@ func formatToolArgs(args map[string]interface{}) string {
 		return url
 	}
 	if cmd, ok := args["command"].(string); ok {
-		if len(cmd) > 40 {
-			return cmd[:37] + "..."
-		}
-		return cmd
+		return textwidth.Truncate(cmd, 40)
 	}
 	if query, ok := args["query"].(string); ok {
-		if len(query) > 40 {
-			return query[:37] + "..."
-		}
-		return query
+		return textwidth.Truncate(query, 40)
 	}
 	return ""
 }
 
 // Run starts the TUI application
-func Run(config Config, client *api.Client, sessionMgr *session.Manager, registry *tools.Registry) error {
+func Run(config Config, sessionMgr *session.Manager, agentTools *agent.Registry) error {
 	// Set yolo mode globally
 	if config.YoloMode {
 		confirmation.YoloMode = true
 	}
 
-	app := NewApp(config, client, sessionMgr, registry)
+	app := NewApp(config, sessionMgr, agentTools)
 
 	p := tea.NewProgram(
 		app,
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
+		tea.WithReportFocus(),
+		tea.WithoutSignalHandler(), // Signals are handled below to save first
 	)
+	app.program = p
+	if config.Confirmer != nil {
+		config.Confirmer.bind(app)
+		defer config.Confirmer.bind(nil)
+	}
 
+	stop := handleShutdownSignals(p)
 	_, err := p.Run()
+	stop()
+	// Confirmations still waiting for the dialog are declined
+	app.cancelFunc()
+
+	// The program has stopped, so this cannot race with Update; it covers a
+	// program that was killed before it could save, or that panicked
+	app.autoSave()
+	if errors.Is(err, tea.ErrProgramPanic) && app.session != nil {
+		fmt.Fprintf(os.Stderr, "\nThe conversation was saved; resume it with: gmn chat --resume %s\n", app.session.ID)
+	}
 
-	// Show exit stats on clean exit
-	if err == nil {
+	// Show exit stats on clean exit, unless the terminal is gone
+	if err == nil && app.shutdownSignal != syscall.SIGHUP {
 		fmt.Print(app.renderExitStats())
 	}
 
 	return err
 }
+
+// shutdownTimeout is how long the app may take to save and quit after a
+// termination signal before it is killed
+const shutdownTimeout = 3 * time.Second
+
+// handleShutdownSignals makes SIGINT, SIGTERM and SIGHUP (terminal closed)
+// save the session and quit the program, which restores the terminal. The
+// returned function stops listening.
+func handleShutdownSignals(p *tea.Program) func() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case s := <-sig:
+			p.Send(shutdownMsg{sig: s})
+			select {
+			case <-time.After(shutdownTimeout):
+				p.Kill()
+			case <-done:
+			}
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
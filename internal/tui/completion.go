@@ -0,0 +1,317 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/linkalls/gmn/internal/commands"
+	"github.com/linkalls/gmn/internal/input"
+	"github.com/linkalls/gmn/internal/textwidth"
+	"github.com/linkalls/gmn/internal/tools"
+)
+
+// completionRows is the number of suggestions shown at once
+const completionRows = 6
+
+// commandHints are the arguments of the built-in slash commands, shown
+// after their names in the suggestions
+var commandHints = map[string]string{
+	"/model":    "[name]",
+	"/compact":  "[auto on|off]",
+	"/summary":  "[save]",
+	"/save":     "[name]",
+	"/load":     "<session>",
+	"/branch":   "[name]",
+	"/rewind":   "[N]",
+	"/search":   "[--regex] <query>",
+	"/run":      "<tool> [key=value…]",
+	"/copy":     "[code|N]",
+	"/savecode": "<N> <path>",
+	"/expand":   "[last]",
+	"/mouse":    "[on|off]",
+	"/context":  "[drop <file>]",
+	"/memory":   "[add|refresh]",
+	"/export":   "activity [file]",
+	"/jobs":     "[kill <id>]",
+}
+
+// commandArgs are the words the built-in slash commands take as their
+// first argument
+var commandArgs = map[string][]string{
+	"/compact": {"auto"},
+	"/summary": {"save"},
+	"/copy":    {"code"},
+	"/expand":  {"last"},
+	"/mouse":   {"on", "off"},
+	"/context": {"drop"},
+	"/memory":  {"add", "refresh"},
+	"/export":  {"activity"},
+	"/jobs":    {"kill"},
+}
+
+// completionItem is a suggestion for the word being typed
+type completionItem struct {
+	value  string // Replaces the word being typed
+	hint   string // Arguments still to type after value
+	detail string
+	more   bool // More is typed after it, so Enter does not send
+}
+
+// =============================================================================
+// Completion Component
+// =============================================================================
+
+// CompletionModel is the list of suggestions under the input, shown while
+// a slash command or its first argument, or an @path, is typed
+type CompletionModel struct {
+	items    []completionItem
+	input    string // Input the items were suggested for
+	selected int
+	top      int
+	width    int
+}
+
+// Set shows items as the suggestions for input, keeping the selection
+// while the input is unchanged
+func (c *CompletionModel) Set(input string, items []completionItem) {
+	if input == c.input && len(items) == len(c.items) {
+		return
+	}
+	c.input = input
+	c.items = items
+	c.selected = 0
+	c.top = 0
+}
+
+// IsVisible reports whether there are suggestions to show
+func (c *CompletionModel) IsVisible() bool {
+	return len(c.items) > 0
+}
+
+// Height returns the number of rows the suggestions take
+func (c *CompletionModel) Height() int {
+	return min(len(c.items), completionRows)
+}
+
+// SetWidth sets the width
+func (c *CompletionModel) SetWidth(width int) {
+	c.width = width
+}
+
+// Move moves the selection by n suggestions, wrapping around the ends
+func (c *CompletionModel) Move(n int) {
+	if len(c.items) == 0 {
+		return
+	}
+	c.selected = (c.selected + n + len(c.items)) % len(c.items)
+	if c.selected < c.top {
+		c.top = c.selected
+	}
+	if c.selected >= c.top+completionRows {
+		c.top = c.selected - completionRows + 1
+	}
+}
+
+// Selected returns the selected suggestion
+func (c *CompletionModel) Selected() completionItem {
+	return c.items[c.selected]
+}
+
+// View renders the suggestions, the value with its arguments on the left
+// and the description on the right
+func (c *CompletionModel) View() string {
+	width := max(c.width-4, 20)
+	end := min(c.top+completionRows, len(c.items))
+	lines := make([]string, 0, end-c.top)
+	for n := c.top; n < end; n++ {
+		item := c.items[n]
+		left := item.value
+		if item.hint != "" {
+			left += " " + item.hint
+		}
+		left = textwidth.Truncate(left, width)
+		space := width - textwidth.Width(left)
+		detail := ""
+		if space > 6 && item.detail != "" {
+			detail = textwidth.Truncate(item.detail, space-3)
+		}
+		gap := strings.Repeat(" ", max(space-textwidth.Width(detail), 0))
+
+		var line string
+		if n == c.selected {
+			line = SelectionStyle.Render(left + gap + detail)
+		} else {
+			hint := strings.TrimPrefix(left, item.value)
+			line = item.value + DimStyle.Render(hint) + gap + HelpDescStyle.Render(detail)
+		}
+		if n == end-1 && len(c.items) > end {
+			line += DimStyle.Render(" ↓")
+		}
+		lines = append(lines, "  "+line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// =============================================================================
+// Completion Actions
+// =============================================================================
+
+// updateCompletion suggests completions for the input as it is typed
+func (a *App) updateCompletion() {
+	value := a.input.Value()
+	if a.focus != FocusInput || value == a.completionDismissed {
+		a.completion.Set(value, nil)
+		return
+	}
+	a.completionDismissed = ""
+	a.completion.Set(value, a.completions(value))
+}
+
+// completions returns the suggestions for value: the files and directories
+// an @path being typed can be, or slash commands while the name is typed,
+// then the values their first argument takes
+func (a *App) completions(value string) []completionItem {
+	if word := lastWord(value); strings.HasPrefix(word, "@") {
+		return a.mentionCompletions(word)
+	}
+	if !strings.HasPrefix(value, "/") || strings.ContainsAny(value, "\n\t") {
+		return nil
+	}
+	name, arg, hasArg := strings.Cut(value, " ")
+	name = strings.ToLower(name)
+	var items []completionItem
+	if !hasArg {
+		for _, c := range paletteCommands {
+			if strings.HasPrefix(c.name, name) {
+				hint := commandHints[c.name]
+				items = append(items, completionItem{c.name, hint, c.desc, hint != ""})
+			}
+		}
+		for _, c := range a.commands.Sorted() {
+			if custom := "/" + c.Name; strings.HasPrefix(custom, name) {
+				hint := ""
+				if strings.Contains(c.Prompt, commands.ArgsPlaceholder) {
+					hint = "<args>"
+				}
+				items = append(items, completionItem{custom, hint, c.Description, hint != ""})
+			}
+		}
+		if len(items) == 1 && items[0].value == name {
+			return nil // Nothing left to complete
+		}
+		return items
+	}
+	if strings.Contains(arg, " ") {
+		return nil // Only the first argument is suggested
+	}
+
+	switch name {
+	case "/load":
+		for _, s := range a.sidebar.sessions {
+			if !strings.HasPrefix(s.ID, arg) && !strings.Contains(strings.ToLower(s.Name), strings.ToLower(arg)) {
+				continue
+			}
+			detail := fmt.Sprintf("%d msgs  %s", s.Messages, s.UpdatedAt)
+			if s.Name != "" {
+				detail = s.Name + "  " + detail
+			}
+			items = append(items, completionItem{value: s.ID, detail: detail})
+		}
+	case "/model":
+		for _, m := range a.config.AvailableModels {
+			if strings.HasPrefix(m, arg) {
+				detail := ""
+				if m == a.config.Model {
+					detail = "current"
+				}
+				items = append(items, completionItem{value: m, detail: detail})
+			}
+		}
+	case "/run":
+		names := a.registry.GetToolNames()
+		sort.Strings(names)
+		for _, tool := range names {
+			if strings.HasPrefix(tool, arg) {
+				items = append(items, completionItem{value: tool, hint: "[key=value…]", more: true})
+			}
+		}
+	default:
+		for _, word := range commandArgs[name] {
+			if strings.HasPrefix(word, arg) {
+				items = append(items, completionItem{value: word})
+			}
+		}
+	}
+	if len(items) == 1 && items[0].value == arg {
+		return nil
+	}
+	return items
+}
+
+// mentionCompletions returns the files and directories an @path word can
+// be, relative to the working directory, leaving out those the file tools
+// skip (.gitignore and .gmnignore, .git and node_modules)
+func (a *App) mentionCompletions(word string) []completionItem {
+	partial := strings.ReplaceAll(word[1:], "\\ ", " ")
+	ignore := tools.NewIgnoreFilter(a.config.Cwd)
+	var items []completionItem
+	for _, path := range input.CompletePath(partial, a.config.Cwd, ignore.Ignored) {
+		detail := "file"
+		if strings.HasSuffix(path, "/") {
+			detail = "directory"
+		}
+		items = append(items, completionItem{value: "@" + strings.ReplaceAll(path, " ", "\\ "), detail: detail, more: true})
+	}
+	if len(items) == 1 && items[0].value == word {
+		return nil
+	}
+	return items
+}
+
+// handleCompletionKey moves through the suggestions with Up and Down and
+// takes the selected one with Tab or Enter. Enter goes on to run what it
+// completes unless there is more to type. Esc hides the suggestions
+// until the input changes. It reports whether the key was used up.
+func (a *App) handleCompletionKey(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyUp:
+		a.completion.Move(-1)
+	case tea.KeyDown:
+		a.completion.Move(1)
+	case tea.KeyEsc:
+		a.completionDismissed = a.input.Value()
+		a.completion.Set(a.completionDismissed, nil)
+	case tea.KeyTab:
+		a.acceptCompletion()
+	case tea.KeyEnter:
+		if msg.Alt || strings.Contains(msg.String(), "shift") {
+			return false // A new line
+		}
+		item := a.completion.Selected()
+		if lastWord(a.input.Value()) != item.value {
+			a.acceptCompletion()
+		}
+		return item.more
+	default:
+		return false
+	}
+	return true
+}
+
+// acceptCompletion replaces the word being typed with the selected
+// suggestion, followed by a space when there is more to type after it,
+// unless it is a directory to go on into
+func (a *App) acceptCompletion() {
+	item := a.completion.Selected()
+	value := a.input.Value()
+	value = value[:len(value)-len(lastWord(value))] + item.value
+	if item.more && !strings.HasSuffix(item.value, "/") {
+		value += " "
+	}
+	a.input.SetValue(value)
+}
@@ -5,12 +5,21 @@ package tui
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/guard"
+	"github.com/linkalls/gmn/internal/history"
+	"github.com/linkalls/gmn/internal/termcolor"
+	"github.com/linkalls/gmn/internal/textwidth"
+	"github.com/mattn/go-runewidth"
 )
 
 // HeaderModel represents the header component
@@ -46,7 +55,7 @@ func (h HeaderModel) View() string {
 	logo := lipgloss.NewStyle().
 		Foreground(AccentColor).
 		Bold(true).
-		Render("✨ gmn")
+		Render(glyphs.Label(glyphs.Current().Logo, "gmn"))
 
 	// Subtitle
 	subtitle := lipgloss.NewStyle().
@@ -54,21 +63,20 @@ func (h HeaderModel) View() string {
 		Render("Gemini CLI")
 
 	// Model badge with icon
-	modelIcon := "🤖"
-	modelBadge := ModelBadgeStyle.Render(modelIcon + " " + h.modelName)
+	modelBadge := ModelBadgeStyle.Render(glyphs.Label(glyphs.Current().Model, h.modelName))
 
 	// Build badges
 	var badges []string
 	badges = append(badges, modelBadge)
 
 	if h.yoloMode {
-		yoloBadge := YoloBadgeStyle.Render("⚡ YOLO")
+		yoloBadge := YoloBadgeStyle.Render(glyphs.Label(glyphs.Current().Yolo, "YOLO"))
 		badges = append(badges, yoloBadge)
 	}
 
 	// Status badge
 	statusBadge := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#000000")).
+		Foreground(termcolor.On("#000000", SuccessColor)).
 		Background(SuccessColor).
 		Padding(0, 1).
 		Bold(true).
@@ -76,7 +84,7 @@ func (h HeaderModel) View() string {
 	badges = append(badges, statusBadge)
 
 	// CWD badge with folder icon
-	cwdBadge := InfoBadgeStyle.Render("📁 " + truncatePath(h.cwd, 40))
+	cwdBadge := InfoBadgeStyle.Render(glyphs.Label(glyphs.Current().Folder, textwidth.TruncateLeft(h.cwd, 40)))
 
 	// Build header line with better spacing
 	headerLine := fmt.Sprintf("%s %s  %s", logo, subtitle, strings.Join(badges, " "))
@@ -87,14 +95,6 @@ func (h HeaderModel) View() string {
 	return HeaderStyle.Width(h.width).Render(content)
 }
 
-// truncatePath truncates a path for display
-func truncatePath(path string, maxLen int) string {
-	if len(path) <= maxLen {
-		return path
-	}
-	return "..." + path[len(path)-maxLen+3:]
-}
-
 // =============================================================================
 // Sidebar Component
 // =============================================================================
@@ -106,6 +106,40 @@ type SessionInfo struct {
 	Messages  int
 	UpdatedAt string
 	IsCurrent bool
+	ParentID  string
+	Depth     int // Branch depth below the nearest listed ancestor
+}
+
+// orderBranches places each branch directly below the session it was forked
+// from, keeping the original (newest first) order among siblings.
+func orderBranches(sessions []SessionInfo) []SessionInfo {
+	listed := make(map[string]bool, len(sessions))
+	for _, s := range sessions {
+		listed[s.ID] = true
+	}
+	children := make(map[string][]SessionInfo)
+	var roots []SessionInfo
+	for _, s := range sessions {
+		if s.ParentID != "" && listed[s.ParentID] && s.ParentID != s.ID {
+			children[s.ParentID] = append(children[s.ParentID], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	ordered := make([]SessionInfo, 0, len(sessions))
+	var add func(s SessionInfo, depth int)
+	add = func(s SessionInfo, depth int) {
+		s.Depth = depth
+		ordered = append(ordered, s)
+		for _, c := range children[s.ID] {
+			add(c, depth+1)
+		}
+	}
+	for _, r := range roots {
+		add(r, 0)
+	}
+	return ordered
 }
 
 // SidebarModel represents the sidebar component
@@ -187,7 +221,7 @@ func (s SidebarModel) View() string {
 	var b strings.Builder
 
 	// Title
-	title := SidebarTitleStyle.Render("📋 Sessions")
+	title := SidebarTitleStyle.Render(glyphs.Label(glyphs.Current().Sessions, "Sessions"))
 	b.WriteString(title)
 	b.WriteString("\n")
 
@@ -214,14 +248,18 @@ func (s SidebarModel) View() string {
 				name = sess.Name
 			}
 
+			// Indent branches below their parent
+			indent := ""
+			if sess.Depth > 0 {
+				indent = strings.Repeat(" ", sess.Depth-1) + "↳ "
+			}
+
 			// Truncate if needed
-			maxNameLen := s.width - 4
+			maxNameLen := s.width - 4 - textwidth.Width(indent)
 			if maxNameLen < 10 {
 				maxNameLen = 10
 			}
-			if len(name) > maxNameLen {
-				name = name[:maxNameLen-3] + "..."
-			}
+			name = textwidth.Truncate(name, maxNameLen)
 
 			// Style based on selection and current
 			var style lipgloss.Style
@@ -239,7 +277,7 @@ func (s SidebarModel) View() string {
 				icon = "▸ "
 			}
 
-			b.WriteString(style.Render(icon + name))
+			b.WriteString(style.Render(icon + indent + name))
 			b.WriteString("\n")
 
 			// Info line
@@ -286,18 +324,34 @@ type ChatMessage struct {
 	ToolArgs  string
 	Timestamp string
 	Rendered  string // Pre-rendered content for Markdown
+	Body      string // Pre-rendered tool result body (see renderToolBody)
+	// Result is the full result of a tool message, shown by expanding it
+	Result     map[string]interface{}
+	ResultTool string // Tool that returned Result
 }
 
-// ChatViewModel represents the chat display area
+// ChatViewModel represents the chat display area. Messages are rendered
+// once into lines and only the lines in view are handed to the viewport, so
+// long sessions do not slow down every redraw.
 type ChatViewModel struct {
-	viewport    viewport.Model
+	viewport    viewport.Model // Holds just the visible lines (see refresh)
 	messages    []ChatMessage
+	lines       [][]string // Rendered lines of each message, blank separator included
+	totalLines  int
+	offset      int // First line in view
 	width       int
 	height      int
 	focused     bool
 	renderer    *MarkdownRenderer
 	loading     bool
 	loadingText string
+	search      *regexp.Regexp // Highlighted search, nil when not searching
+	matches     []int          // Lines with a search match
+	match       int            // Current match in matches
+	searchFrom  int            // Line the search looks upward from
+	selecting   bool           // Selecting lines to copy
+	selAnchor   int            // Line the selection started at
+	selCursor   int            // Line the selection extends to
 }
 
 // NewChatViewModel creates a new chat view model
@@ -319,7 +373,7 @@ func (c *ChatViewModel) SetSize(width, height int) {
 	c.viewport.Width = width
 	c.viewport.Height = height
 	c.renderer.SetWidth(width - 4)
-	c.updateContent()
+	c.refresh()
 }
 
 // SetFocused sets focus state
@@ -340,39 +394,159 @@ func (c *ChatViewModel) AddMessage(msg ChatMessage) {
 		msg.Rendered = c.renderer.Render(msg.Content)
 	}
 	c.messages = append(c.messages, msg)
-	c.updateContent()
-	c.viewport.GotoBottom()
+	c.lines = append(c.lines, c.renderLines(msg))
+	c.totalLines += len(c.lines[len(c.lines)-1])
+	c.findMatches()
+	c.GotoBottom()
 }
 
 // UpdateLastMessage updates the last message (for streaming)
 func (c *ChatViewModel) UpdateLastMessage(content string) {
-	if len(c.messages) > 0 {
-		last := &c.messages[len(c.messages)-1]
-		last.Content = content
-		if last.Type == MessageTypeModel && c.renderer != nil {
-			last.Rendered = c.renderer.Render(content)
-		}
-		c.updateContent()
-		c.viewport.GotoBottom()
+	c.UpdateMessage(len(c.messages)-1, func(msg *ChatMessage) {
+		msg.Content = content
+	})
+}
+
+// MessageCount returns the number of messages, the index the next added
+// message gets
+func (c *ChatViewModel) MessageCount() int {
+	return len(c.messages)
+}
+
+// UpdateMessage changes message i and renders it again. Indexes that no
+// longer exist (e.g. after /clear) are ignored.
+func (c *ChatViewModel) UpdateMessage(i int, update func(*ChatMessage)) {
+	if i < 0 || i >= len(c.messages) {
+		return
 	}
+	msg := &c.messages[i]
+	update(msg)
+	if msg.Type == MessageTypeModel && c.renderer != nil {
+		msg.Rendered = c.renderer.Render(msg.Content)
+	}
+	c.totalLines -= len(c.lines[i])
+	c.lines[i] = c.renderLines(*msg)
+	c.totalLines += len(c.lines[i])
+	c.findMatches()
+	c.GotoBottom()
 }
 
 // Clear clears all messages
 func (c *ChatViewModel) Clear() {
 	c.messages = []ChatMessage{}
-	c.updateContent()
+	c.lines = nil
+	c.totalLines = 0
+	c.offset = 0
+	c.selecting = false
+	c.findMatches()
+	c.refresh()
 }
 
-// updateContent rebuilds the viewport content
-func (c *ChatViewModel) updateContent() {
-	var b strings.Builder
+// renderLines renders a message into lines, followed by a blank separator
+func (c *ChatViewModel) renderLines(msg ChatMessage) []string {
+	return append(strings.Split(c.renderMessage(msg), "\n"), "")
+}
+
+// refresh clamps the scroll offset and hands the lines in view to the
+// viewport. Messages above the offset are skipped by their line counts.
+func (c *ChatViewModel) refresh() {
+	height := c.viewport.Height
+	if maxOffset := c.totalLines - height; c.offset > maxOffset {
+		c.offset = maxOffset
+	}
+	if c.offset < 0 {
+		c.offset = 0
+	}
+
+	visible := make([]string, 0, height)
+	line := 0
+	for _, msgLines := range c.lines {
+		if len(visible) >= height {
+			break
+		}
+		if line+len(msgLines) <= c.offset {
+			line += len(msgLines)
+			continue
+		}
+		start := 0
+		if line < c.offset {
+			start = c.offset - line
+		}
+		for i, l := range msgLines[start:] {
+			if len(visible) >= height {
+				break
+			}
+			if c.selected(line + start + i) {
+				l = SelectionStyle.Render(ansi.Strip(l))
+			} else if c.search != nil {
+				l = c.highlight(l, line+start+i)
+			}
+			visible = append(visible, l)
+		}
+		line += len(msgLines)
+	}
+	c.viewport.SetContent(strings.Join(visible, "\n"))
+}
 
-	for _, msg := range c.messages {
-		b.WriteString(c.renderMessage(msg))
-		b.WriteString("\n\n")
+// MessageAt returns the index of the message shown on row of the view,
+// or -1 if the row is blank or past the last message
+func (c *ChatViewModel) MessageAt(row int) int {
+	line := c.offset + row
+	for i, msgLines := range c.lines {
+		if line < len(msgLines)-1 { // The last line is the blank separator
+			return i
+		}
+		line -= len(msgLines)
+		if line < 0 {
+			return -1
+		}
+	}
+	return -1
+}
+
+// LastVisibleMessage returns the index of the last message in view that
+// match accepts, or -1 if there is none
+func (c *ChatViewModel) LastVisibleMessage(match func(ChatMessage) bool) int {
+	for row := c.viewport.Height - 1; row >= 0; row-- {
+		if i := c.MessageAt(row); i >= 0 && match(c.messages[i]) {
+			return i
+		}
 	}
+	return -1
+}
+
+// LineUp scrolls up by n lines
+func (c *ChatViewModel) LineUp(n int) {
+	c.offset -= n
+	c.refresh()
+}
+
+// LineDown scrolls down by n lines
+func (c *ChatViewModel) LineDown(n int) {
+	c.offset += n
+	c.refresh()
+}
+
+// HalfViewUp scrolls up by half a screen
+func (c *ChatViewModel) HalfViewUp() {
+	c.LineUp(c.viewport.Height / 2)
+}
+
+// HalfViewDown scrolls down by half a screen
+func (c *ChatViewModel) HalfViewDown() {
+	c.LineDown(c.viewport.Height / 2)
+}
 
-	c.viewport.SetContent(b.String())
+// GotoTop scrolls to the first message
+func (c *ChatViewModel) GotoTop() {
+	c.offset = 0
+	c.refresh()
+}
+
+// GotoBottom scrolls to the newest message
+func (c *ChatViewModel) GotoBottom() {
+	c.offset = c.totalLines
+	c.refresh()
 }
 
 // renderMessage renders a single message
@@ -410,7 +584,7 @@ func (c *ChatViewModel) renderUserMessage(msg ChatMessage) string {
 }
 
 func (c *ChatViewModel) renderModelMessage(msg ChatMessage) string {
-	header := AccentStyle.Render("✨ Gemini")
+	header := AccentStyle.Render(glyphs.Label(glyphs.Current().Logo, "Gemini"))
 	if msg.Timestamp != "" {
 		header += " " + TimestampStyle.Render(msg.Timestamp)
 	}
@@ -424,7 +598,7 @@ func (c *ChatViewModel) renderModelMessage(msg ChatMessage) string {
 }
 
 func (c *ChatViewModel) renderToolMessage(msg ChatMessage) string {
-	header := ToolCallStyle.Render("⚡ TOOL") + " " + ToolNameStyle.Render(msg.ToolName)
+	header := ToolCallStyle.Render(glyphs.Label(glyphs.Current().Exec, "TOOL")) + " " + ToolNameStyle.Render(msg.ToolName)
 	if msg.ToolArgs != "" {
 		header += " " + ToolArgStyle.Render("→ "+msg.ToolArgs)
 	}
@@ -441,6 +615,10 @@ func (c *ChatViewModel) renderToolMessage(msg ChatMessage) string {
 		}
 	}
 
+	if msg.Body != "" {
+		content += "\n" + msg.Body
+	}
+
 	if content != "" {
 		return header + "\n" + content
 	}
@@ -455,11 +633,17 @@ func (c *ChatViewModel) renderSystemMessage(msg ChatMessage) string {
 	return DimStyle.Render("─── " + msg.Content + " ───")
 }
 
-// Update handles viewport updates
+// Update handles mouse wheel scrolling
 func (c *ChatViewModel) Update(msg tea.Msg) tea.Cmd {
-	var cmd tea.Cmd
-	c.viewport, cmd = c.viewport.Update(msg)
-	return cmd
+	if msg, ok := msg.(tea.MouseMsg); ok && msg.Action == tea.MouseActionPress {
+		switch msg.Button {
+		case tea.MouseButtonWheelUp:
+			c.LineUp(c.viewport.MouseWheelDelta)
+		case tea.MouseButtonWheelDown:
+			c.LineDown(c.viewport.MouseWheelDelta)
+		}
+	}
+	return nil
 }
 
 // View renders the chat view
@@ -488,25 +672,33 @@ func (c *ChatViewModel) View() string {
 // Input Component
 // =============================================================================
 
-// InputModel represents the text input component
+// maxInputRows is the number of rows the input grows to before scrolling
+const maxInputRows = 8
+
+// InputModel is the multi-line message editor. The text is kept as runes
+// and soft-wrapped to the input's width; the input grows with its text up
+// to maxInputRows rows.
 type InputModel struct {
-	value       string
-	cursor      int
+	value       []rune
+	cursor      int // Rune index into value
+	top         int // First visible row
 	width       int
-	height      int
 	focused     bool
 	placeholder string
 	history     []string
 	historyIdx  int
+	store       *history.History // Persisted history, nil if none
+}
+
+// inputRow is a row of the wrapped input, the runes value[start:end]
+type inputRow struct {
+	start, end int
 }
 
 // NewInputModel creates a new input model
 func NewInputModel() InputModel {
 	return InputModel{
-		value:       "",
-		cursor:      0,
 		placeholder: "Type a message... (Enter to send, Shift+Enter for new line)",
-		height:      3,
 		history:     []string{},
 		historyIdx:  -1,
 	}
@@ -524,42 +716,59 @@ func (i *InputModel) SetFocused(focused bool) {
 
 // Value returns the current value
 func (i *InputModel) Value() string {
-	return i.value
+	return string(i.value)
 }
 
-// SetValue sets the value
+// SetValue sets the value, with the cursor at the end
 func (i *InputModel) SetValue(value string) {
-	i.value = value
-	i.cursor = len(value)
+	i.value = []rune(value)
+	i.cursor = len(i.value)
+}
+
+// SetHistory loads the prompt history of the project, to which Reset then
+// adds each prompt sent
+func (i *InputModel) SetHistory(h *history.History) {
+	i.store = h
+	if h != nil {
+		i.history = h.Prompts()
+	}
+	i.historyIdx = -1
 }
 
 // Reset clears the input
 func (i *InputModel) Reset() {
 	// Add to history if not empty
-	if i.value != "" {
-		i.history = append(i.history, i.value)
+	if len(i.value) > 0 {
+		i.history = append(i.history, string(i.value))
+		if i.store != nil {
+			i.store.Add(string(i.value))
+		}
 	}
-	i.value = ""
+	i.value = nil
 	i.cursor = 0
+	i.top = 0
 	i.historyIdx = -1
 }
 
 // InsertChar inserts a character at cursor
 func (i *InputModel) InsertChar(c rune) {
-	i.value = i.value[:i.cursor] + string(c) + i.value[i.cursor:]
-	i.cursor++
+	i.InsertString(string(c))
 }
 
 // InsertString inserts a string at cursor
 func (i *InputModel) InsertString(s string) {
-	i.value = i.value[:i.cursor] + s + i.value[i.cursor:]
-	i.cursor += len(s)
+	runes := []rune(s)
+	value := make([]rune, 0, len(i.value)+len(runes))
+	value = append(value, i.value[:i.cursor]...)
+	value = append(value, runes...)
+	i.value = append(value, i.value[i.cursor:]...)
+	i.cursor += len(runes)
 }
 
 // DeleteChar deletes character before cursor (backspace)
 func (i *InputModel) DeleteChar() {
 	if i.cursor > 0 {
-		i.value = i.value[:i.cursor-1] + i.value[i.cursor:]
+		i.value = append(i.value[:i.cursor-1], i.value[i.cursor:]...)
 		i.cursor--
 	}
 }
@@ -567,7 +776,7 @@ func (i *InputModel) DeleteChar() {
 // DeleteCharForward deletes character at cursor (delete)
 func (i *InputModel) DeleteCharForward() {
 	if i.cursor < len(i.value) {
-		i.value = i.value[:i.cursor] + i.value[i.cursor+1:]
+		i.value = append(i.value[:i.cursor], i.value[i.cursor+1:]...)
 	}
 }
 
@@ -585,14 +794,42 @@ func (i *InputModel) MoveRight() {
 	}
 }
 
-// MoveToStart moves cursor to start
+// MoveToStart moves cursor to the start of its line
 func (i *InputModel) MoveToStart() {
-	i.cursor = 0
+	for i.cursor > 0 && i.value[i.cursor-1] != '\n' {
+		i.cursor--
+	}
 }
 
-// MoveToEnd moves cursor to end
+// MoveToEnd moves cursor to the end of its line
 func (i *InputModel) MoveToEnd() {
-	i.cursor = len(i.value)
+	for i.cursor < len(i.value) && i.value[i.cursor] != '\n' {
+		i.cursor++
+	}
+}
+
+// CursorUp moves the cursor to the row above, keeping its column where the
+// row is long enough. It reports false on the first row.
+func (i *InputModel) CursorUp() bool {
+	rows := i.rows()
+	row := i.cursorRow(rows)
+	if row == 0 {
+		return false
+	}
+	i.cursor = i.columnIndex(rows[row-1], i.column(rows[row]))
+	return true
+}
+
+// CursorDown moves the cursor to the row below, keeping its column where
+// the row is long enough. It reports false on the last row.
+func (i *InputModel) CursorDown() bool {
+	rows := i.rows()
+	row := i.cursorRow(rows)
+	if row == len(rows)-1 {
+		return false
+	}
+	i.cursor = i.columnIndex(rows[row+1], i.column(rows[row]))
+	return true
 }
 
 // HistoryUp navigates to previous history item
@@ -605,8 +842,7 @@ func (i *InputModel) HistoryUp() {
 	} else if i.historyIdx > 0 {
 		i.historyIdx--
 	}
-	i.value = i.history[i.historyIdx]
-	i.cursor = len(i.value)
+	i.SetValue(i.history[i.historyIdx])
 }
 
 // HistoryDown navigates to next history item
@@ -616,12 +852,16 @@ func (i *InputModel) HistoryDown() {
 	}
 	if i.historyIdx < len(i.history)-1 {
 		i.historyIdx++
-		i.value = i.history[i.historyIdx]
+		i.SetValue(i.history[i.historyIdx])
 	} else {
 		i.historyIdx = -1
-		i.value = ""
+		i.SetValue("")
 	}
-	i.cursor = len(i.value)
+}
+
+// History returns the history items, oldest first
+func (i *InputModel) History() []string {
+	return append([]string(nil), i.history...)
 }
 
 // DeleteWord deletes word before cursor
@@ -632,57 +872,137 @@ func (i *InputModel) DeleteWord() {
 
 	// Find start of word
 	start := i.cursor - 1
-	for start > 0 && i.value[start-1] == ' ' {
+	for start > 0 && unicode.IsSpace(i.value[start-1]) {
 		start--
 	}
-	for start > 0 && i.value[start-1] != ' ' {
+	for start > 0 && !unicode.IsSpace(i.value[start-1]) {
 		start--
 	}
 
-	i.value = i.value[:start] + i.value[i.cursor:]
+	i.value = append(i.value[:start], i.value[i.cursor:]...)
 	i.cursor = start
 }
 
-// DeleteLine clears the line
+// DeleteLine clears the input
 func (i *InputModel) DeleteLine() {
-	i.value = ""
+	i.value = nil
 	i.cursor = 0
 }
 
-// View renders the input
-func (i *InputModel) View() string {
-	prompt := InputPromptStyle.Render("❯ ")
+// Height returns the number of rows the input shows
+func (i *InputModel) Height() int {
+	return min(len(i.rows()), maxInputRows)
+}
 
-	var content string
-	if i.value == "" && !i.focused {
-		content = InputPlaceholderStyle.Render(i.placeholder)
-	} else {
-		// Show value with cursor
-		if i.focused {
-			before := i.value[:i.cursor]
-			after := i.value[i.cursor:]
-			cursor := InputCursorStyle.Render("█")
-			content = before + cursor + after
-		} else {
-			content = i.value
+// textWidth is the width text wraps at, leaving room for the prompt and a
+// cursor after the last character of a row
+func (i *InputModel) textWidth() int {
+	return max(i.width-InputContainerStyle.GetHorizontalFrameSize()-3, 1)
+}
+
+// rows wraps the value into rows at newlines and at the text width. Wide
+// characters (CJK, emoji) count as two columns.
+func (i *InputModel) rows() []inputRow {
+	width := i.textWidth()
+	var rows []inputRow
+	start, used := 0, 0
+	for n, r := range i.value {
+		if r == '\n' {
+			rows = append(rows, inputRow{start, n})
+			start, used = n+1, 0
+			continue
+		}
+		w := runewidth.RuneWidth(r)
+		if used+w > width && n > start {
+			rows = append(rows, inputRow{start, n})
+			start, used = n, 0
 		}
+		used += w
 	}
+	return append(rows, inputRow{start, len(i.value)})
+}
 
-	// Handle multiline
-	lines := strings.Split(content, "\n")
-	if len(lines) > 5 {
-		// Truncate to show last 5 lines
-		content = "...\n" + strings.Join(lines[len(lines)-5:], "\n")
+// cursorRow returns the row the cursor is on. At a soft wrap the cursor
+// belongs to the row that starts there.
+func (i *InputModel) cursorRow(rows []inputRow) int {
+	row := 0
+	for n, r := range rows {
+		if r.start <= i.cursor {
+			row = n
+		}
 	}
+	return row
+}
 
-	inputLine := prompt + content
+// column returns the display column of the cursor in row
+func (i *InputModel) column(row inputRow) int {
+	return runewidth.StringWidth(string(i.value[row.start:i.cursor]))
+}
+
+// columnIndex returns the rune index in row at display column col
+func (i *InputModel) columnIndex(row inputRow, col int) int {
+	used := 0
+	for n := row.start; n < row.end; n++ {
+		w := runewidth.RuneWidth(i.value[n])
+		if used+w > col {
+			return n
+		}
+		used += w
+	}
+	return row.end
+}
+
+// View renders the input
+func (i *InputModel) View() string {
+	prompt := InputPromptStyle.Render("❯ ")
+	indent := strings.Repeat(" ", lipgloss.Width(prompt))
 
 	style := InputContainerStyle.Width(i.width)
 	if i.focused {
 		style = style.Copy().BorderForeground(AccentColor)
 	}
 
-	return style.Render(inputLine)
+	if len(i.value) == 0 && !i.focused {
+		return style.Render(prompt + InputPlaceholderStyle.Render(i.placeholder))
+	}
+
+	// Scroll to keep the cursor row in view
+	rows := i.rows()
+	row := i.cursorRow(rows)
+	height := min(len(rows), maxInputRows)
+	i.top = max(0, min(i.top, len(rows)-height))
+	if row < i.top {
+		i.top = row
+	}
+	if row >= i.top+height {
+		i.top = row - height + 1
+	}
+
+	lines := make([]string, 0, height)
+	for n := i.top; n < i.top+height; n++ {
+		line := string(i.value[rows[n].start:rows[n].end])
+		if i.focused && n == row {
+			line = i.renderCursor(rows[n])
+		}
+		if n == 0 {
+			line = prompt + line
+		} else {
+			line = indent + line
+		}
+		lines = append(lines, line)
+	}
+	return style.Render(strings.Join(lines, "\n"))
+}
+
+// renderCursor renders row with the cursor, on the character under it or
+// after the last one
+func (i *InputModel) renderCursor(row inputRow) string {
+	before := string(i.value[row.start:i.cursor])
+	if i.cursor < row.end {
+		under := InputCursorStyle.Reverse(true).Render(string(i.value[i.cursor]))
+		return before + under + string(i.value[i.cursor+1:row.end])
+	}
+	return before + InputCursorStyle.Render("█")
 }
 
 // =============================================================================
@@ -694,11 +1014,20 @@ type StatusBarModel struct {
 	width        int
 	inputTokens  int
 	outputTokens int
+	context      int // Estimated tokens of the conversation
 	model        string
 	sessionID    string
+	mode         string
+	mouseOff     bool
 	helpText     string
 }
 
+// Fractions of the context window at which the gauge turns yellow and red
+const (
+	contextWarnAt   = 0.7
+	contextDangerAt = 0.9
+)
+
 // NewStatusBarModel creates a new status bar model
 func NewStatusBarModel() StatusBarModel {
 	return StatusBarModel{
@@ -717,6 +1046,11 @@ func (s *StatusBarModel) SetTokens(input, output int) {
 	s.outputTokens = output
 }
 
+// SetContext sets the estimated token count of the conversation
+func (s *StatusBarModel) SetContext(tokens int) {
+	s.context = tokens
+}
+
 // SetModel sets the model name
 func (s *StatusBarModel) SetModel(model string) {
 	s.model = model
@@ -727,6 +1061,17 @@ func (s *StatusBarModel) SetSessionID(sessionID string) {
 	s.sessionID = sessionID
 }
 
+// SetMouseOff shows that mouse capture is off
+func (s *StatusBarModel) SetMouseOff(off bool) {
+	s.mouseOff = off
+}
+
+// SetMode sets the status of a chat mode such as search or selection,
+// empty when none is active
+func (s *StatusBarModel) SetMode(mode string) {
+	s.mode = mode
+}
+
 // View renders the status bar
 func (s StatusBarModel) View() string {
 	// Left side: tokens
@@ -736,26 +1081,72 @@ func (s StatusBarModel) View() string {
 			s.inputTokens,
 			s.outputTokens)
 	}
+	if gauge := s.contextGauge(); gauge != "" {
+		left = strings.TrimSpace(gauge + "  " + StatusValueStyle.Render(left))
+	} else {
+		left = StatusValueStyle.Render(left)
+	}
+	if s.mode != "" {
+		left = strings.TrimSpace(StatusValueStyle.Render(s.mode) + "  " + left)
+	}
+	if s.mouseOff {
+		left = strings.TrimSpace(lipgloss.NewStyle().Foreground(WarningColor).Render("-- mouse off --") + "  " + left)
+	}
 
 	// Right side: help hints
 	right := s.helpText
 
 	// Calculate spacing
-	leftLen := len(left)
-	rightLen := len(right)
+	leftLen := lipgloss.Width(left)
+	rightLen := textwidth.Width(right)
 	spaces := s.width - leftLen - rightLen - 2
 
 	if spaces < 1 {
 		spaces = 1
 	}
 
-	content := StatusValueStyle.Render(left) +
+	content := left +
 		strings.Repeat(" ", spaces) +
 		HelpStyle.Render(right)
 
 	return StatusBarStyle.Width(s.width).Render(content)
 }
 
+// contextGauge renders how much of the model's context window the
+// conversation fills, e.g. "context: 34k/1M (3%)", yellow and then red as
+// it nears the limit. Without a known window only the size is shown.
+func (s StatusBarModel) contextGauge() string {
+	if s.context == 0 {
+		return ""
+	}
+	info, ok := guard.Info(s.model)
+	if !ok || info.Window == 0 {
+		return StatusValueStyle.Render("context: " + gaugeTokens(s.context))
+	}
+	used := float64(s.context) / float64(info.Window)
+	style := StatusValueStyle
+	switch {
+	case used >= contextDangerAt:
+		style = lipgloss.NewStyle().Foreground(DangerColor).Bold(true)
+	case used >= contextWarnAt:
+		style = lipgloss.NewStyle().Foreground(WarningColor)
+	}
+	return style.Render(fmt.Sprintf("context: %s/%s (%d%%)",
+		gaugeTokens(s.context), gaugeTokens(info.Window), int(used*100)))
+}
+
+// gaugeTokens renders a token count in whole thousands or millions where
+// that is exact enough, e.g. 34k or 1M
+func gaugeTokens(n int) string {
+	switch {
+	case n >= 1000000:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e6), ".0") + "M"
+	case n >= 1000:
+		return fmt.Sprintf("%dk", (n+500)/1000)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
 // =============================================================================
 // Spinner Component
 // =============================================================================
@@ -771,6 +1162,9 @@ type SpinnerModel struct {
 func NewSpinnerModel() SpinnerModel {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
+	if glyphs.ASCIIOnly() {
+		s.Spinner = spinner.Line
+	}
 	s.Style = SpinnerStyle
 
 	return SpinnerModel{
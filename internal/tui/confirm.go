@@ -4,12 +4,19 @@
 package tui
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/confirmation"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/termcolor"
+	"github.com/linkalls/gmn/internal/tools"
 )
 
 // ConfirmationType represents the type of confirmation
@@ -20,6 +27,7 @@ const (
 	ConfirmTypeCommand
 	ConfirmTypeNetwork
 	ConfirmTypeDangerous
+	ConfirmTypeTool // Other tools, e.g. MCP tools
 )
 
 // ConfirmChoice represents the user's choice
@@ -43,13 +51,14 @@ type ConfirmDialogModel struct {
 	filePath    string
 	command     string
 	url         string
-	oldContent  string
-	newContent  string
+	diffLines   []DiffLine
+	diffSummary string
 	selected    int
 	width       int
 	height      int
 	showDiff    bool
 	diffScroll  int
+	buttons     []string
 	resultChan  chan ConfirmChoice
 	onResult    func(ConfirmChoice)
 }
@@ -73,14 +82,47 @@ func (c *ConfirmDialogModel) Show(opts ConfirmDialogOptions) {
 	c.filePath = opts.FilePath
 	c.command = opts.Command
 	c.url = opts.URL
-	c.oldContent = opts.OldContent
-	c.newContent = opts.NewContent
+	c.setDiff(opts)
 	c.onResult = opts.OnResult
+	c.resultChan = opts.Result
+	c.buttons = opts.Buttons
+	if len(c.buttons) == 0 {
+		c.buttons = defaultButtons
+	}
 	c.selected = 0
-	c.showDiff = false
+	c.showDiff = opts.ShowDiff && c.hasDiff()
 	c.diffScroll = 0
 }
 
+// setDiff prepares the changes to show: the precomputed diff, or a diff of
+// the old and new content
+func (c *ConfirmDialogModel) setDiff(opts ConfirmDialogOptions) {
+	diff := opts.Diff
+	added, removed := 0, 0
+	if diff == "" && (opts.OldContent != "" || opts.NewContent != "") {
+		diff, added, removed = tools.UnifiedDiff(opts.FilePath, opts.OldContent, opts.NewContent)
+	}
+	c.diffLines = nil
+	c.diffSummary = ""
+	if diff == "" {
+		return
+	}
+	c.diffLines = parseUnifiedDiff(diff)
+	if added+removed > 0 {
+		c.diffSummary = fmt.Sprintf("+%d -%d lines", added, removed)
+	}
+}
+
+// hasDiff reports whether the dialog has changes to show
+func (c ConfirmDialogModel) hasDiff() bool {
+	return len(c.diffLines) > 0
+}
+
+// diffHeight is the number of diff lines shown at once
+func (c ConfirmDialogModel) diffHeight() int {
+	return max(10, c.height-20)
+}
+
 // Hide hides the dialog
 func (c *ConfirmDialogModel) Hide() {
 	c.visible = false
@@ -97,6 +139,9 @@ func (c *ConfirmDialogModel) SetSize(width, height int) {
 	c.height = height
 }
 
+// defaultButtons label the Yes, No and Always choices
+var defaultButtons = []string{"Yes", "No", "Always"}
+
 // ConfirmDialogOptions holds dialog options
 type ConfirmDialogOptions struct {
 	Type       ConfirmationType
@@ -109,7 +154,11 @@ type ConfirmDialogOptions struct {
 	URL        string
 	OldContent string
 	NewContent string
+	Diff       string   // Precomputed unified diff, shown instead of OldContent/NewContent
+	ShowDiff   bool     // Open with the diff shown
+	Buttons    []string // Labels of the Yes, No and Always choices; without a third, Always is not offered
 	OnResult   func(ConfirmChoice)
+	Result     chan ConfirmChoice // Receives the choice; needs room for it
 }
 
 // Update handles input
@@ -126,13 +175,13 @@ func (c *ConfirmDialogModel) Update(msg tea.Msg) tea.Cmd {
 				c.selected--
 			}
 		case key.Matches(msg, key.NewBinding(key.WithKeys("right", "l"))):
-			if c.selected < 2 {
+			if c.selected < len(c.buttons)-1 {
 				c.selected++
 			}
 		case key.Matches(msg, key.NewBinding(key.WithKeys("tab"))):
-			c.selected = (c.selected + 1) % 3
+			c.selected = (c.selected + 1) % len(c.buttons)
 		case key.Matches(msg, key.NewBinding(key.WithKeys("shift+tab"))):
-			c.selected = (c.selected + 2) % 3
+			c.selected = (c.selected + len(c.buttons) - 1) % len(c.buttons)
 		case key.Matches(msg, key.NewBinding(key.WithKeys("y", "Y"))):
 			c.selectChoice(ConfirmChoiceYes)
 			return nil
@@ -140,7 +189,9 @@ func (c *ConfirmDialogModel) Update(msg tea.Msg) tea.Cmd {
 			c.selectChoice(ConfirmChoiceNo)
 			return nil
 		case key.Matches(msg, key.NewBinding(key.WithKeys("a", "A"))):
-			c.selectChoice(ConfirmChoiceAlways)
+			if len(c.buttons) > 2 {
+				c.selectChoice(ConfirmChoiceAlways)
+			}
 			return nil
 		case key.Matches(msg, key.NewBinding(key.WithKeys("enter"))):
 			switch c.selected {
@@ -157,7 +208,7 @@ func (c *ConfirmDialogModel) Update(msg tea.Msg) tea.Cmd {
 			return nil
 		case key.Matches(msg, key.NewBinding(key.WithKeys("d"))):
 			// Toggle diff view
-			if c.oldContent != "" || c.newContent != "" {
+			if c.hasDiff() {
 				c.showDiff = !c.showDiff
 			}
 		case key.Matches(msg, key.NewBinding(key.WithKeys("up", "k"))):
@@ -165,7 +216,7 @@ func (c *ConfirmDialogModel) Update(msg tea.Msg) tea.Cmd {
 				c.diffScroll--
 			}
 		case key.Matches(msg, key.NewBinding(key.WithKeys("down", "j"))):
-			if c.showDiff {
+			if c.showDiff && c.diffScroll < len(c.diffLines)-c.diffHeight() {
 				c.diffScroll++
 			}
 		}
@@ -180,6 +231,10 @@ func (c *ConfirmDialogModel) selectChoice(choice ConfirmChoice) {
 	if c.onResult != nil {
 		c.onResult(choice)
 	}
+	if c.resultChan != nil {
+		c.resultChan <- choice
+		c.resultChan = nil
+	}
 }
 
 // View renders the dialog
@@ -191,9 +246,8 @@ func (c ConfirmDialogModel) View() string {
 	var b strings.Builder
 
 	// Icon and title
-	icon := c.getIcon()
 	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(c.getTitleColor())
-	b.WriteString(fmt.Sprintf("%s %s\n", icon, titleStyle.Render(c.title)))
+	b.WriteString(glyphs.Label(c.getIcon(), titleStyle.Render(c.title)) + "\n")
 	b.WriteString("\n")
 
 	// Message
@@ -212,6 +266,8 @@ func (c ConfirmDialogModel) View() string {
 		c.renderNetworkDetails(&b)
 	case ConfirmTypeDangerous:
 		c.renderDangerousDetails(&b)
+	case ConfirmTypeTool:
+		c.renderFileDetails(&b)
 	}
 
 	// Detail section
@@ -222,7 +278,7 @@ func (c ConfirmDialogModel) View() string {
 	}
 
 	// Diff view
-	if c.showDiff && (c.oldContent != "" || c.newContent != "") {
+	if c.showDiff && c.hasDiff() {
 		b.WriteString("\n")
 		b.WriteString(c.renderDiffView())
 		b.WriteString("\n")
@@ -234,8 +290,11 @@ func (c ConfirmDialogModel) View() string {
 
 	// Hints
 	b.WriteString("\n\n")
-	hints := []string{"y:Yes", "n:No", "a:Always"}
-	if c.oldContent != "" || c.newContent != "" {
+	var hints []string
+	for i, key := range []string{"y", "n", "a"}[:len(c.buttons)] {
+		hints = append(hints, key+":"+c.buttons[i])
+	}
+	if c.hasDiff() {
 		hints = append(hints, "d:Diff")
 	}
 	b.WriteString(lipgloss.NewStyle().Foreground(DimTextColor).Render(strings.Join(hints, "  ")))
@@ -256,22 +315,23 @@ func (c ConfirmDialogModel) View() string {
 
 // getIcon returns the appropriate icon
 func (c ConfirmDialogModel) getIcon() string {
+	g := glyphs.Current()
 	switch c.confirmType {
 	case ConfirmTypeFile:
-		return "📄"
+		return g.File
 	case ConfirmTypeCommand:
-		return "💻"
+		return g.Shell
 	case ConfirmTypeNetwork:
-		return "🌐"
+		return g.URL
 	case ConfirmTypeDangerous:
-		return "⚠️"
+		return g.Warning
 	default:
-		return "❓"
+		return g.Question
 	}
 }
 
 // getTitleColor returns the title color
-func (c ConfirmDialogModel) getTitleColor() lipgloss.Color {
+func (c ConfirmDialogModel) getTitleColor() lipgloss.TerminalColor {
 	switch c.confirmType {
 	case ConfirmTypeDangerous:
 		return DangerColor
@@ -292,6 +352,9 @@ func (c ConfirmDialogModel) renderFileDetails(b *strings.Builder) {
 	if c.filePath != "" {
 		b.WriteString(lipgloss.NewStyle().Foreground(DimTextColor).Render("File: "))
 		b.WriteString(lipgloss.NewStyle().Foreground(InfoColor).Render(c.filePath))
+		if c.diffSummary != "" {
+			b.WriteString(lipgloss.NewStyle().Foreground(DimTextColor).Render("  " + c.diffSummary))
+		}
 		b.WriteString("\n")
 	}
 }
@@ -323,7 +386,7 @@ func (c ConfirmDialogModel) renderDangerousDetails(b *strings.Builder) {
 	warning := lipgloss.NewStyle().
 		Foreground(DangerColor).
 		Bold(true).
-		Render("⚠️  This operation may be destructive!")
+		Render(glyphs.Label(glyphs.Current().Warning, "This operation may be destructive!"))
 	b.WriteString(warning)
 	b.WriteString("\n\n")
 	c.renderFileDetails(b)
@@ -341,10 +404,10 @@ func (c ConfirmDialogModel) renderDiffView() string {
 	b.WriteString(header)
 	b.WriteString("\n")
 
-	diffLines := computeDiff(c.oldContent, c.newContent)
+	diffLines := c.diffLines
 
 	// Calculate visible range
-	maxLines := 10
+	maxLines := c.diffHeight()
 	startLine := c.diffScroll
 	if startLine > len(diffLines)-maxLines {
 		startLine = len(diffLines) - maxLines
@@ -393,10 +456,9 @@ func (c ConfirmDialogModel) renderDiffView() string {
 
 // renderButtons renders the action buttons
 func (c ConfirmDialogModel) renderButtons() string {
-	buttons := []string{"Yes", "No", "Always"}
 	var rendered []string
 
-	for i, btn := range buttons {
+	for i, btn := range c.buttons {
 		var style lipgloss.Style
 		if i == c.selected {
 			// Selected button
@@ -417,41 +479,222 @@ func (c ConfirmDialogModel) renderButtons() string {
 	return lipgloss.JoinHorizontal(lipgloss.Center, rendered...)
 }
 
+// =============================================================================
+// Confirmations from Commands
+// =============================================================================
+
+// confirmMsg asks Update to show the confirmation dialog
+type confirmMsg ConfirmDialogOptions
+
+// confirm asks the user to allow an operation in the confirmation dialog of
+// the running TUI. It blocks until the user answers, so it is only called
+// from commands.
+func (a *App) confirm(details confirmation.Details) (confirmation.Outcome, error) {
+	if confirmation.YoloMode {
+		return confirmation.OutcomeProceedOnce, nil
+	}
+	if a.program == nil {
+		return confirmation.OutcomeCancel, errors.New("the TUI is not running")
+	}
+
+	result := make(chan ConfirmChoice, 1)
+	opts := dialogOptions(details)
+	opts.Result = result
+	a.send(confirmMsg(opts))
+
+	select {
+	case choice := <-result:
+		switch choice {
+		case ConfirmChoiceYes:
+			return confirmation.OutcomeProceedOnce, nil
+		case ConfirmChoiceAlways:
+			return confirmation.OutcomeProceedAlways, nil
+		}
+		return confirmation.OutcomeCancel, nil
+	case <-a.ctx.Done():
+		return confirmation.OutcomeCancel, a.ctx.Err()
+	}
+}
+
+// Confirmer confirms operations in the dialog of the TUI for code that is
+// set up before the TUI starts, e.g. the sampling handler of MCP servers.
+// Operations are declined while the TUI is not running.
+type Confirmer struct {
+	mu  sync.Mutex
+	app *App
+}
+
+// Confirm asks the user to allow an operation in the dialog of the running
+// TUI. It blocks until the user answers.
+func (c *Confirmer) Confirm(details confirmation.Details) (confirmation.Outcome, error) {
+	c.mu.Lock()
+	app := c.app
+	c.mu.Unlock()
+	if app == nil {
+		return confirmation.OutcomeCancel, errors.New("the TUI is not running")
+	}
+	return app.confirm(details)
+}
+
+// bind makes the confirmer ask in app's dialog, or decline if app is nil
+func (c *Confirmer) bind(app *App) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.app = app
+}
+
+// showNextConfirm opens the next queued confirmation once the dialog is
+// free. Tools running in parallel may ask at the same time.
+func (a *App) showNextConfirm() {
+	if a.confirmDlg.IsVisible() || len(a.confirmQueue) == 0 {
+		return
+	}
+	a.confirmDlg.Show(a.confirmQueue[0])
+	a.confirmQueue = a.confirmQueue[1:]
+}
+
+// toolDetails describes a tool call to confirm
+func toolDetails(tool tools.BuiltinTool, args map[string]interface{}) confirmation.Details {
+	details := confirmation.Details{
+		Type:     confirmation.ConfirmationType(tool.ConfirmationType()),
+		Title:    fmt.Sprintf("Allow %s?", tool.DisplayName()),
+		ToolName: tool.Name(),
+		Args:     args,
+	}
+
+	// Get file path if available
+	if path, ok := args["path"].(string); ok {
+		details.FilePath = path
+	}
+
+	// Get URL if available (for web_fetch)
+	if urlStr, ok := args["url"].(string); ok {
+		details.URL = urlStr
+	}
+
+	// Get command if available (for shell)
+	if cmd, ok := args["command"].(string); ok {
+		details.Command = cmd
+	}
+
+	// Get working directory, environment and stdin if available (for shell)
+	if dir, ok := args["cwd"].(string); ok {
+		details.Dir = dir
+	}
+	details.Env, _ = tools.EnvArg(args)
+	if stdin, ok := args["stdin"].(string); ok {
+		details.Stdin = stdin
+	}
+
+	// For edit confirmations, try to get diff content
+	if previewer, ok := tool.(interface {
+		Preview(map[string]interface{}) (string, error)
+	}); ok {
+		if diff, err := previewer.Preview(args); err == nil {
+			details.Diff = diff
+		}
+	} else if tool.ConfirmationType() == "edit" {
+		if getter, ok := tool.(interface {
+			GetOriginalContent(map[string]interface{}) (string, error)
+			GetNewContent(map[string]interface{}) (string, error)
+		}); ok {
+			if orig, err := getter.GetOriginalContent(args); err == nil {
+				details.OriginalContent = orig
+			}
+			if newC, err := getter.GetNewContent(args); err == nil {
+				details.NewContent = newC
+			}
+		}
+	}
+	return details
+}
+
+// dialogOptions describes a confirmation in the dialog
+func dialogOptions(details confirmation.Details) ConfirmDialogOptions {
+	opts := ConfirmDialogOptions{
+		Type:       ConfirmTypeTool,
+		Title:      details.Title,
+		ToolName:   details.ToolName,
+		FilePath:   details.FilePath,
+		Command:    details.Command,
+		URL:        details.URL,
+		OldContent: details.OriginalContent,
+		NewContent: details.NewContent,
+		Diff:       details.Diff,
+	}
+	var detail []string
+	switch details.Type {
+	case confirmation.TypeEdit:
+		opts.Type = ConfirmTypeFile
+		// Changes to files are reviewed before approving them
+		opts.ShowDiff = details.ToolName == "write_file" || details.ToolName == "edit_file"
+	case confirmation.TypeShell, confirmation.TypeExec:
+		opts.Type = ConfirmTypeCommand
+	case confirmation.TypeFetch:
+		opts.Type = ConfirmTypeNetwork
+	}
+	if details.Dir != "" {
+		detail = append(detail, "Directory: "+details.Dir)
+	}
+	for _, kv := range details.Env {
+		detail = append(detail, "Env: "+kv)
+	}
+	if details.Stdin != "" {
+		detail = append(detail, fmt.Sprintf("Stdin: %d lines, %d bytes", countLines(strings.TrimSuffix(details.Stdin, "\n")), len(details.Stdin)))
+	}
+	// Tools without a command or URL to show are described by their arguments
+	if opts.Type == ConfirmTypeTool && len(details.Args) > 0 {
+		args, _ := json.MarshalIndent(details.Args, "", "  ")
+		detail = append(detail, string(args))
+	}
+	opts.Detail = strings.Join(detail, "\n")
+	return opts
+}
+
 // =============================================================================
 // Confirm Dialog Styles
 // =============================================================================
 
 var (
+	ConfirmDialogStyle         lipgloss.Style
+	ButtonStyle                lipgloss.Style
+	ConfirmButtonSelectedStyle lipgloss.Style
+	CancelButtonSelectedStyle  lipgloss.Style
+	AlwaysButtonSelectedStyle  lipgloss.Style
+)
+
+// setConfirmStyles builds the styles of this file for the current theme
+func setConfirmStyles() {
 	ConfirmDialogStyle = lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(AccentColor).
-				Padding(1, 2).
-				Background(BackgroundColor)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(AccentColor).
+		Padding(1, 2).
+		Background(BackgroundColor)
 
 	ButtonStyle = lipgloss.NewStyle().
-			Foreground(TextColor).
-			Background(SurfaceColor).
-			Padding(0, 2).
-			MarginRight(1)
+		Foreground(TextColor).
+		Background(SurfaceColor).
+		Padding(0, 2).
+		MarginRight(1)
 
 	ConfirmButtonSelectedStyle = lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#FFFFFF")).
-					Background(SuccessColor).
-					Padding(0, 2).
-					MarginRight(1).
-					Bold(true)
+		Foreground(termcolor.On("#FFFFFF", SuccessColor)).
+		Background(SuccessColor).
+		Padding(0, 2).
+		MarginRight(1).
+		Bold(true)
 
 	CancelButtonSelectedStyle = lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#FFFFFF")).
-					Background(DangerColor).
-					Padding(0, 2).
-					MarginRight(1).
-					Bold(true)
+		Foreground(termcolor.On("#FFFFFF", DangerColor)).
+		Background(DangerColor).
+		Padding(0, 2).
+		MarginRight(1).
+		Bold(true)
 
 	AlwaysButtonSelectedStyle = lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#FFFFFF")).
-					Background(AccentColor).
-					Padding(0, 2).
-					MarginRight(1).
-					Bold(true)
-)
+		Foreground(termcolor.On("#FFFFFF", AccentColor)).
+		Background(AccentColor).
+		Padding(0, 2).
+		MarginRight(1).
+		Bold(true)
+}
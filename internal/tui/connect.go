@@ -0,0 +1,56 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/linkalls/gmn/internal/api"
+)
+
+// Connection is an API client ready for requests
+type Connection struct {
+	Client    *api.Client
+	ProjectID string
+	Model     string // Default model for the user's tier ("" keeps Config.Model)
+	TierModel string // Default model of the user's tier, marked in the model picker
+}
+
+// Connector returns the connection, waiting until the credentials have been
+// refreshed and the project discovered. Every call returns the same result.
+// It may block, so it is only called from commands, never from Update.
+type Connector func() (Connection, error)
+
+// connectedMsg reports that the background connection finished
+type connectedMsg struct {
+	conn Connection
+	err  error
+}
+
+// awaitConnection waits for the connection started before the first frame
+func (a *App) awaitConnection() tea.Msg {
+	conn, err := a.connect()
+	return connectedMsg{conn: conn, err: err}
+}
+
+// handleConnected switches to the tier's default model unless the user has
+// picked one in the meantime, and reports connection failures
+func (a *App) handleConnected(msg connectedMsg) {
+	if msg.err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Failed to connect: " + msg.err.Error(),
+		})
+		return
+	}
+	a.tierModel = msg.conn.TierModel
+	if msg.conn.Model != "" && a.config.Model == a.defaultModel {
+		a.config.Model = msg.conn.Model
+		a.defaultModel = msg.conn.Model
+		a.header.SetModel(msg.conn.Model)
+		a.statusBar.SetModel(msg.conn.Model)
+		if a.session != nil && len(a.history) == 0 {
+			a.session.Model = msg.conn.Model
+		}
+	}
+}
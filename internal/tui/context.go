@@ -6,10 +6,14 @@ package tui
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/textwidth"
+	"github.com/linkalls/gmn/internal/tools"
 )
 
 // ContextItem represents an item in the context
@@ -19,6 +23,7 @@ type ContextItem struct {
 	Name      string
 	Size      int64
 	LineCount int
+	Files     int // Files a glob matched
 	AddedAt   time.Time
 }
 
@@ -40,6 +45,8 @@ type ActivityItem struct {
 	Status    ActivityStatus
 	Timestamp time.Time
 	Duration  time.Duration
+
+	id int // Position in the activity log
 }
 
 // ActivityType represents the type of activity
@@ -71,6 +78,10 @@ type ContextPanelModel struct {
 	contextItems   []ContextItem
 	activities     []ActivityItem
 	maxActivities  int
+	log            []ActivityItem // Every activity of the session, oldest first
+	nextID         int
+	todos          []tools.Todo // Task list from write_todos
+	jobs           *tools.Jobs  // Background shell commands
 	showContext    bool
 	showActivities bool
 	focused        bool
@@ -98,9 +109,10 @@ func (c *ContextPanelModel) SetFocused(focused bool) {
 	c.focused = focused
 }
 
-// AddContextItem adds a context item
+// AddContextItem adds a context item, replacing one with the same path
 func (c *ContextPanelModel) AddContextItem(item ContextItem) {
 	item.AddedAt = time.Now()
+	c.RemoveContextItem(item.Path)
 	c.contextItems = append(c.contextItems, item)
 }
 
@@ -114,30 +126,87 @@ func (c *ContextPanelModel) RemoveContextItem(path string) {
 	}
 }
 
+// ContextItems returns the context items in the order they were added
+func (c *ContextPanelModel) ContextItems() []ContextItem {
+	return append([]ContextItem(nil), c.contextItems...)
+}
+
 // ClearContext clears all context items
 func (c *ContextPanelModel) ClearContext() {
 	c.contextItems = []ContextItem{}
 }
 
+// maxActivityLog caps the activity log kept for /export activity
+const maxActivityLog = 10000
+
 // AddActivity adds an activity item
 func (c *ContextPanelModel) AddActivity(activity ActivityItem) {
 	activity.Timestamp = time.Now()
+	activity.id = c.nextID
+	c.nextID++
 	c.activities = append([]ActivityItem{activity}, c.activities...)
 
 	// Trim to max activities
 	if len(c.activities) > c.maxActivities {
 		c.activities = c.activities[:c.maxActivities]
 	}
+
+	c.log = append(c.log, activity)
+	if len(c.log) > maxActivityLog {
+		c.log = c.log[len(c.log)-maxActivityLog:]
+	}
 }
 
 // UpdateLastActivity updates the most recent activity
 func (c *ContextPanelModel) UpdateLastActivity(status ActivityStatus, duration time.Duration) {
 	if len(c.activities) > 0 {
-		c.activities[0].Status = status
-		c.activities[0].Duration = duration
+		c.finishActivity(&c.activities[0], status, duration)
+	}
+}
+
+// UpdateOldestRunningActivity updates the earliest activity still running,
+// so results of tools that ran together are applied in call order
+func (c *ContextPanelModel) UpdateOldestRunningActivity(status ActivityStatus, duration time.Duration) {
+	for i := len(c.activities) - 1; i >= 0; i-- {
+		if c.activities[i].Status == ActivityStatusRunning {
+			c.finishActivity(&c.activities[i], status, duration)
+			return
+		}
 	}
 }
 
+// finishActivity sets the outcome of an activity in the feed and the log.
+// Without an explicit duration, the time since it started is used.
+func (c *ContextPanelModel) finishActivity(item *ActivityItem, status ActivityStatus, duration time.Duration) {
+	if duration == 0 && status != ActivityStatusRunning {
+		duration = time.Since(item.Timestamp)
+	}
+	item.Status = status
+	item.Duration = duration
+
+	if len(c.log) > 0 {
+		if i := item.id - c.log[0].id; i >= 0 && i < len(c.log) {
+			c.log[i].Status = status
+			c.log[i].Duration = duration
+		}
+	}
+}
+
+// ActivityLog returns every activity of the session, oldest first
+func (c *ContextPanelModel) ActivityLog() []ActivityItem {
+	return append([]ActivityItem(nil), c.log...)
+}
+
+// SetTodos replaces the task list shown in the panel
+func (c *ContextPanelModel) SetTodos(todos []tools.Todo) {
+	c.todos = todos
+}
+
+// SetJobs sets the background jobs shown in the panel
+func (c *ContextPanelModel) SetJobs(jobs *tools.Jobs) {
+	c.jobs = jobs
+}
+
 // ToggleContext toggles context display
 func (c *ContextPanelModel) ToggleContext() {
 	c.showContext = !c.showContext
@@ -157,6 +226,18 @@ func (c ContextPanelModel) View() string {
 		sections = append(sections, c.renderContext())
 	}
 
+	// Task list section, once the model has written one
+	if len(c.todos) > 0 {
+		sections = append(sections, c.renderTodos())
+	}
+
+	// Background jobs section, once one was started
+	if c.jobs != nil {
+		if jobs := c.jobs.List(); len(jobs) > 0 {
+			sections = append(sections, c.renderJobs(jobs))
+		}
+	}
+
 	// Activities section
 	if c.showActivities {
 		sections = append(sections, c.renderActivities())
@@ -180,7 +261,7 @@ func (c ContextPanelModel) renderContext() string {
 	title := lipgloss.NewStyle().
 		Foreground(AccentColor).
 		Bold(true).
-		Render("📂 Context")
+		Render(glyphs.Label(glyphs.Current().Context, "Context"))
 	b.WriteString(title)
 	b.WriteString("\n")
 
@@ -203,16 +284,16 @@ func (c ContextPanelModel) renderContextItem(item ContextItem) string {
 
 	switch item.Type {
 	case ContextTypeFile:
-		icon = c.getFileIcon(item.Path)
+		icon = glyphs.Current().FileIcon(item.Path)
 		style = lipgloss.NewStyle().Foreground(InfoColor)
 	case ContextTypeDirectory:
-		icon = "📁"
+		icon = glyphs.Current().Folder
 		style = lipgloss.NewStyle().Foreground(WarningColor)
 	case ContextTypeURL:
-		icon = "🌐"
+		icon = glyphs.Current().URL
 		style = lipgloss.NewStyle().Foreground(AccentColor)
 	case ContextTypeClipboard:
-		icon = "📋"
+		icon = glyphs.Current().Clipboard
 		style = lipgloss.NewStyle().Foreground(SuccessColor)
 	}
 
@@ -226,23 +307,110 @@ func (c ContextPanelModel) renderContextItem(item ContextItem) string {
 	if maxLen < 10 {
 		maxLen = 10
 	}
-	if len(name) > maxLen {
-		name = name[:maxLen-3] + "..."
-	}
+	name = textwidth.Truncate(name, maxLen)
 
 	// Info line
 	info := ""
 	if item.LineCount > 0 {
 		info = fmt.Sprintf(" (%d lines)", item.LineCount)
+	} else if item.Files > 0 {
+		info = fmt.Sprintf(" (%d files)", item.Files)
 	} else if item.Size > 0 {
 		info = fmt.Sprintf(" (%s)", formatSize(item.Size))
 	}
 
-	return fmt.Sprintf("  %s %s%s",
-		icon,
-		style.Render(name),
-		lipgloss.NewStyle().Foreground(DimTextColor).Render(info),
-	)
+	return "  " + glyphs.Label(icon, style.Render(name)+lipgloss.NewStyle().Foreground(DimTextColor).Render(info))
+}
+
+// renderTodos renders the task list as a checklist
+func (c ContextPanelModel) renderTodos() string {
+	var b strings.Builder
+
+	divider := lipgloss.NewStyle().Foreground(BorderColor).Render(strings.Repeat("─", c.width-4))
+	b.WriteString(divider)
+	b.WriteString("\n")
+
+	done, total := 0, 0
+	for _, todo := range c.todos {
+		if todo.Status != tools.TodoCancelled {
+			total++
+		}
+		if todo.Status == tools.TodoCompleted {
+			done++
+		}
+	}
+	title := lipgloss.NewStyle().
+		Foreground(AccentColor).
+		Bold(true).
+		Render(glyphs.Label(glyphs.Current().Tasks, "Tasks"))
+	b.WriteString(title)
+	b.WriteString(lipgloss.NewStyle().Foreground(DimTextColor).Render(fmt.Sprintf(" %d/%d", done, total)))
+	b.WriteString("\n")
+
+	maxLen := c.width - 8
+	if maxLen < 10 {
+		maxLen = 10
+	}
+	for _, todo := range c.todos {
+		var style lipgloss.Style
+		switch todo.Status {
+		case tools.TodoCompleted:
+			style = lipgloss.NewStyle().Foreground(SuccessColor)
+		case tools.TodoInProgress:
+			style = lipgloss.NewStyle().Foreground(AccentColor).Bold(true)
+		case tools.TodoCancelled:
+			style = lipgloss.NewStyle().Foreground(MutedColor).Strikethrough(true)
+		default:
+			style = lipgloss.NewStyle().Foreground(DimTextColor)
+		}
+		desc := textwidth.Truncate(todo.Description, maxLen)
+		fmt.Fprintf(&b, "  %s %s\n", style.Render(tools.TodoMark(todo.Status)), style.Render(desc))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// renderJobs renders the background jobs, running ones first
+func (c ContextPanelModel) renderJobs(jobs []tools.JobStatus) string {
+	var b strings.Builder
+
+	divider := lipgloss.NewStyle().Foreground(BorderColor).Render(strings.Repeat("─", c.width-4))
+	b.WriteString(divider)
+	b.WriteString("\n")
+
+	title := lipgloss.NewStyle().
+		Foreground(AccentColor).
+		Bold(true).
+		Render(glyphs.Label(glyphs.Current().Jobs, "Jobs"))
+	b.WriteString(title)
+	b.WriteString("\n")
+
+	sort.SliceStable(jobs, func(i, k int) bool { return jobs[i].Running && !jobs[k].Running })
+	maxLen := c.width - 18
+	if maxLen < 10 {
+		maxLen = 10
+	}
+	for _, job := range jobs {
+		var mark string
+		var style lipgloss.Style
+		switch {
+		case job.Running:
+			mark, style = "◐", lipgloss.NewStyle().Foreground(AccentColor)
+		case job.ExitCode == 0:
+			mark, style = "✓", lipgloss.NewStyle().Foreground(SuccessColor)
+		default:
+			mark, style = "✗", lipgloss.NewStyle().Foreground(DangerColor)
+		}
+		command := textwidth.Truncate(strings.Join(strings.Fields(job.Command), " "), maxLen)
+		fmt.Fprintf(&b, "  %s %s %s%s\n",
+			style.Render(mark),
+			lipgloss.NewStyle().Foreground(DimTextColor).Render(fmt.Sprintf("#%d", job.ID)),
+			style.Render(command),
+			lipgloss.NewStyle().Foreground(DimTextColor).Render(" "+job.Duration.Round(time.Second).String()),
+		)
+	}
+
+	return strings.TrimRight(b.String(), "\n")
 }
 
 // renderActivities renders the activities section
@@ -257,7 +425,7 @@ func (c ContextPanelModel) renderActivities() string {
 	title := lipgloss.NewStyle().
 		Foreground(AccentColor).
 		Bold(true).
-		Render("⚡ Activity")
+		Render(glyphs.Label(glyphs.Current().Activity, "Activity"))
 	b.WriteString(title)
 	b.WriteString("\n")
 
@@ -298,19 +466,20 @@ func (c ContextPanelModel) renderActivityItem(item ActivityItem) string {
 	var style lipgloss.Style
 
 	// Activity type icon
+	g := glyphs.Current()
 	switch item.Type {
 	case ActivityTypeAPI:
-		icon = "🔮"
+		icon = g.API
 	case ActivityTypeTool:
-		icon = "⚙️"
+		icon = g.Tool
 	case ActivityTypeFile:
-		icon = "📄"
+		icon = g.File
 	case ActivityTypeSearch:
-		icon = "🔍"
+		icon = g.Search
 	case ActivityTypeShell:
-		icon = "💻"
+		icon = g.Shell
 	case ActivityTypeThinking:
-		icon = "💭"
+		icon = g.Thinking
 	}
 
 	// Status icon and style
@@ -335,9 +504,7 @@ func (c ContextPanelModel) renderActivityItem(item ActivityItem) string {
 	if maxLen < 10 {
 		maxLen = 10
 	}
-	if len(title) > maxLen {
-		title = title[:maxLen-3] + "..."
-	}
+	title = textwidth.Truncate(title, maxLen)
 
 	// Duration
 	duration := ""
@@ -347,37 +514,11 @@ func (c ContextPanelModel) renderActivityItem(item ActivityItem) string {
 		)
 	}
 
-	return fmt.Sprintf("  %s %s %s%s",
-		icon,
+	return "  " + glyphs.Label(icon, fmt.Sprintf("%s %s%s",
 		style.Render(statusIcon),
 		style.Render(title),
 		duration,
-	)
-}
-
-// getFileIcon returns an icon for a file based on extension
-func (c ContextPanelModel) getFileIcon(path string) string {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".go":
-		return "🔵"
-	case ".py":
-		return "🐍"
-	case ".js", ".ts":
-		return "🟨"
-	case ".rs":
-		return "🦀"
-	case ".md":
-		return "📝"
-	case ".json", ".yaml", ".yml":
-		return "📋"
-	case ".html", ".css":
-		return "🌐"
-	case ".sh", ".bash":
-		return "💻"
-	default:
-		return "📄"
-	}
+	))
 }
 
 // formatSize formats a file size
@@ -398,7 +539,12 @@ func formatSize(size int64) string {
 // Context Panel Style
 // =============================================================================
 
-var ContextPanelStyle = lipgloss.NewStyle().
-	Border(lipgloss.NormalBorder(), false, false, false, true).
-	BorderForeground(BorderColor).
-	Padding(0, 1)
+var ContextPanelStyle lipgloss.Style
+
+// setContextStyles builds the styles of this file for the current theme
+func setContextStyles() {
+	ContextPanelStyle = lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(BorderColor).
+		Padding(0, 1)
+}
@@ -0,0 +1,202 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// globKeyPrefix marks context items that stand for a glob's matches
+const globKeyPrefix = "glob:"
+
+// trackToolContext lists the files a tool read, or the files a glob matched,
+// in the context panel
+func (a *App) trackToolContext(toolName string, result map[string]interface{}) {
+	if _, failed := result["error"]; failed {
+		return
+	}
+	switch toolName {
+	case "read_file":
+		path, _ := result["path"].(string)
+		if path == "" {
+			return
+		}
+		item := ContextItem{
+			Type: ContextTypeFile,
+			Path: path,
+			Name: a.displayPath(path),
+		}
+		if content, ok := result["content"].(string); ok {
+			item.Size = int64(len(content))
+		}
+		if lines, ok := result["total_lines"].(int); ok {
+			item.LineCount = lines
+		} else if size, ok := result["size"].(int); ok {
+			item.Size = int64(size) // Images
+		}
+		a.contextPanel.AddContextItem(item)
+	case "glob":
+		pattern, _ := result["pattern"].(string)
+		count, _ := result["count"].(int)
+		if pattern == "" {
+			return
+		}
+		a.contextPanel.AddContextItem(ContextItem{
+			Type:  ContextTypeDirectory,
+			Path:  globKeyPrefix + pattern,
+			Name:  pattern,
+			Files: count,
+		})
+	}
+}
+
+// contextKey returns the context panel path of a file attached by path,
+// which is absolute like the paths tools report
+func (a *App) contextKey(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(a.config.Cwd, path)
+}
+
+// displayPath shows path relative to the working directory when inside it
+func (a *App) displayPath(path string) string {
+	if rel, err := filepath.Rel(a.config.Cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// contextCommand handles /context list and /context drop <n|name>
+func (a *App) contextCommand(args string) {
+	sub, target, _ := strings.Cut(strings.TrimSpace(args), " ")
+	switch sub {
+	case "", "list":
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeSystem,
+			Content: a.formatContextItems(),
+		})
+	case "drop":
+		if err := a.dropContext(strings.TrimSpace(target)); err != nil {
+			a.chatView.AddMessage(ChatMessage{
+				Type:    MessageTypeError,
+				Content: err.Error(),
+			})
+		}
+	default:
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Usage: /context [list] or /context drop <number|name>",
+		})
+	}
+}
+
+// formatContextItems numbers the context items for /context list
+func (a *App) formatContextItems() string {
+	items := a.contextPanel.ContextItems()
+	if len(items) == 0 {
+		return "No files in context"
+	}
+	var b strings.Builder
+	b.WriteString("Context")
+	for i, item := range items {
+		fmt.Fprintf(&b, "\n  %d. %s", i+1, item.Name)
+		switch {
+		case item.LineCount > 0:
+			fmt.Fprintf(&b, " (%d lines)", item.LineCount)
+		case item.Files > 0:
+			fmt.Fprintf(&b, " (%d files)", item.Files)
+		case item.Size > 0:
+			fmt.Fprintf(&b, " (%s)", formatSize(item.Size))
+		}
+	}
+	b.WriteString("\n/context drop <number|name> removes an item")
+	return b.String()
+}
+
+// dropContext removes a context item. The content of files read by tools
+// is removed from the conversation as well, and a paste not sent yet is
+// discarded; attachments already sent stay in their messages.
+func (a *App) dropContext(target string) error {
+	if target == "" {
+		return fmt.Errorf("usage: /context drop <number|name>")
+	}
+	items := a.contextPanel.ContextItems()
+	var item *ContextItem
+	if n, err := strconv.Atoi(target); err == nil && n >= 1 && n <= len(items) {
+		item = &items[n-1]
+	} else {
+		for i := range items {
+			if items[i].Name == target || items[i].Path == target || items[i].Path == a.contextKey(target) {
+				item = &items[i]
+				break
+			}
+		}
+	}
+	if item == nil {
+		return fmt.Errorf("no context item %q (see /context list)", target)
+	}
+	a.contextPanel.RemoveContextItem(item.Path)
+
+	note := "its content stays in the messages already sent"
+	if removed := a.dropToolResults(item.Path); removed > 0 {
+		note = fmt.Sprintf("removed from %d tool result(s)", removed)
+		a.autoSave()
+	} else {
+		for i, pasted := range a.pasted {
+			if pasted.key == item.Path {
+				a.pasted = append(a.pasted[:i], a.pasted[i+1:]...)
+				note = "it will not be sent"
+				break
+			}
+		}
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: fmt.Sprintf("Dropped %s from context; %s", item.Name, note),
+	})
+	return nil
+}
+
+// dropToolResults replaces the read_file results of a file, or the glob
+// results of a pattern, in the history with a note, and returns how many
+// were replaced. Images read_file attached to its result are removed.
+func (a *App) dropToolResults(key string) int {
+	pattern, isGlob := strings.CutPrefix(key, globKeyPrefix)
+	removed := 0
+	for i := range a.history {
+		parts := a.history[i].Parts
+		for j := 0; j < len(parts); j++ {
+			resp := parts[j].FunctionResp
+			if resp == nil || resp.Response["dropped"] == true {
+				continue
+			}
+			switch {
+			case isGlob && resp.Name == "glob" && resp.Response["pattern"] == pattern:
+				resp.Response = map[string]interface{}{
+					"pattern": pattern,
+					"dropped": true,
+					"notice":  "The user dropped these matches from the context. Run glob again if you need them.",
+				}
+			case !isGlob && resp.Name == "read_file" && resp.Response["path"] == key:
+				resp.Response = map[string]interface{}{
+					"path":    key,
+					"dropped": true,
+					"notice":  "The user dropped this file from the context. Read it again if you need its content.",
+				}
+				if j+1 < len(parts) && parts[j+1].InlineData != nil {
+					parts = append(parts[:j+1], parts[j+2:]...)
+				}
+			default:
+				continue
+			}
+			removed++
+		}
+		a.history[i].Parts = parts
+	}
+	return removed
+}
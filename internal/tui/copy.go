@@ -0,0 +1,320 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/linkalls/gmn/internal/clipboard"
+	"github.com/linkalls/gmn/internal/fence"
+)
+
+// =============================================================================
+// Chat View Selection
+// =============================================================================
+
+// StartSelection starts selecting lines, from the last line in view
+func (c *ChatViewModel) StartSelection() {
+	if c.totalLines == 0 {
+		return
+	}
+	c.selecting = true
+	c.selCursor = min(c.offset+c.viewport.Height, c.totalLines) - 1
+	c.selAnchor = c.selCursor
+	c.refresh()
+}
+
+// EndSelection removes the selection
+func (c *ChatViewModel) EndSelection() {
+	c.selecting = false
+	c.refresh()
+}
+
+// Selecting reports whether lines are being selected
+func (c *ChatViewModel) Selecting() bool {
+	return c.selecting
+}
+
+// MoveSelection moves the end of the selection by n lines, scrolling to
+// keep it in view
+func (c *ChatViewModel) MoveSelection(n int) {
+	c.selCursor = max(0, min(c.selCursor+n, c.totalLines-1))
+	if c.selCursor < c.offset {
+		c.offset = c.selCursor
+	}
+	if c.selCursor >= c.offset+c.viewport.Height {
+		c.offset = c.selCursor - c.viewport.Height + 1
+	}
+	c.refresh()
+}
+
+// selected reports whether line is in the selection
+func (c *ChatViewModel) selected(line int) bool {
+	return c.selecting && line >= min(c.selAnchor, c.selCursor) && line <= max(c.selAnchor, c.selCursor)
+}
+
+// Selection returns the text of the selected lines as shown, without
+// styling
+func (c *ChatViewModel) Selection() string {
+	var out []string
+	line := 0
+	for _, msgLines := range c.lines {
+		for _, l := range msgLines {
+			if c.selected(line) {
+				out = append(out, strings.TrimRight(ansi.Strip(l), " "))
+			}
+			line++
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// LastResponse returns the content of the last model message, or "" if
+// the model has not answered yet
+func (c *ChatViewModel) LastResponse() string {
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		if c.messages[i].Type == MessageTypeModel {
+			return c.messages[i].Content
+		}
+	}
+	return ""
+}
+
+// =============================================================================
+// Copy Actions
+// =============================================================================
+
+// copiedMsg reports the result of copying to the clipboard
+type copiedMsg struct {
+	what string
+	err  error
+}
+
+// copyText puts text on the clipboard in the background. what names the
+// copied text in the confirmation.
+func (a *App) copyText(text, what string) tea.Cmd {
+	return func() tea.Msg {
+		return copiedMsg{what: what, err: clipboard.Write(text)}
+	}
+}
+
+// handleCopied confirms a copy, or shows why it failed
+func (a *App) handleCopied(msg copiedMsg) {
+	if msg.err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Copy failed: " + msg.err.Error(),
+		})
+		return
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: "Copied " + msg.what + " to the clipboard",
+	})
+}
+
+// copyResponse copies the markdown of the last model response
+func (a *App) copyResponse() tea.Cmd {
+	text := a.chatView.LastResponse()
+	if text == "" {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "No response to copy yet",
+		})
+		return nil
+	}
+	return a.copyText(text, "the last response")
+}
+
+// codeBlock returns the code block of the last model response numbered
+// arg, as shown in its header, or the last block for "code"
+func (a *App) codeBlock(arg string) (fence.Block, int, error) {
+	blocks := fence.Blocks(a.chatView.LastResponse())
+	if len(blocks) == 0 {
+		return fence.Block{}, 0, errors.New("the last response has no code block")
+	}
+	if arg == "code" {
+		return blocks[len(blocks)-1], len(blocks), nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(blocks) {
+		return fence.Block{}, 0, fmt.Errorf("no code block %s (the last response has %d)", arg, len(blocks))
+	}
+	return blocks[n-1], n, nil
+}
+
+// describeBlock names code block n in messages, with its language
+func describeBlock(block fence.Block, n int) string {
+	if block.Lang != "" {
+		return fmt.Sprintf("code block %d (%s)", n, block.Lang)
+	}
+	return fmt.Sprintf("code block %d", n)
+}
+
+// copyCode copies a code block of the last model response (see codeBlock)
+func (a *App) copyCode(arg string) tea.Cmd {
+	block, n, err := a.codeBlock(arg)
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Copy failed: " + err.Error(),
+		})
+		return nil
+	}
+	return a.copyText(block.Code, describeBlock(block, n))
+}
+
+// copyCommand handles /copy, /copy code and /copy N
+func (a *App) copyCommand(args string) tea.Cmd {
+	if args == "" {
+		return a.copyResponse()
+	}
+	if len(strings.Fields(args)) == 1 {
+		return a.copyCode(args)
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeError,
+		Content: "Usage: /copy [code|N]",
+	})
+	return nil
+}
+
+// =============================================================================
+// Saving Code Blocks
+// =============================================================================
+
+// saveCode handles /savecode N <path>, writing code block N of the last
+// response to path, relative to the working directory. The path is the rest
+// of the line, so it may contain spaces. Missing directories are created;
+// replacing an existing file is confirmed first.
+func (a *App) saveCode(args string) {
+	arg, path, _ := strings.Cut(strings.TrimSpace(args), " ")
+	path = strings.TrimSpace(path)
+	if arg == "" || path == "" {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Usage: /savecode <N> <path>",
+		})
+		return
+	}
+	block, n, err := a.codeBlock(arg)
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Save failed: " + err.Error(),
+		})
+		return
+	}
+	fullPath := path
+	if !filepath.IsAbs(fullPath) {
+		fullPath = filepath.Join(a.config.Cwd, fullPath)
+	}
+	info, err := os.Stat(fullPath)
+	switch {
+	case err == nil && info.IsDir():
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Save failed: " + path + " is a directory",
+		})
+		return
+	case err != nil:
+		a.writeCode(path, fullPath, block, n, "Saved")
+		return
+	}
+
+	old, _ := os.ReadFile(fullPath)
+	a.confirmQueue = append(a.confirmQueue, ConfirmDialogOptions{
+		Type:       ConfirmTypeFile,
+		Title:      "Replace file",
+		Message:    fmt.Sprintf("%s exists. Replace it with %s?", path, describeBlock(block, n)),
+		FilePath:   fullPath,
+		OldContent: string(old),
+		NewContent: codeFileContent(block.Code),
+		Buttons:    []string{"Replace", "Cancel"},
+		OnResult: func(choice ConfirmChoice) {
+			if choice != ConfirmChoiceYes {
+				a.chatView.AddMessage(ChatMessage{
+					Type:    MessageTypeSystem,
+					Content: "Not saved: " + path + " was left as it is",
+				})
+				return
+			}
+			a.writeCode(path, fullPath, block, n, "Replaced")
+		},
+	})
+	a.showNextConfirm()
+}
+
+// writeCode writes a code block for /savecode and reports it with verb
+func (a *App) writeCode(path, fullPath string, block fence.Block, n int, verb string) {
+	err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+	if err == nil {
+		err = os.WriteFile(fullPath, []byte(codeFileContent(block.Code)), 0644)
+	}
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Save failed: " + err.Error(),
+		})
+		return
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: fmt.Sprintf("%s %s with %s (%d lines)", verb, path, describeBlock(block, n), countLines(block.Code)),
+	})
+}
+
+// codeFileContent is a code block as saved to a file, ending in one newline
+func codeFileContent(code string) string {
+	return strings.TrimRight(code, "\n") + "\n"
+}
+
+// handleSelectionKey moves and copies the chat selection, started by v in
+// the chat. y copies the selected lines; Esc or v again cancels.
+func (a *App) handleSelectionKey(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, a.keys.Up):
+		a.chatView.MoveSelection(-1)
+	case key.Matches(msg, a.keys.Down):
+		a.chatView.MoveSelection(1)
+	case key.Matches(msg, a.keys.PageUp):
+		a.chatView.MoveSelection(-a.chatView.viewport.Height / 2)
+	case key.Matches(msg, a.keys.PageDown):
+		a.chatView.MoveSelection(a.chatView.viewport.Height / 2)
+	case key.Matches(msg, a.keys.Home):
+		a.chatView.MoveSelection(-a.chatView.totalLines)
+	case key.Matches(msg, a.keys.End):
+		a.chatView.MoveSelection(a.chatView.totalLines)
+	case key.Matches(msg, a.keys.Copy):
+		text := a.chatView.Selection()
+		a.endSelection()
+		return a.copyText(text, "the selection")
+	case key.Matches(msg, a.keys.Select), key.Matches(msg, a.keys.Cancel):
+		a.endSelection()
+	}
+	return nil
+}
+
+// startSelection starts selecting chat lines to copy
+func (a *App) startSelection() {
+	a.endSearch()
+	a.chatView.StartSelection()
+	if a.chatView.Selecting() {
+		a.statusBar.SetMode("-- select --  ↑/↓:extend y:copy esc:cancel")
+	}
+}
+
+// endSelection removes the chat selection
+func (a *App) endSelection() {
+	a.chatView.EndSelection()
+	a.statusBar.SetMode("")
+}
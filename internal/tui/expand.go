@@ -0,0 +1,110 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/linkalls/gmn/internal/tools"
+)
+
+// =============================================================================
+// Tool Output Expansion
+// =============================================================================
+
+// hasResult reports whether a chat message is a tool result that can be
+// expanded
+func hasResult(m ChatMessage) bool {
+	return m.Result != nil
+}
+
+// expandToolResult shows the full result of tool message i in the preview:
+// file contents with line numbers, diffs as diffs and anything else as
+// pretty-printed JSON
+func (a *App) expandToolResult(i int) {
+	m := a.chatView.messages[i]
+	title := "Tool result"
+	if m.ResultTool != "" {
+		title = m.ResultTool + " result"
+	}
+
+	body := tools.BodyText(m.Result)
+	switch tools.MediaType(m.Result) {
+	case tools.MediaText, tools.MediaMarkdown:
+		path, _ := m.Result["path"].(string)
+		a.filePreview.SetFilePreview(title, path, strings.TrimRight(body, "\n"))
+	case tools.MediaDiff:
+		path, _ := m.Result["path"].(string)
+		a.filePreview.SetUnifiedDiffPreview(title, path, body)
+	default:
+		a.filePreview.SetOutputPreview(title, tools.FormatResult(m.Result))
+	}
+	a.filePreview.viewport.GotoTop()
+	a.filePreview.Show()
+}
+
+// expandCommand handles /expand [last], expanding the newest tool result
+func (a *App) expandCommand(args string) {
+	if args != "" && args != "last" {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Usage: /expand [last]",
+		})
+		return
+	}
+	for i := len(a.chatView.messages) - 1; i >= 0; i-- {
+		if hasResult(a.chatView.messages[i]) {
+			a.expandToolResult(i)
+			return
+		}
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeError,
+		Content: "No tool results to expand",
+	})
+}
+
+// expandVisible expands the lowest tool result in view, or the newest one
+// if none is in view
+func (a *App) expandVisible() {
+	if i := a.chatView.LastVisibleMessage(hasResult); i >= 0 {
+		a.expandToolResult(i)
+		return
+	}
+	a.expandCommand("")
+}
+
+// expandAt expands the tool result shown on row of the chat, reporting
+// whether there was one
+func (a *App) expandAt(row int) bool {
+	i := a.chatView.MessageAt(row)
+	if i < 0 || !hasResult(a.chatView.messages[i]) {
+		return false
+	}
+	a.expandToolResult(i)
+	return true
+}
+
+// handlePreviewKey scrolls the preview; Esc or q closes it
+func (a *App) handlePreviewKey(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, a.keys.Up):
+		a.filePreview.ScrollUp(1)
+	case key.Matches(msg, a.keys.Down):
+		a.filePreview.ScrollDown(1)
+	case key.Matches(msg, a.keys.PageUp):
+		a.filePreview.ScrollUp(a.filePreview.viewport.Height / 2)
+	case key.Matches(msg, a.keys.PageDown):
+		a.filePreview.ScrollDown(a.filePreview.viewport.Height / 2)
+	case key.Matches(msg, a.keys.Home):
+		a.filePreview.viewport.GotoTop()
+	case key.Matches(msg, a.keys.End):
+		a.filePreview.viewport.GotoBottom()
+	case key.Matches(msg, a.keys.Cancel), msg.String() == "q":
+		a.filePreview.Hide()
+	}
+	return nil
+}
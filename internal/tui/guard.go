@@ -0,0 +1,130 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/compact"
+	"github.com/linkalls/gmn/internal/confirmation"
+	"github.com/linkalls/gmn/internal/guard"
+)
+
+// errRequestNotSent is reported when the user cancels a request over the
+// prompt guard limits
+var errRequestNotSent = errors.New("request not sent; use /compact or /rewind to shrink the conversation")
+
+// turnGuard tracks the prompt guard within one turn
+type turnGuard struct {
+	approved atomic.Bool // The user chose to send a request over the limits
+	sent     atomic.Bool // A request of the turn has been sent
+}
+
+// guardCompactMsg carries the history compacted at the prompt guard, after
+// which the request is sent
+type guardCompactMsg struct {
+	history []api.Content
+	result  *compact.Result
+	err     error
+}
+
+// checkPromptGuard asks before sending a request over the prompt guard
+// limits. It returns nil to send the request, or the message that ends the
+// attempt. Compaction is only offered before the first request of a turn.
+// It blocks, so it is only called from commands.
+func (a *App) checkPromptGuard(g *turnGuard, conn Connection, req *api.GenerateRequest) tea.Msg {
+	if g == nil || g.approved.Load() {
+		return nil
+	}
+	b := guard.Estimate(req.Request.Contents, req.Request.SystemInstruction, req.Request.Tools)
+	if !a.config.PromptGuard.Exceeded(req.Model, b) {
+		return nil
+	}
+
+	choice, err := a.confirmLargeRequest(confirmation.LargeRequest{
+		Model:      req.Model,
+		Breakdown:  b,
+		Limits:     a.config.PromptGuard,
+		CanCompact: !g.sent.Load(),
+	})
+	if err != nil {
+		return streamErrorMsg{err: err}
+	}
+	switch choice {
+	case confirmation.LargeCompact:
+		ctx, cancel := context.WithTimeout(a.ctx, a.config.Timeout)
+		defer cancel()
+		compacted, result, err := compact.Compact(ctx, conn.Client, conn.ProjectID, a.config.Compaction, req.Request.Contents)
+		return guardCompactMsg{history: compacted, result: result, err: err}
+	case confirmation.LargeCancel:
+		return streamErrorMsg{err: errRequestNotSent}
+	}
+	g.approved.Store(true)
+	return nil
+}
+
+// confirmLargeRequest asks what to do with a request over the prompt guard
+// limits in the confirmation dialog of the running TUI. It blocks until the
+// user answers, so it is only called from commands.
+func (a *App) confirmLargeRequest(req confirmation.LargeRequest) (confirmation.LargeChoice, error) {
+	if confirmation.YoloMode {
+		return confirmation.LargeSend, nil
+	}
+	if a.program == nil {
+		return confirmation.LargeCancel, errors.New("the TUI is not running")
+	}
+
+	buttons := []string{"Send anyway", "Cancel"}
+	if req.CanCompact {
+		buttons = append(buttons, "Compact first")
+	}
+	result := make(chan ConfirmChoice, 1)
+	a.send(confirmMsg{
+		Type:    ConfirmTypeTool,
+		Title:   "Large request",
+		Message: confirmation.LargeRequestSummary(req),
+		Buttons: buttons,
+		Result:  result,
+	})
+
+	select {
+	case choice := <-result:
+		switch choice {
+		case ConfirmChoiceYes:
+			return confirmation.LargeSend, nil
+		case ConfirmChoiceAlways:
+			return confirmation.LargeCompact, nil
+		}
+		return confirmation.LargeCancel, nil
+	case <-a.ctx.Done():
+		return confirmation.LargeCancel, a.ctx.Err()
+	}
+}
+
+// handleGuardCompacted applies the compaction chosen at the prompt guard
+// and sends the request
+func (a *App) handleGuardCompacted(msg guardCompactMsg) tea.Cmd {
+	if msg.err != nil {
+		a.loading = false
+		a.spinner.Stop()
+		a.thinking.Stop()
+		a.chatView.SetLoading(false, "")
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Compaction failed: " + msg.err.Error(),
+		})
+		a.finishTurn()
+		return nil
+	}
+	a.applyCompaction(msg.history, msg.result)
+	if a.guard != nil {
+		// Sent as is even if it is still over the limits
+		a.guard.approved.Store(true)
+	}
+	return a.startStreamingWithUpdates()
+}
@@ -0,0 +1,224 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/history"
+	"github.com/linkalls/gmn/internal/textwidth"
+)
+
+// historyRows is the number of prompts the history search shows at once
+const historyRows = 10
+
+// =============================================================================
+// History Search Component
+// =============================================================================
+
+// HistorySearchModel searches the prompt history like fzf: the past
+// prompts are filtered as the query is typed, best match first and newer
+// before older among equal matches
+type HistorySearchModel struct {
+	visible  bool
+	query    string
+	prompts  []string // Oldest first
+	matches  []string
+	total    int // Distinct prompts
+	selected int // Index into matches
+	top      int // First match shown
+	width    int
+}
+
+// Open shows the search over prompts, oldest first, filtered by query
+func (h *HistorySearchModel) Open(prompts []string, query string) {
+	h.visible = true
+	h.prompts = prompts
+	h.total = len(history.Filter(prompts, ""))
+	h.SetQuery(query)
+}
+
+// Close hides the search
+func (h *HistorySearchModel) Close() {
+	h.visible = false
+	h.prompts = nil
+	h.matches = nil
+}
+
+// IsVisible returns visibility
+func (h *HistorySearchModel) IsVisible() bool {
+	return h.visible
+}
+
+// SetWidth sets the screen width
+func (h *HistorySearchModel) SetWidth(width int) {
+	h.width = width
+}
+
+// SetQuery filters the prompts by query and selects the best match
+func (h *HistorySearchModel) SetQuery(query string) {
+	h.query = query
+	h.matches = history.Filter(h.prompts, query)
+	h.selected = 0
+	h.top = 0
+}
+
+// Move moves the selection by n entries, scrolling to keep it in view
+func (h *HistorySearchModel) Move(n int) {
+	if len(h.matches) == 0 {
+		return
+	}
+	h.selected = max(0, min(h.selected+n, len(h.matches)-1))
+	if h.selected < h.top {
+		h.top = h.selected
+	}
+	if h.selected >= h.top+historyRows {
+		h.top = h.selected - historyRows + 1
+	}
+}
+
+// HandleKey edits the query and moves the selection. Enter picks the
+// selected prompt and Esc cancels; either closes the search and reports
+// done, with the prompt picked or "" if cancelled.
+func (h *HistorySearchModel) HandleKey(msg tea.KeyMsg) (prompt string, done bool) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		if len(h.matches) > 0 {
+			prompt = h.matches[h.selected]
+		}
+		h.Close()
+		return prompt, true
+	case tea.KeyEsc, tea.KeyCtrlG, tea.KeyCtrlC:
+		h.Close()
+		return "", true
+	case tea.KeyDown, tea.KeyCtrlN, tea.KeyCtrlR:
+		h.Move(1) // Ctrl+R again goes further back, as in a shell
+	case tea.KeyUp, tea.KeyCtrlP, tea.KeyCtrlS:
+		h.Move(-1)
+	case tea.KeyPgDown:
+		h.Move(historyRows)
+	case tea.KeyPgUp:
+		h.Move(-historyRows)
+	case tea.KeyBackspace:
+		if query := []rune(h.query); len(query) > 0 {
+			h.SetQuery(string(query[:len(query)-1]))
+		}
+	case tea.KeyCtrlU:
+		h.SetQuery("")
+	case tea.KeySpace:
+		h.SetQuery(h.query + " ")
+	case tea.KeyRunes:
+		h.SetQuery(h.query + string(msg.Runes))
+	}
+	return "", false
+}
+
+// View renders the search
+func (h *HistorySearchModel) View() string {
+	width := min(72, max(h.width-8, 30))
+	inner := width - 2
+
+	var b strings.Builder
+	b.WriteString(InputPromptStyle.Render("history ❯ ") + h.query + InputCursorStyle.Render("█") + "\n")
+	b.WriteString(DimStyle.Render(strings.Repeat("─", inner)) + "\n")
+
+	if len(h.matches) == 0 {
+		b.WriteString(MutedStyle.Render("No matches") + "\n")
+	}
+	end := min(h.top+historyRows, len(h.matches))
+	for n := h.top; n < end; n++ {
+		line := textwidth.Truncate(strings.Join(strings.Fields(h.matches[n]), " "), inner)
+		if n == h.selected {
+			line = SelectionStyle.Render(textwidth.Pad(line, inner))
+		}
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString("\n" + HelpStyle.Render(fmt.Sprintf("%d of %d  ↑/↓ C-r select  enter accept  esc cancel", len(h.matches), h.total)))
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(AccentColor).
+		Padding(0, 1).
+		Width(width).
+		Render(b.String())
+}
+
+// =============================================================================
+// History Search Actions
+// =============================================================================
+
+// startHistorySearch opens the prompt history search, started with what is
+// typed in the input
+func (a *App) startHistorySearch() {
+	a.endSearch()
+	a.setFocus(FocusInput)
+	query := a.input.Value()
+	if strings.Contains(query, "\n") {
+		query = ""
+	}
+	a.promptSearch.Open(a.input.History(), query)
+}
+
+// handleHistorySearchKey edits the search and puts the prompt picked in
+// the input, to edit or send
+func (a *App) handleHistorySearchKey(msg tea.KeyMsg) tea.Cmd {
+	if prompt, done := a.promptSearch.HandleKey(msg); done && prompt != "" {
+		a.input.SetValue(prompt)
+	}
+	return nil
+}
+
+// =============================================================================
+// Line-Based Chat
+// =============================================================================
+
+// historyPicker runs the history search on its own, below the prompt of
+// the line-based chat
+type historyPicker struct {
+	search HistorySearchModel
+	prompt string
+	done   bool
+}
+
+func (m historyPicker) Init() tea.Cmd {
+	return nil
+}
+
+func (m historyPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.search.SetWidth(msg.Width)
+	case tea.KeyMsg:
+		if prompt, done := m.search.HandleKey(msg); done {
+			m.prompt, m.done = prompt, true
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m historyPicker) View() string {
+	if m.done {
+		return ""
+	}
+	return glyphs.Text(m.search.View())
+}
+
+// PickPrompt lets the user search prompts, oldest first, starting with
+// query, for the line-based chat. It returns the prompt picked, or "" if
+// the search was cancelled.
+func PickPrompt(prompts []string, query string) (string, error) {
+	m := historyPicker{}
+	m.search.Open(prompts, query)
+	final, err := tea.NewProgram(m).Run()
+	if err != nil {
+		return "", err
+	}
+	return final.(historyPicker).prompt, nil
+}
@@ -15,11 +15,27 @@ type KeyMap struct {
 	Home     key.Binding
 	End      key.Binding
 
+	// Search
+	Search        key.Binding
+	NextMatch     key.Binding
+	PrevMatch     key.Binding
+	HistorySearch key.Binding
+
+	// Clipboard
+	CopyResponse key.Binding
+	CopyCode     key.Binding
+	Select       key.Binding
+	Copy         key.Binding
+
+	// Tool output
+	Expand key.Binding
+
 	// Actions
-	Submit key.Binding
-	Cancel key.Binding
-	Help   key.Binding
-	Quit   key.Binding
+	Submit  key.Binding
+	Cancel  key.Binding
+	Help    key.Binding
+	Palette key.Binding
+	Quit    key.Binding
 
 	// Panels
 	FocusChat     key.Binding
@@ -28,6 +44,15 @@ type KeyMap struct {
 	ToggleSidebar key.Binding
 	ToggleContext key.Binding
 	TogglePreview key.Binding
+	TakeOver      key.Binding
+	ToggleMouse   key.Binding
+
+	// Layout
+	SidebarWider    key.Binding
+	SidebarNarrower key.Binding
+	ContextWider    key.Binding
+	ContextNarrower key.Binding
+	Zen             key.Binding
 
 	// Commands
 	NewSession  key.Binding
@@ -72,6 +97,48 @@ func DefaultKeyMap() KeyMap {
 			key.WithHelp("end/G", "go to bottom"),
 		),
 
+		// Search
+		Search: key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", "search chat"),
+		),
+		NextMatch: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "next match"),
+		),
+		PrevMatch: key.NewBinding(
+			key.WithKeys("N"),
+			key.WithHelp("N", "previous match"),
+		),
+		HistorySearch: key.NewBinding(
+			key.WithKeys("ctrl+r"),
+			key.WithHelp("C-r", "search prompt history"),
+		),
+
+		// Clipboard
+		CopyResponse: key.NewBinding(
+			key.WithKeys("ctrl+y"),
+			key.WithHelp("C-y", "copy last response"),
+		),
+		CopyCode: key.NewBinding(
+			key.WithKeys("alt+y"),
+			key.WithHelp("M-y", "copy last code block"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", "select lines"),
+		),
+		Copy: key.NewBinding(
+			key.WithKeys("y"),
+			key.WithHelp("y", "copy selection"),
+		),
+
+		// Tool output
+		Expand: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "expand tool output"),
+		),
+
 		// Actions
 		Submit: key.NewBinding(
 			key.WithKeys("enter"),
@@ -85,6 +152,10 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("?", "f1"),
 			key.WithHelp("?/F1", "toggle help"),
 		),
+		Palette: key.NewBinding(
+			key.WithKeys("ctrl+k"),
+			key.WithHelp("C-k", "command palette"),
+		),
 		Quit: key.NewBinding(
 			key.WithKeys("ctrl+c", "ctrl+q"),
 			key.WithHelp("C-c/C-q", "quit"),
@@ -115,6 +186,37 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("ctrl+p"),
 			key.WithHelp("C-p", "toggle preview"),
 		),
+		TakeOver: key.NewBinding(
+			key.WithKeys("ctrl+g"),
+			key.WithHelp("C-g", "take over terminal command"),
+		),
+		ToggleMouse: key.NewBinding(
+			// Few terminals send Ctrl+Shift+M apart from Enter, so Alt+Shift+M too
+			key.WithKeys("ctrl+shift+m", "alt+M"),
+			key.WithHelp("M-S-m", "toggle mouse capture"),
+		),
+
+		// Layout
+		SidebarWider: key.NewBinding(
+			key.WithKeys("alt+right"),
+			key.WithHelp("M-→", "widen sidebar"),
+		),
+		SidebarNarrower: key.NewBinding(
+			key.WithKeys("alt+left"),
+			key.WithHelp("M-←", "narrow sidebar"),
+		),
+		ContextWider: key.NewBinding(
+			key.WithKeys("alt+shift+left"),
+			key.WithHelp("M-S-←", "widen context panel"),
+		),
+		ContextNarrower: key.NewBinding(
+			key.WithKeys("alt+shift+right"),
+			key.WithHelp("M-S-→", "narrow context panel"),
+		),
+		Zen: key.NewBinding(
+			key.WithKeys("alt+z"),
+			key.WithHelp("M-z", "zen mode"),
+		),
 
 		// Commands
 		NewSession: key.NewBinding(
@@ -134,12 +236,13 @@ func DefaultKeyMap() KeyMap {
 			key.WithHelp("C-l", "clear chat"),
 		),
 		SwitchModel: key.NewBinding(
-			key.WithKeys("ctrl+m"),
-			key.WithHelp("C-m", "switch model"),
+			// Most terminals send Ctrl+M as Enter, so Alt+M too
+			key.WithKeys("ctrl+m", "alt+m"),
+			key.WithHelp("M-m", "switch model"),
 		),
 		ShowStats: key.NewBinding(
 			key.WithKeys("ctrl+t"),
-			key.WithHelp("C-t", "show stats"),
+			key.WithHelp("C-t", "stats dashboard"),
 		),
 
 		// Editor
@@ -167,8 +270,11 @@ func (k KeyMap) ShortHelp() []key.Binding {
 func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Up, k.Down, k.PageUp, k.PageDown, k.Home, k.End},
-		{k.Submit, k.Cancel, k.Help, k.Quit},
-		{k.FocusChat, k.FocusSidebar, k.FocusInput, k.ToggleSidebar, k.ToggleContext, k.TogglePreview},
-		{k.NewSession, k.SaveSession, k.LoadSession, k.ClearChat},
+		{k.Search, k.NextMatch, k.PrevMatch, k.HistorySearch},
+		{k.CopyResponse, k.CopyCode, k.Select, k.Copy, k.Expand},
+		{k.Submit, k.Cancel, k.Help, k.Palette, k.Quit},
+		{k.FocusChat, k.FocusSidebar, k.FocusInput, k.ToggleSidebar, k.ToggleContext, k.TogglePreview, k.ToggleMouse},
+		{k.SidebarWider, k.SidebarNarrower, k.ContextWider, k.ContextNarrower, k.Zen},
+		{k.NewSession, k.SaveSession, k.LoadSession, k.ClearChat, k.SwitchModel},
 	}
 }
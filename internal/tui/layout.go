@@ -0,0 +1,135 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	settings "github.com/linkalls/gmn/internal/config"
+)
+
+// Panel widths in columns
+const (
+	defaultSidebarWidth = 28
+	defaultContextWidth = 30
+	minPanelWidth       = 16
+	panelStep           = 2 // Columns a resize key moves a panel's edge
+)
+
+// layoutSaveDelay is how long the layout has to stay unchanged before it
+// is saved, so that holding a resize key writes the settings once
+const layoutSaveDelay = time.Second
+
+// layoutSaveMsg saves the layout if it has not changed since change n
+type layoutSaveMsg int
+
+// =============================================================================
+// Panel Layout
+// =============================================================================
+
+// applyLayout sets the panel layout from the settings
+func (a *App) applyLayout(l settings.LayoutConfig) {
+	a.showSidebar = !l.HideSidebar
+	a.showContext = !l.HideContext
+	a.zen = l.Zen
+	a.sidebarWidth = defaultSidebarWidth
+	if l.SidebarWidth > 0 {
+		a.sidebarWidth = max(l.SidebarWidth, minPanelWidth)
+	}
+	a.contextWidth = defaultContextWidth
+	if l.ContextWidth > 0 {
+		a.contextWidth = max(l.ContextWidth, minPanelWidth)
+	}
+}
+
+// sidebarCols returns the width the sidebar takes, 0 when hidden. Panels
+// are kept to a third of the screen each so the chat stays usable.
+func (a *App) sidebarCols() int {
+	if !a.showSidebar || a.zen {
+		return 0
+	}
+	return min(a.sidebarWidth, max(a.width/3, minPanelWidth))
+}
+
+// contextCols returns the width the context panel takes, 0 when hidden
+func (a *App) contextCols() int {
+	if !a.showContext || a.zen {
+		return 0
+	}
+	return min(a.contextWidth, max(a.width/3, minPanelWidth))
+}
+
+// headerRows returns the height of the header, hidden in zen mode
+func (a *App) headerRows() int {
+	if a.zen {
+		return 0
+	}
+	return 3
+}
+
+// statusRows returns the height of the status bar, hidden in zen mode
+func (a *App) statusRows() int {
+	if a.zen {
+		return 0
+	}
+	return 1
+}
+
+// resizeSidebar moves the sidebar's edge by delta columns, showing it if
+// it was hidden
+func (a *App) resizeSidebar(delta int) tea.Cmd {
+	if !a.showSidebar || a.zen {
+		a.showSidebar, a.zen = true, false
+	} else {
+		a.sidebarWidth = max(minPanelWidth, min(a.sidebarCols()+delta, a.width/3))
+	}
+	return a.layoutChanged()
+}
+
+// resizeContext moves the context panel's edge by delta columns (negative
+// widens it, as the panel is on the right), showing it if it was hidden
+func (a *App) resizeContext(delta int) tea.Cmd {
+	if !a.showContext || a.zen {
+		a.showContext, a.zen = true, false
+	} else {
+		a.contextWidth = max(minPanelWidth, min(a.contextCols()-delta, a.width/3))
+	}
+	return a.layoutChanged()
+}
+
+// toggleZen hides or brings back everything but the chat and the input
+func (a *App) toggleZen() tea.Cmd {
+	a.zen = !a.zen
+	if a.zen && a.focus == FocusSidebar {
+		a.setFocus(FocusInput)
+	}
+	return a.layoutChanged()
+}
+
+// layoutChanged lays the screen out again and saves the layout once it
+// has stayed unchanged for layoutSaveDelay
+func (a *App) layoutChanged() tea.Cmd {
+	a.handleWindowSize(a.width, a.height)
+	a.layoutChanges++
+	n := a.layoutChanges
+	return tea.Tick(layoutSaveDelay, func(time.Time) tea.Msg {
+		return layoutSaveMsg(n)
+	})
+}
+
+// handleLayoutSave saves the layout in the user settings unless it changed
+// again since msg was scheduled
+func (a *App) handleLayoutSave(msg layoutSaveMsg) tea.Cmd {
+	if int(msg) != a.layoutChanges {
+		return nil
+	}
+	return saveSettings(map[string]interface{}{
+		"ui.layout.hideSidebar":  !a.showSidebar,
+		"ui.layout.hideContext":  !a.showContext,
+		"ui.layout.sidebarWidth": a.sidebarWidth,
+		"ui.layout.contextWidth": a.contextWidth,
+		"ui.layout.zen":          a.zen,
+	})
+}
@@ -4,15 +4,30 @@
 package tui
 
 import (
-	"regexp"
+	"fmt"
 	"strings"
-
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/quick"
+	chromastyles "github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
 	"github.com/charmbracelet/lipgloss"
+	xansi "github.com/charmbracelet/x/ansi"
+	"github.com/linkalls/gmn/internal/fence"
+	"github.com/muesli/termenv"
 )
 
-// MarkdownRenderer renders markdown content with syntax highlighting
+// minMarkdownWidth is the width below which markdown is shown as written
+const minMarkdownWidth = 30
+
+// MarkdownRenderer renders markdown content with glamour, highlighting
+// code blocks with chroma in the theme's colors
 type MarkdownRenderer struct {
 	width int
+	term  *glamour.TermRenderer // Built for width on first use
 }
 
 // NewMarkdownRenderer creates a new markdown renderer
@@ -24,267 +39,273 @@ func NewMarkdownRenderer(width int) *MarkdownRenderer {
 
 // SetWidth sets the render width
 func (r *MarkdownRenderer) SetWidth(width int) {
-	r.width = width
+	if width != r.width {
+		r.width = width
+		r.term = nil
+	}
 }
 
-// Render renders markdown content
+// Render renders markdown content. Code blocks are numbered from 1 in
+// their headers, as /copy and /savecode count them. Without colors
+// (NO_COLOR or ui.colorMode "none") or on narrow screens the text is kept
+// as written, with just the code block headers added.
 func (r *MarkdownRenderer) Render(content string) string {
+	return r.RenderFrom(content, 1)
+}
+
+// RenderFrom renders markdown content like Render, numbering its code
+// blocks from first, for a response rendered a piece at a time
+func (r *MarkdownRenderer) RenderFrom(content string, first int) string {
 	lines := strings.Split(content, "\n")
-	var result []string
-	var inCodeBlock bool
-	var codeBlockLang string
-	var codeBlockContent []string
+	blocks := fence.Blocks(content)
+	if r.width < minMarkdownWidth || lipgloss.ColorProfile() == termenv.Ascii {
+		return r.renderPlain(lines, blocks, first)
+	}
 
-	for _, line := range lines {
-		// Check for code block start/end
-		if strings.HasPrefix(line, "```") {
-			if inCodeBlock {
-				// End of code block
-				codeContent := strings.Join(codeBlockContent, "\n")
-				rendered := r.renderCodeBlock(codeContent, codeBlockLang)
-				result = append(result, rendered)
-				codeBlockContent = nil
-				inCodeBlock = false
-			} else {
-				// Start of code block
-				codeBlockLang = strings.TrimPrefix(line, "```")
-				codeBlockLang = strings.TrimSpace(codeBlockLang)
-				inCodeBlock = true
+	// The prose is rendered as one document, so a paragraph or list that
+	// goes on after a code block stays together. Each code block stands in
+	// as a placeholder line, replaced with its rendering afterwards.
+	var doc []string
+	next := 0
+	for i, block := range blocks {
+		doc = append(doc, lines[next:block.Start]...)
+		doc = append(doc, "", block.Indent+codePlaceholder(first+i), "")
+		next = min(block.End+1, len(lines))
+	}
+	doc = append(doc, lines[next:]...)
+
+	out := strings.Split(r.renderGlamour(strings.Join(doc, "\n")), "\n")
+	for j, line := range out {
+		text := xansi.Strip(line)
+		for i, block := range blocks {
+			col := strings.Index(text, codePlaceholder(first+i))
+			if col < 0 {
+				continue
 			}
-			continue
+			code := r.renderCodeBlock(block.Code, block.Lang, first+i, r.width-col, false)
+			indent := strings.Repeat(" ", col)
+			out[j] = indent + strings.ReplaceAll(code, "\n", "\n"+indent)
+			break
 		}
-
-		if inCodeBlock {
-			codeBlockContent = append(codeBlockContent, line)
-			continue
-		}
-
-		// Process markdown elements
-		result = append(result, r.renderLine(line))
-	}
-
-	// Handle unclosed code block
-	if inCodeBlock && len(codeBlockContent) > 0 {
-		codeContent := strings.Join(codeBlockContent, "\n")
-		rendered := r.renderCodeBlock(codeContent, codeBlockLang)
-		result = append(result, rendered)
 	}
+	return strings.Join(out, "\n")
+}
 
-	return strings.Join(result, "\n")
+// codePlaceholder is the line that stands in for the nth code block while
+// the prose around it is rendered
+func codePlaceholder(n int) string {
+	return fmt.Sprintf("GMNCODEBLOCK%dGMN", n)
 }
 
-// renderLine renders a single markdown line
-func (r *MarkdownRenderer) renderLine(line string) string {
-	// Headers
-	if strings.HasPrefix(line, "### ") {
-		content := strings.TrimPrefix(line, "### ")
-		return lipgloss.NewStyle().Bold(true).Foreground(InfoColor).Render("### " + content)
-	}
-	if strings.HasPrefix(line, "## ") {
-		content := strings.TrimPrefix(line, "## ")
-		return lipgloss.NewStyle().Bold(true).Foreground(AccentColor).Render("## " + content)
+// renderPlain shows markdown as written, with the code block headers added
+func (r *MarkdownRenderer) renderPlain(lines []string, blocks []fence.Block, first int) string {
+	var parts []string
+	addProse := func(from, to int) {
+		text := strings.Join(lines[from:to], "\n")
+		if strings.TrimSpace(text) != "" {
+			parts = append(parts, strings.Trim(text, "\n"))
+		}
 	}
-	if strings.HasPrefix(line, "# ") {
-		content := strings.TrimPrefix(line, "# ")
-		return lipgloss.NewStyle().Bold(true).Foreground(AccentColor).Underline(true).Render("# " + content)
+	next := 0
+	for i, block := range blocks {
+		addProse(next, block.Start)
+		parts = append(parts, r.renderCodeBlock(block.Code, block.Lang, first+i, r.width, true))
+		next = min(block.End+1, len(lines))
 	}
+	addProse(next, len(lines))
+	return strings.Join(parts, "\n\n")
+}
 
-	// Bullet points
-	if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") {
-		prefix := line[:2]
-		content := line[2:]
-		return lipgloss.NewStyle().Foreground(WarningColor).Render(prefix) + r.renderInline(content)
+// termRenderer returns the glamour renderer for the current width
+func (r *MarkdownRenderer) termRenderer() (*glamour.TermRenderer, error) {
+	if r.term == nil {
+		term, err := glamour.NewTermRenderer(
+			glamour.WithStyles(markdownStyle()),
+			glamour.WithWordWrap(r.width),
+			glamour.WithColorProfile(lipgloss.ColorProfile()),
+			glamour.WithChromaFormatter(chromaFormatter()),
+		)
+		if err != nil {
+			return nil, err
+		}
+		r.term = term
 	}
+	return r.term, nil
+}
 
-	// Numbered lists
-	if matched, _ := regexp.MatchString(`^\d+\. `, line); matched {
-		idx := strings.Index(line, ". ")
-		num := line[:idx+2]
-		content := line[idx+2:]
-		return lipgloss.NewStyle().Foreground(WarningColor).Render(num) + r.renderInline(content)
+// renderGlamour renders markdown with glamour, falling back to the text as
+// written if glamour fails. The spaces glamour pads lines to the full
+// width with are dropped.
+func (r *MarkdownRenderer) renderGlamour(text string) string {
+	term, err := r.termRenderer()
+	if err != nil {
+		return text
 	}
-
-	// Blockquotes
-	if strings.HasPrefix(line, "> ") {
-		content := strings.TrimPrefix(line, "> ")
-		return lipgloss.NewStyle().Foreground(DimTextColor).Italic(true).Render("│ " + content)
+	out, err := term.Render(text)
+	if err != nil {
+		return text
 	}
-
-	// Horizontal rule
-	if line == "---" || line == "***" || line == "___" {
-		return lipgloss.NewStyle().Foreground(BorderColor).Render(strings.Repeat("─", r.width))
+	lines := strings.Split(out, "\n")
+	for i, line := range lines {
+		lines[i] = trimPadding(line)
 	}
-
-	// Regular line with inline formatting
-	return r.renderInline(line)
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
 }
 
-// renderInline renders inline markdown elements
-func (r *MarkdownRenderer) renderInline(text string) string {
-	// Bold **text** or __text__
-	boldRe := regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
-	text = boldRe.ReplaceAllStringFunc(text, func(match string) string {
-		content := strings.Trim(match, "*_")
-		return lipgloss.NewStyle().Bold(true).Render(content)
-	})
-
-	// Italic *text* or _text_
-	italicRe := regexp.MustCompile(`(?:^|[^*_])\*([^*]+?)\*(?:[^*_]|$)|(?:^|[^*_])_([^_]+?)_(?:[^*_]|$)`)
-	text = italicRe.ReplaceAllStringFunc(text, func(match string) string {
-		// Simple italic detection
-		if strings.Contains(match, "*") || strings.Contains(match, "_") {
-			content := strings.Trim(match, "*_ ")
-			return lipgloss.NewStyle().Italic(true).Render(content)
-		}
-		return match
-	})
-
-	// Inline code `text`
-	codeRe := regexp.MustCompile("`([^`]+)`")
-	text = codeRe.ReplaceAllStringFunc(text, func(match string) string {
-		content := strings.Trim(match, "`")
-		return lipgloss.NewStyle().
-			Background(SurfaceColor).
-			Foreground(WarningColor).
-			Render(content)
-	})
-
-	// Links [text](url)
-	linkRe := regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
-	text = linkRe.ReplaceAllStringFunc(text, func(match string) string {
-		// Extract text and url
-		parts := linkRe.FindStringSubmatch(match)
-		if len(parts) >= 3 {
-			linkText := parts[1]
-			url := parts[2]
-			return lipgloss.NewStyle().Foreground(InfoColor).Underline(true).Render(linkText) +
-				lipgloss.NewStyle().Foreground(DimTextColor).Render(" ("+url+")")
-		}
-		return match
-	})
-
-	return text
+// trimPadding removes trailing spaces from a styled line
+func trimPadding(line string) string {
+	width := xansi.StringWidth(strings.TrimRight(xansi.Strip(line), " "))
+	if width == xansi.StringWidth(line) {
+		return line
+	}
+	if width == 0 {
+		return ""
+	}
+	return xansi.Truncate(line, width, "") + "\x1b[0m"
 }
 
-// renderCodeBlock renders a code block with syntax highlighting
-func (r *MarkdownRenderer) renderCodeBlock(content, lang string) string {
-	// Header with language
-	header := ""
+// renderCodeBlock renders the nth code block width columns wide with
+// syntax highlighting, or as written when plain
+func (r *MarkdownRenderer) renderCodeBlock(content, lang string, n, width int, plain bool) string {
+	// Header with number and language
+	label := fmt.Sprintf("[%d]", n)
 	if lang != "" {
-		header = lipgloss.NewStyle().
-			Foreground(DimTextColor).
-			Background(SurfaceColor).
-			Padding(0, 1).
-			Render("  " + lang)
+		label += " " + lang
 	}
-
-	// Apply basic syntax highlighting based on language
-	highlighted := r.highlightCode(content, lang)
+	if plain {
+		return label + "\n" + content
+	}
+	header := lipgloss.NewStyle().
+		Foreground(DimTextColor).
+		Background(SurfaceColor).
+		Padding(0, 1).
+		Render("  " + label)
 
 	// Box style for code
 	codeStyle := lipgloss.NewStyle().
 		Background(SurfaceColor).
 		Padding(0, 1).
-		Width(r.width - 4)
+		Width(width - 4)
 
-	if header != "" {
-		return header + "\n" + codeStyle.Render(highlighted)
-	}
-	return codeStyle.Render(highlighted)
+	return header + "\n" + codeStyle.Render(r.highlightCode(content, lang))
 }
 
-// highlightCode applies basic syntax highlighting
+// highlightCode highlights code with chroma, guessing the lexer from the
+// code when lang is unknown to chroma
 func (r *MarkdownRenderer) highlightCode(code, lang string) string {
-	lines := strings.Split(code, "\n")
-	var result []string
-
-	for _, line := range lines {
-		highlighted := r.highlightLine(line, lang)
-		result = append(result, highlighted)
+	var b strings.Builder
+	if err := quick.Highlight(&b, code, lang, chromaFormatter(), codeStyle().Name); err != nil {
+		return code
 	}
-
-	return strings.Join(result, "\n")
+	return strings.TrimSuffix(b.String(), "\n")
 }
 
-// highlightLine highlights a single line of code
-func (r *MarkdownRenderer) highlightLine(line, lang string) string {
-	// Define styles for syntax elements
-	keywordStyle := lipgloss.NewStyle().Foreground(AccentColor)
-	stringStyle := lipgloss.NewStyle().Foreground(SuccessColor)
-	commentStyle := lipgloss.NewStyle().Foreground(DimTextColor).Italic(true)
-	numberStyle := lipgloss.NewStyle().Foreground(WarningColor)
-	funcStyle := lipgloss.NewStyle().Foreground(InfoColor)
+// =============================================================================
+// Markdown Style
+// =============================================================================
+
+// markdownStyle returns glamour's dark style in the theme's colors, without
+// the document margins since chat messages have their own
+func markdownStyle() ansi.StyleConfig {
+	s := styles.DarkStyleConfig
+	s.Document.BlockPrefix = ""
+	s.Document.BlockSuffix = ""
+	s.Document.Margin = new(uint)
+	s.Document.Color = paletteColor(TextColor)
+
+	s.Heading.Color = paletteColor(AccentColor)
+	s.H1.Prefix = "# "
+	s.H1.Suffix = ""
+	s.H1.Color = paletteColor(AccentColor)
+	s.H1.BackgroundColor = nil
+	s.H1.Underline = boolPtr(true)
+	s.H3.Color = paletteColor(InfoColor)
+
+	s.BlockQuote.Color = paletteColor(DimTextColor)
+	s.BlockQuote.Italic = boolPtr(true)
+	s.Item.Color = nil
+	s.Enumeration.Color = nil
+	s.HorizontalRule.Color = paletteColor(BorderColor)
+
+	s.Link.Color = paletteColor(DimTextColor)
+	s.LinkText.Color = paletteColor(InfoColor)
+	s.LinkText.Underline = boolPtr(true)
+
+	s.Code.Color = paletteColor(WarningColor)
+	s.Code.BackgroundColor = paletteColor(SurfaceColor)
+
+	s.CodeBlock.Theme = codeStyle().Name
+	s.CodeBlock.Chroma = nil
+	return s
+}
 
-	// Common keywords for various languages
-	keywords := []string{
-		// Go
-		"func", "return", "if", "else", "for", "range", "switch", "case", "default",
-		"package", "import", "var", "const", "type", "struct", "interface", "map",
-		"chan", "go", "defer", "select", "break", "continue", "fallthrough", "goto",
-		"nil", "true", "false", "iota", "append", "make", "new", "len", "cap",
-		// JavaScript/TypeScript
-		"function", "async", "await", "class", "extends", "constructor", "this",
-		"let", "const", "var", "export", "import", "from", "require", "module",
-		"try", "catch", "finally", "throw", "typeof", "instanceof", "in", "of",
-		"null", "undefined", "NaN", "Infinity",
-		// Python
-		"def", "class", "self", "lambda", "with", "as", "yield", "assert",
-		"pass", "raise", "except", "finally", "global", "nonlocal", "del",
-		"and", "or", "not", "is", "in", "None", "True", "False",
-		// Rust
-		"fn", "let", "mut", "pub", "impl", "trait", "struct", "enum",
-		"match", "loop", "while", "mod", "use", "crate", "super", "self",
-		"Self", "move", "ref", "where", "unsafe", "dyn", "Box", "Vec",
-		// Common
-		"string", "int", "bool", "float", "void", "any", "object",
-	}
+var (
+	codeStyleOnce sync.Once
+	codeStyleGmn  *chroma.Style
+)
 
-	result := line
+// codeStyle returns the chroma style for code, in the theme's colors. It
+// is registered with chroma for glamour, which looks styles up by name.
+// Chroma takes hex colors and maps them to the terminal's palette itself.
+func codeStyle() *chroma.Style {
+	codeStyleOnce.Do(func() {
+		hex := func(c lipgloss.CompleteColor) string { return c.TrueColor }
+		codeStyleGmn = chromastyles.Register(chroma.MustNewStyle("gmn", chroma.StyleEntries{
+			chroma.Text:                hex(TextColor),
+			chroma.Error:               hex(DangerColor),
+			chroma.Comment:             "italic " + hex(DimTextColor),
+			chroma.CommentPreproc:      hex(MagentaColor),
+			chroma.Keyword:             hex(AccentColor),
+			chroma.KeywordNamespace:    hex(MagentaColor),
+			chroma.KeywordType:         hex(TealColor),
+			chroma.Operator:            hex(DimTextColor),
+			chroma.Punctuation:         hex(DimTextColor),
+			chroma.NameBuiltin:         hex(TealColor),
+			chroma.NameTag:             hex(AccentColor),
+			chroma.NameAttribute:       hex(InfoColor),
+			chroma.NameClass:           hex(TealColor),
+			chroma.NameDecorator:       hex(MagentaColor),
+			chroma.NameFunction:        hex(InfoColor),
+			chroma.LiteralNumber:       hex(WarningColor),
+			chroma.LiteralString:       hex(SuccessColor),
+			chroma.LiteralStringEscape: hex(WarningColor),
+			chroma.GenericDeleted:      hex(DangerColor),
+			chroma.GenericInserted:     hex(SuccessColor),
+			chroma.GenericSubheading:   hex(InfoColor),
+			chroma.Background:          "bg:" + hex(SurfaceColor),
+		}))
+	})
+	return codeStyleGmn
+}
 
-	// Highlight comments (simple detection)
-	if strings.Contains(line, "//") && !strings.Contains(line, "://") {
-		idx := strings.Index(line, "//")
-		before := line[:idx]
-		comment := line[idx:]
-		result = before + commentStyle.Render(comment)
-		return result
+// paletteColor returns the form of a palette color glamour takes for the
+// terminal's color profile, nil where the palette leaves text uncolored
+func paletteColor(c lipgloss.CompleteColor) *string {
+	var color string
+	switch lipgloss.ColorProfile() {
+	case termenv.TrueColor:
+		color = c.TrueColor
+	case termenv.ANSI256:
+		color = c.ANSI256
+	case termenv.ANSI:
+		color = c.ANSI
 	}
-	if strings.HasPrefix(strings.TrimSpace(line), "#") && lang == "python" {
-		return commentStyle.Render(line)
+	if color == "" {
+		return nil
 	}
+	return &color
+}
 
-	// Highlight strings (simple detection for double quotes)
-	stringRe := regexp.MustCompile(`"[^"]*"`)
-	result = stringRe.ReplaceAllStringFunc(result, func(s string) string {
-		return stringStyle.Render(s)
-	})
-
-	// Highlight single-quoted strings
-	singleStringRe := regexp.MustCompile(`'[^']*'`)
-	result = singleStringRe.ReplaceAllStringFunc(result, func(s string) string {
-		return stringStyle.Render(s)
-	})
-
-	// Highlight numbers
-	numRe := regexp.MustCompile(`\b\d+(\.\d+)?\b`)
-	result = numRe.ReplaceAllStringFunc(result, func(s string) string {
-		return numberStyle.Render(s)
-	})
-
-	// Highlight keywords
-	for _, kw := range keywords {
-		kwRe := regexp.MustCompile(`\b` + kw + `\b`)
-		result = kwRe.ReplaceAllStringFunc(result, func(s string) string {
-			return keywordStyle.Render(s)
-		})
+// chromaFormatter returns the chroma formatter for the terminal's color
+// profile
+func chromaFormatter() string {
+	switch lipgloss.ColorProfile() {
+	case termenv.TrueColor:
+		return "terminal16m"
+	case termenv.ANSI:
+		return "terminal16"
 	}
-
-	// Highlight function calls (word followed by parenthesis)
-	funcRe := regexp.MustCompile(`\b([a-zA-Z_][a-zA-Z0-9_]*)\(`)
-	result = funcRe.ReplaceAllStringFunc(result, func(s string) string {
-		name := strings.TrimSuffix(s, "(")
-		return funcStyle.Render(name) + "("
-	})
-
-	return result
+	return "terminal256"
 }
+
+func boolPtr(b bool) *bool { return &b }
@@ -0,0 +1,147 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/guard"
+	"github.com/linkalls/gmn/internal/textwidth"
+)
+
+// =============================================================================
+// Model Picker Component
+// =============================================================================
+
+// ModelPickerModel is the overlay for choosing a model, showing each
+// model's context size and price and marking the tier's default
+type ModelPickerModel struct {
+	visible   bool
+	models    []string
+	current   string
+	tierModel string
+	selected  int
+}
+
+// Open shows the picker with the current model selected
+func (m *ModelPickerModel) Open(models []string, current, tierModel string) {
+	m.visible = true
+	m.models = models
+	m.current = current
+	m.tierModel = tierModel
+	m.selected = max(slices.Index(models, current), 0)
+}
+
+// Close hides the picker
+func (m *ModelPickerModel) Close() {
+	m.visible = false
+}
+
+// IsVisible returns visibility
+func (m *ModelPickerModel) IsVisible() bool {
+	return m.visible
+}
+
+// Move moves the selection by n models
+func (m *ModelPickerModel) Move(n int) {
+	m.selected = max(0, min(m.selected+n, len(m.models)-1))
+}
+
+// Selected returns the selected model, or "" if there are none
+func (m *ModelPickerModel) Selected() string {
+	if len(m.models) == 0 {
+		return ""
+	}
+	return m.models[m.selected]
+}
+
+// View renders the picker
+func (m *ModelPickerModel) View() string {
+	nameWidth := 0
+	for _, model := range m.models {
+		nameWidth = max(nameWidth, textwidth.Width(model))
+	}
+
+	var b strings.Builder
+	b.WriteString(lipgloss.NewStyle().Bold(true).Foreground(AccentColor).Render("Switch model") + "\n\n")
+	b.WriteString(DimStyle.Render(fmt.Sprintf("  %s  %7s  %s", textwidth.Pad("Model", nameWidth), "Context", "Price in/out per 1M")) + "\n")
+	for i, model := range m.models {
+		marker := "  "
+		if model == m.current {
+			marker = "● "
+		}
+		context, price := "?", "?"
+		if info, ok := guard.Info(model); ok {
+			context = guard.FormatTokens(info.Window)
+			price = fmt.Sprintf("$%.2f / $%.2f", info.InputPrice, info.OutputPrice)
+		}
+		line := fmt.Sprintf("%s%s  %7s  %-15s", marker, textwidth.Pad(model, nameWidth), context, price)
+		tag := ""
+		if model == m.tierModel {
+			tag = "  tier default"
+		}
+		if i == m.selected {
+			b.WriteString(SelectionStyle.Render(line+tag) + "\n")
+		} else {
+			b.WriteString(line + SuccessStyle.Render(tag) + "\n")
+		}
+	}
+	b.WriteString("\n" + HelpStyle.Render("↑/↓ select  enter switch  esc cancel"))
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(AccentColor).
+		Padding(1, 2).
+		Render(b.String())
+}
+
+// =============================================================================
+// Model Picker Actions
+// =============================================================================
+
+// openModelPicker opens the model picker
+func (a *App) openModelPicker() {
+	a.modelPicker.Open(a.config.AvailableModels, a.config.Model, a.tierModel)
+}
+
+// handleModelPickerKey moves through the models and switches to the
+// selected one on Enter, remembering it in the settings
+func (a *App) handleModelPickerKey(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, a.keys.Up):
+		a.modelPicker.Move(-1)
+	case key.Matches(msg, a.keys.Down):
+		a.modelPicker.Move(1)
+	case key.Matches(msg, a.keys.Cancel):
+		a.modelPicker.Close()
+	case key.Matches(msg, a.keys.Submit):
+		model := a.modelPicker.Selected()
+		a.modelPicker.Close()
+		if model == "" || model == a.config.Model {
+			return nil
+		}
+		a.switchModel(model)
+		return saveSettings(map[string]interface{}{"model.name": model})
+	}
+	return nil
+}
+
+// switchModel makes model the model of the following requests
+func (a *App) switchModel(model string) {
+	a.config.Model = model
+	a.header.SetModel(model)
+	a.statusBar.SetModel(model)
+	if a.session != nil {
+		a.session.Model = model
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: "Model switched to " + model,
+	})
+}
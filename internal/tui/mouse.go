@@ -0,0 +1,52 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// =============================================================================
+// Mouse Capture
+// =============================================================================
+
+// setMouseCapture turns mouse capture on or off. With it off the terminal
+// handles the mouse itself, so text can be selected and copied with it,
+// but clicks and the wheel no longer reach the TUI.
+func (a *App) setMouseCapture(on bool) tea.Cmd {
+	if on != a.mouseOff {
+		return nil
+	}
+	a.mouseOff = !on
+	a.statusBar.SetMouseOff(a.mouseOff)
+	if on {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeSystem,
+			Content: "Mouse capture on",
+		})
+		return tea.EnableMouseCellMotion
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: "Mouse capture off: select text with the terminal; /mouse or " + a.keys.ToggleMouse.Help().Key + " turns it back on",
+	})
+	return tea.DisableMouse
+}
+
+// mouseCommand handles /mouse [on|off], toggling capture without argument
+func (a *App) mouseCommand(args string) tea.Cmd {
+	switch args {
+	case "":
+		return a.setMouseCapture(a.mouseOff)
+	case "on":
+		return a.setMouseCapture(true)
+	case "off":
+		return a.setMouseCapture(false)
+	}
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeError,
+		Content: "Usage: /mouse [on|off]",
+	})
+	return nil
+}
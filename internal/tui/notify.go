@@ -0,0 +1,31 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/linkalls/gmn/internal/notify"
+)
+
+// =============================================================================
+// Turn Notifications
+// =============================================================================
+
+// notifyTurn returns a command notifying the user that a turn that took
+// long has finished, if notifications are enabled and the terminal is
+// in the background. Terminals that do not report focus are assumed to be
+// in the background.
+func (a *App) notifyTurn(took time.Duration, body string) tea.Cmd {
+	n := a.config.Notifications
+	if !n.Enabled || took < n.After() || (a.focusReported && !a.blurred) {
+		return nil
+	}
+	return func() tea.Msg {
+		// A notification that cannot be shown is not worth interrupting for
+		_ = notify.Send(n.Method, "gmn", body)
+		return nil
+	}
+}
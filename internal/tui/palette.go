@@ -0,0 +1,347 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/commands"
+	"github.com/linkalls/gmn/internal/fuzzy"
+	"github.com/linkalls/gmn/internal/textwidth"
+)
+
+// paletteRows is the number of entries the palette shows at once
+const paletteRows = 12
+
+// paletteItem is an entry of the command palette
+type paletteItem struct {
+	kind   string // "command", "session", "model" or "key"
+	title  string
+	detail string
+	run    func() tea.Cmd
+}
+
+// paletteCommand is a built-in slash command listed in the palette
+type paletteCommand struct {
+	name string
+	desc string
+	args bool // Needs arguments, so is put in the input instead of run
+}
+
+// paletteCommands are the built-in slash commands, as in the help overlay
+var paletteCommands = []paletteCommand{
+	{"/help", "Show help", false},
+	{"/clear", "Clear conversation", false},
+	{"/stats", "Stats dashboard", false},
+	{"/model", "Pick a model", false},
+	{"/compact", "Summarize older turns", false},
+	{"/summary", "Recap the session", false},
+	{"/reload-config", "Re-read settings", false},
+	{"/sessions", "List sessions", false},
+	{"/save", "Save session", false},
+	{"/load", "Load session", true},
+	{"/new", "New session", false},
+	{"/branch", "Fork into a new session", false},
+	{"/rewind", "Drop the last turn", false},
+	{"/search", "Search all sessions", true},
+	{"/run", "Run a tool directly", true},
+	{"/last-cmd", "Attach last shell command", false},
+	{"/paste", "Attach clipboard contents", false},
+	{"/copy", "Copy the last response", false},
+	{"/savecode", "Save a code block to a file", true},
+	{"/expand", "Expand the last tool result", false},
+	{"/mouse", "Toggle mouse capture", false},
+	{"/context", "List context files", false},
+	{"/memory", "Show memory", false},
+	{"/export", "Save the activity log", true},
+	{"/jobs", "List background jobs", false},
+	{"/exit", "Exit", false},
+}
+
+// =============================================================================
+// Palette Component
+// =============================================================================
+
+// PaletteModel is the command palette overlay, a fuzzy-searchable list of
+// commands, sessions, models and key bindings
+type PaletteModel struct {
+	visible  bool
+	query    string
+	items    []paletteItem
+	matches  []int // Indexes into items, best match first
+	selected int   // Index into matches
+	top      int   // First match shown
+	width    int
+	height   int
+}
+
+// Open shows the palette with items and an empty query
+func (p *PaletteModel) Open(items []paletteItem) {
+	p.visible = true
+	p.items = items
+	p.SetQuery("")
+}
+
+// Close hides the palette
+func (p *PaletteModel) Close() {
+	p.visible = false
+	p.items = nil
+	p.matches = nil
+}
+
+// IsVisible returns visibility
+func (p *PaletteModel) IsVisible() bool {
+	return p.visible
+}
+
+// SetSize sets the screen dimensions
+func (p *PaletteModel) SetSize(width, height int) {
+	p.width = width
+	p.height = height
+}
+
+// Query returns the search text
+func (p *PaletteModel) Query() string {
+	return p.query
+}
+
+// SetQuery filters the items by query and selects the best match
+func (p *PaletteModel) SetQuery(query string) {
+	p.query = query
+	p.selected = 0
+	p.top = 0
+
+	type scored struct{ item, score int }
+	var found []scored
+	for i, item := range p.items {
+		text := item.title + " " + item.detail + " " + item.kind
+		if score, ok := fuzzy.Score(query, text); ok {
+			found = append(found, scored{i, score})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score > found[j].score })
+	p.matches = p.matches[:0]
+	for _, f := range found {
+		p.matches = append(p.matches, f.item)
+	}
+}
+
+// Move moves the selection by n entries, scrolling to keep it in view
+func (p *PaletteModel) Move(n int) {
+	if len(p.matches) == 0 {
+		return
+	}
+	p.selected = max(0, min(p.selected+n, len(p.matches)-1))
+	if p.selected < p.top {
+		p.top = p.selected
+	}
+	if p.selected >= p.top+paletteRows {
+		p.top = p.selected - paletteRows + 1
+	}
+}
+
+// Selected returns the selected entry, or nil if nothing matches
+func (p *PaletteModel) Selected() *paletteItem {
+	if len(p.matches) == 0 {
+		return nil
+	}
+	return &p.items[p.matches[p.selected]]
+}
+
+// View renders the palette
+func (p *PaletteModel) View() string {
+	width := min(72, max(p.width-8, 30))
+	inner := width - 2
+
+	var b strings.Builder
+	b.WriteString(InputPromptStyle.Render("❯ ") + p.query + InputCursorStyle.Render("█") + "\n")
+	b.WriteString(DimStyle.Render(strings.Repeat("─", inner)) + "\n")
+
+	if len(p.matches) == 0 {
+		b.WriteString(MutedStyle.Render("No matches") + "\n")
+	}
+	end := min(p.top+paletteRows, len(p.matches))
+	for n := p.top; n < end; n++ {
+		b.WriteString(p.renderItem(p.items[p.matches[n]], n == p.selected, inner) + "\n")
+	}
+
+	b.WriteString("\n" + HelpStyle.Render(fmt.Sprintf("%d of %d  ↑/↓ select  enter run  esc close", len(p.matches), len(p.items))))
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(AccentColor).
+		Padding(0, 1).
+		Width(width).
+		Render(b.String())
+}
+
+// renderItem renders an entry on one line of width columns, the title
+// after its kind and the detail on the right
+func (p *PaletteModel) renderItem(item paletteItem, selected bool, width int) string {
+	kind := textwidth.Pad(item.kind, 8)
+	title := textwidth.Truncate(item.title, width-textwidth.Width(kind)-2)
+	space := width - textwidth.Width(kind) - textwidth.Width(title)
+	detail := ""
+	if space > 4 && item.detail != "" {
+		detail = textwidth.Truncate(item.detail, space-2)
+	}
+	gap := strings.Repeat(" ", max(space-textwidth.Width(detail), 0))
+
+	if selected {
+		return SelectionStyle.Render(kind + title + gap + detail)
+	}
+	return DimStyle.Render(kind) + title + gap + HelpDescStyle.Render(detail)
+}
+
+// =============================================================================
+// Palette Actions
+// =============================================================================
+
+// openPalette opens the command palette
+func (a *App) openPalette() {
+	a.endSearch()
+	a.palette.Open(a.paletteItems())
+}
+
+// paletteItems lists the commands, sessions, models and key bindings the
+// palette offers
+func (a *App) paletteItems() []paletteItem {
+	var items []paletteItem
+
+	for _, c := range paletteCommands {
+		items = append(items, paletteItem{
+			kind:   "command",
+			title:  c.name,
+			detail: c.desc,
+			run:    a.paletteCommandRunner(c.name, c.args),
+		})
+	}
+	for _, c := range a.commands.Sorted() {
+		name := "/" + c.Name
+		items = append(items, paletteItem{
+			kind:   "command",
+			title:  name,
+			detail: c.Description,
+			run:    a.paletteCommandRunner(name, strings.Contains(c.Prompt, commands.ArgsPlaceholder)),
+		})
+	}
+
+	for _, s := range a.sidebar.sessions {
+		name := s.Name
+		if name == "" {
+			name = s.ID
+		}
+		detail := fmt.Sprintf("%d msgs  %s", s.Messages, s.UpdatedAt)
+		if s.IsCurrent {
+			detail = "current  " + detail
+		}
+		id := s.ID
+		items = append(items, paletteItem{
+			kind:   "session",
+			title:  name,
+			detail: detail,
+			run:    func() tea.Cmd { return a.loadSession(id) },
+		})
+	}
+
+	for _, m := range a.config.AvailableModels {
+		detail := ""
+		if m == a.config.Model {
+			detail = "current"
+		}
+		model := m
+		items = append(items, paletteItem{
+			kind:   "model",
+			title:  model,
+			detail: detail,
+			run:    func() tea.Cmd { return a.handleCommand("/model " + model) },
+		})
+	}
+
+	keyActions := []struct {
+		binding key.Binding
+		run     func() tea.Cmd
+	}{
+		{a.keys.Help, func() tea.Cmd { a.showHelp = true; return nil }},
+		{a.keys.ToggleSidebar, a.toggleSidebar},
+		{a.keys.ToggleContext, a.toggleContext},
+		{a.keys.Zen, a.toggleZen},
+		{a.keys.TogglePreview, func() tea.Cmd { a.filePreview.Toggle(); return nil }},
+		{a.keys.SwitchModel, func() tea.Cmd { a.openModelPicker(); return nil }},
+		{a.keys.HistorySearch, func() tea.Cmd { a.startHistorySearch(); return nil }},
+		{a.keys.ShowStats, func() tea.Cmd { a.stats.Open(); return nil }},
+		{a.keys.TakeOver, a.takeOverTerminal},
+		{a.keys.ToggleMouse, func() tea.Cmd { return a.setMouseCapture(a.mouseOff) }},
+		{a.keys.CopyResponse, a.copyResponse},
+		{a.keys.CopyCode, func() tea.Cmd { return a.copyCode("code") }},
+		{a.keys.Expand, func() tea.Cmd { a.expandVisible(); return nil }},
+		{a.keys.FocusChat, func() tea.Cmd { a.setFocus(FocusChat); return nil }},
+		{a.keys.FocusInput, func() tea.Cmd { a.setFocus(FocusInput); return nil }},
+		{a.keys.NewSession, a.newSession},
+		{a.keys.SaveSession, func() tea.Cmd { a.saveSession(); return nil }},
+		{a.keys.ClearChat, func() tea.Cmd { a.clearChat(); return nil }},
+		{a.keys.Quit, a.requestQuit},
+	}
+	for _, k := range keyActions {
+		items = append(items, paletteItem{
+			kind:   "key",
+			title:  k.binding.Help().Desc,
+			detail: k.binding.Help().Key,
+			run:    k.run,
+		})
+	}
+	return items
+}
+
+// paletteCommandRunner runs a slash command from the palette. Commands
+// that need arguments are put in the input to be completed instead.
+func (a *App) paletteCommandRunner(name string, args bool) func() tea.Cmd {
+	return func() tea.Cmd {
+		if args {
+			a.input.SetValue(name + " ")
+			a.setFocus(FocusInput)
+			return nil
+		}
+		return a.handleCommand(name)
+	}
+}
+
+// handlePaletteKey edits the palette query and runs the selected entry on
+// Enter. Esc closes the palette.
+func (a *App) handlePaletteKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		a.palette.Close()
+	case tea.KeyEnter:
+		item := a.palette.Selected()
+		a.palette.Close()
+		if item != nil {
+			return item.run()
+		}
+	case tea.KeyUp, tea.KeyCtrlP:
+		a.palette.Move(-1)
+	case tea.KeyDown, tea.KeyCtrlN:
+		a.palette.Move(1)
+	case tea.KeyPgUp:
+		a.palette.Move(-paletteRows)
+	case tea.KeyPgDown:
+		a.palette.Move(paletteRows)
+	case tea.KeyBackspace:
+		if query := []rune(a.palette.Query()); len(query) > 0 {
+			a.palette.SetQuery(string(query[:len(query)-1]))
+		}
+	case tea.KeyCtrlU:
+		a.palette.SetQuery("")
+	case tea.KeySpace:
+		a.palette.SetQuery(a.palette.Query() + " ")
+	case tea.KeyRunes:
+		a.palette.SetQuery(a.palette.Query() + string(msg.Runes))
+	}
+	return nil
+}
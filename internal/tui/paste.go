@@ -0,0 +1,141 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/clipboard"
+	"github.com/linkalls/gmn/internal/input"
+	"github.com/linkalls/gmn/internal/tools"
+)
+
+const (
+	// pasteLines and pasteBytes are the size from which a paste is attached
+	// to the next message instead of inserted into the input line
+	pasteLines = 10
+	pasteBytes = 2000
+)
+
+// pastedItem is pasted text or an image waiting to be sent with the next
+// message
+type pastedItem struct {
+	key   string // Path of its context panel item
+	name  string
+	text  string
+	image *tools.InlineImage
+}
+
+// clipboardMsg carries the clipboard content read for /paste
+type clipboardMsg struct {
+	content *clipboard.Content
+	err     error
+}
+
+// pasteClipboard reads the clipboard for /paste
+func (a *App) pasteClipboard() tea.Cmd {
+	return func() tea.Msg {
+		content, err := clipboard.Read()
+		return clipboardMsg{content: content, err: err}
+	}
+}
+
+// handleClipboard attaches the clipboard content read for /paste
+func (a *App) handleClipboard(msg clipboardMsg) {
+	err := msg.err
+	if err == nil {
+		if msg.content.Image != nil {
+			err = a.attachPastedImage(msg.content.Image)
+		} else {
+			a.attachPastedText(msg.content.Text)
+		}
+	}
+	if err != nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeError,
+			Content: "Paste failed: " + err.Error(),
+		})
+	}
+}
+
+// normalizePaste turns the carriage returns terminals paste line breaks as
+// into newlines
+func normalizePaste(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+}
+
+// isLargePaste reports whether pasted text is attached rather than typed
+func isLargePaste(text string) bool {
+	return countLines(normalizePaste(text)) > pasteLines || len(text) > pasteBytes
+}
+
+// attachPastedText queues pasted text for the next message and lists it in
+// the context panel
+func (a *App) attachPastedText(text string) {
+	text = strings.TrimRight(normalizePaste(text), "\n")
+	a.pasteCount++
+	item := pastedItem{
+		key:  fmt.Sprintf("paste:%d", a.pasteCount),
+		name: fmt.Sprintf("Pasted text #%d", a.pasteCount),
+		text: text,
+	}
+	a.pasted = append(a.pasted, item)
+	a.contextPanel.AddContextItem(ContextItem{
+		Type:      ContextTypeClipboard,
+		Path:      item.key,
+		Name:      item.name,
+		Size:      int64(len(text)),
+		LineCount: countLines(text),
+	})
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: fmt.Sprintf("%s (%d lines) will be attached to your next message", item.name, countLines(text)),
+	})
+}
+
+// attachPastedImage queues a pasted image for the next message and lists
+// it in the context panel
+func (a *App) attachPastedImage(data []byte) error {
+	img, summary, err := tools.NewInlineImage(data, "clipboard")
+	if err != nil {
+		return err
+	}
+	a.pasteCount++
+	item := pastedItem{
+		key:   fmt.Sprintf("paste:%d", a.pasteCount),
+		name:  fmt.Sprintf("Pasted image #%d", a.pasteCount),
+		image: img,
+	}
+	a.pasted = append(a.pasted, item)
+	a.contextPanel.AddContextItem(ContextItem{
+		Type: ContextTypeClipboard,
+		Path: item.key,
+		Name: item.name,
+		Size: int64(len(img.Data)),
+	})
+	a.chatView.AddMessage(ChatMessage{
+		Type:    MessageTypeSystem,
+		Content: fmt.Sprintf("%s (%s) will be attached to your next message", item.name, summary),
+	})
+	return nil
+}
+
+// takePasted returns the queued pastes, text as attachments and images as
+// inline data parts, and clears the queue
+func (a *App) takePasted() ([]input.Attachment, []api.Part) {
+	var attachments []input.Attachment
+	var images []api.Part
+	for _, item := range a.pasted {
+		if item.image != nil {
+			images = append(images, item.image.Part())
+		} else {
+			attachments = append(attachments, input.Attachment{Path: item.name, Content: item.text})
+		}
+	}
+	a.pasted = nil
+	return attachments, images
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/charmbracelet/bubbles/viewport"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/termcolor"
 )
 
 // DiffLine represents a line in the diff
@@ -118,6 +120,17 @@ func (f *FilePreviewModel) SetDiffPreview(title, path, oldContent, newContent st
 	f.updateContent()
 }
 
+// SetUnifiedDiffPreview sets a diff preview from a unified diff
+func (f *FilePreviewModel) SetUnifiedDiffPreview(title, path, unified string) {
+	f.previewType = PreviewTypeDiff
+	f.title = title
+	f.filePath = path
+	f.oldContent = ""
+	f.newContent = ""
+	f.diffLines = parseUnifiedDiff(unified)
+	f.updateContent()
+}
+
 // SetCommandPreview sets a command preview
 func (f *FilePreviewModel) SetCommandPreview(command, explanation string) {
 	f.previewType = PreviewTypeCommand
@@ -131,6 +144,7 @@ func (f *FilePreviewModel) SetCommandPreview(command, explanation string) {
 func (f *FilePreviewModel) SetOutputPreview(title, output string) {
 	f.previewType = PreviewTypeOutput
 	f.title = title
+	f.filePath = ""
 	f.content = output
 	f.updateContent()
 }
@@ -209,11 +223,11 @@ func (f *FilePreviewModel) renderDiffContent() string {
 			lineNumStyle = lipgloss.NewStyle().Foreground(DimTextColor)
 		case DiffLineAdded:
 			prefix = "+ "
-			style = lipgloss.NewStyle().Foreground(SuccessColor).Background(lipgloss.Color("#0d3321"))
+			style = lipgloss.NewStyle().Foreground(SuccessColor).Background(termcolor.Background("#0d3321", ""))
 			lineNumStyle = lipgloss.NewStyle().Foreground(SuccessColor)
 		case DiffLineRemoved:
 			prefix = "- "
-			style = lipgloss.NewStyle().Foreground(DangerColor).Background(lipgloss.Color("#3d1515"))
+			style = lipgloss.NewStyle().Foreground(DangerColor).Background(termcolor.Background("#3d1515", ""))
 			lineNumStyle = lipgloss.NewStyle().Foreground(DangerColor)
 		case DiffLineHeader:
 			prefix = ""
@@ -312,17 +326,18 @@ func (f FilePreviewModel) View() string {
 	var b strings.Builder
 
 	// Title bar
-	titleIcon := "📄"
+	g := glyphs.Current()
+	titleIcon := g.File
 	switch f.previewType {
 	case PreviewTypeDiff:
-		titleIcon = "📝"
+		titleIcon = g.Edit
 	case PreviewTypeCommand:
-		titleIcon = "💻"
+		titleIcon = g.Shell
 	case PreviewTypeOutput:
-		titleIcon = "📤"
+		titleIcon = g.Upload
 	}
 
-	title := fmt.Sprintf("%s %s", titleIcon, f.title)
+	title := glyphs.Label(titleIcon, f.title)
 	if f.filePath != "" {
 		title += " • " + lipgloss.NewStyle().Foreground(DimTextColor).Render(f.filePath)
 	}
@@ -431,6 +446,25 @@ func computeDiff(oldContent, newContent string) []DiffLine {
 	return diff
 }
 
+// parseUnifiedDiff splits a unified diff into diff lines. File and hunk
+// headers become header lines.
+func parseUnifiedDiff(unified string) []DiffLine {
+	var diff []DiffLine
+	for _, line := range strings.Split(strings.TrimRight(unified, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "@@"):
+			diff = append(diff, DiffLine{Type: DiffLineHeader, Content: line})
+		case strings.HasPrefix(line, "+"):
+			diff = append(diff, DiffLine{Type: DiffLineAdded, Content: line[1:]})
+		case strings.HasPrefix(line, "-"):
+			diff = append(diff, DiffLine{Type: DiffLineRemoved, Content: line[1:]})
+		default:
+			diff = append(diff, DiffLine{Type: DiffLineContext, Content: strings.TrimPrefix(line, " ")})
+		}
+	}
+	return diff
+}
+
 // countLines counts lines in content
 func countLines(content string) int {
 	if content == "" {
@@ -443,7 +477,12 @@ func countLines(content string) int {
 // File Preview Style
 // =============================================================================
 
-var FilePreviewStyle = lipgloss.NewStyle().
-	Border(lipgloss.RoundedBorder()).
-	BorderForeground(AccentColor).
-	Padding(0, 1)
+var FilePreviewStyle lipgloss.Style
+
+// setPreviewStyles builds the styles of this file for the current theme
+func setPreviewStyles() {
+	FilePreviewStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(AccentColor).
+		Padding(0, 1)
+}
@@ -0,0 +1,90 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// =============================================================================
+// Exit Confirmation
+// =============================================================================
+
+// busy describes the work quitting would interrupt, or "" if there is none
+func (a *App) busy() string {
+	switch {
+	case a.loading:
+		return "A response is still in progress."
+	}
+	running := 0
+	for _, job := range a.registry.Jobs().List() {
+		if job.Running {
+			running++
+		}
+	}
+	if running > 0 {
+		return fmt.Sprintf("%d background jobs are running.", running)
+	}
+	return ""
+}
+
+// requestQuit quits, asking first while work is in flight. Asked again
+// (Ctrl+C twice), it quits at once.
+func (a *App) requestQuit() tea.Cmd {
+	if a.confirmingQuit || a.busy() == "" {
+		return a.quit()
+	}
+	a.confirmingQuit = true
+	return nil
+}
+
+// handleQuitKey answers the exit confirmation: y or Enter saves and quits,
+// n or Esc keeps working
+func (a *App) handleQuitKey(msg tea.KeyMsg) tea.Cmd {
+	switch strings.ToLower(msg.String()) {
+	case "y", "enter":
+		return a.quit()
+	case "n", "esc":
+		a.confirmingQuit = false
+	}
+	return nil
+}
+
+// renderQuitConfirm renders the exit confirmation
+func (a *App) renderQuitConfirm() string {
+	title := lipgloss.NewStyle().Bold(true).Foreground(WarningColor).Render("Quit gmn?")
+	reason := a.busy()
+	if reason == "" {
+		reason = "The work in progress has finished."
+	}
+	body := title + "\n\n" +
+		lipgloss.NewStyle().Foreground(TextColor).Render(reason) + "\n" +
+		DimStyle.Render("The session is saved before quitting.") + "\n\n" +
+		HelpStyle.Render("y/enter:save and quit  n/esc:keep working  C-c:quit")
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(WarningColor).
+		Padding(1, 2).
+		Render(body)
+}
+
+// =============================================================================
+// Crash Safety
+// =============================================================================
+
+// flushOnPanic saves the session when the goroutine it is deferred in
+// panics, then panics again. Bubbletea recovers panics in Update and in
+// commands, after which Run saves; goroutines the app starts itself need
+// this instead.
+func (a *App) flushOnPanic() {
+	if r := recover(); r != nil {
+		a.autoSave()
+		panic(r)
+	}
+}
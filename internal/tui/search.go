@@ -0,0 +1,193 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// =============================================================================
+// Chat View Search
+// =============================================================================
+
+// SetSearch highlights the lines matching query, ignoring case, and scrolls
+// to the nearest match above the bottom of the view when the search began.
+// An empty query ends the search.
+func (c *ChatViewModel) SetSearch(query string) {
+	if c.search == nil {
+		c.searchFrom = c.offset + c.viewport.Height - 1
+	}
+	c.search = nil
+	if query != "" {
+		c.search = regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+	}
+	c.findMatches()
+
+	c.match = 0
+	for i, line := range c.matches {
+		if line > c.searchFrom {
+			break
+		}
+		c.match = i
+	}
+	c.showMatch()
+}
+
+// findMatches collects the lines matching the search
+func (c *ChatViewModel) findMatches() {
+	c.matches = c.matches[:0]
+	if c.search == nil {
+		return
+	}
+	line := 0
+	for _, msgLines := range c.lines {
+		for _, l := range msgLines {
+			if c.search.MatchString(ansi.Strip(l)) {
+				c.matches = append(c.matches, line)
+			}
+			line++
+		}
+	}
+	if c.match >= len(c.matches) {
+		c.match = max(0, len(c.matches)-1)
+	}
+}
+
+// NextMatch scrolls to the next match, wrapping around at the end
+func (c *ChatViewModel) NextMatch() {
+	if len(c.matches) > 0 {
+		c.match = (c.match + 1) % len(c.matches)
+		c.showMatch()
+	}
+}
+
+// PrevMatch scrolls to the previous match, wrapping around at the start
+func (c *ChatViewModel) PrevMatch() {
+	if len(c.matches) > 0 {
+		c.match = (c.match + len(c.matches) - 1) % len(c.matches)
+		c.showMatch()
+	}
+}
+
+// showMatch centers the current match in the view
+func (c *ChatViewModel) showMatch() {
+	if len(c.matches) > 0 {
+		c.offset = c.matches[c.match] - c.viewport.Height/2
+	}
+	c.refresh()
+}
+
+// SearchStatus returns the current match, counting from 1, and the number
+// of matching lines
+func (c *ChatViewModel) SearchStatus() (current, total int) {
+	if len(c.matches) == 0 {
+		return 0, 0
+	}
+	return c.match + 1, len(c.matches)
+}
+
+// highlight marks the search matches in a rendered line. Matching lines
+// lose their other styling so the matches stand out.
+func (c *ChatViewModel) highlight(l string, line int) string {
+	i, found := slices.BinarySearch(c.matches, line)
+	if !found {
+		return l
+	}
+	style := SearchMatchStyle
+	if i == c.match {
+		style = SearchCurrentStyle
+	}
+	plain := ansi.Strip(l)
+	var out string
+	last := 0
+	for _, m := range c.search.FindAllStringIndex(plain, -1) {
+		out += plain[last:m[0]] + style.Render(plain[m[0]:m[1]])
+		last = m[1]
+	}
+	return out + plain[last:]
+}
+
+// =============================================================================
+// Search Mode
+// =============================================================================
+
+// startSearch starts typing a chat search, started by / in the chat
+func (a *App) startSearch() {
+	a.searching = true
+	a.searchQuery = ""
+	a.chatView.SetSearch("")
+	a.updateSearchStatus()
+}
+
+// endSearch removes the search highlights
+func (a *App) endSearch() {
+	a.searching = false
+	a.searchQuery = ""
+	a.chatView.SetSearch("")
+	a.updateSearchStatus()
+}
+
+// handleSearchKey edits the search query as it is typed. Matches are
+// highlighted while typing; Enter keeps them for n/N and Esc cancels.
+func (a *App) handleSearchKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEnter:
+		if a.searchQuery == "" {
+			a.endSearch()
+			return nil
+		}
+		a.searching = false
+	case tea.KeyEsc:
+		a.endSearch()
+		return nil
+	case tea.KeyBackspace:
+		if a.searchQuery == "" {
+			a.endSearch()
+			return nil
+		}
+		runes := []rune(a.searchQuery)
+		a.searchQuery = string(runes[:len(runes)-1])
+		a.chatView.SetSearch(a.searchQuery)
+	case tea.KeyCtrlU:
+		a.searchQuery = ""
+		a.chatView.SetSearch("")
+	case tea.KeyRunes:
+		a.searchQuery += string(msg.Runes)
+		a.chatView.SetSearch(a.searchQuery)
+	case tea.KeySpace:
+		a.searchQuery += " "
+		a.chatView.SetSearch(a.searchQuery)
+	}
+	a.updateSearchStatus()
+	return nil
+}
+
+// updateSearchStatus shows the search and its match counter in the status
+// bar
+func (a *App) updateSearchStatus() {
+	if !a.searching && a.searchQuery == "" {
+		a.statusBar.SetMode("")
+		return
+	}
+	status := "/" + a.searchQuery
+	if a.searching {
+		status += "▏"
+	}
+	if a.searchQuery != "" {
+		if current, total := a.chatView.SearchStatus(); total == 0 {
+			status += "  no matches"
+		} else {
+			status += fmt.Sprintf("  %d/%d", current, total)
+		}
+	}
+	if !a.searching {
+		status += "  n/N:next/prev esc:clear"
+	}
+	a.statusBar.SetMode(status)
+}
@@ -0,0 +1,402 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/compact"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/guard"
+	"github.com/linkalls/gmn/internal/locale"
+	"github.com/linkalls/gmn/internal/textwidth"
+)
+
+// sparkBars are the bars of the tokens per turn sparkline, lowest first
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// =============================================================================
+// Metrics Collector
+// =============================================================================
+
+// metrics collects the numbers the stats dashboard shows, from the start
+// of the app. Tools and requests run in their own goroutines, so it is
+// safe for concurrent use.
+type metrics struct {
+	mu         sync.Mutex
+	models     map[string]*modelUsage
+	tools      map[string]*toolUsage
+	latencies  []time.Duration // Time to first event of each API request
+	turns      []int           // Tokens of each finished turn
+	turnTokens int             // Tokens of the turn in progress
+}
+
+// modelUsage counts the requests and tokens of one model
+type modelUsage struct {
+	requests int
+	input    int
+	output   int
+}
+
+// toolUsage counts the calls of one tool and how long they ran
+type toolUsage struct {
+	calls    int
+	failures int
+	total    time.Duration
+	longest  time.Duration
+}
+
+// newMetrics creates an empty collector
+func newMetrics() *metrics {
+	return &metrics{
+		models: make(map[string]*modelUsage),
+		tools:  make(map[string]*toolUsage),
+	}
+}
+
+// addUsage counts the tokens of a response from model
+func (m *metrics) addUsage(model string, input, output int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.models[model]
+	if !ok {
+		u = &modelUsage{}
+		m.models[model] = u
+	}
+	u.requests++
+	u.input += input
+	u.output += output
+	m.turnTokens += input + output
+}
+
+// addTool records a tool call that ran for d
+func (m *metrics) addTool(name string, d time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tools[name]
+	if !ok {
+		t = &toolUsage{}
+		m.tools[name] = t
+	}
+	t.calls++
+	if failed {
+		t.failures++
+	}
+	t.total += d
+	t.longest = max(t.longest, d)
+}
+
+// addLatency records how long an API request took to send its first event
+func (m *metrics) addLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies = append(m.latencies, d)
+}
+
+// endTurn closes the tokens of the turn in progress, counting what was
+// used between turns (recaps, compaction) with it
+func (m *metrics) endTurn() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.turns = append(m.turns, m.turnTokens)
+	m.turnTokens = 0
+}
+
+// percentile returns the p-th percentile of sorted durations, by nearest
+// rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	n := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(0, min(n-1, len(sorted)-1))]
+}
+
+// sparkline draws values as bars scaled to the largest, keeping the last
+// width of them
+func sparkline(values []int, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	peak := 0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		n := 0
+		if peak > 0 {
+			n = v * (len(sparkBars) - 1) / peak
+		}
+		b.WriteRune(sparkBars[n])
+	}
+	return b.String()
+}
+
+// roundDuration rounds a duration for the tables
+func roundDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(100 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
+
+// =============================================================================
+// Stats Dashboard Component
+// =============================================================================
+
+// StatsModel shows the metrics full screen: tokens and cost per model,
+// tool calls, API latency and tokens per turn
+type StatsModel struct {
+	visible bool
+	offset  int // First line shown
+	width   int
+	height  int
+}
+
+// Open shows the dashboard from the top
+func (s *StatsModel) Open() {
+	s.visible = true
+	s.offset = 0
+}
+
+// Close hides the dashboard
+func (s *StatsModel) Close() {
+	s.visible = false
+}
+
+// IsVisible returns visibility
+func (s *StatsModel) IsVisible() bool {
+	return s.visible
+}
+
+// SetSize sets the screen size
+func (s *StatsModel) SetSize(width, height int) {
+	s.width = width
+	s.height = height
+}
+
+// rows returns the number of lines shown between the title and the footer
+func (s *StatsModel) rows() int {
+	return max(s.height-6, 1)
+}
+
+// Scroll moves the view by n lines, within the lines to show
+func (s *StatsModel) Scroll(n, lines int) {
+	s.offset = max(0, min(s.offset+n, lines-s.rows()))
+}
+
+// lines renders the sections of the dashboard for m, to fit width
+func (s *StatsModel) lines(m *metrics, elapsed time.Duration) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	loc := locale.Current()
+	width := max(s.width-6, 40)
+	var out []string
+	section := func(title string) {
+		if len(out) > 0 {
+			out = append(out, "")
+		}
+		out = append(out, AccentStyle.Render(title))
+	}
+
+	// Tokens and cost per model, most used first
+	names := make([]string, 0, len(m.models))
+	input, output, requests := 0, 0, 0
+	cost := 0.0
+	for name, u := range m.models {
+		names = append(names, name)
+		input += u.input
+		output += u.output
+		requests += u.requests
+		cost += guard.Cost(name, u.input, u.output)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ui, uj := m.models[names[i]], m.models[names[j]]
+		if ui.input+ui.output != uj.input+uj.output {
+			return ui.input+ui.output > uj.input+uj.output
+		}
+		return names[i] < names[j]
+	})
+
+	section("Overview")
+	out = append(out,
+		fmt.Sprintf("  Duration  %s", elapsed.Round(time.Second)),
+		fmt.Sprintf("  Tokens    %s in, %s out, %s total", loc.Int(input), loc.Int(output), loc.Int(input+output)),
+		fmt.Sprintf("  Requests  %s in %s turns", loc.Int(requests), loc.Int(len(m.turns))),
+		fmt.Sprintf("  Est cost  ~%s", loc.Cost(cost, 4)),
+	)
+
+	section("Tokens per model")
+	if len(names) == 0 {
+		out = append(out, MutedStyle.Render("  No requests yet"))
+	} else {
+		nameWidth := min(max(maxWidth(names), 5), width/2)
+		out = append(out, DimStyle.Render(fmt.Sprintf("  %s  %8s  %9s  %9s  %10s",
+			textwidth.Pad("model", nameWidth), "requests", "input", "output", "cost")))
+		for _, name := range names {
+			u := m.models[name]
+			price := "—"
+			if _, ok := guard.Info(name); ok {
+				price = loc.Cost(guard.Cost(name, u.input, u.output), 4)
+			}
+			out = append(out, fmt.Sprintf("  %s  %8s  %9s  %9s  %10s",
+				textwidth.Pad(textwidth.Truncate(name, nameWidth), nameWidth),
+				loc.Int(u.requests), guard.FormatTokens(u.input), guard.FormatTokens(u.output), price))
+		}
+	}
+
+	// Tools, most called first
+	section("Tool calls")
+	tools := make([]string, 0, len(m.tools))
+	for name := range m.tools {
+		tools = append(tools, name)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		if m.tools[tools[i]].calls != m.tools[tools[j]].calls {
+			return m.tools[tools[i]].calls > m.tools[tools[j]].calls
+		}
+		return tools[i] < tools[j]
+	})
+	if len(tools) == 0 {
+		out = append(out, MutedStyle.Render("  No tool calls yet"))
+	} else {
+		nameWidth := min(max(maxWidth(tools), 4), width/2)
+		out = append(out, DimStyle.Render(fmt.Sprintf("  %s  %6s  %6s  %8s  %8s  %8s",
+			textwidth.Pad("tool", nameWidth), "calls", "failed", "total", "avg", "max")))
+		for _, name := range tools {
+			t := m.tools[name]
+			failed := fmt.Sprintf("%6d", t.failures)
+			if t.failures > 0 {
+				failed = ErrorStyle.Render(failed)
+			}
+			out = append(out, fmt.Sprintf("  %s  %6d  %s  %8s  %8s  %8s",
+				textwidth.Pad(textwidth.Truncate(name, nameWidth), nameWidth),
+				t.calls, failed, roundDuration(t.total),
+				roundDuration(t.total/time.Duration(t.calls)), roundDuration(t.longest)))
+		}
+	}
+
+	section("API latency (time to first token)")
+	if len(m.latencies) == 0 {
+		out = append(out, MutedStyle.Render("  No requests yet"))
+	} else {
+		sorted := append([]time.Duration(nil), m.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		out = append(out, fmt.Sprintf("  p50 %s   p90 %s   p99 %s   max %s   (%d requests)",
+			roundDuration(percentile(sorted, 50)), roundDuration(percentile(sorted, 90)),
+			roundDuration(percentile(sorted, 99)), roundDuration(sorted[len(sorted)-1]), len(sorted)))
+	}
+
+	section("Tokens per turn")
+	if len(m.turns) == 0 {
+		out = append(out, MutedStyle.Render("  No turns yet"))
+	} else {
+		shown := min(len(m.turns), width-2)
+		peak, sum := 0, 0
+		for _, n := range m.turns {
+			peak = max(peak, n)
+			sum += n
+		}
+		out = append(out,
+			"  "+AccentStyle.Render(sparkline(m.turns, width-2)),
+			DimStyle.Render(fmt.Sprintf("  last %d of %d turns  peak %s  avg %s  last %s",
+				shown, len(m.turns), guard.FormatTokens(peak),
+				guard.FormatTokens(sum/len(m.turns)), guard.FormatTokens(m.turns[len(m.turns)-1]))),
+		)
+	}
+	return out
+}
+
+// maxWidth returns the display width of the widest string
+func maxWidth(values []string) int {
+	w := 0
+	for _, v := range values {
+		w = max(w, textwidth.Width(v))
+	}
+	return w
+}
+
+// View renders the dashboard over the whole screen
+func (s *StatsModel) View(m *metrics, elapsed time.Duration) string {
+	lines := s.lines(m, elapsed)
+	s.Scroll(0, len(lines))
+	end := min(s.offset+s.rows(), len(lines))
+
+	var b strings.Builder
+	b.WriteString(AccentStyle.Render(glyphs.Label(glyphs.Current().Stats, "Session Stats")) + "\n\n")
+	b.WriteString(strings.Join(lines[s.offset:end], "\n"))
+	b.WriteString(strings.Repeat("\n", s.rows()-(end-s.offset)))
+	footer := "↑/↓ scroll  esc/q close"
+	if len(lines) > s.rows() {
+		footer = fmt.Sprintf("%d-%d of %d  %s", s.offset+1, end, len(lines), footer)
+	}
+	b.WriteString("\n\n" + HelpStyle.Render(footer))
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(AccentColor).
+		Padding(0, 2).
+		Width(max(s.width-2, 44)).
+		Render(b.String())
+}
+
+// =============================================================================
+// Stats Actions
+// =============================================================================
+
+// addUsage counts the tokens of a response from model in the status bar and
+// the metrics
+func (a *App) addUsage(model string, usage *api.UsageMetadata) {
+	if usage == nil {
+		return
+	}
+	a.inputTokens += usage.PromptTokenCount
+	a.outputTokens += usage.CandidatesTokenCount
+	a.statusBar.SetTokens(a.inputTokens, a.outputTokens)
+	a.metrics.addUsage(model, usage.PromptTokenCount, usage.CandidatesTokenCount)
+}
+
+// compactionModel returns the model that compacts and recaps the history
+func (a *App) compactionModel() string {
+	if a.config.Compaction.Model != "" {
+		return a.config.Compaction.Model
+	}
+	return compact.DefaultModel
+}
+
+// handleStatsKey scrolls the dashboard and closes it with Esc, q or Ctrl+T
+func (a *App) handleStatsKey(msg tea.KeyMsg) tea.Cmd {
+	lines := len(a.stats.lines(a.metrics, time.Since(a.startTime)))
+	switch {
+	case key.Matches(msg, a.keys.Up):
+		a.stats.Scroll(-1, lines)
+	case key.Matches(msg, a.keys.Down):
+		a.stats.Scroll(1, lines)
+	case key.Matches(msg, a.keys.PageUp):
+		a.stats.Scroll(-a.stats.rows(), lines)
+	case key.Matches(msg, a.keys.PageDown):
+		a.stats.Scroll(a.stats.rows(), lines)
+	case key.Matches(msg, a.keys.Home):
+		a.stats.Scroll(-lines, lines)
+	case key.Matches(msg, a.keys.End):
+		a.stats.Scroll(lines, lines)
+	case key.Matches(msg, a.keys.Cancel), key.Matches(msg, a.keys.ShowStats), msg.String() == "q":
+		a.stats.Close()
+	}
+	return nil
+}
@@ -3,37 +3,42 @@
 // SPDX-License-Identifier: Apache-2.0
 package tui
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/termcolor"
+)
 
 // =============================================================================
 // Codex/Gemini CLI Inspired Theme Colors
 // =============================================================================
 
+// Each color has a hand-picked fallback for 16-color terminals, where
+// automatic approximations of these hex values are often wrong.
 var (
 	// Primary colors - Gemini-inspired gradient palette
-	AccentColor  = lipgloss.Color("#8B5CF6") // Vibrant purple
-	AccentColor2 = lipgloss.Color("#06B6D4") // Cyan for gradients
-	SuccessColor = lipgloss.Color("#22C55E") // Bright green
-	DangerColor  = lipgloss.Color("#EF4444") // Red
-	WarningColor = lipgloss.Color("#FBBF24") // Amber
-	InfoColor    = lipgloss.Color("#3B82F6") // Blue
-	MagentaColor = lipgloss.Color("#EC4899") // Magenta for emphasis
-	TealColor    = lipgloss.Color("#14B8A6") // Teal
+	AccentColor  lipgloss.CompleteColor // Vibrant purple
+	AccentColor2 lipgloss.CompleteColor // Cyan for gradients
+	SuccessColor lipgloss.CompleteColor // Bright green
+	DangerColor  lipgloss.CompleteColor // Red
+	WarningColor lipgloss.CompleteColor // Amber
+	InfoColor    lipgloss.CompleteColor // Blue
+	MagentaColor lipgloss.CompleteColor // Magenta for emphasis
+	TealColor    lipgloss.CompleteColor // Teal
 
 	// Neutral colors - Codex-inspired dark theme
-	TextColor       = lipgloss.Color("#F8FAFC") // Bright white text
-	DimTextColor    = lipgloss.Color("#94A3B8") // Slate dim text
-	MutedColor      = lipgloss.Color("#64748B") // Slate muted
-	SurfaceColor    = lipgloss.Color("#1E293B") // Slate dark surface
-	BackgroundColor = lipgloss.Color("#0F172A") // Slate darker background
-	BorderColor     = lipgloss.Color("#334155") // Slate border
-	HighlightColor  = lipgloss.Color("#475569") // Slate highlight
+	TextColor       lipgloss.CompleteColor // Bright white text
+	DimTextColor    lipgloss.CompleteColor // Slate dim text
+	MutedColor      lipgloss.CompleteColor // Slate muted
+	SurfaceColor    lipgloss.CompleteColor // Slate dark surface
+	BackgroundColor lipgloss.CompleteColor // Slate darker background
+	BorderColor     lipgloss.CompleteColor // Slate border
+	HighlightColor  lipgloss.CompleteColor // Slate highlight
 
 	// Special - Conversation colors
-	UserColor   = lipgloss.Color("#22D3EE") // Cyan for user
-	ModelColor  = lipgloss.Color("#A78BFA") // Light purple for model
-	SystemColor = lipgloss.Color("#64748B") // Slate for system
-	ThinkColor  = lipgloss.Color("#818CF8") // Indigo for thinking
+	UserColor   lipgloss.CompleteColor // Cyan for user
+	ModelColor  lipgloss.CompleteColor // Light purple for model
+	SystemColor lipgloss.CompleteColor // Slate for system
+	ThinkColor  lipgloss.CompleteColor // Indigo for thinking
 )
 
 // =============================================================================
@@ -41,264 +46,389 @@ var (
 // =============================================================================
 
 var (
+	// Container styles
+	BaseContainerStyle     lipgloss.Style
+	BorderedContainerStyle lipgloss.Style
+
+	// Gradient border style (simulated with colors)
+	GradientBorderStyle lipgloss.Style
+
+	// Text styles
+	BoldStyle    lipgloss.Style
+	DimStyle     lipgloss.Style
+	MutedStyle   lipgloss.Style
+	ErrorStyle   lipgloss.Style
+	SuccessStyle lipgloss.Style
+	WarningStyle lipgloss.Style
+	AccentStyle  lipgloss.Style
+
+	// Gradient text effect (using alternating colors)
+	GradientTextStyle lipgloss.Style
+)
+
+// =============================================================================
+// Header Styles - Codex/Gemini inspired
+// =============================================================================
+
+var (
+	HeaderStyle     lipgloss.Style
+	LogoStyle       lipgloss.Style
+	ModelBadgeStyle lipgloss.Style
+	YoloBadgeStyle  lipgloss.Style
+	InfoBadgeStyle  lipgloss.Style
+
+	// New: Status indicator badges
+	OnlineBadgeStyle     lipgloss.Style
+	ProcessingBadgeStyle lipgloss.Style
+)
+
+// =============================================================================
+// Sidebar Styles
+// =============================================================================
+
+var (
+	SidebarStyle             lipgloss.Style
+	SidebarTitleStyle        lipgloss.Style
+	SessionItemStyle         lipgloss.Style
+	SessionItemSelectedStyle lipgloss.Style
+	SessionItemCurrentStyle  lipgloss.Style
+	SessionInfoStyle         lipgloss.Style
+)
+
+// =============================================================================
+// Chat Styles
+// =============================================================================
+
+var (
+	ChatContainerStyle lipgloss.Style
+	UserMessageStyle   lipgloss.Style
+	UserPromptStyle    lipgloss.Style
+	ModelMessageStyle  lipgloss.Style
+	ThinkingStyle      lipgloss.Style
+	TimestampStyle     lipgloss.Style
+	CodeBlockStyle     lipgloss.Style
+	SearchMatchStyle   lipgloss.Style
+	SearchCurrentStyle lipgloss.Style
+	SelectionStyle     lipgloss.Style
+)
+
+// =============================================================================
+// Tool Styles
+// =============================================================================
+
+var (
+	ToolCallStyle   lipgloss.Style
+	ToolNameStyle   lipgloss.Style
+	ToolResultStyle lipgloss.Style
+	ToolBoxStyle    lipgloss.Style
+	ToolArgStyle    lipgloss.Style
+)
+
+// =============================================================================
+// Input Styles
+// =============================================================================
+
+var (
+	InputContainerStyle   lipgloss.Style
+	InputPromptStyle      lipgloss.Style
+	InputPlaceholderStyle lipgloss.Style
+	InputCursorStyle      lipgloss.Style
+)
+
+// =============================================================================
+// Status Bar Styles
+// =============================================================================
+
+var (
+	StatusBarStyle     lipgloss.Style
+	StatusKeyStyle     lipgloss.Style
+	StatusValueStyle   lipgloss.Style
+	StatusDividerStyle lipgloss.Style
+)
+
+// =============================================================================
+// Help Styles
+// =============================================================================
+
+var (
+	HelpStyle     lipgloss.Style
+	HelpKeyStyle  lipgloss.Style
+	HelpDescStyle lipgloss.Style
+)
+
+// =============================================================================
+// Spinner Styles
+// =============================================================================
+
+var (
+	SpinnerStyle     lipgloss.Style
+	SpinnerTextStyle lipgloss.Style
+)
+
+// =============================================================================
+// Scrollbar Styles
+// =============================================================================
+
+var (
+	ScrollbarThumbStyle lipgloss.Style
+	ScrollbarTrackStyle lipgloss.Style
+)
+
+// The palette is rebuilt whenever the theme changes
+func init() {
+	termcolor.OnTheme(setStyles)
+}
+
+// setStyles builds the palette and the styles made from it for the current
+// theme
+func setStyles() {
+	// Primary colors - Gemini-inspired gradient palette
+	AccentColor = termcolor.Color("#8B5CF6", termcolor.Magenta)
+	AccentColor2 = termcolor.Color("#06B6D4", termcolor.Cyan)
+	SuccessColor = termcolor.Color("#22C55E", termcolor.Green)
+	DangerColor = termcolor.Color("#EF4444", termcolor.Red)
+	WarningColor = termcolor.Color("#FBBF24", termcolor.Yellow)
+	InfoColor = termcolor.Color("#3B82F6", termcolor.Blue)
+	MagentaColor = termcolor.Color("#EC4899", termcolor.BrightMagenta)
+	TealColor = termcolor.Color("#14B8A6", termcolor.Cyan)
+
+	// Neutral colors - Codex-inspired dark theme
+	TextColor = termcolor.Color("#F8FAFC", termcolor.BrightWhite)
+	DimTextColor = termcolor.Color("#94A3B8", termcolor.White)
+	MutedColor = termcolor.Color("#64748B", termcolor.BrightBlack)
+	SurfaceColor = termcolor.Background("#1E293B", termcolor.Black)
+	BackgroundColor = termcolor.Background("#0F172A", termcolor.Black)
+	BorderColor = termcolor.Border("#334155", termcolor.BrightBlack)
+	HighlightColor = termcolor.Border("#475569", termcolor.BrightBlack)
+
+	// Special - Conversation colors
+	UserColor = termcolor.Color("#22D3EE", termcolor.BrightCyan)
+	ModelColor = termcolor.Color("#A78BFA", termcolor.BrightMagenta)
+	SystemColor = termcolor.Color("#64748B", termcolor.BrightBlack)
+	ThinkColor = termcolor.Color("#818CF8", termcolor.BrightBlue)
+
 	// Container styles
 	BaseContainerStyle = lipgloss.NewStyle().
-				Padding(0, 1)
+		Padding(0, 1)
 
 	BorderedContainerStyle = lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(BorderColor)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(BorderColor)
 
 	// Gradient border style (simulated with colors)
 	GradientBorderStyle = lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(AccentColor)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(AccentColor)
 
 	// Text styles
 	BoldStyle = lipgloss.NewStyle().Bold(true)
 
 	DimStyle = lipgloss.NewStyle().
-			Foreground(DimTextColor)
+		Foreground(DimTextColor)
 
 	MutedStyle = lipgloss.NewStyle().
-			Foreground(MutedColor)
+		Foreground(MutedColor)
 
 	ErrorStyle = lipgloss.NewStyle().
-			Foreground(DangerColor).
-			Bold(true)
+		Foreground(DangerColor).
+		Bold(true)
 
 	SuccessStyle = lipgloss.NewStyle().
-			Foreground(SuccessColor).
-			Bold(true)
+		Foreground(SuccessColor).
+		Bold(true)
 
 	WarningStyle = lipgloss.NewStyle().
-			Foreground(WarningColor)
+		Foreground(WarningColor)
 
 	AccentStyle = lipgloss.NewStyle().
-			Foreground(AccentColor).
-			Bold(true)
+		Foreground(AccentColor).
+		Bold(true)
 
 	// Gradient text effect (using alternating colors)
 	GradientTextStyle = lipgloss.NewStyle().
-				Foreground(AccentColor).
-				Bold(true)
-)
-
-// =============================================================================
-// Header Styles - Codex/Gemini inspired
-// =============================================================================
+		Foreground(AccentColor).
+		Bold(true)
 
-var (
 	HeaderStyle = lipgloss.NewStyle().
-			Border(lipgloss.NormalBorder(), false, false, true, false).
-			BorderForeground(AccentColor).
-			Padding(0, 1).
-			Background(BackgroundColor)
+		Border(lipgloss.NormalBorder(), false, false, true, false).
+		BorderForeground(AccentColor).
+		Padding(0, 1).
+		Background(BackgroundColor)
 
 	LogoStyle = lipgloss.NewStyle().
-			Foreground(AccentColor).
-			Bold(true)
+		Foreground(AccentColor).
+		Bold(true)
 
 	ModelBadgeStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFFFFF")).
-			Background(AccentColor).
-			Padding(0, 1).
-			Bold(true)
+		Foreground(termcolor.On("#FFFFFF", AccentColor)).
+		Background(AccentColor).
+		Padding(0, 1).
+		Bold(true)
 
 	YoloBadgeStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#000000")).
-			Background(WarningColor).
-			Padding(0, 1).
-			Bold(true)
+		Foreground(termcolor.On("#000000", WarningColor)).
+		Background(WarningColor).
+		Padding(0, 1).
+		Bold(true)
 
 	InfoBadgeStyle = lipgloss.NewStyle().
-			Foreground(DimTextColor).
-			Background(SurfaceColor).
-			Padding(0, 1)
+		Foreground(DimTextColor).
+		Background(SurfaceColor).
+		Padding(0, 1)
 
 	// New: Status indicator badges
 	OnlineBadgeStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#000000")).
-				Background(SuccessColor).
-				Padding(0, 1).
-				Bold(true)
+		Foreground(termcolor.On("#000000", SuccessColor)).
+		Background(SuccessColor).
+		Padding(0, 1).
+		Bold(true)
 
 	ProcessingBadgeStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#FFFFFF")).
-				Background(ThinkColor).
-				Padding(0, 1).
-				Bold(true)
-)
+		Foreground(termcolor.On("#FFFFFF", ThinkColor)).
+		Background(ThinkColor).
+		Padding(0, 1).
+		Bold(true)
 
-// =============================================================================
-// Sidebar Styles
-// =============================================================================
-
-var (
 	SidebarStyle = lipgloss.NewStyle().
-			Border(lipgloss.NormalBorder(), false, true, false, false).
-			BorderForeground(BorderColor).
-			Padding(0, 1).
-			Background(BackgroundColor)
+		Border(lipgloss.NormalBorder(), false, true, false, false).
+		BorderForeground(BorderColor).
+		Padding(0, 1).
+		Background(BackgroundColor)
 
 	SidebarTitleStyle = lipgloss.NewStyle().
-				Foreground(AccentColor).
-				Bold(true).
-				Padding(0, 0, 1, 0)
+		Foreground(AccentColor).
+		Bold(true).
+		Padding(0, 0, 1, 0)
 
 	SessionItemStyle = lipgloss.NewStyle().
-				Foreground(TextColor).
-				Padding(0, 1)
+		Foreground(TextColor).
+		Padding(0, 1)
 
 	SessionItemSelectedStyle = lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#FFFFFF")).
-					Background(AccentColor).
-					Padding(0, 1).
-					Bold(true)
+		Foreground(termcolor.On("#FFFFFF", AccentColor)).
+		Background(AccentColor).
+		Padding(0, 1).
+		Bold(true)
 
 	SessionItemCurrentStyle = lipgloss.NewStyle().
-				Foreground(SuccessColor).
-				Padding(0, 1)
+		Foreground(SuccessColor).
+		Padding(0, 1)
 
 	SessionInfoStyle = lipgloss.NewStyle().
-				Foreground(DimTextColor).
-				Padding(0, 1)
-)
+		Foreground(DimTextColor).
+		Padding(0, 1)
 
-// =============================================================================
-// Chat Styles
-// =============================================================================
-
-var (
 	ChatContainerStyle = lipgloss.NewStyle().
-				Padding(0, 1)
+		Padding(0, 1)
 
 	UserMessageStyle = lipgloss.NewStyle().
-				Foreground(UserColor).
-				Bold(true)
+		Foreground(UserColor).
+		Bold(true)
 
 	UserPromptStyle = lipgloss.NewStyle().
-			Foreground(UserColor).
-			Bold(true)
+		Foreground(UserColor).
+		Bold(true)
 
 	ModelMessageStyle = lipgloss.NewStyle().
-				Foreground(TextColor)
+		Foreground(TextColor)
 
 	ThinkingStyle = lipgloss.NewStyle().
-			Foreground(DimTextColor).
-			Italic(true)
+		Foreground(DimTextColor).
+		Italic(true)
 
 	TimestampStyle = lipgloss.NewStyle().
-			Foreground(MutedColor)
+		Foreground(MutedColor)
 
 	CodeBlockStyle = lipgloss.NewStyle().
-			Background(SurfaceColor).
-			Padding(0, 1)
-)
+		Background(SurfaceColor).
+		Padding(0, 1)
 
-// =============================================================================
-// Tool Styles
-// =============================================================================
+	SearchMatchStyle = lipgloss.NewStyle().
+		Reverse(true)
+
+	SearchCurrentStyle = lipgloss.NewStyle().
+		Foreground(WarningColor).
+		Reverse(true).
+		Bold(true)
+
+	SelectionStyle = lipgloss.NewStyle().
+		Foreground(TextColor).
+		Background(HighlightColor)
 
-var (
 	ToolCallStyle = lipgloss.NewStyle().
-			Foreground(AccentColor).
-			Bold(true)
+		Foreground(AccentColor).
+		Bold(true)
 
 	ToolNameStyle = lipgloss.NewStyle().
-			Foreground(WarningColor).
-			Bold(true)
+		Foreground(WarningColor).
+		Bold(true)
 
 	ToolResultStyle = lipgloss.NewStyle().
-			Foreground(SuccessColor)
+		Foreground(SuccessColor)
 
 	ToolBoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(BorderColor).
-			Padding(0, 1)
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(BorderColor).
+		Padding(0, 1)
 
 	ToolArgStyle = lipgloss.NewStyle().
-			Foreground(DimTextColor)
-)
+		Foreground(DimTextColor)
 
-// =============================================================================
-// Input Styles
-// =============================================================================
-
-var (
 	InputContainerStyle = lipgloss.NewStyle().
-				Border(lipgloss.NormalBorder(), true, false, false, false).
-				BorderForeground(BorderColor).
-				Padding(0, 1)
+		Border(lipgloss.NormalBorder(), true, false, false, false).
+		BorderForeground(BorderColor).
+		Padding(0, 1)
 
 	InputPromptStyle = lipgloss.NewStyle().
-				Foreground(SuccessColor).
-				Bold(true)
+		Foreground(SuccessColor).
+		Bold(true)
 
 	InputPlaceholderStyle = lipgloss.NewStyle().
-				Foreground(MutedColor)
+		Foreground(MutedColor)
 
 	InputCursorStyle = lipgloss.NewStyle().
-				Foreground(AccentColor)
-)
-
-// =============================================================================
-// Status Bar Styles
-// =============================================================================
+		Foreground(AccentColor)
 
-var (
 	StatusBarStyle = lipgloss.NewStyle().
-			Background(SurfaceColor).
-			Foreground(DimTextColor).
-			Padding(0, 1)
+		Background(SurfaceColor).
+		Foreground(DimTextColor).
+		Padding(0, 1)
 
 	StatusKeyStyle = lipgloss.NewStyle().
-			Foreground(AccentColor).
-			Bold(true)
+		Foreground(AccentColor).
+		Bold(true)
 
 	StatusValueStyle = lipgloss.NewStyle().
-				Foreground(DimTextColor)
+		Foreground(DimTextColor)
 
 	StatusDividerStyle = lipgloss.NewStyle().
-				Foreground(BorderColor)
-)
-
-// =============================================================================
-// Help Styles
-// =============================================================================
+		Foreground(BorderColor)
 
-var (
 	HelpStyle = lipgloss.NewStyle().
-			Foreground(DimTextColor)
+		Foreground(DimTextColor)
 
 	HelpKeyStyle = lipgloss.NewStyle().
-			Foreground(AccentColor).
-			Bold(true)
+		Foreground(AccentColor).
+		Bold(true)
 
 	HelpDescStyle = lipgloss.NewStyle().
-			Foreground(MutedColor)
-)
-
-// =============================================================================
-// Spinner Styles
-// =============================================================================
+		Foreground(MutedColor)
 
-var (
 	SpinnerStyle = lipgloss.NewStyle().
-			Foreground(AccentColor)
+		Foreground(AccentColor)
 
 	SpinnerTextStyle = lipgloss.NewStyle().
-				Foreground(DimTextColor)
-)
-
-// =============================================================================
-// Scrollbar Styles
-// =============================================================================
+		Foreground(DimTextColor)
 
-var (
 	ScrollbarThumbStyle = lipgloss.NewStyle().
-				Foreground(MutedColor)
+		Foreground(MutedColor)
 
 	ScrollbarTrackStyle = lipgloss.NewStyle().
-				Foreground(BorderColor)
-)
+		Foreground(BorderColor)
+
+	// Styles kept next to the components that use them
+	setConfirmStyles()
+	setContextStyles()
+	setPreviewStyles()
+	setThinkingStyles()
+}
@@ -0,0 +1,71 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	"github.com/linkalls/gmn/internal/tools"
+	"github.com/muesli/cancelreader"
+)
+
+// takeoverDoneMsg reports that the user handed the terminal back
+type takeoverDoneMsg struct{ err error }
+
+// ptyTakeover gives the user's terminal to a shell command running in a
+// PTY, so they can answer its prompts. It implements tea.ExecCommand.
+type ptyTakeover struct {
+	session *tools.PTYSession
+	stdin   io.Reader
+	stdout  io.Writer
+}
+
+func (t *ptyTakeover) SetStdin(r io.Reader)  { t.stdin = r }
+func (t *ptyTakeover) SetStdout(w io.Writer) { t.stdout = w }
+func (t *ptyTakeover) SetStderr(io.Writer)   {}
+
+// Run attaches the terminal until the command exits or Ctrl+] is pressed
+func (t *ptyTakeover) Run() error {
+	fd := os.Stdin.Fd()
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	// A cancelable reader, so no read is left pending to steal the next
+	// key from the TUI after detaching
+	input, err := cancelreader.NewReader(t.stdin)
+	if err != nil {
+		return err
+	}
+	defer input.Cancel()
+
+	fmt.Fprintf(t.stdout, "\r\n--- gmn: attached to %q, press Ctrl+] to return ---\r\n\r\n", t.session.Command)
+	cols, rows, _ := term.GetSize(os.Stdout.Fd())
+	err = t.session.Attach(input, t.stdout, rows, cols)
+	if err == cancelreader.ErrCanceled {
+		err = nil
+	}
+	return err
+}
+
+// takeOverTerminal hands the terminal to the running PTY command, if any
+func (a *App) takeOverTerminal() tea.Cmd {
+	session := tools.ActivePTY()
+	if session == nil {
+		a.chatView.AddMessage(ChatMessage{
+			Type:    MessageTypeSystem,
+			Content: "No terminal command is running",
+		})
+		return nil
+	}
+	return tea.Exec(&ptyTakeover{session: session}, func(err error) tea.Msg {
+		return takeoverDoneMsg{err: err}
+	})
+}
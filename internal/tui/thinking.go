@@ -254,9 +254,14 @@ func abs(x int) int {
 // Thinking Box Style
 // =============================================================================
 
-var ThinkingBoxStyle = lipgloss.NewStyle().
-	Border(lipgloss.RoundedBorder()).
-	BorderForeground(AccentColor).
-	Padding(0, 1).
-	MarginTop(1).
-	MarginBottom(1)
+var ThinkingBoxStyle lipgloss.Style
+
+// setThinkingStyles builds the styles of this file for the current theme
+func setThinkingStyles() {
+	ThinkingBoxStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(AccentColor).
+		Padding(0, 1).
+		MarginTop(1).
+		MarginBottom(1)
+}
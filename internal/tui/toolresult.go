@@ -0,0 +1,121 @@
+// Package tui provides a full-featured terminal user interface for gmn.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/linkalls/gmn/internal/glyphs"
+	"github.com/linkalls/gmn/internal/textwidth"
+	"github.com/linkalls/gmn/internal/tools"
+	"github.com/mattn/go-runewidth"
+)
+
+// maxTableCell caps the width of a table cell
+const maxTableCell = 48
+
+// toolSummary returns the one-line "✓ ..." or "✗ ..." status of a tool result
+func toolSummary(result map[string]interface{}) string {
+	if errMsg, ok := result["error"].(string); ok {
+		return "✗ " + errMsg
+	}
+	summary := tools.Summary(result)
+	if summary == "" {
+		return "✓ Completed"
+	}
+	summary = textwidth.Truncate(summary, 50)
+	return "✓ " + summary
+}
+
+// renderToolBody renders the body of a tool result according to its media
+// type, limited to maxLines lines. It returns "" for results without one.
+func renderToolBody(result map[string]interface{}, md *MarkdownRenderer, maxLines int) string {
+	var body string
+	switch tools.MediaType(result) {
+	case tools.MediaText:
+		body = strings.TrimRight(tools.BodyText(result), "\n")
+	case tools.MediaMarkdown:
+		text := tools.BodyText(result)
+		if text == "" {
+			return ""
+		}
+		body = strings.TrimRight(md.Render(text), "\n")
+	case tools.MediaDiff:
+		body = renderUnifiedDiff(tools.BodyText(result))
+	case tools.MediaTable:
+		body = renderTable(tools.Table(result))
+	case tools.MediaImage:
+		if path := tools.BodyText(result); path != "" {
+			body = DimStyle.Render(glyphs.Label(glyphs.Current().Image, path))
+		}
+	}
+	if body == "" {
+		return ""
+	}
+	return truncateLines(body, maxLines)
+}
+
+// renderUnifiedDiff colors a unified diff
+func renderUnifiedDiff(diff string) string {
+	added := lipgloss.NewStyle().Foreground(SuccessColor)
+	removed := lipgloss.NewStyle().Foreground(DangerColor)
+	hunk := lipgloss.NewStyle().Foreground(AccentColor)
+
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = BoldStyle.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = hunk.Render(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = added.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = removed.Render(line)
+		default:
+			lines[i] = DimStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderTable lays out rows in aligned columns under a dim header
+func renderTable(columns []string, rows [][]string) string {
+	if len(columns) == 0 || len(rows) == 0 {
+		return ""
+	}
+
+	widths := make([]int, len(columns))
+	for i, c := range columns {
+		widths[i] = runewidth.StringWidth(c)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if w := runewidth.StringWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	for i := range widths {
+		if widths[i] > maxTableCell {
+			widths[i] = maxTableCell
+		}
+	}
+
+	format := func(cells []string) string {
+		parts := make([]string, len(cells))
+		for i, cell := range cells {
+			cell = runewidth.Truncate(cell, widths[i], "…")
+			parts[i] = runewidth.FillRight(cell, widths[i])
+		}
+		return strings.TrimRight(strings.Join(parts, "  "), " ")
+	}
+
+	lines := []string{DimStyle.Render(format(columns))}
+	for _, row := range rows {
+		lines = append(lines, format(row))
+	}
+	return strings.Join(lines, "\n")
+}
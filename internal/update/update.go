@@ -0,0 +1,299 @@
+// Package update implements self-update from GitHub releases.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	apiBaseURL    = "https://api.github.com"
+	repo          = "linkalls/gmn"
+	checksumsFile = "checksums.txt"
+	maxDownload   = 200 << 20 // 200MB
+)
+
+// Release is a GitHub release
+type Release struct {
+	TagName string  `json:"tag_name"`
+	HTMLURL string  `json:"html_url"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
+}
+
+// Version returns the release version without the leading "v"
+func (r *Release) Version() string {
+	return strings.TrimPrefix(r.TagName, "v")
+}
+
+// Asset returns the asset with the given name
+func (r *Release) Asset(name string) (*Asset, bool) {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i], true
+		}
+	}
+	return nil, false
+}
+
+// FetchRelease fetches a release by tag, or the latest release if tag is empty
+func FetchRelease(ctx context.Context, tag string) (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", apiBaseURL, repo)
+	if tag != "" {
+		if !strings.HasPrefix(tag, "v") {
+			tag = "v" + tag
+		}
+		url = fmt.Sprintf("%s/repos/%s/releases/tags/%s", apiBaseURL, repo, tag)
+	}
+
+	data, err := get(ctx, url, 10<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release: %w", err)
+	}
+
+	var rel Release
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	if rel.TagName == "" {
+		return nil, fmt.Errorf("release not found")
+	}
+	return &rel, nil
+}
+
+// IsNewer reports whether version latest is newer than current.
+// Non-release builds (e.g. "dev") are always considered older.
+func IsNewer(current, latest string) bool {
+	cur, ok := parseVersion(current)
+	if !ok {
+		return true
+	}
+	lat, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	for i := range cur {
+		if lat[i] != cur[i] {
+			return lat[i] > cur[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses "v1.2.3" (pre-release suffixes are ignored)
+func parseVersion(v string) ([3]int, bool) {
+	var out [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return out, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return out, false
+		}
+		out[i] = n
+	}
+	return out, true
+}
+
+// ArchiveName returns the goreleaser archive name for the current platform
+func ArchiveName(version string) string {
+	ext := "tar.gz"
+	if runtime.GOOS == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("gmn_%s_%s_%s.%s", version, runtime.GOOS, runtime.GOARCH, ext)
+}
+
+// Download fetches the release archive for the current platform, verifies it
+// against the release's SHA-256 checksums file and returns the gmn binary.
+func Download(ctx context.Context, rel *Release) ([]byte, error) {
+	name := ArchiveName(rel.Version())
+	archive, ok := rel.Asset(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no build for %s/%s", rel.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	sums, ok := rel.Asset(checksumsFile)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s; refusing to install an unverified binary", rel.TagName, checksumsFile)
+	}
+
+	sumData, err := get(ctx, sums.URL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download checksums: %w", err)
+	}
+	want, err := findChecksum(sumData, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := get(ctx, archive.URL, maxDownload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, want, got)
+	}
+
+	return extractBinary(name, data)
+}
+
+// findChecksum looks up the SHA-256 for name in a "<sum>  <file>" listing
+func findChecksum(data []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum listed for %s", name)
+}
+
+// extractBinary returns the gmn executable from a tar.gz or zip archive
+func extractBinary(name string, data []byte) ([]byte, error) {
+	binName := "gmn"
+	if runtime.GOOS == "windows" {
+		binName = "gmn.exe"
+	}
+
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive: %w", err)
+		}
+		for _, f := range zr.File {
+			if path.Base(f.Name) != binName {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract %s: %w", binName, err)
+			}
+			defer rc.Close()
+			return io.ReadAll(io.LimitReader(rc, maxDownload))
+		}
+		return nil, fmt.Errorf("%s not found in %s", binName, name)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == binName {
+			return io.ReadAll(io.LimitReader(tr, maxDownload))
+		}
+	}
+	return nil, fmt.Errorf("%s not found in %s", binName, name)
+}
+
+// Replace atomically replaces the executable at exePath with binary.
+// The old executable is moved aside first, which also works on Windows
+// where a running executable cannot be overwritten.
+func Replace(exePath string, binary []byte) error {
+	info, err := os.Stat(exePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat executable: %w", err)
+	}
+
+	dir := filepath.Dir(exePath)
+	tmp, err := os.CreateTemp(dir, ".gmn-upgrade-*")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s (try running with sudo): %w", dir, err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+	if err := os.Chmod(tmpPath, info.Mode().Perm()|0111); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	oldPath := exePath + ".old"
+	os.Remove(oldPath)
+	if err := os.Rename(exePath, oldPath); err != nil {
+		return fmt.Errorf("failed to move current binary aside: %w", err)
+	}
+	if err := os.Rename(tmpPath, exePath); err != nil {
+		// Restore the original binary
+		os.Rename(oldPath, exePath)
+		return fmt.Errorf("failed to install new binary: %w", err)
+	}
+	// Best effort: Windows keeps the running executable locked
+	os.Remove(oldPath)
+	return nil
+}
+
+// get performs a GET request and returns at most limit bytes of the body
+func get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "gmn-upgrade")
+	if strings.HasPrefix(url, apiBaseURL) {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		// Avoid the unauthenticated rate limit in CI
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
@@ -7,11 +7,16 @@ import (
 	"github.com/linkalls/gmn/cmd"
 )
 
-// version is set via ldflags at build time
-var version = "dev"
+// Set via ldflags at build time
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
 
 func main() {
 	cmd.SetVersion(version)
+	cmd.SetBuildInfo(commit, date)
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -0,0 +1,519 @@
+// Package agent runs gmn's agent loop: it streams the model's response,
+// executes the tool calls it makes and sends the results back until the
+// model answers. Other Go programs can use it to embed gmn's agent without
+// the CLI or TUI.
+//
+//	conn, err := agent.Connect(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	a, err := agent.New(agent.Options{
+//		Client:    conn.Client,
+//		ProjectID: conn.ProjectID,
+//		Tools:     agent.NewRegistry("."),
+//		Confirm: func(ctx context.Context, req agent.ConfirmRequest) (agent.Decision, error) {
+//			return agent.Allow, nil
+//		},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	answer, err := a.Send(ctx, "What does main.go do?", func(ev agent.Event) {
+//		fmt.Print(ev.Text)
+//	})
+//
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/confirmation"
+	"github.com/linkalls/gmn/internal/telemetry"
+	"github.com/linkalls/gmn/internal/tools"
+	pkgtools "github.com/linkalls/gmn/pkg/tools"
+)
+
+// Types shared with the rest of gmn
+type (
+	Client       = api.Client
+	Content      = api.Content
+	Part         = api.Part
+	FunctionCall = api.FunctionCall
+	Usage        = api.UsageMetadata
+	Request      = api.GenerateRequest
+	Registry     = pkgtools.Registry
+	Tool         = pkgtools.Tool
+	AllowList    = confirmation.AllowList
+)
+
+// Defaults used when the options do not set them
+const (
+	DefaultModel         = "gemini-2.5-flash"
+	DefaultMaxIterations = 10
+	DefaultTimeout       = 5 * time.Minute
+	DefaultTemperature   = 1.0
+)
+
+// NewRegistry returns the built-in tools, working in workDir. Custom tools
+// are added with Register (see package github.com/linkalls/gmn/pkg/tools).
+func NewRegistry(workDir string) *Registry {
+	return pkgtools.NewRegistry(workDir)
+}
+
+// NewAllowList returns an empty list of tools allowed without confirmation
+func NewAllowList() *AllowList {
+	return confirmation.NewAllowList()
+}
+
+// EventType is the kind of an Event
+type EventType string
+
+const (
+	EventStart      EventType = "start"       // A request was sent; Model is the model answering it
+	EventText       EventType = "text"        // Text streamed by the model
+	EventToolCall   EventType = "tool_call"   // The model called a tool; it runs once the response is complete
+	EventToolResult EventType = "tool_result" // A tool call finished (or was refused)
+	EventDone       EventType = "done"        // A model response is complete
+)
+
+// Event reports progress while a message is processed
+type Event struct {
+	Type     EventType
+	Text     string                 // EventText
+	Call     *FunctionCall          // EventToolCall, EventToolResult
+	Tool     Tool                   // EventToolResult; nil for unknown tools
+	Result   map[string]interface{} // EventToolResult
+	Duration time.Duration          // EventToolResult: how long the tool ran
+	Usage    *Usage                 // EventDone
+	Model    string                 // EventStart
+}
+
+// EventFunc receives events as they happen, in order, on the goroutine
+// that called Send
+type EventFunc func(Event)
+
+// Decision is the answer to a confirmation request
+type Decision int
+
+const (
+	Deny        Decision = iota // Do not run the tool; the model is told it was cancelled
+	Allow                       // Run the tool once
+	AllowAlways                 // Run the tool and stop asking for it
+)
+
+// ConfirmRequest asks whether a tool that changes something may run
+type ConfirmRequest struct {
+	Tool Tool
+	Call *FunctionCall
+}
+
+// ConfirmFunc decides whether a tool call may run. An error stops the turn.
+type ConfirmFunc func(ctx context.Context, req ConfirmRequest) (Decision, error)
+
+// Options configure an Agent
+type Options struct {
+	Client    *Client // Required
+	ProjectID string
+	Model     string // DefaultModel if empty
+
+	// Fallback lists models tried in order when the model is unavailable
+	// (rate limited or not found)
+	Fallback []string
+
+	Tools     *Registry  // Tools offered to the model; none if nil
+	AllowList *AllowList // Tools allowed without confirmation; empty if nil
+
+	// Confirm is asked before tools that need confirmation run. If it is
+	// nil, those calls are denied.
+	Confirm ConfirmFunc
+
+	// BeforeRequest is called before each request of a turn, with the
+	// number of requests already sent for it. An error stops the turn.
+	BeforeRequest func(ctx context.Context, req *Request, iteration int) error
+
+	MaxIterations int           // Requests per message; DefaultMaxIterations if zero
+	Timeout       time.Duration // Per request; DefaultTimeout if zero
+	FailureNotes  bool          // Tell the model about tools that keep failing
+	Temperature   float64       // Sampling temperature; DefaultTemperature if zero
+}
+
+// Agent holds a conversation with the model. It is not safe for concurrent
+// use.
+type Agent struct {
+	opts     Options
+	registry *tools.Registry // Behind opts.Tools; nil without tools
+	model    string
+	history  []Content
+}
+
+// ErrMaxIterations is returned when the model keeps calling tools for
+// more than MaxIterations requests
+var ErrMaxIterations = errors.New("max tool iterations reached")
+
+// New returns an Agent with an empty conversation
+func New(opts Options) (*Agent, error) {
+	if opts.Client == nil {
+		return nil, errors.New("agent: Client is required")
+	}
+	if opts.Model == "" {
+		opts.Model = DefaultModel
+	}
+	if opts.AllowList == nil {
+		opts.AllowList = NewAllowList()
+	}
+	if opts.MaxIterations <= 0 {
+		opts.MaxIterations = DefaultMaxIterations
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Temperature <= 0 {
+		opts.Temperature = DefaultTemperature
+	}
+	a := &Agent{opts: opts, model: opts.Model}
+	if opts.Tools != nil {
+		a.registry = tools.RegistryOf(opts.Tools)
+	}
+	return a, nil
+}
+
+// Model returns the model in use, which changes after a fallback
+func (a *Agent) Model() string {
+	return a.model
+}
+
+// SetModel switches the model for the next requests
+func (a *Agent) SetModel(model string) {
+	a.model = model
+}
+
+// History returns the conversation so far
+func (a *Agent) History() []Content {
+	return a.history
+}
+
+// SetHistory replaces the conversation, e.g. to resume a saved session
+func (a *Agent) SetHistory(history []Content) {
+	a.history = history
+}
+
+// Send sends a message and runs the tool loop until the model answers,
+// returning the answer. If it fails, the conversation is left as it was.
+// onEvent may be nil.
+func (a *Agent) Send(ctx context.Context, text string, onEvent EventFunc) (string, error) {
+	return a.SendParts(ctx, []Part{{Text: text}}, onEvent)
+}
+
+// SendParts is Send for a message of several parts, e.g. text and images
+func (a *Agent) SendParts(ctx context.Context, parts []Part, onEvent EventFunc) (string, error) {
+	if onEvent == nil {
+		onEvent = func(Event) {}
+	}
+
+	before := len(a.history)
+	a.history = append(a.history, Content{
+		Role:  "user",
+		Parts: parts,
+	})
+	answer, err := a.run(ctx, onEvent)
+	if err != nil {
+		a.history = a.history[:before]
+		return "", err
+	}
+	return answer, nil
+}
+
+// run sends requests until a response has no tool calls
+func (a *Agent) run(ctx context.Context, onEvent EventFunc) (string, error) {
+	for i := 0; i < a.opts.MaxIterations; i++ {
+		req := a.newRequest(i)
+		if a.opts.BeforeRequest != nil {
+			if err := a.opts.BeforeRequest(ctx, req, i); err != nil {
+				return "", err
+			}
+		}
+
+		text, calls, err := a.stream(ctx, req, onEvent)
+		if err != nil {
+			return "", err
+		}
+		if len(calls) == 0 {
+			a.history = append(a.history, Content{
+				Role:  "model",
+				Parts: []Part{{Text: text}},
+			})
+			return text, nil
+		}
+		if err := a.runTools(ctx, calls, onEvent); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("%w (%d)", ErrMaxIterations, a.opts.MaxIterations)
+}
+
+// newRequest builds the request for the conversation so far
+func (a *Agent) newRequest(iteration int) *Request {
+	req := &Request{
+		Model:        a.model,
+		Project:      a.opts.ProjectID,
+		UserPromptID: fmt.Sprintf("gmn-chat-%d-%d", time.Now().UnixNano(), iteration),
+		Request: api.InnerRequest{
+			Contents: a.history,
+			Config: api.GenerationConfig{
+				Temperature:     a.opts.Temperature,
+				TopP:            0.95,
+				MaxOutputTokens: 8192,
+			},
+		},
+	}
+	if a.registry != nil {
+		req.Request.SystemInstruction = a.registry.SystemInstruction(a.opts.FailureNotes)
+		req.Request.Tools = a.registry.GetTools()
+	}
+	return req
+}
+
+// stream sends a request, falling back to other models if the model is
+// unavailable, and collects the response's text and tool calls. A stream
+// that stalls before sending anything is retried once, on the first
+// fallback model if there is one.
+func (a *Agent) stream(ctx context.Context, req *Request, onEvent EventFunc) (string, []*Part, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, a.opts.Timeout)
+	defer cancel()
+
+	retried := false
+	for {
+		text, calls, err := a.receive(reqCtx, req, onEvent)
+		if err == nil {
+			return text, calls, nil
+		}
+		if !retried && errors.Is(err, api.ErrStalled) && text == "" && len(calls) == 0 {
+			retried = true
+			a.model = a.nextModel()
+			continue
+		}
+		return "", nil, err
+	}
+}
+
+// nextModel returns the model to retry a stalled request with
+func (a *Agent) nextModel() string {
+	for _, m := range a.opts.Fallback {
+		if m != a.model {
+			return m
+		}
+	}
+	return a.model
+}
+
+// receive sends a request and collects the response. On failure it
+// returns what was received before the error.
+func (a *Agent) receive(ctx context.Context, req *Request, onEvent EventFunc) (string, []*Part, error) {
+	events, err := a.generate(ctx, req, onEvent)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var text strings.Builder
+	var calls []*Part // Full parts keep the thought signature Gemini 3 needs
+	for ev := range events {
+		switch ev.Type {
+		case "error":
+			err := ev.Err
+			if err == nil {
+				err = errors.New(ev.Error)
+			}
+			return text.String(), calls, err
+		case "start":
+			// Reported by generate, which knows the model
+		case "tool_call":
+			if ev.ToolCall == nil {
+				continue
+			}
+			part := ev.ToolCallPart
+			if part == nil {
+				part = &Part{FunctionCall: ev.ToolCall}
+			}
+			calls = append(calls, part)
+			onEvent(Event{Type: EventToolCall, Call: ev.ToolCall})
+		case "done":
+			onEvent(Event{Type: EventDone, Usage: ev.Usage})
+		default:
+			if ev.Text != "" {
+				text.WriteString(ev.Text)
+				onEvent(Event{Type: EventText, Text: ev.Text})
+			}
+		}
+	}
+	return text.String(), calls, nil
+}
+
+// generate starts streaming, trying the fallback models in turn while the
+// model is unavailable
+func (a *Agent) generate(ctx context.Context, req *Request, onEvent EventFunc) (<-chan api.StreamEvent, error) {
+	models := []string{a.model}
+	for _, m := range a.opts.Fallback {
+		if m != a.model {
+			models = append(models, m)
+		}
+	}
+
+	for attempt, model := range models {
+		req.Model = model
+		events, err := a.opts.Client.GenerateStream(ctx, req)
+		if err != nil {
+			if IsRetryable(err) && attempt < len(models)-1 {
+				continue
+			}
+			return nil, err
+		}
+		a.model = model
+		onEvent(Event{Type: EventStart, Model: model})
+		return events, nil
+	}
+	return nil, errors.New("all fallback models failed")
+}
+
+// IsRetryable reports whether an error means the model is unavailable
+// (rate limited, overloaded or not found), so another model may work
+func IsRetryable(err error) bool {
+	errStr := err.Error()
+	return strings.Contains(errStr, "429") ||
+		strings.Contains(errStr, "404") ||
+		strings.Contains(errStr, "503") ||
+		strings.Contains(errStr, "RESOURCE_EXHAUSTED") ||
+		strings.Contains(errStr, "UNAVAILABLE") ||
+		strings.Contains(errStr, "NOT_FOUND") ||
+		strings.Contains(errStr, "model not found") ||
+		strings.Contains(errStr, "Model not found")
+}
+
+// runTools executes the tool calls of a response in order and adds them
+// with their results to the history. Runs of read-only calls are executed
+// concurrently when the first of them is reached.
+func (a *Agent) runTools(ctx context.Context, calls []*Part, onEvent EventFunc) error {
+	results := make([]map[string]interface{}, len(calls))
+	durations := make([]time.Duration, len(calls))
+	for i, part := range calls {
+		fc := part.FunctionCall
+		tool, ok := a.tool(fc.Name)
+		if ok && results[i] == nil && tools.ReadOnly(tool) {
+			a.runReadOnly(ctx, calls[i:], results[i:], durations[i:])
+		}
+
+		switch {
+		case !ok:
+			results[i] = map[string]interface{}{"error": "unknown tool: " + fc.Name}
+
+		case results[i] != nil:
+			// Executed with the read-only run it belongs to
+
+		default:
+			allowed, err := a.confirm(ctx, tool, fc)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				results[i] = map[string]interface{}{"error": "operation cancelled by user"}
+				break
+			}
+			start := time.Now()
+			results[i] = ExecuteContext(ctx, a.opts.Tools, tool, fc.Args)
+			durations[i] = time.Since(start)
+		}
+		onEvent(Event{Type: EventToolResult, Call: fc, Tool: tool, Result: results[i], Duration: durations[i]})
+
+		responseID := fc.ID
+		if responseID == "" {
+			responseID = fmt.Sprintf("%s-%d", fc.Name, time.Now().UnixNano())
+		}
+		a.history = append(a.history,
+			Content{Role: "model", Parts: []Part{*part}},
+			Content{Role: "user", Parts: tools.ResponseParts(responseID, fc.Name, results[i])},
+		)
+	}
+	return nil
+}
+
+// runReadOnly concurrently executes the read-only calls at the start of
+// calls, storing each result and duration at the same index
+func (a *Agent) runReadOnly(ctx context.Context, calls []*Part, results []map[string]interface{}, durations []time.Duration) {
+	var run []Tool
+	for _, p := range calls {
+		tool, ok := a.tool(p.FunctionCall.Name)
+		if !ok || !tools.ReadOnly(tool) {
+			break
+		}
+		run = append(run, tool)
+	}
+	tools.Parallel(len(run), tools.MaxParallel, func(i int) {
+		start := time.Now()
+		results[i] = ExecuteContext(ctx, a.opts.Tools, run[i], calls[i].FunctionCall.Args)
+		durations[i] = time.Since(start)
+	})
+}
+
+// tool looks up a tool offered to the model
+func (a *Agent) tool(name string) (Tool, bool) {
+	if a.opts.Tools == nil {
+		return nil, false
+	}
+	return a.opts.Tools.Get(name)
+}
+
+// confirm reports whether a tool call may run. The command policy blocks
+// or auto-approves shell commands first; blocked ones are refused by the
+// tool itself.
+func (a *Agent) confirm(ctx context.Context, tool Tool, fc *FunctionCall) (bool, error) {
+	decision, _ := tools.CheckCall(tool, fc.Args)
+	if decision != tools.DecisionAsk || !tool.RequiresConfirmation() {
+		return true, nil
+	}
+	if !tools.AlwaysConfirm(tool) && a.opts.AllowList.IsAllowed(fc.Name) {
+		return true, nil
+	}
+	if a.opts.Confirm == nil {
+		return false, nil
+	}
+
+	answer, err := a.opts.Confirm(ctx, ConfirmRequest{Tool: tool, Call: fc})
+	if err != nil {
+		return false, fmt.Errorf("confirmation error: %w", err)
+	}
+	switch answer {
+	case AllowAlways:
+		a.opts.AllowList.Allow(fc.Name)
+		return true, nil
+	case Allow:
+		return true, nil
+	}
+	return false, nil
+}
+
+// Execute runs a tool, turning execution errors into error results, and
+// counts the call in the registry's stats
+func Execute(registry *Registry, tool Tool, args map[string]interface{}) map[string]interface{} {
+	return ExecuteContext(context.Background(), registry, tool, args)
+}
+
+// ExecuteContext is Execute for a call that stops when ctx is cancelled,
+// for tools that support it (MCP tools)
+func ExecuteContext(ctx context.Context, registry *Registry, tool Tool, args map[string]interface{}) map[string]interface{} {
+	start := time.Now()
+	result, err := tools.ExecuteContext(ctx, tool, args)
+	telemetry.RecordTool(tool.Name(), start, err)
+	if err != nil {
+		result = map[string]interface{}{"error": err.Error()}
+	}
+	if registry != nil {
+		tools.RegistryOf(registry).Stats().Record(tool.Name(), result)
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+// Package agent runs gmn's agent loop.
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/linkalls/gmn/internal/api"
+	"github.com/linkalls/gmn/internal/auth"
+	"github.com/linkalls/gmn/internal/config"
+)
+
+// Connection is an API client authenticated with the user's Google account
+type Connection struct {
+	Client    *Client
+	ProjectID string
+	Tier      string // Code Assist tier, e.g. "free-tier" or "standard-tier"
+}
+
+// Connect creates a client from the credentials saved by "gmn login",
+// refreshing them if they expired, and looks up the Code Assist project
+// unless gmn has cached it
+func Connect(ctx context.Context) (Connection, error) {
+	authMgr, err := auth.NewManager()
+	if err != nil {
+		return Connection{}, fmt.Errorf("failed to initialize auth: %w", err)
+	}
+	creds, err := authMgr.LoadCredentials()
+	if err != nil {
+		return Connection{}, err
+	}
+	if creds.IsExpired() {
+		if creds, err = authMgr.RefreshToken(creds); err != nil {
+			return Connection{}, err
+		}
+	}
+
+	conn := Connection{Client: api.NewClient(authMgr.HTTPClient(creds))}
+	var project string // security.auth.project replaces the assigned project
+	if cfg, err := config.Load(); err == nil {
+		conn.Client.SetStallTimeout(cfg.General.StallTimeout())
+		project = cfg.Security.Auth.Project
+	}
+	if cached, err := config.LoadCachedState(); err == nil {
+		conn.ProjectID, conn.Tier = cached.ProjectID, cached.UserTier
+	}
+	if conn.ProjectID != "" {
+		if project != "" {
+			conn.ProjectID = project
+		}
+		return conn, nil
+	}
+
+	loadResp, err := conn.Client.LoadCodeAssist(ctx)
+	if err != nil {
+		return Connection{}, fmt.Errorf("failed to load Code Assist: %w", err)
+	}
+	conn.ProjectID = loadResp.CloudAICompanionProject
+	if loadResp.CurrentTier != nil {
+		conn.Tier = loadResp.CurrentTier.ID
+	}
+	_ = config.SaveCachedState(&config.CachedState{
+		ProjectID: conn.ProjectID,
+		UserTier:  conn.Tier,
+	})
+	if project != "" {
+		conn.ProjectID = project
+	}
+	return conn, nil
+}
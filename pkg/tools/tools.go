@@ -0,0 +1,125 @@
+// Package tools is the stable interface for adding tools to gmn: programs
+// that embed gmn (see package agent) implement Tool and register it in a
+// Registry next to the built-in tools.
+//
+// The identifiers in this package follow semantic versioning: within a
+// major version of gmn they are neither removed nor changed in incompatible
+// ways. Tool never gains methods; optional behavior is added as separate
+// interfaces, such as AlwaysConfirmer and ReadOnlyTool, that tools may
+// implement.
+//
+// Copyright 2025 Tomohiro Owada
+// SPDX-License-Identifier: Apache-2.0
+package tools
+
+import (
+	"encoding/json"
+
+	"github.com/linkalls/gmn/internal/tools"
+)
+
+// Tool is a function the model can call
+type Tool interface {
+	// Name returns the tool name used in function calls
+	Name() string
+	// DisplayName returns the human-readable name for display
+	DisplayName() string
+	// Description tells the model what the tool does and when to use it
+	Description() string
+	// Parameters returns the JSON schema for the tool's parameters
+	Parameters() json.RawMessage
+	// Execute runs the tool with the arguments the model passed. Failures
+	// the model should see are returned as an "error" field of the result;
+	// a returned error is turned into one.
+	Execute(args map[string]interface{}) (map[string]interface{}, error)
+	// RequiresConfirmation returns whether the user is asked before the
+	// tool runs. Not needing it does not make a tool read-only (see
+	// ReadOnlyTool).
+	RequiresConfirmation() bool
+	// ConfirmationType returns the kind of confirmation shown: "edit",
+	// "exec", "shell", "fetch" or "mcp", or "" for read-only tools
+	ConfirmationType() string
+}
+
+// AlwaysConfirmer is implemented by tools that must be confirmed every
+// time, even after the user allowed them for the session
+type AlwaysConfirmer interface {
+	AlwaysConfirm() bool
+}
+
+// ReadOnlyTool is implemented by tools that change nothing, neither files
+// nor anything outside gmn. Calls to them may run concurrently with each
+// other, and "gmn run" offers them without confirmation. Tools that do not
+// implement it, or return false, run one at a time.
+type ReadOnlyTool interface {
+	ReadOnly() bool
+}
+
+// Tool and the optional interfaces must stay interchangeable with gmn's own
+var (
+	_ tools.BuiltinTool  = Tool(nil)
+	_ Tool               = tools.BuiltinTool(nil)
+	_ tools.ReadOnlyTool = ReadOnlyTool(nil)
+)
+
+// Registry holds the tools offered to the model: the built-in tools and
+// those added with Register
+type Registry struct {
+	tools.Handle
+}
+
+// NewRegistry returns a registry with the built-in tools, working in workDir
+func NewRegistry(workDir string) *Registry {
+	return &Registry{tools.NewHandle(tools.NewRegistry(workDir))}
+}
+
+// internal returns the registry behind r
+func (r *Registry) internal() *tools.Registry {
+	return tools.RegistryOf(r)
+}
+
+// Register adds a tool, replacing one with the same name
+func (r *Registry) Register(tool Tool) {
+	r.internal().Register(tool)
+}
+
+// Get returns an enabled tool by name
+func (r *Registry) Get(name string) (Tool, bool) {
+	tool, ok := r.internal().Get(name)
+	if !ok {
+		return nil, false
+	}
+	return tool, true
+}
+
+// GetAll returns all enabled tools
+func (r *Registry) GetAll() []Tool {
+	all := r.internal().GetAll()
+	list := make([]Tool, len(all))
+	for i, tool := range all {
+		list[i] = tool
+	}
+	return list
+}
+
+// GetToolNames returns the names of all enabled tools
+func (r *Registry) GetToolNames() []string {
+	return r.internal().GetToolNames()
+}
+
+// Optional result fields that tell gmn how to display a tool result
+const (
+	KeyMediaType = tools.KeyMediaType // One of the Media* constants
+	KeySummary   = tools.KeySummary   // One-line summary shown next to the tool name
+	KeyBody      = tools.KeyBody      // Name of the field holding the body (overrides the default)
+	KeyColumns   = tools.KeyColumns   // Column order for MediaTable
+)
+
+// Media types for KeyMediaType, with the field that holds the body by default
+const (
+	MediaText     = tools.MediaText     // "content": plain text
+	MediaMarkdown = tools.MediaMarkdown // "content": markdown
+	MediaDiff     = tools.MediaDiff     // "diff": unified diff
+	MediaTable    = tools.MediaTable    // "rows": list of objects (see KeyColumns)
+	MediaImage    = tools.MediaImage    // "path": path of an image file
+)